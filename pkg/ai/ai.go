@@ -1,6 +1,11 @@
 // ai declares an interface for accessing various AI backends.
 package ai
 
+import (
+	"context"
+	"strings"
+)
+
 // Client Is an interface which declares common methods expected of
 // various NLP models; regardless of the architecture style or method
 // of accessing.
@@ -12,14 +17,23 @@ type Client interface {
 // GenerateClient Describes a client which can generate code/text from an AI backend.
 type GenerateClient interface {
 	// Generate Returns a list of string which are potential completions
-	// for the given prompt.
-	Generate() ([]string, error)
+	// for the given prompt. The request is aborted once ctx is done.
+	Generate(ctx context.Context) ([]string, error)
+}
+
+// StreamingGenerateClient Describes a GenerateClient which can also stream its
+// completions back as they are generated.
+type StreamingGenerateClient interface {
+	GenerateClient
+	// GenerateStream Calls onChunk with each piece of text as it is generated,
+	// and returns the full text of every completion once they're done.
+	GenerateStream(ctx context.Context, onChunk func(chunk string)) ([]string, error)
 }
 
 // EditClient Describes an AI client capable of implementing the edit function.
 type EditClient interface {
-	// Edit Returns a list of edits made to the provided data.
-	Edit() ([]string, error)
+	// Edit Returns a list of edits made to the provided data. The request is aborted once ctx is done.
+	Edit(ctx context.Context) ([]string, error)
 }
 
 // Backend Defines a type specifically for backends.
@@ -34,6 +48,120 @@ const (
 	BLOOM Backend = "bloom"
 	// OPT Declares the OPT-175B AI Backend, created by Meta.
 	OPT Backend = "opt"
+	// CLAUDE Declares the Claude AI backend, created and hosted by Anthropic.
+	CLAUDE Backend = "claude"
+	// OLLAMA Declares a model served locally by Ollama.
+	OLLAMA Backend = "ollama"
+	// HUGGINGFACE Declares any text-generation model hosted on the HuggingFace Inference API.
+	HUGGINGFACE Backend = "huggingface"
+	// COHERE Declares the Cohere AI backend, created and hosted by Cohere.
+	COHERE Backend = "cohere"
+	// GEMINI Declares Google's Gemini models, served by the Generative Language API or Vertex AI.
+	GEMINI Backend = "gemini"
 	// Unselected Represents an empty AI backend type.
 	Unselected Backend = ""
 )
+
+// SupportsMultipleCompletions Reports whether the backend can return several completions
+// from a single request. Other backends need a request for every completion.
+func SupportsMultipleCompletions(backend Backend) bool {
+	switch backend {
+	case GPT3, HUGGINGFACE, COHERE:
+		return true
+	case GPTJ, BLOOM, OPT, CLAUDE, OLLAMA, GEMINI, Unselected:
+		return false
+	default:
+		return false
+	}
+}
+
+// ContextWindow Returns the number of tokens the given model of the backend can attend to,
+// shared between the prompt and the completion. Zero means the limit is unknown.
+func ContextWindow(backend Backend, model string) int {
+	switch backend {
+	case GPT3:
+		return windowByPrefix(model, openAIContextWindows())
+	case CLAUDE:
+		// older models had smaller windows
+		if strings.HasPrefix(model, "claude-2") || strings.HasPrefix(model, "claude-instant") {
+			return 100000 //nolint:gomnd // documented by Anthropic
+		}
+		return 200000 //nolint:gomnd // documented by Anthropic
+	case COHERE:
+		return windowByPrefix(model, cohereContextWindows())
+	case GEMINI:
+		return windowByPrefix(model, geminiContextWindows())
+	case GPTJ, BLOOM, OPT:
+		return 2048 //nolint:gomnd // the sequence length these models were trained with
+	case OLLAMA, HUGGINGFACE, Unselected:
+		// the window is set by how the server loads the model rather than by the model
+		return 0
+	default:
+		return 0
+	}
+}
+
+// modelWindow Is the context window of the models whose names start with the prefix.
+type modelWindow struct {
+	prefix string
+	window int
+}
+
+// windowByPrefix Returns the window of the first entry whose prefix the model starts with,
+// so longer prefixes must come first. Zero is returned for unknown models.
+func windowByPrefix(model string, windows []modelWindow) int {
+	for _, w := range windows {
+		if strings.HasPrefix(model, w.prefix) {
+			return w.window
+		}
+	}
+	return 0
+}
+
+// openAIContextWindows Returns the context windows documented by OpenAI for its models.
+//
+//nolint:gomnd // documented by OpenAI
+func openAIContextWindows() []modelWindow {
+	return []modelWindow{
+		{"gpt-5", 400000},
+		{"gpt-4.1", 1047576},
+		{"gpt-4o", 128000},
+		{"gpt-4-turbo", 128000},
+		{"gpt-4-1106", 128000},
+		{"gpt-4-0125", 128000},
+		{"gpt-4-32k", 32768},
+		{"gpt-4", 8192},
+		{"gpt-3.5-turbo-instruct", 4096},
+		{"gpt-3.5-turbo", 16385},
+		{"o1-mini", 128000},
+		{"o1", 200000},
+		{"o3", 200000},
+		{"o4", 200000},
+		{"code-davinci-002", 8001},
+		{"code-cushman", 2048},
+		{"text-davinci", 4097},
+	}
+}
+
+// cohereContextWindows Returns the context windows documented by Cohere for its models.
+//
+//nolint:gomnd // documented by Cohere
+func cohereContextWindows() []modelWindow {
+	return []modelWindow{
+		{"command-a", 256000},
+		{"command-r", 128000},
+		{"command", 4096},
+	}
+}
+
+// geminiContextWindows Returns the context windows documented by Google for its models.
+//
+//nolint:gomnd // documented by Google
+func geminiContextWindows() []modelWindow {
+	return []modelWindow{
+		{"gemini-1.5-pro", 2097152},
+		{"gemini-1.0", 32760},
+		{"gemini-pro", 32760},
+		{"gemini-", 1048576},
+	}
+}
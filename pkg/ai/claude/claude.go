@@ -0,0 +1,177 @@
+// claude Implements a client for Anthropic's Claude models, using the messages API.
+package claude
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/redhat-et/copilot-ops/pkg/ai"
+	"github.com/redhat-et/copilot-ops/pkg/utils"
+)
+
+// Define the constants used by Anthropic's API here.
+const (
+	APIURL           = "https://api.anthropic.com"
+	MessagesEndpoint = "v1/messages"
+	// APIVersion Is the version of the API sent in the 'anthropic-version' header.
+	APIVersion = "2023-06-01"
+	// DefaultModel Is the model used when none is configured.
+	DefaultModel = "claude-sonnet-4-5"
+	// RoleUser Is the role of messages written by the user.
+	RoleUser = "user"
+	// ContentTypeText Is the type of content blocks which contain text.
+	ContentTypeText = "text"
+	// ThinkingEnabled Is the type of thinking which lets Claude reason before it answers.
+	ThinkingEnabled = "enabled"
+)
+
+// Config Describes the structure needed for configuring a Claude client.
+type Config struct {
+	// APIKey Is the Anthropic API key, sent in the 'x-api-key' header.
+	APIKey string `json:"apiKey" yaml:"apiKey"`
+	// Model Is the name of the Claude model to use.
+	Model string `json:"model" yaml:"model"`
+	// URL Defines the URL which the HTTP Client will be making requests to.
+	URL string `json:"url" yaml:"url"`
+	// HTTPClient Is used to make requests to the API, defaulting to http.DefaultClient.
+	HTTPClient *http.Client `json:"-" yaml:"-"`
+	// SystemPrompt Is sent as the system prompt of every request, if set.
+	SystemPrompt string `json:"-" yaml:"-"`
+	// ThinkingBudget Is the number of tokens Claude may spend thinking before it answers, if set.
+	ThinkingBudget int `json:"-" yaml:"-"`
+}
+
+// Thinking Enables Claude's extended thinking, with a budget of tokens for it.
+type Thinking struct {
+	Type         string `json:"type"`
+	BudgetTokens int    `json:"budget_tokens"`
+}
+
+// Message Is a single turn of the conversation sent to Claude.
+type Message struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+// MessagesRequest Defines the parameters which are sent when requesting
+// a message from Claude.
+type MessagesRequest struct {
+	Model       string    `json:"model"`
+	MaxTokens   int       `json:"max_tokens"`
+	System      string    `json:"system,omitempty"`
+	Messages    []Message `json:"messages"`
+	Temperature float32   `json:"temperature"`
+	TopP        *float32  `json:"top_p,omitempty"`
+	Thinking    *Thinking `json:"thinking,omitempty"`
+}
+
+// contentBlock Is a single block of content in Claude's response.
+type contentBlock struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+// messagesResponse Represents the body returned by the messages endpoint.
+type messagesResponse struct {
+	Content []contentBlock `json:"content"`
+}
+
+// claudeClient Is a client implementation of Claude meant to implement
+// the AI Client interface.
+type claudeClient struct {
+	conf         Config
+	httpClient   *http.Client
+	params       MessagesRequest
+	nCompletions int
+}
+
+// Generate Returns a list of completions created by Claude for the given prompt.
+// The messages API returns a single message per request, so one request
+// is made for every completion.
+func (c claudeClient) Generate(ctx context.Context) ([]string, error) {
+	choices := make([]string, 0, c.nCompletions)
+	for i := 0; i < c.nCompletions; i++ {
+		choice, err := c.createMessage(ctx)
+		if err != nil {
+			return nil, err
+		}
+		choices = append(choices, choice)
+	}
+	return choices, nil
+}
+
+// createMessage Requests a single message from Claude and returns its text.
+func (c claudeClient) createMessage(ctx context.Context) (string, error) {
+	reqBytes, err := json.Marshal(c.params)
+	if err != nil {
+		return "", fmt.Errorf("could not send request: %w", err)
+	}
+
+	// create request
+	urlPath := c.conf.URL + "/" + MessagesEndpoint
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, urlPath, bytes.NewBuffer(reqBytes))
+	if err != nil {
+		return "", fmt.Errorf("could not create request: %w", err)
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("x-api-key", c.conf.APIKey)
+	req.Header.Set("anthropic-version", APIVersion)
+
+	// transform request into response
+	var response messagesResponse
+	if err = utils.JSONRequest(req, c.httpClient, &response); err != nil {
+		return "", fmt.Errorf("could not request claude: %w", err)
+	}
+	var text strings.Builder
+	for _, block := range response.Content {
+		if block.Type == ContentTypeText {
+			text.WriteString(block.Text)
+		}
+	}
+	return text.String(), nil
+}
+
+// CreateClaudeGenerateClient Returns a Claude client capable of making code generations.
+func CreateClaudeGenerateClient(
+	conf Config,
+	prompt string,
+	maxTokens, nCompletions int,
+	temperature float32,
+	topP *float32,
+) ai.GenerateClient {
+	model := conf.Model
+	if model == "" {
+		model = DefaultModel
+	}
+	c := claudeClient{
+		conf:       conf,
+		httpClient: http.DefaultClient,
+		params: MessagesRequest{
+			Model:     model,
+			MaxTokens: maxTokens,
+			System:    conf.SystemPrompt,
+			Messages: []Message{
+				{Role: RoleUser, Content: prompt},
+			},
+			Temperature: temperature,
+			TopP:        topP,
+		},
+		nCompletions: nCompletions,
+	}
+	if conf.ThinkingBudget > 0 {
+		// the budget counts towards max_tokens, and thinking only samples with the default temperature
+		c.params.Thinking = &Thinking{Type: ThinkingEnabled, BudgetTokens: conf.ThinkingBudget}
+		c.params.MaxTokens += conf.ThinkingBudget
+		c.params.Temperature = 1
+		c.params.TopP = nil
+	}
+	if conf.HTTPClient != nil {
+		c.httpClient = conf.HTTPClient
+	}
+	return c
+}
@@ -0,0 +1,13 @@
+package claude_test
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func TestClaude(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Claude Suite")
+}
@@ -0,0 +1,87 @@
+package claude_test
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/redhat-et/copilot-ops/pkg/ai/claude"
+)
+
+// roundTripFunc Stubs the HTTP transport with a function.
+type roundTripFunc func(req *http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+var _ = Describe("Claude Generate Client", func() {
+	var requests []*http.Request
+	var bodies []claude.MessagesRequest
+	var status int
+	var conf claude.Config
+
+	BeforeEach(func() {
+		requests, bodies = nil, nil
+		status = http.StatusOK
+		conf = claude.Config{
+			APIKey: "abc",
+			URL:    claude.APIURL,
+			HTTPClient: &http.Client{
+				Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+					var body claude.MessagesRequest
+					Expect(json.NewDecoder(req.Body).Decode(&body)).To(Succeed())
+					requests = append(requests, req)
+					bodies = append(bodies, body)
+					return &http.Response{
+						StatusCode: status,
+						Header:     http.Header{"Content-Type": []string{"application/json"}},
+						Body: io.NopCloser(strings.NewReader(
+							`{"content": [{"type": "text", "text": "kind: "}, {"type": "text", "text": "Pod"}]}`,
+						)),
+					}, nil
+				}),
+			},
+		}
+	})
+
+	It("shapes the request for the messages API", func() {
+		client := claude.CreateClaudeGenerateClient(conf, "hello world", 256, 1, 0, nil)
+		_, err := client.Generate(context.Background())
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(requests).To(HaveLen(1))
+		Expect(requests[0].URL.String()).To(Equal(claude.APIURL + "/" + claude.MessagesEndpoint))
+		Expect(requests[0].Header.Get("x-api-key")).To(Equal("abc"))
+		Expect(requests[0].Header.Get("anthropic-version")).To(Equal(claude.APIVersion))
+		Expect(bodies[0]).To(Equal(claude.MessagesRequest{
+			Model:     claude.DefaultModel,
+			MaxTokens: 256,
+			Messages:  []claude.Message{{Role: claude.RoleUser, Content: "hello world"}},
+		}))
+	})
+
+	It("decodes the text of every completion", func() {
+		conf.Model = "claude-opus-4-1"
+		client := claude.CreateClaudeGenerateClient(conf, "hello world", 256, 2, 0.5, nil)
+		choices, err := client.Generate(context.Background())
+		Expect(err).NotTo(HaveOccurred())
+		Expect(choices).To(Equal([]string{"kind: Pod", "kind: Pod"}))
+		Expect(requests).To(HaveLen(2))
+		Expect(bodies[1].Model).To(Equal("claude-opus-4-1"))
+		Expect(bodies[1].Temperature).To(BeNumerically("==", 0.5))
+	})
+
+	It("fails when the API returns an error", func() {
+		status = http.StatusUnauthorized
+		client := claude.CreateClaudeGenerateClient(conf, "hello world", 256, 1, 0, nil)
+		choices, err := client.Generate(context.Background())
+		Expect(err).To(MatchError(ContainSubstring("status code: 401")))
+		Expect(choices).To(BeEmpty())
+	})
+})
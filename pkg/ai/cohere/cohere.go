@@ -0,0 +1,186 @@
+// cohere Implements a client for Cohere's models, using the generate API.
+package cohere
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/redhat-et/copilot-ops/pkg/ai"
+	"github.com/redhat-et/copilot-ops/pkg/utils"
+)
+
+// Define the constants used by Cohere's API here.
+const (
+	APIURL           = "https://api.cohere.ai"
+	GenerateEndpoint = "v1/generate"
+	// DefaultModel Is the model used when none is configured.
+	DefaultModel = "command"
+	// MaxGenerations Is the most completions which Cohere returns from a single request.
+	MaxGenerations = 5
+)
+
+// Config Describes the structure needed for configuring a Cohere client.
+type Config struct {
+	// APIKey Is the Cohere API key, sent as a bearer token.
+	APIKey string `json:"apiKey" yaml:"apiKey"`
+	// Model Is the name of the Cohere model to use.
+	Model string `json:"model" yaml:"model"`
+	// URL Defines the URL which the HTTP Client will be making requests to.
+	URL string `json:"url" yaml:"url"`
+	// HTTPClient Is used to make requests to the API, defaulting to http.DefaultClient.
+	HTTPClient *http.Client `json:"-" yaml:"-"`
+}
+
+// GenerateRequest Defines the parameters which are sent when requesting
+// completions from Cohere.
+type GenerateRequest struct {
+	Model          string   `json:"model"`
+	Prompt         string   `json:"prompt"`
+	MaxTokens      int      `json:"max_tokens"`
+	NumGenerations int      `json:"num_generations"`
+	Temperature    float32  `json:"temperature"`
+	P              *float32 `json:"p,omitempty"`
+}
+
+// generation Is a single completion in Cohere's response.
+type generation struct {
+	Text string `json:"text"`
+}
+
+// billedUnits Is the number of tokens which Cohere billed for a request.
+type billedUnits struct {
+	InputTokens  int `json:"input_tokens"`
+	OutputTokens int `json:"output_tokens"`
+}
+
+// generateResponse Represents the envelope returned by the generate endpoint.
+type generateResponse struct {
+	Generations []generation `json:"generations"`
+	Meta        struct {
+		BilledUnits billedUnits `json:"billed_units"`
+	} `json:"meta"`
+	// Message Explains why the request failed.
+	Message string `json:"message,omitempty"`
+}
+
+// cohereClient Is a client implementation of Cohere meant to implement
+// the AI Client interface.
+type cohereClient struct {
+	conf         Config
+	httpClient   *http.Client
+	params       GenerateRequest
+	nCompletions int
+	// usage Records the tokens used by the last generation.
+	usage *ai.Usage
+}
+
+// Generate Returns a list of completions created by Cohere for the given prompt.
+// Cohere returns at most MaxGenerations completions per request, so more requests
+// are made when more completions are needed.
+func (c cohereClient) Generate(ctx context.Context) ([]string, error) {
+	*c.usage = ai.Usage{}
+	choices := make([]string, 0, c.nCompletions)
+	for len(choices) < c.nCompletions {
+		params := c.params
+		params.NumGenerations = c.nCompletions - len(choices)
+		if params.NumGenerations > MaxGenerations {
+			params.NumGenerations = MaxGenerations
+		}
+		generated, err := c.generate(ctx, params)
+		if err != nil {
+			return nil, err
+		}
+		if len(generated) == 0 {
+			return nil, fmt.Errorf("cohere returned no generations")
+		}
+		choices = append(choices, generated...)
+	}
+	return choices, nil
+}
+
+// generate Requests a batch of completions from Cohere.
+func (c cohereClient) generate(ctx context.Context, params GenerateRequest) ([]string, error) {
+	reqBytes, err := json.Marshal(params)
+	if err != nil {
+		return nil, fmt.Errorf("could not send request: %w", err)
+	}
+
+	// create request
+	urlPath := c.conf.URL + "/" + GenerateEndpoint
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, urlPath, bytes.NewBuffer(reqBytes))
+	if err != nil {
+		return nil, fmt.Errorf("could not create request: %w", err)
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+c.conf.APIKey)
+
+	res, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error making request: %w", err)
+	}
+	defer res.Body.Close()
+
+	// wrap the HTTP error
+	var response generateResponse
+	decodeErr := json.NewDecoder(res.Body).Decode(&response)
+	if res.StatusCode < http.StatusOK || res.StatusCode >= http.StatusBadRequest {
+		if decodeErr == nil && response.Message != "" {
+			return nil, utils.NewStatusError(res, response.Message)
+		}
+		return nil, utils.NewStatusError(res, "")
+	}
+	if decodeErr != nil {
+		return nil, fmt.Errorf("could not read response: %w", decodeErr)
+	}
+
+	c.usage.PromptTokens += response.Meta.BilledUnits.InputTokens
+	c.usage.CompletionTokens += response.Meta.BilledUnits.OutputTokens
+	choices := make([]string, len(response.Generations))
+	for i, g := range response.Generations {
+		choices[i] = g.Text
+	}
+	return choices, nil
+}
+
+// Usage Returns the tokens used by the last generation, as billed by Cohere.
+func (c cohereClient) Usage() (ai.Usage, bool) {
+	if c.usage.PromptTokens == 0 && c.usage.CompletionTokens == 0 {
+		return ai.Usage{}, false
+	}
+	return *c.usage, true
+}
+
+// CreateCohereGenerateClient Returns a Cohere client capable of making code generations.
+func CreateCohereGenerateClient(
+	conf Config,
+	prompt string,
+	maxTokens, nCompletions int,
+	temperature float32,
+	topP *float32,
+) ai.GenerateClient {
+	model := conf.Model
+	if model == "" {
+		model = DefaultModel
+	}
+	c := cohereClient{
+		conf:       conf,
+		httpClient: http.DefaultClient,
+		params: GenerateRequest{
+			Model:       model,
+			Prompt:      prompt,
+			MaxTokens:   maxTokens,
+			Temperature: temperature,
+			P:           topP,
+		},
+		nCompletions: nCompletions,
+		usage:        &ai.Usage{},
+	}
+	if conf.HTTPClient != nil {
+		c.httpClient = conf.HTTPClient
+	}
+	return c
+}
@@ -0,0 +1,113 @@
+package cohere_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/redhat-et/copilot-ops/pkg/ai"
+	"github.com/redhat-et/copilot-ops/pkg/ai/cohere"
+)
+
+// roundTripFunc Stubs the HTTP transport with a function.
+type roundTripFunc func(req *http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+var _ = Describe("Cohere Generate Client", func() {
+	var requests []*http.Request
+	var bodies []cohere.GenerateRequest
+	var status int
+	var response string
+	var conf cohere.Config
+
+	BeforeEach(func() {
+		requests, bodies = nil, nil
+		status = http.StatusOK
+		response = ""
+		conf = cohere.Config{
+			APIKey: "abc",
+			URL:    cohere.APIURL,
+			HTTPClient: &http.Client{
+				Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+					var body cohere.GenerateRequest
+					Expect(json.NewDecoder(req.Body).Decode(&body)).To(Succeed())
+					requests = append(requests, req)
+					bodies = append(bodies, body)
+					text := response
+					if text == "" {
+						// answer with as many generations as were asked for
+						generations := make([]string, body.NumGenerations)
+						for i := range generations {
+							generations[i] = fmt.Sprintf(`{"id": "%d", "text": "kind: Pod"}`, i)
+						}
+						text = fmt.Sprintf(`{"id": "gen", "generations": [%s], "prompt": %q, `+
+							`"meta": {"billed_units": {"input_tokens": 10, "output_tokens": 3}}}`,
+							strings.Join(generations, ", "), body.Prompt)
+					}
+					return &http.Response{
+						StatusCode: status,
+						Header:     http.Header{"Content-Type": []string{"application/json"}},
+						Body:       io.NopCloser(strings.NewReader(text)),
+					}, nil
+				}),
+			},
+		}
+	})
+
+	It("shapes the request for the generate API", func() {
+		topP := float32(0.75)
+		client := cohere.CreateCohereGenerateClient(conf, "hello world", 256, 2, 0.5, &topP)
+		_, err := client.Generate(context.Background())
+		Expect(err).NotTo(HaveOccurred())
+		Expect(requests).To(HaveLen(1))
+		Expect(requests[0].URL.String()).To(Equal(cohere.APIURL + "/" + cohere.GenerateEndpoint))
+		Expect(requests[0].Header.Get("Authorization")).To(Equal("Bearer abc"))
+		Expect(bodies[0]).To(Equal(cohere.GenerateRequest{
+			Model:          cohere.DefaultModel,
+			Prompt:         "hello world",
+			MaxTokens:      256,
+			NumGenerations: 2,
+			Temperature:    0.5,
+			P:              &topP,
+		}))
+	})
+
+	It("decodes the generations from the response envelope", func() {
+		conf.Model = "command-light"
+		client := cohere.CreateCohereGenerateClient(conf, "hello world", 256, 7, 0, nil)
+		choices, err := client.Generate(context.Background())
+		Expect(err).NotTo(HaveOccurred())
+		Expect(choices).To(HaveLen(7))
+		Expect(choices[0]).To(Equal("kind: Pod"))
+		// only a few generations are returned per request
+		Expect(requests).To(HaveLen(2))
+		Expect(bodies[0].NumGenerations).To(Equal(cohere.MaxGenerations))
+		Expect(bodies[1].NumGenerations).To(Equal(2))
+		Expect(bodies[1].Model).To(Equal("command-light"))
+
+		reporter, ok := client.(ai.UsageReportingClient)
+		Expect(ok).To(BeTrue())
+		usage, reported := reporter.Usage()
+		Expect(reported).To(BeTrue())
+		Expect(usage).To(Equal(ai.Usage{PromptTokens: 20, CompletionTokens: 6}))
+	})
+
+	It("fails with the message of the API's error", func() {
+		status = http.StatusTooManyRequests
+		response = `{"message": "you are using a Trial key, which is limited"}`
+		client := cohere.CreateCohereGenerateClient(conf, "hello world", 256, 1, 0, nil)
+		choices, err := client.Generate(context.Background())
+		Expect(err).To(MatchError("error, status code: 429, message: you are using a Trial key, which is limited"))
+		Expect(ai.IsRetryable(err)).To(BeTrue())
+		Expect(choices).To(BeEmpty())
+	})
+})
@@ -0,0 +1,81 @@
+package ai
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"sync"
+)
+
+// ConcurrencyLimiter Bounds how many requests are in flight at once. It's shared by every request made through it,
+// whichever backend or feature makes them, so requests wait for a slot once the bound is reached.
+type ConcurrencyLimiter struct {
+	slots chan struct{}
+}
+
+// NewConcurrencyLimiter Returns a limiter which allows n requests at once, or a single one when n isn't positive.
+func NewConcurrencyLimiter(n int) *ConcurrencyLimiter {
+	if n < 1 {
+		n = 1
+	}
+	return &ConcurrencyLimiter{slots: make(chan struct{}, n)}
+}
+
+// Acquire Blocks until a slot is free and takes it. The context's error is returned when it's done first.
+func (l *ConcurrencyLimiter) Acquire(ctx context.Context) error {
+	select {
+	case l.slots <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Release Frees a slot which was taken with Acquire.
+func (l *ConcurrencyLimiter) Release() {
+	<-l.slots
+}
+
+// ConcurrencyLimitTransport Returns a transport which takes a slot of the limiter for every request,
+// holding it until the response's body is closed so that streamed responses count as in flight.
+// A nil transport stands for http.DefaultTransport.
+func ConcurrencyLimitTransport(limiter *ConcurrencyLimiter, transport http.RoundTripper) http.RoundTripper {
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	return concurrencyLimitedTransport{limiter: limiter, transport: transport}
+}
+
+// concurrencyLimitedTransport Takes a slot of the limiter for each request made with the transport.
+type concurrencyLimitedTransport struct {
+	limiter   *ConcurrencyLimiter
+	transport http.RoundTripper
+}
+
+// RoundTrip Makes the request once the limiter has a free slot.
+func (t concurrencyLimitedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if err := t.limiter.Acquire(req.Context()); err != nil {
+		return nil, err
+	}
+	res, err := t.transport.RoundTrip(req)
+	if err != nil {
+		t.limiter.Release()
+		return nil, err
+	}
+	res.Body = &releasingBody{ReadCloser: res.Body, release: t.limiter.Release}
+	return res, nil
+}
+
+// releasingBody Frees the slot of its request once it's closed.
+type releasingBody struct {
+	io.ReadCloser
+	once    sync.Once
+	release func()
+}
+
+// Close Closes the body and frees the slot, only the first time it's called.
+func (b *releasingBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(b.release)
+	return err
+}
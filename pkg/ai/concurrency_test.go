@@ -0,0 +1,73 @@
+package ai_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/redhat-et/copilot-ops/pkg/ai"
+)
+
+var _ = Describe("ConcurrencyLimiter", func() {
+	var inFlight, maxInFlight int32
+	var server *httptest.Server
+
+	BeforeEach(func() {
+		inFlight, maxInFlight = 0, 0
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			current := atomic.AddInt32(&inFlight, 1)
+			defer atomic.AddInt32(&inFlight, -1)
+			for {
+				highest := atomic.LoadInt32(&maxInFlight)
+				if current <= highest || atomic.CompareAndSwapInt32(&maxInFlight, highest, current) {
+					break
+				}
+			}
+			time.Sleep(10 * time.Millisecond)
+			_, _ = w.Write([]byte("ok"))
+		}))
+		DeferCleanup(server.Close)
+	})
+
+	It("bounds the requests in flight across every client sharing it", func() {
+		limiter := ai.NewConcurrencyLimiter(2)
+		clients := []*http.Client{
+			{Transport: ai.ConcurrencyLimitTransport(limiter, nil)},
+			{Transport: ai.ConcurrencyLimitTransport(limiter, nil)},
+		}
+
+		_, err := ai.GenerateConcurrently(12, 6, func(i int) ([]string, error) {
+			res, err := clients[i%len(clients)].Get(server.URL)
+			if err != nil {
+				return nil, err
+			}
+			defer res.Body.Close()
+			body, err := io.ReadAll(res.Body)
+			return []string{string(body)}, err
+		})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(maxInFlight).To(BeEquivalentTo(2))
+	})
+
+	It("holds the slot until the body is closed", func() {
+		limiter := ai.NewConcurrencyLimiter(1)
+		client := &http.Client{Transport: ai.ConcurrencyLimitTransport(limiter, nil)}
+		res, err := client.Get(server.URL)
+		Expect(err).NotTo(HaveOccurred())
+
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+		Expect(limiter.Acquire(ctx)).To(MatchError(context.DeadlineExceeded))
+
+		Expect(res.Body.Close()).To(Succeed())
+		Expect(res.Body.Close()).To(Succeed())
+		Expect(limiter.Acquire(context.Background())).To(Succeed())
+		limiter.Release()
+	})
+})
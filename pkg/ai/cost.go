@@ -0,0 +1,73 @@
+package ai
+
+import "strings"
+
+// tokensPerMillion Is the number of tokens which prices are quoted for.
+const tokensPerMillion = 1_000_000
+
+// Usage Is the number of tokens a generation consumed.
+type Usage struct {
+	// PromptTokens Is the number of tokens read from the prompt, over every request.
+	PromptTokens int `json:"prompt_tokens"`
+	// CompletionTokens Is the number of tokens generated, over every completion.
+	CompletionTokens int `json:"completion_tokens"`
+}
+
+// UsageReportingClient Describes a GenerateClient which knows how many tokens its last generation used,
+// as reported by the backend.
+type UsageReportingClient interface {
+	GenerateClient
+	// Usage Returns the usage of the last call to Generate, and false if nothing was generated yet.
+	Usage() (Usage, bool)
+}
+
+// Price Is what a model costs in US dollars, per million tokens.
+type Price struct {
+	Prompt     float64
+	Completion float64
+}
+
+// Cost Returns the price in US dollars of the given usage.
+func (p Price) Cost(usage Usage) float64 {
+	return (float64(usage.PromptTokens)*p.Prompt + float64(usage.CompletionTokens)*p.Completion) / tokensPerMillion
+}
+
+// PriceFor Returns the price of the model served by the backend, and false if the price is unknown.
+// This is the only place prices are kept, and they need updating whenever the providers change them.
+func PriceFor(backend Backend, model string) (Price, bool) {
+	switch backend {
+	case OLLAMA:
+		// models served locally don't cost anything per token
+		return Price{}, true
+	case GPT3, CLAUDE:
+	case GPTJ, BLOOM, OPT, HUGGINGFACE, COHERE, GEMINI, Unselected:
+		return Price{}, false
+	default:
+		return Price{}, false
+	}
+
+	// more specific model names come before the names they start with
+	prices := []struct { //nolint:gomnd // the list prices published by the providers
+		prefix string
+		price  Price
+	}{
+		{"gpt-4o-mini", Price{Prompt: 0.15, Completion: 0.60}},
+		{"gpt-4o", Price{Prompt: 2.50, Completion: 10}},
+		{"gpt-4-turbo", Price{Prompt: 10, Completion: 30}},
+		{"gpt-4-32k", Price{Prompt: 60, Completion: 120}},
+		{"gpt-4", Price{Prompt: 30, Completion: 60}},
+		{"gpt-3.5-turbo", Price{Prompt: 0.50, Completion: 1.50}},
+		{"code-davinci-", Price{Prompt: 20, Completion: 20}},
+		{"text-davinci-", Price{Prompt: 20, Completion: 20}},
+		{"claude-opus-4", Price{Prompt: 15, Completion: 75}},
+		{"claude-sonnet-4", Price{Prompt: 3, Completion: 15}},
+		{"claude-haiku-4", Price{Prompt: 1, Completion: 5}},
+		{"claude-3-5-haiku", Price{Prompt: 0.80, Completion: 4}},
+	}
+	for _, p := range prices {
+		if strings.HasPrefix(model, p.prefix) {
+			return p.price, true
+		}
+	}
+	return Price{}, false
+}
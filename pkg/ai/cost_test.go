@@ -0,0 +1,39 @@
+package ai_test
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/redhat-et/copilot-ops/pkg/ai"
+)
+
+var _ = Describe("PriceFor", func() {
+	It("finds the price of the most specific model", func() {
+		price, ok := ai.PriceFor(ai.GPT3, "gpt-4o-mini-2024-07-18")
+		Expect(ok).To(BeTrue())
+		Expect(price).To(Equal(ai.Price{Prompt: 0.15, Completion: 0.60}))
+
+		price, ok = ai.PriceFor(ai.GPT3, "gpt-4-0613")
+		Expect(ok).To(BeTrue())
+		Expect(price).To(Equal(ai.Price{Prompt: 30, Completion: 60}))
+	})
+
+	It("prices local models at nothing", func() {
+		price, ok := ai.PriceFor(ai.OLLAMA, "codellama")
+		Expect(ok).To(BeTrue())
+		Expect(price.Cost(ai.Usage{PromptTokens: 1000, CompletionTokens: 500})).To(BeZero())
+	})
+
+	It("doesn't know the price of other models", func() {
+		_, ok := ai.PriceFor(ai.GPT3, "my-fine-tune")
+		Expect(ok).To(BeFalse())
+		_, ok = ai.PriceFor(ai.HUGGINGFACE, "gpt-4")
+		Expect(ok).To(BeFalse())
+	})
+
+	It("computes the cost of the usage", func() {
+		price := ai.Price{Prompt: 30, Completion: 60}
+		cost := price.Cost(ai.Usage{PromptTokens: 1000, CompletionTokens: 500})
+		Expect(cost).To(BeNumerically("~", 0.06, 1e-9))
+	})
+})
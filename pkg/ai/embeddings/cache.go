@@ -0,0 +1,97 @@
+package embeddings
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+
+	"github.com/redhat-et/copilot-ops/pkg/logger"
+)
+
+// DirName Is the name of the directory under the cache directory where embeddings are stored.
+const DirName = "embeddings"
+
+// Cache Stores embeddings on disk, keyed by the model and a hash of the text which was embedded,
+// so that only new or changed files are embedded again.
+type Cache struct {
+	// Dir Is the directory which the embeddings are stored in.
+	Dir string
+}
+
+// Key Returns the key which the embedding of the text by the model is stored under.
+func Key(model, text string) string {
+	sum := sha256.Sum256([]byte(model + "\n" + truncate(text)))
+	return hex.EncodeToString(sum[:])
+}
+
+// Load Returns the embedding stored under the key, and whether it was found.
+// Entries which can't be read are treated as missing.
+func (c *Cache) Load(key string) ([]float64, bool) {
+	data, err := os.ReadFile(c.path(key))
+	if err != nil {
+		return nil, false
+	}
+	var vector []float64
+	if err = json.Unmarshal(data, &vector); err != nil || len(vector) == 0 {
+		return nil, false
+	}
+	return vector, true
+}
+
+// Save Stores the embedding under the key, replacing any existing entry.
+func (c *Cache) Save(key string, vector []float64) error {
+	if err := os.MkdirAll(c.Dir, 0755); err != nil {
+		return err
+	}
+	data, err := json.Marshal(vector)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(c.path(key), data, 0600)
+}
+
+// path Returns the path of the file which stores the entry for the key.
+func (c *Cache) path(key string) string {
+	return filepath.Join(c.Dir, key+".json")
+}
+
+// EmbedCached Returns the embedding of every text, only requesting the ones which aren't cached.
+// Without a cache, every text is embedded. The new embeddings are cached, and failing to
+// cache them isn't an error, since they can always be requested again.
+func EmbedCached(ctx context.Context, client *Client, cache *Cache, texts []string) ([][]float64, error) {
+	vectors := make([][]float64, len(texts))
+	var missing []int
+	for i, text := range texts {
+		if cache != nil {
+			if vector, ok := cache.Load(Key(client.Model(), text)); ok {
+				vectors[i] = vector
+				continue
+			}
+		}
+		missing = append(missing, i)
+	}
+	if len(missing) == 0 {
+		return vectors, nil
+	}
+
+	inputs := make([]string, len(missing))
+	for i, index := range missing {
+		inputs[i] = texts[index]
+	}
+	embedded, err := client.Embed(ctx, inputs)
+	if err != nil {
+		return nil, err
+	}
+	for i, index := range missing {
+		vectors[index] = embedded[i]
+		if cache != nil {
+			if err = cache.Save(Key(client.Model(), texts[index]), embedded[i]); err != nil {
+				logger.Warnf("could not cache an embedding: %s\n", err)
+			}
+		}
+	}
+	return vectors, nil
+}
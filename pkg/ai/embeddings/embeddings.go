@@ -0,0 +1,160 @@
+// embeddings Requests embeddings of text from an OpenAI-compatible embeddings endpoint,
+// and ranks documents by how similar they are to a query.
+package embeddings
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+
+	"github.com/redhat-et/copilot-ops/pkg/utils"
+)
+
+const (
+	// Endpoint Is the endpoint which embeddings are requested from, relative to the base URL.
+	Endpoint = "embeddings"
+	// DefaultModel Is the model used when none is configured.
+	DefaultModel = "text-embedding-3-small"
+	// MaxInputChars Bounds how much of each text is embedded, keeping every input
+	// well within the token limit of the embedding models.
+	MaxInputChars = 16000
+)
+
+// Config Defines the values required to connect to an embeddings endpoint.
+// Any value left empty is taken from the OpenAI config.
+type Config struct {
+	// URL Is the base URL of the API, which the embeddings endpoint is relative to.
+	URL string `json:"url" yaml:"url"`
+	// APIKey Is sent as a bearer token, if set.
+	APIKey string `json:"apiKey,omitempty" yaml:"apiKey,omitempty"`
+	// Model Is the name of the embedding model, defaulting to DefaultModel.
+	Model string `json:"model,omitempty" yaml:"model,omitempty"`
+	// HTTPClient Is used to make requests to the API, defaulting to http.DefaultClient.
+	HTTPClient *http.Client `json:"-" yaml:"-"`
+}
+
+// Request Defines the body of a request for embeddings.
+type Request struct {
+	Model string   `json:"model"`
+	Input []string `json:"input"`
+}
+
+// response Represents the body returned by the embeddings endpoint.
+type response struct {
+	Data []struct {
+		Index     int       `json:"index"`
+		Embedding []float64 `json:"embedding"`
+	} `json:"data"`
+}
+
+// Client Requests embeddings from the configured endpoint.
+type Client struct {
+	conf Config
+}
+
+// NewClient Returns a client for the configured endpoint.
+func NewClient(conf Config) *Client {
+	if conf.Model == "" {
+		conf.Model = DefaultModel
+	}
+	return &Client{conf: conf}
+}
+
+// Model Returns the name of the model which embeds the texts.
+func (c *Client) Model() string {
+	return c.conf.Model
+}
+
+// Embed Returns the embedding of every input, in the same order, using a single request.
+func (c *Client) Embed(ctx context.Context, inputs []string) ([][]float64, error) {
+	if len(inputs) == 0 {
+		return nil, nil
+	}
+	truncated := make([]string, len(inputs))
+	for i, input := range inputs {
+		truncated[i] = truncate(input)
+	}
+	reqBytes, err := json.Marshal(Request{Model: c.conf.Model, Input: truncated})
+	if err != nil {
+		return nil, fmt.Errorf("could not send request: %w", err)
+	}
+
+	// create request
+	urlPath := c.conf.URL + "/" + Endpoint
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, urlPath, bytes.NewBuffer(reqBytes))
+	if err != nil {
+		return nil, fmt.Errorf("could not create request: %w", err)
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("Content-Type", "application/json")
+	if c.conf.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+c.conf.APIKey)
+	}
+
+	var res response
+	if err = utils.JSONRequest(req, c.conf.HTTPClient, &res); err != nil {
+		return nil, fmt.Errorf("could not request embeddings: %w", err)
+	}
+	vectors := make([][]float64, len(inputs))
+	for _, d := range res.Data {
+		if d.Index < 0 || d.Index >= len(inputs) {
+			return nil, fmt.Errorf("received an embedding for input %d of %d", d.Index, len(inputs))
+		}
+		vectors[d.Index] = d.Embedding
+	}
+	for i, vector := range vectors {
+		if vector == nil {
+			return nil, fmt.Errorf("received no embedding for input %d of %d", i, len(inputs))
+		}
+	}
+	return vectors, nil
+}
+
+// truncate Returns the start of the text which is embedded, without splitting a character.
+func truncate(text string) string {
+	if len(text) <= MaxInputChars {
+		return text
+	}
+	runes := []rune(text)
+	if len(runes) <= MaxInputChars {
+		return text
+	}
+	return string(runes[:MaxInputChars])
+}
+
+// CosineSimilarity Returns the cosine of the angle between the vectors, from -1 to 1.
+// Vectors of different lengths, or without any magnitude, have a similarity of 0.
+func CosineSimilarity(a, b []float64) float64 {
+	if len(a) != len(b) {
+		return 0
+	}
+	var dot, normA, normB float64
+	for i := range a {
+		dot += a[i] * b[i]
+		normA += a[i] * a[i]
+		normB += b[i] * b[i]
+	}
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(normA) * math.Sqrt(normB))
+}
+
+// Rank Returns the indexes of the vectors ordered from the most to the least similar to the query.
+// Vectors which are equally similar keep their order.
+func Rank(query []float64, vectors [][]float64) []int {
+	scores := make([]float64, len(vectors))
+	indexes := make([]int, len(vectors))
+	for i, vector := range vectors {
+		scores[i] = CosineSimilarity(query, vector)
+		indexes[i] = i
+	}
+	sort.SliceStable(indexes, func(i, j int) bool {
+		return scores[indexes[i]] > scores[indexes[j]]
+	})
+	return indexes
+}
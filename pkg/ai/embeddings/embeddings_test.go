@@ -0,0 +1,94 @@
+package embeddings_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/redhat-et/copilot-ops/pkg/ai/embeddings"
+)
+
+// keywords Are the dimensions of the stub embeddings, each counting the mentions of a word.
+func keywords() []string {
+	return []string{"pod", "service", "configmap"}
+}
+
+// EmbeddingsTestServer Returns a stub embeddings endpoint whose vectors count the keywords in each input,
+// listing the embeddings in reverse order. Every input it receives is recorded.
+func EmbeddingsTestServer(received *[]string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/"+embeddings.Endpoint {
+			http.Error(w, "not found", http.StatusNotFound)
+			return
+		}
+		var body embeddings.Request
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		*received = append(*received, body.Input...)
+		data := make([]string, 0, len(body.Input))
+		for i := len(body.Input) - 1; i >= 0; i-- {
+			vector := make([]string, len(keywords()))
+			for k, keyword := range keywords() {
+				vector[k] = fmt.Sprint(strings.Count(strings.ToLower(body.Input[i]), keyword))
+			}
+			data = append(data, fmt.Sprintf(`{"index": %d, "embedding": [%s]}`, i, strings.Join(vector, ", ")))
+		}
+		fmt.Fprintf(w, `{"data": [%s]}`, strings.Join(data, ", "))
+	}))
+}
+
+var _ = Describe("Embeddings", func() {
+	var received []string
+	var client *embeddings.Client
+
+	BeforeEach(func() {
+		received = nil
+		ts := EmbeddingsTestServer(&received)
+		DeferCleanup(ts.Close)
+		client = embeddings.NewClient(embeddings.Config{URL: ts.URL})
+	})
+
+	It("returns the embeddings in the order of the inputs", func() {
+		vectors, err := client.Embed(context.Background(), []string{"a Pod", "a Service and a Pod"})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(vectors).To(Equal([][]float64{{1, 0, 0}, {1, 1, 0}}))
+		Expect(client.Model()).To(Equal(embeddings.DefaultModel))
+	})
+
+	It("ranks the vectors by their similarity to the query", func() {
+		query := []float64{1, 0, 0}
+		ranked := embeddings.Rank(query, [][]float64{{0, 1, 0}, {1, 1, 0}, {2, 0, 0}, {0, 0, 1}})
+		Expect(ranked).To(Equal([]int{2, 1, 0, 3}))
+	})
+
+	It("measures the cosine similarity", func() {
+		Expect(embeddings.CosineSimilarity([]float64{1, 0}, []float64{2, 0})).To(BeNumerically("~", 1))
+		Expect(embeddings.CosineSimilarity([]float64{1, 0}, []float64{0, 1})).To(BeNumerically("~", 0))
+		Expect(embeddings.CosineSimilarity([]float64{1, 0}, []float64{-1, 0})).To(BeNumerically("~", -1))
+		Expect(embeddings.CosineSimilarity([]float64{1, 0}, []float64{0, 0})).To(BeZero())
+		Expect(embeddings.CosineSimilarity([]float64{1, 0}, []float64{1})).To(BeZero())
+	})
+
+	It("only embeds the texts which aren't cached", func() {
+		cache := &embeddings.Cache{Dir: GinkgoT().TempDir()}
+		first, err := embeddings.EmbedCached(context.Background(), client, cache, []string{"a Pod", "a Service"})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(received).To(Equal([]string{"a Pod", "a Service"}))
+
+		second, err := embeddings.EmbedCached(context.Background(), client, cache, []string{"a Service", "a ConfigMap", "a Pod"})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(received).To(Equal([]string{"a Pod", "a Service", "a ConfigMap"}))
+		Expect(second).To(Equal([][]float64{first[1], {0, 0, 1}, first[0]}))
+	})
+
+	It("fails when the endpoint does", func() {
+		client = embeddings.NewClient(embeddings.Config{URL: "http://127.0.0.1:1"})
+		_, err := client.Embed(context.Background(), []string{"a Pod"})
+		Expect(err).To(MatchError(ContainSubstring("could not request embeddings")))
+	})
+})
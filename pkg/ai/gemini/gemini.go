@@ -0,0 +1,250 @@
+// gemini Implements a client for Google's Gemini models, served either by the
+// Generative Language API with an API key or by Vertex AI within a GCP project.
+package gemini
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/redhat-et/copilot-ops/pkg/ai"
+	"github.com/redhat-et/copilot-ops/pkg/utils"
+)
+
+// Define the constants used by the Gemini APIs here.
+const (
+	// APIURL Is the Generative Language API, which is authenticated with an API key.
+	APIURL = "https://generativelanguage.googleapis.com"
+	// APIVersion Is the version of the Generative Language API which requests are sent to.
+	APIVersion = "v1beta"
+	// VertexAPIVersion Is the version of the Vertex AI API which requests are sent to.
+	VertexAPIVersion = "v1"
+	// GenerateContentMethod Is the method of a model which generates content.
+	GenerateContentMethod = "generateContent"
+	// DefaultModel Is the model used when none is configured.
+	DefaultModel = "gemini-2.5-flash"
+	// DefaultLocation Is the Vertex AI region used when none is configured.
+	DefaultLocation = "us-central1"
+	// RoleUser Is the role of the content sent by the user.
+	RoleUser = "user"
+)
+
+// Config Describes the structure needed for configuring a Gemini client.
+// Requests are sent to Vertex AI when a project is set, and to the Generative Language API otherwise.
+type Config struct {
+	// APIKey Is the key of the Generative Language API.
+	APIKey string `json:"apiKey,omitempty" yaml:"apiKey,omitempty"`
+	// Model Is the name of the Gemini model to use.
+	Model string `json:"model" yaml:"model"`
+	// URL Overrides the URL of the API, which depends on whether Vertex AI is used.
+	URL string `json:"url,omitempty" yaml:"url,omitempty"`
+	// Project Is the GCP project whose Vertex AI endpoint is used.
+	Project string `json:"project,omitempty" yaml:"project,omitempty"`
+	// Location Is the Vertex AI region, defaulting to DefaultLocation.
+	Location string `json:"location,omitempty" yaml:"location,omitempty"`
+	// AccessToken Is the OAuth access token sent to Vertex AI as a bearer token,
+	// as printed by `gcloud auth print-access-token`.
+	AccessToken string `json:"accessToken,omitempty" yaml:"accessToken,omitempty"`
+	// HTTPClient Is used to make requests to the API, defaulting to http.DefaultClient.
+	HTTPClient *http.Client `json:"-" yaml:"-"`
+	// SystemPrompt Is sent as the system instruction of every request, if set.
+	SystemPrompt string `json:"-" yaml:"-"`
+}
+
+// Vertex Reports whether requests are sent to Vertex AI rather than the Generative Language API.
+func (conf Config) Vertex() bool {
+	return conf.Project != ""
+}
+
+// GenerateURL Returns the URL which the configured model generates content at.
+func (conf Config) GenerateURL() string {
+	model := conf.Model
+	if model == "" {
+		model = DefaultModel
+	}
+	if !conf.Vertex() {
+		baseURL := conf.URL
+		if baseURL == "" {
+			baseURL = APIURL
+		}
+		return fmt.Sprintf("%s/%s/models/%s:%s",
+			strings.TrimSuffix(baseURL, "/"), APIVersion, model, GenerateContentMethod)
+	}
+	location := conf.Location
+	if location == "" {
+		location = DefaultLocation
+	}
+	baseURL := conf.URL
+	if baseURL == "" {
+		baseURL = "https://" + location + "-aiplatform.googleapis.com"
+	}
+	return fmt.Sprintf("%s/%s/projects/%s/locations/%s/publishers/google/models/%s:%s",
+		strings.TrimSuffix(baseURL, "/"), VertexAPIVersion, conf.Project, location, model, GenerateContentMethod)
+}
+
+// Part Is a piece of content, only text is ever sent.
+type Part struct {
+	Text string `json:"text"`
+}
+
+// Content Is a turn of the conversation with the model.
+type Content struct {
+	Role  string `json:"role,omitempty"`
+	Parts []Part `json:"parts"`
+}
+
+// GenerationConfig Defines the sampling parameters of a request.
+type GenerationConfig struct {
+	MaxOutputTokens int      `json:"maxOutputTokens"`
+	Temperature     float32  `json:"temperature"`
+	TopP            *float32 `json:"topP,omitempty"`
+}
+
+// GenerateContentRequest Defines the body of a generateContent request.
+type GenerateContentRequest struct {
+	SystemInstruction *Content         `json:"systemInstruction,omitempty"`
+	Contents          []Content        `json:"contents"`
+	GenerationConfig  GenerationConfig `json:"generationConfig"`
+}
+
+// NewGenerateContentRequest Returns a request which sends the prompt as a single part of user content.
+func NewGenerateContentRequest(
+	prompt string,
+	maxTokens int,
+	temperature float32,
+	topP *float32,
+) GenerateContentRequest {
+	return GenerateContentRequest{
+		Contents: []Content{
+			{Role: RoleUser, Parts: []Part{{Text: prompt}}},
+		},
+		GenerationConfig: GenerationConfig{
+			MaxOutputTokens: maxTokens,
+			Temperature:     temperature,
+			TopP:            topP,
+		},
+	}
+}
+
+// Candidate Is a single response generated by the model.
+type Candidate struct {
+	Content      Content `json:"content"`
+	FinishReason string  `json:"finishReason,omitempty"`
+}
+
+// GenerateContentResponse Represents the body returned by the generateContent method.
+type GenerateContentResponse struct {
+	Candidates     []Candidate `json:"candidates"`
+	PromptFeedback struct {
+		// BlockReason Explains why the prompt was blocked, in which case there are no candidates.
+		BlockReason string `json:"blockReason,omitempty"`
+	} `json:"promptFeedback"`
+	// Error Explains why the request failed.
+	Error *struct {
+		Message string `json:"message"`
+	} `json:"error,omitempty"`
+}
+
+// CandidateTexts Returns the text of every candidate in the response, joining the parts of each.
+// Candidates without any text are skipped, and it fails when none is left.
+func CandidateTexts(response GenerateContentResponse) ([]string, error) {
+	var texts []string
+	finishReason := ""
+	for _, candidate := range response.Candidates {
+		var text strings.Builder
+		for _, part := range candidate.Content.Parts {
+			text.WriteString(part.Text)
+		}
+		if text.Len() == 0 {
+			finishReason = candidate.FinishReason
+			continue
+		}
+		texts = append(texts, text.String())
+	}
+	switch {
+	case len(texts) > 0:
+		return texts, nil
+	case response.PromptFeedback.BlockReason != "":
+		return nil, fmt.Errorf("gemini blocked the prompt, reason: %s", response.PromptFeedback.BlockReason)
+	case finishReason != "":
+		return nil, fmt.Errorf("gemini returned no text, finish reason: %s", finishReason)
+	default:
+		return nil, fmt.Errorf("gemini returned no candidates")
+	}
+}
+
+// geminiClient Is a client implementation of Gemini meant to implement
+// the AI Client interface.
+type geminiClient struct {
+	conf       Config
+	httpClient *http.Client
+	params     GenerateContentRequest
+}
+
+// Generate Returns the completions created by Gemini for the given prompt.
+func (c geminiClient) Generate(ctx context.Context) ([]string, error) {
+	reqBytes, err := json.Marshal(c.params)
+	if err != nil {
+		return nil, fmt.Errorf("could not send request: %w", err)
+	}
+
+	// create request
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.conf.GenerateURL(), bytes.NewBuffer(reqBytes))
+	if err != nil {
+		return nil, fmt.Errorf("could not create request: %w", err)
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("Content-Type", "application/json")
+	if c.conf.Vertex() {
+		req.Header.Set("Authorization", "Bearer "+c.conf.AccessToken)
+	} else {
+		req.Header.Set("x-goog-api-key", c.conf.APIKey)
+	}
+
+	res, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error making request: %w", err)
+	}
+	defer res.Body.Close()
+
+	// wrap the HTTP error
+	var response GenerateContentResponse
+	decodeErr := json.NewDecoder(res.Body).Decode(&response)
+	if res.StatusCode < http.StatusOK || res.StatusCode >= http.StatusBadRequest {
+		if decodeErr == nil && response.Error != nil && response.Error.Message != "" {
+			return nil, utils.NewStatusError(res, response.Error.Message)
+		}
+		return nil, utils.NewStatusError(res, "")
+	}
+	if decodeErr != nil {
+		return nil, fmt.Errorf("could not read response: %w", decodeErr)
+	}
+
+	return CandidateTexts(response)
+}
+
+// CreateGeminiGenerateClient Returns a Gemini client capable of making code generations.
+// Gemini returns a single candidate per request, so a request is made for every completion.
+func CreateGeminiGenerateClient(
+	conf Config,
+	prompt string,
+	maxTokens int,
+	temperature float32,
+	topP *float32,
+) ai.GenerateClient {
+	c := geminiClient{
+		conf:       conf,
+		httpClient: http.DefaultClient,
+		params:     NewGenerateContentRequest(prompt, maxTokens, temperature, topP),
+	}
+	if conf.SystemPrompt != "" {
+		c.params.SystemInstruction = &Content{Parts: []Part{{Text: conf.SystemPrompt}}}
+	}
+	if conf.HTTPClient != nil {
+		c.httpClient = conf.HTTPClient
+	}
+	return c
+}
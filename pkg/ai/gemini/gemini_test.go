@@ -0,0 +1,127 @@
+package gemini_test
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/redhat-et/copilot-ops/pkg/ai"
+	"github.com/redhat-et/copilot-ops/pkg/ai/gemini"
+)
+
+// roundTripFunc Stubs the HTTP transport with a function.
+type roundTripFunc func(req *http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+var _ = Describe("Gemini Generate Client", func() {
+	var requests []*http.Request
+	var bodies []gemini.GenerateContentRequest
+	var status int
+	var response string
+	var conf gemini.Config
+
+	BeforeEach(func() {
+		requests, bodies = nil, nil
+		status = http.StatusOK
+		response = `{"candidates": [{"content": {"role": "model", "parts": [{"text": "kind: "}, {"text": "Pod"}]},` +
+			` "finishReason": "STOP"}], "usageMetadata": {"promptTokenCount": 10, "candidatesTokenCount": 3}}`
+		conf = gemini.Config{
+			APIKey: "abc",
+			HTTPClient: &http.Client{
+				Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+					var body gemini.GenerateContentRequest
+					Expect(json.NewDecoder(req.Body).Decode(&body)).To(Succeed())
+					requests = append(requests, req)
+					bodies = append(bodies, body)
+					return &http.Response{
+						StatusCode: status,
+						Header:     http.Header{"Content-Type": []string{"application/json"}},
+						Body:       io.NopCloser(strings.NewReader(response)),
+					}, nil
+				}),
+			},
+		}
+	})
+
+	It("sends the prompt as a single part of user content", func() {
+		topP := float32(0.75)
+		client := gemini.CreateGeminiGenerateClient(conf, "hello world", 256, 0.5, &topP)
+		choices, err := client.Generate(context.Background())
+		Expect(err).NotTo(HaveOccurred())
+		Expect(choices).To(Equal([]string{"kind: Pod"}))
+		Expect(requests).To(HaveLen(1))
+		Expect(requests[0].URL.String()).To(Equal(
+			"https://generativelanguage.googleapis.com/v1beta/models/" + gemini.DefaultModel + ":generateContent",
+		))
+		Expect(requests[0].Header.Get("x-goog-api-key")).To(Equal("abc"))
+		Expect(requests[0].Header.Get("Authorization")).To(BeEmpty())
+		Expect(bodies[0]).To(Equal(gemini.GenerateContentRequest{
+			Contents: []gemini.Content{
+				{Role: gemini.RoleUser, Parts: []gemini.Part{{Text: "hello world"}}},
+			},
+			GenerationConfig: gemini.GenerationConfig{MaxOutputTokens: 256, Temperature: 0.5, TopP: &topP},
+		}))
+	})
+
+	It("sends the request to Vertex AI when a project is set", func() {
+		conf.Project = "my-project"
+		conf.Location = "europe-west4"
+		conf.AccessToken = "ya29.token"
+		conf.Model = "gemini-2.5-pro"
+		client := gemini.CreateGeminiGenerateClient(conf, "hello world", 256, 0, nil)
+		_, err := client.Generate(context.Background())
+		Expect(err).NotTo(HaveOccurred())
+		Expect(requests[0].URL.String()).To(Equal("https://europe-west4-aiplatform.googleapis.com/v1/projects/" +
+			"my-project/locations/europe-west4/publishers/google/models/gemini-2.5-pro:generateContent"))
+		Expect(requests[0].Header.Get("Authorization")).To(Equal("Bearer ya29.token"))
+		Expect(requests[0].Header.Get("x-goog-api-key")).To(BeEmpty())
+		Expect(bodies[0].GenerationConfig.TopP).To(BeNil())
+	})
+
+	It("fails with the message of the API's error", func() {
+		status = http.StatusTooManyRequests
+		response = `{"error": {"code": 429, "message": "Resource has been exhausted", "status": "RESOURCE_EXHAUSTED"}}`
+		client := gemini.CreateGeminiGenerateClient(conf, "hello world", 256, 0, nil)
+		choices, err := client.Generate(context.Background())
+		Expect(err).To(MatchError("error, status code: 429, message: Resource has been exhausted"))
+		Expect(ai.IsRetryable(err)).To(BeTrue())
+		Expect(choices).To(BeEmpty())
+	})
+})
+
+var _ = Describe("Candidate extraction", func() {
+	// decode Parses a response of the generateContent method.
+	decode := func(body string) gemini.GenerateContentResponse {
+		var response gemini.GenerateContentResponse
+		Expect(json.Unmarshal([]byte(body), &response)).To(Succeed())
+		return response
+	}
+
+	It("returns the text of every candidate", func() {
+		texts, err := gemini.CandidateTexts(decode(`{"candidates": [` +
+			`{"content": {"parts": [{"text": "kind: Pod"}]}},` +
+			`{"content": {"parts": []}, "finishReason": "SAFETY"},` +
+			`{"content": {"parts": [{"text": "kind: "}, {"text": "Service"}]}}]}`))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(texts).To(Equal([]string{"kind: Pod", "kind: Service"}))
+	})
+
+	It("explains why no text was returned", func() {
+		_, err := gemini.CandidateTexts(decode(`{"promptFeedback": {"blockReason": "SAFETY"}}`))
+		Expect(err).To(MatchError("gemini blocked the prompt, reason: SAFETY"))
+
+		_, err = gemini.CandidateTexts(decode(`{"candidates": [{"content": {}, "finishReason": "MAX_TOKENS"}]}`))
+		Expect(err).To(MatchError("gemini returned no text, finish reason: MAX_TOKENS"))
+
+		_, err = gemini.CandidateTexts(decode(`{}`))
+		Expect(err).To(MatchError("gemini returned no candidates"))
+	})
+})
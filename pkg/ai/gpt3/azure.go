@@ -0,0 +1,109 @@
+package gpt3
+
+import (
+	"net/http"
+	"strings"
+)
+
+const (
+	// AzureDeploymentsPath Is the path under an Azure OpenAI endpoint where deployments are served.
+	AzureDeploymentsPath = "/openai/deployments/"
+	// DefaultAzureAPIVersion Is the version of the Azure OpenAI API used when none is configured.
+	DefaultAzureAPIVersion = "2022-12-01"
+	// AzureAPIKeyHeader Is the header Azure OpenAI reads the API key from.
+	AzureAPIKeyHeader = "api-key"
+	// AzureAPIVersionParam Is the query parameter which selects the Azure OpenAI API version.
+	AzureAPIVersionParam = "api-version"
+)
+
+// IsAzure Reports whether requests are routed to an Azure OpenAI deployment
+// rather than the public OpenAI API.
+func (conf Config) IsAzure() bool {
+	return conf.AzureEndpoint != ""
+}
+
+// URL Returns the base URL which endpoints such as 'completions' are appended to.
+// For Azure, this is the URL of the configured deployment.
+func (conf Config) URL() string {
+	if !conf.IsAzure() {
+		return conf.BaseURL
+	}
+	return strings.TrimSuffix(conf.AzureEndpoint, "/") + AzureDeploymentsPath + conf.Deployment
+}
+
+// Client Returns the HTTP client used to make requests. Requests to OpenAI are sent with the
+// organization and project headers when they're configured. For Azure, requests are sent
+// with the API key in the 'api-key' header and the API version as a query parameter.
+func (conf Config) Client() *http.Client {
+	client := conf.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+	if !conf.IsAzure() {
+		if conf.OrgID == nil && conf.Project == "" {
+			return client
+		}
+		base := client.Transport
+		if base == nil {
+			base = http.DefaultTransport
+		}
+		billedClient := *client
+		billedClient.Transport = billingTransport{organization: conf.OrgID, project: conf.Project, base: base}
+		return &billedClient
+	}
+
+	apiVersion := conf.APIVersion
+	if apiVersion == "" {
+		apiVersion = DefaultAzureAPIVersion
+	}
+	base := client.Transport
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	azureClient := *client
+	azureClient.Transport = azureTransport{
+		apiKey:     conf.APIKey,
+		apiVersion: apiVersion,
+		base:       base,
+	}
+	return &azureClient
+}
+
+// azureTransport Adapts requests meant for the OpenAI API to Azure OpenAI.
+type azureTransport struct {
+	apiKey     string
+	apiVersion string
+	base       http.RoundTripper
+}
+
+// RoundTrip Replaces the OpenAI authentication with Azure's, and sets the API version.
+func (t azureTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	query := req.URL.Query()
+	query.Set(AzureAPIVersionParam, t.apiVersion)
+	req.URL.RawQuery = query.Encode()
+	req.Header.Del("Authorization")
+	req.Header.Del(OrganizationHeader)
+	req.Header.Del(ProjectHeader)
+	req.Header.Set(AzureAPIKeyHeader, t.apiKey)
+	return t.base.RoundTrip(req)
+}
+
+// billingTransport Sets the headers which OpenAI bills requests to an organization and a project with.
+type billingTransport struct {
+	organization *string
+	project      string
+	base         http.RoundTripper
+}
+
+// RoundTrip Sets the organization and project headers which are configured.
+func (t billingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	if t.organization != nil {
+		req.Header.Set(OrganizationHeader, *t.organization)
+	}
+	if t.project != "" {
+		req.Header.Set(ProjectHeader, t.project)
+	}
+	return t.base.RoundTrip(req)
+}
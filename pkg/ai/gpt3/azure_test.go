@@ -0,0 +1,98 @@
+package gpt3_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/redhat-et/copilot-ops/pkg/ai/gpt3"
+)
+
+var _ = Describe("Gpt3 endpoints", func() {
+	var ts *httptest.Server
+	var received *http.Request
+
+	BeforeEach(func() {
+		received = nil
+		ts = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			received = r
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`{"choices": [{"text": "kind: Pod", "index": 0}]}`))
+		}))
+		DeferCleanup(ts.Close)
+	})
+
+	It("uses the public OpenAI API by default", func() {
+		conf := gpt3.Config{APIKey: "abc", BaseURL: ts.URL + gpt3.OpenAIEndpointV1}
+		Expect(conf.IsAzure()).To(BeFalse())
+		Expect(conf.URL()).To(Equal(ts.URL + "/v1"))
+
+		choices, err := gpt3.CreateGPT3GenerateClient(conf, "hello world", 256, 1, 0, nil).Generate(context.Background())
+		Expect(err).NotTo(HaveOccurred())
+		Expect(choices).To(Equal([]string{"kind: Pod"}))
+		Expect(received.URL.Path).To(Equal("/v1/" + gpt3.CompletionEndpoint))
+		Expect(received.URL.RawQuery).To(BeEmpty())
+		Expect(received.Header.Get("Authorization")).To(Equal("Bearer abc"))
+	})
+
+	It("routes requests to an Azure deployment", func() {
+		conf := gpt3.Config{
+			APIKey:        "abc",
+			BaseURL:       gpt3.OpenAIURL + gpt3.OpenAIEndpointV1,
+			AzureEndpoint: ts.URL + "/",
+			Deployment:    "codex",
+		}
+		Expect(conf.IsAzure()).To(BeTrue())
+		Expect(conf.URL()).To(Equal(ts.URL + "/openai/deployments/codex"))
+
+		choices, err := gpt3.CreateGPT3GenerateClient(conf, "hello world", 256, 1, 0, nil).Generate(context.Background())
+		Expect(err).NotTo(HaveOccurred())
+		Expect(choices).To(Equal([]string{"kind: Pod"}))
+		Expect(received.URL.Path).To(Equal("/openai/deployments/codex/" + gpt3.CompletionEndpoint))
+		Expect(received.URL.Query().Get(gpt3.AzureAPIVersionParam)).To(Equal(gpt3.DefaultAzureAPIVersion))
+		Expect(received.Header.Get(gpt3.AzureAPIKeyHeader)).To(Equal("abc"))
+		Expect(received.Header.Get("Authorization")).To(BeEmpty())
+	})
+
+	It("uses the configured Azure API version", func() {
+		conf := gpt3.Config{APIKey: "abc", AzureEndpoint: ts.URL, Deployment: "codex", APIVersion: "2023-05-15"}
+		_, err := gpt3.CreateGPT3GenerateClient(conf, "hello world", 256, 1, 0, nil).Generate(context.Background())
+		Expect(err).NotTo(HaveOccurred())
+		Expect(received.URL.Query().Get(gpt3.AzureAPIVersionParam)).To(Equal("2023-05-15"))
+	})
+
+	It("bills the requests to the configured organization and project", func() {
+		org := "org-billing"
+		conf := gpt3.Config{APIKey: "abc", BaseURL: ts.URL + gpt3.OpenAIEndpointV1, OrgID: &org, Project: "proj-web"}
+		_, err := gpt3.CreateGPT3GenerateClient(conf, "hello world", 256, 1, 0, nil).Generate(context.Background())
+		Expect(err).NotTo(HaveOccurred())
+		Expect(received.Header.Get(gpt3.OrganizationHeader)).To(Equal("org-billing"))
+		Expect(received.Header.Get(gpt3.ProjectHeader)).To(Equal("proj-web"))
+
+		_, err = gpt3.ListModels(context.Background(), conf)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(received.URL.Path).To(Equal("/v1/models"))
+		Expect(received.Header.Get(gpt3.OrganizationHeader)).To(Equal("org-billing"))
+		Expect(received.Header.Get(gpt3.ProjectHeader)).To(Equal("proj-web"))
+	})
+
+	It("sends no billing headers when they aren't configured", func() {
+		conf := gpt3.Config{APIKey: "abc", BaseURL: ts.URL + gpt3.OpenAIEndpointV1}
+		_, err := gpt3.CreateGPT3GenerateClient(conf, "hello world", 256, 1, 0, nil).Generate(context.Background())
+		Expect(err).NotTo(HaveOccurred())
+		Expect(received.Header).NotTo(HaveKey(gpt3.OrganizationHeader))
+		Expect(received.Header).NotTo(HaveKey(gpt3.ProjectHeader))
+	})
+
+	It("doesn't send the billing headers to Azure", func() {
+		org := "org-billing"
+		conf := gpt3.Config{APIKey: "abc", AzureEndpoint: ts.URL, Deployment: "codex", OrgID: &org, Project: "proj-web"}
+		_, err := gpt3.CreateGPT3GenerateClient(conf, "hello world", 256, 1, 0, nil).Generate(context.Background())
+		Expect(err).NotTo(HaveOccurred())
+		Expect(received.Header).NotTo(HaveKey(gpt3.OrganizationHeader))
+		Expect(received.Header).NotTo(HaveKey(gpt3.ProjectHeader))
+	})
+})
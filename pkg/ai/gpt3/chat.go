@@ -0,0 +1,148 @@
+package gpt3
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/redhat-et/copilot-ops/pkg/ai"
+	"github.com/redhat-et/copilot-ops/pkg/utils"
+)
+
+const (
+	// ChatCompletionEndpoint Is the endpoint of the chat models, relative to the base URL.
+	ChatCompletionEndpoint = "chat/completions"
+	// RoleSystem Is the role of the message which instructs the chat model.
+	RoleSystem = "system"
+	// RoleUser Is the role of messages written by the user.
+	RoleUser = "user"
+	// ChatSystemPrompt Tells a chat model to continue the prompt like a completion model would,
+	// so that its answer can be decoded the same way.
+	ChatSystemPrompt = chatSystemPromptStart + CompletionEndOfSequence + chatSystemPromptEnd
+
+	chatSystemPromptStart = "You complete documents. Reply with only the text which continues the user's document " +
+		"exactly where it ends, following its format, and write '"
+	chatSystemPromptEnd = "' on its own line once the document is complete."
+)
+
+// ChatSystemPromptFor Returns ChatSystemPrompt, asking for the given end-of-sequence terminator instead.
+func ChatSystemPromptFor(endOfSequence string) string {
+	return chatSystemPromptStart + endOfSequence + chatSystemPromptEnd
+}
+
+// IsChatModel Reports whether the model is only served by the chat completions endpoint.
+func IsChatModel(model string) bool {
+	return strings.HasPrefix(model, "gpt-3.5") || strings.HasPrefix(model, "gpt-4") || IsReasoningModel(model)
+}
+
+// IsReasoningModel Reports whether the model reasons before it answers, such as o1 and o3-mini.
+// Reasoning models are limited by max_completion_tokens, which covers both the reasoning and the answer,
+// and only sample with the default temperature.
+func IsReasoningModel(model string) bool {
+	for _, prefix := range []string{"o1", "o3", "o4", "gpt-5"} {
+		if model == prefix || strings.HasPrefix(model, prefix+"-") {
+			return true
+		}
+	}
+	return false
+}
+
+// ChatMessage Is a single message of the conversation sent to a chat model.
+type ChatMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+	// ToolCalls Are the functions which the model called in its answer, if any.
+	ToolCalls []ToolCall `json:"tool_calls,omitempty"`
+}
+
+// ChatCompletionRequest Defines the parameters which are sent when requesting chat completions.
+type ChatCompletionRequest struct {
+	Model       string        `json:"model"`
+	Messages    []ChatMessage `json:"messages"`
+	MaxTokens   int           `json:"max_tokens,omitempty"`
+	N           int           `json:"n,omitempty"`
+	Temperature float32       `json:"temperature"`
+	TopP        *float32      `json:"top_p,omitempty"`
+	Stop        []string      `json:"stop,omitempty"`
+	Seed        *int          `json:"seed,omitempty"`
+	Tools       []Tool        `json:"tools,omitempty"`
+	ToolChoice  *ToolChoice   `json:"tool_choice,omitempty"`
+	// MaxCompletionTokens Replaces MaxTokens for reasoning models, including the tokens spent reasoning.
+	MaxCompletionTokens int `json:"max_completion_tokens,omitempty"`
+}
+
+// chatCompletionResponse Represents the body returned by the chat completions endpoint.
+type chatCompletionResponse struct {
+	Choices []struct {
+		Index        int         `json:"index"`
+		Message      ChatMessage `json:"message"`
+		FinishReason string      `json:"finish_reason"`
+	} `json:"choices"`
+	Usage ai.Usage `json:"usage"`
+}
+
+// chatClient Requests completions from OpenAI's chat models.
+type chatClient struct {
+	conf   Config
+	params ChatCompletionRequest
+	// usage Records the tokens used by the last generation.
+	usage *ai.Usage
+}
+
+// ChatMessages Turns the prompt into the messages sent to a chat model: a system message
+// describing how to answer, followed by the prompt as the user's message.
+// The system message defaults to ChatSystemPrompt.
+func ChatMessages(systemPrompt, prompt string) []ChatMessage {
+	if systemPrompt == "" {
+		systemPrompt = ChatSystemPrompt
+	}
+	return []ChatMessage{
+		{Role: RoleSystem, Content: systemPrompt},
+		{Role: RoleUser, Content: prompt},
+	}
+}
+
+// Generate Reaches out to the OpenAI Chat Completions API and returns
+// the content of every message generated for the prompt.
+func (c chatClient) Generate(ctx context.Context) ([]string, error) {
+	reqBytes, err := json.Marshal(c.params)
+	if err != nil {
+		return nil, fmt.Errorf("could not send request: %w", err)
+	}
+
+	// create request
+	urlPath := c.conf.URL() + "/" + ChatCompletionEndpoint
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, urlPath, bytes.NewBuffer(reqBytes))
+	if err != nil {
+		return nil, fmt.Errorf("could not create request: %w", err)
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+c.conf.APIKey)
+
+	var response chatCompletionResponse
+	if err = utils.JSONRequest(req, c.conf.Client(), &response); err != nil {
+		return nil, fmt.Errorf("could not request openai: %w", err)
+	}
+	if c.usage != nil {
+		*c.usage = response.Usage
+	}
+	responses := make([]string, len(response.Choices))
+	for i, choice := range response.Choices {
+		// the arguments of the files written by the model are decoded as they are
+		if args, ok := writeFilesCall(choice.Message.ToolCalls); ok {
+			responses[i] = args
+			continue
+		}
+		responses[i] = restoreEndOfSequence(choice.Message.Content, choice.FinishReason, c.conf.Terminator())
+	}
+	return responses, nil
+}
+
+// Usage Returns the tokens used by the last generation, as reported by OpenAI.
+func (c chatClient) Usage() (ai.Usage, bool) {
+	return reportedUsage(c.usage)
+}
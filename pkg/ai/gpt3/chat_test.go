@@ -0,0 +1,179 @@
+package gpt3_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/redhat-et/copilot-ops/pkg/ai"
+	"github.com/redhat-et/copilot-ops/pkg/ai/gpt3"
+)
+
+var _ = Describe("Gpt3 Chat Generate Client", func() {
+	var ts *httptest.Server
+	var paths []string
+	var chatRequest gpt3.ChatCompletionRequest
+
+	BeforeEach(func() {
+		paths = nil
+		chatRequest = gpt3.ChatCompletionRequest{}
+		ts = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			paths = append(paths, r.URL.Path)
+			w.Header().Set("Content-Type", "application/json")
+			switch r.URL.Path {
+			case "/" + gpt3.ChatCompletionEndpoint:
+				if err := json.NewDecoder(r.Body).Decode(&chatRequest); err != nil {
+					http.Error(w, err.Error(), http.StatusBadRequest)
+					return
+				}
+				_, _ = w.Write([]byte(`{"choices": [` +
+					`{"index": 0, "message": {"role": "assistant", "content": "kind: Pod"}, "finish_reason": "stop"},` +
+					`{"index": 1, "message": {"role": "assistant", "content": "kind: Service"}, "finish_reason": "length"}],` +
+					`"usage": {"prompt_tokens": 12, "completion_tokens": 8, "total_tokens": 20}}`))
+			case "/" + gpt3.CompletionEndpoint:
+				_, _ = w.Write([]byte(`{"choices": [{"text": "kind: Deployment", "index": 0}],` +
+					`"usage": {"prompt_tokens": 3, "completion_tokens": 4, "total_tokens": 7}}`))
+			default:
+				http.Error(w, "not found", http.StatusNotFound)
+			}
+		}))
+		DeferCleanup(ts.Close)
+	})
+
+	It("recognizes the chat models", func() {
+		Expect(gpt3.IsChatModel("gpt-3.5-turbo")).To(BeTrue())
+		Expect(gpt3.IsChatModel("gpt-4")).To(BeTrue())
+		Expect(gpt3.IsChatModel("gpt-4o-mini")).To(BeTrue())
+		Expect(gpt3.IsChatModel(gpt3.OpenAICodeDavinciV2)).To(BeFalse())
+		Expect(gpt3.Config{}.ModelName()).To(Equal(gpt3.OpenAICodeDavinciV2))
+	})
+
+	It("sends the prompt as a system and user message", func() {
+		messages := gpt3.ChatMessages("", "hello world")
+		Expect(messages).To(HaveLen(2))
+		Expect(messages[0].Role).To(Equal(gpt3.RoleSystem))
+		Expect(messages[0].Content).To(ContainSubstring(gpt3.CompletionEndOfSequence))
+		Expect(messages[1]).To(Equal(gpt3.ChatMessage{Role: gpt3.RoleUser, Content: "hello world"}))
+	})
+
+	It("uses the chat completions endpoint for chat models", func() {
+		client := gpt3.CreateGPT3GenerateClient(
+			gpt3.Config{APIKey: "abc", BaseURL: ts.URL, Model: "gpt-4"},
+			"hello world",
+			256,
+			2,
+			0,
+			nil,
+		)
+		choices, err := client.Generate(context.Background())
+		Expect(err).NotTo(HaveOccurred())
+		Expect(paths).To(Equal([]string{"/" + gpt3.ChatCompletionEndpoint}))
+		// only the completion which wasn't cut off by the token limit ends with the stop sequence
+		Expect(choices).To(Equal([]string{"kind: Pod\n" + gpt3.CompletionEndOfSequence, "kind: Service"}))
+
+		Expect(chatRequest.Model).To(Equal("gpt-4"))
+		Expect(chatRequest.Messages).To(Equal(gpt3.ChatMessages("", "hello world")))
+		Expect(chatRequest.MaxTokens).To(Equal(256))
+		Expect(chatRequest.N).To(Equal(2))
+		Expect(chatRequest.Stop).To(Equal([]string{gpt3.CompletionEndOfSequence}))
+
+		usage, ok := client.(ai.UsageReportingClient).Usage()
+		Expect(ok).To(BeTrue())
+		Expect(usage).To(Equal(ai.Usage{PromptTokens: 12, CompletionTokens: 8}))
+	})
+
+	It("stops at a custom end-of-sequence terminator", func() {
+		client := gpt3.CreateGPT3GenerateClient(
+			gpt3.Config{APIKey: "abc", BaseURL: ts.URL, Model: "gpt-4", EndOfSequence: "<<END>>"},
+			"hello world",
+			256,
+			2,
+			0,
+			nil,
+		)
+		choices, err := client.Generate(context.Background())
+		Expect(err).NotTo(HaveOccurred())
+		Expect(choices).To(Equal([]string{"kind: Pod\n<<END>>", "kind: Service"}))
+		Expect(chatRequest.Stop).To(Equal([]string{"<<END>>"}))
+		Expect(chatRequest.Messages[0].Content).To(Equal(gpt3.ChatSystemPromptFor("<<END>>")))
+		Expect(chatRequest.Messages[0].Content).To(ContainSubstring("write '<<END>>' on its own line"))
+	})
+
+	It("returns the arguments of the files written with a function call", func() {
+		var toolRequest map[string]interface{}
+		toolServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			Expect(json.NewDecoder(r.Body).Decode(&toolRequest)).To(Succeed())
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`{"choices": [` +
+				`{"index": 0, "message": {"role": "assistant", "content": null, "tool_calls": [` +
+				`{"id": "call_1", "type": "function", "function": {"name": "write_files", ` +
+				`"arguments": "{\"files\": [{\"path\": \"pod.yaml\", \"content\": \"kind: Pod\\n\"}]}"}},` +
+				`{"id": "call_2", "type": "function", "function": {"name": "write_files", ` +
+				`"arguments": "{\"files\": [{\"path\": \"svc.yaml\", \"content\": \"kind: Service\\n\"}]}"}}]}, ` +
+				`"finish_reason": "tool_calls"},` +
+				`{"index": 1, "message": {"role": "assistant", "content": "kind: Pod"}, "finish_reason": "stop"}]}`))
+		}))
+		DeferCleanup(toolServer.Close)
+
+		client := gpt3.CreateGPT3GenerateClient(
+			gpt3.Config{APIKey: "abc", BaseURL: toolServer.URL, Model: "gpt-4", Structured: true}, "hello world", 256, 2, 0, nil,
+		)
+		choices, err := client.Generate(context.Background())
+		Expect(err).NotTo(HaveOccurred())
+		Expect(choices).To(HaveLen(2))
+		args, ok := gpt3.ParseWriteFiles(choices[0])
+		Expect(ok).To(BeTrue())
+		Expect(args.Files).To(Equal([]gpt3.WrittenFile{
+			{Path: "pod.yaml", Content: "kind: Pod\n"},
+			{Path: "svc.yaml", Content: "kind: Service\n"},
+		}))
+		// the model answered with text instead
+		Expect(choices[1]).To(Equal("kind: Pod\n" + gpt3.CompletionEndOfSequence))
+		_, ok = gpt3.ParseWriteFiles(choices[1])
+		Expect(ok).To(BeFalse())
+
+		Expect(toolRequest).NotTo(HaveKey("stop"))
+		Expect(toolRequest["tools"]).To(ConsistOf(HaveKeyWithValue("function", HaveKeyWithValue("name", "write_files"))))
+		Expect(toolRequest["tool_choice"]).To(HaveKeyWithValue("function", HaveKeyWithValue("name", "write_files")))
+		Expect(toolRequest["messages"]).To(ContainElement(HaveKeyWithValue("content", gpt3.StructuredSystemPrompt)))
+	})
+
+	It("sends the seed when one is set", func() {
+		seed := 7
+		client := gpt3.CreateGPT3GenerateClient(
+			gpt3.Config{APIKey: "abc", BaseURL: ts.URL, Model: "gpt-4", Seed: &seed}, "hello world", 256, 1, 0, nil,
+		)
+		_, err := client.Generate(context.Background())
+		Expect(err).NotTo(HaveOccurred())
+		Expect(chatRequest.Seed).To(Equal(&seed))
+	})
+
+	It("uses the completions endpoint for other models", func() {
+		client := gpt3.CreateGPT3GenerateClient(
+			gpt3.Config{APIKey: "abc", BaseURL: ts.URL},
+			"hello world",
+			256,
+			1,
+			0,
+			nil,
+		)
+		choices, err := client.Generate(context.Background())
+		Expect(err).NotTo(HaveOccurred())
+		Expect(paths).To(Equal([]string{"/" + gpt3.CompletionEndpoint}))
+		Expect(choices).To(Equal([]string{"kind: Deployment"}))
+
+		usage, ok := client.(ai.UsageReportingClient).Usage()
+		Expect(ok).To(BeTrue())
+		Expect(usage).To(Equal(ai.Usage{PromptTokens: 3, CompletionTokens: 4}))
+	})
+
+	It("reports no usage before generating", func() {
+		client := gpt3.CreateGPT3GenerateClient(gpt3.Config{BaseURL: ts.URL}, "hello world", 256, 1, 0, nil)
+		_, ok := client.(ai.UsageReportingClient).Usage()
+		Expect(ok).To(BeFalse())
+	})
+})
@@ -1,10 +1,15 @@
 package gpt3
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
 	"fmt"
+	"net/http"
+	"strings"
 
 	"github.com/redhat-et/copilot-ops/pkg/ai"
+	"github.com/redhat-et/copilot-ops/pkg/utils"
 	gogpt "github.com/sashabaranov/go-gpt3"
 )
 
@@ -17,7 +22,17 @@ const (
 	OpenAIEndpointV1        string = "/v1"
 	OpenAICodeDavinciEditV1 string = "code-davinci-edit-001"
 	OpenAICodeDavinciV2     string = "code-davinci-002"
+	// CompletionEndOfSequence Is the default terminator which the model writes once the files are complete.
 	CompletionEndOfSequence string = "EOF"
+	// MaxStopSequences Is the most stop sequences OpenAI accepts in a request, including the terminator.
+	MaxStopSequences = 4
+	// OrganizationHeader Is the header which requests are billed to the organization of OrgID with.
+	OrganizationHeader string = "OpenAI-Organization"
+	// ProjectHeader Is the header which requests are billed to the project of Project with.
+	ProjectHeader string = "OpenAI-Project"
+	// FinishReasonStop Is why a completion ended when the model finished it or wrote a stop sequence,
+	// rather than running out of tokens.
+	FinishReasonStop string = "stop"
 )
 
 type GenerateParams struct {
@@ -31,9 +46,11 @@ type EditParams struct {
 // gpt3Client Is a wrapper struct around the go-gpt3
 // package.
 type gpt3Client struct {
-	client           gogpt.Client
+	conf             Config
 	editParams       *gogpt.EditsRequest
 	completionParams *gogpt.CompletionRequest
+	// usage Records the tokens used by the last generation.
+	usage *ai.Usage
 }
 
 // Config Defines the values required for connecting to the GPT-3 API.
@@ -42,40 +59,119 @@ type Config struct {
 	// APIKey Is the API token used when making requests.
 	APIKey string `json:"apiKey" yaml:"apiKey"`
 	// OrgID Is an optional value which is set by users to dictate billing information.
+	// It's sent as the OrganizationHeader.
 	OrgID *string `json:"orgID,omitempty" yaml:"orgID,omitempty"`
+	// Project Is the optional ID of the project which requests are billed to, sent as the ProjectHeader.
+	Project string `json:"project,omitempty" yaml:"project,omitempty"`
 	// BaseURL Defines where the client will reach out to contact the API.
 	BaseURL string `json:"url" yaml:"url"`
+	// HTTPClient Is used to make requests to the API, if set.
+	HTTPClient *http.Client `json:"-" yaml:"-"`
+	// SystemPrompt Replaces ChatSystemPrompt as the system message sent to chat models, if set.
+	SystemPrompt string `json:"-" yaml:"-"`
+	// EndOfSequence Is sent as the stop sequence in place of CompletionEndOfSequence, if set.
+	EndOfSequence string `json:"-" yaml:"-"`
+	// Seed Is sent to chat models so that they sample deterministically, if set.
+	Seed *int `json:"-" yaml:"-"`
+	// Stop Are sent as stop sequences along with the end-of-sequence terminator.
+	Stop []string `json:"-" yaml:"-"`
+	// ReasoningTokens Is the budget reasoning models may spend reasoning, on top of the max tokens of the answer.
+	ReasoningTokens int `json:"-" yaml:"-"`
+	// Structured Makes chat models answer by calling WriteFilesFunction, whose arguments are returned
+	// as the completion instead of text.
+	Structured bool `json:"-" yaml:"-"`
+	// AzureEndpoint Is the endpoint of an Azure OpenAI resource, e.g. https://<resource>.openai.azure.com.
+	// When set, requests are routed to the Deployment instead of the public OpenAI API.
+	AzureEndpoint string `json:"azureEndpoint,omitempty" yaml:"azureEndpoint,omitempty"`
+	// Deployment Is the name of the Azure OpenAI deployment which serves the model.
+	Deployment string `json:"deployment,omitempty" yaml:"deployment,omitempty"`
+	// APIVersion Is the Azure OpenAI API version, defaulting to DefaultAzureAPIVersion.
+	APIVersion string `json:"apiVersion,omitempty" yaml:"apiVersion,omitempty"`
+	// Model Is the name of the model which generates completions, defaulting to OpenAICodeDavinciV2.
+	// Chat models such as gpt-3.5-turbo and gpt-4 are requested through the chat completions endpoint.
+	Model string `json:"model,omitempty" yaml:"model,omitempty"`
+}
+
+// ModelName Returns the configured model, or the default model if none is configured.
+func (conf Config) ModelName() string {
+	if conf.Model == "" {
+		return OpenAICodeDavinciV2
+	}
+	return conf.Model
+}
+
+// StopSequences Returns the sequences which completions are stopped at: the end-of-sequence
+// terminator, followed by the configured stop sequences.
+func (conf Config) StopSequences() []string {
+	return append([]string{conf.Terminator()}, conf.Stop...)
+}
+
+// Terminator Returns the end-of-sequence terminator which completions are stopped at.
+func (conf Config) Terminator() string {
+	if conf.EndOfSequence == "" {
+		return CompletionEndOfSequence
+	}
+	return conf.EndOfSequence
 }
 
 // Generate Reaches out to the OpenAI GPT-3 Completions API and returns
 // a list of completions pertinent to the request.
-func (c gpt3Client) Generate() ([]string, error) {
+func (c gpt3Client) Generate(ctx context.Context) ([]string, error) {
 	if c.completionParams == nil {
 		return nil, fmt.Errorf("no completions params were provided")
 	}
 	// make request
-	resp, err := c.client.CreateCompletion(context.TODO(), *c.completionParams)
-	if err != nil {
-		return nil, err
+	var resp gogpt.CompletionResponse
+	if err := postJSON(ctx, c.conf, CompletionEndpoint, c.completionParams, &resp); err != nil {
+		return nil, fmt.Errorf("could not request openai: %w", err)
+	}
+	if c.usage != nil {
+		*c.usage = ai.Usage{PromptTokens: resp.Usage.PromptTokens, CompletionTokens: resp.Usage.CompletionTokens}
 	}
 	// collect strings from response
 	responses := make([]string, len(resp.Choices))
 	for i, choice := range resp.Choices {
-		responses[i] = choice.Text
+		responses[i] = restoreEndOfSequence(choice.Text, choice.FinishReason, c.conf.Terminator())
 	}
 	return responses, nil
 }
 
+// restoreEndOfSequence Appends the end-of-sequence terminator, which OpenAI strips from the text
+// as a stop sequence, to a completion which wasn't cut off. This way the completions of every
+// backend end with the terminator unless they were truncated.
+func restoreEndOfSequence(text, finishReason, endOfSequence string) string {
+	if finishReason != FinishReasonStop {
+		return text
+	}
+	if text != "" && !strings.HasSuffix(text, "\n") {
+		text += "\n"
+	}
+	return text + endOfSequence
+}
+
+// Usage Returns the tokens used by the last generation, as reported by OpenAI.
+func (c gpt3Client) Usage() (ai.Usage, bool) {
+	return reportedUsage(c.usage)
+}
+
+// reportedUsage Returns the recorded usage, and whether any tokens were recorded.
+func reportedUsage(usage *ai.Usage) (ai.Usage, bool) {
+	if usage == nil || (usage.PromptTokens == 0 && usage.CompletionTokens == 0) {
+		return ai.Usage{}, false
+	}
+	return *usage, true
+}
+
 // Edit Reaches out to the OpenAI GPT-3 Edits API and returns a list of
 // responses which have been edited in accordance with the given instruction.
-func (c gpt3Client) Edit() ([]string, error) {
+func (c gpt3Client) Edit(ctx context.Context) ([]string, error) {
 	// ensure params
 	if c.editParams == nil {
 		return nil, fmt.Errorf("no edit params were provided")
 	}
 	// editParams, ok := params.(EditParams)
-	resp, err := c.client.Edits(context.Background(), *c.editParams)
-	if err != nil {
+	var resp gogpt.EditsResponse
+	if err := postJSON(ctx, c.conf, EditEndpoint, c.editParams, &resp); err != nil {
 		return nil, fmt.Errorf("could not request openai: %w", err)
 	}
 	edits := make([]string, len(resp.Choices))
@@ -86,23 +182,70 @@ func (c gpt3Client) Edit() ([]string, error) {
 }
 
 // CreateGPT3GenerateClient Returns a GPT-3 client which accesses OpenAI's
-// GPT-3 endpoint to generate completions.
-func CreateGPT3GenerateClient(conf Config, prompt string, maxTokens, nCompletions int) ai.GenerateClient {
-	// create a GPT-3 Client
-	client := createGPT3Client(conf)
+// GPT-3 endpoint to generate completions. Chat models are accessed through
+// the chat completions endpoint instead.
+func CreateGPT3GenerateClient(
+	conf Config,
+	prompt string,
+	maxTokens, nCompletions int,
+	temperature float32,
+	topP *float32,
+) ai.GenerateClient {
+	model := conf.ModelName()
+	if IsChatModel(model) {
+		systemPrompt := conf.SystemPrompt
+		if systemPrompt == "" {
+			systemPrompt = ChatSystemPromptFor(conf.Terminator())
+		}
+		params := ChatCompletionRequest{
+			Model:       model,
+			Messages:    ChatMessages(systemPrompt, prompt),
+			MaxTokens:   maxTokens,
+			N:           nCompletions,
+			Temperature: temperature,
+			TopP:        topP,
+			Stop:        conf.StopSequences(),
+			Seed:        conf.Seed,
+		}
+		if IsReasoningModel(model) {
+			// reasoning models only accept the default temperature
+			params.MaxCompletionTokens = maxTokens + conf.ReasoningTokens
+			params.MaxTokens = 0
+			params.Temperature = 1
+		}
+		if conf.Structured {
+			// the files are passed to the function rather than written out, so there's nothing to stop at
+			if conf.SystemPrompt == "" {
+				params.Messages = ChatMessages(StructuredSystemPrompt, prompt)
+			}
+			params.Stop = nil
+			params.Tools = []Tool{WriteFilesTool()}
+			params.ToolChoice = WriteFilesChoice()
+		}
+		return chatClient{
+			conf:   conf,
+			params: params,
+			usage:  &ai.Usage{},
+		}
+	}
+
 	// create params for getting a completion
 	params := &gogpt.CompletionRequest{
-		Model:       OpenAICodeDavinciV2,
+		Model:       model,
 		Prompt:      prompt,
 		MaxTokens:   maxTokens,
 		N:           nCompletions,
-		Temperature: 0.0,
-		Stop:        []string{CompletionEndOfSequence},
+		Temperature: temperature,
+		Stop:        conf.StopSequences(),
+	}
+	if topP != nil {
+		params.TopP = *topP
 	}
 
 	return gpt3Client{
-		client:           *client,
+		conf:             conf,
 		completionParams: params,
+		usage:            &ai.Usage{},
 	}
 }
 
@@ -113,7 +256,6 @@ func CreateGPT3EditClient(
 	numEdits int, temperature,
 	topP *float32,
 ) ai.EditClient {
-	client := createGPT3Client(conf)
 	// set params
 	model := OpenAICodeDavinciEditV1
 	editParams := &gogpt.EditsRequest{
@@ -130,18 +272,42 @@ func CreateGPT3EditClient(
 	}
 
 	return gpt3Client{
-		client:     *client,
+		conf:       conf,
 		editParams: editParams,
 	}
 }
 
-// createGPT3Client Returns a go-gpt client using the provided config.
-func createGPT3Client(conf Config) (client *gogpt.Client) {
-	if conf.OrgID != nil {
-		client = gogpt.NewOrgClient(conf.APIKey, *conf.OrgID)
-	} else {
-		client = gogpt.NewClient(conf.APIKey)
+// postJSON Sends the body to the endpoint of the API and decodes the response into v.
+// A failed request returns a *utils.StatusError, so that its Retry-After header is honored.
+func postJSON(ctx context.Context, conf Config, endpoint string, body, v interface{}) error {
+	reqBytes, err := json.Marshal(body)
+	if err != nil {
+		return fmt.Errorf("could not send request: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, conf.URL()+"/"+endpoint, bytes.NewBuffer(reqBytes))
+	if err != nil {
+		return fmt.Errorf("could not create request: %w", err)
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+conf.APIKey)
+
+	res, err := conf.Client().Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode < http.StatusOK || res.StatusCode >= http.StatusBadRequest {
+		return statusError(res)
+	}
+	return json.NewDecoder(res.Body).Decode(v)
+}
+
+// statusError Returns the error of a failed response, along with the message OpenAI explained it with.
+func statusError(res *http.Response) error {
+	errResp := errorResponse{}
+	if err := json.NewDecoder(res.Body).Decode(&errResp); err == nil && errResp.Error != nil {
+		return utils.NewStatusError(res, errResp.Error.Message)
 	}
-	client.BaseURL = conf.BaseURL
-	return
+	return utils.NewStatusError(res, "")
 }
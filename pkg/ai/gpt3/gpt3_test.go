@@ -1,6 +1,8 @@
 package gpt3_test
 
 import (
+	"context"
+
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
 
@@ -22,11 +24,13 @@ var _ = Describe("Gpt3 Generate Client", func() {
 			"hello world",
 			256,
 			1,
+			0,
+			nil,
 		)
 	})
 
 	It("doesn't generate with an empty URL", func() {
-		responses, err := gpt3Client.Generate()
+		responses, err := gpt3Client.Generate(context.Background())
 		Expect(err).To(HaveOccurred())
 		Expect(responses).To(BeEmpty())
 	})
@@ -0,0 +1,50 @@
+package gpt3
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/redhat-et/copilot-ops/pkg/utils"
+)
+
+const (
+	// ModelsEndpoint Is the endpoint which lists the available models, relative to the base URL.
+	ModelsEndpoint = "models"
+	// AzureModelsPath Is the path under an Azure OpenAI endpoint where the available models are listed.
+	AzureModelsPath = "/openai/models"
+)
+
+// modelsResponse Represents the body returned by the models endpoint.
+type modelsResponse struct {
+	Data []struct {
+		ID string `json:"id"`
+	} `json:"data"`
+}
+
+// ListModels Returns the IDs of the models available with the configured API key, sorted by name.
+func ListModels(ctx context.Context, conf Config) ([]string, error) {
+	url := strings.TrimSuffix(conf.BaseURL, "/") + "/" + ModelsEndpoint
+	if conf.IsAzure() {
+		url = strings.TrimSuffix(conf.AzureEndpoint, "/") + AzureModelsPath
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("could not create request: %w", err)
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("Authorization", "Bearer "+conf.APIKey)
+
+	var res modelsResponse
+	if err = utils.JSONRequest(req, conf.Client(), &res); err != nil {
+		return nil, fmt.Errorf("could not list the models: %w", err)
+	}
+	models := make([]string, len(res.Data))
+	for i, model := range res.Data {
+		models[i] = model.ID
+	}
+	sort.Strings(models)
+	return models, nil
+}
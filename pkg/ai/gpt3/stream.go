@@ -0,0 +1,100 @@
+package gpt3
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	gogpt "github.com/sashabaranov/go-gpt3"
+)
+
+// streamDone Is the final server-sent event of a streamed completion.
+const streamDone = "[DONE]"
+
+// errorResponse Represents the body returned by OpenAI when a request fails.
+type errorResponse struct {
+	Error *struct {
+		Message string `json:"message"`
+		Type    string `json:"type"`
+	} `json:"error,omitempty"`
+}
+
+// GenerateStream Requests completions with streaming enabled, calling onChunk with each piece
+// of text as it arrives. Once the stream is complete, the full text of every completion is returned.
+func (c gpt3Client) GenerateStream(ctx context.Context, onChunk func(chunk string)) ([]string, error) {
+	if c.completionParams == nil {
+		return nil, fmt.Errorf("no completions params were provided")
+	}
+	params := *c.completionParams
+	params.Stream = true
+	reqBytes, err := json.Marshal(params)
+	if err != nil {
+		return nil, fmt.Errorf("could not send request: %w", err)
+	}
+
+	// create request
+	urlPath := c.conf.URL() + "/" + CompletionEndpoint
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, urlPath, bytes.NewBuffer(reqBytes))
+	if err != nil {
+		return nil, fmt.Errorf("could not create request: %w", err)
+	}
+	req.Header.Set("Accept", "text/event-stream")
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+c.conf.APIKey)
+
+	res, err := c.conf.Client().Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error making request: %w", err)
+	}
+	defer res.Body.Close()
+
+	// wrap the HTTP error
+	if res.StatusCode < http.StatusOK || res.StatusCode >= http.StatusBadRequest {
+		return nil, statusError(res)
+	}
+
+	// read server-sent events until the stream is done
+	completions := make([]strings.Builder, params.N)
+	finishReasons := make([]string, params.N)
+	scanner := bufio.NewScanner(res.Body)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if !strings.HasPrefix(line, "data:") {
+			continue
+		}
+		data := strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+		if data == streamDone {
+			break
+		}
+		var event gogpt.CompletionResponse
+		if err = json.Unmarshal([]byte(data), &event); err != nil {
+			return nil, fmt.Errorf("could not read stream: %w", err)
+		}
+		for _, choice := range event.Choices {
+			for choice.Index >= len(completions) {
+				completions = append(completions, strings.Builder{})
+				finishReasons = append(finishReasons, "")
+			}
+			completions[choice.Index].WriteString(choice.Text)
+			if choice.FinishReason != "" {
+				finishReasons[choice.Index] = choice.FinishReason
+			}
+			if onChunk != nil {
+				onChunk(choice.Text)
+			}
+		}
+	}
+	if err = scanner.Err(); err != nil {
+		return nil, fmt.Errorf("could not read stream: %w", err)
+	}
+
+	responses := make([]string, len(completions))
+	for i := range completions {
+		responses[i] = restoreEndOfSequence(completions[i].String(), finishReasons[i], c.conf.Terminator())
+	}
+	return responses, nil
+}
@@ -0,0 +1,75 @@
+package gpt3_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/redhat-et/copilot-ops/pkg/ai"
+	"github.com/redhat-et/copilot-ops/pkg/ai/gpt3"
+)
+
+var _ = Describe("Gpt3 Streaming Generate Client", func() {
+	var ts *httptest.Server
+
+	BeforeEach(func() {
+		ts = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path != "/"+gpt3.CompletionEndpoint {
+				http.Error(w, "not found", http.StatusNotFound)
+				return
+			}
+			if r.Header.Get("Authorization") != "Bearer abc" {
+				http.Error(w, `{"error": {"message": "invalid api key"}}`, http.StatusUnauthorized)
+				return
+			}
+			w.Header().Set("Content-Type", "text/event-stream")
+			for _, chunk := range []string{"kind", ":", " Pod"} {
+				fmt.Fprintf(w, "data: {\"choices\": [{\"text\": %q, \"index\": 0}]}\n\n", chunk)
+			}
+			fmt.Fprint(w, "data: [DONE]\n\n")
+		}))
+		DeferCleanup(ts.Close)
+	})
+
+	It("streams chunks and returns the full completion", func() {
+		client := gpt3.CreateGPT3GenerateClient(
+			gpt3.Config{APIKey: "abc", BaseURL: ts.URL},
+			"hello world",
+			256,
+			1,
+			0,
+			nil,
+		)
+		streamer, ok := client.(ai.StreamingGenerateClient)
+		Expect(ok).To(BeTrue())
+
+		var chunks []string
+		choices, err := streamer.GenerateStream(context.Background(), func(chunk string) {
+			chunks = append(chunks, chunk)
+		})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(chunks).To(Equal([]string{"kind", ":", " Pod"}))
+		Expect(choices).To(Equal([]string{"kind: Pod"}))
+	})
+
+	It("returns an error when the request fails", func() {
+		client := gpt3.CreateGPT3GenerateClient(
+			gpt3.Config{APIKey: "wrong", BaseURL: ts.URL},
+			"hello world",
+			256,
+			1,
+			0,
+			nil,
+		)
+		streamer, ok := client.(ai.StreamingGenerateClient)
+		Expect(ok).To(BeTrue())
+
+		choices, err := streamer.GenerateStream(context.Background(), nil)
+		Expect(err).To(MatchError(ContainSubstring("invalid api key")))
+		Expect(choices).To(BeEmpty())
+	})
+})
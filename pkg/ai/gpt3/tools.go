@@ -0,0 +1,125 @@
+package gpt3
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+const (
+	// WriteFilesFunction Is the name of the function which chat models call with the files they write,
+	// when structured output is requested.
+	WriteFilesFunction = "write_files"
+	// ToolTypeFunction Is the type of tools which are functions the model can call.
+	ToolTypeFunction = "function"
+	// StructuredSystemPrompt Tells a chat model to answer by calling WriteFilesFunction.
+	StructuredSystemPrompt = "You edit and create files. Answer the user's request by calling " + WriteFilesFunction +
+		" once with the full content of every file which is created or changed. Existing files are shown with " +
+		"a '# @name' heading, so use that name as their path."
+)
+
+// Tool Describes a tool which the chat model can use while answering.
+type Tool struct {
+	Type     string       `json:"type"`
+	Function ToolFunction `json:"function"`
+}
+
+// ToolFunction Describes a function which the chat model can call, with a JSON schema of its arguments.
+type ToolFunction struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description,omitempty"`
+	Parameters  json.RawMessage `json:"parameters,omitempty"`
+}
+
+// ToolChoice Forces the chat model to call the named function.
+type ToolChoice struct {
+	Type     string `json:"type"`
+	Function struct {
+		Name string `json:"name"`
+	} `json:"function"`
+}
+
+// ToolCall Is a call of a function made by the chat model, with its arguments encoded as JSON.
+type ToolCall struct {
+	ID       string `json:"id,omitempty"`
+	Type     string `json:"type"`
+	Function struct {
+		Name      string `json:"name"`
+		Arguments string `json:"arguments"`
+	} `json:"function"`
+}
+
+// WrittenFile Is a file passed to WriteFilesFunction.
+type WrittenFile struct {
+	Path    string `json:"path"`
+	Content string `json:"content"`
+}
+
+// WriteFilesArguments Are the arguments of WriteFilesFunction.
+type WriteFilesArguments struct {
+	Files []WrittenFile `json:"files"`
+}
+
+// WriteFilesTool Returns the definition of WriteFilesFunction which is sent to chat models.
+func WriteFilesTool() Tool {
+	return Tool{
+		Type: ToolTypeFunction,
+		Function: ToolFunction{
+			Name:        WriteFilesFunction,
+			Description: "Writes the full content of every file which is created or changed.",
+			Parameters: json.RawMessage(`{"type": "object", "properties": {"files": {"type": "array", "items": ` +
+				`{"type": "object", "properties": {` +
+				`"path": {"type": "string", "description": "The name of an existing file, or the path of a new one"}, ` +
+				`"content": {"type": "string", "description": "The full content of the file"}}, ` +
+				`"required": ["path", "content"]}}}, "required": ["files"]}`),
+		},
+	}
+}
+
+// WriteFilesChoice Returns a ToolChoice which forces the model to call WriteFilesFunction.
+func WriteFilesChoice() *ToolChoice {
+	choice := &ToolChoice{Type: ToolTypeFunction}
+	choice.Function.Name = WriteFilesFunction
+	return choice
+}
+
+// writeFilesCall Merges the files of every call of WriteFilesFunction into the arguments of a single call,
+// and reports whether there were any calls.
+func writeFilesCall(calls []ToolCall) (string, bool) {
+	var merged WriteFilesArguments
+	called := false
+	for _, call := range calls {
+		if call.Function.Name != WriteFilesFunction {
+			continue
+		}
+		args, ok := ParseWriteFiles(call.Function.Arguments)
+		if !ok {
+			continue
+		}
+		merged.Files = append(merged.Files, args.Files...)
+		called = true
+	}
+	if !called {
+		return "", false
+	}
+	data, err := json.Marshal(merged)
+	if err != nil {
+		return "", false
+	}
+	return string(data), true
+}
+
+// ParseWriteFiles Parses the arguments of a call of WriteFilesFunction, as returned by the chat client
+// in place of a text completion, and reports whether the completion holds such arguments.
+func ParseWriteFiles(completion string) (WriteFilesArguments, bool) {
+	var args WriteFilesArguments
+	trimmed := strings.TrimSpace(completion)
+	if !strings.HasPrefix(trimmed, "{") {
+		return args, false
+	}
+	decoder := json.NewDecoder(strings.NewReader(trimmed))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&args); err != nil || args.Files == nil {
+		return args, false
+	}
+	return args, true
+}
@@ -1,14 +1,10 @@
 package gptj
 
 import (
-	"bytes"
-	"context"
-	"encoding/json"
-	"fmt"
 	"net/http"
 
 	"github.com/redhat-et/copilot-ops/pkg/ai"
-	"github.com/redhat-et/copilot-ops/pkg/utils"
+	"github.com/redhat-et/copilot-ops/pkg/ai/hfinference"
 )
 
 // Define constants used by EleutherAI here.
@@ -35,50 +31,27 @@ type GenerateParams struct {
 type Config struct {
 	// URL Defines the URL which the HTTP Client will be making requests to.
 	URL string `json:"url" yaml:"url"`
-}
-
-// Generate Invokes the generate function to GPT-J. Currently, the endpoint
-// only supports a single item to be returned when generated.
-func (c gptjClient) Generate() ([]string, error) {
-	// parse params
-	if c.generateParams == nil {
-		return nil, fmt.Errorf("no params provided")
-	}
-
-	// marshal params into json bytes
-	reqBytes, err := json.Marshal(c.generateParams)
-	if err != nil {
-		return nil, fmt.Errorf("could not send request: %w", err)
-	}
-	reqBuff := bytes.NewBuffer(reqBytes)
-
-	// create request
-	urlPath := c.baseURL + "/" + CompletionEndpoint
-	req, err := http.NewRequestWithContext(context.Background(), http.MethodPost, urlPath, reqBuff)
-	if err != nil {
-		return nil, fmt.Errorf("could not create request: %w", err)
-	}
-	req.Header.Set("Accept", "application/json")
-	req.Header.Set("Content-Type", "application/json")
-
-	// transform request into response
-	var response []choice
-	if err = utils.JSONRequest(req, c.httpClient, &response); err != nil {
-		return nil, fmt.Errorf("could not request eleuther.ai: %w", err)
-	}
-	choices := make([]string, len(response))
-	for i, choice := range response {
-		choices[i] = choice.GeneratedText
-	}
-	return choices, nil
+	// APIKey Is an optional HuggingFace token, sent as a bearer token.
+	APIKey string `json:"apiKey,omitempty" yaml:"apiKey,omitempty"`
+	// CACertFile Is the path of a PEM file with the certificate authorities which the server's
+	// certificate is signed by, trusted on top of the system's.
+	CACertFile string `json:"caCertFile,omitempty" yaml:"caCertFile,omitempty"`
+	// InsecureSkipVerify Skips verifying the server's certificate, which is only honored
+	// when it's also allowed on the command-line.
+	InsecureSkipVerify bool `json:"insecureSkipVerify,omitempty" yaml:"insecureSkipVerify,omitempty"`
+	// HTTPClient Is used to make requests to the API, defaulting to http.DefaultClient.
+	HTTPClient *http.Client `json:"-" yaml:"-"`
 }
 
 // CreateGPTJGenerateClient Returns a GPT-J client which implements the AI Client interface.
+// Currently, the endpoint only supports a single item to be returned when generated.
 func CreateGPTJGenerateClient(conf Config, params GenerateParams) ai.GenerateClient {
-	c := gptjClient{
-		baseURL:        conf.URL,
-		httpClient:     http.DefaultClient,
-		generateParams: &params,
-	}
-	return c
+	return hfinference.NewClient(
+		hfinference.Config{
+			URL:        conf.URL + "/" + CompletionEndpoint,
+			APIKey:     conf.APIKey,
+			HTTPClient: conf.HTTPClient,
+		},
+		params,
+	)
 }
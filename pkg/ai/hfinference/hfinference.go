@@ -0,0 +1,144 @@
+// hfinference Implements a client for text-generation models served by the
+// HuggingFace Inference API, or any endpoint which responds in the same format.
+package hfinference
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/redhat-et/copilot-ops/pkg/ai"
+	"github.com/redhat-et/copilot-ops/pkg/utils"
+)
+
+// APIURL Is where models hosted on the HuggingFace Inference API can be found,
+// each under its model ID.
+const APIURL = "https://api-inference.huggingface.co/models"
+
+// Config Defines the values required to connect to a model on the Inference API.
+type Config struct {
+	// URL Defines where to find the API.
+	URL string `json:"url" yaml:"url"`
+	// ModelID Is the ID of the model on the HuggingFace hub, e.g. 'bigscience/bloom'.
+	// When empty, requests are sent to the URL as-is.
+	ModelID string `json:"modelID,omitempty" yaml:"modelID,omitempty"`
+	// APIKey Is an optional HuggingFace token, sent as a bearer token.
+	APIKey string `json:"apiKey,omitempty" yaml:"apiKey,omitempty"`
+	// HubURL Is the API of the HuggingFace hub which models are listed from, defaulting to HubAPIURL.
+	HubURL string `json:"hubURL,omitempty" yaml:"hubURL,omitempty"`
+	// HTTPClient Is used to make requests to the API, defaulting to http.DefaultClient.
+	HTTPClient *http.Client `json:"-" yaml:"-"`
+}
+
+// ModelURL Returns the URL which requests for the configured model are sent to.
+func (conf Config) ModelURL() string {
+	if conf.ModelID == "" {
+		return conf.URL
+	}
+	return strings.TrimSuffix(conf.URL, "/") + "/" + strings.TrimPrefix(conf.ModelID, "/")
+}
+
+// Request Defines the body of a text-generation request.
+type Request struct {
+	Inputs     string                 `json:"inputs"`
+	Parameters map[string]interface{} `json:"parameters,omitempty"`
+}
+
+// choice Represents a single text-generation element.
+type choice struct {
+	GeneratedText string `json:"generated_text"`
+}
+
+// responseError Represents an object returned in the event of an error.
+type responseError struct {
+	Error *string `json:"error,omitempty"`
+}
+
+// hfClient Describes the client which implements the AI interfaces.
+type hfClient struct {
+	url        string
+	apiKey     string
+	httpClient *http.Client
+	body       interface{}
+}
+
+// Generate Returns a list of completions created by the model.
+func (c hfClient) Generate(ctx context.Context) ([]string, error) {
+	if c.body == nil {
+		return nil, fmt.Errorf("no params provided")
+	}
+
+	// marshal params into json bytes
+	reqBytes, err := json.Marshal(c.body)
+	if err != nil {
+		return nil, fmt.Errorf("could not send request: %w", err)
+	}
+
+	// create request
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.url, bytes.NewBuffer(reqBytes))
+	if err != nil {
+		return nil, fmt.Errorf("could not create request: %w", err)
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("Content-Type", "application/json")
+	if c.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+c.apiKey)
+	}
+
+	// retrieve a response from server
+	res, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error making request: %w", err)
+	}
+	defer res.Body.Close()
+
+	// wrap the HTTP error
+	if res.StatusCode < http.StatusOK || res.StatusCode >= http.StatusBadRequest {
+		errResp := responseError{}
+		if err = json.NewDecoder(res.Body).Decode(&errResp); err == nil && errResp.Error != nil {
+			return nil, utils.NewStatusError(res, *errResp.Error)
+		}
+		return nil, utils.NewStatusError(res, "")
+	}
+
+	// attempt to marshal into a response
+	resp := make([]choice, 0)
+	if err = json.NewDecoder(res.Body).Decode(&resp); err != nil {
+		return nil, fmt.Errorf("could not read response: %w", err)
+	}
+
+	// transform into flat list
+	responses := make([]string, len(resp))
+	for i, gen := range resp {
+		responses[i] = gen.GeneratedText
+	}
+	return responses, nil
+}
+
+// NewClient Returns a client which posts the given body to the configured model and
+// reads back a list of generated texts. This is meant for endpoints which respond like
+// the Inference API but expect their own parameters.
+func NewClient(conf Config, body interface{}) ai.GenerateClient {
+	c := hfClient{
+		url:        conf.ModelURL(),
+		apiKey:     conf.APIKey,
+		httpClient: http.DefaultClient,
+		body:       body,
+	}
+	if conf.HTTPClient != nil {
+		c.httpClient = conf.HTTPClient
+	}
+	return c
+}
+
+// CreateGenerateClient Returns a client which generates text from the prompt with the
+// configured model, passing along the given text-generation parameters.
+func CreateGenerateClient(conf Config, prompt string, params map[string]interface{}) ai.GenerateClient {
+	return NewClient(conf, Request{
+		Inputs:     prompt,
+		Parameters: params,
+	})
+}
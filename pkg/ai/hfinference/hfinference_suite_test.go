@@ -0,0 +1,13 @@
+package hfinference_test
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func TestHFInference(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "HFInference Suite")
+}
@@ -0,0 +1,72 @@
+package hfinference_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/redhat-et/copilot-ops/pkg/ai/hfinference"
+)
+
+var _ = Describe("HuggingFace Inference Client", func() {
+	var ts *httptest.Server
+	var received map[string]hfinference.Request
+	var authorization string
+
+	BeforeEach(func() {
+		received = make(map[string]hfinference.Request)
+		ts = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var body hfinference.Request
+			_ = json.NewDecoder(r.Body).Decode(&body)
+			received[r.URL.Path] = body
+			authorization = r.Header.Get("Authorization")
+
+			// each fake model responds differently
+			switch r.URL.Path {
+			case "/bigscience/bloom":
+				_, _ = w.Write([]byte(`[{"generated_text": "kind: Pod"}]`))
+			case "/bigcode/starcoder":
+				_, _ = w.Write([]byte(`[{"generated_text": "kind: Service"}, {"generated_text": "kind: Secret"}]`))
+			case "/bigcode/loading":
+				w.WriteHeader(http.StatusServiceUnavailable)
+				_, _ = w.Write([]byte(`{"error": "Model bigcode/loading is currently loading"}`))
+			default:
+				http.NotFound(w, r)
+			}
+		}))
+		DeferCleanup(ts.Close)
+	})
+
+	It("requests a completion from the model", func() {
+		conf := hfinference.Config{URL: ts.URL, ModelID: "bigscience/bloom"}
+		client := hfinference.CreateGenerateClient(conf, "hello world", map[string]interface{}{"max_new_tokens": 16})
+		choices, err := client.Generate(context.Background())
+		Expect(err).NotTo(HaveOccurred())
+		Expect(choices).To(Equal([]string{"kind: Pod"}))
+		Expect(received["/bigscience/bloom"]).To(Equal(hfinference.Request{
+			Inputs:     "hello world",
+			Parameters: map[string]interface{}{"max_new_tokens": float64(16)},
+		}))
+		Expect(authorization).To(BeEmpty())
+	})
+
+	It("returns every sequence generated by another model", func() {
+		conf := hfinference.Config{URL: ts.URL + "/", ModelID: "bigcode/starcoder", APIKey: "hf_abc"}
+		Expect(conf.ModelURL()).To(Equal(ts.URL + "/bigcode/starcoder"))
+		choices, err := hfinference.CreateGenerateClient(conf, "hello world", nil).Generate(context.Background())
+		Expect(err).NotTo(HaveOccurred())
+		Expect(choices).To(Equal([]string{"kind: Service", "kind: Secret"}))
+		Expect(authorization).To(Equal("Bearer hf_abc"))
+	})
+
+	It("reports errors from the model", func() {
+		conf := hfinference.Config{URL: ts.URL, ModelID: "bigcode/loading"}
+		choices, err := hfinference.CreateGenerateClient(conf, "hello world", nil).Generate(context.Background())
+		Expect(err).To(MatchError("error, status code: 503, message: Model bigcode/loading is currently loading"))
+		Expect(choices).To(BeEmpty())
+	})
+})
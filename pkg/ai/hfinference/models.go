@@ -0,0 +1,60 @@
+package hfinference
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/redhat-et/copilot-ops/pkg/utils"
+)
+
+const (
+	// HubAPIURL Is the API of the HuggingFace hub, which the available models are listed from.
+	HubAPIURL = "https://huggingface.co/api"
+	// ModelsEndpoint Is the endpoint of the hub which lists models, relative to the hub's URL.
+	ModelsEndpoint = "models"
+	// ModelListLimit Is how many of the most downloaded models are listed.
+	ModelListLimit = 50
+)
+
+// hubModel Represents a model listed by the hub.
+type hubModel struct {
+	ID string `json:"id"`
+}
+
+// ListModels Returns the IDs of the most downloaded text-generation models on the hub,
+// which can be used with the Inference API, in the order of their downloads.
+func ListModels(ctx context.Context, conf Config) ([]string, error) {
+	hubURL := conf.HubURL
+	if hubURL == "" {
+		hubURL = HubAPIURL
+	}
+	query := url.Values{
+		"pipeline_tag": {"text-generation"},
+		"sort":         {"downloads"},
+		"direction":    {"-1"},
+		"limit":        {strconv.Itoa(ModelListLimit)},
+	}
+	modelsURL := strings.TrimSuffix(hubURL, "/") + "/" + ModelsEndpoint + "?" + query.Encode()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, modelsURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("could not create request: %w", err)
+	}
+	req.Header.Set("Accept", "application/json")
+	if conf.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+conf.APIKey)
+	}
+
+	var res []hubModel
+	if err = utils.JSONRequest(req, conf.HTTPClient, &res); err != nil {
+		return nil, fmt.Errorf("could not list the models: %w", err)
+	}
+	models := make([]string, len(res))
+	for i, model := range res {
+		models[i] = model.ID
+	}
+	return models, nil
+}
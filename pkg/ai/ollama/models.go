@@ -0,0 +1,42 @@
+package ollama
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/redhat-et/copilot-ops/pkg/utils"
+)
+
+// TagsEndpoint Is the endpoint which lists the models pulled into Ollama.
+const TagsEndpoint = "api/tags"
+
+// tagsResponse Represents the body returned by the tags endpoint.
+type tagsResponse struct {
+	Models []struct {
+		Name string `json:"name"`
+	} `json:"models"`
+}
+
+// ListModels Returns the names of the models pulled into the Ollama server, sorted by name.
+func ListModels(ctx context.Context, conf Config) ([]string, error) {
+	url := strings.TrimSuffix(conf.URL, "/") + "/" + TagsEndpoint
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("could not create request: %w", err)
+	}
+	req.Header.Set("Accept", "application/json")
+
+	var res tagsResponse
+	if err = utils.JSONRequest(req, conf.HTTPClient, &res); err != nil {
+		return nil, fmt.Errorf("could not list the models: %w", err)
+	}
+	models := make([]string, len(res.Models))
+	for i, model := range res.Models {
+		models[i] = model.Name
+	}
+	sort.Strings(models)
+	return models, nil
+}
@@ -0,0 +1,175 @@
+// ollama Implements a client for models served locally by Ollama, for offline use.
+package ollama
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/redhat-et/copilot-ops/pkg/ai"
+	"github.com/redhat-et/copilot-ops/pkg/utils"
+)
+
+// Define the constants used by the Ollama API here.
+const (
+	APIURL           = "http://localhost:11434"
+	GenerateEndpoint = "api/generate"
+	// DefaultModel Is the model used when none is configured.
+	DefaultModel = "codellama"
+)
+
+// Config Describes the structure needed for configuring an Ollama client.
+type Config struct {
+	// URL Defines the URL of the Ollama server.
+	URL string `json:"url" yaml:"url"`
+	// Model Is the name of the model pulled into Ollama, e.g. 'codellama'.
+	Model string `json:"model" yaml:"model"`
+	// CACertFile Is the path of a PEM file with the certificate authorities which the server's
+	// certificate is signed by, trusted on top of the system's.
+	CACertFile string `json:"caCertFile,omitempty" yaml:"caCertFile,omitempty"`
+	// InsecureSkipVerify Skips verifying the server's certificate, which is only honored
+	// when it's also allowed on the command-line.
+	InsecureSkipVerify bool `json:"insecureSkipVerify,omitempty" yaml:"insecureSkipVerify,omitempty"`
+	// HTTPClient Is used to make requests to the API, defaulting to http.DefaultClient.
+	HTTPClient *http.Client `json:"-" yaml:"-"`
+	// SystemPrompt Overrides the system message of the model's template, if set.
+	SystemPrompt string `json:"-" yaml:"-"`
+}
+
+// Options Defines the model parameters sent along with a request.
+type Options struct {
+	// NumPredict Is the maximum number of tokens to generate.
+	NumPredict  int      `json:"num_predict,omitempty"`
+	Temperature float32  `json:"temperature"`
+	TopP        *float32 `json:"top_p,omitempty"`
+}
+
+// GenerateRequest Defines the parameters which are sent when requesting
+// a completion from Ollama.
+type GenerateRequest struct {
+	Model   string  `json:"model"`
+	Prompt  string  `json:"prompt"`
+	System  string  `json:"system,omitempty"`
+	Options Options `json:"options"`
+}
+
+// generateChunk Is one line of the newline-delimited JSON streamed back by Ollama.
+type generateChunk struct {
+	Response string `json:"response"`
+	Done     bool   `json:"done"`
+	Error    string `json:"error,omitempty"`
+}
+
+// ollamaClient Is a client implementation of Ollama meant to implement
+// the AI Client interface.
+type ollamaClient struct {
+	conf         Config
+	httpClient   *http.Client
+	params       GenerateRequest
+	nCompletions int
+}
+
+// Generate Returns a list of completions created by Ollama for the given prompt.
+// Ollama generates a single completion per request, so one request is made for
+// every completion.
+func (c ollamaClient) Generate(ctx context.Context) ([]string, error) {
+	choices := make([]string, 0, c.nCompletions)
+	for i := 0; i < c.nCompletions; i++ {
+		choice, err := c.generate(ctx)
+		if err != nil {
+			return nil, err
+		}
+		choices = append(choices, choice)
+	}
+	return choices, nil
+}
+
+// generate Requests a single completion, assembling the response from the chunks
+// which Ollama streams back.
+func (c ollamaClient) generate(ctx context.Context) (string, error) {
+	reqBytes, err := json.Marshal(c.params)
+	if err != nil {
+		return "", fmt.Errorf("could not send request: %w", err)
+	}
+
+	// create request
+	urlPath := c.conf.URL + "/" + GenerateEndpoint
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, urlPath, bytes.NewBuffer(reqBytes))
+	if err != nil {
+		return "", fmt.Errorf("could not create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	res, err := c.httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("error making request: %w", err)
+	}
+	defer res.Body.Close()
+
+	// wrap the HTTP error
+	decoder := json.NewDecoder(res.Body)
+	if res.StatusCode < http.StatusOK || res.StatusCode >= http.StatusBadRequest {
+		var errResp generateChunk
+		if err = decoder.Decode(&errResp); err == nil && errResp.Error != "" {
+			return "", utils.NewStatusError(res, errResp.Error)
+		}
+		return "", utils.NewStatusError(res, "")
+	}
+
+	// read each chunk until the response is done
+	var response strings.Builder
+	for {
+		var chunk generateChunk
+		if err = decoder.Decode(&chunk); errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return "", fmt.Errorf("could not read response: %w", err)
+		}
+		if chunk.Error != "" {
+			return "", fmt.Errorf("received error from server: %s", chunk.Error)
+		}
+		response.WriteString(chunk.Response)
+		if chunk.Done {
+			break
+		}
+	}
+	return response.String(), nil
+}
+
+// CreateOllamaGenerateClient Returns an Ollama client capable of making code generations.
+func CreateOllamaGenerateClient(
+	conf Config,
+	prompt string,
+	maxTokens, nCompletions int,
+	temperature float32,
+	topP *float32,
+) ai.GenerateClient {
+	model := conf.Model
+	if model == "" {
+		model = DefaultModel
+	}
+	c := ollamaClient{
+		conf:       conf,
+		httpClient: http.DefaultClient,
+		params: GenerateRequest{
+			Model:  model,
+			Prompt: prompt,
+			System: conf.SystemPrompt,
+			Options: Options{
+				NumPredict:  maxTokens,
+				Temperature: temperature,
+				TopP:        topP,
+			},
+		},
+		nCompletions: nCompletions,
+	}
+	if conf.HTTPClient != nil {
+		c.httpClient = conf.HTTPClient
+	}
+	return c
+}
@@ -0,0 +1,78 @@
+package ollama_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/redhat-et/copilot-ops/pkg/ai/ollama"
+)
+
+var _ = Describe("Ollama Generate Client", func() {
+	var ts *httptest.Server
+	var received []ollama.GenerateRequest
+
+	BeforeEach(func() {
+		received = nil
+		ts = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path != "/"+ollama.GenerateEndpoint {
+				http.Error(w, "not found", http.StatusNotFound)
+				return
+			}
+			var body ollama.GenerateRequest
+			_ = json.NewDecoder(r.Body).Decode(&body)
+			received = append(received, body)
+			if body.Model == "missing" {
+				w.WriteHeader(http.StatusNotFound)
+				fmt.Fprintln(w, `{"error": "model 'missing' not found"}`)
+				return
+			}
+
+			// stream the response in chunks
+			w.Header().Set("Content-Type", "application/x-ndjson")
+			flusher, _ := w.(http.Flusher)
+			for _, chunk := range []string{"kind", ":", " Pod"} {
+				fmt.Fprintf(w, "{\"model\": %q, \"response\": %q, \"done\": false}\n", body.Model, chunk)
+				if flusher != nil {
+					flusher.Flush()
+				}
+			}
+			fmt.Fprintf(w, "{\"model\": %q, \"response\": \"\", \"done\": true}\n", body.Model)
+		}))
+		DeferCleanup(ts.Close)
+	})
+
+	It("assembles the chunks into a completion", func() {
+		client := ollama.CreateOllamaGenerateClient(ollama.Config{URL: ts.URL}, "hello world", 256, 1, 0, nil)
+		choices, err := client.Generate(context.Background())
+		Expect(err).NotTo(HaveOccurred())
+		Expect(choices).To(Equal([]string{"kind: Pod"}))
+		Expect(received).To(Equal([]ollama.GenerateRequest{{
+			Model:   ollama.DefaultModel,
+			Prompt:  "hello world",
+			Options: ollama.Options{NumPredict: 256},
+		}}))
+	})
+
+	It("makes a request for every completion", func() {
+		client := ollama.CreateOllamaGenerateClient(ollama.Config{URL: ts.URL, Model: "llama3"}, "hello world", 256, 2, 0.7, nil)
+		choices, err := client.Generate(context.Background())
+		Expect(err).NotTo(HaveOccurred())
+		Expect(choices).To(Equal([]string{"kind: Pod", "kind: Pod"}))
+		Expect(received).To(HaveLen(2))
+		Expect(received[0].Model).To(Equal("llama3"))
+		Expect(received[0].Options.Temperature).To(BeNumerically("~", 0.7, 1e-6))
+	})
+
+	It("reports errors from the server", func() {
+		client := ollama.CreateOllamaGenerateClient(ollama.Config{URL: ts.URL, Model: "missing"}, "hello world", 256, 1, 0, nil)
+		choices, err := client.Generate(context.Background())
+		Expect(err).To(MatchError(ContainSubstring("model 'missing' not found")))
+		Expect(choices).To(BeEmpty())
+	})
+})
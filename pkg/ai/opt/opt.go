@@ -1,25 +1,108 @@
 package opt
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
 	"fmt"
+	"net/http"
 
 	"github.com/redhat-et/copilot-ops/pkg/ai"
+	"github.com/redhat-et/copilot-ops/pkg/utils"
 )
 
+// Define the constants used by the hosted OPT-175B API here.
+const (
+	APIURL             = "https://opt.alpa.ai"
+	CompletionEndpoint = "completions"
+)
+
+// Config Describes the structure needed for configuring an OPT client.
+type Config struct {
+	// URL Defines the URL which the HTTP Client will be making requests to.
+	URL string `json:"url" yaml:"url"`
+	// HTTPClient Is used to make requests to the API, defaulting to http.DefaultClient.
+	HTTPClient *http.Client `json:"-" yaml:"-"`
+}
+
+// GenerateParams Defines the parameters which are sent when requesting
+// a completion from OPT.
+type GenerateParams struct {
+	// Prompt Defines the prompt which will be passed into OPT.
+	Prompt      string  `json:"prompt"`
+	MaxTokens   int     `json:"max_tokens"`
+	Temperature float32 `json:"temperature"`
+	TopP        float32 `json:"top_p"`
+}
+
+// optClient Is a client implementation of OPT meant to implement
+// the AI Client interface.
 type optClient struct {
+	baseURL        string
+	httpClient     *http.Client
+	generateParams *GenerateParams
 }
 
-func (c optClient) Generate() ([]string, error) {
-	return nil, fmt.Errorf("not implemented")
+// choice Represents a single completion returned from the OPT API.
+type choice struct {
+	Text string `json:"text"`
 }
 
-func (c optClient) Edit() ([]string, error) {
+// generateResponse Represents the body returned by the OPT completions endpoint.
+type generateResponse struct {
+	Choices []choice `json:"choices"`
+}
+
+// Generate Returns a list of completions created by OPT for the given prompt.
+func (c optClient) Generate(ctx context.Context) ([]string, error) {
+	if c.generateParams == nil {
+		return nil, fmt.Errorf("no params provided")
+	}
+
+	// marshal params into json bytes
+	reqBytes, err := json.Marshal(c.generateParams)
+	if err != nil {
+		return nil, fmt.Errorf("could not send request: %w", err)
+	}
+	reqBuff := bytes.NewBuffer(reqBytes)
+
+	// create request
+	urlPath := c.baseURL + "/" + CompletionEndpoint
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, urlPath, reqBuff)
+	if err != nil {
+		return nil, fmt.Errorf("could not create request: %w", err)
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("Content-Type", "application/json")
+
+	// transform request into response
+	var response generateResponse
+	if err = utils.JSONRequest(req, c.httpClient, &response); err != nil {
+		return nil, fmt.Errorf("could not request opt: %w", err)
+	}
+	choices := make([]string, len(response.Choices))
+	for i, choice := range response.Choices {
+		choices[i] = choice.Text
+	}
+	return choices, nil
+}
+
+// Edit Returns a list of edits made by OPT.
+func (c optClient) Edit(context.Context) ([]string, error) {
 	return nil, fmt.Errorf("not implemented")
 }
 
 // CreateOPTGenerateClient Returns an OPT-175B client capable of making code generations.
-func CreateOPTGenerateClient() ai.GenerateClient {
-	return optClient{}
+func CreateOPTGenerateClient(conf Config, params GenerateParams) ai.GenerateClient {
+	c := optClient{
+		baseURL:        conf.URL,
+		httpClient:     http.DefaultClient,
+		generateParams: &params,
+	}
+	if conf.HTTPClient != nil {
+		c.httpClient = conf.HTTPClient
+	}
+	return c
 }
 
 // CreateOPTEditClient Returns an OPT-175B client capable of making code edits.
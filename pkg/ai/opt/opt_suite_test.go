@@ -0,0 +1,13 @@
+package opt_test
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func TestOpt(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Opt Suite")
+}
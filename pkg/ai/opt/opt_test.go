@@ -0,0 +1,52 @@
+package opt_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/redhat-et/copilot-ops/pkg/ai"
+	"github.com/redhat-et/copilot-ops/pkg/ai/opt"
+)
+
+var _ = Describe("OPT Generate Client", func() {
+	var ts *httptest.Server
+	var received opt.GenerateParams
+
+	BeforeEach(func() {
+		ts = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path != "/"+opt.CompletionEndpoint {
+				http.Error(w, "not found", http.StatusNotFound)
+				return
+			}
+			_ = json.NewDecoder(r.Body).Decode(&received)
+			_, _ = w.Write([]byte(`{"choices": [{"text": "kind: Pod"}]}`))
+		}))
+		DeferCleanup(ts.Close)
+	})
+
+	It("generates completions from the endpoint", func() {
+		var client ai.GenerateClient = opt.CreateOPTGenerateClient(
+			opt.Config{URL: ts.URL},
+			opt.GenerateParams{Prompt: "hello world", MaxTokens: 16},
+		)
+		Expect(client).NotTo(BeNil())
+
+		choices, err := client.Generate(context.Background())
+		Expect(err).NotTo(HaveOccurred())
+		Expect(choices).To(Equal([]string{"kind: Pod"}))
+		Expect(received.Prompt).To(Equal("hello world"))
+		Expect(received.MaxTokens).To(Equal(16))
+	})
+
+	It("fails when the endpoint can't be reached", func() {
+		client := opt.CreateOPTGenerateClient(opt.Config{URL: ts.URL + "/missing"}, opt.GenerateParams{})
+		choices, err := client.Generate(context.Background())
+		Expect(err).To(HaveOccurred())
+		Expect(choices).To(BeEmpty())
+	})
+})
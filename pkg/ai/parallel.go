@@ -0,0 +1,98 @@
+package ai
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// DefaultConcurrency Is the number of requests made to a backend at once by default.
+const DefaultConcurrency = 4
+
+// GenerateErrors Collects the errors returned by every failed request.
+type GenerateErrors []error
+
+// Error Lists the error of every failed request.
+func (e GenerateErrors) Error() string {
+	messages := make([]string, len(e))
+	for i, err := range e {
+		messages[i] = err.Error()
+	}
+	return fmt.Sprintf("%d of the requests failed: %s", len(e), strings.Join(messages, "; "))
+}
+
+// Unwrap Returns the error of every failed request.
+func (e GenerateErrors) Unwrap() []error {
+	return e
+}
+
+// GenerateConcurrently Calls generate n times, with at most concurrency calls running at once,
+// and returns the completions of every call in order of i. If any calls fail, every error is
+// returned together.
+func GenerateConcurrently(n, concurrency int, generate func(i int) ([]string, error)) ([]string, error) {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	results := make([][]string, n)
+	errs := make([]error, n)
+
+	// feed the indexes to a bounded pool of workers
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < concurrency && w < n; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				results[i], errs[i] = generate(i)
+			}
+		}()
+	}
+	for i := 0; i < n; i++ {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+
+	var failed GenerateErrors
+	var choices []string
+	for i := range results {
+		if errs[i] != nil {
+			failed = append(failed, errs[i])
+			continue
+		}
+		choices = append(choices, results[i]...)
+	}
+	if len(failed) > 0 {
+		return nil, failed
+	}
+	return choices, nil
+}
+
+// repeatedClient Makes several requests to a client which only returns a single completion at a time.
+type repeatedClient struct {
+	client      GenerateClient
+	n           int
+	concurrency int
+}
+
+// Generate Requests every completion from the underlying client concurrently.
+func (c repeatedClient) Generate(ctx context.Context) ([]string, error) {
+	return GenerateConcurrently(c.n, c.concurrency, func(int) ([]string, error) {
+		return c.client.Generate(ctx)
+	})
+}
+
+// Repeat Returns a client which calls Generate on the given client n times, with at most
+// concurrency requests at once, for backends which can't return several completions per request.
+func Repeat(client GenerateClient, n, concurrency int) GenerateClient {
+	if n <= 1 {
+		return client
+	}
+	return repeatedClient{
+		client:      client,
+		n:           n,
+		concurrency: concurrency,
+	}
+}
@@ -0,0 +1,79 @@
+package ai_test
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/redhat-et/copilot-ops/pkg/ai"
+)
+
+// staticClient Always generates the same completion.
+type staticClient string
+
+func (c staticClient) Generate(context.Context) ([]string, error) {
+	return []string{string(c)}, nil
+}
+
+var _ = Describe("GenerateConcurrently", func() {
+	It("returns the completions in order", func() {
+		choices, err := ai.GenerateConcurrently(5, 5, func(i int) ([]string, error) {
+			// later requests finish first
+			time.Sleep(time.Duration(5-i) * 5 * time.Millisecond)
+			return []string{fmt.Sprint(i)}, nil
+		})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(choices).To(Equal([]string{"0", "1", "2", "3", "4"}))
+	})
+
+	It("bounds the number of requests in flight", func() {
+		var mu sync.Mutex
+		var inFlight, maxInFlight int
+		var starts []time.Time
+		choices, err := ai.GenerateConcurrently(8, 3, func(i int) ([]string, error) {
+			mu.Lock()
+			inFlight++
+			if inFlight > maxInFlight {
+				maxInFlight = inFlight
+			}
+			starts = append(starts, time.Now())
+			mu.Unlock()
+
+			time.Sleep(10 * time.Millisecond)
+
+			mu.Lock()
+			inFlight--
+			mu.Unlock()
+			return []string{"kind: Pod"}, nil
+		})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(choices).To(HaveLen(8))
+		Expect(maxInFlight).To(Equal(3))
+		Expect(starts).To(HaveLen(8))
+		// the last requests had to wait for earlier ones to finish
+		Expect(starts[7].Sub(starts[0])).To(BeNumerically(">=", 10*time.Millisecond))
+	})
+
+	It("aggregates the errors of every failed request", func() {
+		choices, err := ai.GenerateConcurrently(4, 2, func(i int) ([]string, error) {
+			if i%2 == 1 {
+				return nil, fmt.Errorf("error, status code: %d", 500+i)
+			}
+			return []string{"kind: Pod"}, nil
+		})
+		Expect(choices).To(BeEmpty())
+		Expect(err).To(MatchError("2 of the requests failed: error, status code: 501; error, status code: 503"))
+		Expect(ai.IsRetryable(err)).To(BeTrue())
+	})
+
+	It("repeats a single-completion client", func() {
+		client := staticClient("kind: Pod")
+		choices, err := ai.Repeat(client, 3, 2).Generate(context.Background())
+		Expect(err).NotTo(HaveOccurred())
+		Expect(choices).To(Equal([]string{"kind: Pod", "kind: Pod", "kind: Pod"}))
+	})
+})
@@ -0,0 +1,92 @@
+package ai
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// RateLimiter Spaces out requests so that at most Rate of them start every second.
+// It's a token bucket holding a single token, which is shared by every request made through it,
+// so requests made concurrently by GenerateConcurrently wait for their turn.
+type RateLimiter struct {
+	// Rate Is the number of requests allowed per second, the limiter doesn't wait when it isn't positive.
+	Rate float64
+	// Now Returns the current time, defaults to time.Now.
+	Now func() time.Time
+	// Sleep Waits for the delay to pass, defaults to waiting until the delay passes or the context is done.
+	Sleep func(ctx context.Context, delay time.Duration)
+
+	mu sync.Mutex
+	// next Is the time at which the next request may start.
+	next time.Time
+}
+
+// NewRateLimiter Returns a limiter which allows rate requests per second.
+func NewRateLimiter(rate float64) *RateLimiter {
+	return &RateLimiter{Rate: rate}
+}
+
+// Interval Returns the time which must pass between the start of two requests.
+func (l *RateLimiter) Interval() time.Duration {
+	if l.Rate <= 0 {
+		return 0
+	}
+	return time.Duration(float64(time.Second) / l.Rate)
+}
+
+// Wait Blocks until a request may be made, taking the turn of the request.
+// The context's error is returned when it's done before the turn comes.
+func (l *RateLimiter) Wait(ctx context.Context) error {
+	interval := l.Interval()
+	if interval == 0 {
+		return ctx.Err()
+	}
+	now := time.Now
+	if l.Now != nil {
+		now = l.Now
+	}
+	sleep := sleepContext
+	if l.Sleep != nil {
+		sleep = l.Sleep
+	}
+
+	// reserve the next turn, so that concurrent requests queue up behind each other
+	l.mu.Lock()
+	start := now()
+	if l.next.After(start) {
+		start = l.next
+	}
+	l.next = start.Add(interval)
+	delay := start.Sub(now())
+	l.mu.Unlock()
+
+	if delay > 0 {
+		sleep(ctx, delay)
+	}
+	return ctx.Err()
+}
+
+// RateLimitTransport Returns a transport which waits for the limiter before every request.
+// A nil transport stands for http.DefaultTransport.
+func RateLimitTransport(limiter *RateLimiter, transport http.RoundTripper) http.RoundTripper {
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	return rateLimitedTransport{limiter: limiter, transport: transport}
+}
+
+// rateLimitedTransport Waits for the limiter before making each request with the transport.
+type rateLimitedTransport struct {
+	limiter   *RateLimiter
+	transport http.RoundTripper
+}
+
+// RoundTrip Makes the request once the limiter allows it.
+func (t rateLimitedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if err := t.limiter.Wait(req.Context()); err != nil {
+		return nil, err
+	}
+	return t.transport.RoundTrip(req)
+}
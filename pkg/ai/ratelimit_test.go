@@ -0,0 +1,134 @@
+package ai_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/redhat-et/copilot-ops/pkg/ai"
+)
+
+// fakeClock Is a clock which only moves when it's slept on, recording when every sleep ends.
+type fakeClock struct {
+	mu      sync.Mutex
+	now     time.Time
+	wakeups []time.Duration
+	start   time.Time
+}
+
+func newFakeClock() *fakeClock {
+	start := time.Date(2022, 7, 1, 12, 0, 0, 0, time.UTC)
+	return &fakeClock{now: start, start: start}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// Sleep Advances the clock by the delay.
+func (c *fakeClock) Sleep(_ context.Context, delay time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(delay)
+	c.wakeups = append(c.wakeups, c.now.Sub(c.start))
+}
+
+// Delays Records the delay without moving the clock, as if every sleep started at once.
+func (c *fakeClock) Delays(_ context.Context, delay time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.wakeups = append(c.wakeups, delay)
+}
+
+var _ = Describe("RateLimiter", func() {
+	var clock *fakeClock
+	var limiter *ai.RateLimiter
+
+	BeforeEach(func() {
+		clock = newFakeClock()
+		limiter = ai.NewRateLimiter(4)
+		limiter.Now = clock.Now
+		limiter.Sleep = clock.Sleep
+	})
+
+	It("spaces requests according to the rate", func() {
+		Expect(limiter.Interval()).To(Equal(250 * time.Millisecond))
+		for i := 0; i < 4; i++ {
+			Expect(limiter.Wait(context.Background())).To(Succeed())
+		}
+		// the first request doesn't wait
+		Expect(clock.wakeups).To(Equal([]time.Duration{
+			250 * time.Millisecond, 500 * time.Millisecond, 750 * time.Millisecond,
+		}))
+	})
+
+	It("doesn't wait once the interval has passed", func() {
+		Expect(limiter.Wait(context.Background())).To(Succeed())
+		clock.Sleep(context.Background(), time.Second)
+		clock.wakeups = nil
+		Expect(limiter.Wait(context.Background())).To(Succeed())
+		Expect(clock.wakeups).To(BeEmpty())
+	})
+
+	It("never waits without a rate", func() {
+		limiter.Rate = 0
+		for i := 0; i < 3; i++ {
+			Expect(limiter.Wait(context.Background())).To(Succeed())
+		}
+		Expect(clock.wakeups).To(BeEmpty())
+	})
+
+	It("queues up requests made concurrently", func() {
+		// every request reserves its turn before any of them sleeps
+		limiter.Sleep = clock.Delays
+		var requests int32
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			atomic.AddInt32(&requests, 1)
+		}))
+		DeferCleanup(server.Close)
+		client := &http.Client{Transport: ai.RateLimitTransport(limiter, nil)}
+
+		_, err := ai.GenerateConcurrently(5, ai.DefaultConcurrency, func(int) ([]string, error) {
+			res, err := client.Get(server.URL)
+			if err != nil {
+				return nil, err
+			}
+			res.Body.Close()
+			return []string{res.Status}, nil
+		})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(requests).To(BeEquivalentTo(5))
+
+		delays := clock.wakeups
+		sort.Slice(delays, func(i, j int) bool { return delays[i] < delays[j] })
+		Expect(delays).To(Equal([]time.Duration{
+			250 * time.Millisecond, 500 * time.Millisecond, 750 * time.Millisecond, time.Second,
+		}))
+	})
+
+	It("doesn't make the request once the context is done", func() {
+		var requests int32
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			atomic.AddInt32(&requests, 1)
+		}))
+		DeferCleanup(server.Close)
+		client := &http.Client{Transport: ai.RateLimitTransport(limiter, nil)}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, server.URL, nil)
+		Expect(err).NotTo(HaveOccurred())
+		_, err = client.Do(req) //nolint:bodyclose // the request is never made
+		Expect(err).To(MatchError(context.Canceled))
+		Expect(requests).To(BeZero())
+	})
+})
@@ -0,0 +1,22 @@
+package ai
+
+import (
+	"regexp"
+	"strings"
+)
+
+// StripReasoning Removes the reasoning trace which reasoning-capable models emit
+// before their answer, written as <think>...</think> or <reasoning>...</reasoning>.
+// A trace which was never closed is assumed to have consumed the rest of the output.
+func StripReasoning(output string) string {
+	closedTrace := regexp.MustCompile(`(?s)<(think|thinking|reasoning)>.*?</(think|thinking|reasoning)>`)
+	stripped := closedTrace.ReplaceAllString(output, "")
+
+	openTrace := regexp.MustCompile(`(?s)<(think|thinking|reasoning)>.*$`)
+	stripped = openTrace.ReplaceAllString(stripped, "")
+
+	if stripped == output {
+		return output
+	}
+	return strings.TrimLeft(stripped, "\n")
+}
@@ -0,0 +1,23 @@
+package ai_test
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/redhat-et/copilot-ops/pkg/ai"
+)
+
+var _ = Describe("Reasoning", func() {
+	It("strips the reasoning trace", func() {
+		output := ai.StripReasoning("<think>\nthe user wants a pod\n</think>\n# @pod.yaml\nkind: Pod\n")
+		Expect(output).To(Equal("# @pod.yaml\nkind: Pod\n"))
+	})
+
+	It("strips an unterminated reasoning trace", func() {
+		Expect(ai.StripReasoning("kind: Pod\n<reasoning>ran out of tokens")).To(Equal("kind: Pod\n"))
+	})
+
+	It("leaves other output alone", func() {
+		Expect(ai.StripReasoning("\n# @pod.yaml\nkind: Pod\n")).To(Equal("\n# @pod.yaml\nkind: Pod\n"))
+	})
+})
@@ -0,0 +1,205 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net"
+	"net/http"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/redhat-et/copilot-ops/pkg/logger"
+	"github.com/redhat-et/copilot-ops/pkg/utils"
+)
+
+const (
+	// DefaultMaxRetries Is the number of times a failed request is retried by default.
+	DefaultMaxRetries = 3
+	// DefaultRetryBaseDelay Is the delay before the first retry, which doubles with every attempt.
+	DefaultRetryBaseDelay = time.Second
+	// MaxRetryDelay Caps the delay between two attempts. When the backend asks to wait longer than this
+	// with a Retry-After header, the request isn't retried.
+	MaxRetryDelay = 30 * time.Second
+)
+
+// RetryOptions Configures how failed requests to a backend are retried.
+type RetryOptions struct {
+	// MaxRetries Is the number of times a request is retried after the first attempt fails.
+	MaxRetries int
+	// BaseDelay Is the delay before the first retry, it doubles with every following attempt.
+	BaseDelay time.Duration
+	// Sleep Waits between attempts, defaults to waiting until the delay passes or the context is done.
+	Sleep func(time.Duration)
+}
+
+// RetryGenerate Calls Generate on the client, retrying with exponential backoff and
+// jitter when the backend fails with a transient error. When the backend says how long
+// to wait with a Retry-After header, that delay is used instead, unless it's longer than
+// MaxRetryDelay, in which case the error is returned.
+// Errors which aren't transient are returned immediately, as is the context's error once it's done.
+func RetryGenerate(ctx context.Context, client GenerateClient, opts RetryOptions) ([]string, error) {
+	return retry(ctx, opts, func() ([]string, bool, error) {
+		choices, err := client.Generate(ctx)
+		return choices, true, err
+	})
+}
+
+// RetryGenerateStream Calls GenerateStream on the client, retrying like RetryGenerate as long as the
+// stream failed before its first chunk. Once a chunk was passed to onChunk the error is returned
+// as is, since a retry would pass the same text to onChunk again.
+func RetryGenerateStream(
+	ctx context.Context, client StreamingGenerateClient, onChunk func(chunk string), opts RetryOptions,
+) ([]string, error) {
+	return retry(ctx, opts, func() ([]string, bool, error) {
+		started := false
+		choices, err := client.GenerateStream(ctx, func(chunk string) {
+			started = true
+			if onChunk != nil {
+				onChunk(chunk)
+			}
+		})
+		return choices, !started, err
+	})
+}
+
+// retry Makes attempts until one succeeds, fails with an error which isn't transient, or says it can't
+// be retried, waiting between them as RetryGenerate describes.
+func retry(ctx context.Context, opts RetryOptions, attempt func() ([]string, bool, error)) ([]string, error) {
+	sleep := opts.Sleep
+	if sleep == nil {
+		sleep = func(delay time.Duration) {
+			sleepContext(ctx, delay)
+		}
+	}
+
+	var err error
+	for i := 0; ; i++ {
+		var choices []string
+		var retryable bool
+		choices, retryable, err = attempt()
+		if err == nil {
+			return choices, nil
+		}
+		if !retryable {
+			return nil, err
+		}
+		if ctx.Err() != nil || !IsRetryable(err) || i >= opts.MaxRetries {
+			break
+		}
+		// the server knows best when it can take the request again
+		delay, ok := RetryAfter(err)
+		if !ok {
+			delay = backoff(opts.BaseDelay, i)
+		} else if delay > MaxRetryDelay {
+			return nil, fmt.Errorf("not retrying, the backend asked to wait %s, more than %s: %w", delay, MaxRetryDelay, err)
+		}
+		logger.Warnf("attempt %d failed: %s, retrying in %s\n", i+1, err, delay)
+		sleep(delay)
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+	}
+	if opts.MaxRetries > 0 && IsRetryable(err) {
+		return nil, fmt.Errorf("giving up after %d retries: %w", opts.MaxRetries, err)
+	}
+	return nil, err
+}
+
+// IsRetryable Reports whether the error was caused by a transient failure, such as
+// the backend being rate-limited (429), unavailable (5xx), or a network timeout.
+// Requests which were canceled, or whose deadline passed, are never retried.
+func IsRetryable(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+	code, ok := StatusCode(err)
+	if !ok {
+		return false
+	}
+	return code == http.StatusTooManyRequests || code >= http.StatusInternalServerError
+}
+
+// IsUnavailable Reports whether the backend couldn't serve the request, either because of a transient
+// failure which IsRetryable reports, or because it couldn't be reached at all.
+func IsUnavailable(err error) bool {
+	if IsRetryable(err) {
+		return true
+	}
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && opErr.Op == "dial"
+}
+
+// RetryAfter Returns how long the backend asked to wait before retrying with a Retry-After header,
+// and whether it did. When the error joins several errors, such as GenerateErrors, the longest
+// delay any of them asked for is returned.
+func RetryAfter(err error) (time.Duration, bool) {
+	// errors.As doesn't look into joined errors before Go 1.20
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		var longest time.Duration
+		found := false
+		for _, e := range joined.Unwrap() {
+			if delay, ok := RetryAfter(e); ok && (!found || delay > longest) {
+				longest, found = delay, true
+			}
+		}
+		return longest, found
+	}
+	var statusErr *utils.StatusError
+	if !errors.As(err, &statusErr) || !statusErr.HasRetryAfter {
+		return 0, false
+	}
+	return statusErr.RetryAfter, true
+}
+
+// StatusCode Extracts the HTTP status code from an error returned by one of the backends,
+// which all report failed requests as 'status code: <code>'.
+func StatusCode(err error) (int, bool) {
+	re := regexp.MustCompile(`status code: (\d{3})`)
+	match := re.FindStringSubmatch(err.Error())
+	if match == nil {
+		return 0, false
+	}
+	code, convErr := strconv.Atoi(match[1])
+	if convErr != nil {
+		return 0, false
+	}
+	return code, true
+}
+
+// backoff Returns the delay before the given retry, doubling the base delay with
+// every attempt and picking a random delay between half and all of it.
+func backoff(base time.Duration, attempt int) time.Duration {
+	if base <= 0 {
+		return 0
+	}
+	delay := base << attempt
+	if delay <= 0 || delay > MaxRetryDelay {
+		delay = MaxRetryDelay
+	}
+	half := delay / 2 //nolint:gomnd // jitter covers the upper half of the delay
+	if half <= 0 {
+		return delay
+	}
+	//nolint:gosec // jitter doesn't need a secure source of randomness
+	return half + time.Duration(rand.Int63n(int64(half)+1))
+}
+
+// sleepContext Waits for the delay to pass, returning early once the context is done.
+func sleepContext(ctx context.Context, delay time.Duration) {
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+	case <-timer.C:
+	}
+}
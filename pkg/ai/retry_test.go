@@ -0,0 +1,324 @@
+package ai_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/redhat-et/copilot-ops/pkg/ai"
+	"github.com/redhat-et/copilot-ops/pkg/ai/gpt3"
+	"github.com/redhat-et/copilot-ops/pkg/utils"
+)
+
+// flakyClient Fails with the given error a number of times before succeeding.
+type flakyClient struct {
+	failures int
+	err      error
+	calls    int
+}
+
+func (c *flakyClient) Generate(context.Context) ([]string, error) {
+	c.calls++
+	if c.calls <= c.failures {
+		return nil, c.err
+	}
+	return []string{"kind: Pod"}, nil
+}
+
+var _ = Describe("RetryGenerate", func() {
+	var delays []time.Duration
+	var opts ai.RetryOptions
+
+	BeforeEach(func() {
+		delays = nil
+		opts = ai.RetryOptions{
+			MaxRetries: 3,
+			BaseDelay:  time.Second,
+			Sleep: func(d time.Duration) {
+				delays = append(delays, d)
+			},
+		}
+	})
+
+	It("retries transient errors until the request succeeds", func() {
+		client := &flakyClient{failures: 2, err: fmt.Errorf("error, status code: 429")}
+		choices, err := ai.RetryGenerate(context.Background(), client, opts)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(choices).To(Equal([]string{"kind: Pod"}))
+		Expect(client.calls).To(Equal(3))
+		Expect(delays).To(HaveLen(2))
+		Expect(delays[0]).To(BeNumerically(">=", 500*time.Millisecond))
+		Expect(delays[0]).To(BeNumerically("<=", time.Second))
+		Expect(delays[1]).To(BeNumerically(">=", time.Second))
+		Expect(delays[1]).To(BeNumerically("<=", 2*time.Second))
+	})
+
+	It("gives up once the retries are exhausted", func() {
+		client := &flakyClient{failures: 10, err: fmt.Errorf("error, status code: 503")}
+		choices, err := ai.RetryGenerate(context.Background(), client, opts)
+		Expect(err).To(MatchError(ContainSubstring("giving up after 3 retries")))
+		Expect(choices).To(BeEmpty())
+		Expect(client.calls).To(Equal(4))
+	})
+
+	It("fails fast on errors which aren't transient", func() {
+		for _, code := range []int{400, 401} {
+			client := &flakyClient{failures: 1, err: fmt.Errorf("error, status code: %d", code)}
+			_, err := ai.RetryGenerate(context.Background(), client, opts)
+			Expect(err).To(MatchError(fmt.Sprintf("error, status code: %d", code)))
+			Expect(client.calls).To(Equal(1))
+		}
+		Expect(delays).To(BeEmpty())
+	})
+
+	It("doesn't retry when retries are disabled", func() {
+		opts.MaxRetries = 0
+		client := &flakyClient{failures: 1, err: fmt.Errorf("error, status code: 500")}
+		_, err := ai.RetryGenerate(context.Background(), client, opts)
+		Expect(err).To(MatchError("error, status code: 500"))
+		Expect(client.calls).To(Equal(1))
+	})
+
+	It("stops retrying once the context is done", func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		opts.Sleep = func(time.Duration) {
+			cancel()
+		}
+		client := &flakyClient{failures: 10, err: fmt.Errorf("error, status code: 503")}
+		_, err := ai.RetryGenerate(ctx, client, opts)
+		Expect(err).To(MatchError(context.Canceled))
+		Expect(client.calls).To(Equal(1))
+	})
+
+	It("waits for the delay to pass unless the context is done", func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		opts.Sleep = nil
+		opts.BaseDelay = time.Minute
+		client := &flakyClient{failures: 10, err: fmt.Errorf("error, status code: 503")}
+		start := time.Now()
+		_, err := ai.RetryGenerate(ctx, client, opts)
+		Expect(err).To(MatchError(context.DeadlineExceeded))
+		Expect(time.Since(start)).To(BeNumerically("<", 5*time.Second))
+	})
+})
+
+// brokenStream Streams a chunk and then fails with a transient error.
+type brokenStream struct {
+	flakyClient
+}
+
+func (c *brokenStream) GenerateStream(_ context.Context, onChunk func(chunk string)) ([]string, error) {
+	c.calls++
+	onChunk("kind")
+	return nil, fmt.Errorf("error, status code: 502")
+}
+
+var _ = Describe("RetryGenerateStream", func() {
+	var delays []time.Duration
+	var opts ai.RetryOptions
+
+	BeforeEach(func() {
+		delays = nil
+		opts = ai.RetryOptions{
+			MaxRetries: 3,
+			BaseDelay:  time.Millisecond,
+			Sleep: func(d time.Duration) {
+				delays = append(delays, d)
+			},
+		}
+	})
+
+	It("retries a stream which is rate-limited before its first chunk", func() {
+		var calls int32
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if atomic.AddInt32(&calls, 1) == 1 {
+				w.Header().Set("Retry-After", "2")
+				http.Error(w, `{"error": {"message": "rate limited"}}`, http.StatusTooManyRequests)
+				return
+			}
+			w.Header().Set("Content-Type", "text/event-stream")
+			for _, chunk := range []string{"kind", ":", " Pod"} {
+				fmt.Fprintf(w, "data: {\"choices\": [{\"text\": %q, \"index\": 0}]}\n\n", chunk)
+			}
+			fmt.Fprint(w, "data: [DONE]\n\n")
+		}))
+		DeferCleanup(server.Close)
+		client := gpt3.CreateGPT3GenerateClient(
+			gpt3.Config{APIKey: "abc", BaseURL: server.URL}, "create a pod", 256, 1, 0, nil,
+		)
+		streamer, ok := client.(ai.StreamingGenerateClient)
+		Expect(ok).To(BeTrue())
+
+		var chunks []string
+		choices, err := ai.RetryGenerateStream(context.Background(), streamer, func(chunk string) {
+			chunks = append(chunks, chunk)
+		}, opts)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(choices).To(Equal([]string{"kind: Pod"}))
+		Expect(chunks).To(Equal([]string{"kind", ":", " Pod"}))
+		Expect(atomic.LoadInt32(&calls)).To(BeEquivalentTo(2))
+		Expect(delays).To(Equal([]time.Duration{2 * time.Second}))
+	})
+
+	It("doesn't retry a stream which fails after its first chunk", func() {
+		client := &brokenStream{}
+		var chunks []string
+		_, err := ai.RetryGenerateStream(context.Background(), client, func(chunk string) {
+			chunks = append(chunks, chunk)
+		}, opts)
+		Expect(err).To(MatchError("error, status code: 502"))
+		Expect(client.calls).To(Equal(1))
+		Expect(chunks).To(Equal([]string{"kind"}))
+		Expect(delays).To(BeEmpty())
+	})
+})
+
+var _ = Describe("Retry-After", func() {
+	var retryAfter string
+	var server *httptest.Server
+	var delays []time.Duration
+	var opts ai.RetryOptions
+
+	BeforeEach(func() {
+		delays = nil
+		opts = ai.RetryOptions{
+			MaxRetries: 3,
+			BaseDelay:  time.Millisecond,
+			Sleep: func(d time.Duration) {
+				delays = append(delays, d)
+			},
+		}
+		var calls int32
+		// an OpenAI stub which is rate-limited on the first request
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if atomic.AddInt32(&calls, 1) == 1 {
+				if retryAfter != "" {
+					w.Header().Set("Retry-After", retryAfter)
+				}
+				w.WriteHeader(http.StatusTooManyRequests)
+				return
+			}
+			// answers both the chat and the completions API
+			_, _ = w.Write([]byte(`{"choices": [{"index": 0, "text": "kind: Pod", ` +
+				`"message": {"role": "assistant", "content": "kind: Pod"}}]}`))
+		}))
+		DeferCleanup(server.Close)
+	})
+
+	generateWith := func(model string) {
+		client := gpt3.CreateGPT3GenerateClient(
+			gpt3.Config{APIKey: "abc", BaseURL: server.URL, Model: model}, "create a pod", 256, 1, 0, nil,
+		)
+		choices, err := ai.RetryGenerate(context.Background(), client, opts)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(choices).To(Equal([]string{"kind: Pod"}))
+	}
+	generate := func() {
+		generateWith("gpt-4o")
+	}
+
+	It("waits for as many seconds as the backend asks", func() {
+		retryAfter = "7"
+		generate()
+		Expect(delays).To(Equal([]time.Duration{7 * time.Second}))
+	})
+
+	It("waits for as many seconds as the completions API asks", func() {
+		retryAfter = "7"
+		generateWith(gpt3.OpenAICodeDavinciV2)
+		Expect(delays).To(Equal([]time.Duration{7 * time.Second}))
+	})
+
+	It("waits for the longest delay any of the concurrent requests asks for", func() {
+		err := ai.GenerateErrors{
+			&utils.StatusError{StatusCode: http.StatusTooManyRequests, RetryAfter: 3 * time.Second, HasRetryAfter: true},
+			fmt.Errorf("error, status code: 503"),
+			fmt.Errorf("wrapped: %w", &utils.StatusError{
+				StatusCode: http.StatusTooManyRequests, RetryAfter: 9 * time.Second, HasRetryAfter: true,
+			}),
+		}
+		Expect(err.Unwrap()).To(HaveLen(3))
+		delay, ok := ai.RetryAfter(err)
+		Expect(ok).To(BeTrue())
+		Expect(delay).To(Equal(9 * time.Second))
+	})
+
+	It("gives up when the backend asks to wait too long", func() {
+		retryAfter = "86400"
+		client := gpt3.CreateGPT3GenerateClient(
+			gpt3.Config{APIKey: "abc", BaseURL: server.URL, Model: "gpt-4o"}, "create a pod", 256, 1, 0, nil,
+		)
+		_, err := ai.RetryGenerate(context.Background(), client, opts)
+		Expect(err).To(MatchError(ContainSubstring("the backend asked to wait 24h0m0s")))
+		code, ok := ai.StatusCode(err)
+		Expect(ok).To(BeTrue())
+		Expect(code).To(Equal(http.StatusTooManyRequests))
+		Expect(delays).To(BeEmpty())
+	})
+
+	It("waits until the date the backend asks for", func() {
+		retryAfter = time.Now().Add(20 * time.Second).UTC().Format(http.TimeFormat)
+		generate()
+		Expect(delays).To(HaveLen(1))
+		// the date is only precise to the second
+		Expect(delays[0]).To(BeNumerically("~", 20*time.Second, time.Second))
+	})
+
+	It("backs off as usual without the header", func() {
+		retryAfter = ""
+		generate()
+		Expect(delays).To(HaveLen(1))
+		Expect(delays[0]).To(BeNumerically("<=", time.Millisecond))
+	})
+
+	It("parses both forms of the header", func() {
+		now := time.Date(2024, time.May, 1, 12, 0, 0, 0, time.UTC)
+		for value, expected := range map[string]time.Duration{
+			"120":                           2 * time.Minute,
+			"0":                             0,
+			"Wed, 01 May 2024 12:00:30 GMT": 30 * time.Second,
+			"Wed, 01 May 2024 11:00:00 GMT": 0,
+		} {
+			delay, ok := utils.ParseRetryAfter(value, now)
+			Expect(ok).To(BeTrue(), value)
+			Expect(delay).To(Equal(expected), value)
+		}
+		for _, value := range []string{"", "-1", "soon"} {
+			_, ok := utils.ParseRetryAfter(value, now)
+			Expect(ok).To(BeFalse(), value)
+		}
+	})
+})
+
+var _ = Describe("IsRetryable", func() {
+	It("detects transient status codes", func() {
+		Expect(ai.IsRetryable(fmt.Errorf("error, status code: 429, message: rate limited"))).To(BeTrue())
+		Expect(ai.IsRetryable(fmt.Errorf("error, status code: 502"))).To(BeTrue())
+		Expect(ai.IsRetryable(fmt.Errorf("error, status code: 404"))).To(BeFalse())
+		Expect(ai.IsRetryable(fmt.Errorf("could not read response"))).To(BeFalse())
+	})
+
+	It("never retries canceled requests", func() {
+		Expect(ai.IsRetryable(fmt.Errorf("error making request: %w", context.Canceled))).To(BeFalse())
+		Expect(ai.IsRetryable(fmt.Errorf("error making request: %w", context.DeadlineExceeded))).To(BeFalse())
+	})
+})
+
+var _ = Describe("IsUnavailable", func() {
+	It("detects backends which fail transiently or can't be reached", func() {
+		Expect(ai.IsUnavailable(fmt.Errorf("error, status code: 503"))).To(BeTrue())
+		dialErr := &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}
+		Expect(ai.IsUnavailable(fmt.Errorf("error making request: %w", dialErr))).To(BeTrue())
+		Expect(ai.IsUnavailable(fmt.Errorf("error, status code: 400"))).To(BeFalse())
+		Expect(ai.IsUnavailable(fmt.Errorf("error making request: %w", context.Canceled))).To(BeFalse())
+	})
+})
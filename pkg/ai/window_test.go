@@ -0,0 +1,31 @@
+package ai_test
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/redhat-et/copilot-ops/pkg/ai"
+)
+
+var _ = Describe("Context window", func() {
+	DescribeTable("depends on the model",
+		func(backend ai.Backend, model string, window int) {
+			Expect(ai.ContextWindow(backend, model)).To(Equal(window))
+		},
+		Entry("code-davinci-002", ai.GPT3, "code-davinci-002", 8001),
+		Entry("gpt-4o", ai.GPT3, "gpt-4o-mini", 128000),
+		Entry("gpt-4", ai.GPT3, "gpt-4", 8192),
+		Entry("gpt-4-32k", ai.GPT3, "gpt-4-32k", 32768),
+		Entry("o1-mini", ai.GPT3, "o1-mini", 128000),
+		Entry("o3-mini", ai.GPT3, "o3-mini", 200000),
+		Entry("an unknown OpenAI model", ai.GPT3, "my-fine-tune", 0),
+		Entry("claude", ai.CLAUDE, "claude-sonnet-4-5", 200000),
+		Entry("claude 2", ai.CLAUDE, "claude-2.1", 100000),
+		Entry("command", ai.COHERE, "command", 4096),
+		Entry("command-r", ai.COHERE, "command-r-plus", 128000),
+		Entry("gemini flash", ai.GEMINI, "gemini-2.5-flash", 1048576),
+		Entry("gemini 1.5 pro", ai.GEMINI, "gemini-1.5-pro", 2097152),
+		Entry("gpt-j", ai.GPTJ, "", 2048),
+		Entry("ollama", ai.OLLAMA, "codellama", 0),
+	)
+})
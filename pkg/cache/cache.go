@@ -0,0 +1,87 @@
+// cache Stores the completions returned by the backends on disk, so that
+// repeating an identical request doesn't make another call to the backend.
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// DefaultTTL Is how long cached completions are used for unless configured otherwise.
+const DefaultTTL = 24 * time.Hour
+
+// Entry Is a set of completions stored in the cache.
+type Entry struct {
+	// CreatedAt Is when the completions were stored.
+	CreatedAt time.Time `json:"createdAt"`
+	// Choices Are the raw completions returned by the backend.
+	Choices []string `json:"choices"`
+}
+
+// Store Reads and writes cache entries as files in a directory.
+type Store struct {
+	// Dir Is the directory which the entries are stored in.
+	Dir string
+	// TTL Is how long an entry is used for after it was stored. Entries never expire when it isn't positive.
+	TTL time.Duration
+	// Now Returns the current time, defaulting to time.Now.
+	Now func() time.Time
+}
+
+// Key Hashes everything which determines the completions of a request into a key for the cache.
+// The value must be encodable as JSON.
+func Key(value interface{}) (string, error) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return "", fmt.Errorf("could not compute the cache key: %w", err)
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// Load Returns the completions stored under the key, and whether they were found.
+// Entries which have expired or can't be read are treated as missing.
+func (s *Store) Load(key string) ([]string, bool) {
+	data, err := os.ReadFile(s.path(key))
+	if err != nil {
+		return nil, false
+	}
+	entry := Entry{}
+	if err = json.Unmarshal(data, &entry); err != nil {
+		return nil, false
+	}
+	if s.TTL > 0 && s.now().Sub(entry.CreatedAt) > s.TTL {
+		return nil, false
+	}
+	return entry.Choices, true
+}
+
+// Save Stores the completions under the key, replacing any existing entry.
+func (s *Store) Save(key string, choices []string) error {
+	if err := os.MkdirAll(s.Dir, 0755); err != nil {
+		return err
+	}
+	data, err := json.Marshal(Entry{CreatedAt: s.now().UTC(), Choices: choices})
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(s.path(key), data, 0600)
+}
+
+// path Returns the path of the file which stores the entry for the key.
+func (s *Store) path(key string) string {
+	return filepath.Join(s.Dir, key+".json")
+}
+
+// now Returns the current time.
+func (s *Store) now() time.Time {
+	if s.Now != nil {
+		return s.Now()
+	}
+	return time.Now()
+}
@@ -0,0 +1,65 @@
+package cache_test
+
+import (
+	"os"
+	"path/filepath"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/redhat-et/copilot-ops/pkg/cache"
+)
+
+var _ = Describe("Cache", func() {
+	var store *cache.Store
+	var now time.Time
+
+	BeforeEach(func() {
+		now = time.Date(2022, time.September, 1, 12, 0, 0, 0, time.UTC)
+		store = &cache.Store{
+			Dir: filepath.Join(GinkgoT().TempDir(), "cache"),
+			TTL: time.Hour,
+			Now: func() time.Time { return now },
+		}
+	})
+
+	It("derives the same key from the same values", func() {
+		first, err := cache.Key(map[string]string{"prompt": "create a pod"})
+		Expect(err).NotTo(HaveOccurred())
+		second, err := cache.Key(map[string]string{"prompt": "create a pod"})
+		Expect(err).NotTo(HaveOccurred())
+		other, err := cache.Key(map[string]string{"prompt": "create a service"})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(first).To(Equal(second))
+		Expect(first).NotTo(Equal(other))
+	})
+
+	It("loads the saved completions", func() {
+		_, ok := store.Load("key")
+		Expect(ok).To(BeFalse())
+
+		Expect(store.Save("key", []string{"kind: Pod"})).To(Succeed())
+		choices, ok := store.Load("key")
+		Expect(ok).To(BeTrue())
+		Expect(choices).To(Equal([]string{"kind: Pod"}))
+	})
+
+	It("expires entries after the TTL", func() {
+		Expect(store.Save("key", []string{"kind: Pod"})).To(Succeed())
+		now = now.Add(2 * time.Hour)
+		_, ok := store.Load("key")
+		Expect(ok).To(BeFalse())
+
+		store.TTL = 0
+		_, ok = store.Load("key")
+		Expect(ok).To(BeTrue())
+	})
+
+	It("ignores corrupt entries", func() {
+		Expect(os.MkdirAll(store.Dir, 0755)).To(Succeed())
+		Expect(os.WriteFile(filepath.Join(store.Dir, "key.json"), []byte("{"), 0600)).To(Succeed())
+		_, ok := store.Load("key")
+		Expect(ok).To(BeFalse())
+	})
+})
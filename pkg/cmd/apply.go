@@ -0,0 +1,94 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os/exec"
+	"sort"
+	"strings"
+
+	"github.com/redhat-et/copilot-ops/pkg/filemap"
+	"github.com/redhat-et/copilot-ops/pkg/kustomize"
+	"github.com/redhat-et/copilot-ops/pkg/logger"
+)
+
+// Kubectl Is the program which applies the generated manifests with --apply.
+const Kubectl = "kubectl"
+
+// CommandRunner Runs the named program with the arguments, streaming everything it prints to out.
+type CommandRunner func(ctx context.Context, out io.Writer, name string, args ...string) error
+
+// ExecCommand Is the CommandRunner which runs the program found on the PATH.
+func ExecCommand(ctx context.Context, out io.Writer, name string, args ...string) error {
+	path, err := exec.LookPath(name)
+	if err != nil {
+		return fmt.Errorf("%s must be installed and on your PATH: %w", name, err)
+	}
+	command := exec.CommandContext(ctx, path, args...)
+	command.Stdout = out
+	command.Stderr = out
+	return command.Run()
+}
+
+// KubectlApplyArgs Returns the arguments of `kubectl apply` for the paths, setting the namespace
+// and the kube context when they're given.
+func KubectlApplyArgs(paths []string, namespace, kubeContext string) []string {
+	args := []string{"apply"}
+	if kubeContext != "" {
+		args = append(args, "--context", kubeContext)
+	}
+	if namespace != "" {
+		args = append(args, "--namespace", namespace)
+	}
+	for _, path := range paths {
+		args = append(args, "-f", path)
+	}
+	return args
+}
+
+// ApplyManifests Runs `kubectl apply` over the written YAML files when --apply is given, streaming
+// kubectl's output to the request's ErrOut. Nothing is applied when the completions couldn't be decoded
+// or when any of the files isn't a valid Kubernetes manifest. Kustomizations are left out, since
+// they aren't resources themselves.
+func ApplyManifests(ctx context.Context, r *Request) error {
+	if !r.Apply {
+		return nil
+	}
+	if r.DecodeError != "" {
+		return fmt.Errorf("the files were written, but not applied since the output couldn't be decoded: %s",
+			r.DecodeError)
+	}
+	if errs := r.Filemap.Validate(); len(errs) > 0 {
+		problems := make([]string, 0, len(errs))
+		for _, err := range errs {
+			problems = append(problems, err.Error())
+		}
+		return fmt.Errorf("the files were written, but not applied since they aren't valid manifests: %s",
+			strings.Join(problems, "; "))
+	}
+
+	var paths []string
+	for tag, file := range r.Filemap.Files {
+		if file.URL != "" || file.FileType(tag) != filemap.FileTypeYAML || kustomize.IsKustomization(file.Path) {
+			continue
+		}
+		paths = append(paths, file.Path)
+	}
+	if len(paths) == 0 {
+		logger.Warnf("no manifests were written, so there's nothing to apply\n")
+		return nil
+	}
+	sort.Strings(paths)
+
+	run := r.RunCommand
+	if run == nil {
+		run = ExecCommand
+	}
+	args := KubectlApplyArgs(paths, r.Namespace, r.KubeContext)
+	logger.Infof("running %s %s\n", Kubectl, strings.Join(args, " "))
+	if err := run(ctx, r.ErrOut, Kubectl, args...); err != nil {
+		return fmt.Errorf("the files were written, but could not be applied: %w", err)
+	}
+	return nil
+}
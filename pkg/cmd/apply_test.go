@@ -0,0 +1,109 @@
+package cmd_test
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/redhat-et/copilot-ops/pkg/cmd"
+	"github.com/redhat-et/copilot-ops/pkg/filemap"
+)
+
+var _ = Describe("Apply", func() {
+	var r *cmd.Request
+	var out *bytes.Buffer
+	var calls [][]string
+
+	BeforeEach(func() {
+		wd, err := os.Getwd()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(os.Chdir(GinkgoT().TempDir())).To(Succeed())
+		DeferCleanup(os.Chdir, wd)
+
+		fm := filemap.NewFilemap()
+		fm.Files["svc"] = filemap.File{Path: "manifests/svc.yaml", Content: "apiVersion: v1\nkind: Service\n"}
+		fm.Files["pod"] = filemap.File{Path: "manifests/pod.yaml", Content: "apiVersion: v1\nkind: Pod\n"}
+		fm.Files["readme"] = filemap.File{Path: "manifests/README.md", Content: "# manifests\n"}
+		out = &bytes.Buffer{}
+		calls = nil
+		r = &cmd.Request{Filemap: fm, IsWrite: true, Apply: true, ErrOut: out,
+			RunCommand: func(_ context.Context, w io.Writer, name string, args ...string) error {
+				calls = append(calls, append([]string{name}, args...))
+				fmt.Fprintln(w, "pod/web created")
+				return nil
+			},
+		}
+	})
+
+	It("applies the written manifests in the namespace and kube context", func() {
+		r.Namespace = "staging"
+		r.KubeContext = "kind-dev"
+		Expect(cmd.PrintOrWriteOut(context.Background(), r)).To(Succeed())
+
+		Expect(calls).To(Equal([][]string{{cmd.Kubectl, "apply", "--context", "kind-dev", "--namespace", "staging",
+			"-f", "manifests/pod.yaml", "-f", "manifests/svc.yaml"}}))
+		Expect(out.String()).To(Equal("pod/web created\n"))
+		Expect("manifests/pod.yaml").To(BeARegularFile())
+	})
+
+	It("leaves out kustomizations", func() {
+		r.Filemap.Files["kustomization"] = filemap.File{Path: "manifests/kustomization.yaml",
+			Content: "apiVersion: kustomize.config.k8s.io/v1beta1\nkind: Kustomization\nresources: [pod.yaml]\n"}
+		Expect(cmd.PrintOrWriteOut(context.Background(), r)).To(Succeed())
+		Expect(calls).To(Equal([][]string{{cmd.Kubectl, "apply", "-f", "manifests/pod.yaml", "-f", "manifests/svc.yaml"}}))
+	})
+
+	It("stops kubectl when the run is interrupted or times out", func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		r.RunCommand = func(ctx context.Context, _ io.Writer, _ string, _ ...string) error {
+			return ctx.Err()
+		}
+		Expect(cmd.PrintOrWriteOut(ctx, r)).To(MatchError(context.Canceled))
+	})
+
+	It("is off by default", func() {
+		r.Apply = false
+		Expect(cmd.PrintOrWriteOut(context.Background(), r)).To(Succeed())
+		Expect(calls).To(BeEmpty())
+	})
+
+	It("doesn't apply output which couldn't be decoded", func() {
+		r.DecodeError = "no file tags found"
+		err := cmd.PrintOrWriteOut(context.Background(), r)
+		Expect(err).To(MatchError(ContainSubstring("couldn't be decoded: no file tags found")))
+		Expect(calls).To(BeEmpty())
+	})
+
+	It("doesn't apply invalid manifests", func() {
+		r.Filemap.Files["pod"] = filemap.File{Path: "manifests/pod.yaml", Content: "kind: Pod\n"}
+		Expect(cmd.PrintOrWriteOut(context.Background(), r)).To(MatchError(ContainSubstring("manifests/pod.yaml: ")))
+		Expect(calls).To(BeEmpty())
+	})
+
+	It("fails when kubectl fails", func() {
+		r.RunCommand = func(context.Context, io.Writer, string, ...string) error {
+			return fmt.Errorf("exit status 1")
+		}
+		err := cmd.PrintOrWriteOut(context.Background(), r)
+		Expect(err).To(MatchError("the files were written, but could not be applied: exit status 1"))
+	})
+
+	It("fails clearly when kubectl isn't on the PATH", func() {
+		DeferCleanup(os.Setenv, "PATH", os.Getenv("PATH"))
+		Expect(os.Setenv("PATH", GinkgoT().TempDir())).To(Succeed())
+		err := cmd.ExecCommand(context.Background(), out, cmd.Kubectl, "apply")
+		Expect(err).To(MatchError(ContainSubstring("kubectl must be installed and on your PATH")))
+	})
+
+	It("requires --write", func() {
+		c := cmd.NewGenerateCmd()
+		Expect(c.Flags().Set(cmd.FlagApplyFull, "true")).To(Succeed())
+		Expect(cmd.ValidateFlags(c, []string{})).To(MatchError(ContainSubstring("--" + cmd.FlagWriteFull)))
+	})
+})
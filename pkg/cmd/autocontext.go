@@ -0,0 +1,76 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/redhat-et/copilot-ops/pkg/ai/embeddings"
+	"github.com/redhat-et/copilot-ops/pkg/cmd/config"
+	"github.com/redhat-et/copilot-ops/pkg/filemap"
+	"github.com/redhat-et/copilot-ops/pkg/logger"
+)
+
+// EmbeddingsCache Returns the cache of the embeddings of files, or nil if there's no cache directory.
+func EmbeddingsCache() *embeddings.Cache {
+	cacheDir, err := config.CacheDir()
+	if err != nil {
+		logger.Warnf("not caching embeddings: %s\n", err)
+		return nil
+	}
+	return &embeddings.Cache{Dir: filepath.Join(cacheDir, embeddings.DirName)}
+}
+
+// AddRelevantFiles Loads the n files of the repo which are the most similar to the request into the filemap,
+// ranking every candidate file by the cosine similarity of its embedding to the request's.
+// Files which aren't text, or which are too large to load, are skipped. The paths of the files which were added are returned.
+func AddRelevantFiles(
+	ctx context.Context,
+	client *embeddings.Client,
+	cache *embeddings.Cache,
+	fm *filemap.Filemap,
+	request string,
+	n int,
+) ([]string, error) {
+	candidates, err := fm.Candidates(".")
+	if err != nil {
+		return nil, fmt.Errorf("could not list the files of the repo: %w", err)
+	}
+	// the request is embedded along with the files, so that it only takes a single request
+	texts := []string{request}
+	var paths []string
+	for _, name := range candidates {
+		content, err := os.ReadFile(name)
+		if err != nil {
+			return nil, err
+		}
+		if !filemap.IsText(content) || fm.TooLarge(int64(len(content))) {
+			continue
+		}
+		paths = append(paths, name)
+		texts = append(texts, "# "+filepath.ToSlash(name)+"\n"+string(content))
+	}
+	if len(paths) == 0 || n <= 0 {
+		return nil, nil
+	}
+
+	vectors, err := embeddings.EmbedCached(ctx, client, cache, texts)
+	if err != nil {
+		return nil, err
+	}
+	ranked := embeddings.Rank(vectors[0], vectors[1:])
+	if len(ranked) > n {
+		ranked = ranked[:n]
+	}
+	added := make([]string, len(ranked))
+	for i, index := range ranked {
+		if err = fm.LoadFile(paths[index]); err != nil {
+			return nil, err
+		}
+		added[i] = paths[index]
+	}
+	logger.Infof("picked %d of %d files as context: %s\n", len(added), len(paths), strings.Join(added, ", "))
+	return added, nil
+}
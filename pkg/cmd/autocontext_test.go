@@ -0,0 +1,106 @@
+package cmd_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/spf13/viper"
+
+	"github.com/redhat-et/copilot-ops/pkg/ai/embeddings"
+	"github.com/redhat-et/copilot-ops/pkg/cmd"
+	"github.com/redhat-et/copilot-ops/pkg/cmd/config"
+)
+
+var _ = Describe("Auto context", func() {
+	var embedded int
+	var out *bytes.Buffer
+
+	BeforeEach(func() {
+		// a deterministic embedding which counts the mentions of each kind
+		embedded = 0
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var body embeddings.Request
+			_ = json.NewDecoder(r.Body).Decode(&body)
+			embedded += len(body.Input)
+			data := make([]string, len(body.Input))
+			for i, input := range body.Input {
+				input = strings.ToLower(input)
+				data[i] = fmt.Sprintf(`{"index": %d, "embedding": [%d, %d, %d]}`, i,
+					strings.Count(input, "deployment"), strings.Count(input, "service"), strings.Count(input, "configmap"))
+			}
+			fmt.Fprintf(w, `{"data": [%s]}`, strings.Join(data, ", "))
+		}))
+		DeferCleanup(ts.Close)
+
+		wd, err := os.Getwd()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(os.Chdir(GinkgoT().TempDir())).To(Succeed())
+		DeferCleanup(os.Chdir, wd)
+		viper.Reset()
+		DeferCleanup(viper.Reset)
+		cacheDir := os.Getenv(config.CacheDirEnv)
+		Expect(os.Setenv(config.CacheDirEnv, GinkgoT().TempDir())).To(Succeed())
+		DeferCleanup(os.Setenv, config.CacheDirEnv, cacheDir)
+
+		files := map[string]string{
+			config.ConfigFile:             fmt.Sprintf("embeddings:\n  url: %s\n", ts.URL),
+			"app/deployment.yaml":         "kind: Deployment\nmetadata:\n  name: web\n",
+			"app/service.yaml":            "kind: Service\nmetadata:\n  name: web\n",
+			"app/configmap.yaml":          "kind: ConfigMap\nmetadata:\n  name: web-config\n",
+			"docs/deployment-service.txt": "How the web Deployment is exposed by its Service.\n",
+		}
+		for name, content := range files {
+			Expect(os.MkdirAll(filepath.Dir(name), 0755)).To(Succeed())
+			Expect(os.WriteFile(name, []byte(content), 0600)).To(Succeed())
+		}
+		out = &bytes.Buffer{}
+	})
+
+	// prompt Returns the prompt which the generate command would send with the given flags.
+	prompt := func(flags ...string) string {
+		root := cmd.NewRootCmd()
+		root.SetOut(out)
+		root.SetErr(&bytes.Buffer{})
+		root.SetArgs(append([]string{
+			cmd.CommandGenerate,
+			"--" + cmd.FlagRequestFull, "Add a ConfigMap for the web Service, like the configmap of the api",
+			"--" + cmd.FlagPromptOnlyFull,
+			"--" + cmd.FlagAutoContextFull,
+		}, flags...))
+		Expect(root.Execute()).To(Succeed())
+		return out.String()
+	}
+
+	It("packs the files most similar to the request into the prompt", func() {
+		text := prompt("--"+cmd.FlagContextFilesFull, "1")
+		Expect(text).To(ContainSubstring("name: web-config"))
+		Expect(text).NotTo(ContainSubstring("kind: Deployment"))
+		Expect(text).NotTo(ContainSubstring("kind: Service"))
+	})
+
+	It("ranks the files by their similarity", func() {
+		text := prompt("--"+cmd.FlagContextFilesFull, "2")
+		Expect(text).To(ContainSubstring("name: web-config"))
+		Expect(text).To(ContainSubstring("kind: Service"))
+		Expect(text).NotTo(ContainSubstring("exposed by its Service"))
+		Expect(text).NotTo(ContainSubstring("kind: Deployment"))
+	})
+
+	It("only embeds the files again once they change", func() {
+		prompt()
+		Expect(embedded).To(Equal(5))
+		prompt()
+		Expect(embedded).To(Equal(5))
+		Expect(os.WriteFile("app/service.yaml", []byte("kind: Service\nmetadata:\n  name: api\n"), 0600)).To(Succeed())
+		prompt()
+		Expect(embedded).To(Equal(6))
+	})
+})
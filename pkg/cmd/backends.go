@@ -0,0 +1,225 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/redhat-et/copilot-ops/pkg/ai"
+	"github.com/redhat-et/copilot-ops/pkg/cmd/config"
+	"github.com/redhat-et/copilot-ops/pkg/logger"
+)
+
+// HealthCheckPrompt Is the prompt of the single-token completion which checks that a backend works.
+const HealthCheckPrompt = "ping"
+
+// BackendStatus Is the result of checking a single backend.
+type BackendStatus struct {
+	Backend  ai.Backend
+	Endpoint string
+	// Err Is why the backend couldn't be reached, or nil if it works.
+	Err error
+}
+
+// NewBackendsCmd Creates the `copilot-ops backends` CLI command.
+func NewBackendsCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use: CommandBackends,
+
+		Short: "Checks that each configured backend can be reached",
+
+		Long: "Requests a single-token completion from every backend configured in " + config.ConfigFile +
+			", reporting which endpoints fail and why, such as an invalid API key or a wrong URL.",
+
+		Example: `  copilot-ops backends
+  copilot-ops backends --backend claude`,
+
+		RunE: RunBackends,
+	}
+
+	cmd.Flags().StringP(
+		FlagAIBackendFull, FlagAIBackendShort, string(ai.Unselected),
+		"Only check this backend, even if it isn't configured",
+	)
+
+	cmd.Flags().StringP(
+		FlagPathFull, FlagPathShort, ".",
+		"Path to the root of the repo",
+	)
+
+	cmd.Flags().Duration(
+		FlagTimeoutFull, DefaultCheckTimeout,
+		"Fail the check of a backend which takes longer than this to respond, or 0 to wait indefinitely",
+	)
+
+	AddConnectionFlags(cmd)
+
+	return cmd
+}
+
+// RunBackends Runs when the `backends` command is invoked. It fails when any backend fails its check.
+func RunBackends(cmd *cobra.Command, args []string) error {
+	timeout, _ := cmd.Flags().GetDuration(FlagTimeoutFull)
+	conf, backends, err := LoadBackends(cmd)
+	if err != nil {
+		return err
+	}
+
+	// every backend is checked with its own timeout, so only interrupts apply to them all
+	ctx, cancel := RequestContext(cmd, 0)
+	defer cancel()
+	statuses := CheckBackends(ctx, conf, backends, timeout)
+	if err := PrintBackendStatuses(cmd.OutOrStdout(), statuses); err != nil {
+		return err
+	}
+	failed := 0
+	for _, status := range statuses {
+		if status.Err != nil {
+			failed++
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d backends failed the check", failed, len(statuses))
+	}
+	return nil
+}
+
+// LoadBackends Loads the config of the repo given with --path, returning it along with the backend
+// given with --backend, or every configured backend when none was given.
+func LoadBackends(cmd *cobra.Command) (*config.Config, []ai.Backend, error) {
+	backend, _ := cmd.Flags().GetString(FlagAIBackendFull)
+	path, _ := cmd.Flags().GetString(FlagPathFull)
+	insecureSkipVerify, _ := cmd.Flags().GetBool(FlagInsecureSkipVerifyFull)
+	proxy, _ := cmd.Flags().GetString(FlagProxyFull)
+	rateLimit, _ := cmd.Flags().GetFloat64(FlagRateLimitFull)
+
+	if path != "" {
+		if err := os.Chdir(path); err != nil {
+			return nil, nil, err
+		}
+	}
+	conf := &config.Config{}
+	if err := conf.Load(); err != nil {
+		return nil, nil, err
+	}
+	backends := []ai.Backend{ai.Backend(backend)}
+	if backends[0] == ai.Unselected {
+		if backends = conf.ConfiguredBackends(); len(backends) == 0 {
+			return nil, nil, fmt.Errorf("no backends are configured in %s, use --%s to select one",
+				config.ConfigFile, FlagAIBackendFull)
+		}
+	}
+	conf.SetDefaults()
+	if err := ConfigureTLS(conf, insecureSkipVerify); err != nil {
+		return nil, nil, err
+	}
+	if _, err := ConfigureProxy(conf, proxy); err != nil {
+		return nil, nil, err
+	}
+	ConfigureRateLimit(conf, rateLimit)
+	return conf, backends, nil
+}
+
+// CheckBackends Requests a single-token completion from each of the backends, in order.
+// A backend fails its check when it doesn't respond within the timeout, unless the timeout is zero.
+func CheckBackends(
+	ctx context.Context,
+	conf *config.Config,
+	backends []ai.Backend,
+	timeout time.Duration,
+) []BackendStatus {
+	statuses := make([]BackendStatus, len(backends))
+	for i, backend := range backends {
+		statuses[i] = BackendStatus{
+			Backend:  backend,
+			Endpoint: BackendEndpoint(conf, backend),
+			Err:      checkBackend(ctx, conf, backend, timeout),
+		}
+	}
+	return statuses
+}
+
+// checkBackend Requests a single-token completion from the backend, within the timeout.
+func checkBackend(ctx context.Context, conf *config.Config, backend ai.Backend, timeout time.Duration) error {
+	logger.Debugf("checking the %q backend\n", backend)
+	r := &Request{Config: *conf, Backend: backend, NTokens: 1, NCompletions: 1, Concurrency: 1, Timeout: timeout}
+	client, err := PrepareGenerateClient(r, HealthCheckPrompt)
+	if err != nil {
+		return err
+	}
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	_, err = client.Generate(ctx)
+	return CancellationError(ctx, timeout, err)
+}
+
+// BackendEndpoint Returns the URL which requests for the backend are sent to,
+// or an empty string if the backend isn't configured.
+func BackendEndpoint(conf *config.Config, backend ai.Backend) string {
+	switch backend {
+	case ai.GPT3:
+		if conf.OpenAI != nil {
+			return conf.OpenAI.URL()
+		}
+	case ai.GPTJ:
+		if conf.GPTJ != nil {
+			return conf.GPTJ.URL
+		}
+	case ai.BLOOM:
+		if conf.BLOOM != nil {
+			return conf.BLOOM.URL
+		}
+	case ai.OPT:
+		if conf.OPT != nil {
+			return conf.OPT.URL
+		}
+	case ai.CLAUDE:
+		if conf.Claude != nil {
+			return conf.Claude.URL
+		}
+	case ai.OLLAMA:
+		if conf.Ollama != nil {
+			return conf.Ollama.URL
+		}
+	case ai.HUGGINGFACE:
+		if conf.HuggingFace != nil {
+			return conf.HuggingFace.ModelURL()
+		}
+	case ai.COHERE:
+		if conf.Cohere != nil {
+			return conf.Cohere.URL
+		}
+	case ai.GEMINI:
+		if conf.Gemini != nil {
+			return conf.Gemini.GenerateURL()
+		}
+	case ai.Unselected:
+	}
+	return ""
+}
+
+// PrintBackendStatuses Writes the statuses as a table of each backend, its endpoint, and whether it works.
+func PrintBackendStatuses(out io.Writer, statuses []BackendStatus) error {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0) //nolint:gomnd // the padding between columns
+	fmt.Fprintln(w, "BACKEND\tENDPOINT\tSTATUS")
+	for _, status := range statuses {
+		result := "OK"
+		if status.Err != nil {
+			result = "FAILED: " + status.Err.Error()
+		}
+		endpoint := status.Endpoint
+		if endpoint == "" {
+			endpoint = "-"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\n", status.Backend, endpoint, result)
+	}
+	return w.Flush()
+}
@@ -0,0 +1,63 @@
+package cmd_test
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/spf13/viper"
+
+	"github.com/redhat-et/copilot-ops/pkg/ai"
+	"github.com/redhat-et/copilot-ops/pkg/ai/gpt3"
+	"github.com/redhat-et/copilot-ops/pkg/cmd"
+	"github.com/redhat-et/copilot-ops/pkg/cmd/config"
+)
+
+var _ = Describe("Backends command", func() {
+	var out *bytes.Buffer
+
+	BeforeEach(func() {
+		// a backend which accepts the request, and one which refuses the API key
+		openAI := OpenAITestServer()
+		openAI.Start()
+		DeferCleanup(openAI.Close)
+		unauthorized := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusUnauthorized)
+		}))
+		DeferCleanup(unauthorized.Close)
+
+		wd, err := os.Getwd()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(os.Chdir(GinkgoT().TempDir())).To(Succeed())
+		DeferCleanup(os.Chdir, wd)
+		viper.Reset()
+		DeferCleanup(viper.Reset)
+		Expect(os.WriteFile(config.ConfigFile, []byte(fmt.Sprintf(
+			"openAI:\n  apiKey: sk-test\n  baseURL: %s\nclaude:\n  apiKey: invalid\n  url: %s\n",
+			openAI.URL+gpt3.OpenAIEndpointV1, unauthorized.URL,
+		)), 0600)).To(Succeed())
+		out = &bytes.Buffer{}
+	})
+
+	It("checks every configured backend", func() {
+		c := cmd.NewBackendsCmd()
+		c.SetOut(out)
+		err := cmd.RunBackends(c, []string{})
+		Expect(err).To(MatchError("1 of 2 backends failed the check"))
+		Expect(out.String()).To(MatchRegexp(`gpt-3 +http://\S+/v1 +OK\n`))
+		Expect(out.String()).To(MatchRegexp(`claude +http://\S+ +FAILED: .*status code: 401`))
+	})
+
+	It("only checks the backend given with --backend", func() {
+		c := cmd.NewBackendsCmd()
+		c.SetOut(out)
+		Expect(c.Flags().Set(cmd.FlagAIBackendFull, string(ai.GPT3))).To(Succeed())
+		Expect(cmd.RunBackends(c, []string{})).To(Succeed())
+		Expect(out.String()).To(ContainSubstring("OK"))
+		Expect(out.String()).NotTo(ContainSubstring(string(ai.CLAUDE)))
+	})
+})
@@ -0,0 +1,146 @@
+package cmd
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/redhat-et/copilot-ops/pkg/ai"
+	"github.com/redhat-et/copilot-ops/pkg/filemap"
+	"github.com/redhat-et/copilot-ops/pkg/logger"
+	"github.com/redhat-et/copilot-ops/pkg/tokenizer"
+)
+
+// MaxReportedFiles Is how many of the largest files are named when a prompt is over budget.
+const MaxReportedFiles = 3
+
+// Strategies for trimming the context files when the prompt doesn't fit the context window.
+const (
+	// TrimStrategyDropFiles Removes whole files from the context.
+	TrimStrategyDropFiles = "drop-files"
+	// TrimStrategyTruncate Cuts lines off the end of files, removing them once they're empty.
+	TrimStrategyTruncate = "truncate"
+)
+
+// fileTokens Pairs a file with the number of tokens its content uses.
+type fileTokens struct {
+	path   string
+	tokens int
+}
+
+// CheckTokenBudget Ensures that the prompt, plus the nTokens requested for the completion,
+// fits within the context window of the backend's model. When it doesn't, the error explains
+// how far over budget the request is and which files contributed the most tokens.
+func CheckTokenBudget(
+	tok tokenizer.Tokenizer, backend ai.Backend, model string, prompt string, nTokens int, fm *filemap.Filemap,
+) error {
+	window := ai.ContextWindow(backend, model)
+	if window == 0 || tok == nil {
+		return nil
+	}
+	promptTokens := tok.CountTokens(prompt)
+	total := promptTokens + nTokens
+	if total <= window {
+		return nil
+	}
+
+	msg := fmt.Sprintf("the prompt uses %d tokens and --%s requests %d more, which is %d over the %d token context "+
+		"window of %s (counted with the %s)",
+		promptTokens, FlagNTokensFull, nTokens, total-window, window, describeModel(backend, model), tok.Name())
+	if largest := largestFiles(tok, fm, MaxReportedFiles); len(largest) > 0 {
+		names := make([]string, len(largest))
+		for i, f := range largest {
+			names[i] = fmt.Sprintf("%s (%d tokens)", f.path, f.tokens)
+		}
+		msg += "; the largest files are " + strings.Join(names, ", ")
+	}
+	return fmt.Errorf("%s: try including fewer files, lowering --%s, or passing --%s",
+		msg, FlagNTokensFull, FlagTrimStrategyFull)
+}
+
+// describeModel Names the model of the backend in messages, or only the backend when its model isn't known.
+func describeModel(backend ai.Backend, model string) string {
+	if model == "" {
+		return fmt.Sprintf("the %s backend", backend)
+	}
+	return fmt.Sprintf("%s on the %s backend", model, backend)
+}
+
+// largestFiles Returns up to n files from the filemap which use the most tokens.
+func largestFiles(tok tokenizer.Tokenizer, fm *filemap.Filemap, n int) []fileTokens {
+	if fm == nil {
+		return nil
+	}
+	files := make([]fileTokens, 0, len(fm.Files))
+	for tag, f := range fm.Files {
+		path := f.Path
+		if path == "" {
+			path = tag
+		}
+		files = append(files, fileTokens{path: path, tokens: tok.CountTokens(f.Content)})
+	}
+	sort.Slice(files, func(i, j int) bool {
+		if files[i].tokens == files[j].tokens {
+			return files[i].path < files[j].path
+		}
+		return files[i].tokens > files[j].tokens
+	})
+	if len(files) > n {
+		files = files[:n]
+	}
+	return files
+}
+
+// TrimContext Drops or truncates the files in the filemap, least recently matched first, until the
+// prompt returned by build plus the nTokens requested fits in the context window of the backend's model.
+// The prompt is built again after each file is trimmed. Nothing is trimmed without a strategy or
+// when the context window is unknown, and the last prompt is returned if trimming every file wasn't enough.
+func TrimContext(
+	tok tokenizer.Tokenizer, backend ai.Backend, model, strategy string, nTokens int,
+	fm *filemap.Filemap, build func() (string, error),
+) (string, error) {
+	prompt, err := build()
+	window := ai.ContextWindow(backend, model)
+	if err != nil || strategy == "" || window == 0 || tok == nil || fm == nil {
+		return prompt, err
+	}
+	if strategy != TrimStrategyDropFiles && strategy != TrimStrategyTruncate {
+		return "", fmt.Errorf("invalid trim strategy %q, must be one of: %s, %s",
+			strategy, TrimStrategyDropFiles, TrimStrategyTruncate)
+	}
+
+	for _, tag := range fm.LoadOrder() {
+		over := tok.CountTokens(prompt) + nTokens - window
+		if over <= 0 {
+			break
+		}
+		file := fm.Files[tag]
+		path := file.Path
+		if path == "" {
+			path = tag
+		}
+		fileTokens := tok.CountTokens(file.Content)
+		if strategy == TrimStrategyTruncate && fileTokens > over {
+			file.Content = truncateLines(tok, file.Content, fileTokens-over)
+			fm.Files[tag] = file
+			logger.Infof("truncated %s from %d to %d tokens to fit the context window\n",
+				path, fileTokens, tok.CountTokens(file.Content))
+		} else {
+			delete(fm.Files, tag)
+			logger.Infof("dropped %s (%d tokens) from the context to fit the context window\n", path, fileTokens)
+		}
+		if prompt, err = build(); err != nil {
+			return "", err
+		}
+	}
+	return prompt, nil
+}
+
+// truncateLines Returns the most lines from the start of the content which fit in maxTokens.
+func truncateLines(tok tokenizer.Tokenizer, content string, maxTokens int) string {
+	lines := strings.SplitAfter(content, "\n")
+	fits := sort.Search(len(lines)+1, func(n int) bool {
+		return tok.CountTokens(strings.Join(lines[:n], "")) > maxTokens
+	}) - 1
+	return strings.Join(lines[:fits], "")
+}
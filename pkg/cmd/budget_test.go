@@ -0,0 +1,116 @@
+package cmd_test
+
+import (
+	"os"
+	"strings"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/spf13/viper"
+
+	"github.com/redhat-et/copilot-ops/pkg/ai"
+	"github.com/redhat-et/copilot-ops/pkg/cmd"
+	"github.com/redhat-et/copilot-ops/pkg/cmd/config"
+	"github.com/redhat-et/copilot-ops/pkg/filemap"
+	"github.com/redhat-et/copilot-ops/pkg/tokenizer"
+)
+
+var _ = Describe("Token budget", func() {
+	var fm *filemap.Filemap
+
+	BeforeEach(func() {
+		fm = filemap.NewFilemap()
+		fm.Files["small"] = filemap.File{Path: "small.yaml", Content: strings.Repeat("a", 400)}
+		fm.Files["large"] = filemap.File{Path: "large.yaml", Content: strings.Repeat("a", 6000)}
+	})
+
+	It("accepts prompts which fit in the context window", func() {
+		Expect(cmd.CheckTokenBudget(tokenizer.Heuristic{}, ai.GPTJ, "", "hello world", 256, fm)).To(Succeed())
+	})
+
+	It("reports how far over budget the prompt is and the largest files", func() {
+		prompt := strings.Repeat("a", 8800)
+		err := cmd.CheckTokenBudget(tokenizer.Heuristic{}, ai.GPTJ, "", prompt, 256, fm)
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("uses 2200 tokens"))
+		Expect(err.Error()).To(ContainSubstring("408 over the 2048 token context window"))
+		Expect(err.Error()).To(ContainSubstring("large.yaml (1500 tokens), small.yaml (100 tokens)"))
+	})
+
+	It("skips the check when the context window is unknown", func() {
+		prompt := strings.Repeat("a", 100000)
+		Expect(cmd.CheckTokenBudget(tokenizer.Heuristic{}, ai.Unselected, "", prompt, 256, fm)).To(Succeed())
+	})
+
+	It("uses the context window of the model given with --model", func() {
+		wd, err := os.Getwd()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(os.Chdir(GinkgoT().TempDir())).To(Succeed())
+		DeferCleanup(os.Chdir, wd)
+		viper.Reset()
+		DeferCleanup(viper.Reset)
+		Expect(os.WriteFile(config.ConfigFile, []byte("openAI:\n  apiKey: sk-test\n"), 0600)).To(Succeed())
+
+		// about 10k tokens, over the 8001 token window of code-davinci-002
+		prompt := strings.Repeat("a", 40000)
+		c := cmd.NewGenerateCmd()
+		Expect(c.Flags().Set(cmd.FlagRequestFull, "Create a Pod")).To(Succeed())
+		r, err := cmd.PrepareRequest(c)
+		Expect(err).NotTo(HaveOccurred())
+		err = cmd.CheckTokenBudget(tokenizer.Heuristic{}, r.Backend, cmd.ModelName(r.Config, r.Backend), prompt, 256, fm)
+		Expect(err).To(MatchError(ContainSubstring("8001 token context window of code-davinci-002 on the gpt-3 backend")))
+
+		c = cmd.NewGenerateCmd()
+		Expect(c.Flags().Set(cmd.FlagRequestFull, "Create a Pod")).To(Succeed())
+		Expect(c.Flags().Set(cmd.FlagModelFull, "gpt-4o")).To(Succeed())
+		r, err = cmd.PrepareRequest(c)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(cmd.ModelName(r.Config, r.Backend)).To(Equal("gpt-4o"))
+		Expect(cmd.CheckTokenBudget(tokenizer.Heuristic{}, r.Backend, cmd.ModelName(r.Config, r.Backend), prompt, 256, fm)).
+			To(Succeed())
+	})
+
+	When("the context is trimmed", func() {
+		const nTokens = 256
+		var build func() (string, error)
+
+		BeforeEach(func() {
+			// 3 files of 800 tokens each, which don't fit in the 2048 token window of GPT-J
+			fm = filemap.NewFilemap()
+			for _, name := range []string{"a.yaml", "b.yaml", "c.yaml"} {
+				fm.Files[name] = filemap.File{Path: name, Content: strings.Repeat("key: valu\n", 320)}
+			}
+			build = func() (string, error) {
+				return "create a pod\n" + fm.EncodeToInputText(), nil
+			}
+		})
+
+		It("drops whole files until the prompt fits", func() {
+			prompt, err := cmd.TrimContext(tokenizer.Heuristic{}, ai.GPTJ, "", cmd.TrimStrategyDropFiles, nTokens, fm, build)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(tokenizer.Heuristic{}.CountTokens(prompt) + nTokens).To(BeNumerically("<=", 2048))
+			Expect(fm.Files).To(HaveLen(2))
+			Expect(fm.Files).NotTo(HaveKey("a.yaml"))
+			Expect(cmd.CheckTokenBudget(tokenizer.Heuristic{}, ai.GPTJ, "", prompt, nTokens, fm)).To(Succeed())
+		})
+
+		It("truncates files until the prompt fits", func() {
+			prompt, err := cmd.TrimContext(tokenizer.Heuristic{}, ai.GPTJ, "", cmd.TrimStrategyTruncate, nTokens, fm, build)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(tokenizer.Heuristic{}.CountTokens(prompt) + nTokens).To(BeNumerically("<=", 2048))
+			Expect(fm.Files).To(HaveLen(3))
+			Expect(len(fm.Files["a.yaml"].Content)).To(BeNumerically("<", 3200))
+			// whole lines are kept
+			Expect(fm.Files["a.yaml"].Content).To(HaveSuffix("key: valu\n"))
+			Expect(fm.Files["b.yaml"].Content).To(HaveLen(3200))
+		})
+
+		It("leaves the files alone without a strategy", func() {
+			_, err := cmd.TrimContext(tokenizer.Heuristic{}, ai.GPTJ, "", "", nTokens, fm, build)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(fm.Files).To(HaveLen(3))
+			_, err = cmd.TrimContext(tokenizer.Heuristic{}, ai.GPTJ, "", "unknown", nTokens, fm, build)
+			Expect(err).To(HaveOccurred())
+		})
+	})
+})
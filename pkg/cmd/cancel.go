@@ -0,0 +1,48 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// RequestContext Returns the context which requests to the backend are made with. It's canceled
+// when the process is interrupted or terminated, and once the timeout passes unless the timeout is zero.
+// The returned function must be called to stop listening for signals.
+func RequestContext(cmd *cobra.Command, timeout time.Duration) (context.Context, context.CancelFunc) {
+	parent := cmd.Context()
+	if parent == nil {
+		parent = context.Background()
+	}
+	ctx, stop := signal.NotifyContext(parent, os.Interrupt, syscall.SIGTERM)
+	if timeout <= 0 {
+		return ctx, stop
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	return ctx, func() {
+		cancel()
+		stop()
+	}
+}
+
+// CancellationError Explains why a request failed when it was aborted, so that a timeout
+// or an interrupt isn't reported as a failure of the backend. Other errors are returned as they are.
+func CancellationError(ctx context.Context, timeout time.Duration, err error) error {
+	if err == nil {
+		return nil
+	}
+	switch {
+	case errors.Is(ctx.Err(), context.DeadlineExceeded):
+		return fmt.Errorf("the backend did not respond within %s, use --%s to wait longer: %w",
+			timeout, FlagTimeoutFull, ctx.Err())
+	case errors.Is(ctx.Err(), context.Canceled):
+		return fmt.Errorf("interrupted while waiting for the backend: %w", ctx.Err())
+	}
+	return err
+}
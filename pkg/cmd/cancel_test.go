@@ -0,0 +1,103 @@
+package cmd_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/redhat-et/copilot-ops/pkg/ai"
+	"github.com/redhat-et/copilot-ops/pkg/ai/gpt3"
+	"github.com/redhat-et/copilot-ops/pkg/cmd"
+	"github.com/redhat-et/copilot-ops/pkg/cmd/config"
+)
+
+var _ = Describe("Cancellation", func() {
+	var hanging *httptest.Server
+	var aborted chan struct{}
+
+	BeforeEach(func() {
+		// a backend which never responds in time, recording when the client gives up on it
+		aborted = make(chan struct{}, 1)
+		hanging = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// the server only notices that the client went away once the body is read
+			_, _ = io.Copy(io.Discard, r.Body)
+			select {
+			case <-r.Context().Done():
+				aborted <- struct{}{}
+			case <-time.After(10 * time.Second):
+				http.Error(w, "too late", http.StatusGatewayTimeout)
+			}
+		}))
+		DeferCleanup(hanging.Close)
+
+		wd, err := os.Getwd()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(os.Chdir(GinkgoT().TempDir())).To(Succeed())
+		DeferCleanup(os.Chdir, wd)
+		viper.Reset()
+		DeferCleanup(viper.Reset)
+	})
+
+	// generate Returns the generate command, sending its requests to the hanging backend.
+	generate := func(flags ...string) *cobra.Command {
+		root := cmd.NewRootCmd()
+		root.SetOut(&bytes.Buffer{})
+		root.SetErr(&bytes.Buffer{})
+		root.SetArgs(append([]string{
+			cmd.CommandGenerate,
+			"--" + cmd.FlagAIBackendFull, string(ai.GPT3),
+			"--" + cmd.FlagOpenAIURLFull, hanging.URL + gpt3.OpenAIEndpointV1,
+			"--" + cmd.FlagNTokensFull, "1",
+			"--" + cmd.FlagRequestFull, "Create a Pod running nginx",
+		}, flags...))
+		return root
+	}
+
+	It("aborts the request once the timeout passes", func() {
+		start := time.Now()
+		err := generate("--"+cmd.FlagTimeoutFull, "100ms").Execute()
+		Expect(err).To(MatchError(ContainSubstring("the backend did not respond within 100ms")))
+		Expect(errors.Is(err, context.DeadlineExceeded)).To(BeTrue())
+		Expect(time.Since(start)).To(BeNumerically("<", 5*time.Second))
+		Eventually(aborted).Should(Receive())
+	})
+
+	It("aborts the request when it's interrupted", func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		time.AfterFunc(100*time.Millisecond, cancel)
+		err := generate().ExecuteContext(ctx)
+		Expect(err).To(MatchError(ContainSubstring("interrupted while waiting for the backend")))
+		Expect(errors.Is(err, context.Canceled)).To(BeTrue())
+		Eventually(aborted).Should(Receive())
+	})
+
+	It("fails the check of a backend which doesn't respond in time", func() {
+		Expect(os.WriteFile(config.ConfigFile, []byte(fmt.Sprintf(
+			"openAI:\n  apiKey: sk-test\n  baseURL: %s\n", hanging.URL+gpt3.OpenAIEndpointV1,
+		)), 0600)).To(Succeed())
+		out := &bytes.Buffer{}
+		c := cmd.NewBackendsCmd()
+		c.SetOut(out)
+		Expect(c.Flags().Set(cmd.FlagAIBackendFull, string(ai.GPT3))).To(Succeed())
+		Expect(c.Flags().Set(cmd.FlagTimeoutFull, "100ms")).To(Succeed())
+		Expect(cmd.RunBackends(c, []string{})).To(MatchError("1 of 1 backends failed the check"))
+		Expect(out.String()).To(ContainSubstring("FAILED: the backend did not respond within 100ms"))
+		Eventually(aborted).Should(Receive())
+	})
+
+	It("leaves errors alone when the request wasn't aborted", func() {
+		err := fmt.Errorf("error, status code: 401")
+		Expect(cmd.CancellationError(context.Background(), time.Second, err)).To(Equal(err))
+	})
+})
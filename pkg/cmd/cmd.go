@@ -6,6 +6,8 @@ import (
 	"os"
 
 	"github.com/spf13/cobra"
+
+	"github.com/redhat-et/copilot-ops/pkg/logger"
 )
 
 // Execute the CLI and exit.
@@ -31,11 +33,45 @@ func NewRootCmd() *cobra.Command {
 		// Usage on every error is too noisy and makes it harder
 		// to read the error message, so disabling it
 		SilenceUsage: true,
+
+		PersistentPreRunE: ConfigureLogging,
 	}
 
+	cmd.PersistentFlags().String(
+		FlagLogLevelFull, logger.LevelInfo.String(),
+		"Only log messages at or above this level (debug, info, warn, or error), logs are written to stderr",
+	)
+
+	cmd.PersistentFlags().BoolP(
+		FlagQuietFull, FlagQuietShort, false,
+		"Only log errors, the same as --"+FlagLogLevelFull+" "+logger.LevelError.String(),
+	)
+
 	// Add subcommands of the root command
 	cmd.AddCommand(NewGenerateCmd())
 	cmd.AddCommand(NewEditCmd())
+	cmd.AddCommand(NewUndoCmd())
+	cmd.AddCommand(NewHistoryCmd())
+	cmd.AddCommand(NewBackendsCmd())
+	cmd.AddCommand(NewModelsCmd())
+	cmd.AddCommand(NewConfigCmd())
+	cmd.AddCommand(NewValidateCmd())
 
 	return cmd
 }
+
+// ConfigureLogging Sets the level of the logger from the --log-level and --quiet flags.
+func ConfigureLogging(cmd *cobra.Command, args []string) error {
+	quiet, _ := cmd.Flags().GetBool(FlagQuietFull)
+	if quiet {
+		logger.SetLevel(logger.LevelError)
+		return nil
+	}
+	name, _ := cmd.Flags().GetString(FlagLogLevelFull)
+	level, err := logger.ParseLevel(name)
+	if err != nil {
+		return err
+	}
+	logger.SetLevel(level)
+	return nil
+}
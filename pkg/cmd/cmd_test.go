@@ -1,10 +1,16 @@
 package cmd_test
 
 import (
+	"bytes"
+	"os"
+
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
 
+	"github.com/redhat-et/copilot-ops/pkg/ai"
+	"github.com/redhat-et/copilot-ops/pkg/ai/gpt3"
 	cmd "github.com/redhat-et/copilot-ops/pkg/cmd"
+	"github.com/redhat-et/copilot-ops/pkg/logger"
 )
 
 var _ = Describe("Root command", func() {
@@ -18,3 +24,58 @@ var _ = Describe("Root command", func() {
 		// FIXME: implement this
 	})
 })
+
+var _ = Describe("Logging", func() {
+	var logs *bytes.Buffer
+
+	BeforeEach(func() {
+		logs = &bytes.Buffer{}
+		logger.SetOutput(logs)
+		DeferCleanup(logger.SetOutput, os.Stderr)
+		DeferCleanup(logger.SetLevel, logger.GetLevel())
+
+		wd, err := os.Getwd()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(os.Chdir(GinkgoT().TempDir())).To(Succeed())
+		DeferCleanup(os.Chdir, wd)
+	})
+
+	// generate Runs the generate command against a mocked OpenAI server with the given flags.
+	generate := func(flags ...string) error {
+		ts := OpenAITestServer()
+		ts.Start()
+		DeferCleanup(ts.Close)
+		root := cmd.NewRootCmd()
+		root.SetOut(logs)
+		root.SetErr(logs)
+		root.SetArgs(append([]string{
+			cmd.CommandGenerate,
+			"--" + cmd.FlagAIBackendFull, string(ai.GPT3),
+			"--" + cmd.FlagOpenAIURLFull, ts.URL + gpt3.OpenAIEndpointV1,
+			"--" + cmd.FlagNTokensFull, "1",
+			"--" + cmd.FlagRequestFull, "Create a Pod running nginx",
+			"--" + cmd.FlagWriteFull,
+		}, flags...))
+		return root.Execute()
+	}
+
+	It("logs the progress of a command by default", func() {
+		Expect(generate()).To(Succeed())
+		Expect(logs.String()).To(ContainSubstring("writing to file"))
+		Expect(logs.String()).NotTo(ContainSubstring("decoding output"))
+	})
+
+	It("logs the details of a command at the debug level", func() {
+		Expect(generate("--"+cmd.FlagLogLevelFull, "debug")).To(Succeed())
+		Expect(logs.String()).To(ContainSubstring("decoding output"))
+	})
+
+	It("emits nothing on success with --quiet", func() {
+		Expect(generate("--" + cmd.FlagQuietFull)).To(Succeed())
+		Expect(logs.String()).To(BeEmpty())
+	})
+
+	It("refuses an unknown log level", func() {
+		Expect(generate("--"+cmd.FlagLogLevelFull, "verbose")).To(MatchError(ContainSubstring(`invalid log level "verbose"`)))
+	})
+})
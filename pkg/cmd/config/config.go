@@ -2,11 +2,25 @@ package config
 
 import (
 	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
 
+	"github.com/mitchellh/mapstructure"
 	"github.com/redhat-et/copilot-ops/pkg/ai"
 	"github.com/redhat-et/copilot-ops/pkg/ai/bloom"
+	"github.com/redhat-et/copilot-ops/pkg/ai/claude"
+	"github.com/redhat-et/copilot-ops/pkg/ai/cohere"
+	"github.com/redhat-et/copilot-ops/pkg/ai/embeddings"
+	"github.com/redhat-et/copilot-ops/pkg/ai/gemini"
 	"github.com/redhat-et/copilot-ops/pkg/ai/gpt3"
 	"github.com/redhat-et/copilot-ops/pkg/ai/gptj"
+	"github.com/redhat-et/copilot-ops/pkg/ai/hfinference"
+	"github.com/redhat-et/copilot-ops/pkg/ai/ollama"
+	"github.com/redhat-et/copilot-ops/pkg/ai/opt"
 	"github.com/spf13/viper"
 )
 
@@ -14,6 +28,11 @@ const (
 	ConfigName      = ".copilot-ops"
 	ConfigFile      = ".copilot-ops.yaml"
 	ConfigFileLocal = ".copilot-ops.local"
+	// CacheDirName Is the name of the directory under the user's cache directory
+	// where copilot-ops stores its data.
+	CacheDirName = "copilot-ops"
+	// CacheDirEnv Is the environment variable which overrides the cache directory.
+	CacheDirEnv = "COPILOT_OPS_CACHE_DIR"
 )
 
 // Config Defines the struct into which the config-file will be parsed.
@@ -23,17 +42,98 @@ type Config struct {
 	// FIXME: rename to GPT-3
 	OpenAI *gpt3.Config `json:"openAI,omitempty" yaml:"openAI,omitempty"`
 	// Backend Defines which AI backend should be used in order to generate completions.
-	// Valid models include: gpt-3, gpt-j, opt, and bloom.
+	// Valid models include: gpt-3, gpt-j, opt, bloom, claude, ollama, huggingface, cohere, and gemini.
+	// Deprecated: use DefaultBackend instead.
 	Backend ai.Backend `json:"backend"`
+	// DefaultBackend Defines which AI backend is used when none is passed from the command-line.
+	// It only needs to be set when more than one backend is configured.
+	DefaultBackend ai.Backend `json:"defaultBackend,omitempty" yaml:"defaultBackend,omitempty"`
+	// FallbackBackends Are tried in order when the selected backend is unavailable,
+	// unless other backends are passed from the command-line.
+	FallbackBackends []ai.Backend `json:"fallbackBackends,omitempty" yaml:"fallbackBackends,omitempty"`
 	// GPTJ Defines the configuration options for using GPT-J.
 	GPTJ *gptj.Config `json:"gptj,omitempty" yaml:"gptj,omitempty"`
 	// BLOOM Defines the configuration for using BLOOM.
 	BLOOM *bloom.Config `json:"bloom,omitempty" yaml:"bloom,omitempty"`
+	// OPT Defines the configuration for using OPT.
+	OPT *opt.Config `json:"opt,omitempty" yaml:"opt,omitempty"`
+	// Claude Defines the configuration for using Anthropic's Claude.
+	Claude *claude.Config `json:"claude,omitempty" yaml:"claude,omitempty"`
+	// Ollama Defines the configuration for using a model served locally by Ollama.
+	Ollama *ollama.Config `json:"ollama,omitempty" yaml:"ollama,omitempty"`
+	// HuggingFace Defines the configuration for using any model on the HuggingFace Inference API.
+	HuggingFace *hfinference.Config `json:"huggingface,omitempty" yaml:"huggingface,omitempty"`
+	// Cohere Defines the configuration for using Cohere's models.
+	Cohere *cohere.Config `json:"cohere,omitempty" yaml:"cohere,omitempty"`
+	// Gemini Defines the configuration for using Google's Gemini models.
+	Gemini *gemini.Config `json:"gemini,omitempty" yaml:"gemini,omitempty"`
+	// Embeddings Defines the endpoint which embeds files for --auto-context, defaulting to OpenAI's.
+	Embeddings *embeddings.Config `json:"embeddings,omitempty" yaml:"embeddings,omitempty"`
+	// Summary Is a paragraph describing the repo, which is included at the top of every prompt
+	// unless a context summary is provided from the command-line.
+	Summary string `json:"summary,omitempty" yaml:"summary,omitempty"`
+	// SystemPrompt Is sent as the system message to chat models, and prepended to the prompt of other models.
+	SystemPrompt string `json:"systemPrompt,omitempty" yaml:"systemPrompt,omitempty"`
+	// EndOfSequence Is the terminator which the model is asked to end the generated files with,
+	// for models which tokenize the default terminator poorly. It's used unless --eos is passed.
+	EndOfSequence string `json:"endOfSequence,omitempty" yaml:"endOfSequence,omitempty"`
+	// PromptTemplates Overrides the wording of the prompt sent by the generate command.
+	PromptTemplates *PromptTemplates `json:"promptTemplates,omitempty" yaml:"promptTemplates,omitempty"`
+	// Profiles Are named sets of settings which can be selected with --profile,
+	// overriding the rest of the config.
+	Profiles map[string]Profile `json:"profiles,omitempty" yaml:"profiles,omitempty"`
 }
 
+// Profile Overrides the backend and the generation settings of the config.
+// Any field left empty keeps the value from the rest of the config.
+type Profile struct {
+	// Backend Is the backend used when none is passed from the command-line.
+	Backend ai.Backend `json:"backend,omitempty" yaml:"backend,omitempty"`
+	// Model Is the model used by the selected backend.
+	Model string `json:"model,omitempty" yaml:"model,omitempty"`
+	// NTokens Is the maximum number of tokens to generate.
+	NTokens int32 `json:"ntokens,omitempty" yaml:"ntokens,omitempty"`
+	// NCompletions Is the number of completions to generate.
+	NCompletions int32 `json:"ncompletions,omitempty" yaml:"ncompletions,omitempty"`
+}
+
+// PromptTemplates Defines text/template overrides for each part of the generation prompt.
+// Any template left empty falls back to the built-in wording. Templates may reference
+// {{.Request}}, {{.EncodedFiles}}, {{.Delimiter}}, {{.EndOfSequence}}, and {{.WithFiles}}.
+type PromptTemplates struct {
+	// Preamble Introduces the document to the model.
+	Preamble string `json:"preamble,omitempty" yaml:"preamble,omitempty"`
+	// Instructions Describes the structure of the document.
+	Instructions string `json:"instructions,omitempty" yaml:"instructions,omitempty"`
+	// CallToAction Contains the request and the existing files, and ends where the model should begin.
+	CallToAction string `json:"callToAction,omitempty" yaml:"callToAction,omitempty"`
+}
+
+// Filesets Names a group of files which can be included as context together.
+// Patterns may use '**' to match any number of directories.
 type Filesets struct {
-	Name  string   `json:"name" yaml:"name"`
+	Name string `json:"name" yaml:"name"`
+	// Files Are glob patterns of the files in the fileset, which are combined with Include.
 	Files []string `json:"files" yaml:"files"`
+	// Include Are more glob patterns of the files in the fileset.
+	Include []string `json:"include,omitempty" yaml:"include,omitempty"`
+	// Exclude Are glob patterns of files which are left out of the fileset, even when they are included.
+	Exclude []string `json:"exclude,omitempty" yaml:"exclude,omitempty"`
+	// Backend Is the backend used when the fileset is selected.
+	Backend ai.Backend `json:"backend,omitempty" yaml:"backend,omitempty"`
+	// NTokens Is the maximum number of tokens to generate when the fileset is selected.
+	NTokens int32 `json:"ntokens,omitempty" yaml:"ntokens,omitempty"`
+	// NCompletions Is the number of completions to generate when the fileset is selected.
+	NCompletions int32 `json:"ncompletions,omitempty" yaml:"ncompletions,omitempty"`
+	// Temperature Is the sampling temperature used when the fileset is selected, which may be zero.
+	Temperature *float32 `json:"temperature,omitempty" yaml:"temperature,omitempty"`
+}
+
+// Patterns Returns every glob pattern which includes files in the fileset.
+func (f Filesets) Patterns() []string {
+	patterns := make([]string, 0, len(f.Files)+len(f.Include))
+	patterns = append(patterns, f.Files...)
+	return append(patterns, f.Include...)
 }
 
 // OpenAI Defines the settings for accessing and using OpenAI's tooling.
@@ -46,17 +146,7 @@ type GPTJ struct {
 // we'll just use the defaults and continue without error.
 // Errors here might return if the file exists but is invalid.
 func (c *Config) Load() error {
-	// bind to environment variables
-	openAIEnvs := map[string]string{
-		"openai.apikey": "OPENAI_API_KEY",
-		"openai.orgid":  "OPENAI_ORG_ID",
-		"openai.url":    "OPENAI_URL",
-	}
-	for k, v := range openAIEnvs {
-		if err := viper.BindEnv(k, v); err != nil {
-			return err
-		}
-	}
+	// the API keys are read from the environment by SetDefaults, only when the config doesn't set them
 	viper.SetEnvPrefix("COPILOT_OPS")
 	viper.AutomaticEnv()
 
@@ -86,8 +176,15 @@ func (c *Config) Load() error {
 		}
 	}
 
-	if err := viper.Unmarshal(c); err != nil {
-		return err
+	// environment variables referenced as ${VAR} are expanded in every value,
+	// in addition to the hooks which viper decodes with by default
+	hooks := viper.DecodeHook(mapstructure.ComposeDecodeHookFunc(
+		expandEnvHook,
+		mapstructure.StringToTimeDurationHookFunc(),
+		mapstructure.StringToSliceHookFunc(","),
+	))
+	if err := viper.Unmarshal(c, hooks); err != nil {
+		return fmt.Errorf("could not load the config: %w", err)
 	}
 
 	return nil
@@ -96,9 +193,10 @@ func (c *Config) Load() error {
 // SetDefaults Sets default values for the given config object.
 func (c *Config) SetDefaults() {
 	if c.OpenAI == nil {
-		c.OpenAI = &gpt3.Config{
-			BaseURL: gpt3.OpenAIURL + gpt3.OpenAIEndpointV1,
-		}
+		c.OpenAI = &gpt3.Config{}
+	}
+	if c.OpenAI.BaseURL == "" {
+		c.OpenAI.BaseURL = gpt3.OpenAIURL + gpt3.OpenAIEndpointV1
 	}
 	// configure GPT-J
 	if c.GPTJ == nil {
@@ -111,6 +209,319 @@ func (c *Config) SetDefaults() {
 			URL: bloom.APIURL,
 		}
 	}
+	if c.OPT == nil {
+		c.OPT = &opt.Config{
+			URL: opt.APIURL,
+		}
+	}
+	if c.Claude == nil {
+		c.Claude = &claude.Config{}
+	}
+	if c.Claude.URL == "" {
+		c.Claude.URL = claude.APIURL
+	}
+	if c.Claude.Model == "" {
+		c.Claude.Model = claude.DefaultModel
+	}
+	if c.Ollama == nil {
+		c.Ollama = &ollama.Config{}
+	}
+	if c.Ollama.URL == "" {
+		c.Ollama.URL = ollama.APIURL
+	}
+	if c.Ollama.Model == "" {
+		c.Ollama.Model = ollama.DefaultModel
+	}
+	if c.HuggingFace == nil {
+		c.HuggingFace = &hfinference.Config{}
+	}
+	if c.HuggingFace.URL == "" {
+		c.HuggingFace.URL = hfinference.APIURL
+	}
+	if c.Cohere == nil {
+		c.Cohere = &cohere.Config{}
+	}
+	if c.Cohere.URL == "" {
+		c.Cohere.URL = cohere.APIURL
+	}
+	if c.Cohere.Model == "" {
+		c.Cohere.Model = cohere.DefaultModel
+	}
+	if c.Gemini == nil {
+		c.Gemini = &gemini.Config{}
+	}
+	if c.Gemini.Model == "" {
+		c.Gemini.Model = gemini.DefaultModel
+	}
+	c.applyEnvFallbacks()
+}
+
+// ConfiguredBackends Returns the backends which have a section in the config,
+// in the order that they are defined in the Config struct.
+// This must be called before SetDefaults, which fills in every section.
+func (c *Config) ConfiguredBackends() []ai.Backend {
+	var backends []ai.Backend
+	sections := []struct {
+		backend    ai.Backend
+		configured bool
+	}{
+		{ai.GPT3, c.OpenAI != nil},
+		{ai.GPTJ, c.GPTJ != nil},
+		{ai.BLOOM, c.BLOOM != nil},
+		{ai.OPT, c.OPT != nil},
+		{ai.CLAUDE, c.Claude != nil},
+		{ai.OLLAMA, c.Ollama != nil},
+		{ai.HUGGINGFACE, c.HuggingFace != nil},
+		{ai.COHERE, c.Cohere != nil},
+		{ai.GEMINI, c.Gemini != nil},
+	}
+	for _, section := range sections {
+		if section.configured {
+			backends = append(backends, section.backend)
+		}
+	}
+	return backends
+}
+
+// SelectBackend Determines which backend to use when none was given on the command-line.
+// An explicit DefaultBackend is preferred, followed by the only configured backend.
+// When no backend is configured, GPT-3 is used as it has always been the default.
+// The DefaultBackend must be one of the configured backends, unless the config doesn't configure any,
+// in which case every backend is set up from the defaults and the environment.
+func (c *Config) SelectBackend() (ai.Backend, error) {
+	configured := c.ConfiguredBackends()
+	if c.DefaultBackend != ai.Unselected {
+		if len(configured) > 0 && !containsBackend(configured, c.DefaultBackend) {
+			return ai.Unselected, fmt.Errorf(
+				"the default backend %q isn't configured, add a section for it to the config or use one of: %s",
+				c.DefaultBackend, backendNames(configured),
+			)
+		}
+		return c.DefaultBackend, nil
+	}
+	if c.Backend != ai.Unselected {
+		return c.Backend, nil
+	}
+	switch len(configured) {
+	case 0:
+		return ai.GPT3, nil
+	case 1:
+		return configured[0], nil
+	default:
+		return ai.Unselected, fmt.Errorf(
+			"multiple backends are configured (%s), set defaultBackend in the config or pass --backend",
+			backendNames(configured),
+		)
+	}
+}
+
+// containsBackend Reports whether the backend is one of the backends.
+func containsBackend(backends []ai.Backend, backend ai.Backend) bool {
+	for _, b := range backends {
+		if b == backend {
+			return true
+		}
+	}
+	return false
+}
+
+// backendNames Returns the names of the backends, separated by commas.
+func backendNames(backends []ai.Backend) string {
+	names := make([]string, len(backends))
+	for i, backend := range backends {
+		names[i] = string(backend)
+	}
+	return strings.Join(names, ", ")
+}
+
+// ApplyProfile Merges the named profile over the config and returns it, so that the
+// settings which aren't part of the config can be applied by the caller.
+// Profile names are matched case-insensitively, as the config file is.
+func (c *Config) ApplyProfile(name string) (*Profile, error) {
+	profile, ok := c.Profiles[name]
+	if !ok {
+		profile, ok = c.Profiles[strings.ToLower(name)]
+	}
+	if !ok {
+		if len(c.Profiles) == 0 {
+			return nil, fmt.Errorf("profile %q not found, no profiles are defined in the config", name)
+		}
+		names := make([]string, 0, len(c.Profiles))
+		for profileName := range c.Profiles {
+			names = append(names, profileName)
+		}
+		sort.Strings(names)
+		return nil, fmt.Errorf("profile %q not found, available profiles: %s", name, strings.Join(names, ", "))
+	}
+	if profile.Backend != ai.Unselected {
+		c.DefaultBackend = profile.Backend
+	}
+	return &profile, nil
+}
+
+// SetModel Sets the model used by the given backend. This must be called after SetDefaults,
+// so that the model isn't replaced by the default one.
+func (c *Config) SetModel(backend ai.Backend, model string) error {
+	switch backend {
+	case ai.GPT3:
+		c.OpenAI.Model = model
+		return nil
+	case ai.CLAUDE:
+		c.Claude.Model = model
+		return nil
+	case ai.OLLAMA:
+		c.Ollama.Model = model
+		return nil
+	case ai.HUGGINGFACE:
+		c.HuggingFace.ModelID = model
+		return nil
+	case ai.COHERE:
+		c.Cohere.Model = model
+		return nil
+	case ai.GEMINI:
+		c.Gemini.Model = model
+		return nil
+	case ai.GPTJ, ai.BLOOM, ai.OPT, ai.Unselected:
+	}
+	return fmt.Errorf("the %q backend does not support choosing a model", backend)
+}
+
+// MissingFields Returns the fields of the backend's section which must be set for it to be used, but aren't.
+// API keys which are read from the environment count as set, so this must be called after SetDefaults.
+func (c *Config) MissingFields(backend ai.Backend) []string {
+	var missing []string
+	require := func(field string, set bool) {
+		if !set {
+			missing = append(missing, field)
+		}
+	}
+	switch backend {
+	case ai.GPT3:
+		// servers other than OpenAI's may not need a key
+		if c.OpenAI.IsAzure() || c.OpenAI.BaseURL == gpt3.OpenAIURL+gpt3.OpenAIEndpointV1 {
+			require("openAI.apiKey", c.OpenAI.APIKey != "")
+		}
+		if c.OpenAI.IsAzure() {
+			require("openAI.deployment", c.OpenAI.Deployment != "")
+		}
+	case ai.GPTJ:
+		require("gptj.url", c.GPTJ.URL != "")
+	case ai.BLOOM:
+		require("bloom.url", c.BLOOM.URL != "")
+	case ai.OPT:
+		require("opt.url", c.OPT.URL != "")
+	case ai.CLAUDE:
+		require("claude.apiKey", c.Claude.APIKey != "")
+	case ai.OLLAMA:
+		require("ollama.url", c.Ollama.URL != "")
+	case ai.HUGGINGFACE:
+		require("huggingface.modelID", c.HuggingFace.ModelID != "")
+	case ai.COHERE:
+		require("cohere.apiKey", c.Cohere.APIKey != "")
+	case ai.GEMINI:
+		require("gemini.apiKey", c.Gemini.APIKey != "" || c.Gemini.AccessToken != "")
+	case ai.Unselected:
+	}
+	return missing
+}
+
+// HTTPClients Returns the HTTP client of every backend, so that they can all be configured at once.
+// This must be called after SetDefaults.
+func (c *Config) HTTPClients() []**http.Client {
+	clients := []**http.Client{
+		&c.OpenAI.HTTPClient,
+		&c.GPTJ.HTTPClient,
+		&c.BLOOM.HTTPClient,
+		&c.OPT.HTTPClient,
+		&c.Claude.HTTPClient,
+		&c.Ollama.HTTPClient,
+		&c.HuggingFace.HTTPClient,
+		&c.Cohere.HTTPClient,
+		&c.Gemini.HTTPClient,
+	}
+	if c.Embeddings != nil {
+		clients = append(clients, &c.Embeddings.HTTPClient)
+	}
+	return clients
+}
+
+// WrapTransports Wraps the transport of every backend's HTTP client, e.g. to stub or instrument the requests
+// made to all of them at once. The clients which were set are copied rather than changed, and the backends
+// without a client of their own share a single one wrapping http.DefaultTransport.
+// This must be called after SetDefaults.
+func (c *Config) WrapTransports(wrap func(http.RoundTripper) http.RoundTripper) {
+	var shared *http.Client
+	for _, client := range c.HTTPClients() {
+		if *client == nil {
+			if shared == nil {
+				shared = &http.Client{Transport: wrap(http.DefaultTransport)}
+			}
+			*client = shared
+			continue
+		}
+		wrapped := **client
+		if wrapped.Transport == nil {
+			wrapped.Transport = http.DefaultTransport
+		}
+		wrapped.Transport = wrap(wrapped.Transport)
+		*client = &wrapped
+	}
+}
+
+// Secrets Returns the API keys set in the config, which must never be printed.
+func (c *Config) Secrets() []string {
+	var secrets []string
+	if c.OpenAI != nil && c.OpenAI.APIKey != "" {
+		secrets = append(secrets, c.OpenAI.APIKey)
+	}
+	if c.Claude != nil && c.Claude.APIKey != "" {
+		secrets = append(secrets, c.Claude.APIKey)
+	}
+	if c.HuggingFace != nil && c.HuggingFace.APIKey != "" {
+		secrets = append(secrets, c.HuggingFace.APIKey)
+	}
+	if c.Cohere != nil && c.Cohere.APIKey != "" {
+		secrets = append(secrets, c.Cohere.APIKey)
+	}
+	if c.Gemini != nil && c.Gemini.APIKey != "" {
+		secrets = append(secrets, c.Gemini.APIKey)
+	}
+	if c.Gemini != nil && c.Gemini.AccessToken != "" {
+		secrets = append(secrets, c.Gemini.AccessToken)
+	}
+	if c.GPTJ != nil && c.GPTJ.APIKey != "" {
+		secrets = append(secrets, c.GPTJ.APIKey)
+	}
+	if c.BLOOM != nil && c.BLOOM.APIKey != "" {
+		secrets = append(secrets, c.BLOOM.APIKey)
+	}
+	if c.Embeddings != nil && c.Embeddings.APIKey != "" {
+		secrets = append(secrets, c.Embeddings.APIKey)
+	}
+	return secrets
+}
+
+// EmbeddingsConfig Returns the config of the embeddings endpoint. Without a URL of its own,
+// embeddings are requested from the OpenAI API with the OpenAI API key, billed to the same
+// organization and project, so this must be called after SetDefaults.
+func (c *Config) EmbeddingsConfig() embeddings.Config {
+	conf := embeddings.Config{}
+	if c.Embeddings != nil {
+		conf = *c.Embeddings
+	}
+	if conf.URL == "" && c.OpenAI != nil {
+		conf.URL = c.OpenAI.BaseURL
+		if conf.APIKey == "" {
+			conf.APIKey = c.OpenAI.APIKey
+		}
+		if conf.HTTPClient == nil {
+			conf.HTTPClient = c.OpenAI.HTTPClient
+			if !c.OpenAI.IsAzure() {
+				conf.HTTPClient = c.OpenAI.Client()
+			}
+		}
+	}
+	return conf
 }
 
 // FindFileset Returns a fileset with the matching name,
@@ -123,3 +534,45 @@ func (c *Config) FindFileset(name string) *Filesets {
 	}
 	return nil
 }
+
+// ApplyFilesets Merges the settings of the named filesets over the config and returns them,
+// so that the settings which aren't part of the config can be applied by the caller.
+// Filesets named later override the settings of earlier ones, and missing filesets are skipped.
+func (c *Config) ApplyFilesets(names []string) Filesets {
+	var settings Filesets
+	for _, name := range names {
+		fileset := c.FindFileset(name)
+		if fileset == nil {
+			continue
+		}
+		if fileset.Backend != ai.Unselected {
+			settings.Backend = fileset.Backend
+		}
+		if fileset.NTokens > 0 {
+			settings.NTokens = fileset.NTokens
+		}
+		if fileset.NCompletions > 0 {
+			settings.NCompletions = fileset.NCompletions
+		}
+		if fileset.Temperature != nil {
+			settings.Temperature = fileset.Temperature
+		}
+	}
+	if settings.Backend != ai.Unselected {
+		c.DefaultBackend = settings.Backend
+	}
+	return settings
+}
+
+// CacheDir Returns the directory where copilot-ops stores its cached data,
+// which can be overridden by setting COPILOT_OPS_CACHE_DIR.
+func CacheDir() (string, error) {
+	if dir := os.Getenv(CacheDirEnv); dir != "" {
+		return dir, nil
+	}
+	userCache, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(userCache, CacheDirName), nil
+}
@@ -1,9 +1,22 @@
 package config_test
 
 import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
 
+	"github.com/redhat-et/copilot-ops/pkg/ai"
+	"github.com/redhat-et/copilot-ops/pkg/ai/bloom"
+	"github.com/redhat-et/copilot-ops/pkg/ai/claude"
+	"github.com/redhat-et/copilot-ops/pkg/ai/embeddings"
+	"github.com/redhat-et/copilot-ops/pkg/ai/gpt3"
+	"github.com/redhat-et/copilot-ops/pkg/ai/gptj"
+	"github.com/redhat-et/copilot-ops/pkg/ai/ollama"
 	"github.com/redhat-et/copilot-ops/pkg/cmd/config"
 )
 
@@ -37,6 +50,224 @@ var _ = Describe("Config", func() {
 				// config should find a fileset named "TEST"
 				Expect(conf.FindFileset("TEST")).To(BeNil())
 			})
+
+			It("merges the settings of the selected filesets over the config", func() {
+				low, high := float32(0), float32(0.8)
+				conf.DefaultBackend = ai.GPT3
+				conf.Filesets = append(conf.Filesets,
+					config.Filesets{Name: "configmaps", Backend: ai.OLLAMA, NTokens: 256, Temperature: &low},
+					config.Filesets{Name: "crds", NTokens: 4096, NCompletions: 2, Temperature: &high},
+				)
+
+				settings := conf.ApplyFilesets([]string{"configmaps"})
+				Expect(settings.NTokens).To(Equal(int32(256)))
+				Expect(settings.Temperature).To(Equal(&low))
+				Expect(conf.SelectBackend()).To(Equal(ai.OLLAMA))
+
+				// later filesets win, but keep the settings they leave empty
+				settings = conf.ApplyFilesets([]string{"configmaps", "crds", "missing"})
+				Expect(settings.NTokens).To(Equal(int32(4096)))
+				Expect(settings.NCompletions).To(Equal(int32(2)))
+				Expect(settings.Temperature).To(Equal(&high))
+				Expect(settings.Backend).To(Equal(ai.OLLAMA))
+			})
+
+			It("leaves the config alone for filesets without settings", func() {
+				conf.DefaultBackend = ai.GPT3
+				Expect(conf.ApplyFilesets([]string{"test"})).To(Equal(config.Filesets{}))
+				Expect(conf.DefaultBackend).To(Equal(ai.GPT3))
+			})
+		})
+
+		When("selecting a backend", func() {
+			It("uses GPT-3 when no backend is configured", func() {
+				Expect(conf.ConfiguredBackends()).To(BeEmpty())
+				Expect(conf.SelectBackend()).To(Equal(ai.GPT3))
+			})
+
+			It("uses the only configured backend", func() {
+				conf.Ollama = &ollama.Config{}
+				Expect(conf.SelectBackend()).To(Equal(ai.OLLAMA))
+			})
+
+			It("prefers the default backend", func() {
+				conf.OpenAI = &gpt3.Config{}
+				conf.Claude = &claude.Config{}
+				conf.DefaultBackend = ai.CLAUDE
+				Expect(conf.SelectBackend()).To(Equal(ai.CLAUDE))
+			})
+
+			It("rejects a default backend which isn't configured", func() {
+				conf.OpenAI = &gpt3.Config{}
+				conf.Ollama = &ollama.Config{}
+				conf.DefaultBackend = ai.CLAUDE
+				_, err := conf.SelectBackend()
+				Expect(err).To(MatchError(`the default backend "claude" isn't configured, ` +
+					"add a section for it to the config or use one of: gpt-3, ollama"))
+			})
+
+			It("accepts any default backend when none is configured", func() {
+				conf.DefaultBackend = ai.CLAUDE
+				Expect(conf.SelectBackend()).To(Equal(ai.CLAUDE))
+			})
+
+			It("still honors the backend field", func() {
+				conf.OpenAI = &gpt3.Config{}
+				conf.Claude = &claude.Config{}
+				conf.Backend = ai.GPT3
+				Expect(conf.SelectBackend()).To(Equal(ai.GPT3))
+			})
+
+			It("lists the backends when more than one is configured", func() {
+				conf.OpenAI = &gpt3.Config{}
+				conf.Claude = &claude.Config{}
+				conf.Ollama = &ollama.Config{}
+				Expect(conf.ConfiguredBackends()).To(Equal([]ai.Backend{ai.GPT3, ai.CLAUDE, ai.OLLAMA}))
+				_, err := conf.SelectBackend()
+				Expect(err).To(MatchError(
+					"multiple backends are configured (gpt-3, claude, ollama), " +
+						"set defaultBackend in the config or pass --backend",
+				))
+			})
+
+			It("ignores backends filled in by the defaults", func() {
+				conf.Claude = &claude.Config{}
+				conf.SetDefaults()
+				Expect(conf.ConfiguredBackends()).To(HaveLen(9))
+			})
+		})
+
+		When("a profile is selected", func() {
+			BeforeEach(func() {
+				conf.OpenAI = &gpt3.Config{}
+				conf.Claude = &claude.Config{Model: "claude-base"}
+				conf.DefaultBackend = ai.GPT3
+				conf.Profiles = map[string]config.Profile{
+					"fast": {Backend: ai.CLAUDE, Model: "claude-fast", NTokens: 256},
+					"more": {NCompletions: 3},
+				}
+			})
+
+			It("overrides the backend and the model of the base config", func() {
+				profile, err := conf.ApplyProfile("fast")
+				Expect(err).NotTo(HaveOccurred())
+				Expect(profile.NTokens).To(Equal(int32(256)))
+				backend, err := conf.SelectBackend()
+				Expect(err).NotTo(HaveOccurred())
+				Expect(backend).To(Equal(ai.CLAUDE))
+				conf.SetDefaults()
+				Expect(conf.SetModel(backend, profile.Model)).To(Succeed())
+				Expect(conf.Claude.Model).To(Equal("claude-fast"))
+			})
+
+			It("keeps the base config for the fields it leaves empty", func() {
+				profile, err := conf.ApplyProfile("more")
+				Expect(err).NotTo(HaveOccurred())
+				Expect(profile.NCompletions).To(Equal(int32(3)))
+				Expect(conf.SelectBackend()).To(Equal(ai.GPT3))
+				Expect(conf.Claude.Model).To(Equal("claude-base"))
+			})
+
+			It("matches the profile name case-insensitively", func() {
+				_, err := conf.ApplyProfile("FAST")
+				Expect(err).NotTo(HaveOccurred())
+			})
+
+			It("lists the available profiles when the profile is missing", func() {
+				_, err := conf.ApplyProfile("slow")
+				Expect(err).To(MatchError(`profile "slow" not found, available profiles: fast, more`))
+			})
+
+			It("fails when no profiles are defined", func() {
+				conf.Profiles = nil
+				_, err := conf.ApplyProfile("fast")
+				Expect(err).To(MatchError(`profile "fast" not found, no profiles are defined in the config`))
+			})
+
+			It("refuses a model for backends with a fixed model", func() {
+				Expect(conf.SetModel(ai.GPTJ, "gpt-j-6b")).To(MatchError(
+					`the "gpt-j" backend does not support choosing a model`,
+				))
+			})
+		})
+
+		When("files are embedded", func() {
+			BeforeEach(func() {
+				conf.OpenAI = &gpt3.Config{APIKey: "sk-openai", BaseURL: "https://openai.example.com/v1"}
+			})
+
+			It("uses the OpenAI API by default", func() {
+				embeddingsConf := conf.EmbeddingsConfig()
+				Expect(embeddingsConf.URL).To(Equal("https://openai.example.com/v1"))
+				Expect(embeddingsConf.APIKey).To(Equal("sk-openai"))
+			})
+
+			It("doesn't send the OpenAI API key to another endpoint", func() {
+				conf.Embeddings = &embeddings.Config{URL: "http://localhost:8080/v1", Model: "nomic-embed-text"}
+				embeddingsConf := conf.EmbeddingsConfig()
+				Expect(embeddingsConf.URL).To(Equal("http://localhost:8080/v1"))
+				Expect(embeddingsConf.APIKey).To(BeEmpty())
+				Expect(embeddingsConf.Model).To(Equal("nomic-embed-text"))
+			})
+		})
+
+		When("the transports are wrapped", func() {
+			var requests []string
+
+			BeforeEach(func() {
+				requests = nil
+				conf.OpenAI = &gpt3.Config{APIKey: "sk-openai", Model: "gpt-4o"}
+				conf.GPTJ = &gptj.Config{URL: "https://gptj.example.com", HTTPClient: &http.Client{Timeout: time.Minute}}
+				conf.BLOOM = &bloom.Config{URL: "https://bloom.example.com/generate"}
+				conf.SetDefaults()
+				// the requests are answered by the transport, without a server
+				conf.WrapTransports(func(http.RoundTripper) http.RoundTripper {
+					return roundTripFunc(func(req *http.Request) (*http.Response, error) {
+						requests = append(requests, req.Method+" "+req.URL.String()+" "+req.Header.Get("Authorization"))
+						body := `[{"generated_text": "kind: Pod\n"}]`
+						if req.URL.Host == "api.openai.com" {
+							body = `{"choices": [{"message": {"role": "assistant", "content": "kind: Pod\n"}}]}`
+						}
+						return &http.Response{
+							StatusCode: http.StatusOK,
+							Header:     http.Header{"Content-Type": []string{"application/json"}},
+							Body:       io.NopCloser(strings.NewReader(body)),
+							Request:    req,
+						}, nil
+					})
+				})
+			})
+
+			It("makes the requests of every backend through the transport", func() {
+				clients := []ai.GenerateClient{
+					gpt3.CreateGPT3GenerateClient(*conf.OpenAI, "Create a Pod", 64, 1, 0, nil),
+					gptj.CreateGPTJGenerateClient(*conf.GPTJ, gptj.GenerateParams{Context: "Create a Pod"}),
+					bloom.CreateBloomGenerateClient(*conf.BLOOM, "Create a Pod", bloom.GenerateParameters{}),
+				}
+				for _, client := range clients {
+					completions, err := client.Generate(context.Background())
+					Expect(err).NotTo(HaveOccurred())
+					Expect(completions).To(HaveLen(1))
+				}
+				Expect(requests).To(Equal([]string{
+					"POST https://api.openai.com/v1/chat/completions Bearer sk-openai",
+					"POST https://gptj.example.com/" + gptj.CompletionEndpoint + " ",
+					"POST https://bloom.example.com/generate ",
+				}))
+			})
+
+			It("keeps the settings of the clients it was given", func() {
+				Expect(conf.GPTJ.HTTPClient.Timeout).To(Equal(time.Minute))
+				Expect(conf.BLOOM.HTTPClient).To(BeIdenticalTo(conf.OPT.HTTPClient))
+			})
 		})
 	})
 })
+
+// roundTripFunc Answers requests with the function, standing in for a transport.
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+// RoundTrip Calls the function.
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
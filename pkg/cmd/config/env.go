@@ -0,0 +1,108 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+)
+
+// ExpandEnvReferences Replaces every ${VAR} in the value with the value of the environment variable,
+// so that secrets don't have to be written into the config file. A default can be given as
+// ${VAR:-default}, which is used when the variable is unset or empty. Unlike the request,
+// a bare $VAR is left as-is, since prompt templates and summaries may contain a literal '$'.
+func ExpandEnvReferences(value string) (string, error) {
+	var out strings.Builder
+	var missing []string
+	for {
+		start := strings.Index(value, "${")
+		if start < 0 {
+			break
+		}
+		end := strings.IndexByte(value[start:], '}')
+		if end < 0 {
+			return "", fmt.Errorf("unterminated variable reference at %q", value[start:])
+		}
+		out.WriteString(value[:start])
+		name, defaultValue, hasDefault := strings.Cut(value[start+2:start+end], ":-")
+		envValue, ok := os.LookupEnv(name)
+		switch {
+		case hasDefault && envValue == "":
+			envValue = defaultValue
+		case !ok:
+			missing = append(missing, name)
+		}
+		out.WriteString(envValue)
+		value = value[start+end+1:]
+	}
+	out.WriteString(value)
+
+	if len(missing) > 0 {
+		return "", fmt.Errorf("environment variables are not set: %s (use ${VAR:-default} to provide a default)",
+			strings.Join(missing, ", "))
+	}
+	return out.String(), nil
+}
+
+// expandEnvHook Expands the environment variables referenced in every string of the config while it's decoded.
+func expandEnvHook(from, _ reflect.Kind, data interface{}) (interface{}, error) {
+	if from != reflect.String {
+		return data, nil
+	}
+	value, _ := data.(string)
+	return ExpandEnvReferences(value)
+}
+
+// Environment variables which values of the config are read from when they aren't set in the config file.
+const (
+	OpenAIAPIKeyEnv        = "OPENAI_API_KEY"
+	OpenAIOrgIDEnv         = "OPENAI_ORG_ID"
+	OpenAIProjectIDEnv     = "OPENAI_PROJECT_ID"
+	AzureOpenAIEndpointEnv = "AZURE_OPENAI_ENDPOINT"
+	AnthropicAPIKeyEnv     = "ANTHROPIC_API_KEY"
+	HFTokenEnv             = "HF_TOKEN"
+	HuggingFaceAPITokenEnv = "HUGGINGFACE_API_TOKEN"
+	CohereAPIKeyEnv        = "COHERE_API_KEY"
+	CoAPIKeyEnv            = "CO_API_KEY"
+	GeminiAPIKeyEnv        = "GEMINI_API_KEY"
+	GoogleAPIKeyEnv        = "GOOGLE_API_KEY"
+	GoogleAccessTokenEnv   = "GOOGLE_OAUTH_ACCESS_TOKEN"
+)
+
+// envFallback Describes a value of the config which is read from the first set environment variable
+// when it's left empty.
+type envFallback struct {
+	value *string
+	envs  []string
+}
+
+// applyEnvFallbacks Fills in the values left empty in the config from the environment,
+// so that values in the config file always take precedence. This must be called once
+// every section of the config exists.
+func (c *Config) applyEnvFallbacks() {
+	if c.OpenAI.OrgID == nil {
+		if orgID := os.Getenv(OpenAIOrgIDEnv); orgID != "" {
+			c.OpenAI.OrgID = &orgID
+		}
+	}
+	fallbacks := []envFallback{
+		{&c.OpenAI.APIKey, []string{OpenAIAPIKeyEnv}},
+		{&c.OpenAI.Project, []string{OpenAIProjectIDEnv}},
+		{&c.OpenAI.AzureEndpoint, []string{AzureOpenAIEndpointEnv}},
+		{&c.Claude.APIKey, []string{AnthropicAPIKeyEnv}},
+		{&c.Cohere.APIKey, []string{CohereAPIKeyEnv, CoAPIKeyEnv}},
+		{&c.Gemini.APIKey, []string{GeminiAPIKeyEnv, GoogleAPIKeyEnv}},
+		{&c.Gemini.AccessToken, []string{GoogleAccessTokenEnv}},
+		{&c.HuggingFace.APIKey, []string{HFTokenEnv, HuggingFaceAPITokenEnv}},
+		{&c.GPTJ.APIKey, []string{HuggingFaceAPITokenEnv, HFTokenEnv}},
+		{&c.BLOOM.APIKey, []string{HuggingFaceAPITokenEnv, HFTokenEnv}},
+	}
+	for _, fallback := range fallbacks {
+		for _, env := range fallback.envs {
+			if *fallback.value != "" {
+				break
+			}
+			*fallback.value = os.Getenv(env)
+		}
+	}
+}
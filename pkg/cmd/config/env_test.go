@@ -0,0 +1,147 @@
+package config_test
+
+import (
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/spf13/viper"
+
+	"github.com/redhat-et/copilot-ops/pkg/ai"
+	"github.com/redhat-et/copilot-ops/pkg/cmd/config"
+)
+
+var _ = Describe("Environment variables in the config", func() {
+	BeforeEach(func() {
+		DeferCleanup(os.Unsetenv, "COPILOT_OPS_TEST_KEY")
+		DeferCleanup(os.Unsetenv, "COPILOT_OPS_TEST_MODEL")
+		Expect(os.Setenv("COPILOT_OPS_TEST_KEY", "sk-test")).To(Succeed())
+	})
+
+	It("expands references to set variables", func() {
+		Expect(config.ExpandEnvReferences("Bearer ${COPILOT_OPS_TEST_KEY}!")).To(Equal("Bearer sk-test!"))
+	})
+
+	It("uses the default of unset or empty variables", func() {
+		Expect(config.ExpandEnvReferences("${COPILOT_OPS_TEST_MODEL:-codellama}")).To(Equal("codellama"))
+		Expect(os.Setenv("COPILOT_OPS_TEST_MODEL", "")).To(Succeed())
+		Expect(config.ExpandEnvReferences("${COPILOT_OPS_TEST_MODEL:-codellama}")).To(Equal("codellama"))
+		Expect(config.ExpandEnvReferences("${COPILOT_OPS_TEST_KEY:-none}")).To(Equal("sk-test"))
+	})
+
+	It("leaves a bare $ alone", func() {
+		Expect(config.ExpandEnvReferences("costs $5 or $COPILOT_OPS_TEST_KEY")).To(
+			Equal("costs $5 or $COPILOT_OPS_TEST_KEY"),
+		)
+	})
+
+	It("fails for unset variables without a default", func() {
+		_, err := config.ExpandEnvReferences("${COPILOT_OPS_TEST_MODEL}")
+		Expect(err).To(MatchError(ContainSubstring("environment variables are not set: COPILOT_OPS_TEST_MODEL")))
+		_, err = config.ExpandEnvReferences("${COPILOT_OPS_TEST_KEY")
+		Expect(err).To(MatchError(ContainSubstring("unterminated variable reference")))
+	})
+
+	When("loading the config file", func() {
+		BeforeEach(func() {
+			dir := GinkgoT().TempDir()
+			wd, err := os.Getwd()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(os.Chdir(dir)).To(Succeed())
+			DeferCleanup(os.Chdir, wd)
+			viper.Reset()
+			DeferCleanup(viper.Reset)
+		})
+
+		writeConfig := func(content string) {
+			Expect(os.WriteFile(filepath.Join(".", config.ConfigFile), []byte(content), 0600)).To(Succeed())
+		}
+
+		It("expands the referenced variables", func() {
+			writeConfig("openAI:\n  apiKey: ${COPILOT_OPS_TEST_KEY}\n" +
+				"ollama:\n  model: ${COPILOT_OPS_TEST_MODEL:-codellama}\n")
+			conf := config.Config{}
+			Expect(conf.Load()).To(Succeed())
+			Expect(conf.OpenAI.APIKey).To(Equal("sk-test"))
+			Expect(conf.Ollama.Model).To(Equal("codellama"))
+		})
+
+		It("fails clearly when a variable is unset", func() {
+			writeConfig("ollama:\n  model: ${COPILOT_OPS_TEST_MODEL}\n")
+			conf := config.Config{}
+			err := conf.Load()
+			Expect(err).To(MatchError(ContainSubstring("COPILOT_OPS_TEST_MODEL")))
+			Expect(err).To(MatchError(ContainSubstring("Ollama.Model")))
+		})
+	})
+})
+
+var _ = Describe("API keys from the environment", func() {
+	BeforeEach(func() {
+		wd, err := os.Getwd()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(os.Chdir(GinkgoT().TempDir())).To(Succeed())
+		DeferCleanup(os.Chdir, wd)
+		viper.Reset()
+		DeferCleanup(viper.Reset)
+
+		// start from a clean environment, restoring the variables afterwards
+		for _, name := range []string{
+			config.OpenAIAPIKeyEnv, config.OpenAIOrgIDEnv, config.OpenAIProjectIDEnv, config.AzureOpenAIEndpointEnv,
+			config.AnthropicAPIKeyEnv, config.HFTokenEnv, config.HuggingFaceAPITokenEnv,
+		} {
+			if value, ok := os.LookupEnv(name); ok {
+				DeferCleanup(os.Setenv, name, value)
+			} else {
+				DeferCleanup(os.Unsetenv, name)
+			}
+			Expect(os.Unsetenv(name)).To(Succeed())
+		}
+	})
+
+	// load Loads the config file with the given content, and fills in the defaults.
+	load := func(content string) config.Config {
+		Expect(os.WriteFile(config.ConfigFile, []byte(content), 0600)).To(Succeed())
+		conf := config.Config{}
+		Expect(conf.Load()).To(Succeed())
+		conf.SetDefaults()
+		return conf
+	}
+
+	It("reads the keys missing from the config", func() {
+		Expect(os.Setenv(config.OpenAIAPIKeyEnv, "sk-openai")).To(Succeed())
+		Expect(os.Setenv(config.OpenAIOrgIDEnv, "org-env")).To(Succeed())
+		Expect(os.Setenv(config.OpenAIProjectIDEnv, "proj-env")).To(Succeed())
+		Expect(os.Setenv(config.AnthropicAPIKeyEnv, "sk-ant")).To(Succeed())
+		Expect(os.Setenv(config.HuggingFaceAPITokenEnv, "hf-token")).To(Succeed())
+
+		conf := load("ollama:\n  model: codellama\n")
+		Expect(conf.OpenAI.APIKey).To(Equal("sk-openai"))
+		Expect(conf.OpenAI.OrgID).To(HaveValue(Equal("org-env")))
+		Expect(conf.OpenAI.Project).To(Equal("proj-env"))
+		Expect(conf.Claude.APIKey).To(Equal("sk-ant"))
+		Expect(conf.GPTJ.APIKey).To(Equal("hf-token"))
+		Expect(conf.BLOOM.APIKey).To(Equal("hf-token"))
+		Expect(conf.HuggingFace.APIKey).To(Equal("hf-token"))
+		Expect(conf.Secrets()).To(ContainElements("sk-openai", "sk-ant", "hf-token"))
+	})
+
+	It("prefers the keys in the config", func() {
+		Expect(os.Setenv(config.OpenAIAPIKeyEnv, "sk-openai")).To(Succeed())
+		Expect(os.Setenv(config.HFTokenEnv, "hf-token")).To(Succeed())
+
+		conf := load("openAI:\n  apiKey: sk-config\nbloom:\n  apiKey: hf-config\n")
+		Expect(conf.OpenAI.APIKey).To(Equal("sk-config"))
+		Expect(conf.BLOOM.APIKey).To(Equal("hf-config"))
+		Expect(conf.GPTJ.APIKey).To(Equal("hf-token"))
+	})
+
+	It("doesn't count a backend as configured because of its key", func() {
+		Expect(os.Setenv(config.AnthropicAPIKeyEnv, "sk-ant")).To(Succeed())
+		Expect(os.WriteFile(config.ConfigFile, []byte("ollama:\n  model: codellama\n"), 0600)).To(Succeed())
+		conf := config.Config{}
+		Expect(conf.Load()).To(Succeed())
+		Expect(conf.SelectBackend()).To(Equal(ai.OLLAMA))
+	})
+})
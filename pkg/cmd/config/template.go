@@ -0,0 +1,120 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/redhat-et/copilot-ops/pkg/ai"
+	"github.com/redhat-et/copilot-ops/pkg/ai/bloom"
+	"github.com/redhat-et/copilot-ops/pkg/ai/claude"
+	"github.com/redhat-et/copilot-ops/pkg/ai/cohere"
+	"github.com/redhat-et/copilot-ops/pkg/ai/embeddings"
+	"github.com/redhat-et/copilot-ops/pkg/ai/gemini"
+	"github.com/redhat-et/copilot-ops/pkg/ai/gpt3"
+	"github.com/redhat-et/copilot-ops/pkg/ai/gptj"
+	"github.com/redhat-et/copilot-ops/pkg/ai/hfinference"
+	"github.com/redhat-et/copilot-ops/pkg/ai/ollama"
+)
+
+// ErrConfigExists Is returned when scaffolding a config file over an existing one.
+var ErrConfigExists = errors.New("the config file already exists")
+
+// Template Returns the content of a new config file, which configures OpenAI and
+// stubs out a section for every other backend along with an example fileset.
+// Every section but OpenAI's is commented out, so that only one backend is configured.
+func Template() string {
+	return `# The config file of copilot-ops. Values may reference environment variables as ${VAR} or ${VAR:-default}.
+
+# The backend used when --backend isn't passed: ` + string(ai.GPT3) + `, ` + string(ai.GPTJ) + `, ` + string(ai.BLOOM) +
+		`, ` + string(ai.OPT) + `, ` + string(ai.CLAUDE) + `, ` + string(ai.OLLAMA) + `, ` + string(ai.HUGGINGFACE) +
+		`, ` + string(ai.COHERE) + `, or ` + string(ai.GEMINI) + `.
+defaultBackend: ` + string(ai.GPT3) + `
+# Backends tried in order when the default backend is rate-limited or unavailable.
+# fallbackBackends: [` + string(ai.OLLAMA) + `]
+
+# OpenAI, reading the API key from the OPENAI_API_KEY environment variable unless it's set here.
+openAI:
+  baseURL: ` + gpt3.OpenAIURL + gpt3.OpenAIEndpointV1 + `
+  # apiKey: ${OPENAI_API_KEY}
+  # orgID: my-organization
+  # project: my-project
+  # model: gpt-4o-mini
+  # to use Azure OpenAI instead, set the endpoint of the resource and the name of the deployment
+  # azureEndpoint: https://my-resource.openai.azure.com
+  # deployment: my-deployment
+
+# GPT-J, hosted by EleutherAI.
+# gptj:
+#   url: ` + gptj.APIURL + `
+
+# BLOOM, hosted on the HuggingFace Inference API.
+# bloom:
+#   url: ` + bloom.APIURL + `
+
+# Claude, reading the API key from the ANTHROPIC_API_KEY environment variable unless it's set here.
+# claude:
+#   url: ` + claude.APIURL + `
+#   model: ` + claude.DefaultModel + `
+
+# A model served locally by Ollama.
+# ollama:
+#   url: ` + ollama.APIURL + `
+#   model: ` + ollama.DefaultModel + `
+#   # trust the CA which signed the server's certificate, also supported by gptj and bloom
+#   caCertFile: /etc/pki/internal-ca.pem
+
+# Any model on the HuggingFace Inference API, reading the token from the HF_TOKEN environment variable.
+# huggingface:
+#   url: ` + hfinference.APIURL + `
+#   modelID: bigcode/starcoder
+
+# Cohere, reading the API key from the COHERE_API_KEY environment variable unless it's set here.
+# cohere:
+#   url: ` + cohere.APIURL + `
+#   model: ` + cohere.DefaultModel + `
+
+# Gemini, reading the API key from the GEMINI_API_KEY environment variable unless it's set here.
+# Set a project to use Vertex AI instead, with an access token from GOOGLE_OAUTH_ACCESS_TOKEN.
+# gemini:
+#   model: ` + gemini.DefaultModel + `
+#   # project: my-project
+#   # location: ` + gemini.DefaultLocation + `
+
+# The endpoint which embeds files to pick the relevant ones with --auto-context, defaulting to OpenAI's.
+# embeddings:
+#   url: ` + gpt3.OpenAIURL + gpt3.OpenAIEndpointV1 + `
+#   model: ` + embeddings.DefaultModel + `
+
+# Groups of files which can be passed as context together with --fileset.
+filesets:
+  - name: manifests
+    files:
+      - "manifests/**/*.yaml"
+    # exclude:
+    #   - "manifests/**/secret*.yaml"
+    # settings used when the fileset is selected, unless overridden by --profile or the flags
+    # ntokens: 1024
+    # temperature: 0.2
+`
+}
+
+// WriteTemplate Writes the template to the config file in the given directory,
+// refusing to overwrite an existing config file unless force is set.
+// The path of the written file is returned.
+func WriteTemplate(dir string, force bool) (string, error) {
+	path := filepath.Join(dir, ConfigFile)
+	_, err := os.Stat(path)
+	if err == nil && !force {
+		return "", fmt.Errorf("%w: %s", ErrConfigExists, path)
+	}
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return "", err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+	return path, os.WriteFile(path, []byte(Template()), 0600)
+}
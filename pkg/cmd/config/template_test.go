@@ -0,0 +1,52 @@
+package config_test
+
+import (
+	"os"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/spf13/viper"
+
+	"github.com/redhat-et/copilot-ops/pkg/ai"
+	"github.com/redhat-et/copilot-ops/pkg/ai/gpt3"
+	"github.com/redhat-et/copilot-ops/pkg/cmd/config"
+)
+
+var _ = Describe("Config template", func() {
+	BeforeEach(func() {
+		wd, err := os.Getwd()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(os.Chdir(GinkgoT().TempDir())).To(Succeed())
+		DeferCleanup(os.Chdir, wd)
+		viper.Reset()
+		DeferCleanup(viper.Reset)
+	})
+
+	It("loads back with OpenAI as the backend", func() {
+		path, err := config.WriteTemplate(".", false)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(path).To(Equal(config.ConfigFile))
+
+		conf := config.Config{}
+		Expect(conf.Load()).To(Succeed())
+		// backends may also be configured by their API keys in the environment
+		Expect(conf.ConfiguredBackends()).To(ContainElement(ai.GPT3))
+		for _, backend := range []ai.Backend{ai.GPTJ, ai.BLOOM, ai.OPT, ai.OLLAMA} {
+			Expect(conf.ConfiguredBackends()).NotTo(ContainElement(backend))
+		}
+		Expect(conf.SelectBackend()).To(Equal(ai.GPT3))
+		Expect(conf.OpenAI.BaseURL).To(Equal(gpt3.OpenAIURL + gpt3.OpenAIEndpointV1))
+		Expect(conf.FindFileset("manifests")).NotTo(BeNil())
+	})
+
+	It("refuses to overwrite an existing config file unless forced", func() {
+		Expect(os.WriteFile(config.ConfigFile, []byte("backend: ollama\n"), 0600)).To(Succeed())
+		_, err := config.WriteTemplate(".", false)
+		Expect(err).To(MatchError(config.ErrConfigExists))
+		Expect(os.ReadFile(config.ConfigFile)).To(Equal([]byte("backend: ollama\n")))
+
+		_, err = config.WriteTemplate(".", true)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(os.ReadFile(config.ConfigFile)).To(Equal([]byte(config.Template())))
+	})
+})
@@ -0,0 +1,43 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/redhat-et/copilot-ops/pkg/ai"
+	"github.com/redhat-et/copilot-ops/pkg/utils"
+)
+
+// ErrInsecureTLS Is returned when a backend is configured to skip verifying certificates without it being allowed.
+var ErrInsecureTLS = errors.New("skipping the verification of TLS certificates must be allowed explicitly")
+
+// ConfigureTLS Creates the HTTP clients of the self-hosted backends whose servers have certificates
+// signed by a custom CA, or which skip verifying them. Skipping the verification fails unless
+// allowInsecure is set, so that it's never enabled by a config file alone. This must be called after SetDefaults.
+func (c *Config) ConfigureTLS(allowInsecure bool) error {
+	backends := []struct {
+		backend            ai.Backend
+		caCertFile         string
+		insecureSkipVerify bool
+		httpClient         **http.Client
+	}{
+		{ai.GPTJ, c.GPTJ.CACertFile, c.GPTJ.InsecureSkipVerify, &c.GPTJ.HTTPClient},
+		{ai.BLOOM, c.BLOOM.CACertFile, c.BLOOM.InsecureSkipVerify, &c.BLOOM.HTTPClient},
+		{ai.OLLAMA, c.Ollama.CACertFile, c.Ollama.InsecureSkipVerify, &c.Ollama.HTTPClient},
+	}
+	for _, b := range backends {
+		if b.caCertFile == "" && !b.insecureSkipVerify {
+			continue
+		}
+		if b.insecureSkipVerify && !allowInsecure {
+			return fmt.Errorf("the %s backend sets insecureSkipVerify: %w", b.backend, ErrInsecureTLS)
+		}
+		client, err := utils.TLSClient(b.caCertFile, b.insecureSkipVerify)
+		if err != nil {
+			return fmt.Errorf("could not configure TLS for the %s backend: %w", b.backend, err)
+		}
+		*b.httpClient = client
+	}
+	return nil
+}
@@ -0,0 +1,80 @@
+package config_test
+
+import (
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/redhat-et/copilot-ops/pkg/ai/gptj"
+	"github.com/redhat-et/copilot-ops/pkg/ai/ollama"
+	"github.com/redhat-et/copilot-ops/pkg/cmd/config"
+)
+
+var _ = Describe("TLS", func() {
+	var ts *httptest.Server
+	var caCertFile string
+	var conf *config.Config
+
+	BeforeEach(func() {
+		// the test server's certificate is self-signed, so it's its own CA
+		ts = httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprint(w, "ok")
+		}))
+		DeferCleanup(ts.Close)
+		caCertFile = filepath.Join(GinkgoT().TempDir(), "ca.pem")
+		certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ts.Certificate().Raw})
+		Expect(os.WriteFile(caCertFile, certPEM, 0600)).To(Succeed())
+
+		conf = &config.Config{Ollama: &ollama.Config{URL: ts.URL}}
+		conf.SetDefaults()
+	})
+
+	// get Requests the test server with the client, returning http.DefaultClient's error when it's unset.
+	get := func(client *http.Client) error {
+		if client == nil {
+			client = http.DefaultClient
+		}
+		res, err := client.Get(ts.URL)
+		if err == nil {
+			res.Body.Close()
+		}
+		return err
+	}
+
+	It("doesn't trust the server without its CA", func() {
+		Expect(conf.ConfigureTLS(false)).To(Succeed())
+		Expect(conf.Ollama.HTTPClient).To(BeNil())
+		Expect(get(conf.Ollama.HTTPClient)).To(MatchError(ContainSubstring("certificate")))
+	})
+
+	It("trusts the server when its CA is configured", func() {
+		conf.Ollama.CACertFile = caCertFile
+		Expect(conf.ConfigureTLS(false)).To(Succeed())
+		Expect(get(conf.Ollama.HTTPClient)).To(Succeed())
+		// only the backend with the CA is affected
+		Expect(conf.GPTJ.HTTPClient).To(BeNil())
+	})
+
+	It("fails when the CA certificate is invalid", func() {
+		conf.GPTJ = &gptj.Config{URL: ts.URL, CACertFile: filepath.Join(GinkgoT().TempDir(), "missing.pem")}
+		Expect(conf.ConfigureTLS(false)).To(MatchError(ContainSubstring("could not read the CA certificate")))
+
+		Expect(os.WriteFile(conf.GPTJ.CACertFile, []byte("not a certificate"), 0600)).To(Succeed())
+		Expect(conf.ConfigureTLS(false)).To(MatchError(ContainSubstring("no PEM certificates found")))
+	})
+
+	It("only skips verifying certificates when it's allowed", func() {
+		conf.Ollama.InsecureSkipVerify = true
+		Expect(conf.ConfigureTLS(false)).To(MatchError(config.ErrInsecureTLS))
+		Expect(conf.Ollama.HTTPClient).To(BeNil())
+
+		Expect(conf.ConfigureTLS(true)).To(Succeed())
+		Expect(get(conf.Ollama.HTTPClient)).To(Succeed())
+	})
+})
@@ -0,0 +1,94 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/redhat-et/copilot-ops/pkg/filemap"
+)
+
+// Answers to the question of whether a file should be written.
+const (
+	AnswerYes     = "y"
+	AnswerNo      = "n"
+	AnswerAll     = "a"
+	AnswerSkipAll = "s"
+)
+
+// IsTerminal Reports whether answers can be read from in. Only files which aren't
+// a terminal are refused, so that --interactive never waits on a pipe in CI,
+// while other readers are assumed to be scripted.
+func IsTerminal(in io.Reader) bool {
+	f, ok := in.(*os.File)
+	if !ok {
+		return true
+	}
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// ConfirmFiles Asks whether each file in the filemap should be written, in order of their paths,
+// showing a diff for files which already exist. Files which aren't confirmed are removed from
+// the filemap, as are files which wouldn't change. Answering 'a' writes every remaining file, and
+// 's' skips them all; the remaining files are also skipped if in runs out of answers.
+func ConfirmFiles(fm *filemap.Filemap, in io.Reader, out io.Writer) error {
+	answers := bufio.NewScanner(in)
+	decided := ""
+	for _, tag := range fm.TagsByPath() {
+		file := fm.Files[tag]
+		diff, exists, err := filemap.DiffFile(tag, file)
+		if err != nil {
+			return err
+		}
+		if diff == "" {
+			fmt.Fprintf(out, "%s is unchanged\n", file.Path)
+			delete(fm.Files, tag)
+			continue
+		}
+
+		answer := decided
+		if answer == "" {
+			if exists {
+				fmt.Fprint(out, diff)
+				fmt.Fprintf(out, "overwrite %s? ", file.Path)
+			} else {
+				fmt.Fprintf(out, "create %s? ", file.Path)
+			}
+			answer = askAnswer(answers, out)
+		}
+		switch answer {
+		case AnswerAll:
+			decided = AnswerYes
+		case AnswerSkipAll:
+			decided = AnswerNo
+			delete(fm.Files, tag)
+		case AnswerNo:
+			delete(fm.Files, tag)
+		}
+	}
+	return nil
+}
+
+// askAnswer Reads answers until a valid one is given, treating the end of the input as skipping every file.
+func askAnswer(answers *bufio.Scanner, out io.Writer) string {
+	for {
+		fmt.Fprint(out, "[y]es, [n]o, [a]ll, [s]kip all: ")
+		if !answers.Scan() {
+			fmt.Fprintln(out)
+			return AnswerSkipAll
+		}
+		switch answer := strings.ToLower(strings.TrimSpace(answers.Text())); answer {
+		case AnswerYes, "yes":
+			return AnswerYes
+		case AnswerNo, "no":
+			return AnswerNo
+		case AnswerAll, "all":
+			return AnswerAll
+		case AnswerSkipAll, "skip":
+			return AnswerSkipAll
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package cmd_test
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/redhat-et/copilot-ops/pkg/cmd"
+	"github.com/redhat-et/copilot-ops/pkg/filemap"
+)
+
+var _ = Describe("Interactive confirmation", func() {
+	var dir string
+	var out *bytes.Buffer
+	var r *cmd.Request
+
+	BeforeEach(func() {
+		dir = GinkgoT().TempDir()
+		// the written files are recorded in the current directory
+		wd, err := os.Getwd()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(os.Chdir(dir)).To(Succeed())
+		DeferCleanup(os.Chdir, wd)
+		Expect(os.WriteFile(filepath.Join(dir, "a.yaml"), []byte("kind: Pod\n"), 0600)).To(Succeed())
+		Expect(os.WriteFile(filepath.Join(dir, "same.yaml"), []byte("kind: Secret\n"), 0600)).To(Succeed())
+		fm := filemap.NewFilemap()
+		fm.Files["a"] = filemap.File{Path: filepath.Join(dir, "a.yaml"), Content: "kind: Deployment\n"}
+		fm.Files["b"] = filemap.File{Path: filepath.Join(dir, "b.yaml"), Content: "kind: Service\n"}
+		fm.Files["c"] = filemap.File{Path: filepath.Join(dir, "c.yaml"), Content: "kind: ConfigMap\n"}
+		fm.Files["same"] = filemap.File{Path: filepath.Join(dir, "same.yaml"), Content: "kind: Secret\n"}
+		out = &bytes.Buffer{}
+		r = &cmd.Request{Filemap: fm, IsWrite: true, Interactive: true, ErrOut: out}
+	})
+
+	readFile := func(name string) string {
+		content, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			return ""
+		}
+		return string(content)
+	}
+
+	It("only writes the confirmed files", func() {
+		r.In = strings.NewReader("n\nmaybe\ny\nn\n")
+		Expect(cmd.PrintOrWriteOut(context.Background(), r)).To(Succeed())
+		Expect(readFile("a.yaml")).To(Equal("kind: Pod\n"))
+		Expect(readFile("b.yaml")).To(Equal("kind: Service\n"))
+		Expect(readFile("c.yaml")).To(BeEmpty())
+
+		// existing files are shown as a diff, and unchanged files aren't asked about
+		Expect(out.String()).To(ContainSubstring("-kind: Pod\n+kind: Deployment\n"))
+		Expect(out.String()).To(ContainSubstring("overwrite " + filepath.Join(dir, "a.yaml") + "?"))
+		Expect(out.String()).To(ContainSubstring("create " + filepath.Join(dir, "b.yaml") + "?"))
+		Expect(out.String()).To(ContainSubstring(filepath.Join(dir, "same.yaml") + " is unchanged"))
+		Expect(r.Filemap.Files).To(HaveLen(1))
+	})
+
+	It("writes every remaining file after answering all", func() {
+		r.In = strings.NewReader("n\na\n")
+		Expect(cmd.PrintOrWriteOut(context.Background(), r)).To(Succeed())
+		Expect(readFile("a.yaml")).To(Equal("kind: Pod\n"))
+		Expect(readFile("b.yaml")).To(Equal("kind: Service\n"))
+		Expect(readFile("c.yaml")).To(Equal("kind: ConfigMap\n"))
+	})
+
+	It("skips every remaining file after answering skip all", func() {
+		r.In = strings.NewReader("y\ns\n")
+		Expect(cmd.PrintOrWriteOut(context.Background(), r)).To(Succeed())
+		Expect(readFile("a.yaml")).To(Equal("kind: Deployment\n"))
+		Expect(readFile("b.yaml")).To(BeEmpty())
+		Expect(readFile("c.yaml")).To(BeEmpty())
+	})
+
+	It("skips the remaining files when there are no more answers", func() {
+		r.In = strings.NewReader("y\n")
+		Expect(cmd.PrintOrWriteOut(context.Background(), r)).To(Succeed())
+		Expect(readFile("a.yaml")).To(Equal("kind: Deployment\n"))
+		Expect(readFile("b.yaml")).To(BeEmpty())
+	})
+
+	It("refuses to ask when stdin isn't a terminal", func() {
+		reader, writer, err := os.Pipe()
+		Expect(err).NotTo(HaveOccurred())
+		defer reader.Close()
+		defer writer.Close()
+		Expect(cmd.IsTerminal(reader)).To(BeFalse())
+		Expect(cmd.IsTerminal(strings.NewReader("y\n"))).To(BeTrue())
+
+		c := cmd.NewGenerateCmd()
+		c.SetIn(reader)
+		Expect(c.Flags().Set(cmd.FlagWriteFull, "true")).To(Succeed())
+		Expect(c.Flags().Set(cmd.FlagInteractiveFull, "true")).To(Succeed())
+		_, err = cmd.PrepareRequest(c)
+		Expect(err).To(MatchError(ContainSubstring("needs a terminal")))
+	})
+})
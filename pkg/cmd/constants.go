@@ -1,37 +1,173 @@
 package cmd
 
+import "time"
+
 // Define the names of flags used in commands.
 const (
-	FlagRequestFull       = "request"
-	FlagRequestShort      = "r"
-	FlagWriteFull         = "write"
-	FlagWriteShort        = "w"
-	FlagPathFull          = "path"
-	FlagPathShort         = "p"
-	FlagFilesFull         = "file"
-	FlagFilesShort        = "f"
-	FlagFilesetsFull      = "fileset"
-	FlagFilesetsShort     = "s"
-	FlagNTokensFull       = "ntokens"
-	FlagNTokensShort      = "n"
-	FlagNCompletionsFull  = "ncompletions"
-	FlagNCompletionsShort = "c"
-	FlagOpenAIURLFull     = "openai-url"
-	FlagOpenAIURLShort    = "d"
-	FlagOutputTypeFull    = "output"
-	FlagOutputTypeShort   = "o"
-	FlagAIBackendFull     = "backend"
-	FlagAIBackendShort    = "b"
+	FlagRequestFull            = "request"
+	FlagRequestShort           = "r"
+	FlagWriteFull              = "write"
+	FlagWriteShort             = "w"
+	FlagPathFull               = "path"
+	FlagPathShort              = "p"
+	FlagFilesFull              = "file"
+	FlagStdinFileFull          = "stdin-file"
+	FlagFilesShort             = "f"
+	FlagFilesetsFull           = "fileset"
+	FlagFilesetsShort          = "s"
+	FlagNTokensFull            = "ntokens"
+	FlagNTokensShort           = "n"
+	FlagNCompletionsFull       = "ncompletions"
+	FlagNCompletionsShort      = "c"
+	FlagOpenAIURLFull          = "openai-url"
+	FlagOpenAIURLShort         = "d"
+	FlagOutputTypeFull         = "output"
+	FlagOutputTypeShort        = "o"
+	FlagAIBackendFull          = "backend"
+	FlagAIBackendShort         = "b"
+	FlagSpecFileFull           = "spec-file"
+	FlagSelectFull             = "select"
+	FlagCompletionStrategyFull = "completion-strategy"
+	FlagContextSummaryFull     = "context-summary"
+	FlagContextSummaryFileFull = "context-summary-file"
+	FlagSaveSnapshotFull       = "save-snapshot"
+	FlagFromSnapshotFull       = "from-snapshot"
+	FlagCompletionFilterFull   = "completion-filter"
+	FlagCompletionRejectFull   = "completion-reject"
+	FlagNoExpandEnvFull        = "no-expand-env"
+	FlagTemplateRequestFull    = "template-request"
+	FlagVarFull                = "var"
+	FlagReasoningTokensFull    = "reasoning-tokens"
+	FlagPerNamespaceDirsFull   = "per-namespace-dirs"
+	FlagPreserveBlankLinesFull = "preserve-blank-lines"
+	FlagRecordFull             = "record"
+	FlagReplayFull             = "replay"
+	FlagStreamFull             = "stream"
+	FlagMaxRetriesFull         = "max-retries"
+	FlagMaxRepairAttemptsFull  = "max-repair-attempts"
+	FlagRetryBaseDelayFull     = "retry-base-delay"
+	FlagDryRunFull             = "dry-run"
+	FlagReviewFull             = "review"
+	FlagExitCodeFull           = "exit-code"
+	FlagHFModelFull            = "hf-model"
+	FlagModelFull              = "model"
+	FlagTemperatureFull        = "temperature"
+	FlagTopPFull               = "top-p"
+	FlagConcurrencyFull        = "concurrency"
+	FlagValidateFull           = "validate"
+	FlagGitBranchFull          = "git-branch"
+	FlagPostHookFull           = "post-hook"
+	FlagApplyFull              = "apply"
+	FlagKubeContextFull        = "context"
+	FlagOnConflictFull         = "on-conflict"
+	FlagOutputDirFull          = "output-dir"
+	FlagCacheDirFull           = "cache-dir"
+	FlagNoCacheFull            = "no-cache"
+	FlagCacheTTLFull           = "cache-ttl"
+	FlagTrimStrategyFull       = "trim-strategy"
+	FlagShowPromptFull         = "show-prompt"
+	FlagPromptOnlyFull         = "prompt-only"
+	FlagCountOnlyFull          = "count-only"
+	FlagNoGitignoreFull        = "no-gitignore"
+	FlagProfileFull            = "profile"
+	FlagShowCostFull           = "show-cost"
+	FlagShowUsageFull          = "show-usage"
+	FlagFallbackBackendsFull   = "fallback-backends"
+	FlagRequestFileFull        = "request-file"
+	FlagInteractiveFull        = "interactive"
+	FlagInteractiveShort       = "i"
+	FlagForceFull              = "force"
+	FlagKustomizeFull          = "kustomize"
+	FlagHelmChartFull          = "helm-chart"
+	FlagLogLevelFull           = "log-level"
+	FlagQuietFull              = "quiet"
+	FlagQuietShort             = "q"
+	FlagTimeoutFull            = "timeout"
+	FlagAutoContextFull        = "auto-context"
+	FlagContextFilesFull       = "context-files"
+	FlagNoContextFull          = "no-context"
+	FlagCombineFull            = "combine"
+	FlagInsecureSkipVerifyFull = "insecure-skip-verify"
+	FlagProxyFull              = "proxy"
+	FlagFormatFull             = "format"
+	FlagNamespaceFull          = "namespace"
+	FlagLabelFull              = "label"
+	FlagAnnotationFull         = "annotation"
+	FlagOverwriteMetadataFull  = "overwrite-metadata"
+	FlagExplainFull            = "explain"
+	FlagSystemPromptFull       = "system-prompt"
+	FlagFileHeaderFull         = "file-header"
+	FlagRateLimitFull          = "rate-limit"
+	FlagFileModeFull           = "file-mode"
+	FlagNoHistoryFull          = "no-history"
+	FlagLimitFull              = "limit"
+	FlagPatchFull              = "patch"
+	FlagEndOfSequenceFull      = "eos"
+	FlagStopFull               = "stop"
+	FlagSeedFull               = "seed"
+	FlagStructuredFull         = "structured"
+	FlagMaxFileSizeFull        = "max-file-size"
+	FlagGeneratedNameFull      = "generated-name"
 )
 
 // COMMAND Constants which define the names of commands used in the CLI.
 const (
 	CommandEdit     = "edit"
 	CommandGenerate = "generate"
+	CommandUndo     = "undo"
+	CommandHistory  = "history"
+	CommandBackends = "backends"
+	CommandConfig   = "config"
+	CommandInit     = "init"
+	CommandModels   = "models"
+	CommandValidate = "validate"
 )
 
 // Miscellaneous constants used in the CLI.
 const (
 	DefaultTokens      = 512
 	DefaultCompletions = 1
+	// MaxContextSummaryTokens Bounds how much of the prompt the context summary may use.
+	MaxContextSummaryTokens = 256
+	// MaxTemperature Is the highest sampling temperature accepted by OpenAI, and by default.
+	MaxTemperature = 2.0
+	// MaxClaudeTemperature Is the highest sampling temperature accepted by Claude.
+	MaxClaudeTemperature = 1.0
+	// DefaultOutputDir Is where the output is placed when it can't be decoded into files.
+	DefaultOutputDir = "generated-by-copilot-ops"
+	// GeneratedNameCounter Is replaced with the number of the completion in the names of files
+	// made from output which can't be decoded.
+	GeneratedNameCounter = "{n}"
+	// DefaultGeneratedName Is the name of a file made from output which can't be decoded,
+	// when it isn't named after the resource in it.
+	DefaultGeneratedName = DefaultOutputDir + GeneratedNameCounter + ".yaml"
+	// StdinRequest Is the value of --request which reads the request from STDIN.
+	StdinRequest = "-"
+	// DefaultTimeout Is how long a command waits on the backend before giving up.
+	DefaultTimeout = 5 * time.Minute
+	// DefaultCheckTimeout Is how long the backends command waits on each backend.
+	DefaultCheckTimeout = 30 * time.Second
+	// DefaultContextFiles Is how many of the most relevant files --auto-context includes.
+	DefaultContextFiles = 5
+)
+
+// Policies for writing a file which already exists on disk.
+const (
+	// ConflictPolicyOverwrite Overwrites the existing file.
+	ConflictPolicyOverwrite = "overwrite"
+	// ConflictPolicySkip Leaves the existing file untouched, only writing new files.
+	ConflictPolicySkip = "skip"
+	// ConflictPolicySuffix Writes the file next to the existing one, e.g. to 'pod.generated.yaml'.
+	ConflictPolicySuffix = "suffix"
+)
+
+// Strategies for handling more than one completion when none was selected.
+const (
+	// CompletionStrategyAll Presents every completion and refuses to write them.
+	CompletionStrategyAll = "all"
+	// CompletionStrategyFirst Only uses the first completion.
+	CompletionStrategyFirst = "first"
+	// CompletionStrategyMerge Decodes every completion into the same filemap,
+	// with later completions replacing files from earlier ones.
+	CompletionStrategyMerge = "merge"
 )
@@ -0,0 +1,148 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/redhat-et/copilot-ops/pkg/ai"
+)
+
+// pricedModel Returns the name of the model which the request's backend is billed for.
+func pricedModel(r *Request) string {
+	conf := r.Config
+	switch r.Backend {
+	case ai.GPT3:
+		if conf.OpenAI != nil {
+			return conf.OpenAI.ModelName()
+		}
+	case ai.CLAUDE:
+		if conf.Claude != nil {
+			return conf.Claude.Model
+		}
+	case ai.OLLAMA:
+		if conf.Ollama != nil {
+			return conf.Ollama.Model
+		}
+	case ai.HUGGINGFACE:
+		if conf.HuggingFace != nil {
+			return conf.HuggingFace.ModelID
+		}
+	case ai.COHERE:
+		if conf.Cohere != nil {
+			return conf.Cohere.Model
+		}
+	case ai.GEMINI:
+		if conf.Gemini != nil {
+			return conf.Gemini.Model
+		}
+	case ai.GPTJ, ai.BLOOM, ai.OPT, ai.Unselected:
+	}
+	return string(r.Backend)
+}
+
+// EstimateUsage Counts the tokens of the prompt and the completions with the request's tokenizer,
+// for backends which don't report their usage. The prompt is counted once for every request made.
+func EstimateUsage(r *Request, prompt string, choices []string) ai.Usage {
+	usage := ai.Usage{}
+	if r.Tokenizer == nil {
+		return usage
+	}
+	requests := 1
+	if !ai.SupportsMultipleCompletions(r.Backend) && len(choices) > 1 {
+		requests = len(choices)
+	}
+	usage.PromptTokens = requests * r.Tokenizer.CountTokens(prompt)
+	for _, choice := range choices {
+		usage.CompletionTokens += r.Tokenizer.CountTokens(choice)
+	}
+	return usage
+}
+
+// GenerationUsage Returns the usage reported by the client, and true, or the usage estimated
+// with the request's tokenizer, and false, when the backend doesn't report it.
+func GenerationUsage(r *Request, prompt string, client ai.GenerateClient, choices []string) (ai.Usage, bool) {
+	if reporter, ok := client.(ai.UsageReportingClient); ok {
+		if usage, reported := reporter.Usage(); reported {
+			return usage, true
+		}
+	}
+	return EstimateUsage(r, prompt, choices), false
+}
+
+// ReportCost Prints what the generation cost to w, using the usage reported by the client.
+// When the backend doesn't report its usage, the tokens are estimated and the cost is marked as approximate.
+func ReportCost(w io.Writer, r *Request, prompt string, client ai.GenerateClient, choices []string) {
+	if r.FromCache {
+		fmt.Fprintln(w, "cost: $0, the completions were loaded from the cache")
+		return
+	}
+	model := pricedModel(r)
+	usage, reported := GenerationUsage(r, prompt, client, choices)
+	tokens := fmt.Sprintf("%d prompt tokens and %d completion tokens", usage.PromptTokens, usage.CompletionTokens)
+	if !reported {
+		tokens = fmt.Sprintf("an estimated %d prompt tokens and %d completion tokens",
+			usage.PromptTokens, usage.CompletionTokens)
+	}
+
+	price, ok := ai.PriceFor(r.Backend, model)
+	switch {
+	case !ok:
+		fmt.Fprintf(w, "cost: unknown, no price is known for %q (%s)\n", model, tokens)
+	case reported:
+		fmt.Fprintf(w, "cost: $%.4f for %s with %s\n", price.Cost(usage), tokens, model)
+	default:
+		fmt.Fprintf(w, "cost: approximately $%.4f for %s with %s\n", price.Cost(usage), tokens, model)
+	}
+}
+
+// ReportEstimate Prints the estimated tokens of the prompt, the completion budget given by --ntokens,
+// and the most the generation would cost to w, without making any requests. The prompt is counted once
+// for every request which would be made.
+func ReportEstimate(w io.Writer, r *Request, prompt string) {
+	requests, completions := 1, int(r.NCompletions)
+	if completions < 1 {
+		completions = 1
+	}
+	if !ai.SupportsMultipleCompletions(r.Backend) {
+		requests = completions
+	}
+	usage := ai.Usage{CompletionTokens: completions * int(r.NTokens)}
+	if r.Tokenizer != nil {
+		usage.PromptTokens = requests * r.Tokenizer.CountTokens(prompt)
+	}
+	fmt.Fprintf(w, "prompt: ~%d tokens", usage.PromptTokens)
+	if requests > 1 {
+		fmt.Fprintf(w, " over %d requests", requests)
+	}
+	fmt.Fprintf(w, "\ncompletions: up to %d tokens (%d x --%s %d)\n", usage.CompletionTokens,
+		completions, FlagNTokensFull, r.NTokens)
+
+	model := pricedModel(r)
+	if price, ok := ai.PriceFor(r.Backend, model); ok {
+		fmt.Fprintf(w, "cost: up to ~$%.4f with %s\n", price.Cost(usage), model)
+	} else {
+		fmt.Fprintf(w, "cost: unknown, no price is known for %q\n", model)
+	}
+}
+
+// ReportUsage Prints how many tokens the generation used to w, followed by the tokens of each completion
+// when there are several. The usage reported by the client is preferred; otherwise it's estimated.
+func ReportUsage(w io.Writer, r *Request, prompt string, client ai.GenerateClient, choices []string) {
+	if r.FromCache {
+		fmt.Fprintln(w, "usage: no tokens, the completions were loaded from the cache")
+		return
+	}
+	usage, reported := GenerationUsage(r, prompt, client, choices)
+	source := "reported by the backend"
+	if !reported {
+		source = "estimated"
+	}
+	fmt.Fprintf(w, "usage (%s): %d prompt + %d completion = %d tokens\n", source,
+		usage.PromptTokens, usage.CompletionTokens, usage.PromptTokens+usage.CompletionTokens)
+	if len(choices) < 2 || r.Tokenizer == nil {
+		return
+	}
+	for i, choice := range choices {
+		fmt.Fprintf(w, "  completion %d: ~%d tokens\n", i+1, r.Tokenizer.CountTokens(choice))
+	}
+}
@@ -0,0 +1,116 @@
+package cmd_test
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/redhat-et/copilot-ops/pkg/ai"
+	"github.com/redhat-et/copilot-ops/pkg/ai/gpt3"
+	"github.com/redhat-et/copilot-ops/pkg/cmd"
+	"github.com/redhat-et/copilot-ops/pkg/tokenizer"
+)
+
+// usageClient Reports a fixed usage for its completions.
+type usageClient struct {
+	countingClient
+	usage ai.Usage
+}
+
+func (c *usageClient) Usage() (ai.Usage, bool) {
+	return c.usage, c.calls > 0
+}
+
+var _ = Describe("ReportCost", func() {
+	var r *cmd.Request
+	var out *bytes.Buffer
+
+	BeforeEach(func() {
+		r = &cmd.Request{Backend: ai.GPT3, Tokenizer: tokenizer.Heuristic{}}
+		r.Config.OpenAI = &gpt3.Config{Model: "gpt-4"}
+		out = &bytes.Buffer{}
+	})
+
+	It("uses the usage reported by the backend", func() {
+		client := &usageClient{
+			countingClient: countingClient{choices: []string{"kind: Pod"}},
+			usage:          ai.Usage{PromptTokens: 1000, CompletionTokens: 500},
+		}
+		choices, err := client.Generate(context.Background())
+		Expect(err).NotTo(HaveOccurred())
+		cmd.ReportCost(out, r, "create a pod", client, choices)
+		Expect(out.String()).To(Equal("cost: $0.0600 for 1000 prompt tokens and 500 completion tokens with gpt-4\n"))
+	})
+
+	It("estimates the usage of backends which don't report it", func() {
+		client := &countingClient{choices: []string{"kind: Pod"}}
+		choices, err := client.Generate(context.Background())
+		Expect(err).NotTo(HaveOccurred())
+		usage := cmd.EstimateUsage(r, "create a pod", choices)
+		Expect(usage.PromptTokens).To(Equal(tokenizer.Heuristic{}.CountTokens("create a pod")))
+		Expect(usage.CompletionTokens).To(Equal(tokenizer.Heuristic{}.CountTokens("kind: Pod")))
+
+		cmd.ReportCost(out, r, "create a pod", client, choices)
+		Expect(out.String()).To(HavePrefix("cost: approximately $"))
+		Expect(out.String()).To(ContainSubstring("an estimated"))
+	})
+
+	It("counts the prompt once per completion for backends which make a request for each", func() {
+		r.Backend = ai.CLAUDE
+		usage := cmd.EstimateUsage(r, "create a pod", []string{"kind: Pod", "kind: Pod"})
+		Expect(usage.PromptTokens).To(Equal(2 * tokenizer.Heuristic{}.CountTokens("create a pod")))
+	})
+
+	It("doesn't charge for cached completions", func() {
+		r.FromCache = true
+		cmd.ReportCost(out, r, "create a pod", &countingClient{}, []string{"kind: Pod"})
+		Expect(out.String()).To(Equal("cost: $0, the completions were loaded from the cache\n"))
+	})
+
+	It("says when the price is unknown", func() {
+		r.Config.OpenAI.Model = "my-fine-tune"
+		cmd.ReportCost(out, r, "create a pod", &countingClient{}, []string{"kind: Pod"})
+		Expect(out.String()).To(HavePrefix(`cost: unknown, no price is known for "my-fine-tune"`))
+	})
+})
+
+var _ = Describe("ReportUsage", func() {
+	var r *cmd.Request
+	var out *bytes.Buffer
+
+	BeforeEach(func() {
+		r = &cmd.Request{Backend: ai.GPT3, Tokenizer: tokenizer.Heuristic{}}
+		out = &bytes.Buffer{}
+	})
+
+	It("summarizes the usage reported by the backend", func() {
+		client := &usageClient{
+			countingClient: countingClient{choices: []string{"kind: Pod"}},
+			usage:          ai.Usage{PromptTokens: 1000, CompletionTokens: 500},
+		}
+		choices, err := client.Generate(context.Background())
+		Expect(err).NotTo(HaveOccurred())
+		cmd.ReportUsage(out, r, "create a pod", client, choices)
+		Expect(out.String()).To(Equal("usage (reported by the backend): 1000 prompt + 500 completion = 1500 tokens\n"))
+	})
+
+	It("estimates the usage of backends which don't report it, with the tokens of each completion", func() {
+		choices := []string{"kind: Pod", "kind: Deployment"}
+		usage := cmd.EstimateUsage(r, "create a pod", choices)
+		cmd.ReportUsage(out, r, "create a pod", &countingClient{}, choices)
+		Expect(out.String()).To(Equal(fmt.Sprintf(
+			"usage (estimated): %d prompt + %d completion = %d tokens\n  completion 1: ~%d tokens\n  completion 2: ~%d tokens\n",
+			usage.PromptTokens, usage.CompletionTokens, usage.PromptTokens+usage.CompletionTokens,
+			tokenizer.Heuristic{}.CountTokens(choices[0]), tokenizer.Heuristic{}.CountTokens(choices[1]),
+		)))
+	})
+
+	It("doesn't count cached completions", func() {
+		r.FromCache = true
+		cmd.ReportUsage(out, r, "create a pod", &countingClient{}, []string{"kind: Pod"})
+		Expect(out.String()).To(Equal("usage: no tokens, the completions were loaded from the cache\n"))
+	})
+})
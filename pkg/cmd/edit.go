@@ -20,7 +20,8 @@ func NewEditCmd() *cobra.Command {
 
 		Example: `  copilot-ops edit --file examples/app1/mysql-pvc.yaml --request 'Increase the size of the PVC to 100Gi'`,
 
-		RunE: RunEdit,
+		PreRunE: ValidateFlags,
+		RunE:    RunEdit,
 	}
 
 	AddRequestFlags(cmd)
@@ -31,6 +32,11 @@ func NewEditCmd() *cobra.Command {
 		"File path to the document which should be edited.",
 	)
 
+	cmd.Flags().Bool(
+		FlagPreserveBlankLinesFull, false,
+		"Restore the original blank lines around the regions of the file which were left unchanged",
+	)
+
 	return cmd
 }
 
@@ -45,6 +51,9 @@ func RunEdit(cmd *cobra.Command, args []string) error {
 	editSuffix := fmt.Sprintf("The resulting file should preserve the '# %stagname'"+
 		" format used to identify the YAML(s).", filemap.FileTagPrefix)
 	editInstruction := fmt.Sprintf("%s\n\n%s", r.UserRequest, editSuffix)
+	if r.ContextSummary != "" {
+		editInstruction = fmt.Sprintf("Context: %s\n\n%s", r.ContextSummary, editInstruction)
+	}
 
 	// create a client for editing
 	client, err := PrepareEditClient(r, r.FilemapText, editInstruction)
@@ -52,17 +61,40 @@ func RunEdit(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("could not create client: %w", err)
 	}
 
-	responses, err := client.Edit()
+	ctx, cancel := RequestContext(cmd, r.Timeout)
+	defer cancel()
+	responses, err := client.Edit(ctx)
 	if err != nil {
-		return fmt.Errorf("could not edit files: %w", err)
+		return fmt.Errorf("could not edit files: %w", CancellationError(ctx, r.Timeout, err))
 	}
+	r.Completions = len(responses)
+	r.RawCompletions = responses
 	output := responses[0]
-	err = r.Filemap.DecodeFromOutput(output)
+	original := make(map[string]string, len(r.Filemap.Files))
+	for tag, file := range r.Filemap.Files {
+		original[tag] = file.Content
+	}
+	// edits return the encoded files without an end-of-sequence terminator
+	err = r.Filemap.Decode(output)
 	if err != nil {
 		return err
 	}
 
-	return PrintOrWriteOut(r)
+	// models tend to collapse blank lines, which adds noise to the diff
+	if r.PreserveBlankLines {
+		for tag, file := range r.Filemap.Files {
+			if content, ok := original[tag]; ok {
+				file.Content = filemap.RestoreBlankLines(content, file.Content)
+				r.Filemap.Files[tag] = file
+			}
+		}
+	}
+
+	if err = PrintOrWriteOut(ctx, r); err != nil {
+		return err
+	}
+	RecordHistory(r, CommandEdit, GeneratedFiles(r.Filemap))
+	return SaveSnapshot(r)
 }
 
 // PrepareEditClient Returns an AI Client which implements the EditClient interface.
@@ -75,6 +107,9 @@ func PrepareEditClient(r *Request, input, instruction string) (ai.EditClient, er
 		if config == nil {
 			return nil, fmt.Errorf("no openai config provided")
 		}
+		if config.IsAzure() && config.Deployment == "" {
+			return nil, fmt.Errorf("no deployment provided for azure openai")
+		}
 		client = gpt3.CreateGPT3EditClient(*r.Config.OpenAI, input, instruction, 1, nil, nil)
 	case ai.GPTJ:
 		return nil, fmt.Errorf("editing is not implemented for gpt-j")
@@ -82,6 +117,16 @@ func PrepareEditClient(r *Request, input, instruction string) (ai.EditClient, er
 		return nil, fmt.Errorf("editing is not implemented for bloom")
 	case ai.OPT:
 		return nil, fmt.Errorf("editing is not implemented for opt")
+	case ai.CLAUDE:
+		return nil, fmt.Errorf("editing is not implemented for claude")
+	case ai.OLLAMA:
+		return nil, fmt.Errorf("editing is not implemented for ollama")
+	case ai.HUGGINGFACE:
+		return nil, fmt.Errorf("editing is not implemented for huggingface")
+	case ai.COHERE:
+		return nil, fmt.Errorf("editing is not implemented for cohere")
+	case ai.GEMINI:
+		return nil, fmt.Errorf("editing is not implemented for gemini")
 	case ai.Unselected:
 		return nil, fmt.Errorf("no backend selected")
 	default:
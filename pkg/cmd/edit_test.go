@@ -6,6 +6,7 @@ import (
 
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
+	"github.com/redhat-et/copilot-ops/pkg/ai"
 	"github.com/redhat-et/copilot-ops/pkg/ai/gpt3"
 	"github.com/redhat-et/copilot-ops/pkg/cmd"
 	"github.com/spf13/cobra"
@@ -30,6 +31,11 @@ var _ = Describe("Edit", func() {
 			ts.Start()
 			err := c.Flags().Set(cmd.FlagOpenAIURLFull, ts.URL+gpt3.OpenAIEndpointV1)
 			Expect(err).To(BeNil())
+			// don't depend on which backends the environment configures
+			err = c.Flags().Set(cmd.FlagAIBackendFull, string(ai.GPT3))
+			Expect(err).To(BeNil())
+			// don't record the test's generations in the history of the repo
+			Expect(c.Flags().Set(cmd.FlagNoHistoryFull, "true")).To(Succeed())
 		})
 
 		AfterEach(func() {
@@ -0,0 +1,77 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/redhat-et/copilot-ops/pkg/ai"
+	"github.com/redhat-et/copilot-ops/pkg/logger"
+)
+
+// ExplainTokens Is the most tokens the summary of the changes may take.
+const ExplainTokens = 256
+
+// ExplainPrompt Returns a prompt asking the model for a short, plain-English summary of the diff
+// which was generated for the request, aimed at whoever reviews it.
+func ExplainPrompt(request, diff, endOfSequence string) string {
+	return fmt.Sprintf(`## This document contains a request to change a repository, the diff of the changes made for it,
+## and a short summary of what the changes do, written in plain English for someone reviewing them.
+## The summary names the resources which are added or changed and anything a reviewer should look out for,
+## in a few sentences. It is terminated by an '%s'.
+
+## 1. The request:
+%s
+
+## 2. The diff:
+%s
+
+## 3. The summary:
+`, endOfSequence, strings.TrimSpace(request), strings.TrimSpace(diff))
+}
+
+// ExplainChanges Asks the request's backend to summarize the difference between the files on disk
+// and the generated files, in a single short completion.
+func ExplainChanges(ctx context.Context, r *Request) (string, error) {
+	diff, err := r.Filemap.Diff()
+	if err != nil {
+		return "", fmt.Errorf("could not diff the changes: %w", err)
+	}
+	if diff == "" {
+		return "The generated files are the same as the files on disk, nothing changes.", nil
+	}
+	// a single short completion is enough to summarize the changes
+	single := *r
+	single.NCompletions = 1
+	single.NTokens = ExplainTokens
+	client, err := PrepareGenerateClient(&single, ExplainPrompt(r.UserRequest, diff, r.Terminator()))
+	if err != nil {
+		return "", fmt.Errorf("could not create client: %w", err)
+	}
+	outputs, err := ai.RetryGenerate(ctx, client, r.Retry)
+	if err != nil {
+		return "", fmt.Errorf("could not explain the changes: %w", err)
+	}
+	if len(outputs) == 0 {
+		return "", fmt.Errorf("could not explain the changes: the backend returned no summary")
+	}
+	summary := ai.StripReasoning(outputs[0])
+	summary, _, _ = strings.Cut(summary, r.Terminator())
+	return strings.TrimSpace(summary), nil
+}
+
+// PrintExplanation Prints the summary of the changes to w, or a warning when they couldn't be explained,
+// since the generated files are still useful without it.
+func PrintExplanation(ctx context.Context, w io.Writer, r *Request) {
+	if w == nil {
+		w = os.Stderr
+	}
+	summary, err := ExplainChanges(ctx, r)
+	if err != nil {
+		logger.Warnf("%s\n", err)
+		return
+	}
+	fmt.Fprintf(w, "explanation: %s\n", summary)
+}
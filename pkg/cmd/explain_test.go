@@ -0,0 +1,91 @@
+package cmd_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	gogpt "github.com/sashabaranov/go-gpt3"
+
+	"github.com/redhat-et/copilot-ops/pkg/ai"
+	"github.com/redhat-et/copilot-ops/pkg/ai/gpt3"
+	"github.com/redhat-et/copilot-ops/pkg/cmd"
+	"github.com/redhat-et/copilot-ops/pkg/cmd/config"
+	"github.com/redhat-et/copilot-ops/pkg/filemap"
+)
+
+var _ = Describe("Explaining the changes", func() {
+	var (
+		r        *cmd.Request
+		errOut   *bytes.Buffer
+		requests []gogpt.CompletionRequest
+	)
+
+	BeforeEach(func() {
+		requests = nil
+		// a backend which always answers with the same summary
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			var body gogpt.CompletionRequest
+			if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			requests = append(requests, body)
+			res, _ := json.Marshal(gogpt.CompletionResponse{Choices: []gogpt.CompletionChoice{
+				{Text: " Adds a Pod named web running nginx.\n" + gpt3.CompletionEndOfSequence},
+			}})
+			_, _ = w.Write(res)
+		}))
+		DeferCleanup(server.Close)
+
+		wd, err := os.Getwd()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(os.Chdir(GinkgoT().TempDir())).To(Succeed())
+		DeferCleanup(os.Chdir, wd)
+
+		errOut = &bytes.Buffer{}
+		r = &cmd.Request{
+			Backend:      ai.GPT3,
+			Config:       config.Config{OpenAI: &gpt3.Config{BaseURL: server.URL + gpt3.OpenAIEndpointV1}},
+			UserRequest:  "Create a Pod running nginx",
+			NTokens:      1024,
+			NCompletions: 3,
+			OutputType:   filemap.OutputPlain,
+			ErrOut:       errOut,
+			Explain:      true,
+		}
+	})
+
+	It("prints the summary of the diff before the output", func() {
+		Expect(cmd.DecodeAndOutput(context.Background(), r, []string{"# @pod.yaml\nkind: Pod\n"})).To(Succeed())
+		Expect(errOut.String()).To(Equal("explanation: Adds a Pod named web running nginx.\n"))
+
+		Expect(requests).To(HaveLen(1))
+		Expect(requests[0].MaxTokens).To(Equal(cmd.ExplainTokens))
+		Expect(requests[0].N).To(Equal(1))
+		Expect(requests[0].Prompt).To(ContainSubstring("## 1. The request:\nCreate a Pod running nginx"))
+		Expect(requests[0].Prompt).To(ContainSubstring("+++ b/pod.yaml\n@@ -0,0 +1 @@\n+kind: Pod"))
+	})
+
+	It("doesn't ask the backend when nothing changes", func() {
+		Expect(os.WriteFile("pod.yaml", []byte("kind: Pod\n"), 0600)).To(Succeed())
+		r.Filemap = filemap.NewFilemap()
+		r.Filemap.Files["pod.yaml"] = filemap.File{Path: "pod.yaml", Content: "kind: Pod\n"}
+		summary, err := cmd.ExplainChanges(context.Background(), r)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(summary).To(ContainSubstring("nothing changes"))
+		Expect(requests).To(BeEmpty())
+	})
+
+	It("isn't asked for by default", func() {
+		r.Explain = false
+		Expect(cmd.DecodeAndOutput(context.Background(), r, []string{"# @pod.yaml\nkind: Pod\n"})).To(Succeed())
+		Expect(errOut.String()).To(BeEmpty())
+		Expect(requests).To(BeEmpty())
+	})
+})
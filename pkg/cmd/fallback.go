@@ -0,0 +1,80 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/redhat-et/copilot-ops/pkg/ai"
+	"github.com/redhat-et/copilot-ops/pkg/logger"
+)
+
+// FallbackChain Returns the fallback backends without the selected backend or any duplicates,
+// keeping their order.
+func FallbackChain(selected ai.Backend, fallbacks []ai.Backend) []ai.Backend {
+	seen := map[ai.Backend]bool{selected: true}
+	chain := make([]ai.Backend, 0, len(fallbacks))
+	for _, backend := range fallbacks {
+		if backend == ai.Unselected || seen[backend] {
+			continue
+		}
+		seen[backend] = true
+		chain = append(chain, backend)
+	}
+	return chain
+}
+
+// GenerateWithFallback Generates completions for the prompt with the request's backend, moving on to
+// each of its fallback backends in turn while the previous one is unavailable.
+// Fallbacks which are missing required fields in the config, don't accept the temperature, or whose model
+// can't fit the prompt and the requested tokens, are skipped. The request's backend and tokenizer are
+// switched to those of the backend which succeeded, whose client is returned along with the completions.
+// Any other failure is returned straight away, and the error of the last backend which was tried when
+// every one of them is unavailable.
+func GenerateWithFallback(ctx context.Context, r *Request, prompt string) (ai.GenerateClient, []string, error) {
+	chain := []ai.Backend{r.Backend}
+	for _, backend := range r.FallbackBackends {
+		if missing := r.Config.MissingFields(backend); len(missing) > 0 {
+			logger.Warnf("skipping the %q fallback backend, the config doesn't set %s\n",
+				backend, strings.Join(missing, ", "))
+			continue
+		}
+		if maxTemperature := MaxTemperatureFor(backend); r.Temperature > maxTemperature {
+			logger.Warnf("skipping the %q fallback backend, its temperature can't be above %.1f\n", backend, maxTemperature)
+			continue
+		}
+		chain = append(chain, backend)
+	}
+
+	var err error
+	for i, backend := range chain {
+		if i > 0 {
+			// the tokens of a fallback are counted the way its model counts them
+			tok := TokenizerFor(&r.Config, backend)
+			model := ModelName(r.Config, backend)
+			if budgetErr := CheckTokenBudget(tok, backend, model, prompt, int(r.NTokens), r.Filemap); budgetErr != nil {
+				logger.Warnf("skipping the %q fallback backend: %s\n", backend, budgetErr)
+				continue
+			}
+			r.Backend = backend
+			r.Tokenizer = tok
+		}
+		var client ai.GenerateClient
+		client, err = PrepareGenerateClient(r, prompt)
+		if err != nil {
+			return nil, nil, fmt.Errorf("could not create client: %w", err)
+		}
+		var choices []string
+		choices, err = CachedGenerateChoices(ctx, r, prompt, client)
+		if err == nil {
+			return client, choices, nil
+		}
+		if ctx.Err() != nil || !ai.IsUnavailable(err) {
+			return nil, nil, err
+		}
+		if i < len(chain)-1 {
+			logger.Warnf("the %q backend is unavailable, falling back to the next backend: %s\n", backend, err)
+		}
+	}
+	return nil, nil, err
+}
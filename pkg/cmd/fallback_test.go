@@ -0,0 +1,141 @@
+package cmd_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/spf13/viper"
+
+	"github.com/redhat-et/copilot-ops/pkg/ai"
+	"github.com/redhat-et/copilot-ops/pkg/cmd"
+	"github.com/redhat-et/copilot-ops/pkg/cmd/config"
+)
+
+var _ = Describe("Fallback backends", func() {
+	var primaryStatus int
+	var primary, fallback *httptest.Server
+	var fallbackCalls int
+
+	BeforeEach(func() {
+		primaryStatus = http.StatusServiceUnavailable
+		primary = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(primaryStatus)
+		}))
+		DeferCleanup(primary.Close)
+		fallbackCalls = 0
+		fallback = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			fallbackCalls++
+			fmt.Fprint(w, `[{"generated_text": "kind: Pod\n"}]`)
+		}))
+		DeferCleanup(fallback.Close)
+
+		wd, err := os.Getwd()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(os.Chdir(GinkgoT().TempDir())).To(Succeed())
+		DeferCleanup(os.Chdir, wd)
+		viper.Reset()
+		DeferCleanup(viper.Reset)
+		Expect(os.WriteFile(config.ConfigFile, []byte(fmt.Sprintf(
+			"defaultBackend: gpt-j\nfallbackBackends: [bloom]\ngptj:\n  url: %s\nbloom:\n  url: %s\n",
+			primary.URL, fallback.URL,
+		)), 0600)).To(Succeed())
+	})
+
+	// generate Generates a Pod with the backends from the config, unless flags override them.
+	generate := func(flags map[string]string) (*cmd.Request, []string, error) {
+		c := cmd.NewGenerateCmd()
+		Expect(c.Flags().Set(cmd.FlagRequestFull, "Create a Pod")).To(Succeed())
+		Expect(c.Flags().Set(cmd.FlagMaxRetriesFull, "0")).To(Succeed())
+		for name, value := range flags {
+			Expect(c.Flags().Set(name, value)).To(Succeed())
+		}
+		r, err := cmd.PrepareRequest(c)
+		Expect(err).NotTo(HaveOccurred())
+		_, choices, err := cmd.GenerateWithFallback(context.Background(), r, "Create a Pod")
+		return r, choices, err
+	}
+
+	It("falls back to the next backend when the selected one is unavailable", func() {
+		r, choices, err := generate(nil)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(choices).To(Equal([]string{"kind: Pod\n"}))
+		Expect(r.Backend).To(Equal(ai.BLOOM))
+		Expect(fallbackCalls).To(Equal(1))
+	})
+
+	It("counts tokens with the tokenizer of the backend which fell back", func() {
+		c := cmd.NewGenerateCmd()
+		Expect(c.Flags().Set(cmd.FlagRequestFull, "Create a Pod")).To(Succeed())
+		Expect(c.Flags().Set(cmd.FlagMaxRetriesFull, "0")).To(Succeed())
+		r, err := cmd.PrepareRequest(c)
+		Expect(err).NotTo(HaveOccurred())
+		r.Tokenizer = nil
+		_, _, err = cmd.GenerateWithFallback(context.Background(), r, "Create a Pod")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(r.Tokenizer).To(Equal(cmd.TokenizerFor(&r.Config, ai.BLOOM)))
+	})
+
+	It("falls back when the selected backend can't be reached", func() {
+		primary.Close()
+		r, _, err := generate(nil)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(r.Backend).To(Equal(ai.BLOOM))
+	})
+
+	It("doesn't fall back when the request itself is rejected", func() {
+		primaryStatus = http.StatusBadRequest
+		r, _, err := generate(nil)
+		Expect(err).To(MatchError(ContainSubstring("status code: 400")))
+		Expect(r.Backend).To(Equal(ai.GPTJ))
+		Expect(fallbackCalls).To(BeZero())
+	})
+
+	It("fails with the last backend's error when every backend is unavailable", func() {
+		fallback.Close()
+		_, _, err := generate(nil)
+		Expect(err).To(HaveOccurred())
+		Expect(ai.IsUnavailable(err)).To(BeTrue())
+	})
+
+	It("uses the backends passed with --fallback-backends instead of the config's", func() {
+		_, _, err := generate(map[string]string{cmd.FlagFallbackBackendsFull: "gpt-j"})
+		Expect(err).To(MatchError(ContainSubstring("status code: 503")))
+		Expect(fallbackCalls).To(BeZero())
+	})
+
+	It("skips fallbacks which aren't configured", func() {
+		for _, name := range []string{config.GeminiAPIKeyEnv, config.GoogleAPIKeyEnv, config.GoogleAccessTokenEnv} {
+			DeferCleanup(os.Setenv, name, os.Getenv(name))
+			Expect(os.Unsetenv(name)).To(Succeed())
+		}
+		r, choices, err := generate(map[string]string{cmd.FlagFallbackBackendsFull: "gemini,bloom"})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(choices).To(Equal([]string{"kind: Pod\n"}))
+		Expect(r.Backend).To(Equal(ai.BLOOM))
+	})
+
+	It("skips fallbacks whose model can't fit the prompt and the requested tokens", func() {
+		// the window of gpt-j and bloom is 2048 tokens, but only the selected backend was checked before
+		r, _, err := generate(map[string]string{cmd.FlagNTokensFull: "3000"})
+		Expect(err).To(MatchError(ContainSubstring("status code: 503")))
+		Expect(r.Backend).To(Equal(ai.GPTJ))
+		Expect(fallbackCalls).To(BeZero())
+	})
+
+	It("skips fallbacks which don't accept the temperature", func() {
+		r, _, err := generate(map[string]string{cmd.FlagFallbackBackendsFull: "claude,bloom", cmd.FlagTemperatureFull: "1.5"})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(r.Backend).To(Equal(ai.BLOOM))
+	})
+
+	It("skips the selected backend and duplicates in the chain", func() {
+		Expect(cmd.FallbackChain(ai.GPTJ, []ai.Backend{ai.BLOOM, ai.GPTJ, ai.OLLAMA, ai.BLOOM})).To(
+			Equal([]ai.Backend{ai.BLOOM, ai.OLLAMA}),
+		)
+	})
+})
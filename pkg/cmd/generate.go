@@ -1,18 +1,34 @@
 package cmd
 
 import (
+	"context"
+	"errors"
 	"fmt"
-	"log"
 	"math/rand"
+	"os"
 	"path"
+	"regexp"
+	"sort"
+	"strconv"
 	"strings"
 
 	"github.com/redhat-et/copilot-ops/pkg/ai"
 	"github.com/redhat-et/copilot-ops/pkg/ai/bloom"
+	"github.com/redhat-et/copilot-ops/pkg/ai/claude"
+	"github.com/redhat-et/copilot-ops/pkg/ai/cohere"
+	"github.com/redhat-et/copilot-ops/pkg/ai/gemini"
 	"github.com/redhat-et/copilot-ops/pkg/ai/gpt3"
 	"github.com/redhat-et/copilot-ops/pkg/ai/gptj"
+	"github.com/redhat-et/copilot-ops/pkg/ai/hfinference"
+	"github.com/redhat-et/copilot-ops/pkg/ai/ollama"
+	"github.com/redhat-et/copilot-ops/pkg/ai/opt"
+	"github.com/redhat-et/copilot-ops/pkg/cache"
 	"github.com/redhat-et/copilot-ops/pkg/cmd/config"
 	"github.com/redhat-et/copilot-ops/pkg/filemap"
+	"github.com/redhat-et/copilot-ops/pkg/git"
+	"github.com/redhat-et/copilot-ops/pkg/helm"
+	"github.com/redhat-et/copilot-ops/pkg/logger"
+	"github.com/redhat-et/copilot-ops/pkg/spec"
 	"github.com/spf13/cobra"
 )
 
@@ -31,7 +47,8 @@ func NewGenerateCmd() *cobra.Command {
 			`'Generate a pod that mounts the PVC. Set the pod resources requests and ` +
 			`limits to 4 cpus and 5 Gig of memory.'`,
 
-		RunE: RunGenerate,
+		PreRunE: ValidateFlags,
+		RunE:    RunGenerate,
 	}
 
 	AddRequestFlags(cmd)
@@ -39,7 +56,23 @@ func NewGenerateCmd() *cobra.Command {
 	// generate-specific flags
 	cmd.Flags().StringArrayP(
 		FlagFilesFull, FlagFilesShort, []string{},
-		"File paths (glob) to be considered for the patch (can be specified multiple times)",
+		"File paths (glob) or HTTP(S) URLs to be considered for the patch (can be specified multiple times)",
+	)
+
+	cmd.Flags().String(
+		FlagStdinFileFull, "",
+		"Read a file from stdin and include it as context under this path, without it existing on disk",
+	)
+
+	cmd.Flags().StringArray(
+		FlagFileHeaderFull, []string{},
+		"A 'Name: value' header sent when fetching the files given as URLs, such as an auth token "+
+			"(can be specified multiple times)",
+	)
+
+	cmd.Flags().String(
+		FlagRequestFileFull, "",
+		"Path to a file containing the requested changes in natural language, instead of --"+FlagRequestFull,
 	)
 
 	cmd.Flags().StringArrayP(
@@ -57,6 +90,273 @@ func NewGenerateCmd() *cobra.Command {
 		"Number of completions to generate",
 	)
 
+	cmd.Flags().Int(
+		FlagMaxRetriesFull, ai.DefaultMaxRetries,
+		"Number of times to retry the request when the backend is rate-limited or unavailable",
+	)
+
+	cmd.Flags().Int(
+		FlagMaxRepairAttemptsFull, DefaultMaxRepairAttempts,
+		"Number of times to ask the model to reformat output which can't be decoded into files, "+
+			"before writing the output as-is (0 disables repairs)",
+	)
+
+	cmd.Flags().Duration(
+		FlagRetryBaseDelayFull, ai.DefaultRetryBaseDelay,
+		"Delay before the first retry, doubled with every following attempt",
+	)
+
+	cmd.Flags().Int(
+		FlagConcurrencyFull, ai.DefaultConcurrency,
+		"Maximum number of requests made at once across every backend, including fallbacks and embeddings",
+	)
+
+	cmd.Flags().Float32(
+		FlagTemperatureFull, 0,
+		"Sampling temperature between 0 and 2, higher values trade determinism for creativity",
+	)
+
+	cmd.Flags().Float32(
+		FlagTopPFull, 0,
+		"Nucleus sampling probability mass in (0, 1], the backend's default is used when unset",
+	)
+
+	cmd.Flags().Int(
+		FlagSeedFull, 0,
+		"Seed for reproducible generations with BLOOM and OpenAI's chat models, a random seed is used when unset",
+	)
+
+	cmd.Flags().String(
+		FlagHFModelFull, "",
+		"ID of a text-generation model on the HuggingFace Inference API to use, e.g. 'bigcode/starcoder'",
+	)
+
+	cmd.Flags().String(
+		FlagModelFull, "",
+		"Model used by the selected backend, overriding the config and the profile, e.g. 'gpt-4o' or 'codellama'",
+	)
+
+	cmd.Flags().Bool(
+		FlagStreamFull, false,
+		"Print the completion as it is generated, for backends which support streaming",
+	)
+
+	cmd.Flags().Int32(
+		FlagReasoningTokensFull, 0,
+		"Max number of tokens reasoning models may spend thinking, separate from --"+FlagNTokensFull,
+	)
+
+	cmd.Flags().Int32(
+		FlagSelectFull, 0,
+		"Which completion to decode and write when more than one is generated, starting from 1",
+	)
+
+	cmd.Flags().String(
+		FlagCompletionStrategyFull, CompletionStrategyAll,
+		"How to handle multiple completions when none is selected: "+
+			"'all' presents each one and refuses to write, 'first' uses the first, "+
+			"'merge' decodes them into the same files with later completions taking precedence",
+	)
+
+	cmd.Flags().String(
+		FlagCompletionFilterFull, "",
+		"Only keep completions whose generated content matches this regular expression",
+	)
+
+	cmd.Flags().String(
+		FlagCompletionRejectFull, "",
+		"Discard completions whose generated content matches this regular expression",
+	)
+
+	cmd.Flags().String(
+		FlagFromSnapshotFull, "",
+		"Name of a snapshot (saved with --"+FlagSaveSnapshotFull+") whose files are included as context",
+	)
+
+	cmd.Flags().Bool(
+		FlagPerNamespaceDirsFull, false,
+		"Write each generated resource under a directory named after its namespace "+
+			"("+filemap.ClusterScopedDir+" for cluster-scoped resources)",
+	)
+
+	cmd.Flags().Bool(
+		FlagShowPromptFull, false,
+		"Print the full prompt to stderr before it is sent to the backend",
+	)
+
+	cmd.Flags().Bool(
+		FlagPromptOnlyFull, false,
+		"Print the full prompt and exit, without calling the backend",
+	)
+
+	cmd.Flags().Bool(
+		FlagCountOnlyFull, false,
+		"Print the estimated prompt tokens, completion budget and cost, and exit without calling the backend",
+	)
+
+	cmd.Flags().Bool(
+		FlagNoContextFull, false,
+		"Generate from the request alone, without including any files of the repo as context",
+	)
+
+	cmd.Flags().Bool(
+		FlagAutoContextFull, false,
+		"Include the files of the repo which are the most relevant to the request, ranked by their embeddings",
+	)
+
+	cmd.Flags().Int(
+		FlagContextFilesFull, DefaultContextFiles,
+		"How many files --"+FlagAutoContextFull+" includes",
+	)
+
+	cmd.Flags().Bool(
+		FlagKustomizeFull, false,
+		"Describe the nearest kustomization in the prompt, and add new files to its resources when writing them",
+	)
+
+	cmd.Flags().Bool(
+		FlagShowCostFull, false,
+		"Print the estimated cost of the generation in US dollars once it's done",
+	)
+
+	cmd.Flags().StringSlice(
+		FlagFallbackBackendsFull, nil,
+		"Backends to try in order when the selected backend is rate-limited or unavailable, "+
+			"overriding fallbackBackends in the config",
+	)
+
+	cmd.Flags().Bool(
+		FlagExplainFull, false,
+		"Ask the backend for a short summary of the changes, which is printed before the output",
+	)
+
+	cmd.Flags().String(
+		FlagSystemPromptFull, "",
+		"Send this as the system message to chat models, or prepend it to the prompt of other models "+
+			"(defaults to 'systemPrompt' in "+config.ConfigFile+")",
+	)
+
+	cmd.Flags().String(
+		FlagEndOfSequenceFull, "",
+		"Ask the model to end its output with this terminator instead of '"+gpt3.CompletionEndOfSequence+"', "+
+			"for models which tokenize it poorly (defaults to 'endOfSequence' in "+config.ConfigFile+")",
+	)
+
+	cmd.Flags().StringArray(
+		FlagStopFull, []string{},
+		"Stop the completion at this sequence too, cutting off anything the model writes after the files "+
+			"(can be repeated, OpenAI only)",
+	)
+
+	cmd.Flags().Bool(
+		FlagShowUsageFull, false,
+		"Print how many prompt and completion tokens the generation used once it's done",
+	)
+
+	cmd.Flags().String(
+		FlagTrimStrategyFull, "",
+		"How to trim the context files when the prompt doesn't fit the context window: '"+
+			TrimStrategyDropFiles+"' drops whole files, '"+TrimStrategyTruncate+"' cuts lines off the end of them "+
+			"(by default the command fails)",
+	)
+
+	cmd.Flags().String(
+		FlagCacheDirFull, "",
+		"Directory to cache completions in, so that identical requests don't call the backend again (disabled by default)",
+	)
+
+	cmd.Flags().Bool(
+		FlagNoCacheFull, false,
+		"Ignore the cached completions, while still caching the new ones",
+	)
+
+	cmd.Flags().Duration(
+		FlagCacheTTLFull, cache.DefaultTTL,
+		"How long cached completions are used for, or 0 to never expire them",
+	)
+
+	cmd.Flags().String(
+		FlagOutputDirFull, "",
+		"Directory to place the generated files in, which is created if it doesn't exist "+
+			"(undecodable output goes to '"+DefaultOutputDir+"' by default)",
+	)
+
+	cmd.Flags().String(
+		FlagGeneratedNameFull, "",
+		"Name of the files made from output which can't be decoded, where '"+GeneratedNameCounter+
+			"' is replaced with the number of the completion, e.g. 'new-resource-"+GeneratedNameCounter+
+			".yaml' (named after the resource in it by default)",
+	)
+
+	cmd.Flags().String(
+		FlagNamespaceFull, "",
+		"Set the namespace of every namespaced resource which is generated, leaving cluster-scoped resources alone",
+	)
+
+	cmd.Flags().Bool(
+		FlagApplyFull, false,
+		"Run 'kubectl apply' over the written manifests, in the namespace given with --"+FlagNamespaceFull+
+			" if any, once they were decoded and validated (requires --"+FlagWriteFull+")",
+	)
+
+	cmd.Flags().String(
+		FlagKubeContextFull, "",
+		"Kube context to apply the manifests with (requires --"+FlagApplyFull+")",
+	)
+
+	cmd.Flags().StringArray(
+		FlagLabelFull, []string{},
+		"Add a label to every generated resource, as key=value (can be repeated)",
+	)
+
+	cmd.Flags().StringArray(
+		FlagAnnotationFull, []string{},
+		"Add an annotation to every generated resource, as key=value (can be repeated)",
+	)
+
+	cmd.Flags().Bool(
+		FlagOverwriteMetadataFull, false,
+		"Replace the labels and annotations which the model already set with the ones given with --"+
+			FlagLabelFull+" and --"+FlagAnnotationFull,
+	)
+
+	cmd.Flags().Bool(
+		FlagFormatFull, false,
+		"Re-indent the generated YAML files with 2 spaces and consistent quoting, keeping the order of their keys",
+	)
+
+	cmd.Flags().String(
+		FlagCombineFull, "",
+		"Combine the generated files into a single multi-document YAML file at this path",
+	)
+
+	cmd.Flags().String(
+		FlagGitBranchFull, "",
+		"Commit the written files to this branch, creating it if it doesn't exist (requires --"+FlagWriteFull+")",
+	)
+
+	cmd.Flags().Bool(
+		FlagValidateFull, false,
+		"Refuse to output generated YAML which isn't a Kubernetes object, instead of only warning about it",
+	)
+
+	cmd.Flags().Bool(
+		FlagStructuredFull, false,
+		"Have OpenAI's chat models call a "+gpt3.WriteFilesFunction+" function with the files, "+
+			"instead of decoding them from the text of the completion",
+	)
+
+	cmd.Flags().String(
+		FlagSpecFileFull, "",
+		"Path to a spec file (markdown or YAML) describing one resource per section, all of which are generated in one pass",
+	)
+
+	cmd.Flags().String(
+		FlagHelmChartFull, "",
+		"Path to a Helm chart, whose defaults are included as context to generate a "+helm.ValuesFileName+
+			" overriding them instead of Kubernetes YAML",
+	)
+
 	return cmd
 }
 
@@ -66,53 +366,573 @@ func RunGenerate(cmd *cobra.Command, args []string) error {
 	if err != nil {
 		return err
 	}
-	input := PrepareGenerateInput(r.UserRequest, r.FilemapText)
-	client, err := PrepareGenerateClient(r, input)
+	model := ModelName(r.Config, r.Backend)
+	input, err := TrimContext(r.Tokenizer, r.Backend, model, r.TrimStrategy, int(r.NTokens), r.Filemap,
+		func() (string, error) {
+			return BuildGenerateInput(r)
+		})
 	if err != nil {
-		return fmt.Errorf("could not create client: %w", err)
+		return err
+	}
+	// API keys are never part of the prompt, but make sure that they aren't printed
+	if r.PromptOnly {
+		fmt.Fprint(cmd.OutOrStdout(), RedactSecrets(input, r.Config.Secrets()))
+		return nil
+	}
+	if r.CountOnly {
+		ReportEstimate(cmd.OutOrStdout(), r, input)
+		return nil
+	}
+	if r.ShowPrompt {
+		fmt.Fprintln(cmd.ErrOrStderr(), RedactSecrets(input, r.Config.Secrets()))
 	}
-	choices, err := client.Generate()
+	if err = CheckTokenBudget(r.Tokenizer, r.Backend, model, input, int(r.NTokens), r.Filemap); err != nil {
+		return err
+	}
+	ctx, cancel := RequestContext(cmd, r.Timeout)
+	defer cancel()
+	client, choices, err := GenerateWithFallback(ctx, r, input)
+	if err != nil {
+		return fmt.Errorf("could not generate files: %w", CancellationError(ctx, r.Timeout, err))
+	}
+	if r.ShowCost {
+		ReportCost(cmd.ErrOrStderr(), r, input, client, choices)
+	}
+	if r.ShowUsage {
+		ReportUsage(cmd.ErrOrStderr(), r, input, client, choices)
+	}
+	r.Completions = len(choices)
+	r.RawCompletions = append([]string(nil), choices...)
+	// reasoning models may think out loud before answering
+	for i, choice := range choices {
+		choices[i] = ai.StripReasoning(choice)
+	}
+	choices, err = FilterCompletions(choices, r.CompletionFilter, r.CompletionReject, r.Terminator())
+	if err != nil {
+		return err
+	}
+	choices, err = SelectCompletions(choices, int(r.Select), r.CompletionStrategy, r.IsWrite)
 	if err != nil {
-		return fmt.Errorf("could not generate files: %w", err)
+		return err
 	}
 
-	// decode the response
-	r.Filemap = filemap.NewFilemap()
-	log.Printf("decoding output")
+	// present every completion separately
+	if len(choices) > 1 && r.CompletionStrategy == CompletionStrategyAll {
+		var files []string
+		for i, choice := range choices {
+			logger.Infof("completion %d of %d:\n", i+1, len(choices))
+			if err = DecodeAndOutput(ctx, r, []string{choice}); err != nil {
+				return err
+			}
+			files = append(files, GeneratedFiles(r.Filemap)...)
+		}
+		RecordHistory(r, CommandGenerate, files)
+		logger.Infof("use --%s to pick one of the %d completions to write\n", FlagSelectFull, len(choices))
+		if r.SaveSnapshot != "" {
+			logger.Infof("not saving snapshot %q, since no single completion was chosen\n", r.SaveSnapshot)
+		}
+		return nil
+	}
+
+	if err = DecodeAndOutput(ctx, r, choices); err != nil {
+		return err
+	}
+	RecordHistory(r, CommandGenerate, GeneratedFiles(r.Filemap))
+	if err = SaveSnapshot(r); err != nil {
+		return err
+	}
+	return CommitToBranch(r)
+}
+
+// CommitToBranch Commits the files which were written to the branch given with --git-branch,
+// creating the branch if needed. Only the written files are staged, so unrelated changes
+// in the working tree are left alone.
+func CommitToBranch(r *Request) error {
+	if r.GitBranch == "" || !r.IsWrite {
+		return nil
+	}
+	paths := make([]string, 0, len(r.Filemap.Files))
+	for _, file := range r.Filemap.Files {
+		paths = append(paths, file.Path)
+	}
+	sort.Strings(paths)
+
+	repo, err := git.Open(".")
+	if err == nil {
+		err = repo.Checkout(r.GitBranch)
+	}
+	if err == nil {
+		err = repo.CommitFiles(git.CommitMessage(r.UserRequest), paths)
+	}
+	if errors.Is(err, git.ErrNoChanges) {
+		logger.Infof("the generated files are unchanged, nothing was committed to %q\n", r.GitBranch)
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("the files were written, but could not be committed to branch %q: %w", r.GitBranch, err)
+	}
+	logger.Infof("committed %d files to branch %q\n", len(paths), r.GitBranch)
+	return nil
+}
+
+// BuildGenerateInput Encodes the files in the request's filemap and builds the prompt from them.
+func BuildGenerateInput(r *Request) (string, error) {
+	r.FilemapText = JoinContext(r.Filemap.EncodeToInputText(), r.SnapshotText)
+	var input string
+	switch {
+	case r.Spec != nil:
+		input = PrepareSpecInput(r.UserRequest, r.Spec, r.FilemapText, r.Terminator())
+	case r.HelmChart != nil:
+		input = PrepareHelmInput(r.UserRequest, r.HelmChart, r.FilemapText, r.Terminator())
+	case r.Patch && strings.TrimSpace(r.FilemapText) != "":
+		// the files are modified rather than created, so the model is asked for them back in full
+		input = PreparePatchInput(r.UserRequest, r.FilemapText, r.Filemap.OnlyYAML(), r.Terminator())
+	default:
+		var err error
+		input, err = PrepareGenerateInput(r.UserRequest, r.FilemapText, r.Filemap.OnlyYAML(),
+			r.Config.PromptTemplates, r.Terminator())
+		if err != nil {
+			return "", err
+		}
+	}
+	return ContextSummaryHeader(r.ContextSummary) + r.KustomizeContext + input, nil
+}
+
+// cacheKey Contains everything which determines the completions returned for a prompt.
+type cacheKey struct {
+	Backend      ai.Backend `json:"backend"`
+	URL          string     `json:"url,omitempty"`
+	Model        string     `json:"model"`
+	Prompt       string     `json:"prompt"`
+	NTokens      int32      `json:"nTokens"`
+	NCompletions int32      `json:"nCompletions"`
+	Temperature  float32    `json:"temperature"`
+	TopP         *float32   `json:"topP,omitempty"`
+	Seed         *int       `json:"seed,omitempty"`
+	SystemPrompt string     `json:"systemPrompt,omitempty"`
+	Structured   bool       `json:"structured,omitempty"`
+	Stop         []string   `json:"stop,omitempty"`
+	// ReasoningTokens Is only set when the backend takes a reasoning budget.
+	ReasoningTokens int32 `json:"reasoningTokens,omitempty"`
+}
+
+// reasoningBudget Returns the reasoning budget which is sent to the backend, if any.
+func reasoningBudget(r *Request) int32 {
+	if !SupportsReasoningTokens(r) {
+		return 0
+	}
+	return r.ReasoningTokens
+}
+
+// ModelName Returns the name of the model which the backend generates with, after the defaults of the
+// config were set, or an empty string for backends which are only configured by their URL.
+func ModelName(conf config.Config, backend ai.Backend) string {
+	switch backend {
+	case ai.GPT3:
+		if conf.OpenAI != nil {
+			return conf.OpenAI.ModelName()
+		}
+	case ai.CLAUDE:
+		if conf.Claude != nil {
+			return conf.Claude.Model
+		}
+	case ai.OLLAMA:
+		if conf.Ollama != nil {
+			return conf.Ollama.Model
+		}
+	case ai.HUGGINGFACE:
+		if conf.HuggingFace != nil {
+			return conf.HuggingFace.ModelID
+		}
+	case ai.COHERE:
+		if conf.Cohere != nil {
+			return conf.Cohere.Model
+		}
+	case ai.GEMINI:
+		if conf.Gemini != nil {
+			return conf.Gemini.Model
+		}
+	case ai.GPTJ, ai.BLOOM, ai.OPT, ai.Unselected:
+	}
+	return ""
+}
+
+// CacheKey Returns the key which the completions for the prompt are cached under,
+// derived from the prompt, the backend with its URL and model, and the sampling parameters.
+func CacheKey(r *Request, prompt string) (string, error) {
+	return cache.Key(cacheKey{
+		Backend:         r.Backend,
+		URL:             BackendURL(r.Config, r.Backend),
+		Model:           ModelName(r.Config, r.Backend),
+		Prompt:          prompt,
+		NTokens:         r.NTokens,
+		NCompletions:    r.NCompletions,
+		Temperature:     r.Temperature,
+		TopP:            r.TopP,
+		Seed:            r.Seed,
+		SystemPrompt:    r.SystemPrompt,
+		Structured:      r.Structured,
+		Stop:            r.Stop,
+		ReasoningTokens: reasoningBudget(r),
+	})
+}
+
+// BackendURL Returns the URL which the backend sends its requests to, after the defaults of the config were set.
+// Backends whose URL is derived from the model, such as Gemini's, return the URL of the configured model.
+func BackendURL(conf config.Config, backend ai.Backend) string {
+	switch backend {
+	case ai.GPT3:
+		if conf.OpenAI != nil {
+			return conf.OpenAI.URL()
+		}
+	case ai.GPTJ:
+		if conf.GPTJ != nil {
+			return conf.GPTJ.URL
+		}
+	case ai.BLOOM:
+		if conf.BLOOM != nil {
+			return conf.BLOOM.URL
+		}
+	case ai.OPT:
+		if conf.OPT != nil {
+			return conf.OPT.URL
+		}
+	case ai.CLAUDE:
+		if conf.Claude != nil {
+			return conf.Claude.URL
+		}
+	case ai.OLLAMA:
+		if conf.Ollama != nil {
+			return conf.Ollama.URL
+		}
+	case ai.HUGGINGFACE:
+		if conf.HuggingFace != nil {
+			return conf.HuggingFace.URL
+		}
+	case ai.COHERE:
+		if conf.Cohere != nil {
+			return conf.Cohere.URL
+		}
+	case ai.GEMINI:
+		if conf.Gemini != nil {
+			return conf.Gemini.GenerateURL()
+		}
+	case ai.Unselected:
+	}
+	return ""
+}
+
+// CachedGenerateChoices Returns the completions cached for the prompt when --cache-dir is set,
+// only requesting them from the client when they aren't cached or have expired.
+// With --no-cache, the cached completions are ignored but the new ones are still cached.
+func CachedGenerateChoices(ctx context.Context, r *Request, prompt string, client ai.GenerateClient) ([]string, error) {
+	if r.CacheDir == "" {
+		return GenerateChoices(ctx, r, client)
+	}
+	key, err := CacheKey(r, prompt)
+	if err != nil {
+		return nil, err
+	}
+	store := &cache.Store{Dir: r.CacheDir, TTL: r.CacheTTL}
+	if !r.NoCache {
+		if choices, ok := store.Load(key); ok {
+			logger.Infof("using %d cached completions from %q\n", len(choices), r.CacheDir)
+			r.FromCache = true
+			return choices, nil
+		}
+	}
+	choices, err := GenerateChoices(ctx, r, client)
+	if err != nil {
+		return nil, err
+	}
+	if err = store.Save(key, choices); err != nil {
+		logger.Warnf("could not cache the completions: %s\n", err)
+	}
+	return choices, nil
+}
+
+// GenerateChoices Requests completions from the client, streaming them to STDERR
+// as they arrive when streaming was requested and the backend supports it.
+func GenerateChoices(ctx context.Context, r *Request, client ai.GenerateClient) ([]string, error) {
+	if !r.Stream {
+		return ai.RetryGenerate(ctx, client, r.Retry)
+	}
+	streamer, ok := client.(ai.StreamingGenerateClient)
+	if !ok {
+		logger.Warnf("the %q backend does not support streaming, waiting for the full completion\n", r.Backend)
+		return ai.RetryGenerate(ctx, client, r.Retry)
+	}
+	choices, err := ai.RetryGenerateStream(ctx, streamer, func(chunk string) {
+		fmt.Fprint(os.Stderr, chunk)
+	}, r.Retry)
+	fmt.Fprintln(os.Stderr)
+	return choices, err
+}
+
+// FilterCompletions Discards the completions whose decoded content doesn't match
+// the filter, or does match the reject pattern. Either pattern may be nil.
+// The completions are decoded up to their endOfSequence terminator.
+// An error is returned if no completions remain.
+func FilterCompletions(choices []string, filter, reject *regexp.Regexp, endOfSequence string) ([]string, error) {
+	if filter == nil && reject == nil {
+		return choices, nil
+	}
+	kept := make([]string, 0, len(choices))
 	for _, choice := range choices {
+		content := decodedContent(choice, endOfSequence)
+		if filter != nil && !filter.MatchString(content) {
+			continue
+		}
+		if reject != nil && reject.MatchString(content) {
+			continue
+		}
+		kept = append(kept, choice)
+	}
+	logger.Infof("filtered out %d of %d completions\n", len(choices)-len(kept), len(choices))
+	if len(kept) == 0 {
+		return nil, fmt.Errorf("all %d completions were filtered out", len(choices))
+	}
+	return kept, nil
+}
+
+// decodedContent Returns the content of the files decoded from the given completion,
+// or the completion itself if it cannot be decoded.
+func decodedContent(choice, endOfSequence string) string {
+	if args, ok := gpt3.ParseWriteFiles(choice); ok {
+		contents := make([]string, 0, len(args.Files))
+		for _, file := range args.Files {
+			contents = append(contents, file.Content)
+		}
+		return strings.Join(contents, "\n")
+	}
+	fm := filemap.NewFilemap()
+	content, _ := filemap.TrimEndOfSequence(filemap.StripCodeFences(choice), endOfSequence)
+	if err := fm.Decode(content); err != nil {
+		return choice
+	}
+	contents := make([]string, 0, len(fm.Files))
+	for _, file := range fm.Files {
+		contents = append(contents, file.Content)
+	}
+	return strings.Join(contents, "\n")
+}
+
+// SelectCompletions Narrows down the completions returned by the backend to the ones
+// that should be decoded. A positive selection picks that completion (starting from 1),
+// otherwise the strategy decides. Writing is refused when it would be ambiguous which
+// completion ends up on the disk.
+func SelectCompletions(choices []string, selection int, strategy string, isWrite bool) ([]string, error) {
+	switch strategy {
+	case CompletionStrategyAll, CompletionStrategyFirst, CompletionStrategyMerge:
+	default:
+		return nil, fmt.Errorf("invalid completion strategy %q, must be one of: %s, %s, %s",
+			strategy, CompletionStrategyAll, CompletionStrategyFirst, CompletionStrategyMerge)
+	}
+
+	if selection > 0 {
+		if selection > len(choices) {
+			return nil, fmt.Errorf("cannot select completion %d, only %d were generated", selection, len(choices))
+		}
+		return choices[selection-1 : selection], nil
+	}
+	if len(choices) <= 1 {
+		return choices, nil
+	}
+
+	switch strategy {
+	case CompletionStrategyFirst:
+		return choices[:1], nil
+	case CompletionStrategyAll:
+		if isWrite {
+			return nil, fmt.Errorf("%d completions were generated and it is ambiguous which one to write, "+
+				"use --%s or --%s", len(choices), FlagSelectFull, FlagCompletionStrategyFull)
+		}
+	}
+	return choices, nil
+}
+
+// DecodeAndOutput Decodes the given completions into the request's filemap,
+// and prints or writes the result. Later completions override files from earlier ones.
+// Output which can't be decoded is repaired with requests made with ctx.
+func DecodeAndOutput(ctx context.Context, r *Request, choices []string) error {
+	logger.Debugf("decoding output")
+	// chat models tend to wrap the files in markdown code blocks
+	stripped := make([]string, len(choices))
+	for i, choice := range choices {
+		stripped[i] = filemap.StripCodeFences(choice)
+	}
+	raw := choices
+	choices = stripped
+	// values files aren't Kubernetes objects, so they're neither validated nor grouped by namespace
+	if r.HelmChart != nil {
+		r.Filemap = DecodeHelmValues(choices)
+		if r.OutputDir != "" {
+			r.Filemap.PlaceUnder(r.OutputDir)
+		}
+		if r.Format {
+			r.Filemap.Format()
+		}
+		return ExplainAndOutput(ctx, r)
+	}
+	var err error
+	r.Filemap = filemap.NewFilemap()
+	r.Filemap.UseEndOfSequence(r.Terminator())
+	r.DecodeError = ""
+	for i, choice := range choices {
+		// the files passed to the write_files function map to files without parsing any text
+		if args, ok := structuredFiles(r, raw[i]); ok {
+			for _, file := range args.Files {
+				r.Filemap.AddContentByPath(file.Path, file.Content)
+			}
+			continue
+		}
 		err = r.Filemap.DecodeFromOutput(choice)
+		// ask the model to reformat output which can't be decoded, before falling back to writing it as-is
+		if err != nil && r.MaxRepairAttempts > 0 {
+			var repaired string
+			if repaired, err = RepairCompletion(ctx, r, choice, err); err == nil {
+				err = r.Filemap.DecodeFromOutput(repaired)
+			}
+			// don't fall back to writing the output when the run was aborted
+			if ctx.Err() != nil {
+				return CancellationError(ctx, r.Timeout, err)
+			}
+		}
 		if err != nil {
 			break
 		}
 	}
 
 	if err == nil {
-		return PrintOrWriteOut(r)
+		if r.Spec != nil {
+			assignSpecPaths(r.Filemap, r.Spec)
+		}
+		if r.OutputDir != "" {
+			r.Filemap.PlaceUnder(r.OutputDir)
+		}
+	} else {
+		// HACK: try other way to decode the output to a fileset
+		logger.Warnf("decoding failed, got error: %s", err)
+		r.DecodeError = err.Error()
+		// fallback - generate new files and put the content inside
+		outputDir := r.OutputDir
+		if outputDir == "" {
+			outputDir = DefaultOutputDir
+		}
+		newFiles := generateNewFiles(choices, outputDir, r.GeneratedName, r.Terminator())
+		r.Filemap.Files = newFiles
 	}
 
-	// HACK: try other way to decode the output to a fileset
-	log.Printf("decoding failed, got error: %s", err)
-	// fallback - generate new files and put the content inside
-	newFiles := generateNewFiles(choices)
-	r.Filemap.Files = newFiles
+	if r.Namespace != "" {
+		if err = r.Filemap.SetNamespace(r.Namespace); err != nil {
+			logger.Warnf("%s\n", err)
+		}
+	}
+	if err = r.Filemap.AddMetadata(r.Labels, r.Annotations, r.OverwriteMetadata); err != nil {
+		logger.Warnf("%s\n", err)
+	}
+	if r.PerNamespaceDirs {
+		r.Filemap.GroupByNamespace()
+	}
+	if err = ValidateFiles(r); err != nil {
+		return err
+	}
+	if r.Format {
+		r.Filemap.Format()
+	}
+	if r.Combine != "" {
+		if err = r.Filemap.Combine(r.Combine); err != nil {
+			return fmt.Errorf("could not combine the generated files: %w", err)
+		}
+	}
+	return ExplainAndOutput(ctx, r)
+}
 
-	return PrintOrWriteOut(r)
+// structuredFiles Returns the files passed to the write_files function in the completion when
+// structured output was requested, and whether the model called it. Otherwise the completion
+// is decoded from its text.
+func structuredFiles(r *Request, choice string) (gpt3.WriteFilesArguments, bool) {
+	if !r.Structured {
+		return gpt3.WriteFilesArguments{}, false
+	}
+	args, ok := gpt3.ParseWriteFiles(choice)
+	if !ok {
+		logger.Warnf("the model didn't call %s, decoding the text of the completion instead\n", gpt3.WriteFilesFunction)
+	}
+	return args, ok
+}
+
+// ExplainAndOutput Prints the summary of the changes when it was asked for, then prints or writes the files.
+func ExplainAndOutput(ctx context.Context, r *Request) error {
+	if r.Explain {
+		PrintExplanation(ctx, r.ErrOut, r)
+	}
+	return PrintOrWriteOut(ctx, r)
+}
+
+// ValidateFiles Checks that every generated YAML file is a Kubernetes manifest.
+// Invalid files are only reported as warnings, unless --validate was passed.
+func ValidateFiles(r *Request) error {
+	errs := r.Filemap.Validate()
+	if len(errs) == 0 {
+		return nil
+	}
+	messages := make([]string, len(errs))
+	for i, err := range errs {
+		messages[i] = err.Error()
+		if !r.Validate {
+			logger.Warnf("%s\n", err)
+		}
+	}
+	if !r.Validate {
+		return nil
+	}
+	return fmt.Errorf("%d generated files are invalid: %s", len(errs), strings.Join(messages, "; "))
 }
 
 // PrepareGenerateClient Returns a Generate client depending on which backend was
 // selected by the user.
 func PrepareGenerateClient(r *Request, prompt string) (ai.GenerateClient, error) {
 	var client ai.GenerateClient
+	if r.ReasoningTokens > 0 && !SupportsReasoningTokens(r) {
+		logger.Warnf("the %q backend does not support a reasoning token budget, ignoring --%s\n",
+			r.Backend, FlagReasoningTokensFull)
+	}
+	if r.Seed != nil && !SupportsSeed(r) {
+		logger.Warnf("the %q backend does not support seeding, ignoring --%s\n", r.Backend, FlagSeedFull)
+	}
+	if len(r.Stop) > 0 && r.Backend != ai.GPT3 {
+		logger.Warnf("the %q backend does not support stop sequences, ignoring --%s\n", r.Backend, FlagStopFull)
+	}
+	if r.Structured && !SupportsStructuredOutput(r) {
+		logger.Warnf("the %q backend does not support structured output, decoding the text of the completion instead\n",
+			r.Backend)
+	}
+	if r.SystemPrompt != "" && !UsesSystemMessage(r) {
+		prompt = r.SystemPrompt + "\n\n" + prompt
+	}
 	switch r.Backend {
 	case ai.GPT3:
 		if r.Config.OpenAI == nil {
 			return nil, fmt.Errorf("no config provided for gpt-3")
 		}
+		if r.Config.OpenAI.IsAzure() && r.Config.OpenAI.Deployment == "" {
+			return nil, fmt.Errorf("no deployment provided for azure openai")
+		}
+		conf := *r.Config.OpenAI
+		conf.SystemPrompt = r.SystemPrompt
+		conf.EndOfSequence = r.Terminator()
+		conf.Seed = r.Seed
+		conf.Stop = r.Stop
+		conf.Structured = r.Structured
+		conf.ReasoningTokens = int(r.ReasoningTokens)
 		client = gpt3.CreateGPT3GenerateClient(
-			*r.Config.OpenAI,
+			conf,
 			prompt,
 			int(r.NTokens),
 			int(r.NCompletions),
+			r.Temperature,
+			r.TopP,
 		)
 	case ai.GPTJ:
 		// FIXME: have the config load defaults
@@ -123,7 +943,8 @@ func PrepareGenerateClient(r *Request, prompt string) (ai.GenerateClient, error)
 			*r.Config.GPTJ,
 			gptj.GenerateParams{
 				Context:        prompt,
-				Temp:           0.0,
+				Temp:           r.Temperature,
+				TopP:           TopPOrDefault(r.TopP, 0),
 				ResponseLength: gptj.MaxTokensGenerate,
 				RemoveInput:    true,
 			},
@@ -132,83 +953,296 @@ func PrepareGenerateClient(r *Request, prompt string) (ai.GenerateClient, error)
 		if r.Config.BLOOM == nil {
 			return nil, fmt.Errorf("no config provided for bloom")
 		}
-		//nolint:gosec,gomnd // this random number hardly matters
-		randomSeed := rand.Int() % 100
-		client = bloom.CreateBloomGenerateClient(
-			*r.Config.BLOOM,
-			prompt,
-			bloom.GenerateParameters{
-				Seed:          randomSeed,
-				EarlyStopping: false,
-				MaxNewTokens:  bloom.DefaultTokenSize,
-				// sampling reduces accuracy
-				DoSample: false,
+		client = bloom.CreateBloomGenerateClient(*r.Config.BLOOM, prompt, BloomParams(r))
+	case ai.OPT:
+		if r.Config.OPT == nil {
+			return nil, fmt.Errorf("no config provided for opt")
+		}
+		client = opt.CreateOPTGenerateClient(
+			*r.Config.OPT,
+			opt.GenerateParams{
+				Prompt:      prompt,
+				MaxTokens:   int(r.NTokens),
+				Temperature: r.Temperature,
 				//nolint:gomnd // this is the default
-				TopP: 0.9,
+				TopP: TopPOrDefault(r.TopP, 0.9),
 			},
 		)
-	case ai.OPT:
-		return nil, fmt.Errorf("opt does not implement the generate client")
+	case ai.CLAUDE:
+		if r.Config.Claude == nil {
+			return nil, fmt.Errorf("no config provided for claude")
+		}
+		conf := *r.Config.Claude
+		conf.SystemPrompt = r.SystemPrompt
+		conf.ThinkingBudget = int(r.ReasoningTokens)
+		client = claude.CreateClaudeGenerateClient(
+			conf,
+			prompt,
+			int(r.NTokens),
+			1,
+			r.Temperature,
+			r.TopP,
+		)
+	case ai.OLLAMA:
+		if r.Config.Ollama == nil {
+			return nil, fmt.Errorf("no config provided for ollama")
+		}
+		conf := *r.Config.Ollama
+		conf.SystemPrompt = r.SystemPrompt
+		client = ollama.CreateOllamaGenerateClient(
+			conf,
+			prompt,
+			int(r.NTokens),
+			1,
+			r.Temperature,
+			r.TopP,
+		)
+	case ai.HUGGINGFACE:
+		if r.Config.HuggingFace == nil || r.Config.HuggingFace.ModelID == "" {
+			return nil, fmt.Errorf("no model provided for huggingface, use --%s", FlagHFModelFull)
+		}
+		client = hfinference.CreateGenerateClient(*r.Config.HuggingFace, prompt, HuggingFaceParams(r))
+	case ai.COHERE:
+		if r.Config.Cohere == nil {
+			return nil, fmt.Errorf("no config provided for cohere")
+		}
+		client = cohere.CreateCohereGenerateClient(
+			*r.Config.Cohere,
+			prompt,
+			int(r.NTokens),
+			int(r.NCompletions),
+			r.Temperature,
+			r.TopP,
+		)
+	case ai.GEMINI:
+		if r.Config.Gemini == nil {
+			return nil, fmt.Errorf("no config provided for gemini")
+		}
+		conf := *r.Config.Gemini
+		conf.SystemPrompt = r.SystemPrompt
+		client = gemini.CreateGeminiGenerateClient(
+			conf,
+			prompt,
+			int(r.NTokens),
+			r.Temperature,
+			r.TopP,
+		)
 	case ai.Unselected:
 		return nil, fmt.Errorf("no backend selected")
 	default:
 		return nil, fmt.Errorf("invalid backend selected")
 	}
+	// fan out a request for every completion when the backend only returns one at a time
+	if !ai.SupportsMultipleCompletions(r.Backend) {
+		client = ai.Repeat(client, int(r.NCompletions), r.Concurrency)
+	}
 	return client, nil
 }
 
+// SupportsReasoningTokens Reports whether the selected backend takes a budget of reasoning tokens:
+// OpenAI's reasoning models, and Claude through its extended thinking.
+func SupportsReasoningTokens(r *Request) bool {
+	switch r.Backend {
+	case ai.GPT3:
+		return r.Config.OpenAI != nil && gpt3.IsReasoningModel(r.Config.OpenAI.ModelName())
+	case ai.CLAUDE:
+		return true
+	case ai.GPTJ, ai.BLOOM, ai.OPT, ai.OLLAMA, ai.HUGGINGFACE, ai.COHERE, ai.GEMINI, ai.Unselected:
+	}
+	return false
+}
+
+// SupportsSeed Reports whether the selected backend samples deterministically for a given seed.
+func SupportsSeed(r *Request) bool {
+	switch r.Backend {
+	case ai.GPT3:
+		// the seed is only sent to the chat completions endpoint
+		return r.Config.OpenAI != nil && gpt3.IsChatModel(r.Config.OpenAI.ModelName())
+	case ai.BLOOM:
+		return true
+	case ai.GPTJ, ai.OPT, ai.CLAUDE, ai.OLLAMA, ai.HUGGINGFACE, ai.COHERE, ai.GEMINI, ai.Unselected:
+	}
+	return false
+}
+
+// SupportsStructuredOutput Reports whether the selected backend can pass the files to a function
+// rather than writing them out as text.
+func SupportsStructuredOutput(r *Request) bool {
+	switch r.Backend {
+	case ai.GPT3:
+		// functions are only sent to the chat completions endpoint
+		return r.Config.OpenAI != nil && gpt3.IsChatModel(r.Config.OpenAI.ModelName())
+	case ai.GPTJ, ai.BLOOM, ai.OPT, ai.CLAUDE, ai.OLLAMA, ai.HUGGINGFACE, ai.COHERE, ai.GEMINI, ai.Unselected:
+	}
+	return false
+}
+
+// UsesSystemMessage Reports whether the selected backend sends the system prompt as a separate
+// system message, rather than at the top of the prompt.
+func UsesSystemMessage(r *Request) bool {
+	switch r.Backend {
+	case ai.GPT3:
+		return r.Config.OpenAI != nil && gpt3.IsChatModel(r.Config.OpenAI.ModelName())
+	case ai.CLAUDE, ai.OLLAMA, ai.GEMINI:
+		return true
+	case ai.GPTJ, ai.BLOOM, ai.OPT, ai.HUGGINGFACE, ai.COHERE, ai.Unselected:
+		return false
+	default:
+		return false
+	}
+}
+
+// BloomParams Returns the generation parameters sent to BLOOM, seeded with the request's seed or a random one.
+func BloomParams(r *Request) bloom.GenerateParameters {
+	//nolint:gosec,gomnd // this random number hardly matters
+	seed := rand.Int() % 100
+	if r.Seed != nil {
+		seed = *r.Seed
+	}
+	return bloom.GenerateParameters{
+		Seed:          seed,
+		EarlyStopping: false,
+		MaxNewTokens:  bloom.DefaultTokenSize,
+		// sampling reduces accuracy, so it's only enabled for a non-zero temperature
+		DoSample:    r.Temperature > 0,
+		Temperature: r.Temperature,
+		//nolint:gomnd // this is the default
+		TopP: TopPOrDefault(r.TopP, 0.9),
+	}
+}
+
+// HuggingFaceParams Returns the text-generation parameters sent to the HuggingFace Inference API.
+func HuggingFaceParams(r *Request) map[string]interface{} {
+	params := map[string]interface{}{
+		"max_new_tokens":       r.NTokens,
+		"return_full_text":     false,
+		"num_return_sequences": r.NCompletions,
+	}
+	// the API only accepts a temperature when sampling
+	if r.Temperature > 0 {
+		params["do_sample"] = true
+		params["temperature"] = r.Temperature
+	}
+	if r.TopP != nil {
+		params["top_p"] = *r.TopP
+	}
+	return params
+}
+
+// TopPOrDefault Returns the top-p given on the command-line, or the backend's default when it wasn't set.
+func TopPOrDefault(topP *float32, backendDefault float32) float32 {
+	if topP == nil {
+		return backendDefault
+	}
+	return *topP
+}
+
 // PrepareGenerateInput Accepts the userInput and all of the files encoded as a string,
-// and formats them as a prompt to be sent off to OpenAI.
-func PrepareGenerateInput(userInput string, encodedFiles string) string {
+// and formats them as a prompt to be sent off to OpenAI. The prompt only refers to
+// Kubernetes YAML when yamlOnly is set, since other types of files may be included as context.
+// Any of the templates provided override the built-in wording of their part of the prompt.
+// The model is asked to terminate the files with endOfSequence.
+func PrepareGenerateInput(
+	userInput string, encodedFiles string, yamlOnly bool, templates *config.PromptTemplates, endOfSequence string,
+) (string, error) {
 	// HACK: prompt wording needs to be adjusted to improve accuracy
-	var prompt = ""
 	var withFiles = len(encodedFiles) > 0
+	if templates == nil {
+		templates = &config.PromptTemplates{}
+	}
+	data := PromptData{
+		Request:       userInput,
+		EncodedFiles:  encodedFiles,
+		Delimiter:     filemap.FileDelimeter,
+		EndOfSequence: endOfSequence,
+		WithFiles:     withFiles,
+		YAMLOnly:      yamlOnly,
+	}
+	nouns := nounsFor(yamlOnly)
 
-	// preamble
-	prompt += preamble(withFiles)
+	parts := []struct {
+		name     string
+		template string
+		builtin  func() string
+	}{
+		// preamble
+		{"preamble", templates.Preamble, func() string { return preamble(withFiles, nouns) }},
+		// instructions
+		{"instructions", templates.Instructions, func() string { return instructions(withFiles, nouns, endOfSequence) }},
+		// prompt the AI for a response
+		{"callToAction", templates.CallToAction, func() string {
+			return callToActionSequence(userInput, encodedFiles, nouns)
+		}},
+	}
 
-	// instructions
-	prompt += instructions(withFiles)
+	var prompt strings.Builder
+	for _, part := range parts {
+		if part.template == "" {
+			prompt.WriteString(part.builtin())
+			continue
+		}
+		rendered, err := RenderPromptTemplate(part.name, part.template, data)
+		if err != nil {
+			return "", err
+		}
+		prompt.WriteString(rendered)
+	}
+	return prompt.String(), nil
+}
 
-	// prompt the AI for a response
-	prompt += callToActionSequence(userInput, encodedFiles)
-	return prompt
+// promptNouns Are the words used to refer to the files in the generation prompt.
+type promptNouns struct {
+	// kind Describes the file being requested.
+	kind string
+	// single Refers to a single file.
+	single string
+	// plural Refers to several files.
+	plural string
+}
+
+// nounsFor Returns the words which refer to the files in the prompt, which are
+// Kubernetes YAMLs unless other types of files are included.
+func nounsFor(yamlOnly bool) promptNouns {
+	if yamlOnly {
+		return promptNouns{kind: "Kubernetes YAML", single: "YAML", plural: "YAMLs"}
+	}
+	return promptNouns{kind: "file", single: "file", plural: "files"}
 }
 
 // preamble Returns the preamble for the generation prompt, with varied text
-// depending on whether or not the prompt will be including other relevant YAML
+// depending on whether or not the prompt will be including other relevant
 // files.
-func preamble(withFiles bool) string {
+func preamble(withFiles bool, nouns promptNouns) string {
 	if withFiles {
-		return `## This document contains instructions for a new Kubernetes YAML that needs to be created,
-## along with the relevant YAMLs for context, and the resultant YAML.`
+		return fmt.Sprintf(`## This document contains instructions for a new %s that needs to be created,
+## along with the relevant %s for context, and the resultant %s.`, nouns.kind, nouns.plural, nouns.single)
 	}
-	return `## This document contains instructions for a new Kubernetes YAML that needs to be created,
-## and the resultant YAML.`
+	return fmt.Sprintf(`## This document contains instructions for a new %s that needs to be created,
+## and the resultant %s.`, nouns.kind, nouns.single)
 }
 
 // instructions Returns the sequence in the prompt which details the ordering of the
 // document for the AI, and what it should expect when parsing the tokens.
-func instructions(withFiles bool) string {
+func instructions(withFiles bool, nouns promptNouns, endOfSequence string) string {
 	var numInstructions int8 = 1
 
 	// instructions
 	prompt := fmt.Sprintf(`
 ##
 ## The structure of the document is as follows:
-## %d. Description of the desired YAML`, numInstructions)
+## %d. Description of the desired %s`, numInstructions, nouns.single)
 	numInstructions++
 
-	// mention that extra YAMLs will be provided for context
+	// mention that extra files will be provided for context
 	if withFiles {
 		prompt += fmt.Sprintf(`
-## %d. The existing YAMLs, each separated by a '%s'`, numInstructions, filemap.FileDelimeter)
+## %d. The existing %s, each separated by a '%s'`, numInstructions, nouns.plural, filemap.FileDelimeter)
 		numInstructions++
 	}
 
 	// instruction for the generated code
 	prompt += fmt.Sprintf(`
-## %d. The new YAML, terminated by an '%s'`, numInstructions, gpt3.CompletionEndOfSequence)
+## %d. The new %s, terminated by an '%s'`, numInstructions, nouns.single, endOfSequence)
 	prompt += "\n"
 
 	return prompt
@@ -216,41 +1250,150 @@ func instructions(withFiles bool) string {
 
 // callToActionSequence Creates the section which includes the actual request
 // for the generated YAML, along with the encodedFiles for context if those are also needed.
-func callToActionSequence(request string, encodedFiles string) string {
+func callToActionSequence(request string, encodedFiles string, nouns promptNouns) string {
 	// reset counter
 	numInstructions := 1
 
 	// add the user input
 	prompt := fmt.Sprintf(`
-## %d. Instructions for the new Kubernetes YAML:
+## %d. Instructions for the new %s:
 %s
-`, numInstructions, request)
+`, numInstructions, nouns.kind, request)
 	numInstructions++
 
 	// add the encoded files if they exist
 	if strings.TrimSpace(encodedFiles) != "" {
 		prompt += fmt.Sprintf(`
+## %d. Existing %s:
+%s
+`, numInstructions, nouns.plural, encodedFiles)
+		numInstructions++
+	}
+
+	// add the completion sequence
+	prompt += fmt.Sprintf(`
+## %d. The new %s:
+`, numInstructions, nouns.single)
+	return prompt
+}
+
+// PreparePatchInput Formats a prompt asking the AI to modify the given files per the user's request,
+// returning the full content of every file it changes under the same tag, so that only its changes
+// can be applied to the files on disk.
+func PreparePatchInput(userInput string, encodedFiles string, yamlOnly bool, endOfSequence string) string {
+	nouns := nounsFor(yamlOnly)
+	return fmt.Sprintf(`## This document contains instructions for modifying existing %[1]s,
+## the %[1]s as they are now, and the resultant %[1]s with the modifications applied.
+##
+## The structure of the document is as follows:
+## 1. Description of the modifications
+## 2. The existing %[1]s, each separated by a '%[2]s'
+## 3. The full content of every modified %[3]s, keeping its '# %[4]s' heading and everything which
+##    the modifications don't touch, each separated by a '%[2]s' and terminated by an '%[5]s'
+
+## 1. Instructions for modifying the existing %[1]s:
+%[6]s
+
+## 2. Existing %[1]s:
+%[7]s
+
+## 3. The modified %[1]s, in full:
+`, nouns.plural, filemap.FileDelimeter, nouns.single, filemap.FileTagPrefix+"name", endOfSequence,
+		userInput, encodedFiles)
+}
+
+// PrepareSpecInput Formats the sections of the given spec as a single prompt,
+// asking the AI to generate every file in one pass. Each generated file is expected
+// to be tagged with the path of its section so that it can be decoded back out.
+func PrepareSpecInput(userInput string, s *spec.Spec, encodedFiles, endOfSequence string) string {
+	withFiles := strings.TrimSpace(encodedFiles) != ""
+	numInstructions := 1
+
+	// preamble
+	prompt := `## This document contains instructions for several new Kubernetes YAMLs that need to be created,`
+	if withFiles {
+		prompt += `
+## along with the relevant YAMLs for context, and the resultant YAMLs.`
+	} else {
+		prompt += `
+## and the resultant YAMLs.`
+	}
+
+	// instructions
+	prompt += fmt.Sprintf(`
+##
+## The structure of the document is as follows:
+## %d. Description of each desired YAML, one section per file`, numInstructions)
+	numInstructions++
+	if withFiles {
+		prompt += fmt.Sprintf(`
+## %d. The existing YAMLs, each separated by a '%s'`, numInstructions, filemap.FileDelimeter)
+		numInstructions++
+	}
+	prompt += fmt.Sprintf(`
+## %d. The new YAMLs, each starting with '# %s<path>' and separated by a '%s', terminated by an '%s'
+`, numInstructions, filemap.FileTagPrefix, filemap.FileDelimeter, endOfSequence)
+
+	// call to action
+	numInstructions = 1
+	prompt += fmt.Sprintf(`
+## %d. Instructions for the new Kubernetes YAMLs:
+`, numInstructions)
+	numInstructions++
+	if s.Summary != "" {
+		prompt += s.Summary + "\n"
+	}
+	if strings.TrimSpace(userInput) != "" {
+		prompt += userInput + "\n"
+	}
+	for _, section := range s.Sections {
+		prompt += fmt.Sprintf("### %s%s\n%s\n", filemap.FileTagPrefix, section.Path, section.Description)
+	}
+
+	if withFiles {
+		prompt += fmt.Sprintf(`
 ## %d. Existing YAMLs:
 %s
 `, numInstructions, encodedFiles)
 		numInstructions++
 	}
 
-	// add the completion sequence
 	prompt += fmt.Sprintf(`
-## %d. The new YAML:
+## %d. The new YAMLs:
 `, numInstructions)
 	return prompt
 }
 
+// assignSpecPaths Sets the path of each decoded file whose tag matches a
+// section of the spec, so that it is written out to the requested location.
+func assignSpecPaths(fm *filemap.Filemap, s *spec.Spec) {
+	for _, section := range s.Sections {
+		file, ok := fm.Files[section.Path]
+		if !ok {
+			logger.Warnf("spec section %q was not generated\n", section.Path)
+			continue
+		}
+		if file.Path == "" {
+			file.Path = section.Path
+			file.Name = path.Base(section.Path)
+			fm.Files[section.Path] = file
+		}
+	}
+}
+
 // generateNewFiles Creates a new file for every requested completion,
-// and stores them in the "generated-by-copilot-ops" directory.
-func generateNewFiles(sepOutput []string) map[string]filemap.File {
+// and stores them in the given directory. The files are named with the name template when one is given,
+// or after the resource in the completion when it holds a single one, falling back to DefaultGeneratedName.
+func generateNewFiles(sepOutput []string, dir, nameTemplate, endOfSequence string) map[string]filemap.File {
 	newMap := make(map[string]filemap.File)
 	for i, output := range sepOutput {
 		// set file name + path here
-		newFileName := "generated-by-copilot-ops" + fmt.Sprint(i+1) + ".yaml"
-		newFilePath := path.Join("generated-by-copilot-ops", newFileName)
+		newFileName := GeneratedFileName(output, nameTemplate, endOfSequence, i+1)
+		newFilePath := path.Join(dir, newFileName)
+		if _, taken := newMap[newFilePath]; taken {
+			newFileName = numberedName(newFileName, i+1)
+			newFilePath = path.Join(dir, newFileName)
+		}
 
 		// populate file contents
 		var newFile filemap.File
@@ -263,3 +1406,22 @@ func generateNewFiles(sepOutput []string) map[string]filemap.File {
 	}
 	return newMap
 }
+
+// GeneratedFileName Returns the name of the file made from the nth completion, which couldn't be decoded.
+// Without a name template, the file is named after the resource in the completion when it can be parsed.
+func GeneratedFileName(output, nameTemplate, endOfSequence string, n int) string {
+	if nameTemplate == "" {
+		content, _ := filemap.TrimEndOfSequence(output, endOfSequence)
+		if name, ok := filemap.ResourceFileName(content); ok {
+			return name
+		}
+		nameTemplate = DefaultGeneratedName
+	}
+	return strings.ReplaceAll(nameTemplate, GeneratedNameCounter, strconv.Itoa(n))
+}
+
+// numberedName Returns the name with the number added before its extension, e.g. 'pod-web-2.yaml'.
+func numberedName(name string, n int) string {
+	ext := path.Ext(name)
+	return fmt.Sprintf("%s-%d%s", strings.TrimSuffix(name, ext), n, ext)
+}
@@ -1,16 +1,32 @@
 package cmd_test
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
 	"log"
+	"net/http"
 	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync/atomic"
+	"time"
 
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
 	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
 
 	"github.com/redhat-et/copilot-ops/pkg/ai"
+	"github.com/redhat-et/copilot-ops/pkg/ai/claude"
 	"github.com/redhat-et/copilot-ops/pkg/ai/gpt3"
 	"github.com/redhat-et/copilot-ops/pkg/cmd"
+	"github.com/redhat-et/copilot-ops/pkg/cmd/config"
+	"github.com/redhat-et/copilot-ops/pkg/filemap"
+	"github.com/redhat-et/copilot-ops/pkg/spec"
 )
 
 var _ = Describe("Generate command", func() {
@@ -37,6 +53,8 @@ var _ = Describe("Generate command", func() {
 			Expect(err).To(BeNil())
 			err = c.Flags().Set(cmd.FlagAIBackendFull, string(ai.GPT3))
 			Expect(err).To(BeNil())
+			// don't record the test's generations in the history of the repo
+			Expect(c.Flags().Set(cmd.FlagNoHistoryFull, "true")).To(Succeed())
 		})
 		AfterEach(func() {
 			ts.Close()
@@ -50,6 +68,307 @@ var _ = Describe("Generate command", func() {
 		// TODO: add more tests for expected success
 	})
 
+	When("only the prompt is requested", func() {
+		var out *bytes.Buffer
+		BeforeEach(func() {
+			out = &bytes.Buffer{}
+			c.SetOut(out)
+			Expect(c.Flags().Set(cmd.FlagRequestFull, "Create a Pod running nginx")).To(Succeed())
+			// a backend which no client can be created for
+			Expect(c.Flags().Set(cmd.FlagAIBackendFull, "unknown")).To(Succeed())
+		})
+
+		It("fails to create the client without --prompt-only", func() {
+			Expect(cmd.RunGenerate(c, []string{})).To(MatchError(ContainSubstring("could not create client")))
+		})
+
+		It("prints the prompt before creating the client", func() {
+			Expect(c.Flags().Set(cmd.FlagPromptOnlyFull, "true")).To(Succeed())
+			Expect(cmd.RunGenerate(c, []string{})).To(Succeed())
+			Expect(out.String()).To(ContainSubstring("## 1. Instructions for the new Kubernetes YAML:\nCreate a Pod running nginx\n"))
+		})
+	})
+
+	When("only the tokens are counted", func() {
+		var out *bytes.Buffer
+		BeforeEach(func() {
+			out = &bytes.Buffer{}
+			c.SetOut(out)
+			Expect(c.Flags().Set(cmd.FlagRequestFull, "Create a Pod running nginx")).To(Succeed())
+			Expect(c.Flags().Set(cmd.FlagCountOnlyFull, "true")).To(Succeed())
+			Expect(c.Flags().Set(cmd.FlagNoHistoryFull, "true")).To(Succeed())
+		})
+
+		It("doesn't create a client", func() {
+			// a backend which no client can be created for
+			Expect(c.Flags().Set(cmd.FlagAIBackendFull, "unknown")).To(Succeed())
+			Expect(cmd.RunGenerate(c, []string{})).To(Succeed())
+			Expect(out.String()).To(MatchRegexp(`^prompt: ~\d+ tokens\n`))
+			Expect(out.String()).To(ContainSubstring("cost: unknown"))
+		})
+
+		It("estimates the cost without calling the backend", func() {
+			var requests int32
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				atomic.AddInt32(&requests, 1)
+				http.Error(w, "unexpected request", http.StatusInternalServerError)
+			}))
+			DeferCleanup(server.Close)
+			Expect(c.Flags().Set(cmd.FlagAIBackendFull, string(ai.GPT3))).To(Succeed())
+			Expect(c.Flags().Set(cmd.FlagOpenAIURLFull, server.URL)).To(Succeed())
+			Expect(c.Flags().Set(cmd.FlagModelFull, "gpt-4o")).To(Succeed())
+			Expect(c.Flags().Set(cmd.FlagNTokensFull, "1000")).To(Succeed())
+			Expect(c.Flags().Set(cmd.FlagNCompletionsFull, "2")).To(Succeed())
+
+			Expect(cmd.RunGenerate(c, []string{})).To(Succeed())
+			Expect(requests).To(BeZero())
+			Expect(out.String()).To(ContainSubstring("completions: up to 2000 tokens (2 x --ntokens 1000)\n"))
+			Expect(out.String()).To(MatchRegexp(`cost: up to ~\$0\.0\d+ with gpt-4o\n`))
+		})
+
+		It("can't be combined with writing", func() {
+			Expect(c.Flags().Set(cmd.FlagWriteFull, "true")).To(Succeed())
+			Expect(cmd.ValidateFlags(c, []string{})).To(MatchError(ContainSubstring(
+				"--count-only and --write cannot be used together",
+			)))
+		})
+	})
+
+	When("the request is read from a file", func() {
+		var requestFile string
+		BeforeEach(func() {
+			requestFile = GinkgoT().TempDir() + "/request.txt"
+			c.SetOut(&bytes.Buffer{})
+			Expect(c.Flags().Set(cmd.FlagAIBackendFull, string(ai.GPT3))).To(Succeed())
+			Expect(c.Flags().Set(cmd.FlagPromptOnlyFull, "true")).To(Succeed())
+			Expect(c.Flags().Set(cmd.FlagRequestFileFull, requestFile)).To(Succeed())
+		})
+
+		It("uses the contents of the file as the request", func() {
+			Expect(os.WriteFile(requestFile, []byte("\nCreate a Pod running nginx\n\n"), 0600)).To(Succeed())
+			r, err := cmd.PrepareRequest(c)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(r.UserRequest).To(Equal("Create a Pod running nginx"))
+		})
+
+		It("fails when the file is empty", func() {
+			Expect(os.WriteFile(requestFile, []byte(" \n"), 0600)).To(Succeed())
+			_, err := cmd.PrepareRequest(c)
+			Expect(err).To(MatchError(ContainSubstring("is empty")))
+		})
+
+		It("fails when the file is missing", func() {
+			_, err := cmd.PrepareRequest(c)
+			Expect(err).To(MatchError(ContainSubstring("could not read the request from --" + cmd.FlagRequestFileFull)))
+		})
+
+		It("can't be combined with --request", func() {
+			Expect(c.Flags().Set(cmd.FlagRequestFull, "Create a Service")).To(Succeed())
+			Expect(cmd.ValidateFlags(c, []string{})).To(MatchError(ContainSubstring(
+				"--request and --request-file cannot be used together",
+			)))
+		})
+	})
+
+	When("a file is piped through stdin", func() {
+		BeforeEach(func() {
+			wd, err := os.Getwd()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(os.Chdir(GinkgoT().TempDir())).To(Succeed())
+			DeferCleanup(os.Chdir, wd)
+			Expect(c.Flags().Set(cmd.FlagRequestFull, "Add a readiness probe")).To(Succeed())
+			Expect(c.Flags().Set(cmd.FlagAIBackendFull, string(ai.GPT3))).To(Succeed())
+			Expect(c.Flags().Set(cmd.FlagStdinFileFull, "drafts/draft.yaml")).To(Succeed())
+		})
+
+		It("includes it in the context under the given path", func() {
+			c.SetIn(strings.NewReader("kind: Deployment\nmetadata:\n  name: web\n"))
+			r, err := cmd.PrepareRequest(c)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(r.Filemap.Files).To(HaveKeyWithValue("draft.yaml", HaveField("Path", "drafts/draft.yaml")))
+			Expect(r.FilemapText).To(ContainSubstring(
+				"# " + filemap.FileTagPrefix + "draft.yaml\nkind: Deployment\nmetadata:\n  name: web\n",
+			))
+			// the file doesn't have to exist on disk
+			Expect("drafts/draft.yaml").NotTo(BeAnExistingFile())
+		})
+
+		It("rejects content which isn't text", func() {
+			c.SetIn(bytes.NewReader([]byte{0x00, 0x01}))
+			_, err := cmd.PrepareRequest(c)
+			Expect(err).To(MatchError(ContainSubstring("drafts/draft.yaml isn't a text file")))
+		})
+
+		It("can't be combined with reading the request from stdin", func() {
+			Expect(c.Flags().Set(cmd.FlagRequestFull, cmd.StdinRequest)).To(Succeed())
+			Expect(cmd.ValidateFlags(c, []string{})).To(MatchError(ContainSubstring("both would read stdin")))
+		})
+	})
+
+	When("files are given as URLs", func() {
+		var server *httptest.Server
+		BeforeEach(func() {
+			wd, err := os.Getwd()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(os.Chdir(GinkgoT().TempDir())).To(Succeed())
+			DeferCleanup(os.Chdir, wd)
+			// a stub server which only serves the manifest to authorized requests
+			server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != "/platform/manifests/main/nginx.yaml" || r.Header.Get("Authorization") != "token abc" {
+					http.NotFound(w, r)
+					return
+				}
+				_, _ = w.Write([]byte("kind: Deployment\nmetadata:\n  name: nginx\n"))
+			}))
+			DeferCleanup(server.Close)
+
+			Expect(c.Flags().Set(cmd.FlagRequestFull, "Create a Service for nginx")).To(Succeed())
+			Expect(c.Flags().Set(cmd.FlagAIBackendFull, string(ai.GPT3))).To(Succeed())
+			Expect(c.Flags().Set(cmd.FlagFilesFull, server.URL+"/platform/manifests/main/nginx.yaml")).To(Succeed())
+		})
+
+		It("fetches them into the context", func() {
+			Expect(c.Flags().Set(cmd.FlagFileHeaderFull, "Authorization: token abc")).To(Succeed())
+			r, err := cmd.PrepareRequest(c)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(r.Filemap.Files).To(HaveKey("nginx.yaml"))
+			Expect(r.Filemap.Files["nginx.yaml"].URL).To(Equal(server.URL + "/platform/manifests/main/nginx.yaml"))
+			Expect(r.FilemapText).To(ContainSubstring(
+				"# " + filemap.FileTagPrefix + "nginx.yaml\nkind: Deployment\nmetadata:\n  name: nginx\n",
+			))
+
+			// the fetched file is never written back
+			r.IsWrite = true
+			Expect(cmd.PrintOrWriteOut(context.Background(), r)).To(Succeed())
+			Expect(r.Filemap.Files).To(BeEmpty())
+			Expect(filepath.Glob("127.0.0.1*")).To(BeEmpty())
+			Expect("nginx.yaml").NotTo(BeAnExistingFile())
+		})
+
+		It("fails when they can't be fetched", func() {
+			_, err := cmd.PrepareRequest(c)
+			Expect(err).To(MatchError(ContainSubstring("status code: 404")))
+		})
+
+		It("rejects a malformed header", func() {
+			Expect(c.Flags().Set(cmd.FlagFileHeaderFull, "Authorization=token")).To(Succeed())
+			_, err := cmd.PrepareRequest(c)
+			Expect(err).To(MatchError("invalid --" + cmd.FlagFileHeaderFull + " \"Authorization=token\", expected 'Name: value'"))
+		})
+	})
+
+	When("no files are included as context", func() {
+		var out *bytes.Buffer
+		BeforeEach(func() {
+			wd, err := os.Getwd()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(os.Chdir(GinkgoT().TempDir())).To(Succeed())
+			DeferCleanup(os.Chdir, wd)
+			Expect(os.WriteFile("pod.yaml", []byte("kind: Pod\nmetadata:\n  name: existing\n"), 0600)).To(Succeed())
+
+			out = &bytes.Buffer{}
+			c.SetOut(out)
+			Expect(c.Flags().Set(cmd.FlagRequestFull, "Create a Pod running nginx")).To(Succeed())
+			Expect(c.Flags().Set(cmd.FlagAIBackendFull, string(ai.GPT3))).To(Succeed())
+			Expect(c.Flags().Set(cmd.FlagPromptOnlyFull, "true")).To(Succeed())
+			Expect(c.Flags().Set(cmd.FlagNoContextFull, "true")).To(Succeed())
+		})
+
+		It("doesn't pack any files", func() {
+			r, err := cmd.PrepareRequest(c)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(r.Filemap.Files).To(BeEmpty())
+			Expect(r.FilemapText).To(BeEmpty())
+		})
+
+		It("prompts without the existing files", func() {
+			Expect(cmd.RunGenerate(c, []string{})).To(Succeed())
+			Expect(out.String()).NotTo(ContainSubstring("Existing"))
+			Expect(out.String()).NotTo(ContainSubstring("name: existing"))
+			Expect(out.String()).To(ContainSubstring(
+				"## 1. Instructions for the new Kubernetes YAML:\nCreate a Pod running nginx\n",
+			))
+		})
+
+		It("can't be combined with --file", func() {
+			Expect(c.Flags().Set(cmd.FlagFilesFull, "pod.yaml")).To(Succeed())
+			Expect(cmd.ValidateFlags(c, []string{})).To(MatchError(ContainSubstring(
+				"no files are included with --" + cmd.FlagNoContextFull,
+			)))
+		})
+	})
+
+	When("the files and filesets overlap", func() {
+		BeforeEach(func() {
+			wd, err := os.Getwd()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(os.Chdir(GinkgoT().TempDir())).To(Succeed())
+			DeferCleanup(os.Chdir, wd)
+			viper.Reset()
+			DeferCleanup(viper.Reset)
+			Expect(os.MkdirAll("manifests", 0755)).To(Succeed())
+			for _, name := range []string{"service.yaml", "pod.yaml", "deployment.yaml"} {
+				Expect(os.WriteFile(filepath.Join("manifests", name), []byte("kind: "+name+"\n"), 0600)).To(Succeed())
+			}
+			Expect(os.WriteFile(config.ConfigFile, []byte(
+				"filesets:\n- name: manifests\n  files: [manifests/*.yaml]\n",
+			), 0600)).To(Succeed())
+
+			Expect(c.Flags().Set(cmd.FlagRequestFull, "Create a Pod running nginx")).To(Succeed())
+			Expect(c.Flags().Set(cmd.FlagAIBackendFull, string(ai.GPT3))).To(Succeed())
+			Expect(c.Flags().Set(cmd.FlagFilesFull, "manifests/pod.yaml")).To(Succeed())
+			Expect(c.Flags().Set(cmd.FlagFilesFull, "./manifests/service.yaml")).To(Succeed())
+			Expect(c.Flags().Set(cmd.FlagFilesetsFull, "manifests")).To(Succeed())
+		})
+
+		It("packs every file once, in order of their paths", func() {
+			r, err := cmd.PrepareRequest(c)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(r.Filemap.Files).To(HaveLen(3))
+			Expect(r.FilemapText).To(MatchRegexp(
+				`(?s)^# @deployment\.yaml\n.*# @pod\.yaml\n.*# @service\.yaml\n`,
+			))
+			Expect(strings.Count(r.FilemapText, "# @")).To(Equal(3))
+		})
+	})
+
+	When("binary or oversized files are matched", func() {
+		BeforeEach(func() {
+			wd, err := os.Getwd()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(os.Chdir(GinkgoT().TempDir())).To(Succeed())
+			DeferCleanup(os.Chdir, wd)
+			Expect(os.WriteFile("pod.yaml", []byte("kind: Pod\n"), 0600)).To(Succeed())
+			Expect(os.WriteFile("chart.tgz", []byte{0x1f, 0x8b, 0x08, 0x00}, 0600)).To(Succeed())
+			Expect(os.WriteFile("crds.yaml", []byte(strings.Repeat("kind: CustomResourceDefinition\n", 100)),
+				0600)).To(Succeed())
+
+			Expect(c.Flags().Set(cmd.FlagRequestFull, "Create a Pod running nginx")).To(Succeed())
+			Expect(c.Flags().Set(cmd.FlagAIBackendFull, string(ai.GPT3))).To(Succeed())
+			Expect(c.Flags().Set(cmd.FlagFilesFull, "*")).To(Succeed())
+		})
+
+		It("packs only the text files within --max-file-size", func() {
+			Expect(c.Flags().Set(cmd.FlagMaxFileSizeFull, "1024")).To(Succeed())
+			r, err := cmd.PrepareRequest(c)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(r.Filemap.Files).To(HaveLen(1))
+			Expect(r.Filemap.Files).To(HaveKey("pod.yaml"))
+		})
+
+		It("rejects a negative limit", func() {
+			Expect(c.Flags().Set(cmd.FlagMaxFileSizeFull, "-1")).To(Succeed())
+			Expect(cmd.ValidateFlags(c, []string{})).To(MatchError(ContainSubstring(
+				"--" + cmd.FlagMaxFileSizeFull + " must not be negative",
+			)))
+		})
+	})
+
+	It("redacts secrets from printed prompts", func() {
+		conf := config.Config{OpenAI: &gpt3.Config{APIKey: "sk-secret"}}
+		Expect(cmd.RedactSecrets("key: sk-secret\n", conf.Secrets())).To(Equal("key: " + cmd.RedactedSecret + "\n"))
+	})
+
 	When("OpenAI server is down", func() {
 		BeforeEach(func() {
 			// set a port that isn't taken
@@ -62,4 +381,979 @@ var _ = Describe("Generate command", func() {
 		})
 		// TODO: add more cases that should fail
 	})
+
+	When("a spec is provided", func() {
+		It("requests every section in the prompt", func() {
+			s := &spec.Spec{
+				Summary: "An nginx web server.",
+				Sections: []spec.Section{
+					{Path: "app/deployment.yaml", Description: "A Deployment running nginx."},
+					{Path: "app/service.yaml", Description: "A Service exposing nginx."},
+				},
+			}
+			prompt := cmd.PrepareSpecInput("", s, "", gpt3.CompletionEndOfSequence)
+			Expect(prompt).To(ContainSubstring("An nginx web server."))
+			Expect(prompt).To(ContainSubstring(filemap.FileTagPrefix + "app/deployment.yaml"))
+			Expect(prompt).To(ContainSubstring(filemap.FileTagPrefix + "app/service.yaml"))
+			Expect(prompt).NotTo(ContainSubstring("Existing YAMLs"))
+		})
+	})
+
+	When("multiple completions are generated", func() {
+		choices := []string{"first", "second", "third"}
+
+		It("uses the selected completion", func() {
+			selected, err := cmd.SelectCompletions(choices, 2, cmd.CompletionStrategyAll, true)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(selected).To(Equal([]string{"second"}))
+
+			_, err = cmd.SelectCompletions(choices, 4, cmd.CompletionStrategyAll, false)
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("refuses to write when it is ambiguous", func() {
+			_, err := cmd.SelectCompletions(choices, 0, cmd.CompletionStrategyAll, true)
+			Expect(err).To(HaveOccurred())
+
+			selected, err := cmd.SelectCompletions(choices, 0, cmd.CompletionStrategyAll, false)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(selected).To(HaveLen(3))
+		})
+
+		It("follows the completion strategy", func() {
+			selected, err := cmd.SelectCompletions(choices, 0, cmd.CompletionStrategyFirst, true)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(selected).To(Equal([]string{"first"}))
+
+			selected, err = cmd.SelectCompletions(choices, 0, cmd.CompletionStrategyMerge, true)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(selected).To(HaveLen(3))
+
+			_, err = cmd.SelectCompletions(choices, 0, "unknown", false)
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	When("completions are filtered", func() {
+		choices := []string{
+			"# @deployment.yaml\nimage: nginx:latest\n",
+			"# @deployment.yaml\nimage: nginx:1.23\n",
+		}
+
+		It("keeps matching completions", func() {
+			kept, err := cmd.FilterCompletions(choices, regexp.MustCompile(`nginx:1\.`), nil, gpt3.CompletionEndOfSequence)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(kept).To(Equal(choices[1:]))
+		})
+
+		It("discards rejected completions", func() {
+			kept, err := cmd.FilterCompletions(choices, nil, regexp.MustCompile(`:latest`), gpt3.CompletionEndOfSequence)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(kept).To(Equal(choices[1:]))
+		})
+
+		It("matches against the decoded content", func() {
+			_, err := cmd.FilterCompletions(choices, nil, regexp.MustCompile(`@deployment`), gpt3.CompletionEndOfSequence)
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("fails when nothing is left", func() {
+			_, err := cmd.FilterCompletions(choices, regexp.MustCompile(`redis`), nil, gpt3.CompletionEndOfSequence)
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	When("generated files are validated", func() {
+		var r *cmd.Request
+		BeforeEach(func() {
+			r = &cmd.Request{Filemap: filemap.NewFilemap()}
+			r.Filemap.Files["pod.yaml"] = filemap.File{Path: "pod.yaml", Content: "apiVersion: v1\nkind: Pod\n"}
+		})
+
+		It("accepts Kubernetes objects", func() {
+			r.Validate = true
+			Expect(cmd.ValidateFiles(r)).To(Succeed())
+		})
+
+		It("only warns by default", func() {
+			r.Filemap.Files["values.yaml"] = filemap.File{Path: "values.yaml", Content: "replicas: 2\n"}
+			Expect(cmd.ValidateFiles(r)).To(Succeed())
+		})
+
+		It("reports which files are invalid with --validate", func() {
+			r.Validate = true
+			r.Filemap.Files["values.yaml"] = filemap.File{Path: "values.yaml", Content: "replicas: 2\n"}
+			r.Filemap.Files["broken.yaml"] = filemap.File{Path: "broken.yaml", Content: "kind: [Pod\n"}
+			err := cmd.ValidateFiles(r)
+			Expect(err).To(MatchError(ContainSubstring("2 generated files are invalid: broken.yaml: ")))
+			Expect(err).To(MatchError(ContainSubstring(
+				"; values.yaml: document 1 is not a Kubernetes object, missing apiVersion and kind",
+			)))
+		})
+	})
+
+	When("completions are cached", func() {
+		var r *cmd.Request
+		var client *countingClient
+		BeforeEach(func() {
+			r = &cmd.Request{Backend: ai.OLLAMA, CacheDir: GinkgoT().TempDir(), CacheTTL: time.Hour}
+			client = &countingClient{choices: []string{"# @pod.yaml\nkind: Pod\n"}}
+		})
+
+		It("doesn't call the backend for an identical request", func() {
+			first, err := cmd.CachedGenerateChoices(context.Background(), r, "create a pod", client)
+			Expect(err).NotTo(HaveOccurred())
+			second, err := cmd.CachedGenerateChoices(context.Background(), r, "create a pod", client)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(second).To(Equal(first))
+			Expect(client.calls).To(Equal(1))
+		})
+
+		It("calls the backend when anything about the request changes", func() {
+			_, err := cmd.CachedGenerateChoices(context.Background(), r, "create a pod", client)
+			Expect(err).NotTo(HaveOccurred())
+			_, err = cmd.CachedGenerateChoices(context.Background(), r, "create a service", client)
+			Expect(err).NotTo(HaveOccurred())
+			r.Temperature = 0.5
+			_, err = cmd.CachedGenerateChoices(context.Background(), r, "create a pod", client)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(client.calls).To(Equal(3))
+		})
+
+		It("calls the backend when its URL or model changes", func() {
+			r.Backend = ai.CLAUDE
+			r.Config.Claude = &claude.Config{URL: "https://api.anthropic.com/v1/messages", Model: "claude-3-haiku"}
+			_, err := cmd.CachedGenerateChoices(context.Background(), r, "create a pod", client)
+			Expect(err).NotTo(HaveOccurred())
+			r.Config.Claude.URL = "https://proxy.example.com/v1/messages"
+			_, err = cmd.CachedGenerateChoices(context.Background(), r, "create a pod", client)
+			Expect(err).NotTo(HaveOccurred())
+			r.Config.Claude.Model = "claude-3-opus"
+			_, err = cmd.CachedGenerateChoices(context.Background(), r, "create a pod", client)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(client.calls).To(Equal(3))
+		})
+
+		It("skips the cache with --no-cache", func() {
+			_, err := cmd.CachedGenerateChoices(context.Background(), r, "create a pod", client)
+			Expect(err).NotTo(HaveOccurred())
+			r.NoCache = true
+			_, err = cmd.CachedGenerateChoices(context.Background(), r, "create a pod", client)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(client.calls).To(Equal(2))
+		})
+
+		It("is disabled without a cache directory", func() {
+			r.CacheDir = ""
+			for i := 0; i < 2; i++ {
+				_, err := cmd.CachedGenerateChoices(context.Background(), r, "create a pod", client)
+				Expect(err).NotTo(HaveOccurred())
+			}
+			Expect(client.calls).To(Equal(2))
+		})
+	})
+
+	When("structured output is requested", func() {
+		var r *cmd.Request
+		BeforeEach(func() {
+			r = &cmd.Request{OutputType: filemap.OutputPlain, Structured: true, Backend: ai.GPT3}
+			r.Config.OpenAI = &gpt3.Config{Model: "gpt-4o"}
+		})
+
+		It("builds the files from the arguments of the function call", func() {
+			output := `{"files": [{"path": "@pod.yaml", "content": "kind: Pod\n===\n"},` +
+				`{"path": "app/service.yaml", "content": "kind: Service\n"}]}`
+			Expect(cmd.SupportsStructuredOutput(r)).To(BeTrue())
+			Expect(cmd.DecodeAndOutput(context.Background(), r, []string{output})).To(Succeed())
+			Expect(r.DecodeError).To(BeEmpty())
+			// the delimiter in the content isn't parsed
+			Expect(r.Filemap.Files).To(HaveKeyWithValue("pod.yaml", HaveField("Content", "kind: Pod\n===\n")))
+			Expect(r.Filemap.Files).To(HaveKeyWithValue("app/service.yaml", And(
+				HaveField("Path", "app/service.yaml"),
+				HaveField("Content", "kind: Service\n"),
+			)))
+		})
+
+		It("decodes the text when the model didn't call the function", func() {
+			output := "# @pod.yaml\nkind: Pod\n" + gpt3.CompletionEndOfSequence
+			Expect(cmd.DecodeAndOutput(context.Background(), r, []string{output})).To(Succeed())
+			Expect(r.DecodeError).To(BeEmpty())
+			Expect(r.Filemap.Files).To(HaveKeyWithValue("pod.yaml", HaveField("Content", "kind: Pod\n")))
+		})
+
+		It("is only supported by OpenAI's chat models", func() {
+			Expect(cmd.SupportsStructuredOutput(&cmd.Request{Backend: ai.GPT3})).To(BeFalse())
+			Expect(cmd.SupportsStructuredOutput(&cmd.Request{Backend: ai.CLAUDE})).To(BeFalse())
+		})
+
+		It("filters the content of the files", func() {
+			choices := []string{
+				`{"files": [{"path": "pod.yaml", "content": "image: nginx:latest"}]}`,
+				`{"files": [{"path": "pod.yaml", "content": "image: nginx:1.25"}]}`,
+			}
+			kept, err := cmd.FilterCompletions(choices, nil, regexp.MustCompile(`:latest$`), gpt3.CompletionEndOfSequence)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(kept).To(Equal(choices[1:]))
+		})
+	})
+
+	When("an output directory is given", func() {
+		var r *cmd.Request
+		BeforeEach(func() {
+			r = &cmd.Request{OutputType: filemap.OutputPlain, OutputDir: "manifests/generated"}
+		})
+
+		It("places the decoded files in it", func() {
+			output := "# @pod.yaml\nkind: Pod\n===\n# @app/service.yaml\nkind: Service\n"
+			Expect(cmd.DecodeAndOutput(context.Background(), r, []string{output})).To(Succeed())
+			Expect(r.Filemap.Files["pod.yaml"].Path).To(Equal("manifests/generated/pod.yaml"))
+			Expect(r.Filemap.Files["app/service.yaml"].Path).To(Equal("manifests/generated/app/service.yaml"))
+		})
+
+		It("places the output which can't be decoded in it", func() {
+			Expect(cmd.DecodeAndOutput(context.Background(), r, []string{"kind: Pod\n"})).To(Succeed())
+			Expect(r.Filemap.Files).To(HaveKey("manifests/generated/generated-by-copilot-ops1.yaml"))
+		})
+
+		It("names the output after the resource in it", func() {
+			pod := "apiVersion: v1\nkind: Pod\nmetadata:\n  name: web\n" + gpt3.CompletionEndOfSequence
+			Expect(cmd.DecodeAndOutput(context.Background(), r, []string{pod, pod})).To(Succeed())
+			Expect(r.Filemap.Files).To(HaveKey("manifests/generated/pod-web.yaml"))
+			Expect(r.Filemap.Files).To(HaveKey("manifests/generated/pod-web-2.yaml"))
+		})
+
+		It("names the output with the --generated-name template", func() {
+			r.GeneratedName = "new-resource-" + cmd.GeneratedNameCounter + ".yaml"
+			pod := "apiVersion: v1\nkind: Pod\nmetadata:\n  name: web\n"
+			Expect(cmd.DecodeAndOutput(context.Background(), r, []string{pod, "kind: Service\n"})).To(Succeed())
+			Expect(r.Filemap.Files).To(HaveKey("manifests/generated/new-resource-1.yaml"))
+			Expect(r.Filemap.Files).To(HaveKey("manifests/generated/new-resource-2.yaml"))
+		})
+
+		It("rejects a --generated-name outside of the output directory", func() {
+			Expect(c.Flags().Set(cmd.FlagGeneratedNameFull, "../pod.yaml")).To(Succeed())
+			Expect(cmd.ValidateFlags(c, []string{})).To(MatchError(ContainSubstring(
+				"--" + cmd.FlagGeneratedNameFull + " must be a relative path",
+			)))
+		})
+
+		It("defaults to the previous locations", func() {
+			r.OutputDir = ""
+			Expect(cmd.DecodeAndOutput(context.Background(), r, []string{"kind: Pod\n"})).To(Succeed())
+			Expect(r.Filemap.Files).To(HaveKey(cmd.DefaultOutputDir + "/generated-by-copilot-ops1.yaml"))
+			Expect(cmd.DecodeAndOutput(context.Background(), r, []string{"# @pod.yaml\nkind: Pod\n"})).To(Succeed())
+			Expect(r.Filemap.Files["pod.yaml"].Path).To(BeEmpty())
+		})
+	})
+
+	When("a namespace is given", func() {
+		It("sets it on the namespaced resources before they are grouped", func() {
+			r := &cmd.Request{OutputType: filemap.OutputPlain, Namespace: "shop", PerNamespaceDirs: true}
+			output := "# @deployment.yaml\nkind: Deployment\nmetadata:\n  name: web\n" +
+				"===\n# @role.yaml\nkind: ClusterRole\nmetadata:\n  name: reader\n"
+			Expect(cmd.DecodeAndOutput(context.Background(), r, []string{output})).To(Succeed())
+			Expect(r.Filemap.Files["deployment.yaml"].Content).To(Equal("kind: Deployment\nmetadata:\n  namespace: shop\n  name: web\n"))
+			Expect(r.Filemap.Files["deployment.yaml"].Path).To(Equal("shop/deployment.yaml"))
+			Expect(r.Filemap.Files["role.yaml"].Content).To(Equal("kind: ClusterRole\nmetadata:\n  name: reader\n"))
+		})
+	})
+
+	When("labels and annotations are given", func() {
+		It("adds them to every resource", func() {
+			c := cmd.NewGenerateCmd()
+			Expect(c.Flags().Set(cmd.FlagLabelFull, "app.kubernetes.io/managed-by=copilot-ops")).To(Succeed())
+			Expect(c.Flags().Set(cmd.FlagAnnotationFull, "owner=team-web")).To(Succeed())
+			Expect(c.Flags().Set(cmd.FlagRequestFull, "Create a Pod")).To(Succeed())
+			r, err := cmd.PrepareRequest(c)
+			Expect(err).NotTo(HaveOccurred())
+			r.OutputType = filemap.OutputPlain
+
+			Expect(cmd.DecodeAndOutput(context.Background(), r, []string{"# @pod.yaml\nkind: Pod\nmetadata:\n  name: web\n"})).To(Succeed())
+			Expect(r.Filemap.Files["pod.yaml"].Content).To(Equal("kind: Pod\nmetadata:\n  name: web\n" +
+				"  labels:\n    app.kubernetes.io/managed-by: copilot-ops\n  annotations:\n    owner: team-web\n"))
+		})
+
+		It("rejects pairs without a value", func() {
+			_, err := cmd.ParseKeyValues(cmd.FlagLabelFull, []string{"tier=front", "managed-by"})
+			Expect(err).To(MatchError(`invalid --label "managed-by", expected key=value`))
+		})
+	})
+
+	When("the files are formatted", func() {
+		It("re-indents the decoded files before they are combined", func() {
+			wd, err := os.Getwd()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(os.Chdir(GinkgoT().TempDir())).To(Succeed())
+			DeferCleanup(os.Chdir, wd)
+
+			r := &cmd.Request{IsWrite: true, Format: true, Combine: "manifests.yaml"}
+			output := "# @pod.yaml\nkind:   Pod\nmetadata:\n    name: 'web'\n"
+			Expect(cmd.DecodeAndOutput(context.Background(), r, []string{output})).To(Succeed())
+			content, err := os.ReadFile("manifests.yaml")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(content)).To(Equal("kind: Pod\nmetadata:\n  name: web\n"))
+		})
+	})
+
+	When("the files are combined", func() {
+		It("writes a single multi-document file", func() {
+			wd, err := os.Getwd()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(os.Chdir(GinkgoT().TempDir())).To(Succeed())
+			DeferCleanup(os.Chdir, wd)
+
+			r := &cmd.Request{IsWrite: true, Combine: "manifests.yaml"}
+			output := "# @service.yaml\nkind: Service\n===\n# @app/deployment.yaml\nkind: Deployment\n"
+			Expect(cmd.DecodeAndOutput(context.Background(), r, []string{output})).To(Succeed())
+			content, err := os.ReadFile("manifests.yaml")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(content)).To(Equal("kind: Deployment\n---\nkind: Service\n"))
+			Expect("service.yaml").NotTo(BeAnExistingFile())
+			Expect("app").NotTo(BeADirectory())
+		})
+
+		It("can't be combined with --output-dir", func() {
+			Expect(c.Flags().Set(cmd.FlagCombineFull, "manifests.yaml")).To(Succeed())
+			Expect(c.Flags().Set(cmd.FlagOutputDirFull, "generated")).To(Succeed())
+			Expect(cmd.ValidateFlags(c, []string{})).To(MatchError(ContainSubstring(
+				"--combine and --output-dir cannot be used together",
+			)))
+		})
+	})
+
+	When("the files are committed to a branch", func() {
+		// runGit Runs git in the current directory.
+		runGit := func(args ...string) string {
+			out, err := exec.Command("git", args...).CombinedOutput()
+			Expect(err).NotTo(HaveOccurred(), string(out))
+			return strings.TrimSpace(string(out))
+		}
+
+		BeforeEach(func() {
+			wd, err := os.Getwd()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(os.Chdir(GinkgoT().TempDir())).To(Succeed())
+			DeferCleanup(os.Chdir, wd)
+
+			runGit("init", "--quiet")
+			runGit("config", "user.name", "Test")
+			runGit("config", "user.email", "test@example.com")
+			runGit("config", "commit.gpgsign", "false")
+			runGit("commit", "--quiet", "--allow-empty", "-m", "Initial commit")
+		})
+
+		It("commits the written files", func() {
+			r := &cmd.Request{IsWrite: true, GitBranch: "generated", UserRequest: "Create a pod", Filemap: filemap.NewFilemap()}
+			r.Filemap.Files["pod"] = filemap.File{Path: "app/pod.yaml", Content: "kind: Pod\n"}
+			Expect(r.Filemap.WriteUpdatesToFiles()).To(Succeed())
+
+			Expect(cmd.CommitToBranch(r)).To(Succeed())
+			Expect(runGit("rev-parse", "--abbrev-ref", "HEAD")).To(Equal("generated"))
+			Expect(runGit("show", "--name-only", "--format=%s")).To(Equal("Create a pod\n\napp/pod.yaml"))
+		})
+
+		It("keeps the written files when committing fails", func() {
+			r := &cmd.Request{IsWrite: true, GitBranch: "bad branch", Filemap: filemap.NewFilemap()}
+			r.Filemap.Files["pod"] = filemap.File{Path: "pod.yaml", Content: "kind: Pod\n"}
+			Expect(r.Filemap.WriteUpdatesToFiles()).To(Succeed())
+
+			err := cmd.CommitToBranch(r)
+			Expect(err).To(MatchError(HavePrefix(`the files were written, but could not be committed to branch "bad branch"`)))
+			Expect("pod.yaml").To(BeAnExistingFile())
+		})
+	})
+
+	When("the OPT backend is selected", func() {
+		It("creates a client", func() {
+			r := &cmd.Request{Backend: ai.OPT}
+			r.Config.SetDefaults()
+			client, err := cmd.PrepareGenerateClient(r, "hello world")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(client).NotTo(BeNil())
+		})
+
+		It("requires a config", func() {
+			_, err := cmd.PrepareGenerateClient(&cmd.Request{Backend: ai.OPT}, "hello world")
+			Expect(err).To(MatchError("no config provided for opt"))
+		})
+
+		It("makes a request for every completion", func() {
+			var requests int32
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				atomic.AddInt32(&requests, 1)
+				_, _ = w.Write([]byte(`{"choices": [{"text": "kind: Pod"}]}`))
+			}))
+			DeferCleanup(server.Close)
+
+			r := &cmd.Request{Backend: ai.OPT, NCompletions: 3, Concurrency: 2}
+			r.Config.SetDefaults()
+			r.Config.OPT.URL = server.URL
+			client, err := cmd.PrepareGenerateClient(r, "hello world")
+			Expect(err).NotTo(HaveOccurred())
+			choices, err := client.Generate(context.Background())
+			Expect(err).NotTo(HaveOccurred())
+			Expect(choices).To(HaveLen(3))
+			Expect(atomic.LoadInt32(&requests)).To(BeEquivalentTo(3))
+		})
+	})
+	When("the Claude backend is selected", func() {
+		It("creates a client", func() {
+			r := &cmd.Request{Backend: ai.CLAUDE}
+			r.Config.SetDefaults()
+			client, err := cmd.PrepareGenerateClient(r, "hello world")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(client).NotTo(BeNil())
+		})
+
+		It("requires a config", func() {
+			_, err := cmd.PrepareGenerateClient(&cmd.Request{Backend: ai.CLAUDE}, "hello world")
+			Expect(err).To(MatchError("no config provided for claude"))
+		})
+	})
+
+	When("the Cohere backend is selected", func() {
+		It("creates a client", func() {
+			r := &cmd.Request{Backend: ai.COHERE}
+			r.Config.SetDefaults()
+			client, err := cmd.PrepareGenerateClient(r, "hello world")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(client).NotTo(BeNil())
+		})
+
+		It("requires a config", func() {
+			_, err := cmd.PrepareGenerateClient(&cmd.Request{Backend: ai.COHERE}, "hello world")
+			Expect(err).To(MatchError("no config provided for cohere"))
+		})
+	})
+
+	When("the Gemini backend is selected", func() {
+		It("creates a client", func() {
+			r := &cmd.Request{Backend: ai.GEMINI}
+			r.Config.SetDefaults()
+			client, err := cmd.PrepareGenerateClient(r, "hello world")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(client).NotTo(BeNil())
+		})
+
+		It("requires a config", func() {
+			_, err := cmd.PrepareGenerateClient(&cmd.Request{Backend: ai.GEMINI}, "hello world")
+			Expect(err).To(MatchError("no config provided for gemini"))
+		})
+	})
+
+	When("the Ollama backend is selected", func() {
+		It("creates a client", func() {
+			r := &cmd.Request{Backend: ai.OLLAMA}
+			r.Config.SetDefaults()
+			client, err := cmd.PrepareGenerateClient(r, "hello world")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(client).NotTo(BeNil())
+		})
+
+		It("requires a config", func() {
+			_, err := cmd.PrepareGenerateClient(&cmd.Request{Backend: ai.OLLAMA}, "hello world")
+			Expect(err).To(MatchError("no config provided for ollama"))
+		})
+	})
+
+	When("sampling parameters are set", func() {
+		var backend *httptest.Server
+		var received map[string]interface{}
+
+		BeforeEach(func() {
+			received = nil
+			backend = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				_ = json.NewDecoder(r.Body).Decode(&received)
+				http.Error(w, "{}", http.StatusInternalServerError)
+			}))
+			DeferCleanup(backend.Close)
+		})
+
+		// generate Sends a request to the given backend and returns the body it received.
+		generate := func(r *cmd.Request) map[string]interface{} {
+			received = nil
+			r.NTokens, r.NCompletions = 16, 1
+			r.Config.SetDefaults()
+			r.Config.OpenAI.BaseURL = backend.URL
+			r.Config.GPTJ.URL = backend.URL
+			r.Config.BLOOM.URL = backend.URL
+			r.Config.OPT.URL = backend.URL
+			r.Config.Claude.URL = backend.URL
+			r.Config.Ollama.URL = backend.URL
+			r.Config.HuggingFace.URL = backend.URL
+			r.Config.Cohere.URL = backend.URL
+			r.Config.Gemini.URL = backend.URL
+			r.Config.HuggingFace.ModelID = "bigcode/starcoder"
+
+			client, err := cmd.PrepareGenerateClient(r, "hello world")
+			Expect(err).NotTo(HaveOccurred())
+			_, _ = client.Generate(context.Background())
+			return received
+		}
+
+		// lookup Returns the value at the path of keys in the body.
+		lookup := func(body map[string]interface{}, path []string) interface{} {
+			var value interface{} = body
+			for _, key := range path {
+				Expect(value).To(HaveKey(key))
+				value = value.(map[string]interface{})[key]
+			}
+			return value
+		}
+
+		DescribeTable("passes them to the backend",
+			func(b ai.Backend, temperaturePath, topPPath []string) {
+				topP := float32(0.75)
+				body := generate(&cmd.Request{Backend: b, Temperature: 0.5, TopP: &topP})
+				Expect(lookup(body, temperaturePath)).To(BeNumerically("==", 0.5))
+				Expect(lookup(body, topPPath)).To(BeNumerically("==", 0.75))
+			},
+			Entry("gpt-3", ai.GPT3, []string{"temperature"}, []string{"top_p"}),
+			Entry("gpt-j", ai.GPTJ, []string{"temp"}, []string{"top_p"}),
+			Entry("bloom", ai.BLOOM, []string{"parameters", "temperature"}, []string{"parameters", "top_p"}),
+			Entry("opt", ai.OPT, []string{"temperature"}, []string{"top_p"}),
+			Entry("claude", ai.CLAUDE, []string{"temperature"}, []string{"top_p"}),
+			Entry("ollama", ai.OLLAMA, []string{"options", "temperature"}, []string{"options", "top_p"}),
+			Entry("huggingface", ai.HUGGINGFACE, []string{"parameters", "temperature"}, []string{"parameters", "top_p"}),
+			Entry("cohere", ai.COHERE, []string{"temperature"}, []string{"p"}),
+			Entry("gemini", ai.GEMINI, []string{"generationConfig", "temperature"}, []string{"generationConfig", "topP"}),
+		)
+
+		It("passes the seed to the backends which support it", func() {
+			seed := 42
+			body := generate(&cmd.Request{Backend: ai.BLOOM, Seed: &seed})
+			Expect(lookup(body, []string{"parameters", "seed"})).To(BeNumerically("==", 42))
+
+			r := &cmd.Request{Backend: ai.GPT3, Seed: &seed}
+			r.Config.OpenAI = &gpt3.Config{Model: "gpt-4o"}
+			Expect(cmd.SupportsSeed(r)).To(BeTrue())
+			Expect(generate(r)).To(HaveKeyWithValue("seed", BeNumerically("==", 42)))
+
+			// the completions endpoint doesn't take a seed
+			r = &cmd.Request{Backend: ai.GPT3, Seed: &seed}
+			Expect(cmd.SupportsSeed(r)).To(BeFalse())
+			Expect(generate(r)).NotTo(HaveKey("seed"))
+		})
+
+		It("passes the reasoning budget to the backends which support it", func() {
+			r := &cmd.Request{Backend: ai.GPT3, ReasoningTokens: 2048, Temperature: 0.5}
+			r.Config.OpenAI = &gpt3.Config{Model: "o3-mini"}
+			Expect(cmd.SupportsReasoningTokens(r)).To(BeTrue())
+			body := generate(r)
+			Expect(body).To(HaveKeyWithValue("max_completion_tokens", BeNumerically("==", 16+2048)))
+			Expect(body).NotTo(HaveKey("max_tokens"))
+			Expect(body).To(HaveKeyWithValue("temperature", BeNumerically("==", 1)))
+
+			body = generate(&cmd.Request{Backend: ai.CLAUDE, ReasoningTokens: 2048})
+			Expect(body["thinking"]).To(Equal(map[string]interface{}{"type": "enabled", "budget_tokens": float64(2048)}))
+			Expect(body).To(HaveKeyWithValue("max_tokens", BeNumerically("==", 16+2048)))
+
+			// models which don't reason are sent the max tokens alone
+			r = &cmd.Request{Backend: ai.GPT3, ReasoningTokens: 2048}
+			r.Config.OpenAI = &gpt3.Config{Model: "gpt-4o"}
+			Expect(cmd.SupportsReasoningTokens(r)).To(BeFalse())
+			body = generate(r)
+			Expect(body).To(HaveKeyWithValue("max_tokens", BeNumerically("==", 16)))
+			Expect(body).NotTo(HaveKey("max_completion_tokens"))
+		})
+
+		It("reads the reasoning budget from --reasoning-tokens", func() {
+			wd, err := os.Getwd()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(os.Chdir(GinkgoT().TempDir())).To(Succeed())
+			DeferCleanup(os.Chdir, wd)
+			viper.Reset()
+			DeferCleanup(viper.Reset)
+			Expect(os.WriteFile(config.ConfigFile, []byte("claude:\n  apiKey: sk-test\n  url: "+backend.URL+"\n"),
+				0600)).To(Succeed())
+
+			c := cmd.NewGenerateCmd()
+			Expect(c.Flags().Set(cmd.FlagRequestFull, "Create a Pod")).To(Succeed())
+			Expect(c.Flags().Set(cmd.FlagReasoningTokensFull, "1024")).To(Succeed())
+			r, err := cmd.PrepareRequest(c)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(r.ReasoningTokens).To(BeEquivalentTo(1024))
+
+			client, err := cmd.PrepareGenerateClient(r, "hello world")
+			Expect(err).NotTo(HaveOccurred())
+			_, _ = client.Generate(context.Background())
+			Expect(lookup(received, []string{"thinking", "budget_tokens"})).To(BeNumerically("==", 1024))
+		})
+
+		It("seeds BLOOM randomly without a seed", func() {
+			Expect(cmd.BloomParams(&cmd.Request{}).Seed).To(BeNumerically("<", 100))
+			Expect(generate(&cmd.Request{Backend: ai.GPT3})).NotTo(HaveKey("seed"))
+		})
+
+		It("sends the system prompt as a separate message to chat backends", func() {
+			const systemPrompt = "You write Kubernetes YAML."
+			r := &cmd.Request{Backend: ai.GPT3, SystemPrompt: systemPrompt}
+			r.Config.OpenAI = &gpt3.Config{Model: "gpt-4o"}
+			Expect(cmd.UsesSystemMessage(r)).To(BeTrue())
+			body := generate(r)
+			Expect(body["messages"]).To(Equal([]interface{}{
+				map[string]interface{}{"role": gpt3.RoleSystem, "content": systemPrompt},
+				map[string]interface{}{"role": gpt3.RoleUser, "content": "hello world"},
+			}))
+
+			body = generate(&cmd.Request{Backend: ai.CLAUDE, SystemPrompt: systemPrompt})
+			Expect(body["system"]).To(Equal(systemPrompt))
+			Expect(body["messages"]).To(Equal([]interface{}{
+				map[string]interface{}{"role": "user", "content": "hello world"},
+			}))
+		})
+
+		It("prepends the system prompt to the prompt of completion backends", func() {
+			r := &cmd.Request{Backend: ai.GPT3, SystemPrompt: "You write Kubernetes YAML."}
+			Expect(cmd.UsesSystemMessage(r)).To(BeFalse())
+			body := generate(r)
+			Expect(body["prompt"]).To(Equal("You write Kubernetes YAML.\n\nhello world"))
+			Expect(body).NotTo(HaveKey("messages"))
+		})
+
+		It("sends the end-of-sequence terminator as the stop sequence", func() {
+			body := generate(&cmd.Request{Backend: ai.GPT3})
+			Expect(body["stop"]).To(Equal([]interface{}{gpt3.CompletionEndOfSequence}))
+			body = generate(&cmd.Request{Backend: ai.GPT3, EndOfSequence: "<<END>>"})
+			Expect(body["stop"]).To(Equal([]interface{}{"<<END>>"}))
+		})
+
+		It("sends the custom stop sequences after the terminator", func() {
+			stop := []string{"\n\nExplanation:", "```\n\n"}
+			body := generate(&cmd.Request{Backend: ai.GPT3, Stop: stop})
+			Expect(body["stop"]).To(Equal([]interface{}{gpt3.CompletionEndOfSequence, "\n\nExplanation:", "```\n\n"}))
+
+			r := &cmd.Request{Backend: ai.GPT3, Stop: stop}
+			r.Config.OpenAI = &gpt3.Config{Model: "gpt-4o"}
+			Expect(generate(r)["stop"]).To(Equal([]interface{}{gpt3.CompletionEndOfSequence, "\n\nExplanation:", "```\n\n"}))
+		})
+
+		It("rejects more stop sequences than OpenAI accepts", func() {
+			for _, sequence := range []string{"a", "b", "c"} {
+				Expect(c.Flags().Set(cmd.FlagStopFull, sequence)).To(Succeed())
+			}
+			Expect(cmd.ValidateFlags(c, []string{})).To(Succeed())
+			Expect(c.Flags().Set(cmd.FlagStopFull, "d")).To(Succeed())
+			Expect(cmd.ValidateFlags(c, []string{})).To(MatchError(ContainSubstring(
+				"--stop can be given at most 3 times",
+			)))
+		})
+
+		It("keeps the backend's default top-p when unset", func() {
+			body := generate(&cmd.Request{Backend: ai.BLOOM})
+			Expect(lookup(body, []string{"parameters", "top_p"})).To(BeNumerically("~", 0.9, 1e-6))
+			body = generate(&cmd.Request{Backend: ai.CLAUDE})
+			Expect(body).NotTo(HaveKey("top_p"))
+		})
+
+		It("rejects values out of range", func() {
+			Expect(cmd.ValidateRequest(&cmd.Request{Backend: ai.GPT3, Temperature: 2.5})).NotTo(Succeed())
+			Expect(cmd.ValidateRequest(&cmd.Request{Backend: ai.GPT3, Temperature: 1.2})).To(Succeed())
+			Expect(cmd.ValidateRequest(&cmd.Request{Backend: ai.CLAUDE, Temperature: 1.2})).To(
+				MatchError(ContainSubstring("between 0 and 1.0")))
+			Expect(cmd.ValidateRequest(&cmd.Request{Backend: ai.CLAUDE, Temperature: 1})).To(Succeed())
+			Expect(cmd.ValidateRequest(&cmd.Request{Backend: ai.GPT3, Temperature: -0.1})).NotTo(Succeed())
+
+			Expect(c.Flags().Set(cmd.FlagTopPFull, "0")).To(Succeed())
+			Expect(cmd.ValidateFlags(c, []string{})).NotTo(Succeed())
+			Expect(c.Flags().Set(cmd.FlagTopPFull, "1")).To(Succeed())
+			Expect(cmd.ValidateFlags(c, []string{})).To(Succeed())
+		})
+	})
+
+	When("a custom end-of-sequence terminator is set", func() {
+		It("asks for it in the prompt", func() {
+			prompt, err := cmd.PrepareGenerateInput("create a pod", "# @pod.yaml\nkind: Pod", true, nil, "<<END>>")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(prompt).To(ContainSubstring("The new YAML, terminated by an '<<END>>'"))
+			Expect(prompt).NotTo(ContainSubstring("'" + gpt3.CompletionEndOfSequence + "'"))
+
+			templates := &config.PromptTemplates{Instructions: "End with {{.EndOfSequence}}\n"}
+			prompt, err = cmd.PrepareGenerateInput("create a pod", "", true, templates, "<<END>>")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(prompt).To(ContainSubstring("End with <<END>>\n"))
+		})
+
+		It("decodes the output up to it", func() {
+			r := &cmd.Request{OutputType: filemap.OutputPlain, EndOfSequence: "<<END>>"}
+			output := "# @job.yaml\nkind: Job\nargs: [EOF]\n" + gpt3.CompletionEndOfSequence + "\n<<END>>\nHope this helps!\n"
+			Expect(cmd.DecodeAndOutput(context.Background(), r, []string{output})).To(Succeed())
+			Expect(r.Filemap.Files["job.yaml"].Content).To(Equal(
+				"kind: Job\nargs: [EOF]\n" + gpt3.CompletionEndOfSequence + "\n"))
+		})
+
+		When("it's configured", func() {
+			BeforeEach(func() {
+				wd, err := os.Getwd()
+				Expect(err).NotTo(HaveOccurred())
+				Expect(os.Chdir(GinkgoT().TempDir())).To(Succeed())
+				DeferCleanup(os.Chdir, wd)
+				viper.Reset()
+				DeferCleanup(viper.Reset)
+				Expect(os.WriteFile(config.ConfigFile, []byte("endOfSequence: <<DONE>>\n"), 0600)).To(Succeed())
+				Expect(c.Flags().Set(cmd.FlagRequestFull, "Add a label")).To(Succeed())
+			})
+
+			It("uses the config, unless --eos is passed", func() {
+				r, err := cmd.PrepareRequest(c)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(r.Terminator()).To(Equal("<<DONE>>"))
+
+				Expect(c.Flags().Set(cmd.FlagEndOfSequenceFull, "<<END>>")).To(Succeed())
+				r, err = cmd.PrepareRequest(c)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(r.Terminator()).To(Equal("<<END>>"))
+			})
+
+			It("rejects a terminator which can't be told apart from the files", func() {
+				Expect(c.Flags().Set(cmd.FlagEndOfSequenceFull, filemap.FileDelimeter)).To(Succeed())
+				_, err := cmd.PrepareRequest(c)
+				Expect(err).To(MatchError(ContainSubstring("can't be the file delimiter")))
+			})
+		})
+
+		It("defaults to the built-in terminator", func() {
+			Expect((&cmd.Request{}).Terminator()).To(Equal(gpt3.CompletionEndOfSequence))
+			Expect(cmd.CheckEndOfSequence(" END")).NotTo(Succeed())
+			Expect(cmd.CheckEndOfSequence("END\nNOW")).NotTo(Succeed())
+			Expect(cmd.CheckEndOfSequence("")).To(Succeed())
+		})
+	})
+
+	When("filesets carry their own settings", func() {
+		BeforeEach(func() {
+			wd, err := os.Getwd()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(os.Chdir(GinkgoT().TempDir())).To(Succeed())
+			DeferCleanup(os.Chdir, wd)
+			viper.Reset()
+			DeferCleanup(viper.Reset)
+			Expect(os.WriteFile("configmap.yaml", []byte("kind: ConfigMap\n"), 0600)).To(Succeed())
+			Expect(os.WriteFile("crd.yaml", []byte("kind: CustomResourceDefinition\n"), 0600)).To(Succeed())
+			Expect(os.WriteFile(config.ConfigFile, []byte("defaultBackend: gpt-3\n"+
+				"filesets:\n"+
+				"  - name: configmaps\n"+
+				"    files: [configmap.yaml]\n"+
+				"  - name: crds\n"+
+				"    files: [crd.yaml]\n"+
+				"    backend: ollama\n"+
+				"    ntokens: 4096\n"+
+				"    ncompletions: 2\n"+
+				"    temperature: 0.8\n"+
+				"profiles:\n"+
+				"  short:\n"+
+				"    ntokens: 128\n"+
+				"  local:\n"+
+				"    backend: claude\n"+
+				"    model: claude-3-haiku-20240307\n"+
+				"    ntokens: 256\n"+
+				"    ncompletions: 3\n"), 0600)).To(Succeed())
+			Expect(c.Flags().Set(cmd.FlagRequestFull, "Add a label")).To(Succeed())
+		})
+
+		It("uses the global defaults when the fileset has no settings", func() {
+			Expect(c.Flags().Set(cmd.FlagFilesetsFull, "configmaps")).To(Succeed())
+			r, err := cmd.PrepareRequest(c)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(r.Backend).To(Equal(ai.GPT3))
+			Expect(r.NTokens).To(Equal(int32(cmd.DefaultTokens)))
+			Expect(r.NCompletions).To(Equal(int32(cmd.DefaultCompletions)))
+			Expect(r.Temperature).To(BeZero())
+		})
+
+		It("overrides the global defaults with the settings of the fileset", func() {
+			Expect(c.Flags().Set(cmd.FlagFilesetsFull, "crds")).To(Succeed())
+			r, err := cmd.PrepareRequest(c)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(r.Backend).To(Equal(ai.OLLAMA))
+			Expect(r.NTokens).To(Equal(int32(4096)))
+			Expect(r.NCompletions).To(Equal(int32(2)))
+			Expect(r.Temperature).To(Equal(float32(0.8)))
+			Expect(r.Filemap.Files).To(HaveKey("crd.yaml"))
+		})
+
+		It("selects among the completions of the fileset", func() {
+			Expect(c.Flags().Set(cmd.FlagFilesetsFull, "crds")).To(Succeed())
+			Expect(c.Flags().Set(cmd.FlagSelectFull, "2")).To(Succeed())
+			Expect(cmd.ValidateFlags(c, []string{})).To(Succeed())
+			r, err := cmd.PrepareRequest(c)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(r.Select).To(Equal(int32(2)))
+
+			Expect(c.Flags().Set(cmd.FlagSelectFull, "3")).To(Succeed())
+			_, err = cmd.PrepareRequest(c)
+			Expect(err).To(MatchError(ContainSubstring("--" + cmd.FlagSelectFull)))
+		})
+
+		It("uses a completion strategy with the completions of the fileset", func() {
+			Expect(c.Flags().Set(cmd.FlagFilesetsFull, "configmaps")).To(Succeed())
+			Expect(c.Flags().Set(cmd.FlagCompletionStrategyFull, cmd.CompletionStrategyMerge)).To(Succeed())
+			Expect(cmd.ValidateFlags(c, []string{})).To(Succeed())
+			_, err := cmd.PrepareRequest(c)
+			Expect(err).To(MatchError(ContainSubstring("--" + cmd.FlagCompletionStrategyFull)))
+
+			Expect(c.Flags().Set(cmd.FlagFilesetsFull, "crds")).To(Succeed())
+			r, err := cmd.PrepareRequest(c)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(r.CompletionStrategy).To(Equal(cmd.CompletionStrategyMerge))
+		})
+
+		It("rejects a temperature of the fileset out of range", func() {
+			content := "defaultBackend: gpt-3\nfilesets:\n  - name: hot\n    files: [crd.yaml]\n    temperature: 3\n"
+			Expect(os.WriteFile(config.ConfigFile, []byte(content), 0600)).To(Succeed())
+			Expect(c.Flags().Set(cmd.FlagFilesetsFull, "hot")).To(Succeed())
+			_, err := cmd.PrepareRequest(c)
+			Expect(err).To(MatchError(ContainSubstring("temperature must be between 0 and 2.0 for the \"gpt-3\" backend")))
+		})
+
+		It("overrides the profile", func() {
+			Expect(c.Flags().Set(cmd.FlagFilesetsFull, "crds")).To(Succeed())
+			Expect(c.Flags().Set(cmd.FlagProfileFull, "local")).To(Succeed())
+			r, err := cmd.PrepareRequest(c)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(r.Backend).To(Equal(ai.OLLAMA))
+			Expect(r.NTokens).To(Equal(int32(4096)))
+			Expect(r.NCompletions).To(Equal(int32(2)))
+			Expect(r.Config.Ollama.Model).NotTo(Equal("claude-3-haiku-20240307"))
+		})
+
+		It("keeps the profile's settings which the fileset doesn't set", func() {
+			Expect(c.Flags().Set(cmd.FlagFilesetsFull, "configmaps")).To(Succeed())
+			Expect(c.Flags().Set(cmd.FlagProfileFull, "local")).To(Succeed())
+			r, err := cmd.PrepareRequest(c)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(r.Backend).To(Equal(ai.CLAUDE))
+			Expect(r.Config.Claude.Model).To(Equal("claude-3-haiku-20240307"))
+			Expect(r.NTokens).To(Equal(int32(256)))
+			Expect(r.NCompletions).To(Equal(int32(3)))
+		})
+
+		It("is overridden by the flags", func() {
+			Expect(c.Flags().Set(cmd.FlagFilesetsFull, "crds")).To(Succeed())
+			Expect(c.Flags().Set(cmd.FlagAIBackendFull, string(ai.GPT3))).To(Succeed())
+			Expect(c.Flags().Set(cmd.FlagNTokensFull, "64")).To(Succeed())
+			Expect(c.Flags().Set(cmd.FlagNCompletionsFull, "1")).To(Succeed())
+			Expect(c.Flags().Set(cmd.FlagTemperatureFull, "0")).To(Succeed())
+			r, err := cmd.PrepareRequest(c)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(r.Backend).To(Equal(ai.GPT3))
+			Expect(r.NTokens).To(Equal(int32(64)))
+			Expect(r.NCompletions).To(Equal(int32(1)))
+			Expect(r.Temperature).To(BeZero())
+		})
+	})
+
+	When("the config sets a default backend", func() {
+		BeforeEach(func() {
+			wd, err := os.Getwd()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(os.Chdir(GinkgoT().TempDir())).To(Succeed())
+			DeferCleanup(os.Chdir, wd)
+			viper.Reset()
+			DeferCleanup(viper.Reset)
+			Expect(c.Flags().Set(cmd.FlagRequestFull, "Add a label")).To(Succeed())
+		})
+
+		It("uses it when no backend is passed", func() {
+			Expect(os.WriteFile(config.ConfigFile, []byte("defaultBackend: ollama\n"+
+				"ollama:\n  model: llama3\n"+
+				"claude:\n  apiKey: test\n"), 0600)).To(Succeed())
+			r, err := cmd.PrepareRequest(c)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(r.Backend).To(Equal(ai.OLLAMA))
+		})
+
+		It("is overridden by --backend", func() {
+			Expect(os.WriteFile(config.ConfigFile, []byte("defaultBackend: ollama\n"+
+				"ollama:\n  model: llama3\n"+
+				"claude:\n  apiKey: test\n"), 0600)).To(Succeed())
+			Expect(c.Flags().Set(cmd.FlagAIBackendFull, string(ai.CLAUDE))).To(Succeed())
+			r, err := cmd.PrepareRequest(c)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(r.Backend).To(Equal(ai.CLAUDE))
+		})
+
+		It("fails when the default isn't configured", func() {
+			Expect(os.WriteFile(config.ConfigFile, []byte("defaultBackend: cohere\n"+
+				"ollama:\n  model: llama3\n"+
+				"claude:\n  apiKey: test\n"), 0600)).To(Succeed())
+			_, err := cmd.PrepareRequest(c)
+			Expect(err).To(MatchError(ContainSubstring(`the default backend "cohere" isn't configured`)))
+		})
+	})
+
+	When("the changes are patched onto the files", func() {
+		const pod = "# @pod.yaml\nkind: Pod\nmetadata:\n  name: web\n"
+
+		It("asks for the existing files to be modified rather than created", func() {
+			prompt := cmd.PreparePatchInput("add a readiness probe", pod, true, gpt3.CompletionEndOfSequence)
+			Expect(prompt).To(HavePrefix("## This document contains instructions for modifying existing YAMLs,"))
+			Expect(prompt).To(ContainSubstring("## 1. Instructions for modifying the existing YAMLs:\nadd a readiness probe\n"))
+			Expect(prompt).To(ContainSubstring("## 2. Existing YAMLs:\n" + pod))
+			Expect(prompt).To(ContainSubstring("terminated by an '" + gpt3.CompletionEndOfSequence + "'"))
+			Expect(prompt).To(HaveSuffix("## 3. The modified YAMLs, in full:\n"))
+			Expect(prompt).NotTo(ContainSubstring("created"))
+		})
+
+		It("is used for --patch when files are given", func() {
+			fm := filemap.NewFilemap()
+			fm.Files["pod.yaml"] = filemap.File{Path: "pod.yaml", Content: "kind: Pod\nmetadata:\n  name: web\n"}
+			r := &cmd.Request{UserRequest: "add a readiness probe", Filemap: fm, Patch: true}
+			prompt, err := cmd.BuildGenerateInput(r)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(prompt).To(Equal(cmd.PreparePatchInput("add a readiness probe", r.FilemapText, true, r.Terminator())))
+
+			// there's nothing to modify without any files
+			r = &cmd.Request{UserRequest: "create a pod", Filemap: filemap.NewFilemap(), Patch: true}
+			prompt, err = cmd.BuildGenerateInput(r)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(prompt).To(ContainSubstring("## 1. Instructions for the new Kubernetes YAML:\ncreate a pod\n"))
+		})
+	})
+
+	When("prompt templates are configured", func() {
+		It("uses the built-in wording by default", func() {
+			prompt, err := cmd.PrepareGenerateInput("create a pod", "", true, nil, gpt3.CompletionEndOfSequence)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(prompt).To(ContainSubstring("## 1. Instructions for the new Kubernetes YAML:\ncreate a pod\n"))
+		})
+
+		It("renders the custom templates", func() {
+			templates := &config.PromptTemplates{
+				Preamble:     "# Our manifests\n",
+				CallToAction: "Request: {{.Request}}\n{{if .WithFiles}}Files ({{.Delimiter}}):\n{{.EncodedFiles}}\n{{end}}YAML:\n",
+			}
+			prompt, err := cmd.PrepareGenerateInput(
+				"create a pod", "@pod.yaml\nkind: Pod", true, templates, gpt3.CompletionEndOfSequence)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(prompt).To(HavePrefix("# Our manifests\n\n##\n## The structure of the document is as follows:"))
+			Expect(prompt).To(HaveSuffix("Request: create a pod\nFiles (===):\n@pod.yaml\nkind: Pod\nYAML:\n"))
+		})
+
+		It("doesn't only ask for Kubernetes YAML when other files are included", func() {
+			prompt, err := cmd.PrepareGenerateInput(
+				"add a healthcheck", "# @config.json\n{}", false, nil, gpt3.CompletionEndOfSequence)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(prompt).NotTo(ContainSubstring("YAML"))
+			Expect(prompt).To(ContainSubstring("## 2. Existing files:\n# @config.json\n{}\n"))
+			Expect(prompt).To(HaveSuffix("## 3. The new file:\n"))
+		})
+
+		It("rejects invalid templates", func() {
+			_, err := cmd.PrepareGenerateInput(
+				"create a pod", "", true, &config.PromptTemplates{Preamble: "{{.Unknown}}"}, gpt3.CompletionEndOfSequence)
+			Expect(err).To(MatchError(ContainSubstring("preamble prompt template")))
+		})
+	})
 })
+
+// countingClient Returns the same completions every time, counting how often it was called.
+type countingClient struct {
+	choices []string
+	calls   int
+}
+
+func (c *countingClient) Generate(context.Context) ([]string, error) {
+	c.calls++
+	return c.choices, nil
+}
@@ -0,0 +1,53 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/redhat-et/copilot-ops/pkg/filemap"
+	"github.com/redhat-et/copilot-ops/pkg/helm"
+)
+
+// helmNouns Are the words used to refer to the files in a prompt for a Helm values file.
+func helmNouns() promptNouns {
+	return promptNouns{kind: "Helm values file", single: "Helm values", plural: "files"}
+}
+
+// PrepareHelmInput Formats a prompt asking for a file which overrides the values of the given chart,
+// rather than Kubernetes YAML. The chart's defaults are included ahead of the other files for context.
+func PrepareHelmInput(userInput string, chart *helm.Chart, encodedFiles, endOfSequence string) string {
+	nouns := helmNouns()
+	context := JoinContext(chart.EncodeDefaults(), encodedFiles)
+	withFiles := strings.TrimSpace(context) != ""
+	overrides := fmt.Sprintf(`
+## The Helm values override the defaults of the %q chart, so they only contain the values which need to change.`,
+		chart.Name)
+	return preamble(withFiles, nouns) + overrides + instructions(withFiles, nouns, endOfSequence) +
+		callToActionSequence(userInput, context, nouns)
+}
+
+// DecodeHelmValues Decodes the completions into a single values file, taken from the last completion.
+// The output is used as-is, apart from the tag or delimiters the model may have added around it.
+func DecodeHelmValues(choices []string) *filemap.Filemap {
+	fm := filemap.NewFilemap()
+	for _, choice := range choices {
+		for _, part := range filemap.SplitFiles(choice) {
+			part = strings.TrimSpace(part)
+			if part == "" {
+				continue
+			}
+			// drop the tag, since the values are always written to the same file
+			if first, rest, _ := strings.Cut(part, "\n"); strings.HasPrefix(first, "# "+filemap.FileTagPrefix) {
+				part = strings.TrimSpace(rest)
+			}
+			fm.Files[helm.ValuesFileName] = filemap.File{
+				Name:    helm.ValuesFileName,
+				Path:    helm.ValuesFileName,
+				Content: part + "\n",
+				Type:    filemap.FileTypeYAML,
+			}
+			break
+		}
+	}
+	return fm
+}
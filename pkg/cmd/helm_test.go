@@ -0,0 +1,57 @@
+package cmd_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/redhat-et/copilot-ops/pkg/cmd"
+	"github.com/redhat-et/copilot-ops/pkg/filemap"
+	"github.com/redhat-et/copilot-ops/pkg/helm"
+)
+
+var _ = Describe("Helm values", func() {
+	var chart *helm.Chart
+
+	BeforeEach(func() {
+		chart = &helm.Chart{Path: "charts/guestbook", Name: "guestbook", Values: "replicaCount: 1\n"}
+	})
+
+	It("asks for Helm values instead of Kubernetes YAML", func() {
+		prompt, err := cmd.BuildGenerateInput(&cmd.Request{
+			UserRequest: "Run 3 replicas",
+			Filemap:     filemap.NewFilemap(),
+			HelmChart:   chart,
+		})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(prompt).To(ContainSubstring("a new Helm values file"))
+		Expect(prompt).To(ContainSubstring(`the defaults of the "guestbook" chart`))
+		Expect(prompt).NotTo(ContainSubstring("Kubernetes YAML"))
+		Expect(prompt).To(ContainSubstring("# " + filemap.FileTagPrefix + "charts/guestbook/values.yaml\nreplicaCount: 1\n"))
+		Expect(prompt).To(HaveSuffix("## 3. The new Helm values:\n"))
+	})
+
+	It("decodes the output into a single values.yaml", func() {
+		fm := cmd.DecodeHelmValues([]string{
+			"replicaCount: 2\n",
+			"# " + filemap.FileTagPrefix + "values.yaml\nreplicaCount: 3\n" + filemap.FileDelimeter + "\nkind: Pod\n",
+		})
+		Expect(fm.Files).To(HaveLen(1))
+		Expect(fm.Files[helm.ValuesFileName].Path).To(Equal(helm.ValuesFileName))
+		Expect(fm.Files[helm.ValuesFileName].Content).To(Equal("replicaCount: 3\n"))
+	})
+
+	It("writes the values under the output directory", func() {
+		wd, err := os.Getwd()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(os.Chdir(GinkgoT().TempDir())).To(Succeed())
+		DeferCleanup(os.Chdir, wd)
+
+		r := &cmd.Request{HelmChart: chart, IsWrite: true, OutputDir: "overrides", Validate: true}
+		Expect(cmd.DecodeAndOutput(context.Background(), r, []string{"replicaCount: 3\n"})).To(Succeed())
+		Expect(os.ReadFile(filepath.Join("overrides", helm.ValuesFileName))).To(Equal([]byte("replicaCount: 3\n")))
+	})
+})
@@ -0,0 +1,118 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/redhat-et/copilot-ops/pkg/filemap"
+	"github.com/redhat-et/copilot-ops/pkg/history"
+	"github.com/redhat-et/copilot-ops/pkg/logger"
+)
+
+// DefaultHistoryLimit Is the number of entries listed by `copilot-ops history` by default.
+const DefaultHistoryLimit = 10
+
+// NewHistoryCmd Creates the `copilot-ops history` CLI command.
+func NewHistoryCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use: CommandHistory,
+
+		Short: "Lists the recent generations",
+
+		Long: "Lists the most recent generations recorded in " + history.Path(".") +
+			", with the backend and model which made them, the request, and the files they produced.",
+
+		Example: `  copilot-ops history --limit 3`,
+
+		RunE: RunHistory,
+	}
+
+	cmd.Flags().StringP(
+		FlagPathFull, FlagPathShort, ".",
+		"Path to the root of the repo",
+	)
+
+	cmd.Flags().Int(
+		FlagLimitFull, DefaultHistoryLimit,
+		"Number of recent generations to list (0 lists all of them)",
+	)
+
+	return cmd
+}
+
+// RunHistory Runs when the `history` command is invoked.
+func RunHistory(cmd *cobra.Command, args []string) error {
+	root, _ := cmd.Flags().GetString(FlagPathFull)
+	limit, _ := cmd.Flags().GetInt(FlagLimitFull)
+
+	entries, err := history.Load(root)
+	if err != nil {
+		return err
+	}
+	if len(entries) == 0 {
+		logger.Infof("no generations have been recorded in %s\n", history.Path(root))
+		return nil
+	}
+	PrintHistory(cmd.OutOrStdout(), history.Recent(entries, limit))
+	return nil
+}
+
+// PrintHistory Prints the entries, oldest first, each followed by its request and files.
+func PrintHistory(out io.Writer, entries []history.Entry) {
+	for _, entry := range entries {
+		model := string(entry.Backend)
+		if entry.Model != "" && entry.Model != model {
+			model += "/" + entry.Model
+		}
+		action := "printed"
+		if entry.Written {
+			action = "written"
+		}
+		fmt.Fprintf(out, "%s  %s  %s  %s\n", entry.Time.Local().Format("2006-01-02 15:04:05"), entry.Command, model, action)
+		fmt.Fprintf(out, "  request: %s\n", strings.Join(strings.Fields(entry.Request), " "))
+		for _, file := range entry.Files {
+			fmt.Fprintf(out, "  - %s\n", file)
+		}
+	}
+}
+
+// RecordHistory Appends the generation of the files to the history of the repo, unless --no-history was passed.
+// Failing to record it only warns, since the files were already generated.
+func RecordHistory(r *Request, command string, files []string) {
+	if r.NoHistory {
+		return
+	}
+	entry := history.Entry{
+		Time:    time.Now().UTC(),
+		Command: command,
+		Backend: r.Backend,
+		Model:   pricedModel(r),
+		Request: r.UserRequest,
+		Files:   files,
+		Written: r.IsWrite,
+	}
+	if err := history.Append(".", entry); err != nil {
+		logger.Warnf("could not record the generation in %s: %s\n", history.Path("."), err)
+	}
+}
+
+// GeneratedFiles Returns the paths of the generated files in the filemap, leaving out those fetched as context.
+func GeneratedFiles(fm *filemap.Filemap) []string {
+	var files []string
+	for _, tag := range fm.TagsByPath() {
+		file := fm.Files[tag]
+		if file.URL != "" {
+			continue
+		}
+		path := file.Path
+		if path == "" {
+			path = tag
+		}
+		files = append(files, path)
+	}
+	return files
+}
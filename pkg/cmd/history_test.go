@@ -0,0 +1,93 @@
+package cmd_test
+
+import (
+	"bytes"
+	"os"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/redhat-et/copilot-ops/pkg/ai"
+	"github.com/redhat-et/copilot-ops/pkg/ai/gpt3"
+	"github.com/redhat-et/copilot-ops/pkg/cmd"
+	"github.com/redhat-et/copilot-ops/pkg/history"
+)
+
+var _ = Describe("History command", func() {
+	var url string
+
+	BeforeEach(func() {
+		ts := OpenAITestServer()
+		ts.Start()
+		DeferCleanup(ts.Close)
+		url = ts.URL + gpt3.OpenAIEndpointV1
+
+		wd, err := os.Getwd()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(os.Chdir(GinkgoT().TempDir())).To(Succeed())
+		DeferCleanup(os.Chdir, wd)
+	})
+
+	// generate Runs `copilot-ops generate` for the request with the given flags.
+	generate := func(request string, flags map[string]string) {
+		c := cmd.NewGenerateCmd()
+		Expect(c.Flags().Set(cmd.FlagAIBackendFull, string(ai.GPT3))).To(Succeed())
+		Expect(c.Flags().Set(cmd.FlagOpenAIURLFull, url)).To(Succeed())
+		Expect(c.Flags().Set(cmd.FlagNTokensFull, "1")).To(Succeed())
+		Expect(c.Flags().Set(cmd.FlagRequestFull, request)).To(Succeed())
+		for name, value := range flags {
+			Expect(c.Flags().Set(name, value)).To(Succeed())
+		}
+		Expect(cmd.RunGenerate(c, []string{})).To(Succeed())
+	}
+
+	It("appends an entry for every run", func() {
+		generate("Create a Pod running nginx", nil)
+		generate("Create a Service for nginx", map[string]string{cmd.FlagWriteFull: "true"})
+
+		entries, err := history.Load(".")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(entries).To(HaveLen(2))
+		Expect(entries[0].Command).To(Equal(cmd.CommandGenerate))
+		Expect(entries[0].Backend).To(Equal(ai.GPT3))
+		Expect(entries[0].Model).NotTo(BeEmpty())
+		Expect(entries[0].Request).To(Equal("Create a Pod running nginx"))
+		Expect(entries[0].Files).NotTo(BeEmpty())
+		Expect(entries[0].Written).To(BeFalse())
+		Expect(entries[1].Request).To(Equal("Create a Service for nginx"))
+		Expect(entries[1].Written).To(BeTrue())
+		Expect(entries[1].Time).NotTo(BeTemporally("<", entries[0].Time))
+	})
+
+	It("doesn't record runs with --no-history", func() {
+		generate("Create a Pod running nginx", map[string]string{cmd.FlagNoHistoryFull: "true"})
+		Expect(history.Path(".")).NotTo(BeAnExistingFile())
+	})
+
+	It("lists the recent entries", func() {
+		generate("Create a Pod running nginx", nil)
+		generate("Create a Service for nginx", nil)
+
+		c := cmd.NewHistoryCmd()
+		out := &bytes.Buffer{}
+		c.SetOut(out)
+		Expect(cmd.RunHistory(c, []string{})).To(Succeed())
+		Expect(out.String()).To(ContainSubstring("request: Create a Pod running nginx\n"))
+		Expect(out.String()).To(ContainSubstring("request: Create a Service for nginx\n"))
+		Expect(out.String()).To(ContainSubstring(" generate  gpt-3/"))
+
+		out.Reset()
+		Expect(c.Flags().Set(cmd.FlagLimitFull, "1")).To(Succeed())
+		Expect(cmd.RunHistory(c, []string{})).To(Succeed())
+		Expect(out.String()).NotTo(ContainSubstring("Create a Pod running nginx"))
+		Expect(out.String()).To(ContainSubstring("request: Create a Service for nginx\n"))
+	})
+
+	It("succeeds when nothing was recorded", func() {
+		c := cmd.NewHistoryCmd()
+		out := &bytes.Buffer{}
+		c.SetOut(out)
+		Expect(cmd.RunHistory(c, []string{})).To(Succeed())
+		Expect(out.String()).To(BeEmpty())
+	})
+})
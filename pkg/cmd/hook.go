@@ -0,0 +1,64 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"sort"
+	"strings"
+
+	"github.com/redhat-et/copilot-ops/pkg/logger"
+)
+
+// PostHookFilePlaceholder Is replaced with the path of each written file in the --post-hook command,
+// which then runs once per file.
+const PostHookFilePlaceholder = "{}"
+
+// RunPostHook Runs the command given with --post-hook after the files were written: once for each
+// written file when the command contains PostHookFilePlaceholder, or else once in the output directory.
+// The command is run by the shell, and everything it prints is streamed to the request's ErrOut.
+// It fails when the command exits with a non-zero status.
+func RunPostHook(ctx context.Context, r *Request) error {
+	if r.PostHook == "" {
+		return nil
+	}
+	if !strings.Contains(r.PostHook, PostHookFilePlaceholder) {
+		dir := r.OutputDir
+		if dir == "" {
+			dir = "."
+		}
+		return runHook(ctx, r, r.PostHook, dir)
+	}
+	paths := make([]string, 0, len(r.Filemap.Files))
+	for _, file := range r.Filemap.Files {
+		if file.URL == "" {
+			paths = append(paths, file.Path)
+		}
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		command := strings.ReplaceAll(r.PostHook, PostHookFilePlaceholder, shellQuote(path))
+		if err := runHook(ctx, r, command, "."); err != nil {
+			return fmt.Errorf("%w for %q", err, path)
+		}
+	}
+	return nil
+}
+
+// runHook Runs the command with the shell in dir, streaming its output to the request's ErrOut.
+func runHook(ctx context.Context, r *Request, command, dir string) error {
+	logger.Infof("running post-hook %q\n", command)
+	hook := exec.CommandContext(ctx, "sh", "-c", command)
+	hook.Dir = dir
+	hook.Stdout = r.ErrOut
+	hook.Stderr = r.ErrOut
+	if err := hook.Run(); err != nil {
+		return fmt.Errorf("the files were written, but post-hook %q failed: %w", command, err)
+	}
+	return nil
+}
+
+// shellQuote Quotes the string so that the shell passes it as a single argument.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
@@ -0,0 +1,73 @@
+package cmd_test
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/redhat-et/copilot-ops/pkg/cmd"
+	"github.com/redhat-et/copilot-ops/pkg/filemap"
+)
+
+var _ = Describe("Post-hook", func() {
+	var r *cmd.Request
+	var out *bytes.Buffer
+
+	BeforeEach(func() {
+		wd, err := os.Getwd()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(os.Chdir(GinkgoT().TempDir())).To(Succeed())
+		DeferCleanup(os.Chdir, wd)
+
+		fm := filemap.NewFilemap()
+		fm.Files["pod"] = filemap.File{Path: "manifests/pod.yaml", Content: "kind: Pod\nimage: nginx:latest\n"}
+		fm.Files["svc"] = filemap.File{Path: "manifests/my svc.yaml", Content: "kind: Service\n"}
+		out = &bytes.Buffer{}
+		r = &cmd.Request{Filemap: fm, IsWrite: true, ErrOut: out}
+	})
+
+	It("runs once per written file when the command has a placeholder", func() {
+		r.PostHook = "sed -i 's/:latest/:1.25/' {} && echo checked {}"
+		Expect(cmd.PrintOrWriteOut(context.Background(), r)).To(Succeed())
+
+		content, err := os.ReadFile(filepath.Join("manifests", "pod.yaml"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(content)).To(Equal("kind: Pod\nimage: nginx:1.25\n"))
+		Expect(out.String()).To(Equal("checked manifests/my svc.yaml\nchecked manifests/pod.yaml\n"))
+	})
+
+	It("runs once in the output directory without a placeholder", func() {
+		r.OutputDir = "manifests"
+		r.PostHook = "ls"
+		Expect(cmd.PrintOrWriteOut(context.Background(), r)).To(Succeed())
+		Expect(out.String()).To(Equal("my svc.yaml\npod.yaml\n"))
+	})
+
+	It("stops the command when the run is interrupted or times out", func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		r.PostHook = "exec sleep 10"
+		start := time.Now()
+		Expect(cmd.PrintOrWriteOut(ctx, r)).To(MatchError(ContainSubstring(`post-hook "exec sleep 10" failed`)))
+		Expect(time.Since(start)).To(BeNumerically("<", 5*time.Second))
+	})
+
+	It("fails the run when the command fails", func() {
+		r.PostHook = "grep -q Service {} || { echo 'policy violated' >&2; exit 3; }"
+		err := cmd.PrintOrWriteOut(context.Background(), r)
+		Expect(err).To(MatchError(ContainSubstring("exit status 3")))
+		Expect(err).To(MatchError(ContainSubstring(`for "manifests/pod.yaml"`)))
+		Expect(out.String()).To(Equal("policy violated\n"))
+	})
+
+	It("requires --write", func() {
+		c := cmd.NewGenerateCmd()
+		Expect(c.Flags().Set(cmd.FlagPostHookFull, "kubeconform {}")).To(Succeed())
+		Expect(cmd.ValidateFlags(c, []string{})).To(MatchError(ContainSubstring("--" + cmd.FlagWriteFull)))
+	})
+})
@@ -0,0 +1,69 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/redhat-et/copilot-ops/pkg/cmd/config"
+	"github.com/redhat-et/copilot-ops/pkg/logger"
+)
+
+// NewConfigCmd Creates the `copilot-ops config` CLI command, which groups the commands managing the config file.
+func NewConfigCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use: CommandConfig,
+
+		Short: "Manages the config file",
+	}
+
+	cmd.AddCommand(NewConfigInitCmd())
+
+	return cmd
+}
+
+// NewConfigInitCmd Creates the `copilot-ops config init` CLI command.
+func NewConfigInitCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use: CommandInit,
+
+		Short: "Writes a commented config file to start from",
+
+		Long: "Writes a " + config.ConfigFile + " configuring OpenAI, with commented-out sections " +
+			"for every other backend and an example fileset.",
+
+		Example: `  copilot-ops config init
+  copilot-ops config init --path ~/src/my-repo`,
+
+		RunE: RunConfigInit,
+	}
+
+	cmd.Flags().StringP(
+		FlagPathFull, FlagPathShort, ".",
+		"Path to the root of the repo, where the config file is written",
+	)
+
+	cmd.Flags().Bool(
+		FlagForceFull, false,
+		"Overwrite the config file if it already exists",
+	)
+
+	return cmd
+}
+
+// RunConfigInit Runs when the `config init` command is invoked.
+func RunConfigInit(cmd *cobra.Command, args []string) error {
+	dir, _ := cmd.Flags().GetString(FlagPathFull)
+	force, _ := cmd.Flags().GetBool(FlagForceFull)
+
+	path, err := config.WriteTemplate(dir, force)
+	if errors.Is(err, config.ErrConfigExists) {
+		return fmt.Errorf("%w (pass --%s to overwrite it)", err, FlagForceFull)
+	}
+	if err != nil {
+		return fmt.Errorf("could not write the config file: %w", err)
+	}
+	logger.Infof("wrote %s\n", path)
+	return nil
+}
@@ -0,0 +1,28 @@
+package cmd_test
+
+import (
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/redhat-et/copilot-ops/pkg/cmd"
+	"github.com/redhat-et/copilot-ops/pkg/cmd/config"
+)
+
+var _ = Describe("Config init command", func() {
+	It("writes the config file to the given path, and only overwrites it with --force", func() {
+		dir := filepath.Join(GinkgoT().TempDir(), "repo")
+		c := cmd.NewConfigInitCmd()
+		Expect(c.Flags().Set(cmd.FlagPathFull, dir)).To(Succeed())
+		Expect(cmd.RunConfigInit(c, []string{})).To(Succeed())
+		Expect(filepath.Join(dir, config.ConfigFile)).To(BeAnExistingFile())
+
+		err := cmd.RunConfigInit(c, []string{})
+		Expect(err).To(MatchError(config.ErrConfigExists))
+		Expect(err).To(MatchError(ContainSubstring("--" + cmd.FlagForceFull)))
+
+		Expect(c.Flags().Set(cmd.FlagForceFull, "true")).To(Succeed())
+		Expect(cmd.RunConfigInit(c, []string{})).To(Succeed())
+	})
+})
@@ -0,0 +1,111 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/redhat-et/copilot-ops/pkg/filemap"
+	"github.com/redhat-et/copilot-ops/pkg/kustomize"
+	"github.com/redhat-et/copilot-ops/pkg/logger"
+)
+
+// FindKustomizations Returns the nearest kustomization of each file in the filemap, or of the repo
+// when the filemap is empty, without duplicates and in order of their paths.
+func FindKustomizations(fm *filemap.Filemap) ([]*kustomize.Kustomization, error) {
+	dirs := []string{"."}
+	if len(fm.Files) > 0 {
+		dirs = dirs[:0]
+		for _, file := range fm.Files {
+			dirs = append(dirs, filepath.Dir(file.Path))
+		}
+	}
+	found := map[string]*kustomize.Kustomization{}
+	for _, dir := range dirs {
+		k, err := kustomize.Find(dir, ".")
+		if err != nil {
+			return nil, err
+		}
+		if k != nil {
+			found[k.Path] = k
+		}
+	}
+	kustomizations := make([]*kustomize.Kustomization, 0, len(found))
+	for _, k := range found {
+		kustomizations = append(kustomizations, k)
+	}
+	sort.Slice(kustomizations, func(i, j int) bool {
+		return kustomizations[i].Path < kustomizations[j].Path
+	})
+	return kustomizations, nil
+}
+
+// KustomizeHeader Formats the resources of each kustomization as a header to be placed
+// at the top of a prompt, or an empty string if there are no kustomizations.
+func KustomizeHeader(kustomizations []*kustomize.Kustomization) string {
+	if len(kustomizations) == 0 {
+		return ""
+	}
+	header := "## The files are managed with Kustomize.\n"
+	for _, k := range kustomizations {
+		header += fmt.Sprintf("## %s lists these resources:\n", filepath.ToSlash(k.Path))
+		for _, resource := range k.Resources {
+			header += "## - " + resource + "\n"
+		}
+	}
+	return header + "## New resources should fit into these kustomizations, " +
+		"and resources from a base should be changed with patches.\n##\n"
+}
+
+// AddToKustomizations Adds every new YAML file in the filemap to the resources of its nearest
+// kustomization. The updated kustomizations are added to the filemap, so that they're
+// written, diffed, and recorded along with the generated files.
+func AddToKustomizations(fm *filemap.Filemap) error {
+	added := map[string][]string{}
+	kustomizations := map[string]*kustomize.Kustomization{}
+	for _, tag := range fm.TagsByPath() {
+		file := fm.Files[tag]
+		ext := strings.ToLower(filepath.Ext(file.Path))
+		if (ext != ".yaml" && ext != ".yml") || kustomize.IsKustomization(file.Path) {
+			continue
+		}
+		if _, err := os.Stat(file.Path); !errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		k, err := kustomize.Find(filepath.Dir(file.Path), ".")
+		if err != nil {
+			return err
+		}
+		if k == nil {
+			continue
+		}
+		resource, err := k.Resource(file.Path)
+		if err != nil {
+			return err
+		}
+		kustomizations[k.Path] = k
+		added[k.Path] = append(added[k.Path], resource)
+	}
+
+	for path, resources := range added {
+		k := kustomizations[path]
+		// the kustomization may have been generated too
+		tag := path
+		for fileTag, file := range fm.Files {
+			if file.Path == path {
+				tag = fileTag
+				var err error
+				if k, err = kustomize.Parse(path, file.Content); err != nil {
+					return err
+				}
+			}
+		}
+		logger.Infof("adding %s to the resources of %s\n", strings.Join(resources, ", "), path)
+		fm.Files[tag] = filemap.File{Path: path, Content: k.AddResources(resources), Type: filemap.FileTypeYAML}
+	}
+	return nil
+}
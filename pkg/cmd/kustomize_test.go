@@ -0,0 +1,48 @@
+package cmd_test
+
+import (
+	"context"
+	"os"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/redhat-et/copilot-ops/pkg/cmd"
+	"github.com/redhat-et/copilot-ops/pkg/filemap"
+)
+
+var _ = Describe("Kustomize", func() {
+	BeforeEach(func() {
+		wd, err := os.Getwd()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(os.Chdir(GinkgoT().TempDir())).To(Succeed())
+		DeferCleanup(os.Chdir, wd)
+
+		Expect(os.MkdirAll("overlays/prod", 0755)).To(Succeed())
+		Expect(os.WriteFile("overlays/prod/kustomization.yaml", []byte("resources:\n- ../../base\n"), 0600)).To(Succeed())
+		Expect(os.WriteFile("overlays/prod/replicas.yaml", []byte("kind: Deployment\n"), 0600)).To(Succeed())
+	})
+
+	It("describes the kustomizations of the files in the prompt", func() {
+		fm := filemap.NewFilemap()
+		fm.Files["replicas"] = filemap.File{Path: "overlays/prod/replicas.yaml", Content: "kind: Deployment\n"}
+		kustomizations, err := cmd.FindKustomizations(fm)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(kustomizations).To(HaveLen(1))
+
+		header := cmd.KustomizeHeader(kustomizations)
+		Expect(header).To(ContainSubstring("## overlays/prod/kustomization.yaml lists these resources:\n## - ../../base\n"))
+		Expect(cmd.KustomizeHeader(nil)).To(BeEmpty())
+	})
+
+	It("adds new files to the resources of their kustomization when writing", func() {
+		fm := filemap.NewFilemap()
+		fm.Files["replicas"] = filemap.File{Path: "overlays/prod/replicas.yaml", Content: "kind: Deployment\nreplicas: 3\n"}
+		fm.Files["service"] = filemap.File{Path: "overlays/prod/service.yaml", Content: "kind: Service\n"}
+		Expect(cmd.PrintOrWriteOut(context.Background(),
+			&cmd.Request{Filemap: fm, IsWrite: true, Kustomize: true})).To(Succeed())
+
+		Expect(os.ReadFile("overlays/prod/service.yaml")).To(Equal([]byte("kind: Service\n")))
+		Expect(os.ReadFile("overlays/prod/kustomization.yaml")).To(Equal([]byte("resources:\n- ../../base\n- service.yaml\n")))
+	})
+})
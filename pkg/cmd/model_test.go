@@ -0,0 +1,88 @@
+package cmd_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/redhat-et/copilot-ops/pkg/ai"
+	"github.com/redhat-et/copilot-ops/pkg/ai/gpt3"
+	"github.com/redhat-et/copilot-ops/pkg/cmd"
+)
+
+var _ = Describe("Overriding the model", func() {
+	var (
+		c      *cobra.Command
+		server *httptest.Server
+		models []string
+	)
+
+	BeforeEach(func() {
+		models = nil
+		// a backend which records the model of each request, answering like OpenAI's chat models or Ollama
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var body struct {
+				Model string `json:"model"`
+			}
+			Expect(json.NewDecoder(r.Body).Decode(&body)).To(Succeed())
+			models = append(models, body.Model)
+			if r.URL.Path == "/v1/"+gpt3.ChatCompletionEndpoint {
+				_, _ = w.Write([]byte(`{"choices": [{"message": {"role": "assistant", "content": "kind: Pod"}}]}`))
+				return
+			}
+			_, _ = w.Write([]byte(`{"response": "kind: Pod", "done": true}`))
+		}))
+		DeferCleanup(server.Close)
+
+		wd, err := os.Getwd()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(os.Chdir(GinkgoT().TempDir())).To(Succeed())
+		DeferCleanup(os.Chdir, wd)
+		viper.Reset()
+		DeferCleanup(viper.Reset)
+
+		c = cmd.NewGenerateCmd()
+		Expect(c.Flags().Set(cmd.FlagRequestFull, "Create a Pod running nginx")).To(Succeed())
+	})
+
+	// generate Prepares the request and sends the prompt to the client created for it.
+	generate := func() *cmd.Request {
+		r, err := cmd.PrepareRequest(c)
+		Expect(err).NotTo(HaveOccurred())
+		r.Config.Ollama.URL = server.URL
+		client, err := cmd.PrepareGenerateClient(r, "Create a Pod running nginx")
+		Expect(err).NotTo(HaveOccurred())
+		_, err = client.Generate(context.Background())
+		Expect(err).NotTo(HaveOccurred())
+		return r
+	}
+
+	It("sends the model to OpenAI", func() {
+		Expect(c.Flags().Set(cmd.FlagAIBackendFull, string(ai.GPT3))).To(Succeed())
+		Expect(c.Flags().Set(cmd.FlagOpenAIURLFull, server.URL+gpt3.OpenAIEndpointV1)).To(Succeed())
+		Expect(c.Flags().Set(cmd.FlagModelFull, "gpt-4o-mini")).To(Succeed())
+		generate()
+		Expect(models).To(Equal([]string{"gpt-4o-mini"}))
+	})
+
+	It("sends the model to Ollama", func() {
+		Expect(c.Flags().Set(cmd.FlagAIBackendFull, string(ai.OLLAMA))).To(Succeed())
+		Expect(c.Flags().Set(cmd.FlagModelFull, "codellama:13b")).To(Succeed())
+		generate()
+		Expect(models).To(Equal([]string{"codellama:13b"}))
+	})
+
+	It("refuses a backend which doesn't support choosing a model", func() {
+		Expect(c.Flags().Set(cmd.FlagAIBackendFull, string(ai.BLOOM))).To(Succeed())
+		Expect(c.Flags().Set(cmd.FlagModelFull, "bigscience/bloomz")).To(Succeed())
+		_, err := cmd.PrepareRequest(c)
+		Expect(err).To(MatchError(ContainSubstring(`cannot use --model: the "bloom" backend does not support choosing a model`)))
+	})
+})
@@ -0,0 +1,156 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/redhat-et/copilot-ops/pkg/ai"
+	"github.com/redhat-et/copilot-ops/pkg/ai/gpt3"
+	"github.com/redhat-et/copilot-ops/pkg/ai/hfinference"
+	"github.com/redhat-et/copilot-ops/pkg/ai/ollama"
+	"github.com/redhat-et/copilot-ops/pkg/cmd/config"
+	"github.com/redhat-et/copilot-ops/pkg/logger"
+)
+
+// ErrModelsNotSupported Is returned for backends which have no API to list their models.
+var ErrModelsNotSupported = errors.New("not supported")
+
+// BackendModels Is the result of listing the models of a single backend.
+type BackendModels struct {
+	Backend ai.Backend
+	Models  []string
+	// Err Is why the models couldn't be listed, or nil if they were.
+	Err error
+}
+
+// NewModelsCmd Creates the `copilot-ops models` CLI command.
+func NewModelsCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use: CommandModels,
+
+		Short: "Lists the models available from each configured backend",
+
+		Long: "Lists the models which can be passed to --" + FlagModelFull + ", for every backend configured in " +
+			config.ConfigFile + " whose API lists its models: OpenAI, Ollama, and the HuggingFace Inference API.",
+
+		Example: `  copilot-ops models
+  copilot-ops models --backend ollama`,
+
+		RunE: RunModels,
+	}
+
+	cmd.Flags().StringP(
+		FlagAIBackendFull, FlagAIBackendShort, string(ai.Unselected),
+		"Only list the models of this backend, even if it isn't configured",
+	)
+
+	cmd.Flags().StringP(
+		FlagPathFull, FlagPathShort, ".",
+		"Path to the root of the repo",
+	)
+
+	cmd.Flags().Duration(
+		FlagTimeoutFull, DefaultCheckTimeout,
+		"Give up on a backend which takes longer than this to list its models, or 0 to wait indefinitely",
+	)
+
+	AddConnectionFlags(cmd)
+
+	return cmd
+}
+
+// RunModels Runs when the `models` command is invoked. It fails when the models of any backend
+// which supports listing them couldn't be listed.
+func RunModels(cmd *cobra.Command, args []string) error {
+	timeout, _ := cmd.Flags().GetDuration(FlagTimeoutFull)
+	conf, backends, err := LoadBackends(cmd)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := RequestContext(cmd, 0)
+	defer cancel()
+	results := ListBackendModels(ctx, conf, backends, timeout)
+	PrintBackendModels(cmd.OutOrStdout(), results)
+	failed := 0
+	for _, result := range results {
+		if result.Err != nil && !errors.Is(result.Err, ErrModelsNotSupported) {
+			failed++
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("could not list the models of %d of %d backends", failed, len(results))
+	}
+	return nil
+}
+
+// ListBackendModels Lists the models of each of the backends, in order.
+// Listing the models of a backend fails when it doesn't respond within the timeout, unless the timeout is zero.
+func ListBackendModels(
+	ctx context.Context,
+	conf *config.Config,
+	backends []ai.Backend,
+	timeout time.Duration,
+) []BackendModels {
+	results := make([]BackendModels, len(backends))
+	for i, backend := range backends {
+		models, err := listModels(ctx, conf, backend, timeout)
+		results[i] = BackendModels{Backend: backend, Models: models, Err: err}
+	}
+	return results
+}
+
+// listModels Lists the models of the backend, within the timeout.
+func listModels(ctx context.Context, conf *config.Config, backend ai.Backend, timeout time.Duration) ([]string, error) {
+	logger.Debugf("listing the models of the %q backend\n", backend)
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	models, err := ListModels(ctx, conf, backend)
+	if err != nil {
+		return nil, CancellationError(ctx, timeout, err)
+	}
+	return models, nil
+}
+
+// ListModels Returns the models available from the backend, or ErrModelsNotSupported
+// when the backend has no API to list them. This must be called after SetDefaults.
+func ListModels(ctx context.Context, conf *config.Config, backend ai.Backend) ([]string, error) {
+	switch backend {
+	case ai.GPT3:
+		return gpt3.ListModels(ctx, *conf.OpenAI)
+	case ai.OLLAMA:
+		return ollama.ListModels(ctx, *conf.Ollama)
+	case ai.HUGGINGFACE:
+		return hfinference.ListModels(ctx, *conf.HuggingFace)
+	case ai.GPTJ, ai.BLOOM, ai.OPT, ai.CLAUDE, ai.COHERE, ai.GEMINI, ai.Unselected:
+	}
+	return nil, ErrModelsNotSupported
+}
+
+// PrintBackendModels Writes the models of each backend, one per line under the name of the backend,
+// or why they couldn't be listed next to it.
+func PrintBackendModels(out io.Writer, results []BackendModels) {
+	for _, result := range results {
+		switch {
+		case errors.Is(result.Err, ErrModelsNotSupported):
+			fmt.Fprintf(out, "%s: %s\n", result.Backend, result.Err)
+		case result.Err != nil:
+			fmt.Fprintf(out, "%s: FAILED: %s\n", result.Backend, result.Err)
+		case len(result.Models) == 0:
+			fmt.Fprintf(out, "%s: no models available\n", result.Backend)
+		default:
+			fmt.Fprintf(out, "%s:\n", result.Backend)
+			for _, model := range result.Models {
+				fmt.Fprintf(out, "  %s\n", model)
+			}
+		}
+	}
+}
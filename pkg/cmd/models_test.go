@@ -0,0 +1,90 @@
+package cmd_test
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/spf13/viper"
+
+	"github.com/redhat-et/copilot-ops/pkg/ai"
+	"github.com/redhat-et/copilot-ops/pkg/cmd"
+	"github.com/redhat-et/copilot-ops/pkg/cmd/config"
+)
+
+var _ = Describe("Models command", func() {
+	var out *bytes.Buffer
+
+	BeforeEach(func() {
+		// stub servers which list models the way each backend does
+		openAI := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path != "/v1/models" || r.Header.Get("Authorization") != "Bearer sk-test" {
+				http.NotFound(w, r)
+				return
+			}
+			fmt.Fprint(w, `{"data": [{"id": "gpt-4o"}, {"id": "gpt-4o-mini"}, {"id": "davinci-002"}]}`)
+		}))
+		DeferCleanup(openAI.Close)
+		ollama := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path != "/api/tags" {
+				http.NotFound(w, r)
+				return
+			}
+			fmt.Fprint(w, `{"models": [{"name": "llama3:8b"}, {"name": "codellama:latest"}]}`)
+		}))
+		DeferCleanup(ollama.Close)
+		hub := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path != "/api/models" || r.URL.Query().Get("pipeline_tag") != "text-generation" {
+				http.NotFound(w, r)
+				return
+			}
+			fmt.Fprint(w, `[{"id": "bigcode/starcoder"}, {"id": "bigscience/bloom"}]`)
+		}))
+		DeferCleanup(hub.Close)
+
+		wd, err := os.Getwd()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(os.Chdir(GinkgoT().TempDir())).To(Succeed())
+		DeferCleanup(os.Chdir, wd)
+		viper.Reset()
+		DeferCleanup(viper.Reset)
+		Expect(os.WriteFile(config.ConfigFile, []byte(fmt.Sprintf(
+			"openAI:\n  apiKey: sk-test\n  baseURL: %s/v1\n"+
+				"ollama:\n  url: %s\n"+
+				"huggingface:\n  hubURL: %s/api\n"+
+				"claude:\n  apiKey: sk-ant\n",
+			openAI.URL, ollama.URL, hub.URL,
+		)), 0600)).To(Succeed())
+		out = &bytes.Buffer{}
+	})
+
+	It("lists the models of every configured backend", func() {
+		c := cmd.NewModelsCmd()
+		c.SetOut(out)
+		Expect(cmd.RunModels(c, []string{})).To(Succeed())
+		Expect(out.String()).To(Equal("gpt-3:\n  davinci-002\n  gpt-4o\n  gpt-4o-mini\n" +
+			"claude: not supported\n" +
+			"ollama:\n  codellama:latest\n  llama3:8b\n" +
+			"huggingface:\n  bigcode/starcoder\n  bigscience/bloom\n"))
+	})
+
+	It("only lists the models of the backend given with --backend", func() {
+		c := cmd.NewModelsCmd()
+		c.SetOut(out)
+		Expect(c.Flags().Set(cmd.FlagAIBackendFull, string(ai.OLLAMA))).To(Succeed())
+		Expect(cmd.RunModels(c, []string{})).To(Succeed())
+		Expect(out.String()).To(Equal("ollama:\n  codellama:latest\n  llama3:8b\n"))
+	})
+
+	It("fails when a backend can't list its models", func() {
+		c := cmd.NewModelsCmd()
+		c.SetOut(out)
+		Expect(os.WriteFile(config.ConfigFile, []byte("ollama:\n  url: http://localhost:23423\n"), 0600)).To(Succeed())
+		Expect(cmd.RunModels(c, []string{})).To(MatchError("could not list the models of 1 of 1 backends"))
+		Expect(out.String()).To(HavePrefix("ollama: FAILED: could not list the models"))
+	})
+})
@@ -0,0 +1,125 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/redhat-et/copilot-ops/pkg/ai"
+	"github.com/redhat-et/copilot-ops/pkg/cmd/config"
+	"github.com/redhat-et/copilot-ops/pkg/filemap"
+)
+
+// NewValidateCmd Creates the `copilot-ops validate` CLI command.
+func NewValidateCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use: CommandValidate,
+
+		Short: "Checks the config and its filesets for problems",
+
+		Long: "Loads " + config.ConfigFile + " and reports every problem found in it at once, such as backends " +
+			"missing required settings, duplicate fileset names, and filesets which match no files or files which " +
+			"can't be read. It fails when any problem is found, so that it can be run before generations in CI.",
+
+		Example: `  copilot-ops validate
+  copilot-ops validate --path ./deploy`,
+
+		RunE: RunValidate,
+	}
+
+	cmd.Flags().StringP(
+		FlagPathFull, FlagPathShort, ".",
+		"Path to the root of the repo",
+	)
+
+	return cmd
+}
+
+// RunValidate Runs when the `validate` command is invoked. It prints every problem with the config
+// and fails when there are any.
+func RunValidate(cmd *cobra.Command, args []string) error {
+	path, _ := cmd.Flags().GetString(FlagPathFull)
+	if path != "" {
+		if err := os.Chdir(path); err != nil {
+			return err
+		}
+	}
+	conf := &config.Config{}
+	if err := conf.Load(); err != nil {
+		return err
+	}
+
+	problems := ValidateConfig(conf)
+	out := cmd.OutOrStdout()
+	if len(problems) == 0 {
+		fmt.Fprintf(out, "no problems found in %s\n", config.ConfigFile)
+		return nil
+	}
+	for _, problem := range problems {
+		fmt.Fprintf(out, "- %s\n", problem)
+	}
+	noun := "problems"
+	if len(problems) == 1 {
+		noun = "problem"
+	}
+	return fmt.Errorf("found %d %s in %s", len(problems), noun, config.ConfigFile)
+}
+
+// ValidateConfig Returns every problem with the loaded config: a default backend which can't be selected,
+// backends missing required settings, and filesets which are unnamed, defined more than once,
+// match no files, or include files which can't be read. Fileset patterns are resolved from the
+// working directory. This sets the defaults of the config, so it must be called before SetDefaults.
+func ValidateConfig(conf *config.Config) []error {
+	var problems []error
+
+	backends := conf.ConfiguredBackends()
+	selected, err := conf.SelectBackend()
+	if err != nil {
+		problems = append(problems, err)
+	} else if len(backends) == 0 {
+		// without any sections, the selected backend is set up from the defaults and the environment
+		backends = []ai.Backend{selected}
+	}
+	conf.SetDefaults()
+	for _, backend := range backends {
+		if missing := conf.MissingFields(backend); len(missing) > 0 {
+			problems = append(problems, fmt.Errorf("the %s backend is missing %s", backend, strings.Join(missing, ", ")))
+		}
+	}
+
+	return append(problems, validateFilesets(conf.Filesets)...)
+}
+
+// validateFilesets Returns every problem with the filesets, resolving each of them.
+func validateFilesets(filesets []config.Filesets) []error {
+	var problems []error
+	counts := make(map[string]int, len(filesets))
+	for _, fileset := range filesets {
+		counts[fileset.Name]++
+	}
+	for i, fileset := range filesets {
+		if fileset.Name == "" {
+			problems = append(problems, fmt.Errorf("fileset #%d has no name", i+1))
+		} else if counts[fileset.Name] > 1 {
+			// only reported at the first definition
+			problems = append(problems, fmt.Errorf("fileset %q is defined %d times", fileset.Name, counts[fileset.Name]))
+			counts[fileset.Name] = 0
+		}
+		matches, err := filemap.ResolveFileset(fileset)
+		if err != nil {
+			problems = append(problems, fmt.Errorf("could not resolve fileset %q: %w", fileset.Name, err))
+			continue
+		}
+		if len(matches) == 0 {
+			problems = append(problems, fmt.Errorf("fileset %q matches no files", fileset.Name))
+		}
+		for _, match := range matches {
+			if _, err = os.ReadFile(match); err != nil {
+				problems = append(problems, fmt.Errorf("fileset %q includes a file which can't be read: %w", fileset.Name, err))
+			}
+		}
+	}
+	return problems
+}
@@ -0,0 +1,74 @@
+package cmd_test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/spf13/viper"
+
+	"github.com/redhat-et/copilot-ops/pkg/cmd"
+	"github.com/redhat-et/copilot-ops/pkg/cmd/config"
+)
+
+var _ = Describe("Validate command", func() {
+	var out *bytes.Buffer
+
+	BeforeEach(func() {
+		wd, err := os.Getwd()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(os.Chdir(GinkgoT().TempDir())).To(Succeed())
+		DeferCleanup(os.Chdir, wd)
+		viper.Reset()
+		DeferCleanup(viper.Reset)
+		DeferCleanup(os.Setenv, config.OpenAIAPIKeyEnv, os.Getenv(config.OpenAIAPIKeyEnv))
+		Expect(os.Unsetenv(config.OpenAIAPIKeyEnv)).To(Succeed())
+
+		Expect(os.MkdirAll("manifests", 0755)).To(Succeed())
+		Expect(os.WriteFile(filepath.Join("manifests", "pod.yaml"), []byte("kind: Pod\n"), 0600)).To(Succeed())
+		out = &bytes.Buffer{}
+	})
+
+	run := func() error {
+		c := cmd.NewValidateCmd()
+		c.SetOut(out)
+		return cmd.RunValidate(c, []string{})
+	}
+
+	It("passes a valid config", func() {
+		Expect(os.WriteFile(config.ConfigFile, []byte("claude:\n  apiKey: sk-test\n"+
+			"filesets:\n- name: manifests\n  files: [manifests/*.yaml]\n"), 0600)).To(Succeed())
+		Expect(run()).To(Succeed())
+		Expect(out.String()).To(ContainSubstring("no problems found"))
+	})
+
+	It("reports every problem at once", func() {
+		// the pod's link points at a file which doesn't exist
+		Expect(os.Symlink("missing.yaml", filepath.Join("manifests", "broken.yaml"))).To(Succeed())
+		Expect(os.WriteFile(config.ConfigFile, []byte("defaultBackend: gpt-3\n"+
+			"openAI:\n  model: gpt-4o\nclaude:\n  apiKey: sk-test\nhuggingface:\n  apiKey: hf-test\n"+
+			"filesets:\n"+
+			"- name: manifests\n  files: [manifests/*.yaml]\n"+
+			"- name: charts\n  files: [charts/**/*.yaml]\n"+
+			"- name: manifests\n  files: [manifests/pod.yaml]\n"+
+			"- files: [manifests/pod.yaml]\n"), 0600)).To(Succeed())
+
+		Expect(run()).To(MatchError("found 6 problems in " + config.ConfigFile))
+		Expect(out.String()).To(ContainSubstring("- the gpt-3 backend is missing openAI.apiKey\n"))
+		Expect(out.String()).To(ContainSubstring("- the huggingface backend is missing huggingface.modelID\n"))
+		Expect(out.String()).To(ContainSubstring(`- fileset "manifests" is defined 2 times`))
+		Expect(out.String()).To(MatchRegexp(`- fileset "manifests" includes a file which can't be read: .*broken\.yaml`))
+		Expect(out.String()).To(ContainSubstring(`- fileset "charts" matches no files`))
+		Expect(out.String()).To(ContainSubstring("- fileset #4 has no name"))
+		Expect(out.String()).NotTo(ContainSubstring("claude"))
+	})
+
+	It("reports a default backend which isn't configured", func() {
+		Expect(os.WriteFile(config.ConfigFile, []byte("defaultBackend: cohere\nclaude:\n  apiKey: sk-test\n"),
+			0600)).To(Succeed())
+		Expect(run()).To(MatchError("found 1 problem in " + config.ConfigFile))
+		Expect(out.String()).To(ContainSubstring(`the default backend "cohere" isn't configured`))
+	})
+})
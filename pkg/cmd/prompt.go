@@ -0,0 +1,79 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"text/template"
+
+	"github.com/redhat-et/copilot-ops/pkg/ai/gpt3"
+	"github.com/redhat-et/copilot-ops/pkg/filemap"
+)
+
+// RedactedSecret Replaces any secret found in text which is printed.
+const RedactedSecret = "<redacted>"
+
+// PromptData Holds the values which can be referenced from the prompt templates
+// set in the config file.
+type PromptData struct {
+	// Request Is the user's natural-language request.
+	Request string
+	// EncodedFiles Contains the existing files included as context, separated by the Delimiter.
+	EncodedFiles string
+	// Delimiter Separates the files in EncodedFiles.
+	Delimiter string
+	// EndOfSequence Is the token which ends the generated YAML.
+	EndOfSequence string
+	// WithFiles Is true when existing files are included as context.
+	WithFiles bool
+	// YAMLOnly Is true when every file included as context is YAML.
+	YAMLOnly bool
+}
+
+// Terminator Returns the end-of-sequence terminator of the request, which defaults to gpt3.CompletionEndOfSequence.
+// The same terminator is asked for in the prompt, sent as the stop sequence, and ends the decoding.
+func (r *Request) Terminator() string {
+	if r.EndOfSequence == "" {
+		return gpt3.CompletionEndOfSequence
+	}
+	return r.EndOfSequence
+}
+
+// CheckEndOfSequence Returns an error when the terminator can't be told apart from the files,
+// since it's matched against whole lines of the output. An empty terminator stands for the default.
+func CheckEndOfSequence(endOfSequence string) error {
+	switch {
+	case endOfSequence == "":
+		return nil
+	case strings.TrimSpace(endOfSequence) != endOfSequence:
+		return errors.New("the terminator can't start or end with whitespace")
+	case strings.ContainsAny(endOfSequence, "\r\n"):
+		return errors.New("the terminator must fit on a single line")
+	case endOfSequence == filemap.FileDelimeter:
+		return errors.New("the terminator can't be the file delimiter")
+	}
+	return nil
+}
+
+// RenderPromptTemplate Executes the named prompt template with the given data.
+func RenderPromptTemplate(name, text string, data PromptData) (string, error) {
+	tmpl, err := template.New(name).Parse(text)
+	if err != nil {
+		return "", fmt.Errorf("could not parse the %s prompt template: %w", name, err)
+	}
+	var out strings.Builder
+	if err = tmpl.Execute(&out, data); err != nil {
+		return "", fmt.Errorf("could not render the %s prompt template: %w", name, err)
+	}
+	return out.String(), nil
+}
+
+// RedactSecrets Replaces every occurrence of the secrets in the text, so that it's safe to print.
+func RedactSecrets(text string, secrets []string) string {
+	for _, secret := range secrets {
+		if secret != "" {
+			text = strings.ReplaceAll(text, secret, RedactedSecret)
+		}
+	}
+	return text
+}
@@ -0,0 +1,104 @@
+package cmd_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	gogpt "github.com/sashabaranov/go-gpt3"
+	"github.com/spf13/viper"
+
+	"github.com/redhat-et/copilot-ops/pkg/ai"
+	"github.com/redhat-et/copilot-ops/pkg/cmd"
+	"github.com/redhat-et/copilot-ops/pkg/cmd/config"
+)
+
+var _ = Describe("Proxies", func() {
+	var proxy *httptest.Server
+	var hosts chan string
+
+	BeforeEach(func() {
+		// a stub proxy which answers for the backends itself, since their hosts don't exist
+		hosts = make(chan string, 10)
+		proxy = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			hosts <- r.Host
+			if r.Host == "openai.invalid" {
+				res, _ := json.Marshal(gogpt.CompletionResponse{Choices: []gogpt.CompletionChoice{{Text: "kind: Pod\n"}}})
+				fmt.Fprint(w, string(res))
+				return
+			}
+			fmt.Fprint(w, `[{"generated_text": "kind: Pod\n"}]`)
+		}))
+		DeferCleanup(proxy.Close)
+
+		wd, err := os.Getwd()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(os.Chdir(GinkgoT().TempDir())).To(Succeed())
+		DeferCleanup(os.Chdir, wd)
+		viper.Reset()
+		DeferCleanup(viper.Reset)
+		Expect(os.WriteFile(config.ConfigFile, []byte("defaultBackend: gpt-3\n"+
+			"gptj:\n  url: http://gptj.invalid\n"+
+			"bloom:\n  url: http://bloom.invalid/models/bigscience/bloom\n"), 0600)).To(Succeed())
+
+		for _, name := range []string{"HTTP_PROXY", "http_proxy", "NO_PROXY", "no_proxy"} {
+			if value, ok := os.LookupEnv(name); ok {
+				DeferCleanup(os.Setenv, name, value)
+			} else {
+				DeferCleanup(os.Unsetenv, name)
+			}
+			Expect(os.Unsetenv(name)).To(Succeed())
+		}
+	})
+
+	// generate Requests a completion from the backend, with the given flags.
+	generate := func(backend ai.Backend, flags map[string]string) error {
+		c := cmd.NewGenerateCmd()
+		Expect(c.Flags().Set(cmd.FlagOpenAIURLFull, "http://openai.invalid/v1")).To(Succeed())
+		Expect(c.Flags().Set(cmd.FlagRequestFull, "Create a Pod")).To(Succeed())
+		Expect(c.Flags().Set(cmd.FlagAIBackendFull, string(backend))).To(Succeed())
+		for name, value := range flags {
+			Expect(c.Flags().Set(name, value)).To(Succeed())
+		}
+		r, err := cmd.PrepareRequest(c)
+		if err != nil {
+			return err
+		}
+		client, err := cmd.PrepareGenerateClient(r, "ping")
+		Expect(err).NotTo(HaveOccurred())
+		_, err = client.Generate(context.Background())
+		return err
+	}
+
+	It("sends the requests of every backend through --proxy", func() {
+		for backend, host := range map[ai.Backend]string{
+			ai.GPT3:  "openai.invalid",
+			ai.GPTJ:  "gptj.invalid",
+			ai.BLOOM: "bloom.invalid",
+		} {
+			Expect(generate(backend, map[string]string{cmd.FlagProxyFull: proxy.URL})).To(Succeed(), string(backend))
+			Expect(hosts).To(Receive(Equal(host)))
+		}
+	})
+
+	It("uses the proxy from the environment", func() {
+		Expect(os.Setenv("HTTP_PROXY", proxy.URL)).To(Succeed())
+		Expect(generate(ai.GPTJ, nil)).To(Succeed())
+		Expect(hosts).To(Receive(Equal("gptj.invalid")))
+
+		Expect(os.Setenv("NO_PROXY", "gptj.invalid")).To(Succeed())
+		Expect(generate(ai.GPTJ, nil)).NotTo(Succeed())
+		Expect(hosts).NotTo(Receive())
+	})
+
+	It("rejects an invalid proxy", func() {
+		Expect(generate(ai.GPTJ, map[string]string{cmd.FlagProxyFull: "proxy.example.com:3128"})).To(
+			MatchError(ContainSubstring("could not use --" + cmd.FlagProxyFull)),
+		)
+	})
+})
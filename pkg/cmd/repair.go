@@ -0,0 +1,63 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/redhat-et/copilot-ops/pkg/ai"
+	"github.com/redhat-et/copilot-ops/pkg/filemap"
+	"github.com/redhat-et/copilot-ops/pkg/logger"
+)
+
+// DefaultMaxRepairAttempts Is how many times the model is asked to reformat output which couldn't be decoded.
+const DefaultMaxRepairAttempts = 2
+
+// RepairPrompt Returns a prompt asking the model to reformat output which couldn't be decoded
+// into the structure that the files are decoded from, without changing their content.
+func RepairPrompt(output string, decodeErr error, endOfSequence string) string {
+	return fmt.Sprintf(`## This document contains output which could not be decoded into files, followed by the same output reformatted.
+## The output could not be decoded: %s
+##
+## In the reformatted output, each file starts with a line '# %s<path>' naming the file,
+## and the files are separated by a line containing '%s'. Only the formatting changes:
+## the content of every file is kept as it was, and any text which isn't part of a file is left out.
+## The reformatted output is terminated by an '%s'.
+
+## 1. The output:
+%s
+
+## 2. The reformatted output:
+`, decodeErr, filemap.FileTagPrefix, filemap.FileDelimeter, endOfSequence, strings.TrimSpace(output))
+}
+
+// RepairCompletion Asks the request's backend to reformat a completion which failed to decode,
+// up to --max-repair-attempts times, returning the first reformatted completion which decodes.
+// Each attempt starts from the original completion, since a failed repair is rarely closer to the format.
+func RepairCompletion(ctx context.Context, r *Request, choice string, decodeErr error) (string, error) {
+	// only a single completion is needed for each repair
+	single := *r
+	single.NCompletions = 1
+	for attempt := 1; attempt <= r.MaxRepairAttempts; attempt++ {
+		logger.Warnf("could not decode the output (%s), asking the model to reformat it, attempt %d of %d\n",
+			decodeErr, attempt, r.MaxRepairAttempts)
+		client, err := PrepareGenerateClient(&single, RepairPrompt(choice, decodeErr, r.Terminator()))
+		if err != nil {
+			return "", fmt.Errorf("could not create client: %w", err)
+		}
+		outputs, err := ai.RetryGenerate(ctx, client, r.Retry)
+		if err != nil {
+			return "", fmt.Errorf("could not repair the output: %w", err)
+		}
+		if len(outputs) == 0 {
+			continue
+		}
+		repaired := filemap.StripCodeFences(ai.StripReasoning(outputs[0]))
+		fm := filemap.NewFilemap()
+		fm.UseEndOfSequence(r.Terminator())
+		if decodeErr = fm.DecodeFromOutput(repaired); decodeErr == nil {
+			return repaired, nil
+		}
+	}
+	return "", fmt.Errorf("the output could not be decoded after %d repair attempts: %w", r.MaxRepairAttempts, decodeErr)
+}
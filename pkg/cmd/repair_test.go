@@ -0,0 +1,76 @@
+package cmd_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	gogpt "github.com/sashabaranov/go-gpt3"
+
+	"github.com/redhat-et/copilot-ops/pkg/ai"
+	"github.com/redhat-et/copilot-ops/pkg/ai/gpt3"
+	"github.com/redhat-et/copilot-ops/pkg/cmd"
+	"github.com/redhat-et/copilot-ops/pkg/cmd/config"
+	"github.com/redhat-et/copilot-ops/pkg/filemap"
+)
+
+var _ = Describe("Repairing output", func() {
+	const malformed = "Sure! Here is the Pod you asked for:\nkind: Pod\n"
+	var (
+		r         *cmd.Request
+		responses []string
+		prompts   []string
+	)
+
+	BeforeEach(func() {
+		responses, prompts = nil, nil
+		// a backend which answers each repair with the next response
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			var body gogpt.CompletionRequest
+			Expect(json.NewDecoder(req.Body).Decode(&body)).To(Succeed())
+			prompts = append(prompts, body.Prompt)
+			text := responses[0]
+			responses = responses[1:]
+			Expect(json.NewEncoder(w).Encode(gogpt.CompletionResponse{
+				Choices: []gogpt.CompletionChoice{{Text: text}},
+			})).To(Succeed())
+		}))
+		DeferCleanup(server.Close)
+
+		r = &cmd.Request{
+			Backend:           ai.GPT3,
+			Config:            config.Config{OpenAI: &gpt3.Config{BaseURL: server.URL + gpt3.OpenAIEndpointV1}},
+			NTokens:           64,
+			NCompletions:      3,
+			MaxRepairAttempts: 2,
+			OutputType:        filemap.OutputPlain,
+		}
+	})
+
+	It("decodes the output once the model reformats it", func() {
+		responses = []string{"# @pod.yaml\nkind: Pod\n"}
+		Expect(cmd.DecodeAndOutput(context.Background(), r, []string{malformed})).To(Succeed())
+		Expect(r.Filemap.Files).To(HaveLen(1))
+		Expect(r.Filemap.Files["pod.yaml"].Content).To(Equal("kind: Pod\n"))
+
+		Expect(prompts).To(HaveLen(1))
+		Expect(prompts[0]).To(ContainSubstring("## 1. The output:\n" + malformed))
+		Expect(prompts[0]).To(ContainSubstring("'# " + filemap.FileTagPrefix + "<path>'"))
+	})
+
+	It("writes the output as-is once the repairs are exhausted", func() {
+		responses = []string{malformed, "still not a file"}
+		Expect(cmd.DecodeAndOutput(context.Background(), r, []string{malformed})).To(Succeed())
+		Expect(prompts).To(HaveLen(2))
+		Expect(r.Filemap.Files).To(HaveKey(cmd.DefaultOutputDir + "/generated-by-copilot-ops1.yaml"))
+	})
+
+	It("doesn't ask for repairs when they're disabled", func() {
+		r.MaxRepairAttempts = 0
+		Expect(cmd.DecodeAndOutput(context.Background(), r, []string{malformed})).To(Succeed())
+		Expect(prompts).To(BeEmpty())
+	})
+})
@@ -2,6 +2,7 @@
 package cmd
 
 import (
+	"github.com/redhat-et/copilot-ops/pkg/ai"
 	fm "github.com/redhat-et/copilot-ops/pkg/filemap"
 )
 
@@ -13,6 +14,90 @@ type Result struct {
 	Result   *[]fm.Filemap `json:"result"`
 }
 
+// GenerateResult Is the document printed with '--output json', describing the files
+// which were produced and how they were generated.
+type GenerateResult struct {
+	// Backend Is the AI backend which produced the files.
+	Backend ai.Backend `json:"backend"`
+	// Completions Is the number of completions returned by the backend.
+	Completions int `json:"completions"`
+	// Files Maps the path of each file to its content.
+	Files map[string]string `json:"files"`
+}
+
+// NewGenerateResult Describes the files in the request's filemap. Files without
+// a path are keyed by their tag instead.
+func NewGenerateResult(r *Request) GenerateResult {
+	result := GenerateResult{
+		Backend:     r.Backend,
+		Completions: r.Completions,
+		Files:       make(map[string]string, len(r.Filemap.Files)),
+	}
+	for tag, file := range r.Filemap.Files {
+		path := file.Path
+		if path == "" {
+			path = tag
+		}
+		result.Files[path] = file.Content
+	}
+	return result
+}
+
+// GenerateResponse Is the document printed with '--output json-full', describing the whole generation:
+// the request and how it was sent, the raw completions, and what they were decoded into.
+type GenerateResponse struct {
+	// Request Is the user's request.
+	Request string `json:"request"`
+	// Backend Is the AI backend which produced the completions.
+	Backend ai.Backend `json:"backend"`
+	// Model Is the model of the backend, or the backend itself when it has a single model.
+	Model string `json:"model"`
+	// Parameters Are the generation settings sent to the backend.
+	Parameters GenerateParameters `json:"parameters"`
+	// Choices Are the completions as the backend returned them.
+	Choices []string `json:"choices"`
+	// Files Are the decoded files, by their tag.
+	Files map[string]fm.File `json:"files"`
+	// DecodeError Explains why the completions couldn't be decoded, in which case each of them
+	// was placed in a new file as-is. It's empty when the completions were decoded.
+	DecodeError string `json:"decodeError"`
+}
+
+// GenerateParameters Are the generation settings of a request.
+type GenerateParameters struct {
+	NTokens      int32    `json:"ntokens"`
+	NCompletions int32    `json:"ncompletions"`
+	Temperature  float32  `json:"temperature"`
+	TopP         *float32 `json:"topP,omitempty"`
+	SystemPrompt string   `json:"systemPrompt,omitempty"`
+}
+
+// NewGenerateResponse Describes the generation of the request, along with the files in its filemap.
+func NewGenerateResponse(r *Request) GenerateResponse {
+	response := GenerateResponse{
+		Request: r.UserRequest,
+		Backend: r.Backend,
+		Model:   pricedModel(r),
+		Parameters: GenerateParameters{
+			NTokens:      r.NTokens,
+			NCompletions: r.NCompletions,
+			Temperature:  r.Temperature,
+			TopP:         r.TopP,
+			SystemPrompt: r.SystemPrompt,
+		},
+		Choices:     r.RawCompletions,
+		Files:       map[string]fm.File{},
+		DecodeError: r.DecodeError,
+	}
+	if response.Choices == nil {
+		response.Choices = []string{}
+	}
+	if r.Filemap != nil {
+		response.Files = r.Filemap.Files
+	}
+	return response
+}
+
 // Error represents an error or warning from within the program
 // which has taken place during the execution of the CLI.
 type Error struct {
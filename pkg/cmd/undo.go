@@ -0,0 +1,65 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/redhat-et/copilot-ops/pkg/journal"
+	"github.com/redhat-et/copilot-ops/pkg/logger"
+)
+
+// NewUndoCmd Creates the `copilot-ops undo` CLI command.
+func NewUndoCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use: CommandUndo,
+
+		Short: "Reverts the files written by the last run",
+
+		Long: "Restores the previous content of every file written by the last run with --" + FlagWriteFull +
+			", and deletes the files which it created.",
+
+		Example: `  copilot-ops undo`,
+
+		RunE: RunUndo,
+	}
+
+	cmd.Flags().StringP(
+		FlagPathFull, FlagPathShort, ".",
+		"Path to the root of the repo",
+	)
+
+	cmd.Flags().Bool(
+		FlagForceFull, false,
+		"Undo the run even if the files were modified since",
+	)
+
+	return cmd
+}
+
+// RunUndo Runs when the `undo` command is invoked.
+func RunUndo(cmd *cobra.Command, args []string) error {
+	root, _ := cmd.Flags().GetString(FlagPathFull)
+	force, _ := cmd.Flags().GetBool(FlagForceFull)
+
+	j, err := journal.Load(root)
+	if err != nil {
+		return err
+	}
+	err = j.Undo(root, force)
+	if errors.Is(err, journal.ErrModified) {
+		return fmt.Errorf("could not undo the last run: %w (pass --%s to undo it anyway)", err, FlagForceFull)
+	}
+	if err != nil {
+		return fmt.Errorf("could not undo the last run: %w", err)
+	}
+	for _, entry := range j.Files {
+		if entry.Created {
+			logger.Infof("removed %s\n", entry.Path)
+		} else {
+			logger.Infof("restored %s\n", entry.Path)
+		}
+	}
+	return nil
+}
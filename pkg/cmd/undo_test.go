@@ -0,0 +1,54 @@
+package cmd_test
+
+import (
+	"context"
+	"os"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/redhat-et/copilot-ops/pkg/cmd"
+	"github.com/redhat-et/copilot-ops/pkg/filemap"
+	"github.com/redhat-et/copilot-ops/pkg/journal"
+)
+
+var _ = Describe("Undo command", func() {
+	BeforeEach(func() {
+		wd, err := os.Getwd()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(os.Chdir(GinkgoT().TempDir())).To(Succeed())
+		DeferCleanup(os.Chdir, wd)
+
+		// generate a change to an existing file and a new file
+		Expect(os.WriteFile("deployment.yaml", []byte("kind: Deployment\nspec:\n  replicas: 1\n"), 0600)).To(Succeed())
+		fm := filemap.NewFilemap()
+		fm.Files["deployment"] = filemap.File{Path: "deployment.yaml", Content: "kind: Deployment\n"}
+		fm.Files["service"] = filemap.File{Path: "manifests/service.yaml", Content: "kind: Service\n"}
+		Expect(cmd.PrintOrWriteOut(context.Background(), &cmd.Request{Filemap: fm, IsWrite: true})).To(Succeed())
+		Expect(os.ReadFile("deployment.yaml")).To(Equal([]byte("kind: Deployment\n")))
+	})
+
+	It("restores the files written by the last run", func() {
+		c := cmd.NewUndoCmd()
+		Expect(cmd.RunUndo(c, []string{})).To(Succeed())
+		Expect(os.ReadFile("deployment.yaml")).To(Equal([]byte("kind: Deployment\nspec:\n  replicas: 1\n")))
+		Expect("manifests/service.yaml").NotTo(BeAnExistingFile())
+
+		// the run can only be undone once
+		Expect(cmd.RunUndo(c, []string{})).To(MatchError(journal.ErrNoRun))
+	})
+
+	It("refuses to undo files modified since the run without --force", func() {
+		Expect(os.WriteFile("manifests/service.yaml", []byte("kind: Service\n# edited\n"), 0600)).To(Succeed())
+		c := cmd.NewUndoCmd()
+		err := cmd.RunUndo(c, []string{})
+		Expect(err).To(MatchError(ContainSubstring("manifests/service.yaml")))
+		Expect(err).To(MatchError(ContainSubstring("--" + cmd.FlagForceFull)))
+		Expect("manifests/service.yaml").To(BeAnExistingFile())
+
+		Expect(c.Flags().Set(cmd.FlagForceFull, "true")).To(Succeed())
+		Expect(cmd.RunUndo(c, []string{})).To(Succeed())
+		Expect(os.ReadFile("deployment.yaml")).To(Equal([]byte("kind: Deployment\nspec:\n  replicas: 1\n")))
+		Expect("manifests/service.yaml").NotTo(BeAnExistingFile())
+	})
+})
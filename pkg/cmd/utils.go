@@ -1,14 +1,35 @@
 package cmd
 
 import (
-	"log"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
 	"os"
+	"regexp"
+	"sort"
+	"strconv"
 	"strings"
+	"text/template"
+	"time"
 
 	"github.com/redhat-et/copilot-ops/pkg/ai"
+	"github.com/redhat-et/copilot-ops/pkg/ai/embeddings"
 	"github.com/redhat-et/copilot-ops/pkg/ai/gpt3"
 	"github.com/redhat-et/copilot-ops/pkg/cmd/config"
 	"github.com/redhat-et/copilot-ops/pkg/filemap"
+	"github.com/redhat-et/copilot-ops/pkg/helm"
+	"github.com/redhat-et/copilot-ops/pkg/history"
+	"github.com/redhat-et/copilot-ops/pkg/journal"
+	"github.com/redhat-et/copilot-ops/pkg/logger"
+	"github.com/redhat-et/copilot-ops/pkg/recording"
+	"github.com/redhat-et/copilot-ops/pkg/snapshot"
+	"github.com/redhat-et/copilot-ops/pkg/spec"
+	"github.com/redhat-et/copilot-ops/pkg/tokenizer"
+	"github.com/redhat-et/copilot-ops/pkg/utils"
 	"github.com/spf13/cobra"
 )
 
@@ -16,27 +37,171 @@ import (
 // AI backends.
 // FIXME: consolidate the settings depending on the type of Model. E.g., OpenAI settings should be under their own.
 type Request struct {
-	Config       config.Config
-	Fileset      *config.Filesets
-	Filemap      *filemap.Filemap
-	FilemapText  string
+	Config      config.Config
+	Fileset     *config.Filesets
+	Filemap     *filemap.Filemap
+	FilemapText string
+	// SnapshotText Contains the encoded files of the snapshot included as context, if any.
+	SnapshotText string
 	UserRequest  string
 	IsWrite      bool
 	OutputType   string
 	OpenAIURL    string
 	NTokens      int32
 	NCompletions int32
+	// ReasoningTokens Is the budget for reasoning tokens, separate from NTokens,
+	// for backends which support reasoning models.
+	ReasoningTokens int32
 	// Backend Sepecifies which type of AI Backend to use.
 	Backend ai.Backend
+	// Select Is the completion which should be used, starting from 1, or 0 if none was selected.
+	Select int32
+	// CompletionStrategy Decides how multiple completions are handled when none was selected.
+	CompletionStrategy string
+	// ContextSummary Is a paragraph describing the overall repo, placed once at the top of the prompt.
+	ContextSummary string
+	// Kustomize Fits the generated files into the kustomizations which manage them.
+	Kustomize bool
+	// KustomizeContext Describes the kustomizations of the files in the prompt, if any.
+	KustomizeContext string
+	// CompletionFilter Keeps only the completions whose decoded content matches, if set.
+	CompletionFilter *regexp.Regexp
+	// CompletionReject Discards the completions whose decoded content matches, if set.
+	CompletionReject *regexp.Regexp
+	// PerNamespaceDirs Places each generated file under a directory named after its namespace.
+	PerNamespaceDirs bool
+	// PreserveBlankLines Restores the original blank lines around unchanged regions of edited files.
+	PreserveBlankLines bool
+	// Stream Prints completions as they are generated, for backends which support it.
+	Stream bool
+	// Completions Is the number of completions the backend returned.
+	Completions int
+	// RawCompletions Are the completions as the backend returned them, before they were decoded.
+	RawCompletions []string
+	// DecodeError Explains why the completions couldn't be decoded into files, in which case they were
+	// placed in new files as-is. It's empty when they were decoded.
+	DecodeError string
+	// Temperature Is the sampling temperature passed to the backend.
+	Temperature float32
+	// TopP Is the nucleus sampling probability mass, or nil to use the backend's default.
+	TopP *float32
+	// Seed Makes the backends which support it sample deterministically, or nil to use a random seed.
+	Seed *int
+	// Structured Makes OpenAI's chat models pass the files to a function rather than writing them out as text.
+	Structured bool
+	// Concurrency Limits how many requests are made to the backend at once.
+	Concurrency int
+	// ShowPrompt Prints the prompt to STDERR before it is sent.
+	ShowPrompt bool
+	// PromptOnly Prints the prompt to STDOUT instead of sending it.
+	PromptOnly bool
+	// CountOnly Prints the estimated tokens and cost of the prompt instead of sending it.
+	CountOnly bool
+	// ShowCost Prints what the generation cost once it's done.
+	ShowCost bool
+	// Explain Asks the backend for a summary of the changes, which is printed before the output.
+	Explain bool
+	// SystemPrompt Is sent as the system message to chat models, and prepended to the prompt of other models.
+	SystemPrompt string
+	// EndOfSequence Is the terminator which the model is asked to end the generated files with, and which
+	// ends the decoding of its output. Use Terminator to read it, since it's empty when the default is used.
+	EndOfSequence string
+	// Stop Are extra sequences which the backend stops the completion at.
+	Stop []string
+	// ShowUsage Prints how many tokens the generation used once it's done.
+	ShowUsage bool
+	// NoHistory Skips recording the generation in the history of the repo.
+	NoHistory bool
+	// FromCache Is set when the completions were loaded from the cache rather than generated.
+	FromCache bool
+	// TrimStrategy Is how the context files are trimmed when the prompt doesn't fit
+	// the context window, or empty to fail instead.
+	TrimStrategy string
+	// CacheDir Is the directory where completions are cached, or empty to disable the cache.
+	CacheDir string
+	// CacheTTL Is how long cached completions are used for.
+	CacheTTL time.Duration
+	// NoCache Skips looking up cached completions, while still caching the new ones.
+	NoCache bool
+	// OutputDir Is the directory which generated files are placed in, if any.
+	OutputDir string
+	// GeneratedName Is the name of the files made from output which can't be decoded, in which
+	// GeneratedNameCounter is replaced with the number of the completion. When it's empty, the files are
+	// named after the resource in them, or DefaultGeneratedName.
+	GeneratedName string
+	// Combine Is the path of the multi-document YAML file which the generated files are combined into, if any.
+	Combine string
+	// Namespace Is set on every namespaced resource which is generated, if any.
+	Namespace string
+	// Labels Are added to every generated resource.
+	Labels map[string]string
+	// Annotations Are added to every generated resource.
+	Annotations map[string]string
+	// OverwriteMetadata Replaces the labels and annotations which the generated resources already have.
+	OverwriteMetadata bool
+	// Format Re-indents the generated YAML files consistently before they are output.
+	Format bool
+	// GitBranch Is the branch which the written files are committed to, if any.
+	GitBranch string
+	// PostHook Is the shell command run after the files are written, if any.
+	PostHook string
+	// Apply Runs `kubectl apply` over the written manifests.
+	Apply bool
+	// KubeContext Is the kube context which the manifests are applied with, if any.
+	KubeContext string
+	// RunCommand Runs kubectl when the manifests are applied, ExecCommand when it isn't set.
+	RunCommand CommandRunner
+	// OnConflict Is the policy for writing files which already exist, one of the ConflictPolicy values.
+	OnConflict string
+	// Validate Refuses to output generated files which aren't valid Kubernetes manifests,
+	// instead of only warning about them.
+	Validate bool
+	// DryRun Prints a diff of the changes against the files on disk instead of writing them.
+	DryRun bool
+	// Review Prints the changes grouped by Kubernetes resource against the files on disk instead of writing them.
+	Review bool
+	// ExitCode Fails the run with ErrChanges when the diff or review isn't empty, so that CI can detect changes.
+	ExitCode bool
+	// Interactive Asks whether to write each file before writing it.
+	Interactive bool
+	// FileMode Is the permissions every file is written with, if set.
+	// Otherwise existing files keep their permissions and new ones get filemap.DefaultFileMode.
+	FileMode os.FileMode
+	// Patch Applies only the changes made to the files given as context on top of their current content on disk,
+	// instead of overwriting them, so that edits made since they were loaded are kept.
+	Patch bool
+	// In Is where the answers to interactive questions are read from.
+	In io.Reader
+	// ErrOut Is where interactive questions are asked.
+	ErrOut io.Writer
+	// FallbackBackends Are tried in order when Backend is unavailable.
+	FallbackBackends []ai.Backend
+	// Retry Configures how requests to the backend are retried after transient failures.
+	Retry ai.RetryOptions
+	// MaxRepairAttempts Is how many times the model is asked to reformat output which can't be decoded.
+	MaxRepairAttempts int
+	// Timeout Is how long to wait on the backend before the requests are aborted, or zero to wait indefinitely.
+	Timeout time.Duration
+	// SaveSnapshot Is the name under which the output should be saved as a snapshot, if any.
+	SaveSnapshot string
+	// Tokenizer Counts tokens for the selected backend.
+	Tokenizer tokenizer.Tokenizer
+	// Spec Contains the sections of the spec file, if one was provided.
+	Spec *spec.Spec
+	// HelmChart Is the chart whose values are generated, instead of Kubernetes YAML.
+	HelmChart *helm.Chart
 }
 
 // PrepareRequest Processes the user input along with provided environment variables,
 // creating a Request object which is used for context in further requests.
 func PrepareRequest(cmd *cobra.Command) (*Request, error) {
 	request, _ := cmd.Flags().GetString(FlagRequestFull)
+	requestFile, _ := cmd.Flags().GetString(FlagRequestFileFull)
 	write, _ := cmd.Flags().GetBool(FlagWriteFull)
 	path, _ := cmd.Flags().GetString(FlagPathFull)
 	files, _ := cmd.Flags().GetStringArray(FlagFilesFull)
+	stdinFile, _ := cmd.Flags().GetString(FlagStdinFileFull)
+	fileHeaders, _ := cmd.Flags().GetStringArray(FlagFileHeaderFull)
 	if cmd.Name() == CommandEdit {
 		file, _ := cmd.Flags().GetString(FlagFilesFull)
 		files = append(files, file)
@@ -44,22 +209,265 @@ func PrepareRequest(cmd *cobra.Command) (*Request, error) {
 	filesets, _ := cmd.Flags().GetStringArray(FlagFilesetsFull)
 	nTokens, _ := cmd.Flags().GetInt32(FlagNTokensFull)
 	nCompletions, _ := cmd.Flags().GetInt32(FlagNCompletionsFull)
+	reasoningTokens, _ := cmd.Flags().GetInt32(FlagReasoningTokensFull)
 	outputType, _ := cmd.Flags().GetString(FlagOutputTypeFull)
 	openAIURL, _ := cmd.Flags().GetString(FlagOpenAIURLFull)
 	aiBackend, _ := cmd.Flags().GetString(FlagAIBackendFull)
+	fallbackBackends, _ := cmd.Flags().GetStringSlice(FlagFallbackBackendsFull)
+	specFile, _ := cmd.Flags().GetString(FlagSpecFileFull)
+	helmChart, _ := cmd.Flags().GetString(FlagHelmChartFull)
+	selection, _ := cmd.Flags().GetInt32(FlagSelectFull)
+	completionStrategy, _ := cmd.Flags().GetString(FlagCompletionStrategyFull)
+	contextSummary, _ := cmd.Flags().GetString(FlagContextSummaryFull)
+	contextSummaryFile, _ := cmd.Flags().GetString(FlagContextSummaryFileFull)
+	saveSnapshot, _ := cmd.Flags().GetString(FlagSaveSnapshotFull)
+	fromSnapshot, _ := cmd.Flags().GetString(FlagFromSnapshotFull)
+	completionFilter, _ := cmd.Flags().GetString(FlagCompletionFilterFull)
+	completionReject, _ := cmd.Flags().GetString(FlagCompletionRejectFull)
+	noExpandEnv, _ := cmd.Flags().GetBool(FlagNoExpandEnvFull)
+	templateRequest, _ := cmd.Flags().GetBool(FlagTemplateRequestFull)
+	varFlags, _ := cmd.Flags().GetStringArray(FlagVarFull)
+	perNamespaceDirs, _ := cmd.Flags().GetBool(FlagPerNamespaceDirsFull)
+	preserveBlankLines, _ := cmd.Flags().GetBool(FlagPreserveBlankLinesFull)
+	record, _ := cmd.Flags().GetString(FlagRecordFull)
+	replay, _ := cmd.Flags().GetString(FlagReplayFull)
+	stream, _ := cmd.Flags().GetBool(FlagStreamFull)
+	maxRetries, _ := cmd.Flags().GetInt(FlagMaxRetriesFull)
+	maxRepairAttempts, _ := cmd.Flags().GetInt(FlagMaxRepairAttemptsFull)
+	retryBaseDelay, _ := cmd.Flags().GetDuration(FlagRetryBaseDelayFull)
+	timeout, _ := cmd.Flags().GetDuration(FlagTimeoutFull)
+	autoContext, _ := cmd.Flags().GetBool(FlagAutoContextFull)
+	contextFiles, _ := cmd.Flags().GetInt(FlagContextFilesFull)
+	noContext, _ := cmd.Flags().GetBool(FlagNoContextFull)
+	dryRun, _ := cmd.Flags().GetBool(FlagDryRunFull)
+	review, _ := cmd.Flags().GetBool(FlagReviewFull)
+	exitCode, _ := cmd.Flags().GetBool(FlagExitCodeFull)
+	interactive, _ := cmd.Flags().GetBool(FlagInteractiveFull)
+	fileModeFlag, _ := cmd.Flags().GetString(FlagFileModeFull)
+	patch, _ := cmd.Flags().GetBool(FlagPatchFull)
+	kustomizeFiles, _ := cmd.Flags().GetBool(FlagKustomizeFull)
+	hfModel, _ := cmd.Flags().GetString(FlagHFModelFull)
+	temperature, _ := cmd.Flags().GetFloat32(FlagTemperatureFull)
+	concurrency, _ := cmd.Flags().GetInt(FlagConcurrencyFull)
+	validate, _ := cmd.Flags().GetBool(FlagValidateFull)
+	structured, _ := cmd.Flags().GetBool(FlagStructuredFull)
+	gitBranch, _ := cmd.Flags().GetString(FlagGitBranchFull)
+	postHook, _ := cmd.Flags().GetString(FlagPostHookFull)
+	apply, _ := cmd.Flags().GetBool(FlagApplyFull)
+	kubeContext, _ := cmd.Flags().GetString(FlagKubeContextFull)
+	onConflict, _ := cmd.Flags().GetString(FlagOnConflictFull)
+	outputDir, _ := cmd.Flags().GetString(FlagOutputDirFull)
+	generatedName, _ := cmd.Flags().GetString(FlagGeneratedNameFull)
+	combine, _ := cmd.Flags().GetString(FlagCombineFull)
+	format, _ := cmd.Flags().GetBool(FlagFormatFull)
+	namespace, _ := cmd.Flags().GetString(FlagNamespaceFull)
+	labelFlags, _ := cmd.Flags().GetStringArray(FlagLabelFull)
+	annotationFlags, _ := cmd.Flags().GetStringArray(FlagAnnotationFull)
+	overwriteMetadata, _ := cmd.Flags().GetBool(FlagOverwriteMetadataFull)
+	insecureSkipVerify, _ := cmd.Flags().GetBool(FlagInsecureSkipVerifyFull)
+	proxy, _ := cmd.Flags().GetString(FlagProxyFull)
+	rateLimit, _ := cmd.Flags().GetFloat64(FlagRateLimitFull)
+	cacheDir, _ := cmd.Flags().GetString(FlagCacheDirFull)
+	noCache, _ := cmd.Flags().GetBool(FlagNoCacheFull)
+	cacheTTL, _ := cmd.Flags().GetDuration(FlagCacheTTLFull)
+	trimStrategy, _ := cmd.Flags().GetString(FlagTrimStrategyFull)
+	showPrompt, _ := cmd.Flags().GetBool(FlagShowPromptFull)
+	promptOnly, _ := cmd.Flags().GetBool(FlagPromptOnlyFull)
+	countOnly, _ := cmd.Flags().GetBool(FlagCountOnlyFull)
+	showCost, _ := cmd.Flags().GetBool(FlagShowCostFull)
+	showUsage, _ := cmd.Flags().GetBool(FlagShowUsageFull)
+	noHistory, _ := cmd.Flags().GetBool(FlagNoHistoryFull)
+	explain, _ := cmd.Flags().GetBool(FlagExplainFull)
+	systemPrompt, _ := cmd.Flags().GetString(FlagSystemPromptFull)
+	endOfSequence, _ := cmd.Flags().GetString(FlagEndOfSequenceFull)
+	stop, _ := cmd.Flags().GetStringArray(FlagStopFull)
+	noGitignore, _ := cmd.Flags().GetBool(FlagNoGitignoreFull)
+	maxFileSize, _ := cmd.Flags().GetInt64(FlagMaxFileSizeFull)
+	profileName, _ := cmd.Flags().GetString(FlagProfileFull)
+	model, _ := cmd.Flags().GetString(FlagModelFull)
+	var topP *float32
+	if cmd.Flags().Changed(FlagTopPFull) {
+		value, _ := cmd.Flags().GetFloat32(FlagTopPFull)
+		topP = &value
+	}
+	var seed *int
+	if cmd.Flags().Changed(FlagSeedFull) {
+		value, _ := cmd.Flags().GetInt(FlagSeedFull)
+		seed = &value
+	}
+
+	// answers can't be read from STDIN when the request is
+	if interactive && (request == StdinRequest || !IsTerminal(cmd.InOrStdin())) {
+		return nil, fmt.Errorf("--%s needs a terminal to read answers from, write without it instead", FlagInteractiveFull)
+	}
+
+	// read the request from STDIN or a file when asked to
+	if request == StdinRequest {
+		var err error
+		if request, err = ReadRequest(cmd.InOrStdin()); err != nil {
+			return nil, err
+		}
+	}
+	if requestFile != "" {
+		var err error
+		if request, err = ReadRequestFile(requestFile); err != nil {
+			return nil, err
+		}
+	}
+
+	logger.Debugf("flags:")
+	logger.Debugf(" - %-8s: %v\n", FlagRequestFull, request)
+	logger.Debugf(" - %-8s: %q\n", FlagRequestFileFull, requestFile)
+	logger.Debugf(" - %-8s: %v\n", FlagWriteFull, write)
+	logger.Debugf(" - %-8s: %v\n", FlagPathFull, path)
+	logger.Debugf(" - %-8s: %v\n", FlagFilesFull, files)
+	logger.Debugf(" - %-8s: %q\n", FlagStdinFileFull, stdinFile)
+	// the values of the headers are left out, since they tend to be secrets
+	logger.Debugf(" - %-8s: %d headers\n", FlagFileHeaderFull, len(fileHeaders))
+	logger.Debugf(" - %-8s: %v\n", FlagFilesetsFull, filesets)
+	logger.Debugf(" - %-8s: %v\n", FlagNTokensFull, nTokens)
+	logger.Debugf(" - %-8s: %v\n", FlagNCompletionsFull, nCompletions)
+	logger.Debugf(" - %-8s: %v\n", FlagReasoningTokensFull, reasoningTokens)
+	logger.Debugf(" - %-8s: %v\n", FlagOutputTypeFull, outputType)
 
-	log.Println("flags:")
-	log.Printf(" - %-8s: %v\n", FlagRequestFull, request)
-	log.Printf(" - %-8s: %v\n", FlagWriteFull, write)
-	log.Printf(" - %-8s: %v\n", FlagPathFull, path)
-	log.Printf(" - %-8s: %v\n", FlagFilesFull, files)
-	log.Printf(" - %-8s: %v\n", FlagFilesetsFull, filesets)
-	log.Printf(" - %-8s: %v\n", FlagNTokensFull, nTokens)
-	log.Printf(" - %-8s: %v\n", FlagNCompletionsFull, nCompletions)
-	log.Printf(" - %-8s: %v\n", FlagOutputTypeFull, outputType)
+	logger.Debugf(" - %-8s: %q\n", FlagOpenAIURLFull, openAIURL)
+	logger.Debugf(" - %-8s: %q\n", FlagAIBackendFull, aiBackend)
+	logger.Debugf(" - %-8s: %q\n", FlagFallbackBackendsFull, fallbackBackends)
+	logger.Debugf(" - %-8s: %q\n", FlagSpecFileFull, specFile)
+	logger.Debugf(" - %-8s: %q\n", FlagHelmChartFull, helmChart)
+	logger.Debugf(" - %-8s: %v\n", FlagSelectFull, selection)
+	logger.Debugf(" - %-8s: %q\n", FlagCompletionStrategyFull, completionStrategy)
+	logger.Debugf(" - %-8s: %q\n", FlagContextSummaryFull, contextSummary)
+	logger.Debugf(" - %-8s: %q\n", FlagContextSummaryFileFull, contextSummaryFile)
+	logger.Debugf(" - %-8s: %q\n", FlagSaveSnapshotFull, saveSnapshot)
+	logger.Debugf(" - %-8s: %q\n", FlagFromSnapshotFull, fromSnapshot)
+	logger.Debugf(" - %-8s: %q\n", FlagCompletionFilterFull, completionFilter)
+	logger.Debugf(" - %-8s: %q\n", FlagCompletionRejectFull, completionReject)
+	logger.Debugf(" - %-8s: %v\n", FlagNoExpandEnvFull, noExpandEnv)
+	logger.Debugf(" - %-8s: %v\n", FlagTemplateRequestFull, templateRequest)
+	logger.Debugf(" - %-8s: %q\n", FlagVarFull, varFlags)
+	logger.Debugf(" - %-8s: %v\n", FlagPerNamespaceDirsFull, perNamespaceDirs)
+	logger.Debugf(" - %-8s: %v\n", FlagPreserveBlankLinesFull, preserveBlankLines)
+	logger.Debugf(" - %-8s: %q\n", FlagRecordFull, record)
+	logger.Debugf(" - %-8s: %q\n", FlagReplayFull, replay)
+	logger.Debugf(" - %-8s: %v\n", FlagStreamFull, stream)
+	logger.Debugf(" - %-8s: %v\n", FlagMaxRetriesFull, maxRetries)
+	logger.Debugf(" - %-8s: %v\n", FlagMaxRepairAttemptsFull, maxRepairAttempts)
+	logger.Debugf(" - %-8s: %v\n", FlagRetryBaseDelayFull, retryBaseDelay)
+	logger.Debugf(" - %-8s: %v\n", FlagTimeoutFull, timeout)
+	logger.Debugf(" - %-8s: %v\n", FlagAutoContextFull, autoContext)
+	logger.Debugf(" - %-8s: %v\n", FlagContextFilesFull, contextFiles)
+	logger.Debugf(" - %-8s: %v\n", FlagNoContextFull, noContext)
+	logger.Debugf(" - %-8s: %v\n", FlagDryRunFull, dryRun)
+	logger.Debugf(" - %-8s: %v\n", FlagReviewFull, review)
+	logger.Debugf(" - %-8s: %v\n", FlagExitCodeFull, exitCode)
+	logger.Debugf(" - %-8s: %v\n", FlagInteractiveFull, interactive)
+	logger.Debugf(" - %-8s: %q\n", FlagFileModeFull, fileModeFlag)
+	logger.Debugf(" - %-8s: %v\n", FlagPatchFull, patch)
+	logger.Debugf(" - %-8s: %v\n", FlagKustomizeFull, kustomizeFiles)
+	logger.Debugf(" - %-8s: %q\n", FlagHFModelFull, hfModel)
+	logger.Debugf(" - %-8s: %v\n", FlagTemperatureFull, temperature)
+	logger.Debugf(" - %-8s: %v\n", FlagConcurrencyFull, concurrency)
+	logger.Debugf(" - %-8s: %v\n", FlagValidateFull, validate)
+	logger.Debugf(" - %-8s: %q\n", FlagGitBranchFull, gitBranch)
+	logger.Debugf(" - %-8s: %v\n", FlagStructuredFull, structured)
+	logger.Debugf(" - %-8s: %q\n", FlagPostHookFull, postHook)
+	logger.Debugf(" - %-8s: %v\n", FlagApplyFull, apply)
+	logger.Debugf(" - %-8s: %q\n", FlagKubeContextFull, kubeContext)
+	logger.Debugf(" - %-8s: %q\n", FlagOnConflictFull, onConflict)
+	logger.Debugf(" - %-8s: %q\n", FlagOutputDirFull, outputDir)
+	logger.Debugf(" - %-8s: %q\n", FlagGeneratedNameFull, generatedName)
+	logger.Debugf(" - %-8s: %q\n", FlagCombineFull, combine)
+	logger.Debugf(" - %-8s: %v\n", FlagFormatFull, format)
+	logger.Debugf(" - %-8s: %q\n", FlagNamespaceFull, namespace)
+	logger.Debugf(" - %-8s: %q\n", FlagLabelFull, labelFlags)
+	logger.Debugf(" - %-8s: %q\n", FlagAnnotationFull, annotationFlags)
+	logger.Debugf(" - %-8s: %v\n", FlagOverwriteMetadataFull, overwriteMetadata)
+	logger.Debugf(" - %-8s: %v\n", FlagInsecureSkipVerifyFull, insecureSkipVerify)
+	logger.Debugf(" - %-8s: %q\n", FlagProxyFull, proxy)
+	logger.Debugf(" - %-8s: %v\n", FlagRateLimitFull, rateLimit)
+	logger.Debugf(" - %-8s: %q\n", FlagCacheDirFull, cacheDir)
+	logger.Debugf(" - %-8s: %v\n", FlagNoCacheFull, noCache)
+	logger.Debugf(" - %-8s: %v\n", FlagCacheTTLFull, cacheTTL)
+	logger.Debugf(" - %-8s: %q\n", FlagTrimStrategyFull, trimStrategy)
+	logger.Debugf(" - %-8s: %v\n", FlagShowPromptFull, showPrompt)
+	logger.Debugf(" - %-8s: %v\n", FlagPromptOnlyFull, promptOnly)
+	logger.Debugf(" - %-8s: %v\n", FlagCountOnlyFull, countOnly)
+	logger.Debugf(" - %-8s: %v\n", FlagShowCostFull, showCost)
+	logger.Debugf(" - %-8s: %v\n", FlagShowUsageFull, showUsage)
+	logger.Debugf(" - %-8s: %v\n", FlagNoHistoryFull, noHistory)
+	logger.Debugf(" - %-8s: %v\n", FlagExplainFull, explain)
+	logger.Debugf(" - %-8s: %q\n", FlagSystemPromptFull, systemPrompt)
+	logger.Debugf(" - %-8s: %q\n", FlagEndOfSequenceFull, endOfSequence)
+	logger.Debugf(" - %-8s: %q\n", FlagStopFull, stop)
+	logger.Debugf(" - %-8s: %v\n", FlagNoGitignoreFull, noGitignore)
+	logger.Debugf(" - %-8s: %v\n", FlagMaxFileSizeFull, maxFileSize)
+	logger.Debugf(" - %-8s: %q\n", FlagProfileFull, profileName)
+	logger.Debugf(" - %-8s: %q\n", FlagModelFull, model)
+	if topP != nil {
+		logger.Debugf(" - %-8s: %v\n", FlagTopPFull, *topP)
+	}
+	if seed != nil {
+		logger.Debugf(" - %-8s: %v\n", FlagSeedFull, *seed)
+	}
+
+	// render the request as a template before anything else reads it
+	if templateRequest {
+		vars, err := ParseKeyValues(FlagVarFull, varFlags)
+		if err != nil {
+			return nil, err
+		}
+		rendered, err := RenderRequest(request, vars)
+		if err != nil {
+			return nil, err
+		}
+		logger.Debugf("rendered request: %q\n", rendered)
+		request = rendered
+	}
 
-	log.Printf(" - %-8s: %q\n", FlagOpenAIURLFull, openAIURL)
-	log.Printf(" - %-8s: %q\n", FlagAIBackendFull, aiBackend)
+	// expand environment variables referenced in the request
+	if !noExpandEnv {
+		expanded, err := ExpandEnv(request)
+		if err != nil {
+			return nil, fmt.Errorf("could not expand the request: %w", err)
+		}
+		if expanded != request {
+			logger.Debugf("expanded request: %q\n", expanded)
+		}
+		request = expanded
+	}
+
+	fileHeader, err := ParseHeaders(FlagFileHeaderFull, fileHeaders)
+	if err != nil {
+		return nil, err
+	}
+	fileMode, err := ParseFileMode(FlagFileModeFull, fileModeFlag)
+	if err != nil {
+		return nil, err
+	}
+	labels, err := ParseKeyValues(FlagLabelFull, labelFlags)
+	if err != nil {
+		return nil, err
+	}
+	annotations, err := ParseKeyValues(FlagAnnotationFull, annotationFlags)
+	if err != nil {
+		return nil, err
+	}
+
+	// compile the completion filters
+	var filterPattern, rejectPattern *regexp.Regexp
+	if completionFilter != "" {
+		var err error
+		if filterPattern, err = regexp.Compile(completionFilter); err != nil {
+			return nil, fmt.Errorf("invalid --%s: %w", FlagCompletionFilterFull, err)
+		}
+	}
+	if completionReject != "" {
+		var err error
+		if rejectPattern, err = regexp.Compile(completionReject); err != nil {
+			return nil, fmt.Errorf("invalid --%s: %w", FlagCompletionRejectFull, err)
+		}
+	}
 
 	// Handle --path by changing the working directory
 	// so that every file name we refer to is relative to path
@@ -76,6 +484,57 @@ func PrepareRequest(cmd *cobra.Command) (*Request, error) {
 	if err := conf.Load(); err != nil {
 		return nil, err
 	}
+	// the profile overrides the config, but not the filesets or the flags which were set explicitly
+	var profile *config.Profile
+	if profileName != "" {
+		var err error
+		if profile, err = conf.ApplyProfile(profileName); err != nil {
+			return nil, err
+		}
+		if profile.NTokens > 0 && !cmd.Flags().Changed(FlagNTokensFull) {
+			nTokens = profile.NTokens
+		}
+		if profile.NCompletions > 0 && !cmd.Flags().Changed(FlagNCompletionsFull) {
+			nCompletions = profile.NCompletions
+		}
+	}
+	// the settings of the filesets override the config and the profile, but not the flags which were set explicitly
+	filesetSettings := conf.ApplyFilesets(filesets)
+	if filesetSettings.NTokens > 0 && !cmd.Flags().Changed(FlagNTokensFull) {
+		nTokens = filesetSettings.NTokens
+	}
+	if filesetSettings.NCompletions > 0 && !cmd.Flags().Changed(FlagNCompletionsFull) {
+		nCompletions = filesetSettings.NCompletions
+	}
+	if filesetSettings.Temperature != nil && !cmd.Flags().Changed(FlagTemperatureFull) {
+		temperature = *filesetSettings.Temperature
+	}
+	if profile != nil {
+		logger.Infof("using the %q profile, %s: %d, %s: %d\n",
+			profileName, FlagNTokensFull, nTokens, FlagNCompletionsFull, nCompletions)
+	}
+	// select backend type, which has to happen before the defaults fill in every backend
+	selectedBackend := ai.Backend(aiBackend)
+	// any model on the HuggingFace Inference API can be used by its ID
+	if hfModel != "" {
+		selectedBackend = ai.HUGGINGFACE
+	}
+	if selectedBackend == ai.Unselected {
+		var err error
+		if selectedBackend, err = conf.SelectBackend(); err != nil {
+			return nil, err
+		}
+		logger.Debugf("using the %q backend from the config\n", selectedBackend)
+	}
+	fallbacks := conf.FallbackBackends
+	if cmd.Flags().Changed(FlagFallbackBackendsFull) {
+		fallbacks = make([]ai.Backend, len(fallbackBackends))
+		for i, backend := range fallbackBackends {
+			fallbacks[i] = ai.Backend(backend)
+		}
+	}
+	fallbacks = FallbackChain(selectedBackend, fallbacks)
+
 	// TODO: generalize overriding default values via CLI
 	conf.SetDefaults()
 	// override OpenAI URL
@@ -83,50 +542,334 @@ func PrepareRequest(cmd *cobra.Command) (*Request, error) {
 		conf.OpenAI.BaseURL = openAIURL
 	}
 
+	if err := ConfigureTLS(&conf, insecureSkipVerify); err != nil {
+		return nil, err
+	}
+	transport, err := ConfigureProxy(&conf, proxy)
+	if err != nil {
+		return nil, err
+	}
+
+	// record or replay the interactions with the backends
+	if record != "" || replay != "" {
+		httpClient, err := RecordingHTTPClient(record, replay, transport)
+		if err != nil {
+			return nil, err
+		}
+		for _, client := range conf.HTTPClients() {
+			*client = httpClient
+		}
+	}
+	ConfigureRateLimit(&conf, rateLimit)
+	ConfigureConcurrencyLimit(&conf, concurrency)
+
+	// the context summary can come from the CLI or the config file
+	if contextSummaryFile != "" {
+		summaryBytes, err := os.ReadFile(contextSummaryFile)
+		if err != nil {
+			return nil, fmt.Errorf("error reading context summary: %w", err)
+		}
+		contextSummary = string(summaryBytes)
+	}
+	if contextSummary == "" {
+		contextSummary = conf.Summary
+	}
+	if systemPrompt == "" {
+		systemPrompt = conf.SystemPrompt
+	}
+	if endOfSequence == "" {
+		endOfSequence = conf.EndOfSequence
+	}
+	if err := CheckEndOfSequence(endOfSequence); err != nil {
+		return nil, fmt.Errorf("invalid --%s %q: %w", FlagEndOfSequenceFull, endOfSequence, err)
+	}
+
+	// the model of the profile is meant for its own backend, not the one of a fileset
+	filesetBackend := filesetSettings.Backend != ai.Unselected && ai.Backend(aiBackend) == ai.Unselected && hfModel == ""
+	if filesetBackend && profile != nil && profile.Model != "" && filesetSettings.Backend != profile.Backend {
+		logger.Debugf("not using the model of the %q profile with the %q backend of the fileset\n",
+			profileName, filesetSettings.Backend)
+	} else if profile != nil && profile.Model != "" {
+		if err := conf.SetModel(selectedBackend, profile.Model); err != nil {
+			return nil, fmt.Errorf("cannot use the model of the %q profile: %w", profileName, err)
+		}
+	}
+	// the model given on the command-line overrides both the config and the profile
+	if model != "" {
+		if err := conf.SetModel(selectedBackend, model); err != nil {
+			return nil, fmt.Errorf("cannot use --%s: %w", FlagModelFull, err)
+		}
+	}
+	if hfModel != "" {
+		conf.HuggingFace.ModelID = hfModel
+	}
+	tok := TokenizerFor(&conf, selectedBackend)
+
+	contextSummary = TruncateToTokens(tok, strings.TrimSpace(contextSummary), MaxContextSummaryTokens)
+
 	// load files
 	fm := filemap.NewFilemap()
-	if err := fm.LoadFiles(files); err != nil {
-		log.Fatalf("error loading files: %s\n", err.Error())
+	// a pure generation skips collecting files altogether
+	if noContext {
+		logger.Debugf("not including any files as context\n")
+	} else {
+		if !noGitignore {
+			if err := fm.UseGitignore("."); err != nil {
+				return nil, err
+			}
+		}
+		fm.UseMaxFileSize(maxFileSize)
+		// files given as URLs are fetched within the timeout, through the proxy
+		fm.UseHTTPClient(&http.Client{Timeout: timeout, Transport: transport}, fileHeader)
+		if err := fm.LoadFiles(files); err != nil {
+			return nil, fmt.Errorf("error loading files: %w", err)
+		}
+		if stdinFile != "" {
+			if err := fm.LoadReader(stdinFile, cmd.InOrStdin()); err != nil {
+				return nil, fmt.Errorf("error loading --%s: %w", FlagStdinFileFull, err)
+			}
+		}
+		if len(filesets) > 0 {
+			logger.Debugf("loading filesets: %v\n", filesets)
+		}
+		if err := fm.LoadFilesets(filesets, conf, config.ConfigFile); err != nil {
+			return nil, fmt.Errorf("error loading filesets: %w", err)
+		}
+		// pick the files most relevant to the request, on top of the ones which were given
+		if autoContext {
+			ctx, cancel := RequestContext(cmd, timeout)
+			defer cancel()
+			client := embeddings.NewClient(conf.EmbeddingsConfig())
+			if _, err := AddRelevantFiles(ctx, client, EmbeddingsCache(), fm, request, contextFiles); err != nil {
+				return nil, fmt.Errorf("could not pick the files to include as context: %w",
+					CancellationError(ctx, timeout, err))
+			}
+		}
 	}
-	if len(filesets) > 0 {
-		log.Printf("loading filesets: %v\n", filesets)
+	var kustomizeContext string
+	if kustomizeFiles {
+		kustomizations, err := FindKustomizations(fm)
+		if err != nil {
+			return nil, fmt.Errorf("error finding kustomizations: %w", err)
+		}
+		logger.Debugf("found %d kustomizations\n", len(kustomizations))
+		kustomizeContext = KustomizeHeader(kustomizations)
+	}
+	var snapshotText string
+
+	// include the output of a previous generation as context
+	if fromSnapshot != "" {
+		snap, err := snapshot.Load(fromSnapshot)
+		if err != nil {
+			return nil, err
+		}
+		logger.Infof("using snapshot %q from %s, generated by %q for request: %q\n",
+			snap.Name, snap.CreatedAt.Format(time.RFC3339), snap.Backend, snap.Request)
+		snapshotText = snap.Filemap().EncodeToInputText()
 	}
-	if err := fm.LoadFilesets(filesets, conf, config.ConfigFile); err != nil {
-		log.Fatalf("error loading filesets: %s\n", err.Error())
+
+	// load the spec file, relative to path
+	var s *spec.Spec
+	if specFile != "" {
+		var err error
+		if s, err = spec.Load(specFile); err != nil {
+			return nil, fmt.Errorf("error loading spec file: %w", err)
+		}
+		logger.Infof("loaded %d sections from spec file %q\n", len(s.Sections), specFile)
 	}
-	filemapText := fm.EncodeToInputText()
 
-	// select backend type
-	selectedBackend := ai.Backend(aiBackend)
-	if selectedBackend == "" {
-		selectedBackend = conf.Backend
+	// load the chart whose values are generated, relative to path
+	var chart *helm.Chart
+	if helmChart != "" {
+		var err error
+		if chart, err = helm.Load(helmChart); err != nil {
+			return nil, fmt.Errorf("error loading helm chart: %w", err)
+		}
+		logger.Infof("generating values for helm chart %q in %s\n", chart.Name, chart.Path)
 	}
 
 	// configure backends
 	// FIXME: create default config methods for these
 	r := Request{
-		Config:       conf,
-		Filemap:      fm,
-		FilemapText:  filemapText,
-		UserRequest:  request,
-		IsWrite:      write,
-		OutputType:   outputType,
-		NTokens:      nTokens,
-		NCompletions: nCompletions,
-		Backend:      selectedBackend,
+		Config:             conf,
+		Filemap:            fm,
+		FilemapText:        JoinContext(fm.EncodeToInputText(), snapshotText),
+		SnapshotText:       snapshotText,
+		UserRequest:        request,
+		IsWrite:            write,
+		OutputType:         outputType,
+		NTokens:            nTokens,
+		NCompletions:       nCompletions,
+		ReasoningTokens:    reasoningTokens,
+		Backend:            selectedBackend,
+		FallbackBackends:   fallbacks,
+		Spec:               s,
+		HelmChart:          chart,
+		Select:             selection,
+		CompletionStrategy: completionStrategy,
+		ContextSummary:     contextSummary,
+		SystemPrompt:       strings.TrimSpace(systemPrompt),
+		EndOfSequence:      endOfSequence,
+		Stop:               stop,
+		Kustomize:          kustomizeFiles,
+		KustomizeContext:   kustomizeContext,
+		SaveSnapshot:       saveSnapshot,
+		CompletionFilter:   filterPattern,
+		CompletionReject:   rejectPattern,
+		PerNamespaceDirs:   perNamespaceDirs,
+		Tokenizer:          tok,
+		PreserveBlankLines: preserveBlankLines,
+		Stream:             stream,
+		DryRun:             dryRun,
+		Review:             review,
+		ExitCode:           exitCode,
+		Interactive:        interactive,
+		FileMode:           fileMode,
+		Patch:              patch,
+		In:                 cmd.InOrStdin(),
+		ErrOut:             cmd.ErrOrStderr(),
+		Temperature:        temperature,
+		TopP:               topP,
+		Seed:               seed,
+		Structured:         structured,
+		Concurrency:        concurrency,
+		Validate:           validate,
+		GitBranch:          gitBranch,
+		PostHook:           postHook,
+		Apply:              apply,
+		KubeContext:        kubeContext,
+		OnConflict:         onConflict,
+		OutputDir:          outputDir,
+		GeneratedName:      generatedName,
+		Combine:            combine,
+		Format:             format,
+		Namespace:          namespace,
+		Labels:             labels,
+		Annotations:        annotations,
+		OverwriteMetadata:  overwriteMetadata,
+		CacheDir:           cacheDir,
+		NoCache:            noCache,
+		CacheTTL:           cacheTTL,
+		TrimStrategy:       trimStrategy,
+		ShowPrompt:         showPrompt,
+		PromptOnly:         promptOnly,
+		CountOnly:          countOnly,
+		ShowCost:           showCost,
+		ShowUsage:          showUsage,
+		NoHistory:          noHistory,
+		Explain:            explain,
+		Retry: ai.RetryOptions{
+			MaxRetries: maxRetries,
+			BaseDelay:  retryBaseDelay,
+		},
+		MaxRepairAttempts: maxRepairAttempts,
+		Timeout:           timeout,
+	}
+	if err := ValidateRequest(&r); err != nil {
+		return nil, err
 	}
 
 	return &r, nil
 }
 
+// ErrChanges Is returned with --exit-code when the files on disk would change.
+var ErrChanges = errors.New("the files on disk would change")
+
+// changesFound Returns ErrChanges when the request asked for an exit code and the diff or review isn't empty.
+func changesFound(r *Request, changes string) error {
+	if r.ExitCode && changes != "" {
+		return ErrChanges
+	}
+	return nil
+}
+
 // PrintOrWriteOut Accepts a request object and writes the contents of the filemap
 // to the disk if specified, otherwise it prints to STDOUT.
-func PrintOrWriteOut(r *Request) error {
+// On a dry run, a unified diff against the files on disk is printed instead, and with --review,
+// the changes to each Kubernetes resource.
+func PrintOrWriteOut(ctx context.Context, r *Request) error {
+	againstDisk := r.IsWrite || r.DryRun || r.Review
+	// files fetched from URLs are only context, so they're never written back
+	if againstDisk {
+		r.Filemap.DropFetched()
+	}
+	// the changes are applied on top of the files as they are now, before anything is shown or written
+	if r.Patch && againstDisk {
+		if err := r.Filemap.Patch(); err != nil {
+			return err
+		}
+	}
+	if r.Kustomize && againstDisk {
+		if err := AddToKustomizations(r.Filemap); err != nil {
+			return fmt.Errorf("could not update the kustomizations: %w", err)
+		}
+	}
+	if againstDisk {
+		if err := ApplyConflictPolicy(r.Filemap, r.OnConflict); err != nil {
+			return err
+		}
+	}
+	if r.Review {
+		review, err := r.Filemap.Review()
+		if err != nil {
+			return fmt.Errorf("could not review the changes: %w", err)
+		}
+		if review == "" {
+			logger.Infof("nothing would change\n")
+		}
+		fmt.Print(review)
+		return changesFound(r, review)
+	}
+	if r.DryRun {
+		diff, err := r.Filemap.Diff()
+		if err != nil {
+			return fmt.Errorf("could not diff the changes: %w", err)
+		}
+		fmt.Print(diff)
+		return changesFound(r, diff)
+	}
+
 	if r.IsWrite {
-		err := r.Filemap.WriteUpdatesToFiles()
+		if r.Interactive {
+			if err := ConfirmFiles(r.Filemap, r.In, r.ErrOut); err != nil {
+				return err
+			}
+		}
+		if r.FileMode != 0 {
+			r.Filemap.SetMode(r.FileMode)
+		}
+		// record what the files contained, so that writing them can be undone
+		j, err := journal.Record(r.Filemap, r.UserRequest)
 		if err != nil {
 			return err
 		}
+		if err = r.Filemap.WriteUpdatesToFiles(); err != nil {
+			return err
+		}
+		if err = j.Save("."); err != nil {
+			return fmt.Errorf("the files were written, but could not be recorded for %s: %w", CommandUndo, err)
+		}
+		if err = RunPostHook(ctx, r); err != nil {
+			return err
+		}
+		return ApplyManifests(ctx, r)
+	}
+
+	// structured output is printed on its own so that it can be parsed
+	if r.OutputType == filemap.OutputJSON {
+		out, err := json.MarshalIndent(NewGenerateResult(r), "", "    ")
+		if err != nil {
+			return fmt.Errorf("could not encode the result: %w", err)
+		}
+		fmt.Println(string(out))
+		return nil
+	}
+	if r.OutputType == filemap.OutputJSONFull {
+		out, err := json.MarshalIndent(NewGenerateResponse(r), "", "    ")
+		if err != nil {
+			return fmt.Errorf("could not encode the response: %w", err)
+		}
+		fmt.Println(string(out))
 		return nil
 	}
 
@@ -136,16 +879,337 @@ func PrintOrWriteOut(r *Request) error {
 		return err
 	}
 	stringOut := strings.ReplaceAll(fmOutput, "\\n", "\n")
-	log.Printf("\n%s\n", stringOut)
+	fmt.Println(stringOut)
+
+	return nil
+}
+
+// RecordingHTTPClient Returns an HTTP client which replays the backend's responses
+// from the replay file if one is given, or otherwise records the interactions to the record file,
+// making the requests with the given transport.
+func RecordingHTTPClient(record, replay string, transport http.RoundTripper) (*http.Client, error) {
+	if replay != "" {
+		replayer, err := recording.NewReplayer(replay)
+		if err != nil {
+			return nil, err
+		}
+		logger.Infof("replaying backend responses from %q\n", replay)
+		return &http.Client{Transport: replayer}, nil
+	}
+	logger.Infof("recording backend interactions to %q\n", record)
+	return &http.Client{Transport: recording.NewRecorder(record, transport)}, nil
+}
+
+// ConfigureProxy Sends the requests to every backend through the proxy, or through the proxy from
+// the environment when it's empty, keeping the TLS settings of each backend.
+// The transport which the backends' requests should be made with is returned.
+func ConfigureProxy(conf *config.Config, proxy string) (http.RoundTripper, error) {
+	var proxyURL *url.URL
+	if proxy != "" {
+		var err error
+		if proxyURL, err = utils.ParseProxy(proxy); err != nil {
+			return nil, fmt.Errorf("could not use --%s: %w", FlagProxyFull, err)
+		}
+	}
+	for _, client := range conf.HTTPClients() {
+		proxied := http.Client{}
+		if *client != nil {
+			proxied = **client
+		}
+		transport, err := utils.ProxyTransport(proxied.Transport, proxyURL)
+		if err != nil {
+			return nil, err
+		}
+		proxied.Transport = transport
+		*client = &proxied
+	}
+	return utils.ProxyTransport(nil, proxyURL)
+}
+
+// ConfigureRateLimit Makes every backend wait for a single limiter shared by all of them
+// before each request, so that at most rate requests are made per second. A rate of zero sets no limit.
+// The limit applies to each HTTP request, including retries and the requests made concurrently for completions.
+func ConfigureRateLimit(conf *config.Config, rate float64) {
+	if rate <= 0 {
+		return
+	}
+	limiter := ai.NewRateLimiter(rate)
+	conf.WrapTransports(func(transport http.RoundTripper) http.RoundTripper {
+		return ai.RateLimitTransport(limiter, transport)
+	})
+}
+
+// ConfigureConcurrencyLimit Makes every backend share a single limiter which bounds how many requests are in
+// flight at once, so that the requests made concurrently for completions, those made to fallback backends,
+// and those embedding files for --auto-context never exceed the limit together. A limit below 1 sets no limit.
+func ConfigureConcurrencyLimit(conf *config.Config, concurrency int) {
+	if concurrency < 1 {
+		return
+	}
+	limiter := ai.NewConcurrencyLimiter(concurrency)
+	conf.WrapTransports(func(transport http.RoundTripper) http.RoundTripper {
+		return ai.ConcurrencyLimitTransport(limiter, transport)
+	})
+}
+
+// ConfigureTLS Sets up the HTTP clients of the self-hosted backends with custom TLS settings,
+// only allowing them to skip verifying certificates when --insecure-skip-verify was passed.
+func ConfigureTLS(conf *config.Config, allowInsecure bool) error {
+	err := conf.ConfigureTLS(allowInsecure)
+	if errors.Is(err, config.ErrInsecureTLS) {
+		return fmt.Errorf("%w, pass --%s to allow it", err, FlagInsecureSkipVerifyFull)
+	}
+	return err
+}
 
+// SaveSnapshot Saves the request's filemap as a named snapshot if one was requested,
+// so that it can be used as context in later requests.
+func SaveSnapshot(r *Request) error {
+	if r.SaveSnapshot == "" {
+		return nil
+	}
+	snap := snapshot.New(r.SaveSnapshot, r.Backend, r.UserRequest, r.Filemap)
+	if err := snap.Save(); err != nil {
+		return fmt.Errorf("could not save snapshot: %w", err)
+	}
+	logger.Infof("saved %d files to snapshot %q\n", len(snap.Files), snap.Name)
 	return nil
 }
 
+// JoinContext Joins the encoded files with the encoded files of a snapshot, either of which may be empty.
+func JoinContext(filemapText, snapshotText string) string {
+	if strings.TrimSpace(filemapText) != "" && snapshotText != "" {
+		filemapText += filemap.FileDelimeter + "\n"
+	}
+	return filemapText + snapshotText
+}
+
+// ReadRequest Reads the full natural-language request from r, which is usually STDIN.
+// An error is returned if nothing but whitespace was read.
+func ReadRequest(r io.Reader) (string, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return "", fmt.Errorf("could not read the request from stdin: %w", err)
+	}
+	request := strings.TrimSpace(string(data))
+	if request == "" {
+		return "", fmt.Errorf("--%s was %q but nothing was read from stdin", FlagRequestFull, StdinRequest)
+	}
+	return request, nil
+}
+
+// ReadRequestFile Reads the full natural-language request from the file at path,
+// so that long requests can be kept and reviewed alongside the repo.
+// An error is returned if the file can't be read or contains nothing but whitespace.
+func ReadRequestFile(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("could not read the request from --%s: %w", FlagRequestFileFull, err)
+	}
+	request := strings.TrimSpace(string(data))
+	if request == "" {
+		return "", fmt.Errorf("--%s %q is empty", FlagRequestFileFull, path)
+	}
+	return request, nil
+}
+
+// ApplyConflictPolicy Decides what happens to the files which already exist on disk before they're written:
+// they're overwritten, left untouched, or written next to the existing files, depending on the policy.
+func ApplyConflictPolicy(fm *filemap.Filemap, policy string) error {
+	switch policy {
+	case "", ConflictPolicyOverwrite:
+		return nil
+	case ConflictPolicySkip:
+		skipped, err := fm.SkipExisting()
+		for _, path := range skipped {
+			logger.Infof("leaving %q untouched, since it already exists\n", path)
+		}
+		return err
+	case ConflictPolicySuffix:
+		moved, err := fm.SuffixExisting()
+		for _, path := range moved {
+			logger.Infof("writing to %q, since the file it replaces already exists\n", path)
+		}
+		return err
+	default:
+		return fmt.Errorf("unknown --%s policy %q", FlagOnConflictFull, policy)
+	}
+}
+
+// ParseKeyValues Parses the key=value pairs given with the flag into a map.
+// A later pair overrides an earlier one with the same key.
+func ParseKeyValues(flag string, pairs []string) (map[string]string, error) {
+	values := make(map[string]string, len(pairs))
+	for _, pair := range pairs {
+		key, value, ok := strings.Cut(pair, "=")
+		if !ok || strings.TrimSpace(key) == "" {
+			return nil, fmt.Errorf("invalid --%s %q, expected key=value", flag, pair)
+		}
+		values[strings.TrimSpace(key)] = value
+	}
+	return values, nil
+}
+
+// ParseHeaders Parses the 'Name: value' headers given with the flag.
+func ParseHeaders(flag string, headers []string) (http.Header, error) {
+	header := make(http.Header, len(headers))
+	for _, h := range headers {
+		name, value, ok := strings.Cut(h, ":")
+		if !ok || strings.TrimSpace(name) == "" {
+			return nil, fmt.Errorf("invalid --%s %q, expected 'Name: value'", flag, h)
+		}
+		header.Add(strings.TrimSpace(name), strings.TrimSpace(value))
+	}
+	return header, nil
+}
+
+// ParseFileMode Parses the octal permissions given with the flag, such as 0755.
+// No mode is returned when the value is empty.
+func ParseFileMode(flag, value string) (os.FileMode, error) {
+	if value == "" {
+		return 0, nil
+	}
+	mode, err := strconv.ParseUint(value, 8, 32)
+	if err != nil || mode == 0 || mode > uint64(os.ModePerm) {
+		return 0, fmt.Errorf("invalid --%s %q, expected octal permissions such as 0755", flag, value)
+	}
+	return os.FileMode(mode), nil
+}
+
+// RenderRequest Renders the request as a text/template, with the variables given with --var
+// available as {{ .name }}. Besides the builtin functions, lower, upper and trim can be used.
+// Referencing a variable which wasn't given is an error, rather than rendering it empty.
+func RenderRequest(request string, vars map[string]string) (string, error) {
+	funcs := template.FuncMap{
+		"lower": strings.ToLower,
+		"upper": strings.ToUpper,
+		"trim":  strings.TrimSpace,
+	}
+	tmpl, err := template.New(FlagRequestFull).Funcs(funcs).Option("missingkey=error").Parse(request)
+	if err != nil {
+		return "", fmt.Errorf("could not parse the request as a template: %w", err)
+	}
+	var out strings.Builder
+	if err = tmpl.Execute(&out, vars); err != nil {
+		return "", fmt.Errorf("could not render the request, pass every variable it uses with --%s: %w", FlagVarFull, err)
+	}
+	return out.String(), nil
+}
+
+// ExpandEnv Replaces references to environment variables in the text, written as $VAR
+// or ${VAR}. A default can be given as ${VAR:-default}, which is used when the variable is
+// unset or empty, and '$$' produces a literal '$'. An error listing every variable which
+// is unset and has no default is returned.
+func ExpandEnv(text string) (string, error) {
+	var out strings.Builder
+	var missing []string
+	isNameChar := func(c byte, first bool) bool {
+		return c == '_' || ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z') || (!first && '0' <= c && c <= '9')
+	}
+
+	for i := 0; i < len(text); i++ {
+		if text[i] != '$' || i+1 == len(text) {
+			out.WriteByte(text[i])
+			continue
+		}
+		next := text[i+1]
+		switch {
+		case next == '$':
+			out.WriteByte('$')
+			i++
+		case next == '{':
+			end := strings.IndexByte(text[i:], '}')
+			if end < 0 {
+				return "", fmt.Errorf("unterminated variable reference at %q", text[i:])
+			}
+			name, defaultValue, hasDefault := strings.Cut(text[i+2:i+end], ":-")
+			value, ok := os.LookupEnv(name)
+			switch {
+			case hasDefault && value == "":
+				value = defaultValue
+			case !ok:
+				missing = append(missing, name)
+			}
+			out.WriteString(value)
+			i += end
+		case isNameChar(next, true):
+			j := i + 1
+			for j < len(text) && isNameChar(text[j], j == i+1) {
+				j++
+			}
+			name := text[i+1 : j]
+			value, ok := os.LookupEnv(name)
+			if !ok {
+				missing = append(missing, name)
+			}
+			out.WriteString(value)
+			i = j - 1
+		default:
+			out.WriteByte('$')
+		}
+	}
+
+	if len(missing) > 0 {
+		return "", fmt.Errorf("environment variables are not set: %s "+
+			"(use ${VAR:-default} to provide a default, or '$$' for a literal '$')", strings.Join(missing, ", "))
+	}
+	return out.String(), nil
+}
+
+// ContextSummaryHeader Formats the given summary of the repo as a header
+// to be placed at the top of a prompt, or an empty string if there is no summary.
+func ContextSummaryHeader(summary string) string {
+	if summary == "" {
+		return ""
+	}
+	header := "## Summary of the repository:\n"
+	for _, line := range strings.Split(summary, "\n") {
+		header += strings.TrimRight("## "+line, " ") + "\n"
+	}
+	return header + "##\n"
+}
+
+// TokenizerFor Returns the tokenizer which best matches the model used by the given backend.
+// Only OpenAI's models are known by name, the others get the heuristic.
+func TokenizerFor(conf *config.Config, backend ai.Backend) tokenizer.Tokenizer {
+	if backend == ai.GPT3 {
+		return tokenizer.ForModel(conf.OpenAI.ModelName())
+	}
+	return tokenizer.ForModel(string(backend))
+}
+
+// TruncateToTokens Shortens the given text so that it fits within maxTokens tokens, keeping its
+// formatting intact: it's cut after the last whole line which fits, or within the first line
+// when not even that one does.
+func TruncateToTokens(tok tokenizer.Tokenizer, text string, maxTokens int) string {
+	total := tok.CountTokens(text)
+	if total <= maxTokens {
+		return text
+	}
+	// find the most lines which still fit
+	lines := strings.SplitAfter(text, "\n")
+	fits := sort.Search(len(lines)+1, func(n int) bool {
+		return tok.CountTokens(strings.Join(lines[:n], "")) > maxTokens
+	}) - 1
+	truncated := strings.TrimSuffix(strings.Join(lines[:fits], ""), "\n")
+	if fits == 0 {
+		runes := []rune(lines[0])
+		fits = sort.Search(len(runes)+1, func(n int) bool {
+			return tok.CountTokens(string(runes[:n])) > maxTokens
+		}) - 1
+		truncated = string(runes[:fits])
+	}
+	logger.Infof("truncated text from %d to %d tokens to fit within %d tokens\n",
+		total, tok.CountTokens(truncated), maxTokens)
+	return truncated
+}
+
 // AddRequestFlags Appends flags to the given command which are then used at the command-line.
 func AddRequestFlags(cmd *cobra.Command) {
 	cmd.Flags().StringP(
 		FlagRequestFull, FlagRequestShort, "",
-		"Requested changes in natural language (empty request will surprise you!)",
+		"Requested changes in natural language, or '"+StdinRequest+"' to read them from stdin "+
+			"(empty request will surprise you!)",
 	)
 
 	cmd.Flags().BoolP(
@@ -153,18 +1217,88 @@ func AddRequestFlags(cmd *cobra.Command) {
 		"Write changes to the repo files (if not set the patch is printed to stdout)",
 	)
 
+	cmd.Flags().BoolP(
+		FlagInteractiveFull, FlagInteractiveShort, false,
+		"Ask before writing each file, showing a diff of the files which would be overwritten",
+	)
+
+	cmd.Flags().String(
+		FlagPostHookFull, "",
+		"Shell command to run after the files are written, e.g. a linter, failing when it does: once per file "+
+			"with '"+PostHookFilePlaceholder+"' replaced by its path, or else once in the output directory "+
+			"(requires --"+FlagWriteFull+")",
+	)
+
+	cmd.Flags().String(
+		FlagOnConflictFull, ConflictPolicyOverwrite,
+		"What to do with files which already exist when writing: '"+ConflictPolicyOverwrite+"' them, '"+
+			ConflictPolicySkip+"' them and only write new files, or '"+ConflictPolicySuffix+"' to write next to them, "+
+			"e.g. to pod"+filemap.GeneratedSuffix+".yaml",
+	)
+
+	cmd.Flags().String(
+		FlagFileModeFull, "",
+		"Octal permissions to write every file with, e.g. 0755 for scripts "+
+			"(existing files keep their permissions and new ones get 0644 by default)",
+	)
+
+	cmd.Flags().Bool(
+		FlagDryRunFull, false,
+		"Print a unified diff of the changes against the files on disk, without writing anything",
+	)
+
+	cmd.Flags().Bool(
+		FlagReviewFull, false,
+		"Print the changes against the files on disk grouped by Kubernetes resource, listing each added, "+
+			"changed and removed field with its values before and after, without writing anything",
+	)
+
+	cmd.Flags().Bool(
+		FlagExitCodeFull, false,
+		"Exit with status 1 when the diff of --"+FlagDryRunFull+" or --"+FlagReviewFull+
+			" isn't empty, like git diff --exit-code",
+	)
+
+	cmd.Flags().Bool(
+		FlagPatchFull, false,
+		"Apply only the lines the model changed to the files on disk, keeping edits made since they were read, "+
+			"and fail if the changes no longer apply",
+	)
+
+	cmd.Flags().Bool(
+		FlagNoGitignoreFull, false,
+		"Include files which are ignored by the nearest "+filemap.GitignoreFile,
+	)
+
+	cmd.Flags().Int64(
+		FlagMaxFileSizeFull, filemap.DefaultMaxFileSize,
+		"Skip files larger than this many bytes when collecting files, or 0 to include files of any size",
+	)
+
 	cmd.Flags().StringP(
 		FlagPathFull, FlagPathShort, ".",
 		"Path to the root of the repo",
 	)
 
 	cmd.Flags().StringP(
-		FlagOutputTypeFull, FlagOutputTypeShort, "json",
-		"How to format output",
+		FlagOutputTypeFull, FlagOutputTypeShort, filemap.OutputPlain,
+		"How to format output ("+filemap.OutputPlain+", "+filemap.OutputJSON+", or "+filemap.OutputJSONFull+
+			" to include the raw completions and whether they could be decoded)",
 	)
 
 	cmd.Flags().StringP(
-		FlagAIBackendFull, FlagAIBackendShort, string(ai.GPT3), "AI Backend to use",
+		FlagAIBackendFull, FlagAIBackendShort, string(ai.Unselected),
+		"AI Backend to use, defaults to the backend in the config",
+	)
+
+	cmd.Flags().String(
+		FlagProfileFull, "",
+		"Name of a profile in "+config.ConfigFile+" which overrides the backend, model, and generation settings",
+	)
+
+	cmd.Flags().Duration(
+		FlagTimeoutFull, DefaultTimeout,
+		"Abort the requests to the backend if they take longer than this, or 0 to wait indefinitely",
 	)
 
 	cmd.Flags().StringP(
@@ -173,4 +1307,71 @@ func AddRequestFlags(cmd *cobra.Command) {
 		gpt3.OpenAIURL+gpt3.OpenAIEndpointV1,
 		"OpenAI URL",
 	)
+
+	cmd.Flags().String(
+		FlagContextSummaryFull, "",
+		"A paragraph describing the repo, included once at the top of the prompt (defaults to 'summary' in "+
+			config.ConfigFile+")",
+	)
+
+	cmd.Flags().String(
+		FlagContextSummaryFileFull, "",
+		"Path to a file containing the context summary",
+	)
+
+	cmd.Flags().Bool(
+		FlagNoHistoryFull, false,
+		"Don't record the generation in "+history.Path("."),
+	)
+
+	cmd.Flags().Bool(
+		FlagNoExpandEnvFull, false,
+		"Don't expand environment variables such as $VAR or ${VAR:-default} in the request",
+	)
+
+	cmd.Flags().Bool(
+		FlagTemplateRequestFull, false,
+		"Render the request as a Go template, e.g. 'Deploy to {{ .region }}', with the values given with --"+FlagVarFull,
+	)
+
+	cmd.Flags().StringArray(
+		FlagVarFull, []string{},
+		"Set a variable of the request's template, as key=value (can be repeated, requires --"+
+			FlagTemplateRequestFull+")",
+	)
+
+	cmd.Flags().String(
+		FlagRecordFull, "",
+		"Record the requests and responses exchanged with the backend to this file",
+	)
+
+	cmd.Flags().String(
+		FlagReplayFull, "",
+		"Serve the backend's responses from a file written by --"+FlagRecordFull+", without network access",
+	)
+
+	cmd.Flags().String(
+		FlagSaveSnapshotFull, "",
+		"Save the output under the given name so it can be reused with --"+FlagFromSnapshotFull,
+	)
+
+	AddConnectionFlags(cmd)
+}
+
+// AddConnectionFlags Adds the flags which configure how the backends are connected to.
+func AddConnectionFlags(cmd *cobra.Command) {
+	cmd.Flags().Bool(
+		FlagInsecureSkipVerifyFull, false,
+		"Allow the self-hosted backends configured with insecureSkipVerify to skip verifying TLS certificates",
+	)
+
+	cmd.Flags().String(
+		FlagProxyFull, "",
+		"URL of the proxy to send every request to the backends through, instead of HTTP_PROXY or HTTPS_PROXY",
+	)
+
+	cmd.Flags().Float64(
+		FlagRateLimitFull, 0,
+		"Maximum number of requests per second made to the backends, shared by every request (0 for no limit)",
+	)
 }
@@ -1,12 +1,556 @@
 package cmd_test
 
-// import (
-// 	. "github.com/onsi/ginkgo/v2"
-// 	. "github.com/onsi/gomega"
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
-// 	"github.com/redhat-et/copilot-ops/pkg/cmd"
-// )
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/spf13/viper"
 
-// var _ = Describe("Utils", func() {
+	"github.com/redhat-et/copilot-ops/pkg/ai"
+	"github.com/redhat-et/copilot-ops/pkg/ai/embeddings"
+	"github.com/redhat-et/copilot-ops/pkg/ai/ollama"
+	"github.com/redhat-et/copilot-ops/pkg/cmd"
+	"github.com/redhat-et/copilot-ops/pkg/cmd/config"
+	"github.com/redhat-et/copilot-ops/pkg/filemap"
+	"github.com/redhat-et/copilot-ops/pkg/tokenizer"
+)
 
-// })
+var _ = Describe("Utils", func() {
+	When("a context summary is provided", func() {
+		It("is formatted as a prompt header", func() {
+			header := cmd.ContextSummaryHeader("A web store.\nRuns on OpenShift.")
+			Expect(header).To(HavePrefix("## Summary of the repository:\n"))
+			Expect(header).To(ContainSubstring("## A web store.\n## Runs on OpenShift.\n"))
+		})
+
+		It("is omitted when empty", func() {
+			Expect(cmd.ContextSummaryHeader("")).To(BeEmpty())
+		})
+
+		It("is truncated to the token limit", func() {
+			long := strings.Repeat("word ", 100)
+			truncated := cmd.TruncateToTokens(tokenizer.Heuristic{}, long, 10)
+			Expect(tokenizer.Heuristic{}.CountTokens(truncated)).To(Equal(10))
+			Expect(long).To(HavePrefix(truncated))
+			Expect(cmd.TruncateToTokens(tokenizer.Heuristic{}, "short", 10)).To(Equal("short"))
+		})
+
+		It("keeps the lines and indentation of the text it truncates", func() {
+			summary := "services:\n  web:\n    image: nginx\n  db:\n    image: postgres\n"
+			truncated := cmd.TruncateToTokens(tokenizer.Heuristic{}, summary, 9)
+			Expect(truncated).To(Equal("services:\n  web:\n    image: nginx"))
+		})
+	})
+
+	When("the output is JSON", func() {
+		It("prints the generated files and how they were made", func() {
+			fm := filemap.NewFilemap()
+			fm.Files["pod"] = filemap.File{Path: "app/pod.yaml", Content: "kind: Pod\n"}
+			fm.Files["service"] = filemap.File{Content: "kind: Service\n"}
+			r := &cmd.Request{
+				Filemap:     fm,
+				OutputType:  filemap.OutputJSON,
+				Backend:     ai.GPT3,
+				Completions: 2,
+			}
+
+			// capture STDOUT
+			reader, writer, err := os.Pipe()
+			Expect(err).NotTo(HaveOccurred())
+			stdout := os.Stdout
+			os.Stdout = writer
+			err = cmd.PrintOrWriteOut(context.Background(), r)
+			os.Stdout = stdout
+			Expect(writer.Close()).To(Succeed())
+			Expect(err).NotTo(HaveOccurred())
+			out, err := io.ReadAll(reader)
+			Expect(err).NotTo(HaveOccurred())
+
+			var result cmd.GenerateResult
+			Expect(json.Unmarshal(out, &result)).To(Succeed())
+			Expect(result).To(Equal(cmd.GenerateResult{
+				Backend:     ai.GPT3,
+				Completions: 2,
+				Files: map[string]string{
+					"app/pod.yaml": "kind: Pod\n",
+					"service":      "kind: Service\n",
+				},
+			}))
+		})
+	})
+
+	When("the full response is printed as JSON", func() {
+		var r *cmd.Request
+		BeforeEach(func() {
+			topP := float32(0.9)
+			r = &cmd.Request{
+				UserRequest:    "Create a Pod running nginx",
+				OutputType:     filemap.OutputJSONFull,
+				Backend:        ai.OLLAMA,
+				Config:         config.Config{Ollama: &ollama.Config{Model: "codellama"}},
+				NTokens:        256,
+				NCompletions:   1,
+				Temperature:    0.2,
+				TopP:           &topP,
+				RawCompletions: []string{"# @pod.yaml\nkind: Pod\n"},
+			}
+		})
+
+		// output Decodes the completions and returns the response printed to STDOUT.
+		output := func() cmd.GenerateResponse {
+			reader, writer, err := os.Pipe()
+			Expect(err).NotTo(HaveOccurred())
+			stdout := os.Stdout
+			os.Stdout = writer
+			err = cmd.DecodeAndOutput(context.Background(), r, r.RawCompletions)
+			os.Stdout = stdout
+			Expect(writer.Close()).To(Succeed())
+			Expect(err).NotTo(HaveOccurred())
+			out, err := io.ReadAll(reader)
+			Expect(err).NotTo(HaveOccurred())
+
+			var response cmd.GenerateResponse
+			Expect(json.Unmarshal(out, &response)).To(Succeed())
+			return response
+		}
+
+		It("includes the request, the raw completions and the decoded files", func() {
+			topP := float32(0.9)
+			Expect(output()).To(Equal(cmd.GenerateResponse{
+				Request: "Create a Pod running nginx",
+				Backend: ai.OLLAMA,
+				Model:   "codellama",
+				Parameters: cmd.GenerateParameters{
+					NTokens:      256,
+					NCompletions: 1,
+					Temperature:  0.2,
+					TopP:         &topP,
+				},
+				Choices: []string{"# @pod.yaml\nkind: Pod\n"},
+				Files: map[string]filemap.File{
+					"pod.yaml": {Content: "kind: Pod\n", Type: filemap.FileTypeYAML},
+				},
+			}))
+		})
+
+		It("explains why a malformed completion couldn't be decoded", func() {
+			r.RawCompletions = []string{"kind: Pod\nmetadata:\n  name: nginx\n"}
+			response := output()
+			Expect(response.DecodeError).NotTo(BeEmpty())
+			Expect(response.Choices).To(Equal(r.RawCompletions))
+			Expect(response.Files).To(HaveLen(1))
+			for _, file := range response.Files {
+				Expect(file.Path).To(HavePrefix(cmd.DefaultOutputDir + "/"))
+				Expect(file.Content).To(ContainSubstring("name: nginx"))
+			}
+		})
+	})
+
+	When("the request is read from stdin", func() {
+		It("reads the whole request", func() {
+			request, err := cmd.ReadRequest(strings.NewReader("add a liveness probe\nto every container\n"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(request).To(Equal("add a liveness probe\nto every container"))
+		})
+
+		It("rejects an empty request", func() {
+			_, err := cmd.ReadRequest(strings.NewReader(" \n"))
+			Expect(err).To(MatchError(ContainSubstring("nothing was read from stdin")))
+		})
+	})
+
+	When("the request references environment variables", func() {
+		BeforeEach(func() {
+			DeferCleanup(os.Unsetenv, "COPILOT_TEST_APP")
+			Expect(os.Setenv("COPILOT_TEST_APP", "guestbook")).To(Succeed())
+		})
+
+		It("expands them", func() {
+			expanded, err := cmd.ExpandEnv("create $COPILOT_TEST_APP in ${COPILOT_TEST_APP}-ns")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(expanded).To(Equal("create guestbook in guestbook-ns"))
+		})
+
+		It("uses defaults for unset variables", func() {
+			expanded, err := cmd.ExpandEnv("namespace ${COPILOT_TEST_UNSET:-default}")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(expanded).To(Equal("namespace default"))
+		})
+
+		It("keeps escaped and lone dollar signs", func() {
+			expanded, err := cmd.ExpandEnv("costs $$5 or $ 10")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(expanded).To(Equal("costs $5 or $ 10"))
+		})
+
+		It("fails on unset variables", func() {
+			_, err := cmd.ExpandEnv("deploy to $COPILOT_TEST_UNSET")
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("COPILOT_TEST_UNSET"))
+		})
+	})
+
+	When("the request is a template", func() {
+		It("substitutes the variables", func() {
+			rendered, err := cmd.RenderRequest(
+				"create a {{ .app }} Deployment in {{ upper .region }} for cluster {{ .cluster }}",
+				map[string]string{"app": "guestbook", "region": "eu-west-1", "cluster": "prod"},
+			)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(rendered).To(Equal("create a guestbook Deployment in EU-WEST-1 for cluster prod"))
+		})
+
+		It("fails on variables which weren't given", func() {
+			_, err := cmd.RenderRequest("deploy to {{ .region }}", map[string]string{"cluster": "prod"})
+			Expect(err).To(MatchError(ContainSubstring(`map has no entry for key "region"`)))
+			Expect(err).To(MatchError(ContainSubstring("--" + cmd.FlagVarFull)))
+		})
+
+		It("fails on invalid templates", func() {
+			_, err := cmd.RenderRequest("deploy to {{ .region", nil)
+			Expect(err).To(MatchError(ContainSubstring("could not parse the request as a template")))
+		})
+
+		It("is rendered when preparing the request", func() {
+			wd, err := os.Getwd()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(os.Chdir(GinkgoT().TempDir())).To(Succeed())
+			DeferCleanup(os.Chdir, wd)
+			viper.Reset()
+			DeferCleanup(viper.Reset)
+			Expect(os.WriteFile(config.ConfigFile, []byte("claude:\n  apiKey: sk-test\n"), 0600)).To(Succeed())
+
+			c := cmd.NewGenerateCmd()
+			Expect(c.Flags().Set(cmd.FlagRequestFull, "scale {{ .app }} to $$3 in {{ .region }}")).To(Succeed())
+			Expect(c.Flags().Set(cmd.FlagTemplateRequestFull, "true")).To(Succeed())
+			Expect(c.Flags().Set(cmd.FlagVarFull, "app=web")).To(Succeed())
+			Expect(c.Flags().Set(cmd.FlagVarFull, "region=us-east-1")).To(Succeed())
+			r, err := cmd.PrepareRequest(c)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(r.UserRequest).To(Equal("scale web to $3 in us-east-1"))
+		})
+
+		It("requires --template-request for --var", func() {
+			c := cmd.NewGenerateCmd()
+			Expect(c.Flags().Set(cmd.FlagVarFull, "region=us-east-1")).To(Succeed())
+			Expect(cmd.ValidateFlags(c, []string{})).To(MatchError(ContainSubstring("--" + cmd.FlagTemplateRequestFull)))
+		})
+	})
+
+	When("a rate limit is given", func() {
+		It("makes every backend share a limiter, keeping their settings", func() {
+			conf := &config.Config{}
+			conf.SetDefaults()
+			conf.Claude.HTTPClient = &http.Client{Timeout: time.Minute}
+			cmd.ConfigureRateLimit(conf, 2)
+
+			transports := map[http.RoundTripper]bool{}
+			for _, client := range conf.HTTPClients() {
+				Expect(*client).NotTo(BeNil())
+				Expect((*client).Transport).NotTo(BeNil())
+				transports[(*client).Transport] = true
+			}
+			Expect(transports).To(HaveLen(1))
+			Expect(conf.Claude.HTTPClient.Timeout).To(Equal(time.Minute))
+		})
+
+		It("leaves the backends alone without a limit", func() {
+			conf := &config.Config{}
+			conf.SetDefaults()
+			cmd.ConfigureRateLimit(conf, 0)
+			for _, client := range conf.HTTPClients() {
+				Expect(*client).To(BeNil())
+			}
+		})
+	})
+	When("a concurrency limit is given", func() {
+		var inFlight, maxInFlight int32
+
+		BeforeEach(func() {
+			inFlight, maxInFlight = 0, 0
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				current := atomic.AddInt32(&inFlight, 1)
+				defer atomic.AddInt32(&inFlight, -1)
+				for {
+					highest := atomic.LoadInt32(&maxInFlight)
+					if current <= highest || atomic.CompareAndSwapInt32(&maxInFlight, highest, current) {
+						break
+					}
+				}
+				time.Sleep(10 * time.Millisecond)
+				switch {
+				case strings.HasPrefix(r.URL.Path, "/unavailable"):
+					w.WriteHeader(http.StatusServiceUnavailable)
+				case strings.HasPrefix(r.URL.Path, "/generate"):
+					fmt.Fprint(w, `[{"generated_text": "kind: Pod\n"}]`)
+				default:
+					fmt.Fprint(w, `{"data": [{"index": 0, "embedding": [1, 0]}]}`)
+				}
+			}))
+			DeferCleanup(ts.Close)
+
+			wd, err := os.Getwd()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(os.Chdir(GinkgoT().TempDir())).To(Succeed())
+			DeferCleanup(os.Chdir, wd)
+			viper.Reset()
+			DeferCleanup(viper.Reset)
+			Expect(os.WriteFile(config.ConfigFile, []byte(fmt.Sprintf(
+				"defaultBackend: gpt-j\nfallbackBackends: [bloom]\n"+
+					"gptj:\n  url: %[1]s/unavailable\nbloom:\n  url: %[1]s/generate\nembeddings:\n  url: %[1]s/embeddings\n",
+				ts.URL,
+			)), 0600)).To(Succeed())
+		})
+
+		It("bounds the requests of every feature together", func() {
+			c := cmd.NewGenerateCmd()
+			Expect(c.Flags().Set(cmd.FlagRequestFull, "Create a Pod")).To(Succeed())
+			Expect(c.Flags().Set(cmd.FlagMaxRetriesFull, "0")).To(Succeed())
+			Expect(c.Flags().Set(cmd.FlagNCompletionsFull, "4")).To(Succeed())
+			Expect(c.Flags().Set(cmd.FlagConcurrencyFull, "3")).To(Succeed())
+			r, err := cmd.PrepareRequest(c)
+			Expect(err).NotTo(HaveOccurred())
+
+			// two generations falling back from gpt-j to bloom, each making 4 requests 3 at a time,
+			// alongside requests embedding files
+			var wg sync.WaitGroup
+			errs := make([]error, 4)
+			for i := range errs {
+				wg.Add(1)
+				go func(i int) {
+					defer GinkgoRecover()
+					defer wg.Done()
+					if i < 2 {
+						single := *r
+						_, _, errs[i] = cmd.GenerateWithFallback(context.Background(), &single, "Create a Pod")
+						return
+					}
+					client := embeddings.NewClient(r.Config.EmbeddingsConfig())
+					_, errs[i] = client.Embed(context.Background(), []string{"kind: Pod"})
+				}(i)
+			}
+			wg.Wait()
+			for _, err := range errs {
+				Expect(err).NotTo(HaveOccurred())
+			}
+			Expect(maxInFlight).To(BeNumerically("<=", 3))
+		})
+
+		It("makes every backend share a limiter", func() {
+			conf := &config.Config{}
+			conf.SetDefaults()
+			cmd.ConfigureConcurrencyLimit(conf, 2)
+			transports := map[http.RoundTripper]bool{}
+			for _, client := range conf.HTTPClients() {
+				Expect(*client).NotTo(BeNil())
+				transports[(*client).Transport] = true
+			}
+			Expect(transports).To(HaveLen(1))
+		})
+	})
+
+	When("a file to write already exists", func() {
+		var fm *filemap.Filemap
+
+		BeforeEach(func() {
+			wd, err := os.Getwd()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(os.Chdir(GinkgoT().TempDir())).To(Succeed())
+			DeferCleanup(os.Chdir, wd)
+			Expect(os.WriteFile("pod.yaml", []byte("kind: Pod # hand-written\n"), 0600)).To(Succeed())
+
+			fm = filemap.NewFilemap()
+			fm.Files["pod.yaml"] = filemap.File{Path: "pod.yaml", Content: "kind: Pod\n"}
+			fm.Files["service.yaml"] = filemap.File{Path: "service.yaml", Content: "kind: Service\n"}
+		})
+
+		read := func(path string) string {
+			content, err := os.ReadFile(path)
+			Expect(err).NotTo(HaveOccurred())
+			return string(content)
+		}
+
+		It("overwrites it by default", func() {
+			Expect(cmd.PrintOrWriteOut(context.Background(), &cmd.Request{Filemap: fm, IsWrite: true})).To(Succeed())
+			Expect(read("pod.yaml")).To(Equal("kind: Pod\n"))
+			Expect(read("service.yaml")).To(Equal("kind: Service\n"))
+		})
+
+		It("overwrites it with the overwrite policy", func() {
+			r := &cmd.Request{Filemap: fm, IsWrite: true, OnConflict: cmd.ConflictPolicyOverwrite}
+			Expect(cmd.PrintOrWriteOut(context.Background(), r)).To(Succeed())
+			Expect(read("pod.yaml")).To(Equal("kind: Pod\n"))
+		})
+
+		It("leaves it untouched with the skip policy", func() {
+			Expect(cmd.PrintOrWriteOut(context.Background(),
+				&cmd.Request{Filemap: fm, IsWrite: true, OnConflict: cmd.ConflictPolicySkip})).To(Succeed())
+			Expect(read("pod.yaml")).To(Equal("kind: Pod # hand-written\n"))
+			Expect(read("service.yaml")).To(Equal("kind: Service\n"))
+			Expect(fm.Files).NotTo(HaveKey("pod.yaml"))
+		})
+
+		It("writes next to it with the suffix policy", func() {
+			Expect(cmd.PrintOrWriteOut(context.Background(),
+				&cmd.Request{Filemap: fm, IsWrite: true, OnConflict: cmd.ConflictPolicySuffix})).To(Succeed())
+			Expect(read("pod.yaml")).To(Equal("kind: Pod # hand-written\n"))
+			Expect(read("pod.generated.yaml")).To(Equal("kind: Pod\n"))
+			Expect(read("service.yaml")).To(Equal("kind: Service\n"))
+			Expect("service.generated.yaml").NotTo(BeAnExistingFile())
+		})
+
+		It("rejects unknown policies", func() {
+			c := cmd.NewGenerateCmd()
+			Expect(c.Flags().Set(cmd.FlagOnConflictFull, "merge")).To(Succeed())
+			Expect(cmd.ValidateFlags(c, []string{})).To(MatchError(ContainSubstring(
+				"--" + cmd.FlagOnConflictFull + " must be one of: overwrite, skip, suffix",
+			)))
+		})
+	})
+
+	When("a file mode is given", func() {
+		It("is parsed as octal permissions", func() {
+			Expect(cmd.ParseFileMode(cmd.FlagFileModeFull, "0755")).To(Equal(os.FileMode(0755)))
+			Expect(cmd.ParseFileMode(cmd.FlagFileModeFull, "600")).To(Equal(os.FileMode(0600)))
+			Expect(cmd.ParseFileMode(cmd.FlagFileModeFull, "")).To(BeZero())
+			for _, invalid := range []string{"rwxr-xr-x", "0", "0999", "01777"} {
+				_, err := cmd.ParseFileMode(cmd.FlagFileModeFull, invalid)
+				Expect(err).To(MatchError(ContainSubstring("expected octal permissions such as 0755")), invalid)
+			}
+		})
+
+		It("writes every file with it", func() {
+			wd, err := os.Getwd()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(os.Chdir(GinkgoT().TempDir())).To(Succeed())
+			DeferCleanup(os.Chdir, wd)
+			Expect(os.WriteFile("hook.sh", []byte("#!/bin/sh\n"), 0600)).To(Succeed())
+
+			fm := filemap.NewFilemap()
+			fm.Files["hook"] = filemap.File{Path: "hook.sh", Content: "#!/bin/sh\nkubectl apply -f .\n"}
+			fm.Files["pre-sync"] = filemap.File{Path: "hooks/pre-sync.sh", Content: "#!/bin/sh\n"}
+			Expect(cmd.PrintOrWriteOut(context.Background(),
+				&cmd.Request{Filemap: fm, IsWrite: true, FileMode: 0755})).To(Succeed())
+			for _, name := range []string{"hook.sh", "hooks/pre-sync.sh"} {
+				info, err := os.Stat(name)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(info.Mode().Perm()).To(Equal(os.FileMode(0755)))
+			}
+		})
+
+		It("requires --write", func() {
+			c := cmd.NewGenerateCmd()
+			Expect(c.Flags().Set(cmd.FlagFileModeFull, "0755")).To(Succeed())
+			Expect(cmd.ValidateFlags(c, []string{})).To(MatchError(ContainSubstring("--" + cmd.FlagWriteFull)))
+		})
+	})
+
+	When("the changes are patched onto the files", func() {
+		pod := "kind: Pod\nmetadata:\n  name: web\nspec:\n  containers:\n  - name: web\n    image: nginx:1.21\n"
+		var fm *filemap.Filemap
+
+		BeforeEach(func() {
+			wd, err := os.Getwd()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(os.Chdir(GinkgoT().TempDir())).To(Succeed())
+			DeferCleanup(os.Chdir, wd)
+			Expect(os.WriteFile("pod.yaml", []byte(pod), 0600)).To(Succeed())
+
+			fm = filemap.NewFilemap()
+			Expect(fm.LoadFile("pod.yaml")).To(Succeed())
+			Expect(fm.Decode("# @pod.yaml\n" + strings.Replace(pod, "nginx:1.21", "nginx:1.23", 1))).To(Succeed())
+		})
+
+		It("keeps the edits made on disk since the file was read", func() {
+			Expect(os.WriteFile("pod.yaml", []byte("# edited by hand\n"+pod), 0600)).To(Succeed())
+			Expect(cmd.PrintOrWriteOut(context.Background(),
+				&cmd.Request{Filemap: fm, IsWrite: true, Patch: true})).To(Succeed())
+			content, err := os.ReadFile("pod.yaml")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(content)).To(Equal("# edited by hand\n" + strings.Replace(pod, "nginx:1.21", "nginx:1.23", 1)))
+		})
+
+		It("writes nothing when the changes no longer apply", func() {
+			conflicting := strings.Replace(pod, "nginx:1.21", "nginx:1.22", 1)
+			Expect(os.WriteFile("pod.yaml", []byte(conflicting), 0600)).To(Succeed())
+			err := cmd.PrintOrWriteOut(context.Background(), &cmd.Request{Filemap: fm, IsWrite: true, Patch: true})
+			Expect(err).To(MatchError(filemap.ErrConflict))
+			Expect(err).To(MatchError(ContainSubstring("pod.yaml")))
+			content, err := os.ReadFile("pod.yaml")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(content)).To(Equal(conflicting))
+		})
+
+		It("reviews the changes to each resource without writing them", func() {
+			Expect(os.WriteFile("pod.yaml", []byte("# edited by hand\n"+pod), 0600)).To(Succeed())
+			reader, writer, err := os.Pipe()
+			Expect(err).NotTo(HaveOccurred())
+			stdout := os.Stdout
+			os.Stdout = writer
+			err = cmd.PrintOrWriteOut(context.Background(), &cmd.Request{Filemap: fm, Review: true, Patch: true})
+			os.Stdout = stdout
+			Expect(writer.Close()).To(Succeed())
+			Expect(err).NotTo(HaveOccurred())
+			out, err := io.ReadAll(reader)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(out)).To(Equal("pod.yaml\n  Pod/web\n    ~ spec.containers[0].image: nginx:1.21 -> nginx:1.23\n"))
+
+			content, err := os.ReadFile("pod.yaml")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(content)).To(Equal("# edited by hand\n" + pod))
+		})
+
+		It("fails with --exit-code only when the files would change", func() {
+			stdout := os.Stdout
+			devNull, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+			Expect(err).NotTo(HaveOccurred())
+			os.Stdout = devNull
+			DeferCleanup(func() {
+				os.Stdout = stdout
+				devNull.Close()
+			})
+
+			Expect(cmd.PrintOrWriteOut(context.Background(), &cmd.Request{Filemap: fm, DryRun: true})).To(Succeed())
+			Expect(cmd.PrintOrWriteOut(context.Background(),
+				&cmd.Request{Filemap: fm, DryRun: true, ExitCode: true})).To(MatchError(cmd.ErrChanges))
+			Expect(cmd.PrintOrWriteOut(context.Background(),
+				&cmd.Request{Filemap: fm, Review: true, ExitCode: true})).To(MatchError(cmd.ErrChanges))
+
+			updated := strings.Replace(pod, "nginx:1.21", "nginx:1.23", 1)
+			Expect(os.WriteFile("pod.yaml", []byte(updated), 0600)).To(Succeed())
+			Expect(cmd.PrintOrWriteOut(context.Background(),
+				&cmd.Request{Filemap: fm, DryRun: true, ExitCode: true})).To(Succeed())
+			Expect(cmd.PrintOrWriteOut(context.Background(),
+				&cmd.Request{Filemap: fm, Review: true, ExitCode: true})).To(Succeed())
+		})
+
+		It("requires --dry-run or --review for --exit-code", func() {
+			c := cmd.NewEditCmd()
+			Expect(c.Flags().Set(cmd.FlagExitCodeFull, "true")).To(Succeed())
+			Expect(cmd.ValidateFlags(c, []string{})).To(MatchError(ContainSubstring("--" + cmd.FlagExitCodeFull)))
+			Expect(c.Flags().Set(cmd.FlagDryRunFull, "true")).To(Succeed())
+			Expect(cmd.ValidateFlags(c, []string{})).To(Succeed())
+		})
+
+		It("requires --write, --dry-run or --review", func() {
+			c := cmd.NewEditCmd()
+			Expect(c.Flags().Set(cmd.FlagPatchFull, "true")).To(Succeed())
+			Expect(cmd.ValidateFlags(c, []string{})).To(MatchError(ContainSubstring("--" + cmd.FlagDryRunFull)))
+			Expect(c.Flags().Set(cmd.FlagReviewFull, "true")).To(Succeed())
+			Expect(cmd.ValidateFlags(c, []string{})).To(Succeed())
+			Expect(c.Flags().Set(cmd.FlagDryRunFull, "true")).To(Succeed())
+			Expect(cmd.ValidateFlags(c, []string{})).To(MatchError(ContainSubstring("only one of them can be printed")))
+		})
+	})
+})
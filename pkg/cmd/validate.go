@@ -0,0 +1,217 @@
+package cmd
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/redhat-et/copilot-ops/pkg/ai"
+	"github.com/redhat-et/copilot-ops/pkg/ai/gpt3"
+	"github.com/redhat-et/copilot-ops/pkg/filemap"
+)
+
+// flagConflict Describes two flags which contradict each other when both are set.
+type flagConflict struct {
+	first  string
+	second string
+	reason string
+}
+
+// flagDependency Describes a flag which only has an effect when another flag is set.
+type flagDependency struct {
+	flag     string
+	requires string
+}
+
+// conflictingFlags Returns every pair of flags which may not be set together.
+func conflictingFlags() []flagConflict {
+	return []flagConflict{
+		{FlagContextSummaryFull, FlagContextSummaryFileFull, "only one context summary may be provided"},
+		{FlagRequestFull, FlagRequestFileFull, "only one request may be provided"},
+		{FlagSelectFull, FlagCompletionStrategyFull, "a selected completion is always used on its own"},
+		{FlagWriteFull, FlagOutputTypeFull, "the output format only applies when printing"},
+		{FlagRecordFull, FlagReplayFull, "a replayed run makes no requests to record"},
+		{FlagDryRunFull, FlagWriteFull, "a dry run never writes files"},
+		{FlagDryRunFull, FlagOutputTypeFull, "a dry run always prints a diff"},
+		{FlagReviewFull, FlagWriteFull, "a review never writes files"},
+		{FlagReviewFull, FlagDryRunFull, "only one of them can be printed"},
+		{FlagReviewFull, FlagOutputTypeFull, "a review is always printed as text"},
+		{FlagPromptOnlyFull, FlagWriteFull, "no files are generated when only printing the prompt"},
+		{FlagCountOnlyFull, FlagWriteFull, "no files are generated when only counting tokens"},
+		{FlagCountOnlyFull, FlagPromptOnlyFull, "only one of them can be printed"},
+		{FlagCountOnlyFull, FlagAutoContextFull, "picking the files makes requests for their embeddings"},
+		{FlagHFModelFull, FlagModelFull, "--" + FlagHFModelFull + " already selects the model"},
+		{FlagQuietFull, FlagLogLevelFull, "--" + FlagQuietFull + " already sets the log level"},
+		{FlagSpecFileFull, FlagHelmChartFull, "a spec file describes Kubernetes YAML rather than Helm values"},
+		{FlagNoContextFull, FlagFilesFull, "no files are included with --" + FlagNoContextFull},
+		{FlagNoContextFull, FlagStdinFileFull, "no files are included with --" + FlagNoContextFull},
+		{FlagStdinFileFull, FlagInteractiveFull, "the answers would be read from stdin too"},
+		{FlagNoContextFull, FlagFilesetsFull, "no files are included with --" + FlagNoContextFull},
+		{FlagNoContextFull, FlagAutoContextFull, "no files are included with --" + FlagNoContextFull},
+		{FlagNoContextFull, FlagFromSnapshotFull, "no files are included with --" + FlagNoContextFull},
+		{FlagCombineFull, FlagOutputDirFull, "every file is written to the path given with --" + FlagCombineFull},
+		{FlagCombineFull, FlagPerNamespaceDirsFull, "every file is written to the path given with --" + FlagCombineFull},
+		{FlagCombineFull, FlagHelmChartFull, "a values file can't be combined with other files"},
+		{FlagNamespaceFull, FlagHelmChartFull, "a values file isn't a Kubernetes resource"},
+		{FlagLabelFull, FlagHelmChartFull, "a values file isn't a Kubernetes resource"},
+		{FlagAnnotationFull, FlagHelmChartFull, "a values file isn't a Kubernetes resource"},
+		{FlagStructuredFull, FlagHelmChartFull, "a values file is decoded from the text of the completion"},
+		{FlagApplyFull, FlagHelmChartFull, "a values file isn't a Kubernetes resource"},
+	}
+}
+
+// dependentFlags Returns every flag which requires another flag to also be set.
+func dependentFlags() []flagDependency {
+	return []flagDependency{
+		{FlagGitBranchFull, FlagWriteFull},
+		{FlagNoCacheFull, FlagCacheDirFull},
+		{FlagCacheTTLFull, FlagCacheDirFull},
+		{FlagInteractiveFull, FlagWriteFull},
+		{FlagFileModeFull, FlagWriteFull},
+		{FlagPostHookFull, FlagWriteFull},
+		{FlagApplyFull, FlagWriteFull},
+		{FlagKubeContextFull, FlagApplyFull},
+		{FlagVarFull, FlagTemplateRequestFull},
+		{FlagContextFilesFull, FlagAutoContextFull},
+	}
+}
+
+// ValidateFlags Checks the flags given to a command for contradictory or incomplete
+// combinations, returning an error which lists every problem found.
+func ValidateFlags(cmd *cobra.Command, args []string) error {
+	flags := cmd.Flags()
+	var problems []string
+
+	for _, c := range conflictingFlags() {
+		if flags.Changed(c.first) && flags.Changed(c.second) {
+			problems = append(problems, fmt.Sprintf("--%s and --%s cannot be used together: %s", c.first, c.second, c.reason))
+		}
+	}
+	for _, d := range dependentFlags() {
+		if flags.Changed(d.flag) && !flags.Changed(d.requires) {
+			problems = append(problems, fmt.Sprintf("--%s requires --%s to be set", d.flag, d.requires))
+		}
+	}
+
+	// flags whose values depend on each other
+	if request, _ := flags.GetString(FlagRequestFull); request == StdinRequest && flags.Changed(FlagStdinFileFull) {
+		problems = append(problems, fmt.Sprintf("--%s %q and --%s cannot be used together: both would read stdin",
+			FlagRequestFull, StdinRequest, FlagStdinFileFull))
+	}
+	// the upper bound depends on the number of completions, which ValidateRequest checks once it's resolved
+	if selection, err := flags.GetInt32(FlagSelectFull); err == nil && flags.Changed(FlagSelectFull) && selection < 1 {
+		problems = append(problems, fmt.Sprintf("--%s must be at least 1", FlagSelectFull))
+	}
+	if flags.Changed(FlagHFModelFull) && flags.Changed(FlagAIBackendFull) {
+		if backend, _ := flags.GetString(FlagAIBackendFull); ai.Backend(backend) != ai.HUGGINGFACE {
+			problems = append(problems, fmt.Sprintf("--%s can only be used with the %s backend", FlagHFModelFull, ai.HUGGINGFACE))
+		}
+	}
+	if flags.Changed(FlagTopPFull) {
+		if topP, _ := flags.GetFloat32(FlagTopPFull); topP <= 0 || topP > 1 {
+			problems = append(problems, fmt.Sprintf("--%s must be greater than 0 and at most 1", FlagTopPFull))
+		}
+	}
+	switch strategy, _ := flags.GetString(FlagTrimStrategyFull); strategy {
+	case "", TrimStrategyDropFiles, TrimStrategyTruncate:
+	default:
+		problems = append(problems, fmt.Sprintf("--%s must be one of: %s, %s",
+			FlagTrimStrategyFull, TrimStrategyDropFiles, TrimStrategyTruncate))
+	}
+	switch policy, _ := flags.GetString(FlagOnConflictFull); policy {
+	case "", ConflictPolicyOverwrite, ConflictPolicySkip, ConflictPolicySuffix:
+	default:
+		problems = append(problems, fmt.Sprintf("--%s must be one of: %s, %s, %s",
+			FlagOnConflictFull, ConflictPolicyOverwrite, ConflictPolicySkip, ConflictPolicySuffix))
+	}
+	if namespace, _ := flags.GetString(FlagNamespaceFull); namespace != "" && !filemap.ValidNamespace(namespace) {
+		problems = append(problems, fmt.Sprintf("--%s must be a valid namespace name, such as my-namespace", FlagNamespaceFull))
+	}
+	if flags.Changed(FlagOverwriteMetadataFull) && !flags.Changed(FlagLabelFull) && !flags.Changed(FlagAnnotationFull) {
+		problems = append(problems, fmt.Sprintf("--%s requires --%s or --%s to be set",
+			FlagOverwriteMetadataFull, FlagLabelFull, FlagAnnotationFull))
+	}
+	if flags.Changed(FlagPatchFull) && !flags.Changed(FlagWriteFull) && !flags.Changed(FlagDryRunFull) &&
+		!flags.Changed(FlagReviewFull) {
+		problems = append(problems, fmt.Sprintf("--%s requires --%s, --%s or --%s to be set",
+			FlagPatchFull, FlagWriteFull, FlagDryRunFull, FlagReviewFull))
+	}
+	if flags.Changed(FlagExitCodeFull) && !flags.Changed(FlagDryRunFull) && !flags.Changed(FlagReviewFull) {
+		problems = append(problems, fmt.Sprintf("--%s requires --%s or --%s to be set",
+			FlagExitCodeFull, FlagDryRunFull, FlagReviewFull))
+	}
+	if name, err := flags.GetString(FlagGeneratedNameFull); err == nil && flags.Changed(FlagGeneratedNameFull) &&
+		(name == "" || path.IsAbs(name) || strings.HasPrefix(path.Clean(name), "..")) {
+		problems = append(problems, fmt.Sprintf("--%s must be a relative path within the output directory",
+			FlagGeneratedNameFull))
+	}
+	if stop, err := flags.GetStringArray(FlagStopFull); err == nil {
+		// the end-of-sequence terminator takes up one of the stop sequences
+		if len(stop) > gpt3.MaxStopSequences-1 {
+			problems = append(problems, fmt.Sprintf("--%s can be given at most %d times, since OpenAI accepts %d stop "+
+				"sequences including the end-of-sequence terminator", FlagStopFull, gpt3.MaxStopSequences-1, gpt3.MaxStopSequences))
+		}
+		for _, sequence := range stop {
+			if sequence == "" {
+				problems = append(problems, fmt.Sprintf("--%s can't be empty", FlagStopFull))
+				break
+			}
+		}
+	}
+	if maxFileSize, err := flags.GetInt64(FlagMaxFileSizeFull); err == nil && maxFileSize < 0 {
+		problems = append(problems, fmt.Sprintf("--%s must not be negative", FlagMaxFileSizeFull))
+	}
+	if concurrency, err := flags.GetInt(FlagConcurrencyFull); err == nil && concurrency < 1 {
+		problems = append(problems, fmt.Sprintf("--%s must be at least 1", FlagConcurrencyFull))
+	}
+	if rateLimit, err := flags.GetFloat64(FlagRateLimitFull); err == nil && rateLimit < 0 {
+		problems = append(problems, fmt.Sprintf("--%s cannot be negative", FlagRateLimitFull))
+	}
+	if contextFiles, err := flags.GetInt(FlagContextFilesFull); err == nil && contextFiles < 1 {
+		problems = append(problems, fmt.Sprintf("--%s must be at least 1", FlagContextFilesFull))
+	}
+	if maxRetries, err := flags.GetInt(FlagMaxRetriesFull); err == nil && maxRetries < 0 {
+		problems = append(problems, fmt.Sprintf("--%s cannot be negative", FlagMaxRetriesFull))
+	}
+	if baseDelay, err := flags.GetDuration(FlagRetryBaseDelayFull); err == nil && baseDelay < 0 {
+		problems = append(problems, fmt.Sprintf("--%s cannot be negative", FlagRetryBaseDelayFull))
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("invalid combination of flags:\n - %s", strings.Join(problems, "\n - "))
+	}
+	return nil
+}
+
+// ValidateRequest Checks the settings of the request which may come from the flags, a fileset or a profile,
+// once they're resolved, so that they're checked wherever they were set.
+func ValidateRequest(r *Request) error {
+	var problems []string
+	if r.Select != 0 && (r.Select < 1 || r.Select > r.NCompletions) {
+		problems = append(problems, fmt.Sprintf("--%s must be between 1 and the number of completions (%d)",
+			FlagSelectFull, r.NCompletions))
+	}
+	if r.CompletionStrategy != "" && r.CompletionStrategy != CompletionStrategyAll && r.NCompletions < 2 {
+		problems = append(problems, fmt.Sprintf("--%s %q requires more than one completion, got %d",
+			FlagCompletionStrategyFull, r.CompletionStrategy, r.NCompletions))
+	}
+	if maxTemperature := MaxTemperatureFor(r.Backend); r.Temperature < 0 || r.Temperature > maxTemperature {
+		problems = append(problems, fmt.Sprintf("the temperature must be between 0 and %.1f for the %q backend, got %g",
+			maxTemperature, r.Backend, r.Temperature))
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("invalid request:\n - %s", strings.Join(problems, "\n - "))
+	}
+	return nil
+}
+
+// MaxTemperatureFor Returns the highest sampling temperature the backend accepts.
+func MaxTemperatureFor(backend ai.Backend) float32 {
+	if backend == ai.CLAUDE {
+		return MaxClaudeTemperature
+	}
+	return MaxTemperature
+}
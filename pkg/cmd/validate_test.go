@@ -0,0 +1,82 @@
+package cmd_test
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/spf13/cobra"
+
+	"github.com/redhat-et/copilot-ops/pkg/cmd"
+)
+
+var _ = Describe("Flag validation", func() {
+	var c *cobra.Command
+
+	BeforeEach(func() {
+		c = cmd.NewGenerateCmd()
+	})
+
+	It("accepts the defaults", func() {
+		Expect(cmd.ValidateFlags(c, []string{})).To(Succeed())
+	})
+
+	It("rejects mutually exclusive flags", func() {
+		Expect(c.Flags().Set(cmd.FlagContextSummaryFull, "a web store")).To(Succeed())
+		Expect(c.Flags().Set(cmd.FlagContextSummaryFileFull, "summary.md")).To(Succeed())
+		err := cmd.ValidateFlags(c, []string{})
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("--" + cmd.FlagContextSummaryFull))
+	})
+
+	It("rejects flags missing their dependencies", func() {
+		Expect(c.Flags().Set(cmd.FlagGitBranchFull, "copilot/pod")).To(Succeed())
+		Expect(cmd.ValidateFlags(c, []string{})).NotTo(Succeed())
+
+		Expect(c.Flags().Set(cmd.FlagWriteFull, "true")).To(Succeed())
+		Expect(cmd.ValidateFlags(c, []string{})).To(Succeed())
+	})
+
+	It("only uses a completion strategy with more than one completion", func() {
+		Expect(c.Flags().Set(cmd.FlagCompletionStrategyFull, cmd.CompletionStrategyFirst)).To(Succeed())
+		Expect(cmd.ValidateFlags(c, []string{})).To(Succeed())
+
+		r := &cmd.Request{NCompletions: 1, CompletionStrategy: cmd.CompletionStrategyFirst}
+		Expect(cmd.ValidateRequest(r)).To(MatchError(ContainSubstring("--" + cmd.FlagCompletionStrategyFull)))
+		r.NCompletions = 3
+		Expect(cmd.ValidateRequest(r)).To(Succeed())
+		Expect(cmd.ValidateRequest(&cmd.Request{NCompletions: 1, CompletionStrategy: cmd.CompletionStrategyAll})).
+			To(Succeed())
+	})
+
+	It("rejects a selection out of range", func() {
+		Expect(c.Flags().Set(cmd.FlagSelectFull, "0")).To(Succeed())
+		Expect(cmd.ValidateFlags(c, []string{})).To(MatchError(ContainSubstring("--" + cmd.FlagSelectFull)))
+
+		Expect(cmd.ValidateRequest(&cmd.Request{NCompletions: 2, Select: 3})).To(
+			MatchError(ContainSubstring("--" + cmd.FlagSelectFull)))
+		Expect(cmd.ValidateRequest(&cmd.Request{NCompletions: 3, Select: 3})).To(Succeed())
+	})
+	It("rejects an invalid namespace", func() {
+		Expect(c.Flags().Set(cmd.FlagNamespaceFull, "My_Namespace")).To(Succeed())
+		Expect(cmd.ValidateFlags(c, []string{})).To(MatchError(ContainSubstring("--" + cmd.FlagNamespaceFull)))
+	})
+
+	It("only overwrites metadata when labels or annotations are given", func() {
+		Expect(c.Flags().Set(cmd.FlagOverwriteMetadataFull, "true")).To(Succeed())
+		Expect(cmd.ValidateFlags(c, []string{})).To(MatchError(ContainSubstring("--" + cmd.FlagOverwriteMetadataFull)))
+
+		Expect(c.Flags().Set(cmd.FlagAnnotationFull, "owner=web")).To(Succeed())
+		Expect(cmd.ValidateFlags(c, []string{})).To(Succeed())
+	})
+
+	It("rejects a negative number of retries", func() {
+		Expect(c.Flags().Set(cmd.FlagMaxRetriesFull, "-1")).To(Succeed())
+		Expect(cmd.ValidateFlags(c, []string{})).NotTo(Succeed())
+	})
+	It("only uses a HuggingFace model with the huggingface backend", func() {
+		Expect(c.Flags().Set(cmd.FlagHFModelFull, "bigcode/starcoder")).To(Succeed())
+		Expect(cmd.ValidateFlags(c, []string{})).To(Succeed())
+
+		Expect(c.Flags().Set(cmd.FlagAIBackendFull, "gpt-3")).To(Succeed())
+		Expect(cmd.ValidateFlags(c, []string{})).NotTo(Succeed())
+	})
+})
@@ -0,0 +1,118 @@
+package filemap
+
+import "strings"
+
+// MinUnchangedRatio Is the share of non-blank lines which must be unchanged for
+// a file to be considered largely unchanged, so that its blank lines are restored.
+const MinUnchangedRatio = 0.5
+
+// RestoreBlankLines Restores the blank lines from the original content between lines
+// which are unchanged in the updated content. Models tend to collapse blank lines,
+// which makes edits noisier to review than they need to be.
+// The updated content is returned as-is when it's not largely unchanged from the original.
+func RestoreBlankLines(original, updated string) string {
+	origLines := strings.Split(original, "\n")
+	newLines := strings.Split(updated, "\n")
+	origIdx := nonBlankIndexes(origLines)
+	newIdx := nonBlankIndexes(newLines)
+	if len(origIdx) == 0 || len(newIdx) == 0 {
+		return updated
+	}
+
+	// match up the non-blank lines which are unchanged
+	matches := matchUnchanged(origLines, origIdx, newLines, newIdx)
+	longest := len(origIdx)
+	if len(newIdx) > longest {
+		longest = len(newIdx)
+	}
+	if float64(len(matches))/float64(longest) < MinUnchangedRatio {
+		return updated
+	}
+
+	// origMatch maps the line number of each unchanged line in the update to its line in the original
+	origMatch := make(map[int]int, len(matches))
+	for _, m := range matches {
+		origMatch[m[1]] = m[0]
+	}
+
+	out := make([]string, 0, len(newLines))
+	for k, i := range newIdx {
+		if k > 0 {
+			prev := newIdx[k-1]
+			origPrev, prevOK := origMatch[prev]
+			origCur, curOK := origMatch[i]
+			if prevOK && curOK && nextNonBlank(origIdx, origPrev) == origCur {
+				// an unchanged region: use the original's blank lines
+				out = append(out, origLines[origPrev+1:origCur]...)
+			} else {
+				out = append(out, newLines[prev+1:i]...)
+			}
+		} else {
+			out = append(out, newLines[:i]...)
+		}
+		out = append(out, newLines[i])
+	}
+	out = append(out, newLines[newIdx[len(newIdx)-1]+1:]...)
+	return strings.Join(out, "\n")
+}
+
+// nonBlankIndexes Returns the line numbers of every line with content.
+func nonBlankIndexes(lines []string) []int {
+	indexes := make([]int, 0, len(lines))
+	for i, line := range lines {
+		if strings.TrimSpace(line) != "" {
+			indexes = append(indexes, i)
+		}
+	}
+	return indexes
+}
+
+// nextNonBlank Returns the line number of the non-blank line following line i.
+func nextNonBlank(indexes []int, i int) int {
+	for k, idx := range indexes {
+		if idx == i && k+1 < len(indexes) {
+			return indexes[k+1]
+		}
+	}
+	return -1
+}
+
+// matchUnchanged Finds the longest common subsequence of the non-blank lines,
+// returning pairs of matching line numbers from the original and updated lines.
+func matchUnchanged(origLines []string, origIdx []int, newLines []string, newIdx []int) [][2]int {
+	same := func(a, b int) bool {
+		return strings.TrimRight(origLines[origIdx[a]], " \t\r") == strings.TrimRight(newLines[newIdx[b]], " \t\r")
+	}
+	n, m := len(origIdx), len(newIdx)
+	lcs := make([][]int, n+1)
+	for a := range lcs {
+		lcs[a] = make([]int, m+1)
+	}
+	for a := n - 1; a >= 0; a-- {
+		for b := m - 1; b >= 0; b-- {
+			switch {
+			case same(a, b):
+				lcs[a][b] = lcs[a+1][b+1] + 1
+			case lcs[a+1][b] >= lcs[a][b+1]:
+				lcs[a][b] = lcs[a+1][b]
+			default:
+				lcs[a][b] = lcs[a][b+1]
+			}
+		}
+	}
+
+	var matches [][2]int
+	for a, b := 0, 0; a < n && b < m; {
+		switch {
+		case same(a, b):
+			matches = append(matches, [2]int{origIdx[a], newIdx[b]})
+			a++
+			b++
+		case lcs[a+1][b] >= lcs[a][b+1]:
+			a++
+		default:
+			b++
+		}
+	}
+	return matches
+}
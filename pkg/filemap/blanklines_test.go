@@ -0,0 +1,76 @@
+package filemap_test
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	. "github.com/redhat-et/copilot-ops/pkg/filemap"
+)
+
+var _ = Describe("Blank lines", func() {
+	const original = `apiVersion: v1
+kind: Pod
+
+metadata:
+  name: web
+
+
+spec:
+  containers:
+    - name: web
+      image: nginx:1.23
+`
+
+	It("restores the blank lines around unchanged regions", func() {
+		updated := `apiVersion: v1
+kind: Pod
+metadata:
+  name: web
+spec:
+  containers:
+    - name: web
+      image: nginx:1.25
+`
+		Expect(RestoreBlankLines(original, updated)).To(Equal(`apiVersion: v1
+kind: Pod
+
+metadata:
+  name: web
+
+
+spec:
+  containers:
+    - name: web
+      image: nginx:1.25
+`))
+	})
+
+	It("keeps the blank lines of changed regions", func() {
+		updated := `apiVersion: v1
+kind: Pod
+metadata:
+  name: api
+
+spec:
+  containers:
+    - name: web
+      image: nginx:1.23
+`
+		Expect(RestoreBlankLines(original, updated)).To(Equal(`apiVersion: v1
+kind: Pod
+
+metadata:
+  name: api
+
+spec:
+  containers:
+    - name: web
+      image: nginx:1.23
+`))
+	})
+
+	It("leaves rewritten files alone", func() {
+		updated := "kind: Service\nmetadata:\n  name: web\n"
+		Expect(RestoreBlankLines(original, updated)).To(Equal(updated))
+	})
+})
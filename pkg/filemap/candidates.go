@@ -0,0 +1,48 @@
+package filemap
+
+import (
+	"io/fs"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// MaxCandidateSize Is the size in bytes of the largest file which is picked as context automatically,
+// since larger files are rarely manifests and would crowd out the rest of the prompt.
+const MaxCandidateSize = 64 * 1024
+
+// Candidates Returns the files under dir which could be picked as context, sorted by name:
+// every regular file which isn't hidden, ignored, already in the filemap, or larger than MaxCandidateSize.
+func (fm *Filemap) Candidates(dir string) ([]string, error) {
+	loaded := make(map[string]bool, len(fm.Files))
+	for _, file := range fm.Files {
+		loaded[filepath.Clean(file.Path)] = true
+	}
+
+	var candidates []string
+	err := filepath.WalkDir(dir, func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		hidden := name != dir && strings.HasPrefix(d.Name(), ".")
+		if d.IsDir() {
+			if hidden || (name != dir && fm.gitignore.Ignored(name, true)) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if hidden || !d.Type().IsRegular() || loaded[filepath.Clean(name)] || fm.isIgnored(name) {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		if info.Size() <= MaxCandidateSize {
+			candidates = append(candidates, name)
+		}
+		return nil
+	})
+	sort.Strings(candidates)
+	return candidates, err
+}
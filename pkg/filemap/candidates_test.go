@@ -0,0 +1,44 @@
+package filemap_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	. "github.com/redhat-et/copilot-ops/pkg/filemap"
+)
+
+var _ = Describe("Candidates", func() {
+	BeforeEach(func() {
+		wd, err := os.Getwd()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(os.Chdir(GinkgoT().TempDir())).To(Succeed())
+		DeferCleanup(os.Chdir, wd)
+		files := map[string]string{
+			"k8s/pod.yaml":      "kind: Pod\n",
+			"k8s/service.yaml":  "kind: Service\n",
+			"k8s/db.secret":     "password\n",
+			"build/out.yaml":    "kind: Pod\n",
+			".git/config":       "[core]\n",
+			".copilot-ops.yaml": "filesets: []\n",
+			"k8s/large.yaml":    strings.Repeat("#", MaxCandidateSize+1),
+			GitignoreFile:       "*.secret\nbuild/\n",
+		}
+		for name, content := range files {
+			Expect(os.MkdirAll(filepath.Dir(name), 0755)).To(Succeed())
+			Expect(os.WriteFile(name, []byte(content), 0600)).To(Succeed())
+		}
+	})
+
+	It("lists the files which could be picked as context", func() {
+		fm := NewFilemap()
+		Expect(fm.UseGitignore(".")).To(Succeed())
+		Expect(fm.LoadFile(filepath.Join("k8s", "service.yaml"))).To(Succeed())
+		candidates, err := fm.Candidates(".")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(candidates).To(Equal([]string{filepath.Join("k8s", "pod.yaml")}))
+	})
+})
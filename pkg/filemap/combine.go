@@ -0,0 +1,69 @@
+package filemap
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// DocumentSeparator Separates the documents of a multi-document YAML file.
+const DocumentSeparator = "---"
+
+// Combine Replaces every file in the filemap with a single multi-document YAML file at the given path.
+// The documents are ordered by the path of the file they came from, or its tag when it has no path,
+// so that the same files are always combined the same way. Files which aren't YAML can't be combined.
+func (fm *Filemap) Combine(path string) error {
+	names := make(map[string]string, len(fm.Files))
+	tags := make([]string, 0, len(fm.Files))
+	for tag, file := range fm.Files {
+		if file.FileType(tag) != FileTypeYAML {
+			return fmt.Errorf("cannot combine %s, which is not YAML", tag)
+		}
+		names[tag] = file.Path
+		if names[tag] == "" {
+			names[tag] = tag
+		}
+		tags = append(tags, tag)
+	}
+	sort.Slice(tags, func(i, j int) bool {
+		if names[tags[i]] != names[tags[j]] {
+			return names[tags[i]] < names[tags[j]]
+		}
+		return tags[i] < tags[j]
+	})
+
+	var documents []string
+	for _, tag := range tags {
+		if document := trimDocument(fm.Files[tag].Content); document != "" {
+			documents = append(documents, document)
+		}
+	}
+	content := strings.Join(documents, "\n"+DocumentSeparator+"\n")
+	if content != "" {
+		content += "\n"
+	}
+	fm.Files = map[string]File{
+		filepath.Base(path): {
+			Name:    filepath.Base(path),
+			Path:    path,
+			Content: content,
+			Type:    FileTypeYAML,
+		},
+	}
+	fm.loaded = nil
+	return nil
+}
+
+// trimDocument Removes the blank lines and document separators around the content,
+// which are added back between the documents when they are combined.
+func trimDocument(content string) string {
+	content = strings.TrimSpace(content)
+	for strings.HasPrefix(content, DocumentSeparator+"\n") || content == DocumentSeparator {
+		content = strings.TrimSpace(strings.TrimPrefix(content, DocumentSeparator))
+	}
+	for strings.HasSuffix(content, "\n"+DocumentSeparator) {
+		content = strings.TrimSpace(strings.TrimSuffix(content, DocumentSeparator))
+	}
+	return content
+}
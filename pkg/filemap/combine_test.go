@@ -0,0 +1,69 @@
+package filemap_test
+
+import (
+	"errors"
+	"io"
+	"strings"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"gopkg.in/yaml.v2"
+
+	. "github.com/redhat-et/copilot-ops/pkg/filemap"
+)
+
+// documents Decodes every non-empty document of the YAML content.
+func documents(content string) []interface{} {
+	var docs []interface{}
+	decoder := yaml.NewDecoder(strings.NewReader(content))
+	for {
+		var doc interface{}
+		err := decoder.Decode(&doc)
+		if errors.Is(err, io.EOF) {
+			return docs
+		}
+		Expect(err).NotTo(HaveOccurred())
+		if doc != nil {
+			docs = append(docs, doc)
+		}
+	}
+}
+
+var _ = Describe("Combine", func() {
+	var fm *Filemap
+
+	BeforeEach(func() {
+		fm = NewFilemap()
+		fm.Files["service.yaml"] = File{Path: "app/service.yaml", Content: "---\nkind: Service\nmetadata:\n  name: web\n"}
+		fm.Files["deployment.yaml"] = File{Path: "app/deployment.yaml", Content: "kind: Deployment\nmetadata:\n  name: web\n"}
+		fm.Files["rbac"] = File{Content: "kind: Role\nmetadata:\n  name: reader\n---\nkind: RoleBinding\n" +
+			"metadata:\n  name: reader\n---\n"}
+	})
+
+	It("joins every file into a single multi-document file", func() {
+		var expected []interface{}
+		for _, file := range fm.Files {
+			expected = append(expected, documents(file.Content)...)
+		}
+
+		Expect(fm.Combine("manifests.yaml")).To(Succeed())
+		Expect(fm.Files).To(HaveLen(1))
+		combined := fm.Files["manifests.yaml"]
+		Expect(combined.Path).To(Equal("manifests.yaml"))
+		Expect(documents(combined.Content)).To(ConsistOf(expected...))
+	})
+
+	It("orders the documents by path", func() {
+		Expect(fm.Combine("out/all.yaml")).To(Succeed())
+		Expect(fm.Files["all.yaml"].Content).To(Equal(
+			"kind: Deployment\nmetadata:\n  name: web\n---\n" +
+				"kind: Service\nmetadata:\n  name: web\n---\n" +
+				"kind: Role\nmetadata:\n  name: reader\n---\nkind: RoleBinding\nmetadata:\n  name: reader\n",
+		))
+	})
+
+	It("refuses to combine other types of files", func() {
+		fm.Files["config.json"] = File{Path: "config.json", Content: "{}"}
+		Expect(fm.Combine("manifests.yaml")).To(MatchError(ContainSubstring("config.json")))
+	})
+})
@@ -0,0 +1,77 @@
+package filemap
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// GeneratedSuffix Is inserted before the extension of a file which is written next to an existing one.
+const GeneratedSuffix = ".generated"
+
+// SuffixedPath Returns the path with GeneratedSuffix inserted before its extension,
+// e.g. 'pod.generated.yaml' for 'pod.yaml'.
+func SuffixedPath(path string) string {
+	ext := filepath.Ext(path)
+	return strings.TrimSuffix(path, ext) + GeneratedSuffix + ext
+}
+
+// existingTags Returns the tags of the files which already exist on disk, in order of their paths.
+func (fm *Filemap) existingTags() ([]string, error) {
+	var tags []string
+	for _, tag := range fm.TagsByPath() {
+		file := fm.Files[tag]
+		if file.Path == "" || file.URL != "" {
+			continue
+		}
+		_, err := os.Stat(file.Path)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("could not check whether %q exists: %w", file.Path, err)
+		}
+		tags = append(tags, tag)
+	}
+	return tags, nil
+}
+
+// SkipExisting Removes the files which already exist on disk from the filemap, so that only new
+// files are written. The paths of the removed files are returned.
+func (fm *Filemap) SkipExisting() ([]string, error) {
+	tags, err := fm.existingTags()
+	if err != nil {
+		return nil, err
+	}
+	paths := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		paths = append(paths, fm.Files[tag].Path)
+		delete(fm.Files, tag)
+	}
+	return paths, nil
+}
+
+// SuffixExisting Moves the files which already exist on disk to their SuffixedPath, so that they're
+// written next to the existing files instead of over them. The new paths are returned.
+func (fm *Filemap) SuffixExisting() ([]string, error) {
+	tags, err := fm.existingTags()
+	if err != nil {
+		return nil, err
+	}
+	paths := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		file := fm.Files[tag]
+		file.Path = SuffixedPath(file.Path)
+		file.Name = filepath.Base(file.Path)
+		// the new file has nothing in common with the one which was loaded
+		file.original = nil
+		fm.Files[tag] = file
+		paths = append(paths, file.Path)
+	}
+	sort.Strings(paths)
+	return paths, nil
+}
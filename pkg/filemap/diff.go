@@ -0,0 +1,190 @@
+package filemap
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/pmezard/go-difflib/difflib"
+)
+
+// DiffContextLines Is the number of unchanged lines shown around each change in a diff.
+const DiffContextLines = 3
+
+// DevNull Is the name used in a diff for the missing side of a new file.
+const DevNull = "/dev/null"
+
+// Diff Returns a unified diff between the content of each file on disk and its
+// content in the filemap, without modifying anything. Files which don't exist yet
+// are shown as entirely added. Files are diffed in order of their paths.
+func (fm *Filemap) Diff() (string, error) {
+	var out strings.Builder
+	for _, tag := range fm.TagsByPath() {
+		diff, _, err := DiffFile(tag, fm.Files[tag])
+		if err != nil {
+			return "", err
+		}
+		out.WriteString(diff)
+	}
+	return out.String(), nil
+}
+
+// TagsByPath Returns the tags of every file in the filemap, in order of the paths they're written to,
+// and of their tags when the paths are the same.
+func (fm *Filemap) TagsByPath() []string {
+	tags := make([]string, 0, len(fm.Files))
+	for tag := range fm.Files {
+		tags = append(tags, tag)
+	}
+	sort.Slice(tags, func(i, j int) bool {
+		pathI := filepath.Clean(diffPath(tags[i], fm.Files[tags[i]]))
+		pathJ := filepath.Clean(diffPath(tags[j], fm.Files[tags[j]]))
+		if pathI != pathJ {
+			return pathI < pathJ
+		}
+		return tags[i] < tags[j]
+	})
+	return tags
+}
+
+// DiffFile Returns a unified diff between the file on disk and the given content of the file,
+// and whether the file already exists. The diff is empty when nothing would change.
+func DiffFile(tag string, file File) (string, bool, error) {
+	path := diffPath(tag, file)
+	fromName := "a/" + path
+	exists := true
+	current, err := os.ReadFile(file.Path)
+	if errors.Is(err, fs.ErrNotExist) || file.Path == "" {
+		fromName = DevNull
+		exists = false
+	} else if err != nil {
+		return "", false, fmt.Errorf("could not read %q: %w", file.Path, err)
+	}
+	return UnifiedDiff(fromName, "b/"+path, string(current), file.Content, DiffContextLines), exists, nil
+}
+
+// diffPath Returns the path a file is shown as in a diff, falling back to its tag.
+func diffPath(tag string, file File) string {
+	if file.Path != "" {
+		return file.Path
+	}
+	return tag
+}
+
+// diffLine Is a single line of a diff, marked as unchanged (' '), removed ('-') or added ('+').
+type diffLine struct {
+	kind byte
+	text string
+}
+
+// UnifiedDiff Returns the changes between from and to in the unified diff format,
+// with the given number of unchanged lines around each change.
+// An empty string is returned when the contents are identical.
+func UnifiedDiff(fromName, toName, from, to string, context int) string {
+	if from == to {
+		return ""
+	}
+	var out strings.Builder
+	fmt.Fprintf(&out, "--- %s\n+++ %s\n", fromName, toName)
+	for _, h := range diffHunks(splitLinesKeepEnds(from), splitLinesKeepEnds(to), context) {
+		out.WriteString(h.header())
+		for _, l := range h.lines {
+			out.WriteByte(l.kind)
+			out.WriteString(l.text)
+			if !strings.HasSuffix(l.text, "\n") {
+				out.WriteString("\n\\ No newline at end of file\n")
+			}
+		}
+	}
+	return out.String()
+}
+
+// hunk Is a group of changes which are close enough to share their unchanged lines.
+type hunk struct {
+	// fromStart and toStart Are the number of lines preceding the hunk on each side.
+	fromStart, toStart int
+	lines              []diffLine
+}
+
+// header Returns the line which starts the hunk in a unified diff.
+func (h hunk) header() string {
+	return fmt.Sprintf("@@ -%s +%s @@\n",
+		hunkRange(h.fromStart, len(h.from())), hunkRange(h.toStart, len(h.to())))
+}
+
+// from Returns the lines which the hunk replaces.
+func (h hunk) from() []string {
+	return h.side('+')
+}
+
+// to Returns the lines which the hunk replaces them with.
+func (h hunk) to() []string {
+	return h.side('-')
+}
+
+// side Returns the text of every line of the hunk, except those of the given kind.
+func (h hunk) side(skip byte) []string {
+	text := make([]string, 0, len(h.lines))
+	for _, l := range h.lines {
+		if l.kind != skip {
+			text = append(text, l.text)
+		}
+	}
+	return text
+}
+
+// diffHunks Groups the changes between the lines into hunks, with the given number
+// of unchanged lines around each change. Removed lines come before the lines added in their place.
+func diffHunks(from, to []string, context int) []hunk {
+	// without automatic junk, lines repeated throughout a manifest such as '- name:' still match
+	matcher := difflib.NewMatcherWithJunk(from, to, false, nil)
+	var hunks []hunk
+	for _, group := range matcher.GetGroupedOpCodes(context) {
+		h := hunk{fromStart: group[0].I1, toStart: group[0].J1}
+		for _, op := range group {
+			if op.Tag == 'e' {
+				h.lines = appendDiffLines(h.lines, ' ', from[op.I1:op.I2])
+				continue
+			}
+			h.lines = appendDiffLines(h.lines, '-', from[op.I1:op.I2])
+			h.lines = appendDiffLines(h.lines, '+', to[op.J1:op.J2])
+		}
+		hunks = append(hunks, h)
+	}
+	return hunks
+}
+
+// appendDiffLines Appends the text of every line to the diff lines as the given kind.
+func appendDiffLines(lines []diffLine, kind byte, text []string) []diffLine {
+	for _, t := range text {
+		lines = append(lines, diffLine{kind: kind, text: t})
+	}
+	return lines
+}
+
+// hunkRange Formats the range of lines covered by a hunk, where start is the
+// number of lines preceding it.
+func hunkRange(start, count int) string {
+	switch count {
+	case 0:
+		return fmt.Sprintf("%d,0", start)
+	case 1:
+		return fmt.Sprintf("%d", start+1)
+	default:
+		return fmt.Sprintf("%d,%d", start+1, count)
+	}
+}
+
+// splitLinesKeepEnds Splits the text into lines, each keeping its line ending,
+// so that a missing newline at the end of the text counts as a change.
+func splitLinesKeepEnds(text string) []string {
+	lines := strings.SplitAfter(text, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
@@ -0,0 +1,147 @@
+package filemap_test
+
+import (
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/redhat-et/copilot-ops/pkg/filemap"
+)
+
+var _ = Describe("Diff", func() {
+	It("is empty for identical content", func() {
+		Expect(filemap.UnifiedDiff("a/pod.yaml", "b/pod.yaml", "kind: Pod\n", "kind: Pod\n", 3)).To(BeEmpty())
+	})
+
+	It("shows changes with their context", func() {
+		from := "a\nb\nc\nd\ne\nf\ng\nh\ni\nj\n"
+		to := "a\nb\nc\nd\nE\nf\ng\nh\ni\nj\nk\n"
+		Expect(filemap.UnifiedDiff("a/x", "b/x", from, to, 1)).To(Equal(`--- a/x
++++ b/x
+@@ -4,3 +4,3 @@
+ d
+-e
++E
+ f
+@@ -10 +10,2 @@
+ j
++k
+`))
+	})
+
+	It("marks a missing newline at the end of the file", func() {
+		Expect(filemap.UnifiedDiff("a/x", "b/x", "a\nb", "a\nb\n", 3)).To(Equal(`--- a/x
++++ b/x
+@@ -1,2 +1,2 @@
+ a
+-b
+\ No newline at end of file
++b
+`))
+	})
+
+	It("holds every change between the lines", func() {
+		rng := rand.New(rand.NewSource(1))
+		randomLines := func() []string {
+			lines := make([]string, rng.Intn(30))
+			for i := range lines {
+				lines[i] = string(rune('a'+rng.Intn(4))) + "\n"
+			}
+			return lines
+		}
+		for i := 0; i < 500; i++ {
+			a, b := randomLines(), randomLines()
+			from, to := strings.Join(a, ""), strings.Join(b, "")
+			diff := filemap.UnifiedDiff("a/x", "b/x", from, to, len(a)+len(b))
+			if from == to {
+				Expect(diff).To(BeEmpty())
+				continue
+			}
+
+			// the hunk holds every line, so both sides can be rebuilt from it
+			var before, after strings.Builder
+			for _, line := range strings.SplitAfter(diff, "\n")[3:] {
+				if line == "" {
+					continue
+				}
+				switch line[0] {
+				case ' ':
+					before.WriteString(line[1:])
+					after.WriteString(line[1:])
+				case '-':
+					before.WriteString(line[1:])
+				case '+':
+					after.WriteString(line[1:])
+				}
+			}
+			Expect(before.String()).To(Equal(from), fmt.Sprintf("%q -> %q", from, to))
+			Expect(after.String()).To(Equal(to), fmt.Sprintf("%q -> %q", from, to))
+		}
+	})
+
+	It("diffs large files without running out of memory", func() {
+		// a quadratic table for these would take gigabytes
+		lines := make([]string, 40000)
+		for i := range lines {
+			lines[i] = fmt.Sprintf("line %d\n", i)
+		}
+		from := strings.Join(lines, "")
+		lines[20000] = "changed\n"
+		to := strings.Join(lines[1:], "")
+		Expect(filemap.UnifiedDiff("a/x", "b/x", from, to, 0)).To(Equal(`--- a/x
++++ b/x
+@@ -1 +0,0 @@
+-line 0
+@@ -20001 +20000 @@
+-line 20000
++changed
+`))
+	})
+
+	It("matches lines repeated throughout a large file", func() {
+		var from, to strings.Builder
+		for i := 0; i < 300; i++ {
+			fmt.Fprintf(&from, "- name: app-%d\n  image: nginx\n", i)
+			image := "nginx"
+			if i == 150 {
+				image = "nginx:1.25"
+			}
+			fmt.Fprintf(&to, "- name: app-%d\n  image: %s\n", i, image)
+		}
+		Expect(filemap.UnifiedDiff("a/x", "b/x", from.String(), to.String(), 1)).To(Equal(`--- a/x
++++ b/x
+@@ -301,3 +301,3 @@
+ - name: app-150
+-  image: nginx
++  image: nginx:1.25
+ - name: app-151
+`))
+	})
+
+	It("compares the filemap against the files on disk", func() {
+		dir := GinkgoT().TempDir()
+		existing := filepath.Join(dir, "deployment.yaml")
+		Expect(os.WriteFile(existing, []byte("kind: Deployment\nreplicas: 1\n"), 0600)).To(Succeed())
+		created := filepath.Join(dir, "service.yaml")
+
+		fm := filemap.NewFilemap()
+		fm.Files["deployment"] = filemap.File{Path: existing, Content: "kind: Deployment\nreplicas: 3\n"}
+		fm.Files["service"] = filemap.File{Path: created, Content: "kind: Service\n"}
+
+		diff, err := fm.Diff()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(diff).To(Equal("--- a/" + existing + "\n+++ b/" + existing + "\n" +
+			"@@ -1,2 +1,2 @@\n kind: Deployment\n-replicas: 1\n+replicas: 3\n" +
+			"--- /dev/null\n+++ b/" + created + "\n" +
+			"@@ -0,0 +1 @@\n+kind: Service\n"))
+
+		// nothing was written
+		_, err = os.Stat(created)
+		Expect(os.IsNotExist(err)).To(BeTrue())
+	})
+})
@@ -0,0 +1,54 @@
+package filemap
+
+import "strings"
+
+// CodeFence Is the marker which opens and closes a code block in markdown.
+const CodeFence = "```"
+
+// StripCodeFences Returns the content of the markdown code blocks in the output, which chat models
+// like to wrap files in, leaving out the prose around them. A tag written just above a block
+// is kept along with it, and the content of each block is separated by a FileDelimeter.
+// Output without any code blocks is returned as-is.
+func StripCodeFences(output string) string {
+	var (
+		blocks    []string
+		block     []string
+		inBlock   bool
+		lastProse string
+	)
+	for _, line := range strings.Split(output, "\n") {
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case strings.HasPrefix(trimmed, CodeFence) && inBlock:
+			blocks = append(blocks, strings.Join(block, "\n"))
+			block, inBlock = nil, false
+		case strings.HasPrefix(trimmed, CodeFence):
+			inBlock = true
+			if isTagLine(lastProse) {
+				block = append(block, lastProse)
+			}
+			lastProse = ""
+		case inBlock:
+			block = append(block, line)
+		case trimmed != "":
+			lastProse = trimmed
+		}
+	}
+	// the last block may be cut off by the token limit
+	if inBlock {
+		blocks = append(blocks, strings.Join(block, "\n"))
+	}
+	if len(blocks) == 0 {
+		return output
+	}
+	return strings.Join(blocks, "\n"+FileDelimeter+"\n") + "\n"
+}
+
+// isTagLine Reports whether the line tags the file following it, such as '# @app/pod.yaml'.
+func isTagLine(line string) bool {
+	if !strings.HasPrefix(line, "#") {
+		return false
+	}
+	tag := strings.TrimSpace(strings.TrimPrefix(line, "#"))
+	return strings.HasPrefix(tag, FileTagPrefix) && len(tag) > len(FileTagPrefix)
+}
@@ -0,0 +1,39 @@
+package filemap_test
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	. "github.com/redhat-et/copilot-ops/pkg/filemap"
+)
+
+var _ = Describe("Code fences", func() {
+	It("decodes a single fenced file", func() {
+		output := StripCodeFences("Here is the Pod you asked for:\n\n```yaml\n# @pod.yaml\nkind: Pod\n```\n\nLet me know if it works!")
+		Expect(output).To(Equal("# @pod.yaml\nkind: Pod\n"))
+
+		fm := NewFilemap()
+		Expect(fm.DecodeFromOutput(output)).To(Succeed())
+		Expect(fm.Files).To(HaveLen(1))
+		Expect(fm.Files["pod.yaml"].Content).To(Equal("kind: Pod\n"))
+	})
+
+	It("decodes several fenced files, tagged inside or above their block", func() {
+		output := StripCodeFences("First the Deployment:\n```yaml\n# @app/deployment.yaml\nkind: Deployment\n```\n" +
+			"Then the Service:\n# @app/service.yaml\n```\nkind: Service\n```\n")
+		fm := NewFilemap()
+		Expect(fm.DecodeFromOutput(output)).To(Succeed())
+		Expect(fm.Files).To(HaveLen(2))
+		Expect(fm.Files["app/deployment.yaml"].Content).To(Equal("kind: Deployment\n"))
+		Expect(fm.Files["app/service.yaml"].Content).To(Equal("kind: Service\n"))
+	})
+
+	It("keeps a block which was cut off", func() {
+		Expect(StripCodeFences("```yaml\n# @pod.yaml\nkind: Pod")).To(Equal("# @pod.yaml\nkind: Pod\n"))
+	})
+
+	It("leaves output without code blocks alone", func() {
+		output := "# @pod.yaml\nkind: Pod\n" + FileDelimeter + "\n# @service.yaml\nkind: Service\n"
+		Expect(StripCodeFences(output)).To(Equal(output))
+	})
+})
@@ -2,29 +2,44 @@
 package filemap
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
-	"log"
+	"net/http"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
+	"unicode/utf8"
 
+	"github.com/redhat-et/copilot-ops/pkg/ai/gpt3"
 	"github.com/redhat-et/copilot-ops/pkg/cmd/config"
+	"github.com/redhat-et/copilot-ops/pkg/logger"
 )
 
 // Define the values that are used for parsing files.
 const (
 	// FileDelimeter Is the string used to separate files when encoding/decoding.
+	// It only separates files on a line of its own, so the '---' separating the documents of a YAML file,
+	// or content such as 'a===b', are kept as part of the file.
 	FileDelimeter = "==="
 	// FileTagPrefix Is a string that indicates that the following string is the file's tag.
 	FileTagPrefix = "@"
+	// DefaultFileMode Is the mode of the files which are created, unless another mode is set.
+	DefaultFileMode os.FileMode = 0644
+	// DefaultMaxFileSize Is the size in bytes above which files are left out of the context, unless another
+	// limit is set.
+	DefaultMaxFileSize int64 = 256 * 1024
 )
 
 // Defines the values for all output options.
 const (
 	OutputJSON  = "json"
 	OutputPlain = "plain"
+	// OutputJSONFull Describes the whole generation as JSON, including the raw completions, rather than only the files.
+	OutputJSONFull = "json-full"
 )
 
 // File represents a file which was referenced in the issue to be updated.
@@ -35,62 +50,192 @@ type File struct {
 	Path string `json:"path"`
 	// Content is the content of the file.
 	Content string `json:"content"`
+	// Type is a hint of the file's type, derived from its extension.
+	Type string `json:"type,omitempty"`
+	// URL is where the file was fetched from, if it wasn't read from disk.
+	// Fetched files are only context, and are never written.
+	URL string `json:"url,omitempty"`
+	// Mode is the permissions the file is written with. When it isn't set, an existing file keeps
+	// its permissions and a new file is created with DefaultFileMode.
+	Mode os.FileMode `json:"mode,omitempty"`
+	// original Is the content the file had when it was loaded from disk, or nil if it wasn't.
+	original *string
 }
 
 // Filemap represents a mapping of files in a directory by their tagnames.
 type Filemap struct {
 	Files map[string]File `json:"files"`
+	// loaded Contains the tags of the files loaded from disk, in the order they were matched.
+	loaded []string
+	// gitignore Leaves the files it ignores out when loading files, if set.
+	gitignore *Gitignore
+	// httpClient Fetches the files given as URLs, defaulting to http.DefaultClient.
+	httpClient *http.Client
+	// header Is sent along with every request which fetches a file.
+	header http.Header
+	// endOfSequence Is the terminator which ends the output decoded by DecodeFromOutput,
+	// defaulting to gpt3.CompletionEndOfSequence.
+	endOfSequence string
+	// maxFileSize Is the size in bytes above which files are skipped when loading files, or 0 for no limit.
+	maxFileSize int64
 }
 
 // NewFilemap Builds and returns a new filemap.
 func NewFilemap() *Filemap {
 	return &Filemap{
-		Files: make(map[string]File),
+		Files:       make(map[string]File),
+		maxFileSize: DefaultMaxFileSize,
 	}
 }
 
 // LogDump Displays the contents of the filemap to the log.
 func (fm *Filemap) LogDump() {
 	maxShown := 30
-	log.Printf("filemap: len %d\n", len(fm.Files))
+	logger.Debugf("filemap: len %d\n", len(fm.Files))
 	for name, f := range fm.Files {
 		l := len(f.Content)
 		if l > maxShown {
 			l = maxShown
 		}
 		short := strings.ReplaceAll(f.Content[:l], "\n", " ")
-		log.Printf(" - tag: %-10q: path: %-20q [%s ...] len %d\n", name, f.Path, short, len(f.Content))
+		logger.Debugf(" - tag: %-10q: path: %-20q [%s ...] len %d\n", name, f.Path, short, len(f.Content))
 	}
 }
 
 // LoadFilesFromGlob reads files into the filemap from the given glob pattern.
+// A file which was already loaded, under any path which resolves to the same absolute path, is only loaded once.
 func (fm *Filemap) LoadFile(path string) error {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+	if tag, ok := fm.loadedTag(absPath); ok {
+		logger.Debugf("%s was already loaded as %q\n", path, tag)
+		return nil
+	}
 	tag := filepath.Base(path)
-	bytes, err := os.ReadFile(path)
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	if fm.TooLarge(info.Size()) {
+		logger.Warnf("skipping %s, its %d bytes are over the limit of %d bytes\n", path, info.Size(), fm.maxFileSize)
+		return nil
+	}
+	data, err := os.ReadFile(path)
 	if err != nil {
 		return err
 	}
+	if !IsText(data) {
+		logger.Warnf("skipping %s, it isn't a text file\n", path)
+		return nil
+	}
+	content := string(data)
+	return fm.addLoaded(tag, File{
+		Path:     path,
+		Content:  content,
+		Type:     DetectFileType(path),
+		Mode:     info.Mode().Perm(),
+		original: &content,
+	})
+}
+
+// UseMaxFileSize Skips files larger than the given number of bytes when loading files, or none when it's 0.
+func (fm *Filemap) UseMaxFileSize(maxFileSize int64) {
+	fm.maxFileSize = maxFileSize
+}
+
+// TooLarge Reports whether a file of the given size in bytes is skipped when loading files.
+func (fm *Filemap) TooLarge(size int64) bool {
+	return fm.maxFileSize > 0 && size > fm.maxFileSize
+}
+
+// IsText Reports whether the content is text which can be included in a prompt:
+// valid UTF-8 without any NUL bytes, which only binary files contain.
+func IsText(content []byte) bool {
+	return utf8.Valid(content) && bytes.IndexByte(content, 0) < 0
+}
+
+// loadedTag Returns the tag of the file which was loaded from the absolute path, if any.
+func (fm *Filemap) loadedTag(absPath string) (string, bool) {
+	for _, tag := range fm.loaded {
+		file, ok := fm.Files[tag]
+		if !ok || file.original == nil {
+			continue
+		}
+		if loadedPath, err := filepath.Abs(file.Path); err == nil && loadedPath == absPath {
+			return tag, true
+		}
+	}
+	return "", false
+}
+
+// addLoaded Adds a file which was loaded as context under the tag, or under a numbered tag
+// when the tag is already taken.
+func (fm *Filemap) addLoaded(tag string, file File) error {
 	if _, ok := fm.Files[tag]; ok {
 		tag = fmt.Sprintf("%s#%d", tag, len(fm.Files))
 		if _, ok = fm.Files[tag]; ok {
 			return fmt.Errorf("File tag conflict %s", tag)
 		}
 	}
-	fm.Files[tag] = File{
-		Path:    path,
-		Content: string(bytes),
+	fm.Files[tag] = file
+	fm.loaded = append(fm.loaded, tag)
+	return nil
+}
+
+// LoadOrder Returns the tags of every file in the filemap, least recently matched first.
+// Files which weren't loaded from disk come first, sorted by their tag.
+func (fm *Filemap) LoadOrder() []string {
+	loaded := make(map[string]bool, len(fm.loaded))
+	for _, tag := range fm.loaded {
+		loaded[tag] = true
+	}
+	tags := make([]string, 0, len(fm.Files))
+	for tag := range fm.Files {
+		if !loaded[tag] {
+			tags = append(tags, tag)
+		}
+	}
+	sort.Strings(tags)
+	for _, tag := range fm.loaded {
+		if _, ok := fm.Files[tag]; ok {
+			tags = append(tags, tag)
+		}
 	}
+	return tags
+}
+
+// UseGitignore Leaves the files ignored by the nearest .gitignore to dir out when loading files.
+func (fm *Filemap) UseGitignore(dir string) error {
+	gitignore, err := FindGitignore(dir)
+	if err != nil {
+		return fmt.Errorf("could not read %s: %w", GitignoreFile, err)
+	}
+	fm.gitignore = gitignore
 	return nil
 }
 
+// isIgnored Reports whether the file should be left out because it's gitignored.
+func (fm *Filemap) isIgnored(name string) bool {
+	if !fm.gitignore.Ignored(name, false) {
+		return false
+	}
+	logger.Debugf("skipping %q, which is ignored by %s\n", name, filepath.Join(fm.gitignore.Root, GitignoreFile))
+	return true
+}
+
 // LoadFilesFromGlob reads files into the filemap from the given glob pattern.
 func (fm *Filemap) LoadFilesFromGlob(glob string) error {
-	matches, err := filepath.Glob(glob)
+	matches, err := Glob(glob)
 	if err != nil {
 		return err
 	}
-	log.Printf("LoadFilesFromGlob %q - matches %v\n", glob, matches)
+	logger.Debugf("LoadFilesFromGlob %q - matches %v\n", glob, matches)
 	for _, match := range matches {
+		if fm.isIgnored(match) {
+			continue
+		}
 		err = fm.LoadFile(match)
 		if err != nil {
 			return err
@@ -102,13 +247,16 @@ func (fm *Filemap) LoadFilesFromGlob(glob string) error {
 // WriteUpdatesToFiles Writes the updated contents of each file to the directory.
 func (fm *Filemap) WriteUpdatesToFiles() error {
 	for name, file := range fm.Files {
+		if file.URL != "" {
+			continue
+		}
 		// add extension if necessary, assume this is YAML for the time being
 		// HACK: classify the relevant extension (e.g. .yaml, .yml, .json)
 		// fileName := file.Tag
 		// if len(strings.Split(file.Tag, ".")) == 1 {
 		// 	fileName += ".yaml"
 		// }
-		log.Printf("path: %q, tag: %q\n", file.Path, name)
+		logger.Debugf("path: %q, tag: %q\n", file.Path, name)
 		// locate the base directory of filePath
 		dirPath := filepath.Dir(file.Path)
 		// create the directory if it does not exist
@@ -119,9 +267,12 @@ func (fm *Filemap) WriteUpdatesToFiles() error {
 			}
 		}
 
-		// write the file at the given path with read write permissions for user, read-only for others
-		log.Printf("writing to file %q\n", file.Path)
-		f, err := os.OpenFile(file.Path, os.O_RDWR|os.O_CREATE, 0644)
+		mode, err := file.WriteMode()
+		if err != nil {
+			return err
+		}
+		logger.Infof("writing to file %q\n", file.Path)
+		f, err := os.OpenFile(file.Path, os.O_RDWR|os.O_CREATE|os.O_TRUNC, mode)
 		if err != nil {
 			return err
 		}
@@ -131,10 +282,39 @@ func (fm *Filemap) WriteUpdatesToFiles() error {
 		if err != nil {
 			return err
 		}
+		// the mode given when opening only applies to new files, and is masked by the umask
+		if err = f.Chmod(mode); err != nil {
+			return err
+		}
 	}
 	return nil
 }
 
+// WriteMode Returns the permissions the file is written with: its Mode if set, or else the
+// permissions of the existing file at its path, or DefaultFileMode for a new file.
+func (f File) WriteMode() (os.FileMode, error) {
+	if f.Mode != 0 {
+		return f.Mode.Perm(), nil
+	}
+	info, err := os.Stat(f.Path)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		return DefaultFileMode, nil
+	case err != nil:
+		return 0, err
+	default:
+		return info.Mode().Perm(), nil
+	}
+}
+
+// SetMode Sets the permissions every file in the filemap is written with.
+func (fm *Filemap) SetMode(mode os.FileMode) {
+	for tag, file := range fm.Files {
+		file.Mode = mode
+		fm.Files[tag] = file
+	}
+}
+
 // EncodeToInputText Encodes the filemap into a string which can be used as input to the OpenAI CLI.
 // If there was some issue or problem encoding the filemap, an error will be returned.
 func (fm *Filemap) EncodeToInputText() string {
@@ -163,8 +343,9 @@ func (fm *Filemap) EncodeToInputText() string {
 	*/
 	var input = ""
 	var i int
-	// join the files together along with their tag
-	for tagname, file := range fm.Files {
+	// join the files together along with their tag, in order of their paths so that the prompt is stable
+	for _, tagname := range fm.TagsByPath() {
+		file := fm.Files[tagname]
 		input += fmt.Sprintf("# %s%s\n%s\n", FileTagPrefix, tagname, file.Content)
 		// insert a delimeter between each file, but not after the last file
 		if 1 < len(fm.Files) && i < len(fm.Files)-1 {
@@ -182,7 +363,8 @@ func (fm *Filemap) EncodeToInputTextFullPaths(outputType string) (string, error)
 	var genFiles []File
 
 	// join the files together along with their tag
-	for _, file := range fm.Files {
+	for _, tag := range fm.TagsByPath() {
+		file := fm.Files[tag]
 		genFiles = append(genFiles, file)
 		input += fmt.Sprintf("# %s%s\n%s\n", FileTagPrefix, file.Path, file.Content)
 		// insert a delimeter between each file, but not after the last file
@@ -248,19 +430,140 @@ func (fm *Filemap) AddContentByTag(tagname string, content string) {
 			// TODO: infer path
 			Path:    "",
 			Content: content,
+			Type:    DetectFileType(tagname),
 		}
 	}
 }
 
-// DecodeFromOutput Decodes the given content and updates the filemap with the decoded content.
-// If new files exist within the content, a best-guess effort will be made to determine the name and pathing.
+// AddContentByPath Sets the content of the file at the given path, which is either the tagname of a file
+// in the filemap, with or without FileTagPrefix, or the path of a file. A new file is added at the path
+// if there is no such file.
+func (fm *Filemap) AddContentByPath(path string, content string) {
+	tagname := strings.TrimPrefix(strings.TrimSpace(path), FileTagPrefix)
+	if _, ok := fm.Files[tagname]; ok {
+		fm.AddContentByTag(tagname, content)
+		return
+	}
+	cleanPath := filepath.Clean(tagname)
+	for tag, file := range fm.Files {
+		if file.Path != "" && filepath.Clean(file.Path) == cleanPath {
+			fm.AddContentByTag(tag, content)
+			return
+		}
+	}
+	fm.Files[tagname] = File{
+		Name:    filepath.Base(cleanPath),
+		Path:    cleanPath,
+		Content: content,
+		Type:    DetectFileType(cleanPath),
+	}
+}
+
+// PlaceUnder Moves every file under the given directory, keeping its path relative to it.
+// Files without a path are placed in the directory by their tag.
+func (fm *Filemap) PlaceUnder(dir string) {
+	for tag, file := range fm.Files {
+		filePath := file.Path
+		if filePath == "" {
+			filePath = tag
+		}
+		file.Path = filepath.Join(dir, filePath)
+		if file.Name == "" {
+			file.Name = filepath.Base(filePath)
+		}
+		fm.Files[tag] = file
+	}
+}
+
+// UseEndOfSequence Makes DecodeFromOutput end the output at the given terminator,
+// which the model was asked to write once the files are complete.
+func (fm *Filemap) UseEndOfSequence(endOfSequence string) {
+	fm.endOfSequence = endOfSequence
+}
+
+// EndOfSequence Returns the terminator which ends the output decoded by DecodeFromOutput.
+func (fm *Filemap) EndOfSequence() string {
+	if fm.endOfSequence == "" {
+		return gpt3.CompletionEndOfSequence
+	}
+	return fm.endOfSequence
+}
+
+// DecodeFromOutput Decodes the completion of a model and updates the filemap with the decoded content.
+// The completion ends at its end-of-sequence terminator, or at its end when the model didn't write one,
+// in which case the last file may have been cut off by the token limit.
 func (fm *Filemap) DecodeFromOutput(content string) error {
+	content, terminated := TrimEndOfSequence(content, fm.EndOfSequence())
+	if !terminated {
+		logger.Warnf("the output doesn't end with %q, the last file may be truncated\n", fm.EndOfSequence())
+	}
+	return fm.Decode(content)
+}
+
+// TrimEndOfSequence Returns the content up to its end-of-sequence terminator, and whether it has one.
+// The terminator is the last unindented line made up of endOfSequence, unless another file follows it,
+// and anything the model wrote after it is dropped. A terminator which the model echoed right before the
+// start of the next file is removed as well, but any other such line is left alone, since it belongs to
+// the content of a file, such as the end of a heredoc in a script.
+func TrimEndOfSequence(content, endOfSequence string) (string, bool) {
+	lines := strings.Split(content, "\n")
+	end := -1
+	for i := len(lines) - 1; i >= 0; i-- {
+		if isEndOfSequence(lines[i], endOfSequence) {
+			end = i
+			break
+		}
+	}
+	// a file after the last terminator means the model kept going and was cut off
+	if end >= 0 {
+		rest := strings.Join(lines[end+1:], "\n")
+		if _, _, err := extractTagName(rest); err == nil {
+			end = -1
+		}
+	}
+	if end >= 0 {
+		lines = lines[:end]
+	}
+	kept := make([]string, 0, len(lines))
+	for i, line := range lines {
+		if !isEndOfSequence(line, endOfSequence) || !beforeFileBoundary(lines[i+1:]) {
+			kept = append(kept, line)
+		}
+	}
+	return strings.Join(kept, "\n"), end >= 0
+}
+
+// isEndOfSequence Reports whether the line is an unindented end-of-sequence terminator.
+func isEndOfSequence(line, endOfSequence string) bool {
+	return strings.TrimRight(line, " \t\r") == endOfSequence
+}
+
+// beforeFileBoundary Reports whether the first non-blank line is a file delimiter or the tag of a file,
+// or whether there are no such lines left.
+func beforeFileBoundary(lines []string) bool {
+	for _, line := range lines {
+		line = strings.TrimRight(line, " \t\r")
+		if line == "" {
+			continue
+		}
+		if line == FileDelimeter {
+			return true
+		}
+		_, _, err := extractTagName(line)
+		return err == nil
+	}
+	return true
+}
+
+// Decode Decodes the given content and updates the filemap with the decoded content.
+// If new files exist within the content, a best-guess effort will be made to determine the name and pathing.
+func (fm *Filemap) Decode(content string) error {
 	// To decode from the output, we have to split the content up by the defined file delimeter.
 	// Then we use RegEx to extract the tagname which we can use to look up the file and update its content.
 	// If the tagname is not found, we assume that the file is new and we will create a new file with the tagname.
 
 	// Split the content by the file delimeter
-	parts := strings.Split(content, FileDelimeter)
+	parts := SplitFiles(content)
 	for _, part := range parts {
 		// Trim the leading and trailing whitespace
 		part = strings.TrimSpace(part)
@@ -290,9 +593,15 @@ func (fm *Filemap) DecodeFromOutput(content string) error {
 	return nil
 }
 
-// loadFiles attempts to load the given files by globbing.
+// loadFiles attempts to load the given files by globbing. Files given as URLs are fetched instead.
 func (fm *Filemap) LoadFiles(files []string) error {
 	for _, glob := range files {
+		if IsURL(glob) {
+			if err := fm.LoadURL(context.Background(), glob); err != nil {
+				return err
+			}
+			continue
+		}
 		// load or ignore failure
 		// FIXME: warn when a file has failed to load
 		if err := fm.LoadFilesFromGlob(glob); err != nil {
@@ -309,12 +618,61 @@ func (fm *Filemap) LoadFilesets(filesets []string, conf config.Config, configFil
 		if fileset == nil {
 			return fmt.Errorf("fileset %s not found in %s", name, configFile)
 		}
-		for _, glob := range fileset.Files {
-			// FIXME: check error here
-			if err := fm.LoadFilesFromGlob(glob); err != nil {
+		matches, err := ResolveFileset(*fileset)
+		if err != nil {
+			return fmt.Errorf("could not resolve fileset %s: %w", name, err)
+		}
+		logger.Debugf("fileset %q matches %v\n", name, matches)
+		for _, match := range matches {
+			if fm.isIgnored(match) {
+				continue
+			}
+			if err = fm.LoadFile(match); err != nil {
 				return err
 			}
 		}
 	}
 	return nil
 }
+
+// ResolveFileset Returns the union of the files matched by the fileset's patterns,
+// without any which match one of its exclude patterns. Every file is only returned once.
+func ResolveFileset(fileset config.Filesets) ([]string, error) {
+	var files []string
+	seen := make(map[string]bool)
+	for _, pattern := range fileset.Patterns() {
+		matches, err := Glob(pattern)
+		if err != nil {
+			return nil, err
+		}
+		for _, match := range matches {
+			name := filepath.ToSlash(filepath.Clean(match))
+			if seen[name] {
+				continue
+			}
+			seen[name] = true
+			excluded, err := isExcluded(name, fileset.Exclude)
+			if err != nil {
+				return nil, err
+			}
+			if !excluded {
+				files = append(files, match)
+			}
+		}
+	}
+	return files, nil
+}
+
+// isExcluded Reports whether the slash-separated name matches any of the exclude patterns.
+func isExcluded(name string, excludes []string) (bool, error) {
+	for _, exclude := range excludes {
+		ok, err := MatchGlob(exclude, name)
+		if err != nil {
+			return false, fmt.Errorf("invalid exclude pattern %q: %w", exclude, err)
+		}
+		if ok {
+			return true, nil
+		}
+	}
+	return false, nil
+}
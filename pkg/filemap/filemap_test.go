@@ -2,10 +2,14 @@ package filemap_test
 
 import (
 	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
 
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
 
+	"github.com/redhat-et/copilot-ops/pkg/ai/gpt3"
 	. "github.com/redhat-et/copilot-ops/pkg/filemap"
 )
 
@@ -58,6 +62,45 @@ kind: CatVideos
 			Expect(encoding).To(ContainSubstring(FileDelimeter))
 		})
 
+		It("orders files by when they were matched", func() {
+			dir := GinkgoT().TempDir()
+			for _, name := range []string{"b.yaml", "a.yaml"} {
+				Expect(os.WriteFile(filepath.Join(dir, name), []byte("kind: Pod\n"), 0600)).To(Succeed())
+			}
+			Expect(filemap.LoadFile(filepath.Join(dir, "b.yaml"))).To(Succeed())
+			Expect(filemap.LoadFile(filepath.Join(dir, "a.yaml"))).To(Succeed())
+			delete(filemap.Files, "a.yaml")
+			Expect(filemap.LoadOrder()).To(Equal([]string{"cat_videos", "fortnite_vods", "b.yaml"}))
+		})
+
+		It("loads a file matched by overlapping globs once", func() {
+			dir := GinkgoT().TempDir()
+			for _, name := range []string{"b.yaml", "a.yaml"} {
+				Expect(os.WriteFile(filepath.Join(dir, name), []byte("kind: Pod\n"), 0600)).To(Succeed())
+			}
+			Expect(filemap.LoadFilesFromGlob(filepath.Join(dir, "b.yaml"))).To(Succeed())
+			Expect(filemap.LoadFilesFromGlob(filepath.Join(dir, "*.yaml"))).To(Succeed())
+			Expect(filemap.LoadFile(filepath.Join(dir, ".", "a.yaml"))).To(Succeed())
+			Expect(filemap.LoadOrder()).To(Equal([]string{"cat_videos", "fortnite_vods", "b.yaml", "a.yaml"}))
+		})
+
+		It("encodes the files in order of their paths", func() {
+			filemap.AddContentByTag("new.yaml", "kind: Pod")
+			encoding := filemap.EncodeToInputText()
+			Expect(encoding).To(MatchRegexp(`(?s)^# @new\.yaml\n.*# @cat_videos\n.*# @fortnite_vods\n`))
+			for i := 0; i < 10; i++ {
+				Expect(filemap.EncodeToInputText()).To(Equal(encoding))
+			}
+		})
+
+		It("places files under a directory", func() {
+			filemap.AddContentByTag("new.yaml", "kind: Pod")
+			filemap.PlaceUnder("manifests/generated")
+			Expect(filemap.Files["new.yaml"].Path).To(Equal("manifests/generated/new.yaml"))
+			Expect(filemap.Files["new.yaml"].Name).To(Equal("new.yaml"))
+			Expect(filemap.Files["cat_videos"].Path).To(Equal("manifests/generated/testdata/cat_videos"))
+		})
+
 		It("updates existing files by their tagname", func() {
 			// update the fortnite vods file with content
 			filemap.AddContentByTag("fortnite_vods", "new-fortnite-content")
@@ -105,6 +148,26 @@ kind: CatVideos
 		})
 	})
 
+	It("skips binary and oversized files", func() {
+		dir := GinkgoT().TempDir()
+		Expect(os.WriteFile(filepath.Join(dir, "pod.yaml"), []byte("kind: Pod\n"), 0600)).To(Succeed())
+		Expect(os.WriteFile(filepath.Join(dir, "logo.png"), []byte("\x89PNG\r\n\x1a\n\x00\x00"), 0600)).To(Succeed())
+		Expect(os.WriteFile(filepath.Join(dir, "latin1.txt"), []byte("caf\xe9\n"), 0600)).To(Succeed())
+		Expect(os.WriteFile(filepath.Join(dir, "crds.yaml"), []byte(strings.Repeat("kind: CRD\n", 10)), 0600)).To(Succeed())
+
+		filemap.UseMaxFileSize(64)
+		Expect(filemap.LoadFilesFromGlob(filepath.Join(dir, "*"))).To(Succeed())
+		Expect(filemap.Files).To(HaveLen(1))
+		Expect(filemap.Files).To(HaveKey("pod.yaml"))
+
+		// without a limit, only the binary files are skipped
+		filemap = NewFilemap()
+		filemap.UseMaxFileSize(0)
+		Expect(filemap.LoadFilesFromGlob(filepath.Join(dir, "*"))).To(Succeed())
+		Expect(filemap.Files).To(HaveLen(2))
+		Expect(filemap.Files).To(HaveKey("crds.yaml"))
+	})
+
 	It("concatenates after a line number", func() {
 		const content = `1
 2
@@ -180,5 +243,180 @@ kind: NotEmtpy`, FileTagPrefix, FileDelimeter, FileTagPrefix)
 			Expect(ok).To(BeTrue())
 			Expect(listing.Content).To(ContainSubstring("kind: NotEmtpy"))
 		})
+
+		It("ends at the end-of-sequence terminator", func() {
+			response := fmt.Sprintf(responseTemplate, FileTagPrefix, FileDelimeter, FileTagPrefix) +
+				gpt3.CompletionEndOfSequence + "\nI hope these manifests help!\n"
+			Expect(filemap.DecodeFromOutput(response)).To(Succeed())
+			Expect(filemap.Files).To(HaveLen(2))
+			Expect(filemap.Files["viva-pinata-server"].Content).NotTo(ContainSubstring(gpt3.CompletionEndOfSequence))
+			Expect(filemap.Files["viva-pinata-server"].Content).NotTo(ContainSubstring("help"))
+		})
+
+		It("keeps the last file of a truncated output", func() {
+			response := fmt.Sprintf(responseTemplate, FileTagPrefix, FileDelimeter, FileTagPrefix) + "spec:\n  repli"
+			content, terminated := TrimEndOfSequence(response, gpt3.CompletionEndOfSequence)
+			Expect(terminated).To(BeFalse())
+			Expect(content).To(Equal(response))
+
+			Expect(filemap.DecodeFromOutput(response)).To(Succeed())
+			Expect(filemap.Files).To(HaveLen(2))
+			Expect(filemap.Files["viva-pinata-server"].Content).To(HaveSuffix("spec:\n  repli\n"))
+		})
+
+		It("removes a terminator echoed in the middle of the files", func() {
+			response := fmt.Sprintf(`# %sfortnite-stats
+kind: Deployment
+%s
+%s
+# %sviva-pinata-server
+kind: Service
+%s
+`, FileTagPrefix, gpt3.CompletionEndOfSequence, FileDelimeter, FileTagPrefix, gpt3.CompletionEndOfSequence)
+			Expect(filemap.DecodeFromOutput(response)).To(Succeed())
+			Expect(filemap.Files).To(HaveLen(2))
+			Expect(filemap.Files["fortnite-stats"].Content).To(Equal("kind: Deployment\n"))
+			Expect(filemap.Files["viva-pinata-server"].Content).To(Equal("kind: Service\n"))
+
+			// the terminator only counts when there are no files after it
+			content, terminated := TrimEndOfSequence(fmt.Sprintf("# %sfortnite-stats\nkind: Deployment\n%s\n"+
+				"# %sviva-pinata-server\nkind: Serv", FileTagPrefix, gpt3.CompletionEndOfSequence, FileTagPrefix),
+				gpt3.CompletionEndOfSequence)
+			Expect(terminated).To(BeFalse())
+			Expect(content).NotTo(ContainSubstring(gpt3.CompletionEndOfSequence))
+			Expect(content).To(HaveSuffix("kind: Serv"))
+		})
+
+		It("keeps the end of a heredoc in a script", func() {
+			script := "#!/bin/sh\ncat <<EOF > /etc/motd\nwelcome\nEOF\necho done\n"
+			config := "data:\n  run.sh: |\n    cat <<EOF\n    hello\n    EOF\n"
+			response := fmt.Sprintf("# %ssetup\n%s%s\n# %sconfig\n%s%s\n", FileTagPrefix, script, FileDelimeter,
+				FileTagPrefix, config, gpt3.CompletionEndOfSequence)
+			Expect(filemap.DecodeFromOutput(response)).To(Succeed())
+			Expect(filemap.Files["setup"].Content).To(Equal(script))
+			Expect(filemap.Files["config"].Content).To(Equal(config))
+		})
+
+		It("ends at a custom terminator", func() {
+			filemap.UseEndOfSequence("<<END>>")
+			Expect(filemap.EndOfSequence()).To(Equal("<<END>>"))
+			response := fmt.Sprintf("# %sscript\ndata:\n  run.sh: |\n    cat <<EOF\n    hello\n%s\n<<END>>\n"+
+				"I hope this script helps!\n", FileTagPrefix, gpt3.CompletionEndOfSequence)
+			Expect(filemap.DecodeFromOutput(response)).To(Succeed())
+			// the default terminator is only content now
+			Expect(filemap.Files["script"].Content).To(Equal("data:\n  run.sh: |\n    cat <<EOF\n    hello\n" +
+				gpt3.CompletionEndOfSequence + "\n"))
+		})
+
+		It("keeps an indented terminator, which belongs to the file", func() {
+			response := fmt.Sprintf("# %sscript\ndata:\n  run.sh: |\n    cat <<EOF\n    hello\n    EOF\n%s\n",
+				FileTagPrefix, gpt3.CompletionEndOfSequence)
+			Expect(filemap.DecodeFromOutput(response)).To(Succeed())
+			Expect(filemap.Files["script"].Content).To(Equal("data:\n  run.sh: |\n    cat <<EOF\n    hello\n    EOF\n"))
+		})
+	})
+	When("a file contains multiple documents", func() {
+		const (
+			manifests = `---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: docs
+data:
+  README.md: |
+    Usage
+    ===
+    Compare with a===b, not a==b.
+---
+# the service in front of the deployment
+apiVersion: v1
+kind: Service
+metadata:
+  name: web
+...
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+`
+			service = "apiVersion: v1\nkind: Service\nmetadata:\n  name: api\n"
+		)
+
+		BeforeEach(func() {
+			filemap.Files["web.yaml"] = File{Path: "app/web.yaml", Content: manifests}
+			filemap.Files["api.yaml"] = File{Path: "app/api.yaml", Content: service}
+		})
+
+		It("round-trips byte-for-byte through the encoding", func() {
+			decoded := NewFilemap()
+			Expect(decoded.DecodeFromOutput(filemap.EncodeToInputText() + gpt3.CompletionEndOfSequence + "\n")).To(Succeed())
+			Expect(decoded.Files).To(HaveLen(2))
+			Expect(decoded.Files["web.yaml"].Content).To(Equal(manifests))
+			Expect(decoded.Files["api.yaml"].Content).To(Equal(service))
+		})
+
+		It("round-trips byte-for-byte through the encoding by path", func() {
+			encoded, err := filemap.EncodeToInputTextFullPaths(OutputPlain)
+			Expect(err).NotTo(HaveOccurred())
+			decoded := NewFilemap()
+			Expect(decoded.Decode(encoded)).To(Succeed())
+			Expect(decoded.Files["app/web.yaml"].Content).To(Equal(manifests))
+			Expect(decoded.Files["app/api.yaml"].Content).To(Equal(service))
+		})
+
+		It("only splits files at a delimiter on a line of its own", func() {
+			Expect(SplitFiles("# @a\nkind: A\n===\n# @b\n  ===\nx: a===b\n=== \n")).To(Equal([]string{
+				"# @a\nkind: A\n", "# @b\n  ===\nx: a===b\n", "",
+			}))
+		})
+	})
+
+	It("suffixes the paths of files written next to existing ones", func() {
+		Expect(SuffixedPath("pod.yaml")).To(Equal("pod.generated.yaml"))
+		Expect(SuffixedPath("app/v1.2/Dockerfile")).To(Equal("app/v1.2/Dockerfile.generated"))
+		Expect(SuffixedPath("charts/values.tar.gz")).To(Equal("charts/values.tar.generated.gz"))
+	})
+
+	When("files are written", func() {
+		var dir string
+		// mode Returns the permissions of the file in the directory.
+		mode := func(name string) os.FileMode {
+			info, err := os.Stat(filepath.Join(dir, name))
+			Expect(err).NotTo(HaveOccurred())
+			return info.Mode().Perm()
+		}
+
+		BeforeEach(func() {
+			dir = GinkgoT().TempDir()
+			Expect(os.WriteFile(filepath.Join(dir, "hook.sh"), []byte("#!/bin/sh\n"), 0700)).To(Succeed())
+		})
+
+		It("creates new files with the default mode", func() {
+			filemap.Files["pod"] = File{Path: filepath.Join(dir, "pod.yaml"), Content: "kind: Pod\n"}
+			Expect(filemap.WriteUpdatesToFiles()).To(Succeed())
+			Expect(mode("pod.yaml")).To(Equal(DefaultFileMode))
+		})
+
+		It("preserves the mode of the files it overwrites", func() {
+			filemap.Files["hook"] = File{Path: filepath.Join(dir, "hook.sh"), Content: "#!/bin/sh\nexit 0\n"}
+			Expect(filemap.WriteUpdatesToFiles()).To(Succeed())
+			Expect(mode("hook.sh")).To(Equal(os.FileMode(0700)))
+			Expect(os.ReadFile(filepath.Join(dir, "hook.sh"))).To(Equal([]byte("#!/bin/sh\nexit 0\n")))
+		})
+
+		It("records the mode of loaded files", func() {
+			Expect(filemap.LoadFile(filepath.Join(dir, "hook.sh"))).To(Succeed())
+			Expect(filemap.Files["hook.sh"].Mode).To(Equal(os.FileMode(0700)))
+		})
+
+		It("writes the files with the mode which was set", func() {
+			filemap.Files["hook"] = File{Path: filepath.Join(dir, "hook.sh"), Content: "#!/bin/sh\n"}
+			filemap.Files["pre-sync"] = File{Path: filepath.Join(dir, "pre-sync.sh"), Content: "#!/bin/sh\n"}
+			filemap.SetMode(0755)
+			Expect(filemap.WriteUpdatesToFiles()).To(Succeed())
+			Expect(mode("hook.sh")).To(Equal(os.FileMode(0755)))
+			Expect(mode("pre-sync.sh")).To(Equal(os.FileMode(0755)))
+		})
 	})
 })
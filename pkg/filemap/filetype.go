@@ -0,0 +1,58 @@
+package filemap
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// File types which can be detected from a file's name.
+const (
+	// FileTypeYAML Is used for YAML files, and for files without an extension
+	// since the generated files are assumed to be Kubernetes YAML.
+	FileTypeYAML       = "yaml"
+	FileTypeJSON       = "json"
+	FileTypeDockerfile = "dockerfile"
+	FileTypeShell      = "shell"
+	// FileTypeText Is used for any other type of file.
+	FileTypeText = "text"
+)
+
+// DetectFileType Returns the type of the file with the given name, based on its extension.
+func DetectFileType(name string) string {
+	base := strings.ToLower(filepath.Base(name))
+	if base == "dockerfile" || strings.HasPrefix(base, "dockerfile.") || strings.HasSuffix(base, ".dockerfile") {
+		return FileTypeDockerfile
+	}
+	switch filepath.Ext(base) {
+	case ".yaml", ".yml", "":
+		return FileTypeYAML
+	case ".json":
+		return FileTypeJSON
+	case ".sh", ".bash":
+		return FileTypeShell
+	default:
+		return FileTypeText
+	}
+}
+
+// FileType Returns the type of the file, detecting it from the path or the given tag
+// when it wasn't set.
+func (f File) FileType(tag string) string {
+	if f.Type != "" {
+		return f.Type
+	}
+	if f.Path != "" {
+		return DetectFileType(f.Path)
+	}
+	return DetectFileType(tag)
+}
+
+// OnlyYAML Returns whether every file in the filemap is YAML.
+func (fm *Filemap) OnlyYAML() bool {
+	for tag, file := range fm.Files {
+		if file.FileType(tag) != FileTypeYAML {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,61 @@
+package filemap_test
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	. "github.com/redhat-et/copilot-ops/pkg/filemap"
+)
+
+var _ = Describe("File types", func() {
+	It("detects the type from the file name", func() {
+		Expect(DetectFileType("app/pod.yaml")).To(Equal(FileTypeYAML))
+		Expect(DetectFileType("pod.YML")).To(Equal(FileTypeYAML))
+		Expect(DetectFileType("pod")).To(Equal(FileTypeYAML))
+		Expect(DetectFileType("config/settings.json")).To(Equal(FileTypeJSON))
+		Expect(DetectFileType("build/Dockerfile")).To(Equal(FileTypeDockerfile))
+		Expect(DetectFileType("web.dockerfile")).To(Equal(FileTypeDockerfile))
+		Expect(DetectFileType("hack/deploy.sh")).To(Equal(FileTypeShell))
+		Expect(DetectFileType("README.md")).To(Equal(FileTypeText))
+	})
+
+	It("round-trips a mixed YAML and JSON fileset", func() {
+		dir := GinkgoT().TempDir()
+		podPath := filepath.Join(dir, "pod.yaml")
+		configPath := filepath.Join(dir, "config.json")
+		Expect(os.WriteFile(podPath, []byte("apiVersion: v1\nkind: Pod\n"), 0600)).To(Succeed())
+		Expect(os.WriteFile(configPath, []byte("{\n  \"replicas\": 2\n}\n"), 0600)).To(Succeed())
+
+		fm := NewFilemap()
+		Expect(fm.LoadFiles([]string{podPath, configPath})).To(Succeed())
+		Expect(fm.Files["pod.yaml"].Type).To(Equal(FileTypeYAML))
+		Expect(fm.Files["config.json"].Type).To(Equal(FileTypeJSON))
+		Expect(fm.OnlyYAML()).To(BeFalse())
+
+		decoded := NewFilemap()
+		Expect(decoded.DecodeFromOutput(fm.EncodeToInputText())).To(Succeed())
+		Expect(decoded.Files).To(HaveLen(2))
+		Expect(decoded.Files["pod.yaml"].Type).To(Equal(FileTypeYAML))
+		Expect(strings.TrimSpace(decoded.Files["pod.yaml"].Content)).To(Equal("apiVersion: v1\nkind: Pod"))
+		config := decoded.Files["config.json"]
+		Expect(config.Type).To(Equal(FileTypeJSON))
+		var settings map[string]int
+		Expect(json.Unmarshal([]byte(config.Content), &settings)).To(Succeed())
+		Expect(settings).To(Equal(map[string]int{"replicas": 2}))
+	})
+
+	It("only validates YAML files", func() {
+		fm := NewFilemap()
+		fm.Files["config.json"] = File{Content: "{\"replicas\": 2}"}
+		Expect(fm.Validate()).To(BeEmpty())
+		fm.Files["pod.yaml"] = File{Content: "apiVersion: v1\nkind: Pod\n"}
+		Expect(fm.OnlyYAML()).To(BeFalse())
+		delete(fm.Files, "config.json")
+		Expect(fm.OnlyYAML()).To(BeTrue())
+	})
+})
@@ -0,0 +1,89 @@
+package filemap
+
+import (
+	"fmt"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+
+	"github.com/redhat-et/copilot-ops/pkg/logger"
+)
+
+// Format Re-serializes every YAML file in the filemap with FormatYAML.
+// Files which can't be parsed are left as they are, with a warning.
+func (fm *Filemap) Format() {
+	for tag, file := range fm.Files {
+		if file.FileType(tag) != FileTypeYAML {
+			continue
+		}
+		formatted, err := FormatYAML(file.Content)
+		if err != nil {
+			logger.Warnf("not formatting %s, which can't be parsed: %s\n", tag, err)
+			continue
+		}
+		file.Content = formatted
+		fm.Files[tag] = file
+	}
+}
+
+// FormatYAML Parses each document of the YAML content and marshals it again, so that it's indented
+// with 2 spaces and quoted consistently. The order of the keys is kept, but comments are dropped,
+// as are documents which are empty.
+func FormatYAML(content string) (string, error) {
+	var documents []string
+	for i, document := range splitDocuments(content) {
+		value, err := decodeOrdered(document)
+		if err != nil {
+			return "", fmt.Errorf("document %d: %w", i+1, err)
+		}
+		if value == nil {
+			continue
+		}
+		out, err := yaml.Marshal(value)
+		if err != nil {
+			return "", fmt.Errorf("document %d: %w", i+1, err)
+		}
+		documents = append(documents, string(out))
+	}
+	return strings.Join(documents, DocumentSeparator+"\n"), nil
+}
+
+// splitDocuments Splits the YAML content at its document separators.
+func splitDocuments(content string) []string {
+	var documents []string
+	var current strings.Builder
+	for _, line := range strings.SplitAfter(content, "\n") {
+		trimmed := strings.TrimRight(line, " \t\r\n")
+		if trimmed == DocumentSeparator || strings.HasPrefix(trimmed, DocumentSeparator+" ") {
+			documents = append(documents, current.String())
+			current.Reset()
+			continue
+		}
+		current.WriteString(line)
+	}
+	return append(documents, current.String())
+}
+
+// decodeOrdered Decodes the YAML document into values which keep the order of the keys of its mappings,
+// or returns nil for an empty document.
+func decodeOrdered(document string) (interface{}, error) {
+	var value interface{}
+	if err := yaml.Unmarshal([]byte(document), &value); err != nil {
+		return nil, err
+	}
+	// a slice of mappings would decode into a MapSlice too, so the kind of the document is checked first
+	var ordered interface{}
+	switch value.(type) {
+	case map[interface{}]interface{}:
+		ordered = &yaml.MapSlice{}
+	case []interface{}:
+		ordered = &[]yaml.MapSlice{}
+	default:
+		return value, nil
+	}
+	if err := yaml.Unmarshal([]byte(document), ordered); err != nil {
+		// sequences of anything but mappings can't keep an order anyway
+		return value, nil //nolint:nilerr // the document itself is valid
+	}
+	return ordered, nil
+}
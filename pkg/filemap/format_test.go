@@ -0,0 +1,38 @@
+package filemap_test
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	. "github.com/redhat-et/copilot-ops/pkg/filemap"
+)
+
+var _ = Describe("Format", func() {
+	It("re-indents messy YAML, keeping the order of the keys", func() {
+		messy := "---\nkind:   Pod\napiVersion: v1   \nmetadata:\n    name: 'web'\n" +
+			"    labels: {tier: front, app: \"web\"}\nspec:\n    containers:\n" +
+			"        -   name: web\n            image: nginx\n            ports:\n              - containerPort: 80\n"
+		formatted, err := FormatYAML(messy)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(formatted).To(Equal("kind: Pod\napiVersion: v1\nmetadata:\n  name: web\n" +
+			"  labels:\n    tier: front\n    app: web\nspec:\n  containers:\n" +
+			"  - name: web\n    image: nginx\n    ports:\n    - containerPort: 80\n"))
+	})
+
+	It("formats every document, dropping the empty ones", func() {
+		formatted, err := FormatYAML("kind:  Service\n---\n# nothing here\n---\n-   b: 1\n    a: 2\n--- \nkind: Pod\n")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(formatted).To(Equal("kind: Service\n---\n- b: 1\n  a: 2\n---\nkind: Pod\n"))
+	})
+
+	It("leaves files which can't be parsed as they are", func() {
+		fm := NewFilemap()
+		fm.Files["pod.yaml"] = File{Content: "kind:   Pod\n"}
+		fm.Files["broken.yaml"] = File{Content: "kind: Pod\n\tname: web\n"}
+		fm.Files["README.md"] = File{Content: "*  a list\n"}
+		fm.Format()
+		Expect(fm.Files["pod.yaml"].Content).To(Equal("kind: Pod\n"))
+		Expect(fm.Files["broken.yaml"].Content).To(Equal("kind: Pod\n\tname: web\n"))
+		Expect(fm.Files["README.md"].Content).To(Equal("*  a list\n"))
+	})
+})
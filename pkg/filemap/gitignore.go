@@ -0,0 +1,136 @@
+package filemap
+
+import (
+	"bufio"
+	"errors"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// GitignoreFile Is the name of the file which lists the paths git ignores.
+const GitignoreFile = ".gitignore"
+
+// gitignoreRule Is a single pattern from a .gitignore file.
+type gitignoreRule struct {
+	pattern string
+	// negate Re-includes paths which an earlier rule ignored.
+	negate bool
+	// dirOnly Only matches directories.
+	dirOnly bool
+	// anchored Matches the pattern against the full path rather than any name in it.
+	anchored bool
+}
+
+// Gitignore Decides which paths are ignored by the patterns of a .gitignore file.
+type Gitignore struct {
+	// Root Is the directory containing the .gitignore file, which the patterns are relative to.
+	Root  string
+	rules []gitignoreRule
+}
+
+// FindGitignore Loads the nearest .gitignore file, looking in dir and then each of its parents.
+// Nil is returned when there isn't one.
+func FindGitignore(dir string) (*Gitignore, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		f, err := os.Open(filepath.Join(dir, GitignoreFile))
+		if err == nil {
+			defer f.Close()
+			return ParseGitignore(dir, f)
+		}
+		if !errors.Is(err, os.ErrNotExist) {
+			return nil, err
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return nil, nil //nolint:nilnil // having no .gitignore isn't an error
+		}
+		dir = parent
+	}
+}
+
+// ParseGitignore Reads the patterns of a .gitignore file in the root directory.
+func ParseGitignore(root string, f *os.File) (*Gitignore, error) {
+	g := &Gitignore{Root: root}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		g.AddPattern(scanner.Text())
+	}
+	return g, scanner.Err()
+}
+
+// AddPattern Adds a line of a .gitignore file to the rules. Blank lines and comments are skipped.
+func (g *Gitignore) AddPattern(line string) {
+	line = strings.TrimRight(line, " \t\r")
+	if line == "" || strings.HasPrefix(line, "#") {
+		return
+	}
+	rule := gitignoreRule{}
+	if strings.HasPrefix(line, "!") {
+		rule.negate = true
+		line = line[1:]
+	}
+	// a leading backslash escapes '#' and '!'
+	line = strings.TrimPrefix(line, `\`)
+	if strings.HasSuffix(line, "/") {
+		rule.dirOnly = true
+		line = strings.TrimSuffix(line, "/")
+	}
+	// patterns with a slash anywhere but the end are relative to the root
+	if strings.Contains(line, "/") {
+		rule.anchored = true
+		line = strings.TrimPrefix(line, "/")
+	}
+	rule.pattern = line
+	g.rules = append(g.rules, rule)
+}
+
+// Ignored Reports whether the file or directory at the given path is ignored,
+// either by a pattern or because one of its parent directories is.
+// Paths outside of the root are never ignored.
+func (g *Gitignore) Ignored(name string, isDir bool) bool {
+	if g == nil {
+		return false
+	}
+	abs, err := filepath.Abs(name)
+	if err != nil {
+		return false
+	}
+	rel, err := filepath.Rel(g.Root, abs)
+	if err != nil || rel == "." || strings.HasPrefix(rel, "..") {
+		return false
+	}
+	segments := strings.Split(filepath.ToSlash(rel), "/")
+	for i := 1; i <= len(segments); i++ {
+		if g.matches(segments[:i], i < len(segments) || isDir) {
+			return true
+		}
+	}
+	return false
+}
+
+// matches Applies every rule to the path, where the last matching rule decides whether it's ignored.
+func (g *Gitignore) matches(segments []string, isDir bool) bool {
+	ignored := false
+	relPath := strings.Join(segments, "/")
+	for _, rule := range g.rules {
+		if rule.dirOnly && !isDir {
+			continue
+		}
+		var ok bool
+		if rule.anchored {
+			ok, _ = MatchGlob(rule.pattern, relPath)
+		} else {
+			ok, _ = path.Match(rule.pattern, segments[len(segments)-1])
+		}
+		if ok {
+			ignored = !rule.negate
+		}
+	}
+	return ignored
+}
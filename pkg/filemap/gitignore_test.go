@@ -0,0 +1,71 @@
+package filemap_test
+
+import (
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	. "github.com/redhat-et/copilot-ops/pkg/filemap"
+)
+
+var _ = Describe("Gitignore", func() {
+	var dir string
+
+	BeforeEach(func() {
+		dir = GinkgoT().TempDir()
+		for _, name := range []string{
+			"k8s/pod.yaml",
+			"k8s/db.secret",
+			"k8s/keep.secret",
+			"node_modules/lib/index.yaml",
+			"build/out.yaml",
+			"k8s/build/pod.yaml",
+		} {
+			Expect(os.MkdirAll(filepath.Join(dir, filepath.Dir(name)), 0755)).To(Succeed())
+			Expect(os.WriteFile(filepath.Join(dir, name), []byte("kind: Pod\n"), 0600)).To(Succeed())
+		}
+		gitignore := "# dependencies\nnode_modules/\n*.secret\n!keep.secret\n/build\n"
+		Expect(os.WriteFile(filepath.Join(dir, GitignoreFile), []byte(gitignore), 0600)).To(Succeed())
+	})
+
+	It("honors the standard patterns", func() {
+		g, err := FindGitignore(filepath.Join(dir, "k8s"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(g.Root).To(Equal(dir))
+
+		Expect(g.Ignored(filepath.Join(dir, "k8s/pod.yaml"), false)).To(BeFalse())
+		Expect(g.Ignored(filepath.Join(dir, "k8s/db.secret"), false)).To(BeTrue())
+		Expect(g.Ignored(filepath.Join(dir, "k8s/keep.secret"), false)).To(BeFalse())
+		Expect(g.Ignored(filepath.Join(dir, "node_modules/lib/index.yaml"), false)).To(BeTrue())
+		// anchored patterns only match at the root
+		Expect(g.Ignored(filepath.Join(dir, "build/out.yaml"), false)).To(BeTrue())
+		Expect(g.Ignored(filepath.Join(dir, "k8s/build/pod.yaml"), false)).To(BeFalse())
+		// paths outside of the repo are never ignored
+		Expect(g.Ignored(filepath.Dir(dir), true)).To(BeFalse())
+	})
+
+	It("returns nothing when there is no .gitignore", func() {
+		Expect(os.Remove(filepath.Join(dir, GitignoreFile))).To(Succeed())
+		Expect(FindGitignore(filepath.Join(dir, "k8s"))).To(BeNil())
+	})
+
+	It("leaves ignored files out of the filemap", func() {
+		fm := NewFilemap()
+		Expect(fm.UseGitignore(dir)).To(Succeed())
+		Expect(fm.LoadFiles([]string{filepath.Join(dir, "**/*.yaml"), filepath.Join(dir, "k8s/*.secret")})).To(Succeed())
+		Expect(fm.Files).To(HaveLen(3))
+		encoded := fm.EncodeToInputText()
+		Expect(encoded).To(ContainSubstring("@keep.secret"))
+		Expect(encoded).NotTo(ContainSubstring("@db.secret"))
+		Expect(encoded).NotTo(ContainSubstring("@index.yaml"))
+		Expect(encoded).NotTo(ContainSubstring("@out.yaml"))
+	})
+
+	It("includes every file without the .gitignore", func() {
+		fm := NewFilemap()
+		Expect(fm.LoadFiles([]string{filepath.Join(dir, "**/*.yaml")})).To(Succeed())
+		Expect(fm.Files).To(HaveLen(4))
+	})
+})
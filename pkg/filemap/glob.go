@@ -0,0 +1,106 @@
+package filemap
+
+import (
+	"io/fs"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// DoubleStar Is the glob segment which matches any number of directories.
+const DoubleStar = "**"
+
+// MatchGlob Reports whether the slash-separated name matches the pattern, where a '**'
+// segment matches zero or more directories and every other segment is matched with path.Match.
+func MatchGlob(pattern, name string) (bool, error) {
+	return matchSegments(splitPath(pattern), splitPath(name))
+}
+
+// matchSegments Matches the segments of a name against the segments of a pattern.
+func matchSegments(pattern, name []string) (bool, error) {
+	for len(pattern) > 0 {
+		if pattern[0] == DoubleStar {
+			// try to match the rest of the pattern after skipping any number of segments
+			for skip := 0; skip <= len(name); skip++ {
+				if ok, err := matchSegments(pattern[1:], name[skip:]); ok || err != nil {
+					return ok, err
+				}
+			}
+			return false, nil
+		}
+		if len(name) == 0 {
+			return false, nil
+		}
+		if ok, err := path.Match(pattern[0], name[0]); !ok || err != nil {
+			return false, err
+		}
+		pattern, name = pattern[1:], name[1:]
+	}
+	return len(name) == 0, nil
+}
+
+// Glob Returns the names of the files matching the pattern. Patterns containing a '**'
+// segment walk the directory tree below the part of the pattern without wildcards,
+// and only match regular files. Other patterns behave like filepath.Glob.
+func Glob(pattern string) ([]string, error) {
+	segments := splitPath(pattern)
+	doubleStar := false
+	for _, segment := range segments {
+		if segment == DoubleStar {
+			doubleStar = true
+		}
+	}
+	if !doubleStar {
+		return filepath.Glob(pattern)
+	}
+
+	// walk from the deepest directory which doesn't need to be matched
+	var base []string
+	for _, segment := range segments {
+		if strings.ContainsAny(segment, `*?[\`) {
+			break
+		}
+		base = append(base, segment)
+	}
+	root := strings.Join(base, "/")
+	if root == "" {
+		root = "."
+	}
+	if strings.HasPrefix(filepath.ToSlash(pattern), "/") {
+		root = "/" + root
+	}
+	cleanPattern := path.Clean(filepath.ToSlash(pattern))
+
+	var matches []string
+	err := filepath.WalkDir(filepath.FromSlash(root), func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			// a missing root matches nothing, like filepath.Glob
+			if name == filepath.FromSlash(root) {
+				return filepath.SkipDir
+			}
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		ok, err := MatchGlob(cleanPattern, filepath.ToSlash(name))
+		if err != nil {
+			return err
+		}
+		if ok {
+			matches = append(matches, name)
+		}
+		return nil
+	})
+	return matches, err
+}
+
+// splitPath Splits a cleaned, slash-separated path into its segments.
+func splitPath(name string) []string {
+	name = path.Clean(filepath.ToSlash(name))
+	name = strings.TrimPrefix(name, "/")
+	if name == "." || name == "" {
+		return nil
+	}
+	return strings.Split(name, "/")
+}
@@ -0,0 +1,99 @@
+package filemap_test
+
+import (
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/redhat-et/copilot-ops/pkg/cmd/config"
+	. "github.com/redhat-et/copilot-ops/pkg/filemap"
+)
+
+var _ = Describe("Glob", func() {
+	It("matches any number of directories with '**'", func() {
+		for pattern, name := range map[string]string{
+			"k8s/**/*.yaml":    "k8s/app/web/deployment.yaml",
+			"k8s/**":           "k8s/service.yaml",
+			"**/*_test.yaml":   "k8s/app/pod_test.yaml",
+			"./k8s/**/*.yaml":  "k8s/pod.yaml",
+			"k8s/*/pod.yaml":   "k8s/app/pod.yaml",
+			"/repo/**/*.yaml":  "/repo/pod.yaml",
+			"k8s/**/app/*.yml": "k8s/app/pod.yml",
+		} {
+			Expect(MatchGlob(pattern, name)).To(BeTrue(), pattern)
+		}
+		for pattern, name := range map[string]string{
+			"k8s/**/*.yaml":  "k8s/app/pod.json",
+			"k8s/*.yaml":     "k8s/app/pod.yaml",
+			"**/*_test.yaml": "k8s/app/pod.yaml",
+			"k8s/**":         "docs/pod.yaml",
+		} {
+			Expect(MatchGlob(pattern, name)).To(BeFalse(), pattern)
+		}
+		_, err := MatchGlob("k8s/[", "k8s/a")
+		Expect(err).To(HaveOccurred())
+	})
+
+	When("files are collected from a directory tree", func() {
+		var dir string
+
+		BeforeEach(func() {
+			dir = GinkgoT().TempDir()
+			for _, name := range []string{
+				"k8s/pod.yaml",
+				"k8s/app/deployment.yaml",
+				"k8s/app/deployment_test.yaml",
+				"k8s/app/web/service.yaml",
+				"k8s/app/config.json",
+				"docs/pod.yaml",
+			} {
+				Expect(os.MkdirAll(filepath.Join(dir, filepath.Dir(name)), 0755)).To(Succeed())
+				Expect(os.WriteFile(filepath.Join(dir, name), []byte("kind: Pod\n"), 0600)).To(Succeed())
+			}
+		})
+
+		It("finds nested matches", func() {
+			matches, err := Glob(filepath.Join(dir, "k8s/**/*.yaml"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(matches).To(ConsistOf(
+				filepath.Join(dir, "k8s/pod.yaml"),
+				filepath.Join(dir, "k8s/app/deployment.yaml"),
+				filepath.Join(dir, "k8s/app/deployment_test.yaml"),
+				filepath.Join(dir, "k8s/app/web/service.yaml"),
+			))
+
+			matches, err = Glob(filepath.Join(dir, "missing/**/*.yaml"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(matches).To(BeEmpty())
+		})
+
+		It("resolves filesets with excludes, only once per file", func() {
+			matches, err := ResolveFileset(config.Filesets{
+				Name:    "k8s",
+				Files:   []string{filepath.Join(dir, "k8s/*.yaml")},
+				Include: []string{filepath.Join(dir, "k8s/**/*.yaml"), filepath.Join(dir, "k8s/**/*.json")},
+				Exclude: []string{"**/*_test.yaml", "**/web/**"},
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(matches).To(ConsistOf(
+				filepath.Join(dir, "k8s/pod.yaml"),
+				filepath.Join(dir, "k8s/app/deployment.yaml"),
+				filepath.Join(dir, "k8s/app/config.json"),
+			))
+		})
+
+		It("loads the resolved files", func() {
+			fm := NewFilemap()
+			conf := config.Config{Filesets: []config.Filesets{{
+				Name:    "k8s",
+				Include: []string{filepath.Join(dir, "**/pod.yaml")},
+				Exclude: []string{"**/docs/**"},
+			}}}
+			Expect(fm.LoadFilesets([]string{"k8s"}, conf, config.ConfigFile)).To(Succeed())
+			Expect(fm.Files).To(HaveLen(1))
+			Expect(fm.Files["pod.yaml"].Path).To(Equal(filepath.Join(dir, "k8s/pod.yaml")))
+		})
+	})
+})
@@ -0,0 +1,278 @@
+package filemap
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// defaultIndent Is how far the fields of a mapping are indented when it has none to go by.
+const defaultIndent = "  "
+
+// AddMetadata Adds the labels and annotations to every resource in the YAML files with AddResourceMetadata.
+// Files whose resources can't be edited are left as they are, and reported in the error.
+func (fm *Filemap) AddMetadata(labels, annotations map[string]string, overwrite bool) error {
+	var failed []string
+	for tag, file := range fm.Files {
+		if file.FileType(tag) != FileTypeYAML {
+			continue
+		}
+		content, err := AddResourceMetadata(file.Content, labels, annotations, overwrite)
+		if err != nil {
+			failed = append(failed, fmt.Sprintf("%s: %s", tag, err))
+			continue
+		}
+		file.Content = content
+		fm.Files[tag] = file
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("could not add the labels and annotations to %s", strings.Join(failed, "; "))
+	}
+	return nil
+}
+
+// AddResourceMetadata Merges the labels and annotations into the metadata of every resource in the YAML content,
+// creating the metadata when a resource has none. Labels and annotations which a resource already has are kept,
+// unless overwrite is set. The lines are edited in place, so comments and formatting are kept.
+func AddResourceMetadata(content string, labels, annotations map[string]string, overwrite bool) (string, error) {
+	if len(labels) == 0 && len(annotations) == 0 {
+		return content, nil
+	}
+	return editResources(content, func(kind string, lines []string) ([]string, error) {
+		lines, metadata, ok := metadataBlock(lines)
+		if !ok {
+			return nil, fmt.Errorf("the metadata of the %s must be a block to add labels or annotations", kind)
+		}
+		var err error
+		if lines, err = mergeField(lines, metadata, "labels", labels, overwrite); err != nil {
+			return nil, fmt.Errorf("the %s: %w", kind, err)
+		}
+		// the labels may have moved the end of the metadata
+		if lines, metadata, ok = metadataBlock(lines); !ok {
+			return nil, fmt.Errorf("the metadata of the %s must be a block to add labels or annotations", kind)
+		}
+		if lines, err = mergeField(lines, metadata, "annotations", annotations, overwrite); err != nil {
+			return nil, fmt.Errorf("the %s: %w", kind, err)
+		}
+		return lines, nil
+	})
+}
+
+// mergeField Merges the values into the mapping under the given field of the metadata, creating the field
+// when it's missing. Values which are already set are only replaced when overwrite is set.
+func mergeField(lines []string, metadata block, name string, values map[string]string, overwrite bool) ([]string, error) {
+	if len(values) == 0 {
+		return lines, nil
+	}
+	i := metadata.field(lines, name)
+	if i < 0 {
+		i = metadata.end
+		lines = insertLines(lines, i, metadata.indent+name+":\n")
+	}
+	step := strings.TrimPrefix(metadata.indent, lineIndent(lines[metadata.start-1]))
+	mapping, ok := blockOf(lines, i, step)
+	if !ok {
+		return nil, fmt.Errorf("the %s must be a block", name)
+	}
+
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		entry := mapping.indent + yamlScalar(key) + ": " + yamlScalar(values[key]) + "\n"
+		if j := mapping.field(lines, key); j >= 0 {
+			if overwrite {
+				mapping.end += 1 - (fieldEnd(lines, j) - j)
+				lines = replaceField(lines, j, entry)
+			}
+			continue
+		}
+		lines = insertLines(lines, mapping.end, entry)
+		mapping.end++
+	}
+	return lines, nil
+}
+
+// block Is a block mapping within the lines of a YAML document. Its fields are the lines from start
+// up to end which are indented by indent.
+type block struct {
+	start  int
+	end    int
+	indent string
+}
+
+// field Returns the index of the line which starts the field with the given key, or -1 if there is none.
+func (b block) field(lines []string, key string) int {
+	for i := b.start; i < b.end; i++ {
+		if !isContent(lines[i]) || lineIndent(lines[i]) != b.indent {
+			continue
+		}
+		if k, _, ok := splitField(lines[i]); ok && k == key {
+			return i
+		}
+	}
+	return -1
+}
+
+// editResources Calls edit with the kind and the lines of every document in the YAML content which is
+// a Kubernetes resource, and joins the edited lines back together. Other documents are left alone.
+func editResources(content string, edit func(kind string, lines []string) ([]string, error)) (string, error) {
+	var out strings.Builder
+	var document []string
+	flush := func() error {
+		header := resourceHeader{}
+		err := yaml.Unmarshal([]byte(strings.Join(document, "")), &header)
+		if err == nil && header.Kind != "" {
+			if document, err = edit(header.Kind, document); err != nil {
+				return err
+			}
+		}
+		out.WriteString(strings.Join(document, ""))
+		document = nil
+		return nil
+	}
+	for _, line := range strings.SplitAfter(content, "\n") {
+		trimmed := strings.TrimRight(line, " \t\r\n")
+		if trimmed == DocumentSeparator || strings.HasPrefix(trimmed, DocumentSeparator+" ") {
+			if err := flush(); err != nil {
+				return "", err
+			}
+			out.WriteString(line)
+			continue
+		}
+		document = append(document, line)
+	}
+	if err := flush(); err != nil {
+		return "", err
+	}
+	return out.String(), nil
+}
+
+// metadataBlock Returns the lines of the document along with its metadata, which is added when it's missing,
+// and false when the metadata isn't a block mapping.
+func metadataBlock(lines []string) ([]string, block, bool) {
+	root := block{end: len(lines)}
+	for root.end > 0 && !isContent(lines[root.end-1]) {
+		root.end--
+	}
+	i := root.field(lines, "metadata")
+	if i < 0 {
+		i = root.end
+		lines = insertLines(lines, i, "metadata:\n")
+	}
+	metadata, ok := blockOf(lines, i, defaultIndent)
+	return lines, metadata, ok
+}
+
+// blockOf Returns the block mapping which is the value of the field starting at line i. When the mapping
+// is empty, its fields are indented by step from the field. False is returned when the value of the field
+// is on the same line, such as a flow mapping.
+func blockOf(lines []string, i int, step string) (block, bool) {
+	if _, value, _ := splitField(lines[i]); value != "" {
+		return block{}, false
+	}
+	b := block{start: i + 1, end: fieldEnd(lines, i), indent: lineIndent(lines[i]) + step}
+	for j := b.start; j < b.end; j++ {
+		if isContent(lines[j]) {
+			b.indent = lineIndent(lines[j])
+			break
+		}
+	}
+	return b, true
+}
+
+// fieldEnd Returns the index after the last line of the field starting at line i, including the lines of
+// its value which are indented further, and the items of a sequence which are indented as far as the field.
+func fieldEnd(lines []string, i int) int {
+	indent := len(lineIndent(lines[i]))
+	end := i + 1
+	for j := i + 1; j < len(lines); j++ {
+		if !isContent(lines[j]) {
+			continue
+		}
+		n := len(lineIndent(lines[j]))
+		if n < indent || (n == indent && !strings.HasPrefix(strings.TrimSpace(lines[j]), "-")) {
+			break
+		}
+		end = j + 1
+	}
+	return end
+}
+
+// splitField Splits a line such as 'name: value # comment' into its unquoted key and its value,
+// leaving out the comment. False is returned for lines which aren't the field of a mapping.
+func splitField(line string) (key, value string, ok bool) {
+	trimmed := strings.TrimSpace(line)
+	if strings.HasPrefix(trimmed, "#") || strings.HasPrefix(trimmed, "-") {
+		return "", "", false
+	}
+	colon := -1
+	for i := 0; i < len(trimmed); i++ {
+		if trimmed[i] == ':' && (i == len(trimmed)-1 || trimmed[i+1] == ' ' || trimmed[i+1] == '\t') {
+			colon = i
+			break
+		}
+	}
+	if colon < 0 {
+		return "", "", false
+	}
+	key = trimmed[:colon]
+	var unquoted string
+	if err := yaml.Unmarshal([]byte(key), &unquoted); err == nil {
+		key = unquoted
+	}
+	value = strings.TrimSpace(trimmed[colon+1:])
+	if strings.HasPrefix(value, "#") {
+		value = ""
+	} else if comment := strings.Index(value, " #"); comment >= 0 {
+		value = strings.TrimSpace(value[:comment])
+	}
+	return key, value, true
+}
+
+// yamlScalar Returns the string as a YAML scalar, quoted when it would otherwise be read as another type.
+func yamlScalar(s string) string {
+	out, err := yaml.Marshal(s)
+	scalar := strings.TrimSuffix(string(out), "\n")
+	if err != nil || strings.Contains(scalar, "\n") {
+		return strconv.Quote(s)
+	}
+	return scalar
+}
+
+// lineIndent Returns the spaces which the line is indented by.
+func lineIndent(line string) string {
+	return line[:len(line)-len(strings.TrimLeft(line, " "))]
+}
+
+// isContent Reports whether the line is neither blank nor a comment.
+func isContent(line string) bool {
+	trimmed := strings.TrimSpace(line)
+	return trimmed != "" && !strings.HasPrefix(trimmed, "#")
+}
+
+// insertLines Returns the lines with the new lines inserted before index i.
+func insertLines(lines []string, i int, added ...string) []string {
+	edited := make([]string, 0, len(lines)+len(added))
+	edited = append(edited, lines[:i]...)
+	// the line before may have been the last line of the document, without a line break
+	if i > 0 && !strings.HasSuffix(edited[i-1], "\n") {
+		edited[i-1] += "\n"
+	}
+	edited = append(edited, added...)
+	return append(edited, lines[i:]...)
+}
+
+// replaceField Returns the lines with the field starting at line i replaced by the new lines.
+func replaceField(lines []string, i int, added ...string) []string {
+	end := fieldEnd(lines, i)
+	edited := make([]string, 0, len(lines)-(end-i)+len(added))
+	edited = append(edited, lines[:i]...)
+	edited = append(edited, added...)
+	return append(edited, lines[end:]...)
+}
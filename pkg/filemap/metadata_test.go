@@ -0,0 +1,52 @@
+package filemap_test
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	. "github.com/redhat-et/copilot-ops/pkg/filemap"
+)
+
+var _ = Describe("Metadata", func() {
+	labels := map[string]string{"app.kubernetes.io/managed-by": "copilot-ops", "tier": "front"}
+	annotations := map[string]string{"owner": "team-web", "enabled": "true"}
+
+	It("merges labels and annotations into the metadata, keeping comments", func() {
+		content, err := AddResourceMetadata("kind: Deployment\nmetadata:\n  name: web # the frontend\n"+
+			"  labels:\n    app: web\nspec:\n  replicas: 2\n", labels, annotations, false)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(content).To(Equal("kind: Deployment\nmetadata:\n  name: web # the frontend\n" +
+			"  labels:\n    app: web\n    app.kubernetes.io/managed-by: copilot-ops\n    tier: front\n" +
+			"  annotations:\n    enabled: \"true\"\n    owner: team-web\nspec:\n  replicas: 2\n"))
+	})
+
+	It("creates the metadata of resources which have none", func() {
+		content, err := AddResourceMetadata("kind: Namespace\n---\nnot: a resource\n", labels, nil, false)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(content).To(Equal("kind: Namespace\nmetadata:\n  labels:\n" +
+			"    app.kubernetes.io/managed-by: copilot-ops\n    tier: front\n---\nnot: a resource\n"))
+	})
+
+	It("doesn't clobber the values the resource already has unless told to", func() {
+		pod := "kind: Pod\nmetadata:\n    labels:\n        tier: back\n    annotations:\n" +
+			"        owner: |\n            team-db\n        note: keep\n"
+		content, err := AddResourceMetadata(pod, labels, annotations, false)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(content).To(Equal("kind: Pod\nmetadata:\n    labels:\n        tier: back\n" +
+			"        app.kubernetes.io/managed-by: copilot-ops\n    annotations:\n" +
+			"        owner: |\n            team-db\n        note: keep\n        enabled: \"true\"\n"))
+
+		content, err = AddResourceMetadata(pod, labels, annotations, true)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(content).To(Equal("kind: Pod\nmetadata:\n    labels:\n        tier: front\n" +
+			"        app.kubernetes.io/managed-by: copilot-ops\n    annotations:\n" +
+			"        owner: team-web\n        note: keep\n        enabled: \"true\"\n"))
+	})
+
+	It("reports labels which aren't a block", func() {
+		fm := NewFilemap()
+		fm.Files["pod.yaml"] = File{Content: "kind: Pod\nmetadata:\n  labels: {app: web}\n"}
+		Expect(fm.AddMetadata(labels, nil, false)).To(MatchError(ContainSubstring("pod.yaml: the Pod: the labels must be a block")))
+		Expect(fm.Files["pod.yaml"].Content).To(Equal("kind: Pod\nmetadata:\n  labels: {app: web}\n"))
+	})
+})
@@ -0,0 +1,127 @@
+package filemap
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// ClusterScopedDir Is the directory which cluster-scoped resources are placed
+// in when files are grouped by namespace.
+const ClusterScopedDir = "_cluster"
+
+// resourceHeader Contains the fields of a Kubernetes resource needed to determine its scope.
+type resourceHeader struct {
+	Kind     string `yaml:"kind"`
+	Metadata struct {
+		Namespace string `yaml:"namespace"`
+	} `yaml:"metadata"`
+}
+
+// clusterScopedKinds Returns the kinds of the built-in Kubernetes resources which are not namespaced.
+func clusterScopedKinds() map[string]bool {
+	return map[string]bool{
+		"APIService":                     true,
+		"CertificateSigningRequest":      true,
+		"ClusterRole":                    true,
+		"ClusterRoleBinding":             true,
+		"CSIDriver":                      true,
+		"CSINode":                        true,
+		"CustomResourceDefinition":       true,
+		"IngressClass":                   true,
+		"MutatingWebhookConfiguration":   true,
+		"Namespace":                      true,
+		"Node":                           true,
+		"PersistentVolume":               true,
+		"PriorityClass":                  true,
+		"RuntimeClass":                   true,
+		"StorageClass":                   true,
+		"ValidatingWebhookConfiguration": true,
+		"VolumeAttachment":               true,
+	}
+}
+
+// ResourceNamespace Returns the namespace of the first resource in the given YAML content,
+// and whether that resource is cluster-scoped. Content which can't be parsed as a
+// resource returns an empty namespace.
+func ResourceNamespace(content string) (namespace string, clusterScoped bool) {
+	header := resourceHeader{}
+	if err := yaml.NewDecoder(strings.NewReader(content)).Decode(&header); err != nil {
+		return "", false
+	}
+	if clusterScopedKinds()[header.Kind] {
+		return "", true
+	}
+	return header.Metadata.Namespace, false
+}
+
+// GroupByNamespace Moves each file under a directory named after the namespace of
+// its resource, with cluster-scoped resources going to the ClusterScopedDir.
+// Files without a namespace keep their path. Files without a path use their tag.
+func (fm *Filemap) GroupByNamespace() {
+	for tag, file := range fm.Files {
+		namespace, clusterScoped := ResourceNamespace(file.Content)
+		if clusterScoped {
+			namespace = ClusterScopedDir
+		}
+		if namespace == "" {
+			continue
+		}
+		filePath := file.Path
+		if filePath == "" {
+			filePath = tag
+		}
+		file.Path = filepath.Join(namespace, filePath)
+		fm.Files[tag] = file
+	}
+}
+
+// ValidNamespace Reports whether Kubernetes accepts the name for a namespace, which must be a DNS label.
+func ValidNamespace(namespace string) bool {
+	return regexp.MustCompile(`^[a-z0-9]([-a-z0-9]{0,61}[a-z0-9])?$`).MatchString(namespace)
+}
+
+// SetNamespace Sets the namespace of every namespaced resource in the YAML files with SetResourceNamespace.
+// Files whose resources can't be edited are left as they are, and reported in the error.
+func (fm *Filemap) SetNamespace(namespace string) error {
+	var failed []string
+	for tag, file := range fm.Files {
+		if file.FileType(tag) != FileTypeYAML {
+			continue
+		}
+		content, err := SetResourceNamespace(file.Content, namespace)
+		if err != nil {
+			failed = append(failed, fmt.Sprintf("%s: %s", tag, err))
+			continue
+		}
+		file.Content = content
+		fm.Files[tag] = file
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("could not set the namespace of %s", strings.Join(failed, "; "))
+	}
+	return nil
+}
+
+// SetResourceNamespace Sets metadata.namespace on every namespaced resource in the YAML content,
+// replacing the namespace it had. Cluster-scoped resources and documents which aren't resources are
+// left alone. The lines are edited in place, so comments and formatting are kept.
+func SetResourceNamespace(content, namespace string) (string, error) {
+	return editResources(content, func(kind string, lines []string) ([]string, error) {
+		if clusterScopedKinds()[kind] {
+			return lines, nil
+		}
+		lines, metadata, ok := metadataBlock(lines)
+		if !ok {
+			return nil, fmt.Errorf("the metadata of the %s must be a block to set its namespace", kind)
+		}
+		field := metadata.indent + "namespace: " + yamlScalar(namespace) + "\n"
+		if i := metadata.field(lines, "namespace"); i >= 0 {
+			return replaceField(lines, i, field), nil
+		}
+		return insertLines(lines, metadata.start, field), nil
+	})
+}
@@ -0,0 +1,75 @@
+package filemap_test
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	. "github.com/redhat-et/copilot-ops/pkg/filemap"
+)
+
+var _ = Describe("Namespaces", func() {
+	It("finds the namespace of a resource", func() {
+		namespace, clusterScoped := ResourceNamespace("---\nkind: Pod\nmetadata:\n  name: web\n  namespace: shop\n")
+		Expect(namespace).To(Equal("shop"))
+		Expect(clusterScoped).To(BeFalse())
+
+		_, clusterScoped = ResourceNamespace("kind: ClusterRole\nmetadata:\n  name: reader\n")
+		Expect(clusterScoped).To(BeTrue())
+
+		namespace, clusterScoped = ResourceNamespace("not: [valid")
+		Expect(namespace).To(BeEmpty())
+		Expect(clusterScoped).To(BeFalse())
+	})
+
+	It("groups files into namespace directories", func() {
+		fm := NewFilemap()
+		fm.Files["pod.yaml"] = File{Content: "kind: Pod\nmetadata:\n  namespace: shop\n"}
+		fm.Files["role"] = File{Path: "rbac/role.yaml", Content: "kind: ClusterRole\n"}
+		fm.Files["service.yaml"] = File{Path: "app/service.yaml", Content: "kind: Service\n"}
+
+		fm.GroupByNamespace()
+		Expect(fm.Files["pod.yaml"].Path).To(Equal("shop/pod.yaml"))
+		Expect(fm.Files["role"].Path).To(Equal(ClusterScopedDir + "/rbac/role.yaml"))
+		Expect(fm.Files["service.yaml"].Path).To(Equal("app/service.yaml"))
+	})
+
+	It("sets the namespace of namespaced resources, keeping their comments", func() {
+		deployment := "# the web frontend\napiVersion: apps/v1\nkind: Deployment\nmetadata: # who we are\n" +
+			"    name: web\n    labels:\n      namespace: not-this-one\nspec:\n  replicas: 2\n"
+		content, err := SetResourceNamespace(deployment, "shop")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(content).To(Equal("# the web frontend\napiVersion: apps/v1\nkind: Deployment\nmetadata: # who we are\n" +
+			"    namespace: shop\n    name: web\n    labels:\n      namespace: not-this-one\nspec:\n  replicas: 2\n"))
+
+		content, err = SetResourceNamespace("kind: Service\nmetadata:\n  name: web\n  namespace: default # guessed\n", "shop")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(content).To(Equal("kind: Service\nmetadata:\n  name: web\n  namespace: shop\n"))
+
+		content, err = SetResourceNamespace("kind: ConfigMap\ndata:\n  key: value\n", "shop")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(content).To(Equal("kind: ConfigMap\ndata:\n  key: value\nmetadata:\n  namespace: shop\n"))
+	})
+
+	It("leaves cluster-scoped resources alone", func() {
+		content := "kind: ClusterRole\nmetadata:\n  name: reader\n---\nkind: Role\nmetadata:\n  name: reader\n"
+		content, err := SetResourceNamespace(content, "shop")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(content).To(Equal("kind: ClusterRole\nmetadata:\n  name: reader\n---\n" +
+			"kind: Role\nmetadata:\n  namespace: shop\n  name: reader\n"))
+	})
+
+	It("reports resources whose metadata can't be edited", func() {
+		fm := NewFilemap()
+		fm.Files["pod.yaml"] = File{Content: "kind: Pod\nmetadata: {name: web}\n"}
+		fm.Files["notes.txt"] = File{Content: "metadata:\n"}
+		Expect(fm.SetNamespace("shop")).To(MatchError(ContainSubstring("pod.yaml: the metadata of the Pod must be a block")))
+		Expect(fm.Files["pod.yaml"].Content).To(Equal("kind: Pod\nmetadata: {name: web}\n"))
+		Expect(fm.Files["notes.txt"].Content).To(Equal("metadata:\n"))
+	})
+
+	It("validates namespace names", func() {
+		Expect(ValidNamespace("shop-prod")).To(BeTrue())
+		Expect(ValidNamespace("Shop")).To(BeFalse())
+		Expect(ValidNamespace("-shop")).To(BeFalse())
+	})
+})
@@ -0,0 +1,55 @@
+package filemap
+
+import (
+	"errors"
+	"io"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// resourceName Contains the fields of a Kubernetes resource which a file can be named after.
+type resourceName struct {
+	Kind     string `yaml:"kind"`
+	Metadata struct {
+		Name string `yaml:"name"`
+	} `yaml:"metadata"`
+}
+
+// ResourceFileName Returns a file name derived from the kind and name of the resource in the YAML content,
+// such as 'deployment-web.yaml', and whether the content holds a single resource with both a kind and a name.
+func ResourceFileName(content string) (string, bool) {
+	decoder := yaml.NewDecoder(strings.NewReader(content))
+	var found *resourceName
+	for {
+		var resource *resourceName
+		err := decoder.Decode(&resource)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return "", false
+		}
+		// empty documents, e.g. after a trailing '---', are skipped
+		if resource == nil {
+			continue
+		}
+		if found != nil {
+			return "", false
+		}
+		found = resource
+	}
+	if found == nil {
+		return "", false
+	}
+	// only characters which are safe in a file name are kept
+	unsafeNameChars := regexp.MustCompile(`[^a-z0-9.-]+`)
+	kind := unsafeNameChars.ReplaceAllString(strings.ToLower(found.Kind), "-")
+	name := unsafeNameChars.ReplaceAllString(strings.ToLower(found.Metadata.Name), "-")
+	kind, name = strings.Trim(kind, "-."), strings.Trim(name, "-.")
+	if kind == "" || name == "" {
+		return "", false
+	}
+	return kind + "-" + name + ".yaml", true
+}
@@ -0,0 +1,25 @@
+package filemap_test
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	. "github.com/redhat-et/copilot-ops/pkg/filemap"
+)
+
+var _ = Describe("Naming", func() {
+	DescribeTable("names a file after the resource in it",
+		func(content, expected string) {
+			name, ok := ResourceFileName(content)
+			Expect(ok).To(Equal(expected != ""))
+			Expect(name).To(Equal(expected))
+		},
+		Entry("a single resource", "apiVersion: apps/v1\nkind: Deployment\nmetadata:\n  name: web\n",
+			"deployment-web.yaml"),
+		Entry("a resource between separators", "---\nkind: Pod\nmetadata:\n  name: Web_App\n---\n", "pod-web-app.yaml"),
+		Entry("a resource without a name", "kind: Pod\n", ""),
+		Entry("several resources", "kind: Pod\nmetadata:\n  name: a\n---\nkind: Pod\nmetadata:\n  name: b\n", ""),
+		Entry("text which isn't YAML", "Here is your Pod: [", ""),
+		Entry("a name which is only punctuation", "kind: Pod\nmetadata:\n  name: '..'\n", ""),
+	)
+})
@@ -0,0 +1,102 @@
+package filemap
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"strings"
+)
+
+// ErrConflict Is returned when the changes to a file no longer apply to it, because the lines
+// around them were modified on disk since the file was loaded.
+var ErrConflict = errors.New("the changes conflict with the file on disk")
+
+// ApplyChanges Applies the changes between the original and the updated content to the current content,
+// the way `patch -p0` applies a unified diff: each hunk is placed where its unchanged and removed lines
+// are found, looking outwards from where they were in the original, and lines outside of the hunks are kept.
+// ErrConflict is returned, naming the hunk, when one of them isn't found.
+func ApplyChanges(original, updated, current string) (string, error) {
+	if original == updated {
+		return current, nil
+	}
+	if original == current {
+		return updated, nil
+	}
+	lines := splitLinesKeepEnds(current)
+	patched := make([]string, 0, len(lines))
+	// pos is the number of lines of the current content which were already copied,
+	// and offset is how far the last hunk moved from where it was in the original
+	pos, offset := 0, 0
+	for i, h := range diffHunks(splitLinesKeepEnds(original), splitLinesKeepEnds(updated), DiffContextLines) {
+		from := h.from()
+		at, ok := findLines(lines, from, h.fromStart+offset, pos)
+		if !ok {
+			return "", fmt.Errorf("hunk #%d %s: %w", i+1, strings.TrimSpace(h.header()), ErrConflict)
+		}
+		patched = append(patched, lines[pos:at]...)
+		patched = append(patched, h.to()...)
+		pos = at + len(from)
+		offset = at - h.fromStart
+	}
+	patched = append(patched, lines[pos:]...)
+	return strings.Join(patched, ""), nil
+}
+
+// findLines Returns where the wanted lines are found in the lines, starting no earlier than min,
+// picking the match closest to the expected position.
+func findLines(lines, want []string, expected, min int) (int, bool) {
+	last := len(lines) - len(want)
+	if expected < min {
+		expected = min
+	}
+	if expected > last {
+		expected = last
+	}
+	for distance := 0; expected-distance >= min || expected+distance <= last; distance++ {
+		if at := expected - distance; at >= min && at <= last && matchLines(lines[at:], want) {
+			return at, true
+		}
+		if at := expected + distance; distance > 0 && at >= min && at <= last && matchLines(lines[at:], want) {
+			return at, true
+		}
+	}
+	return 0, false
+}
+
+// matchLines Returns whether the lines start with the wanted lines.
+func matchLines(lines, want []string) bool {
+	for i, line := range want {
+		if lines[i] != line {
+			return false
+		}
+	}
+	return true
+}
+
+// Patch Replaces the content of every file which was loaded from disk with its current content on disk,
+// with only the changes made since it was loaded applied to it, so that unrelated edits made in the meantime
+// are kept. Other files are left as they are. Nothing is changed when the changes to any file conflict.
+func (fm *Filemap) Patch() error {
+	patched := make(map[string]File, len(fm.Files))
+	for _, tag := range fm.TagsByPath() {
+		file := fm.Files[tag]
+		if file.original == nil || file.URL != "" {
+			continue
+		}
+		current, err := os.ReadFile(file.Path)
+		if errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("could not patch %q, it was removed since it was loaded: %w", file.Path, ErrConflict)
+		} else if err != nil {
+			return fmt.Errorf("could not read %q: %w", file.Path, err)
+		}
+		if file.Content, err = ApplyChanges(*file.original, file.Content, string(current)); err != nil {
+			return fmt.Errorf("could not patch %q: %w", file.Path, err)
+		}
+		patched[tag] = file
+	}
+	for tag, file := range patched {
+		fm.Files[tag] = file
+	}
+	return nil
+}
@@ -0,0 +1,106 @@
+package filemap_test
+
+import (
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/redhat-et/copilot-ops/pkg/filemap"
+)
+
+var _ = Describe("ApplyChanges", func() {
+	original := "kind: Deployment\nmetadata:\n  name: web\nspec:\n  replicas: 1\n  template:\n" +
+		"    spec:\n      containers:\n      - name: web\n        image: nginx:1.21\n"
+	updated := "kind: Deployment\nmetadata:\n  name: web\nspec:\n  replicas: 1\n  template:\n" +
+		"    spec:\n      containers:\n      - name: web\n        image: nginx:1.23\n"
+
+	It("applies the changes to an untouched file", func() {
+		patched, err := filemap.ApplyChanges(original, updated, original)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(patched).To(Equal(updated))
+	})
+
+	It("keeps the file when nothing changed", func() {
+		current := original + "status: {}\n"
+		patched, err := filemap.ApplyChanges(original, original, current)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(patched).To(Equal(current))
+	})
+
+	It("keeps unrelated lines which changed on disk", func() {
+		current := "# managed by hand\nkind: Deployment\nmetadata:\n  name: web\n  labels:\n    app: web\nspec:\n" +
+			"  replicas: 1\n  template:\n    spec:\n      containers:\n      - name: web\n        image: nginx:1.21\n"
+		patched, err := filemap.ApplyChanges(original, updated, current)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(patched).To(Equal("# managed by hand\nkind: Deployment\nmetadata:\n  name: web\n  labels:\n    app: web\n" +
+			"spec:\n  replicas: 1\n  template:\n    spec:\n      containers:\n      - name: web\n        image: nginx:1.23\n"))
+	})
+
+	It("applies every hunk where it moved to", func() {
+		from := "a\nb\nc\nd\ne\nf\ng\nh\ni\nj\nk\nl\n"
+		to := "A\nb\nc\nd\ne\nf\ng\nh\ni\nj\nk\nL\n"
+		current := "a\nb\nc\nd\ne\nf\nadded\ng\nh\ni\nj\nk\nl\n"
+		patched, err := filemap.ApplyChanges(from, to, current)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(patched).To(Equal("A\nb\nc\nd\ne\nf\nadded\ng\nh\ni\nj\nk\nL\n"))
+	})
+
+	It("rejects a hunk whose lines changed on disk", func() {
+		current := "kind: Deployment\nmetadata:\n  name: web\nspec:\n  replicas: 1\n  template:\n" +
+			"    spec:\n      containers:\n      - name: web\n        image: nginx:1.22\n"
+		_, err := filemap.ApplyChanges(original, updated, current)
+		Expect(err).To(MatchError(filemap.ErrConflict))
+		Expect(err).To(MatchError(ContainSubstring("hunk #1 @@ -7,4 +7,4 @@")))
+	})
+
+	It("rejects a hunk whose context changed on disk", func() {
+		current := "kind: Deployment\nmetadata:\n  name: web\nspec:\n  replicas: 1\n  template:\n" +
+			"    spec:\n      containers:\n      - name: app\n        image: nginx:1.21\n"
+		_, err := filemap.ApplyChanges(original, updated, current)
+		Expect(err).To(MatchError(filemap.ErrConflict))
+	})
+})
+
+var _ = Describe("Patch", func() {
+	var dir string
+	var fm *filemap.Filemap
+
+	BeforeEach(func() {
+		dir = GinkgoT().TempDir()
+		Expect(os.WriteFile(filepath.Join(dir, "pod.yaml"), []byte("kind: Pod\nmetadata:\n  name: web\n"+
+			"spec:\n  containers:\n  - name: web\n    image: nginx:1.21\n"), 0600)).To(Succeed())
+		fm = filemap.NewFilemap()
+		Expect(fm.LoadFile(filepath.Join(dir, "pod.yaml"))).To(Succeed())
+		Expect(fm.Decode("# @pod.yaml\nkind: Pod\nmetadata:\n  name: web\n" +
+			"spec:\n  containers:\n  - name: web\n    image: nginx:1.23\n")).To(Succeed())
+		fm.AddContentByTag("service.yaml", "kind: Service\n")
+	})
+
+	It("applies the changes on top of the file on disk", func() {
+		podPath := filepath.Join(dir, "pod.yaml")
+		Expect(os.WriteFile(podPath, []byte("# edited by hand\nkind: Pod\nmetadata:\n  name: web\n"+
+			"spec:\n  containers:\n  - name: web\n    image: nginx:1.21\n"), 0600)).To(Succeed())
+
+		Expect(fm.Patch()).To(Succeed())
+		Expect(fm.Files["pod.yaml"].Content).To(Equal("# edited by hand\nkind: Pod\nmetadata:\n  name: web\n" +
+			"spec:\n  containers:\n  - name: web\n    image: nginx:1.23\n"))
+		// files which weren't loaded are kept as they are
+		Expect(fm.Files["service.yaml"].Content).To(Equal("kind: Service\n"))
+	})
+
+	It("changes nothing when the changes conflict", func() {
+		podPath := filepath.Join(dir, "pod.yaml")
+		Expect(os.WriteFile(podPath, []byte("kind: Pod\nmetadata:\n  name: web\n"+
+			"spec:\n  containers:\n  - name: web\n    image: nginx:1.22\n"), 0600)).To(Succeed())
+
+		Expect(fm.Patch()).To(MatchError(filemap.ErrConflict))
+		Expect(fm.Files["pod.yaml"].Content).To(ContainSubstring("image: nginx:1.23"))
+	})
+
+	It("rejects a file which was removed since it was loaded", func() {
+		Expect(os.Remove(filepath.Join(dir, "pod.yaml"))).To(Succeed())
+		Expect(fm.Patch()).To(MatchError(filemap.ErrConflict))
+	})
+})
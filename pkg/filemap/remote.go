@@ -0,0 +1,110 @@
+package filemap
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/redhat-et/copilot-ops/pkg/logger"
+)
+
+// IsURL Reports whether the file given on the command-line is an HTTP(S) URL rather than a path.
+func IsURL(file string) bool {
+	return strings.HasPrefix(file, "http://") || strings.HasPrefix(file, "https://")
+}
+
+// UseHTTPClient Fetches the files given as URLs with the client, sending the header along with every request.
+func (fm *Filemap) UseHTTPClient(client *http.Client, header http.Header) {
+	fm.httpClient = client
+	fm.header = header
+}
+
+// LoadURL Fetches the file at the URL into the filemap, as context which is never written.
+// The file is tagged by the last element of the URL's path, and its path is made up of
+// the URL's host and path so that it can't collide with a file on disk.
+func (fm *Filemap) LoadURL(ctx context.Context, rawURL string) error {
+	fileURL, err := url.Parse(rawURL)
+	if err != nil {
+		return fmt.Errorf("invalid URL %q: %w", rawURL, err)
+	}
+	filePath := path.Join(fileURL.Host, fileURL.Path)
+	tag := path.Base(filePath)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return fmt.Errorf("could not create request: %w", err)
+	}
+	for name, values := range fm.header {
+		for _, value := range values {
+			req.Header.Add(name, value)
+		}
+	}
+	client := fm.httpClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+	logger.Debugf("fetching %q\n", rawURL)
+	res, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("could not fetch %s: %w", rawURL, err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode < http.StatusOK || res.StatusCode >= http.StatusBadRequest {
+		return fmt.Errorf("could not fetch %s, status code: %d", rawURL, res.StatusCode)
+	}
+	content, err := io.ReadAll(res.Body)
+	if err != nil {
+		return fmt.Errorf("could not read %s: %w", rawURL, err)
+	}
+	if fm.TooLarge(int64(len(content))) {
+		logger.Warnf("skipping %s, its %d bytes are over the limit of %d bytes\n", rawURL, len(content), fm.maxFileSize)
+		return nil
+	}
+	if !IsText(content) {
+		logger.Warnf("skipping %s, it isn't a text file\n", rawURL)
+		return nil
+	}
+
+	return fm.addLoaded(tag, File{
+		Path:    filePath,
+		Content: string(content),
+		Type:    DetectFileType(filePath),
+		URL:     rawURL,
+	})
+}
+
+// LoadReader Reads a file which doesn't need to exist on disk, such as one piped through STDIN,
+// into the filemap under the given path. It's tagged by the last element of the path, and is
+// written to the path like a new file.
+func (fm *Filemap) LoadReader(filePath string, r io.Reader) error {
+	content, err := io.ReadAll(r)
+	if err != nil {
+		return fmt.Errorf("could not read %s: %w", filePath, err)
+	}
+	if fm.TooLarge(int64(len(content))) {
+		return fmt.Errorf("%s is %d bytes, which is over the limit of %d bytes", filePath, len(content), fm.maxFileSize)
+	}
+	if !IsText(content) {
+		return fmt.Errorf("%s isn't a text file", filePath)
+	}
+	return fm.addLoaded(filepath.Base(filePath), File{
+		Path:    filePath,
+		Content: string(content),
+		Type:    DetectFileType(filePath),
+	})
+}
+
+// DropFetched Removes the files which were fetched from URLs, which must never be written.
+func (fm *Filemap) DropFetched() {
+	for tag, file := range fm.Files {
+		if file.URL != "" {
+			logger.Debugf("not writing %q, which was fetched from %s\n", tag, file.URL)
+			delete(fm.Files, tag)
+		}
+	}
+}
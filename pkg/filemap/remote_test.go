@@ -0,0 +1,71 @@
+package filemap_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	. "github.com/redhat-et/copilot-ops/pkg/filemap"
+)
+
+var _ = Describe("Remote files", func() {
+	var server *httptest.Server
+	var fm *Filemap
+
+	BeforeEach(func() {
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path != "/manifests/service.yaml" {
+				http.NotFound(w, r)
+				return
+			}
+			fmt.Fprintf(w, "kind: Service\nmetadata:\n  name: %s\n", r.Header.Get("X-Name"))
+		}))
+		DeferCleanup(server.Close)
+		fm = NewFilemap()
+		fm.UseHTTPClient(server.Client(), http.Header{"X-Name": []string{"web"}})
+	})
+
+	It("recognizes URLs", func() {
+		Expect(IsURL("https://example.com/service.yaml")).To(BeTrue())
+		Expect(IsURL("http://example.com/service.yaml")).To(BeTrue())
+		Expect(IsURL("manifests/*.yaml")).To(BeFalse())
+	})
+
+	It("fetches the file with the header, under a path made from the URL", func() {
+		fileURL := server.URL + "/manifests/service.yaml"
+		Expect(fm.LoadFiles([]string{fileURL})).To(Succeed())
+		Expect(fm.Files).To(HaveKeyWithValue("service.yaml", File{
+			Path:    strings.TrimPrefix(server.URL, "http://") + "/manifests/service.yaml",
+			Content: "kind: Service\nmetadata:\n  name: web\n",
+			Type:    FileTypeYAML,
+			URL:     fileURL,
+		}))
+		Expect(fm.LoadOrder()).To(Equal([]string{"service.yaml"}))
+	})
+
+	It("never writes fetched files", func() {
+		wd, err := os.Getwd()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(os.Chdir(GinkgoT().TempDir())).To(Succeed())
+		DeferCleanup(os.Chdir, wd)
+
+		Expect(fm.LoadFiles([]string{server.URL + "/manifests/service.yaml"})).To(Succeed())
+		Expect(fm.WriteUpdatesToFiles()).To(Succeed())
+		entries, err := os.ReadDir(".")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(entries).To(BeEmpty())
+
+		fm.DropFetched()
+		Expect(fm.Files).To(BeEmpty())
+	})
+
+	It("fails when the file can't be fetched", func() {
+		err := fm.LoadFiles([]string{server.URL + "/missing.yaml"})
+		Expect(err).To(MatchError(ContainSubstring("status code: 404")))
+	})
+})
@@ -0,0 +1,218 @@
+package filemap
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// Markers of the fields in a review, for fields which are added, changed or removed.
+const (
+	ReviewAdded   = "+"
+	ReviewChanged = "~"
+	ReviewRemoved = "-"
+)
+
+// reviewResource Is a Kubernetes resource of a file, with the value of each of its fields by path.
+type reviewResource struct {
+	id     string
+	fields map[string]string
+}
+
+// Review Returns the changes which writing the filemap would make to the files on disk, grouped by the
+// Kubernetes resource they're made to, named after its kind and name. Rather than the changed lines,
+// every field which is added, changed or removed is listed by its path with its values before and after.
+// Files which aren't YAML or can't be parsed are only listed as changed. Files are reviewed in order of
+// their paths, and the review is empty when nothing would change.
+func (fm *Filemap) Review() (string, error) {
+	var out strings.Builder
+	for _, tag := range fm.TagsByPath() {
+		file := fm.Files[tag]
+		current, err := os.ReadFile(file.Path)
+		exists := err == nil && file.Path != ""
+		if err != nil && !errors.Is(err, fs.ErrNotExist) && file.Path != "" {
+			return "", fmt.Errorf("could not read %q: %w", file.Path, err)
+		}
+		if exists && string(current) == file.Content {
+			continue
+		}
+		out.WriteString(ReviewFile(diffPath(tag, file), string(current), file.Content, file.FileType(tag)))
+	}
+	return out.String(), nil
+}
+
+// ReviewFile Returns the review of the changes from one content of the file to the other,
+// given the type of the file.
+func ReviewFile(path, from, to, fileType string) string {
+	var out strings.Builder
+	out.WriteString(path + "\n")
+	if fileType != FileTypeYAML {
+		out.WriteString("  changed, but not a YAML file\n")
+		return out.String()
+	}
+	before, err := reviewResources(from)
+	if err != nil {
+		fmt.Fprintf(&out, "  changed, but the file on disk can't be parsed: %s\n", err)
+		return out.String()
+	}
+	after, err := reviewResources(to)
+	if err != nil {
+		fmt.Fprintf(&out, "  changed, but the new content can't be parsed: %s\n", err)
+		return out.String()
+	}
+
+	changed := false
+	previous := make(map[string]reviewResource, len(before))
+	for _, resource := range before {
+		previous[resource.id] = resource
+	}
+	kept := make(map[string]bool, len(after))
+	for _, resource := range after {
+		kept[resource.id] = true
+		old, ok := previous[resource.id]
+		if !ok {
+			fmt.Fprintf(&out, "  %s: added\n", resource.id)
+			changed = true
+			continue
+		}
+		if fields := reviewFields(old.fields, resource.fields); len(fields) > 0 {
+			fmt.Fprintf(&out, "  %s\n", resource.id)
+			for _, field := range fields {
+				fmt.Fprintf(&out, "    %s\n", field)
+			}
+			changed = true
+		}
+	}
+	for _, resource := range before {
+		if !kept[resource.id] {
+			fmt.Fprintf(&out, "  %s: removed\n", resource.id)
+			changed = true
+		}
+	}
+	if !changed {
+		out.WriteString("  only the formatting or comments changed\n")
+	}
+	return out.String()
+}
+
+// reviewFields Returns a line for every field which differs between the resources, in order of their paths.
+func reviewFields(before, after map[string]string) []string {
+	paths := make([]string, 0, len(before)+len(after))
+	for path := range before {
+		paths = append(paths, path)
+	}
+	for path := range after {
+		if _, ok := before[path]; !ok {
+			paths = append(paths, path)
+		}
+	}
+	sort.Strings(paths)
+
+	var lines []string
+	for _, path := range paths {
+		old, hadField := before[path]
+		value, hasField := after[path]
+		switch {
+		case !hadField:
+			lines = append(lines, fmt.Sprintf("%s %s: %s", ReviewAdded, path, value))
+		case !hasField:
+			lines = append(lines, fmt.Sprintf("%s %s: %s", ReviewRemoved, path, old))
+		case old != value:
+			lines = append(lines, fmt.Sprintf("%s %s: %s -> %s", ReviewChanged, path, old, value))
+		}
+	}
+	return lines
+}
+
+// reviewResources Returns the resources of the YAML documents in the content, in order.
+// Resources are named Kind/name, numbering those which share a name.
+func reviewResources(content string) ([]reviewResource, error) {
+	decoder := yaml.NewDecoder(strings.NewReader(content))
+	var resources []reviewResource
+	seen := map[string]int{}
+	for i := 1; ; i++ {
+		var document interface{}
+		err := decoder.Decode(&document)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		// empty documents, e.g. after a trailing '---', are skipped
+		if document == nil {
+			continue
+		}
+		id := resourceID(document, i)
+		seen[id]++
+		if seen[id] > 1 {
+			id = fmt.Sprintf("%s (%d)", id, seen[id])
+		}
+		fields := map[string]string{}
+		flattenFields("", document, fields)
+		resources = append(resources, reviewResource{id: id, fields: fields})
+	}
+	return resources, nil
+}
+
+// resourceID Returns the kind and name of the resource, falling back to the number of its document.
+func resourceID(document interface{}, i int) string {
+	resource, _ := document.(map[interface{}]interface{})
+	kind, _ := resource["kind"].(string)
+	metadata, _ := resource["metadata"].(map[interface{}]interface{})
+	name, _ := metadata["name"].(string)
+	if kind == "" || name == "" {
+		return fmt.Sprintf("document %d", i)
+	}
+	return kind + "/" + name
+}
+
+// flattenFields Sets the value of every field under the path, such as spec.containers[0].image.
+// Empty maps and lists are kept as fields of their own.
+func flattenFields(path string, value interface{}, fields map[string]string) {
+	switch v := value.(type) {
+	case map[interface{}]interface{}:
+		if len(v) == 0 {
+			fields[reviewPath(path)] = "{}"
+		}
+		for key, field := range v {
+			name := fmt.Sprint(key)
+			if path != "" {
+				name = path + "." + name
+			}
+			flattenFields(name, field, fields)
+		}
+	case []interface{}:
+		if len(v) == 0 {
+			fields[reviewPath(path)] = "[]"
+		}
+		for i, item := range v {
+			flattenFields(fmt.Sprintf("%s[%d]", path, i), item, fields)
+		}
+	case nil:
+		fields[reviewPath(path)] = "null"
+	case string:
+		// quoted when the value wouldn't otherwise be visible on a single line
+		if v == "" || strings.ContainsAny(v, "\n\t") || strings.TrimSpace(v) != v {
+			v = strconv.Quote(v)
+		}
+		fields[reviewPath(path)] = v
+	default:
+		fields[reviewPath(path)] = fmt.Sprint(v)
+	}
+}
+
+// reviewPath Returns the path a field is shown as, with '.' standing for a document which isn't a map.
+func reviewPath(path string) string {
+	if path == "" {
+		return "."
+	}
+	return path
+}
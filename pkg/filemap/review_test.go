@@ -0,0 +1,75 @@
+package filemap_test
+
+import (
+	"os"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/redhat-et/copilot-ops/pkg/filemap"
+)
+
+var _ = Describe("Review", func() {
+	deployment := "apiVersion: apps/v1\nkind: Deployment\nmetadata:\n  name: web\n  labels:\n    tier: frontend\n" +
+		"spec:\n  replicas: 1\n  template:\n    spec:\n      containers:\n      - name: web\n        image: nginx:1.21\n"
+
+	It("lists an added field", func() {
+		to := deployment + "        resources:\n          limits:\n            memory: 512Mi\n"
+		Expect(filemap.ReviewFile("web.yaml", deployment, to, filemap.FileTypeYAML)).To(Equal("web.yaml\n" +
+			"  Deployment/web\n" +
+			"    + spec.template.spec.containers[0].resources.limits.memory: 512Mi\n"))
+	})
+
+	It("lists a changed value with its values before and after", func() {
+		to := "apiVersion: apps/v1\nkind: Deployment\nmetadata:\n  labels:\n    tier: frontend\n  name: web\n" +
+			"spec:\n  replicas: 3\n  template:\n    spec:\n      containers:\n      - name: web\n        image: nginx:1.21\n"
+		Expect(filemap.ReviewFile("web.yaml", deployment, to, filemap.FileTypeYAML)).To(Equal("web.yaml\n" +
+			"  Deployment/web\n" +
+			"    ~ spec.replicas: 1 -> 3\n"))
+	})
+
+	It("lists a removed field", func() {
+		to := "apiVersion: apps/v1\nkind: Deployment\nmetadata:\n  name: web\n" +
+			"spec:\n  replicas: 1\n  template:\n    spec:\n      containers:\n      - name: web\n        image: nginx:1.21\n"
+		Expect(filemap.ReviewFile("web.yaml", deployment, to, filemap.FileTypeYAML)).To(Equal("web.yaml\n" +
+			"  Deployment/web\n" +
+			"    - metadata.labels.tier: frontend\n"))
+	})
+
+	It("groups the changes of each resource in a file", func() {
+		service := "apiVersion: v1\nkind: Service\nmetadata:\n  name: web\nspec:\n  ports:\n  - port: 80\n"
+		configMap := "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: web\ndata:\n  index.html: |\n    hello\n"
+		from := deployment + "---\n" + configMap
+		to := deployment + "---\n" + service + "---\n"
+		Expect(filemap.ReviewFile("web.yaml", from, to, filemap.FileTypeYAML)).To(Equal("web.yaml\n" +
+			"  Service/web: added\n" +
+			"  ConfigMap/web: removed\n"))
+	})
+
+	It("notes changes which don't touch any resource", func() {
+		Expect(filemap.ReviewFile("web.yaml", deployment, "# the web server\n"+deployment, filemap.FileTypeYAML)).
+			To(Equal("web.yaml\n  only the formatting or comments changed\n"))
+	})
+
+	It("only lists other types of files as changed", func() {
+		Expect(filemap.ReviewFile("run.sh", "#!/bin/sh\n", "#!/bin/sh\nset -e\n", filemap.FileTypeShell)).
+			To(Equal("run.sh\n  changed, but not a YAML file\n"))
+	})
+
+	It("reviews the files against the disk in order of their paths", func() {
+		wd, err := os.Getwd()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(os.Chdir(GinkgoT().TempDir())).To(Succeed())
+		DeferCleanup(os.Chdir, wd)
+		Expect(os.WriteFile("web.yaml", []byte(deployment), 0600)).To(Succeed())
+		Expect(os.WriteFile("same.yaml", []byte("kind: Pod\n"), 0600)).To(Succeed())
+
+		fm := filemap.NewFilemap()
+		fm.Files["web"] = filemap.File{Path: "web.yaml",
+			Content: deployment + "        ports:\n        - containerPort: 80\n"}
+		fm.Files["same"] = filemap.File{Path: "same.yaml", Content: "kind: Pod\n"}
+		fm.Files["pod"] = filemap.File{Path: "pod.yaml", Content: "kind: Pod\nmetadata:\n  name: db\n"}
+		Expect(fm.Review()).To(Equal("pod.yaml\n  Pod/db: added\n" +
+			"web.yaml\n  Deployment/web\n    + spec.template.spec.containers[0].ports[0].containerPort: 80\n"))
+	})
+})
@@ -30,3 +30,19 @@ func extractTagName(content string) (string, int32, error) {
 	}
 	return "", -1, fmt.Errorf("no tagname found in content")
 }
+
+// SplitFiles Splits the encoded files at every line which only contains the FileDelimeter.
+// Indented lines are part of a file, such as a heading underlined within a YAML block scalar.
+func SplitFiles(content string) []string {
+	var parts []string
+	var part strings.Builder
+	for _, line := range strings.SplitAfter(content, "\n") {
+		if strings.TrimRight(line, " \t\r\n") == FileDelimeter {
+			parts = append(parts, part.String())
+			part.Reset()
+			continue
+		}
+		part.WriteString(line)
+	}
+	return append(parts, part.String())
+}
@@ -0,0 +1,76 @@
+package filemap
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// ValidateManifest Checks that the content is valid YAML, and that every document in it
+// is a Kubernetes object with an apiVersion and a kind.
+func ValidateManifest(content string) error {
+	decoder := yaml.NewDecoder(strings.NewReader(content))
+	documents := 0
+	for i := 1; ; i++ {
+		var document interface{}
+		err := decoder.Decode(&document)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("document %d is not valid YAML: %w", i, err)
+		}
+		// empty documents, e.g. after a trailing '---', are skipped
+		if document == nil {
+			continue
+		}
+		documents++
+		object, ok := document.(map[interface{}]interface{})
+		if !ok {
+			return fmt.Errorf("document %d is not a YAML mapping", i)
+		}
+		var missing []string
+		for _, field := range []string{"apiVersion", "kind"} {
+			if value, ok := object[field].(string); !ok || value == "" {
+				missing = append(missing, field)
+			}
+		}
+		if len(missing) > 0 {
+			return fmt.Errorf("document %d is not a Kubernetes object, missing %s", i, strings.Join(missing, " and "))
+		}
+	}
+	if documents == 0 {
+		return fmt.Errorf("no YAML documents found")
+	}
+	return nil
+}
+
+// Validate Checks every YAML file in the filemap with ValidateManifest, returning
+// an error for each invalid file, sorted by file name. Other types of files are skipped.
+func (fm *Filemap) Validate() []error {
+	tags := make([]string, 0, len(fm.Files))
+	for tag := range fm.Files {
+		tags = append(tags, tag)
+	}
+	sort.Strings(tags)
+
+	var errs []error
+	for _, tag := range tags {
+		file := fm.Files[tag]
+		name := file.Path
+		if name == "" {
+			name = tag
+		}
+		if file.FileType(tag) != FileTypeYAML {
+			continue
+		}
+		if err := ValidateManifest(file.Content); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", name, err))
+		}
+	}
+	return errs
+}
@@ -0,0 +1,46 @@
+package filemap_test
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	. "github.com/redhat-et/copilot-ops/pkg/filemap"
+)
+
+var _ = Describe("Validate", func() {
+	It("accepts Kubernetes objects", func() {
+		Expect(ValidateManifest("apiVersion: v1\nkind: Pod\nmetadata:\n  name: web\n")).To(Succeed())
+		Expect(ValidateManifest("---\napiVersion: v1\nkind: Service\n---\napiVersion: apps/v1\nkind: Deployment\n---\n")).
+			To(Succeed())
+	})
+
+	It("rejects malformed YAML", func() {
+		Expect(ValidateManifest("apiVersion: v1\nkind: [Pod\n")).To(MatchError(HavePrefix("document 1 is not valid YAML")))
+		// prose before the YAML
+		Expect(ValidateManifest("Here is the Pod you asked for: apiVersion: v1\nkind: Pod\n")).
+			To(MatchError(HavePrefix("document 1 is not valid YAML")))
+		Expect(ValidateManifest("")).To(MatchError("no YAML documents found"))
+	})
+
+	It("rejects YAML which isn't a Kubernetes object", func() {
+		Expect(ValidateManifest("just some text")).To(MatchError("document 1 is not a YAML mapping"))
+		Expect(ValidateManifest("name: web\nimage: nginx\n")).
+			To(MatchError("document 1 is not a Kubernetes object, missing apiVersion and kind"))
+		// truncated after the first document
+		Expect(ValidateManifest("apiVersion: v1\nkind: Pod\n---\napiVersion: v1\n")).
+			To(MatchError("document 2 is not a Kubernetes object, missing kind"))
+	})
+
+	It("reports every invalid YAML file in the filemap", func() {
+		fm := NewFilemap()
+		fm.Files["pod"] = File{Path: "app/pod.yaml", Content: "apiVersion: v1\nkind: Pod\n"}
+		fm.Files["values"] = File{Path: "chart/values.yaml", Content: "replicas: 2\n"}
+		fm.Files["broken.yml"] = File{Content: "kind: [Pod\n"}
+		fm.Files["readme"] = File{Path: "README.md", Content: "# not YAML"}
+
+		errs := fm.Validate()
+		Expect(errs).To(HaveLen(2))
+		Expect(errs[0]).To(MatchError(HavePrefix("broken.yml: document 1 is not valid YAML")))
+		Expect(errs[1]).To(MatchError("chart/values.yaml: document 1 is not a Kubernetes object, missing apiVersion and kind"))
+	})
+})
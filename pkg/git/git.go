@@ -0,0 +1,120 @@
+// git Commits the files written by copilot-ops to a branch of the repository
+// they were written to.
+//
+// Commands are run with the git binary rather than go-git: go-git's dependencies
+// (ProtonMail/go-crypto in particular) require a newer Go than this module supports,
+// so git has to be installed and in the PATH.
+package git
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// MaxSubjectLength Is the longest subject line of a commit message
+// derived from the user's request.
+const MaxSubjectLength = 72
+
+// ErrNoChanges Is returned when none of the files to commit were changed.
+var ErrNoChanges = errors.New("no changes to commit")
+
+// ErrGitNotFound Is returned when the git binary can't be found in the PATH.
+var ErrGitNotFound = errors.New("git must be installed and in the PATH to commit files")
+
+// Repo Is a git working tree which commands are run in.
+type Repo struct {
+	// Dir Is a directory inside of the working tree.
+	Dir string
+}
+
+// Open Returns the repository containing the given directory,
+// or an error if the directory isn't part of a git working tree.
+func Open(dir string) (*Repo, error) {
+	if _, err := exec.LookPath("git"); err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrGitNotFound, err)
+	}
+	r := &Repo{Dir: dir}
+	if _, err := r.run("rev-parse", "--is-inside-work-tree"); err != nil {
+		return nil, fmt.Errorf("%s is not in a git repository: %w", dir, err)
+	}
+	return r, nil
+}
+
+// Checkout Switches to the given branch, creating it from the current commit
+// if it doesn't exist yet. Changes in the working tree are carried over.
+func (r *Repo) Checkout(branch string) error {
+	if _, err := r.run("rev-parse", "--verify", "--quiet", "refs/heads/"+branch); err != nil {
+		_, err = r.run("checkout", "-b", branch)
+		return err
+	}
+	_, err := r.run("checkout", branch)
+	return err
+}
+
+// CurrentBranch Returns the name of the branch which is checked out.
+func (r *Repo) CurrentBranch() (string, error) {
+	out, err := r.run("rev-parse", "--abbrev-ref", "HEAD")
+	return strings.TrimSpace(out), err
+}
+
+// CommitFiles Stages and commits only the given paths, leaving any other changes in
+// the working tree or the index untouched. ErrNoChanges is returned if none of the paths changed.
+func (r *Repo) CommitFiles(message string, paths []string) error {
+	if len(paths) == 0 {
+		return ErrNoChanges
+	}
+	args := append([]string{"add", "--"}, paths...)
+	if _, err := r.run(args...); err != nil {
+		return err
+	}
+	// the exit code is 1 when there are staged changes in the paths, any other failure is an error
+	args = append([]string{"diff", "--cached", "--quiet", "--"}, paths...)
+	_, err := r.run(args...)
+	if err == nil {
+		return ErrNoChanges
+	}
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) || exitErr.ExitCode() != 1 {
+		return err
+	}
+	args = append([]string{"commit", "--quiet", "--message", message, "--"}, paths...)
+	_, err = r.run(args...)
+	return err
+}
+
+// run Executes git with the given arguments in the repository,
+// returning its output, or an error including anything it printed to STDERR.
+func (r *Repo) run(args ...string) (string, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = r.Dir
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("git %s: %w: %s", args[0], err, msg)
+		}
+		return "", fmt.Errorf("git %s: %w", args[0], err)
+	}
+	return stdout.String(), nil
+}
+
+// CommitMessage Derives a commit message from the user's request, using its first line
+// as the subject (shortened to MaxSubjectLength) and the full request as the body.
+func CommitMessage(request string) string {
+	request = strings.TrimSpace(request)
+	subject := strings.TrimSpace(strings.SplitN(request, "\n", 2)[0])
+	if subject == "" {
+		subject = "Update files generated by copilot-ops"
+	}
+	if runes := []rune(subject); len(runes) > MaxSubjectLength {
+		subject = strings.TrimSpace(string(runes[:MaxSubjectLength-3])) + "..."
+	}
+	if request == "" {
+		return subject + "\n"
+	}
+	return fmt.Sprintf("%s\n\nGenerated by copilot-ops from the request:\n\n%s\n", subject, request)
+}
@@ -0,0 +1,101 @@
+package git_test
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/redhat-et/copilot-ops/pkg/git"
+)
+
+// gitOutput Runs git in the given directory and returns its output.
+func gitOutput(dir string, args ...string) string {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	out, err := cmd.CombinedOutput()
+	Expect(err).NotTo(HaveOccurred(), string(out))
+	return strings.TrimSpace(string(out))
+}
+
+// writeFile Writes the content to the file in the given directory.
+func writeFile(dir, name, content string) {
+	Expect(os.WriteFile(filepath.Join(dir, name), []byte(content), 0600)).To(Succeed())
+}
+
+var _ = Describe("Git", func() {
+	var dir string
+	var repo *git.Repo
+
+	BeforeEach(func() {
+		dir = GinkgoT().TempDir()
+		gitOutput(dir, "init", "--quiet")
+		gitOutput(dir, "config", "user.name", "Test")
+		gitOutput(dir, "config", "user.email", "test@example.com")
+		gitOutput(dir, "config", "commit.gpgsign", "false")
+		writeFile(dir, "README.md", "# repo\n")
+		gitOutput(dir, "add", "README.md")
+		gitOutput(dir, "commit", "--quiet", "-m", "Initial commit")
+
+		var err error
+		repo, err = git.Open(dir)
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("refuses directories outside of a repository", func() {
+		_, err := git.Open(GinkgoT().TempDir())
+		Expect(err).To(MatchError(ContainSubstring("is not in a git repository")))
+	})
+
+	It("reports when git isn't in the PATH", func() {
+		path := os.Getenv("PATH")
+		DeferCleanup(os.Setenv, "PATH", path)
+		Expect(os.Setenv("PATH", GinkgoT().TempDir())).To(Succeed())
+
+		_, err := git.Open(dir)
+		Expect(err).To(MatchError(git.ErrGitNotFound))
+	})
+
+	It("commits only the given files on a new branch", func() {
+		writeFile(dir, "pod.yaml", "kind: Pod\n")
+		writeFile(dir, "README.md", "# unrelated change\n")
+		writeFile(dir, "notes.txt", "unrelated\n")
+
+		Expect(repo.Checkout("copilot/pod")).To(Succeed())
+		Expect(repo.CurrentBranch()).To(Equal("copilot/pod"))
+		Expect(repo.CommitFiles("Create a pod\n", []string{"pod.yaml"})).To(Succeed())
+
+		Expect(gitOutput(dir, "log", "-1", "--format=%s")).To(Equal("Create a pod"))
+		Expect(gitOutput(dir, "show", "--name-only", "--format=")).To(Equal("pod.yaml"))
+		// the unrelated changes are left as they were
+		Expect(gitOutput(dir, "status", "--porcelain")).To(Equal("M README.md\n?? notes.txt"))
+	})
+
+	It("commits to an existing branch", func() {
+		gitOutput(dir, "branch", "generated")
+		writeFile(dir, "pod.yaml", "kind: Pod\n")
+		Expect(repo.Checkout("generated")).To(Succeed())
+		Expect(repo.CommitFiles("Create a pod\n", []string{"pod.yaml"})).To(Succeed())
+		Expect(gitOutput(dir, "rev-list", "--count", "generated")).To(Equal("2"))
+	})
+
+	It("reports when nothing changed", func() {
+		Expect(repo.CommitFiles("Nothing\n", []string{"README.md"})).To(MatchError(git.ErrNoChanges))
+	})
+
+	It("surfaces errors from git", func() {
+		err := repo.Checkout("not a valid branch")
+		Expect(err).To(MatchError(ContainSubstring("git checkout")))
+	})
+
+	It("derives the commit message from the request", func() {
+		Expect(git.CommitMessage("Create a pod\nrunning nginx")).
+			To(Equal("Create a pod\n\nGenerated by copilot-ops from the request:\n\nCreate a pod\nrunning nginx\n"))
+		subject := strings.SplitN(git.CommitMessage(strings.Repeat("a", 100)), "\n", 2)[0]
+		Expect(subject).To(HaveLen(git.MaxSubjectLength))
+		Expect(subject).To(HaveSuffix("..."))
+	})
+})
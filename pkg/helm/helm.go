@@ -0,0 +1,92 @@
+// helm Reads the Helm charts which values files are generated for.
+package helm
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+
+	"github.com/redhat-et/copilot-ops/pkg/filemap"
+)
+
+const (
+	// ChartFileName Is the name of the file which describes a chart.
+	ChartFileName = "Chart.yaml"
+	// ValuesFileName Is the name of the file holding a chart's default values,
+	// and of the values file which is generated.
+	ValuesFileName = "values.yaml"
+	// SchemaFileName Is the name of the optional JSON schema which a chart's values must follow.
+	SchemaFileName = "values.schema.json"
+)
+
+// Chart Is a Helm chart whose values are being overridden.
+type Chart struct {
+	// Path Is the directory of the chart.
+	Path string
+	// Name Is the name given in the chart's Chart.yaml.
+	Name string
+	// Values Is the content of the chart's values.yaml, which holds its defaults.
+	Values string
+	// Schema Is the content of the chart's values.schema.json, if it has one.
+	Schema string
+}
+
+// Load Reads the chart in the given directory. A Chart.yaml is required,
+// while the default values and their schema are only included when present.
+func Load(dir string) (*Chart, error) {
+	data, err := os.ReadFile(filepath.Join(dir, ChartFileName))
+	if err != nil {
+		return nil, fmt.Errorf("%s is not a Helm chart: %w", dir, err)
+	}
+	var metadata struct {
+		Name string `yaml:"name"`
+	}
+	if err = yaml.Unmarshal(data, &metadata); err != nil {
+		return nil, fmt.Errorf("could not parse %s: %w", filepath.Join(dir, ChartFileName), err)
+	}
+	chart := &Chart{Path: dir, Name: metadata.Name}
+	if chart.Name == "" {
+		chart.Name = filepath.Base(filepath.Clean(dir))
+	}
+	if chart.Values, err = readOptional(filepath.Join(dir, ValuesFileName)); err != nil {
+		return nil, err
+	}
+	if chart.Schema, err = readOptional(filepath.Join(dir, SchemaFileName)); err != nil {
+		return nil, err
+	}
+	return chart, nil
+}
+
+// EncodeDefaults Encodes the chart's default values and schema the same way as the files
+// in the prompt, so that they can be included alongside them.
+func (c *Chart) EncodeDefaults() string {
+	var parts []string
+	for _, file := range []struct{ name, content string }{
+		{ValuesFileName, c.Values},
+		{SchemaFileName, c.Schema},
+	} {
+		if strings.TrimSpace(file.content) == "" {
+			continue
+		}
+		path := filepath.ToSlash(filepath.Join(c.Path, file.name))
+		parts = append(parts, fmt.Sprintf("# %s%s\n%s\n", filemap.FileTagPrefix, path, strings.TrimRight(file.content, "\n")))
+	}
+	return strings.Join(parts, filemap.FileDelimeter+"\n")
+}
+
+// readOptional Returns the content of the file, or an empty string if it doesn't exist.
+func readOptional(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
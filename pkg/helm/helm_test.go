@@ -0,0 +1,47 @@
+package helm_test
+
+import (
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/redhat-et/copilot-ops/pkg/filemap"
+	"github.com/redhat-et/copilot-ops/pkg/helm"
+)
+
+var _ = Describe("Helm", func() {
+	var dir string
+
+	BeforeEach(func() {
+		dir = GinkgoT().TempDir()
+		Expect(os.WriteFile(filepath.Join(dir, helm.ChartFileName), []byte("apiVersion: v2\nname: guestbook\n"), 0600)).To(Succeed())
+		Expect(os.WriteFile(filepath.Join(dir, helm.ValuesFileName), []byte("replicaCount: 1\n"), 0600)).To(Succeed())
+	})
+
+	It("loads the name and defaults of a chart", func() {
+		chart, err := helm.Load(dir)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(chart.Name).To(Equal("guestbook"))
+		Expect(chart.Values).To(Equal("replicaCount: 1\n"))
+		Expect(chart.Schema).To(BeEmpty())
+
+		encoded := chart.EncodeDefaults()
+		Expect(encoded).To(Equal("# " + filemap.FileTagPrefix + filepath.ToSlash(filepath.Join(dir, helm.ValuesFileName)) +
+			"\nreplicaCount: 1\n"))
+	})
+
+	It("includes the schema of the values", func() {
+		Expect(os.WriteFile(filepath.Join(dir, helm.SchemaFileName), []byte(`{"type": "object"}`), 0600)).To(Succeed())
+		chart, err := helm.Load(dir)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(chart.EncodeDefaults()).To(ContainSubstring(filemap.FileDelimeter + "\n# " + filemap.FileTagPrefix))
+		Expect(chart.EncodeDefaults()).To(HaveSuffix(`{"type": "object"}` + "\n"))
+	})
+
+	It("refuses a directory without a Chart.yaml", func() {
+		_, err := helm.Load(GinkgoT().TempDir())
+		Expect(err).To(MatchError(ContainSubstring("is not a Helm chart")))
+	})
+})
@@ -0,0 +1,105 @@
+// history Keeps a log of every generation, so that it can be shown which changes were
+// generated, by which model and from which request.
+package history
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/redhat-et/copilot-ops/pkg/ai"
+	"github.com/redhat-et/copilot-ops/pkg/journal"
+)
+
+// FileName Is the name of the file which the history is kept in, with an entry per line.
+const FileName = "history.jsonl"
+
+// Entry Records a single generation.
+type Entry struct {
+	// Time Is when the generation finished.
+	Time time.Time `json:"time"`
+	// Command Is the command which was run, such as generate or edit.
+	Command string `json:"command"`
+	// Backend Is the backend which generated the files.
+	Backend ai.Backend `json:"backend"`
+	// Model Is the model of the backend, if it has a choice of models.
+	Model string `json:"model,omitempty"`
+	// Request Is the user's request.
+	Request string `json:"request"`
+	// Files Are the paths of the files which were generated.
+	Files []string `json:"files,omitempty"`
+	// Written Is set when the files were written to the repo, rather than printed.
+	Written bool `json:"written,omitempty"`
+}
+
+// Path Returns the path of the history in the given repo.
+func Path(root string) string {
+	return filepath.Join(root, journal.DirName, FileName)
+}
+
+// Append Adds the entry to the end of the history in the repo, creating it if needed.
+func Append(root string, entry Entry) error {
+	historyPath := Path(root)
+	if err := os.MkdirAll(filepath.Dir(historyPath), 0755); err != nil {
+		return err
+	}
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(historyPath, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err = f.Write(append(data, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Load Reads every entry of the history in the repo, oldest first.
+// An empty history is returned when nothing was recorded yet.
+func Load(root string) ([]Entry, error) {
+	historyPath := Path(root)
+	f, err := os.Open(historyPath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return []Entry{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	entries := []Entry{}
+	scanner := bufio.NewScanner(f)
+	// requests may be long, and are kept on a single line
+	scanner.Buffer(make([]byte, 0, bufio.MaxScanTokenSize), 16*1024*1024) //nolint:gomnd // 16MiB per entry
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var entry Entry
+		if err = json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return nil, fmt.Errorf("could not read line %d of %s: %w", line, historyPath, err)
+		}
+		entries = append(entries, entry)
+	}
+	if err = scanner.Err(); err != nil {
+		return nil, fmt.Errorf("could not read %s: %w", historyPath, err)
+	}
+	return entries, nil
+}
+
+// Recent Returns the last n entries, or every entry when n isn't positive.
+func Recent(entries []Entry, n int) []Entry {
+	if n <= 0 || n >= len(entries) {
+		return entries
+	}
+	return entries[len(entries)-n:]
+}
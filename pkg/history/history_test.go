@@ -0,0 +1,70 @@
+package history_test
+
+import (
+	"bytes"
+	"os"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/redhat-et/copilot-ops/pkg/ai"
+	"github.com/redhat-et/copilot-ops/pkg/history"
+)
+
+var _ = Describe("History", func() {
+	var dir string
+
+	BeforeEach(func() {
+		dir = GinkgoT().TempDir()
+	})
+
+	It("is empty when nothing was recorded", func() {
+		Expect(history.Load(dir)).To(BeEmpty())
+	})
+
+	It("appends an entry per generation, oldest first", func() {
+		first := history.Entry{
+			Time:    time.Date(2022, 7, 1, 12, 0, 0, 0, time.UTC),
+			Command: "generate",
+			Backend: ai.GPT3,
+			Model:   "gpt-4o",
+			Request: "Create a Service\nfor the deployment",
+			Files:   []string{"service.yaml"},
+			Written: true,
+		}
+		second := history.Entry{
+			Time:    time.Date(2022, 7, 1, 13, 0, 0, 0, time.UTC),
+			Command: "edit",
+			Backend: ai.OLLAMA,
+			Request: "Add a readiness probe",
+		}
+		Expect(history.Append(dir, first)).To(Succeed())
+		Expect(history.Append(dir, second)).To(Succeed())
+
+		Expect(history.Load(dir)).To(Equal([]history.Entry{first, second}))
+		data, err := os.ReadFile(history.Path(dir))
+		Expect(err).NotTo(HaveOccurred())
+		// every entry is kept on a single line
+		Expect(bytes.Count(data, []byte("\n"))).To(Equal(2))
+	})
+
+	It("reports the line which can't be read", func() {
+		Expect(history.Append(dir, history.Entry{Command: "generate"})).To(Succeed())
+		f, err := os.OpenFile(history.Path(dir), os.O_WRONLY|os.O_APPEND, 0)
+		Expect(err).NotTo(HaveOccurred())
+		_, err = f.WriteString("{not json\n")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(f.Close()).To(Succeed())
+
+		_, err = history.Load(dir)
+		Expect(err).To(MatchError(ContainSubstring("could not read line 2")))
+	})
+
+	It("returns the most recent entries", func() {
+		entries := []history.Entry{{Request: "a"}, {Request: "b"}, {Request: "c"}}
+		Expect(history.Recent(entries, 2)).To(Equal(entries[1:]))
+		Expect(history.Recent(entries, 5)).To(Equal(entries))
+		Expect(history.Recent(entries, 0)).To(Equal(entries))
+	})
+})
@@ -0,0 +1,190 @@
+// journal Records the files written by the last run, so that the run can be undone.
+package journal
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/redhat-et/copilot-ops/pkg/filemap"
+)
+
+const (
+	// DirName Is the name of the directory in the repo where copilot-ops keeps its state.
+	DirName = ".copilot-ops"
+	// FileName Is the name of the file which the last run is recorded in.
+	FileName = "last-run.json"
+)
+
+var (
+	// ErrNoRun Is returned when there is no recorded run to undo.
+	ErrNoRun = errors.New("no run has been recorded, nothing to undo")
+	// ErrModified Is returned when undoing a run whose files were modified since.
+	ErrModified = errors.New("files were modified since the last run")
+)
+
+// Entry Records a single file written by a run.
+type Entry struct {
+	// Path Is where the file was written, relative to the repo.
+	Path string `json:"path"`
+	// Created Is set when the file didn't exist before the run.
+	Created bool `json:"created,omitempty"`
+	// Previous Is the content of the file before the run.
+	Previous string `json:"previous,omitempty"`
+	// Mode Is the permissions of the file before the run.
+	Mode os.FileMode `json:"mode,omitempty"`
+	// Written Is the SHA-256 hash of the content written by the run,
+	// used to tell whether the file was modified since.
+	Written string `json:"written"`
+}
+
+// Journal Is the record of a run which wrote files.
+type Journal struct {
+	// CreatedAt Is when the files were written.
+	CreatedAt time.Time `json:"createdAt"`
+	// Request Is the user's request which produced the files.
+	Request string `json:"request"`
+	// Files Are the files which were written.
+	Files []Entry `json:"files"`
+}
+
+// Path Returns the path of the journal in the given repo.
+func Path(root string) string {
+	return filepath.Join(root, DirName, FileName)
+}
+
+// Record Reads the current content of every file in the filemap before it's overwritten.
+// It must be called before the files are written.
+func Record(fm *filemap.Filemap, request string) (*Journal, error) {
+	j := &Journal{
+		CreatedAt: time.Now().UTC(),
+		Request:   request,
+		Files:     make([]Entry, 0, len(fm.Files)),
+	}
+	for _, tag := range fm.TagsByPath() {
+		file := fm.Files[tag]
+		entry := Entry{Path: file.Path, Written: hash(file.Content)}
+		previous, err := os.ReadFile(file.Path)
+		switch {
+		case errors.Is(err, fs.ErrNotExist):
+			entry.Created = true
+		case err != nil:
+			return nil, fmt.Errorf("could not read %q before writing it: %w", file.Path, err)
+		default:
+			entry.Previous = string(previous)
+			info, err := os.Stat(file.Path)
+			if err != nil {
+				return nil, fmt.Errorf("could not read %q before writing it: %w", file.Path, err)
+			}
+			entry.Mode = info.Mode().Perm()
+		}
+		j.Files = append(j.Files, entry)
+	}
+	return j, nil
+}
+
+// Save Writes the journal to the repo, replacing the record of any previous run.
+func (j *Journal) Save(root string) error {
+	journalPath := Path(root)
+	if err := os.MkdirAll(filepath.Dir(journalPath), 0755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(j, "", "    ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(journalPath, data, 0600)
+}
+
+// Load Reads the journal of the last run in the repo, returning ErrNoRun if there is none.
+func Load(root string) (*Journal, error) {
+	data, err := os.ReadFile(Path(root))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, ErrNoRun
+	}
+	if err != nil {
+		return nil, err
+	}
+	j := &Journal{}
+	if err = json.Unmarshal(data, j); err != nil {
+		return nil, fmt.Errorf("could not read %s: %w", Path(root), err)
+	}
+	return j, nil
+}
+
+// Modified Returns the paths of the files which were changed or deleted since the run.
+func (j *Journal) Modified(root string) ([]string, error) {
+	var modified []string
+	for _, entry := range j.Files {
+		current, err := os.ReadFile(resolve(root, entry.Path))
+		if errors.Is(err, fs.ErrNotExist) {
+			modified = append(modified, entry.Path)
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if hash(string(current)) != entry.Written {
+			modified = append(modified, entry.Path)
+		}
+	}
+	return modified, nil
+}
+
+// Undo Restores the previous content of every file written by the run, and deletes the files it created.
+// Unless force is set, nothing is changed if any of the files were modified since the run.
+// The journal is removed once the run is undone, so that it can't be undone twice.
+func (j *Journal) Undo(root string, force bool) error {
+	if !force {
+		modified, err := j.Modified(root)
+		if err != nil {
+			return err
+		}
+		if len(modified) > 0 {
+			return fmt.Errorf("%w: %s", ErrModified, strings.Join(modified, ", "))
+		}
+	}
+	for _, entry := range j.Files {
+		name := resolve(root, entry.Path)
+		if entry.Created {
+			if err := os.Remove(name); err != nil && !errors.Is(err, fs.ErrNotExist) {
+				return fmt.Errorf("could not remove %q: %w", entry.Path, err)
+			}
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(name, []byte(entry.Previous), filemap.DefaultFileMode); err != nil {
+			return fmt.Errorf("could not restore %q: %w", entry.Path, err)
+		}
+		// the run may have changed the permissions of the file too
+		if entry.Mode != 0 {
+			if err := os.Chmod(name, entry.Mode); err != nil {
+				return fmt.Errorf("could not restore %q: %w", entry.Path, err)
+			}
+		}
+	}
+	return os.Remove(Path(root))
+}
+
+// resolve Returns the path of a recorded file, which is relative to the repo unless it's absolute.
+func resolve(root, name string) string {
+	if filepath.IsAbs(name) {
+		return name
+	}
+	return filepath.Join(root, name)
+}
+
+// hash Returns the SHA-256 hash of the content.
+func hash(content string) string {
+	sum := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(sum[:])
+}
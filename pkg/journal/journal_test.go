@@ -0,0 +1,82 @@
+package journal_test
+
+import (
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/redhat-et/copilot-ops/pkg/filemap"
+	"github.com/redhat-et/copilot-ops/pkg/journal"
+)
+
+var _ = Describe("Journal", func() {
+	var dir string
+	var fm *filemap.Filemap
+
+	BeforeEach(func() {
+		dir = GinkgoT().TempDir()
+		Expect(os.WriteFile(filepath.Join(dir, "pod.yaml"), []byte("kind: Pod\n"), 0600)).To(Succeed())
+		fm = filemap.NewFilemap()
+		fm.Files["pod"] = filemap.File{Path: filepath.Join(dir, "pod.yaml"), Content: "kind: Pod\nmetadata: {}\n"}
+		fm.Files["service"] = filemap.File{Path: filepath.Join(dir, "app", "service.yaml"), Content: "kind: Service\n"}
+	})
+
+	It("records the previous content of every file", func() {
+		j, err := journal.Record(fm, "create a service")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(j.Request).To(Equal("create a service"))
+		Expect(j.Files).To(HaveLen(2))
+		Expect(j.Files[0].Created).To(BeTrue())
+		Expect(j.Files[1].Previous).To(Equal("kind: Pod\n"))
+
+		Expect(j.Save(dir)).To(Succeed())
+		loaded, err := journal.Load(dir)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(loaded.Files).To(Equal(j.Files))
+	})
+
+	It("reports when no run was recorded", func() {
+		_, err := journal.Load(dir)
+		Expect(err).To(MatchError(journal.ErrNoRun))
+	})
+
+	When("the files were written", func() {
+		var j *journal.Journal
+		BeforeEach(func() {
+			var err error
+			j, err = journal.Record(fm, "create a service")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(fm.WriteUpdatesToFiles()).To(Succeed())
+			Expect(j.Save(dir)).To(Succeed())
+		})
+
+		It("restores the previous files", func() {
+			Expect(j.Modified(dir)).To(BeEmpty())
+			Expect(j.Undo(dir, false)).To(Succeed())
+			Expect(os.ReadFile(filepath.Join(dir, "pod.yaml"))).To(Equal([]byte("kind: Pod\n")))
+			Expect(filepath.Join(dir, "app", "service.yaml")).NotTo(BeAnExistingFile())
+			Expect(journal.Path(dir)).NotTo(BeAnExistingFile())
+		})
+
+		It("refuses to undo modified files unless forced", func() {
+			Expect(os.WriteFile(filepath.Join(dir, "pod.yaml"), []byte("kind: Pod\n# edited\n"), 0600)).To(Succeed())
+			Expect(j.Modified(dir)).To(Equal([]string{filepath.Join(dir, "pod.yaml")}))
+			Expect(j.Undo(dir, false)).To(MatchError(journal.ErrModified))
+			Expect(filepath.Join(dir, "app", "service.yaml")).To(BeAnExistingFile())
+
+			Expect(j.Undo(dir, true)).To(Succeed())
+			Expect(os.ReadFile(filepath.Join(dir, "pod.yaml"))).To(Equal([]byte("kind: Pod\n")))
+		})
+
+		It("restores the permissions of the previous files", func() {
+			Expect(j.Files[1].Mode).To(Equal(os.FileMode(0600)))
+			Expect(os.Chmod(filepath.Join(dir, "pod.yaml"), 0755)).To(Succeed())
+			Expect(j.Undo(dir, false)).To(Succeed())
+			info, err := os.Stat(filepath.Join(dir, "pod.yaml"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(info.Mode().Perm()).To(Equal(os.FileMode(0600)))
+		})
+	})
+})
@@ -0,0 +1,169 @@
+// kustomize Reads and updates the kustomization files which manage a repo's manifests,
+// so that generated files fit into the existing Kustomize structure.
+package kustomize
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// ResourcesKey Is the key of the kustomization which lists its resources.
+const ResourcesKey = "resources"
+
+// FileNames Returns the names which Kustomize looks for in a directory, in order of preference.
+func FileNames() []string {
+	return []string{"kustomization.yaml", "kustomization.yml", "Kustomization"}
+}
+
+// IsKustomization Reports whether the file at the path is a kustomization.
+func IsKustomization(path string) bool {
+	base := filepath.Base(path)
+	for _, name := range FileNames() {
+		if base == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Kustomization Is a kustomization file found in the repo.
+type Kustomization struct {
+	// Path Is where the kustomization file is.
+	Path string
+	// Content Is the content of the kustomization file.
+	Content string
+	// Resources Are the resources listed by the kustomization, relative to its directory.
+	Resources []string
+}
+
+// Dir Returns the directory of the kustomization, which its resources are relative to.
+func (k *Kustomization) Dir() string {
+	return filepath.Dir(k.Path)
+}
+
+// Parse Reads the resources listed by the kustomization at path with the given content.
+func Parse(path, content string) (*Kustomization, error) {
+	var fields struct {
+		Resources []string `yaml:"resources"`
+	}
+	if err := yaml.Unmarshal([]byte(content), &fields); err != nil {
+		return nil, fmt.Errorf("could not parse %s: %w", path, err)
+	}
+	return &Kustomization{Path: path, Content: content, Resources: fields.Resources}, nil
+}
+
+// Find Returns the nearest kustomization, looking in dir and then each of its parents up to root.
+// Nil is returned when there isn't one.
+func Find(dir, root string) (*Kustomization, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	if root, err = filepath.Abs(root); err != nil {
+		return nil, err
+	}
+	for {
+		for _, name := range FileNames() {
+			content, err := os.ReadFile(filepath.Join(dir, name))
+			if errors.Is(err, fs.ErrNotExist) {
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+			path, err := filepath.Rel(root, filepath.Join(dir, name))
+			if err != nil {
+				return nil, err
+			}
+			return Parse(path, string(content))
+		}
+		parent := filepath.Dir(dir)
+		if dir == root || parent == dir || !strings.HasPrefix(parent, root) {
+			return nil, nil //nolint:nilnil // having no kustomization isn't an error
+		}
+		dir = parent
+	}
+}
+
+// Resource Returns how the file at the path is listed as a resource of the kustomization.
+func (k *Kustomization) Resource(path string) (string, error) {
+	dir, err := filepath.Abs(k.Dir())
+	if err != nil {
+		return "", err
+	}
+	if path, err = filepath.Abs(path); err != nil {
+		return "", err
+	}
+	rel, err := filepath.Rel(dir, path)
+	if err != nil {
+		return "", err
+	}
+	return filepath.ToSlash(rel), nil
+}
+
+// AddResources Returns the content of the kustomization with the given resources appended
+// to its resources list, skipping the ones it already lists. The rest of the file,
+// including its comments, is left as it was.
+func (k *Kustomization) AddResources(resources []string) string {
+	listed := make(map[string]bool, len(k.Resources))
+	for _, resource := range k.Resources {
+		listed[resource] = true
+	}
+	var added []string
+	for _, resource := range resources {
+		if !listed[resource] {
+			listed[resource] = true
+			added = append(added, resource)
+		}
+	}
+	if len(added) == 0 {
+		return k.Content
+	}
+
+	lines := strings.Split(strings.TrimRight(k.Content, "\n"), "\n")
+	key := -1
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(line, ResourcesKey+":") && (trimmed == ResourcesKey+":" || trimmed == ResourcesKey+": []") {
+			key = i
+			lines[i] = ResourcesKey + ":"
+			break
+		}
+	}
+	if key < 0 {
+		if len(lines) == 1 && lines[0] == "" {
+			lines = nil
+		}
+		lines = append(lines, ResourcesKey+":")
+		key = len(lines) - 1
+	}
+
+	// append after the last item of the list, following its indentation
+	last, indent := key, ""
+	for i := key + 1; i < len(lines); i++ {
+		line := lines[i]
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		// the list ends at the next top-level key
+		if line[0] != ' ' && line[0] != '\t' && line[0] != '-' {
+			break
+		}
+		if strings.HasPrefix(trimmed, "-") {
+			last, indent = i, line[:len(line)-len(strings.TrimLeft(line, " \t"))]
+		}
+	}
+	items := make([]string, len(added))
+	for i, resource := range added {
+		items[i] = indent + "- " + resource
+	}
+	lines = append(lines[:last+1], append(items, lines[last+1:]...)...)
+	return strings.Join(lines, "\n") + "\n"
+}
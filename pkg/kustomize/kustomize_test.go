@@ -0,0 +1,69 @@
+package kustomize_test
+
+import (
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/redhat-et/copilot-ops/pkg/kustomize"
+)
+
+var _ = Describe("Kustomize", func() {
+	When("finding a kustomization", func() {
+		var root string
+
+		BeforeEach(func() {
+			root = GinkgoT().TempDir()
+			Expect(os.MkdirAll(filepath.Join(root, "overlays", "prod", "patches"), 0755)).To(Succeed())
+			Expect(os.WriteFile(
+				filepath.Join(root, "overlays", "prod", "kustomization.yaml"),
+				[]byte("resources:\n- ../../base\n- service.yaml\n"), 0600,
+			)).To(Succeed())
+		})
+
+		It("looks in the parents of the directory", func() {
+			k, err := kustomize.Find(filepath.Join(root, "overlays", "prod", "patches"), root)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(k).NotTo(BeNil())
+			Expect(k.Path).To(Equal(filepath.Join("overlays", "prod", "kustomization.yaml")))
+			Expect(k.Resources).To(Equal([]string{"../../base", "service.yaml"}))
+		})
+
+		It("doesn't look above the root", func() {
+			Expect(os.WriteFile(filepath.Join(root, "kustomization.yaml"), []byte("resources: []\n"), 0600)).To(Succeed())
+			k, err := kustomize.Find(filepath.Join(root, "overlays"), filepath.Join(root, "overlays"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(k).To(BeNil())
+		})
+	})
+
+	When("adding resources", func() {
+		It("follows the indentation of the list and keeps comments", func() {
+			k, err := kustomize.Parse("kustomization.yaml",
+				"# the production overlay\nresources:\n  - deployment.yaml # the app\nnamespace: prod\n")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(k.AddResources([]string{"service.yaml", "deployment.yaml"})).To(Equal(
+				"# the production overlay\nresources:\n  - deployment.yaml # the app\n  - service.yaml\nnamespace: prod\n",
+			))
+		})
+
+		It("adds the list when it's missing or empty", func() {
+			k, err := kustomize.Parse("kustomization.yaml", "namespace: prod\n")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(k.AddResources([]string{"service.yaml"})).To(Equal("namespace: prod\nresources:\n- service.yaml\n"))
+
+			k, err = kustomize.Parse("kustomization.yaml", "resources: []\nnamespace: prod\n")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(k.AddResources([]string{"service.yaml"})).To(Equal("resources:\n- service.yaml\nnamespace: prod\n"))
+		})
+
+		It("leaves the kustomization alone when every resource is listed", func() {
+			content := "resources:\n- service.yaml\n"
+			k, err := kustomize.Parse("kustomization.yaml", content)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(k.AddResources([]string{"service.yaml"})).To(Equal(content))
+		})
+	})
+})
@@ -0,0 +1,99 @@
+// logger Writes leveled log messages to stderr, so that they never mix with the output of a command.
+package logger
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"sync/atomic"
+)
+
+// Level Is the severity of a log message. Messages below the configured level are discarded.
+type Level int32
+
+const (
+	// LevelDebug Is used for details which only help when troubleshooting, such as the flags in use.
+	LevelDebug Level = iota
+	// LevelInfo Is used for the progress of a command.
+	LevelInfo
+	// LevelWarn Is used when something went wrong, but the command carries on.
+	LevelWarn
+	// LevelError Is used when the command fails.
+	LevelError
+)
+
+// levelNames Returns the name of each level, as accepted by ParseLevel.
+func levelNames() []string {
+	return []string{"debug", "info", "warn", "error"}
+}
+
+// String Returns the name of the level.
+func (l Level) String() string {
+	names := levelNames()
+	if l < LevelDebug || int(l) >= len(names) {
+		return fmt.Sprintf("level(%d)", int32(l))
+	}
+	return names[l]
+}
+
+// ParseLevel Returns the level with the given name.
+func ParseLevel(name string) (Level, error) {
+	for i, levelName := range levelNames() {
+		if strings.EqualFold(name, levelName) {
+			return Level(i), nil
+		}
+	}
+	return LevelInfo, fmt.Errorf("invalid log level %q, must be one of: %s", name, strings.Join(levelNames(), ", "))
+}
+
+//nolint:gochecknoglobals // the logger is shared by every package, like the standard library's
+var (
+	level  = int32(LevelInfo)
+	output = log.New(os.Stderr, "", log.LstdFlags)
+)
+
+// SetLevel Discards every message below the given level.
+func SetLevel(l Level) {
+	atomic.StoreInt32(&level, int32(l))
+}
+
+// GetLevel Returns the level below which messages are discarded.
+func GetLevel() Level {
+	return Level(atomic.LoadInt32(&level))
+}
+
+// SetOutput Writes the log messages to w instead of stderr.
+func SetOutput(w io.Writer) {
+	output.SetOutput(w)
+}
+
+// Debugf Logs details which only help when troubleshooting.
+func Debugf(format string, args ...interface{}) {
+	logf(LevelDebug, "", format, args...)
+}
+
+// Infof Logs the progress of a command.
+func Infof(format string, args ...interface{}) {
+	logf(LevelInfo, "", format, args...)
+}
+
+// Warnf Logs a problem which the command carries on from.
+func Warnf(format string, args ...interface{}) {
+	logf(LevelWarn, "warning: ", format, args...)
+}
+
+// Errorf Logs a problem which the command can't carry on from.
+func Errorf(format string, args ...interface{}) {
+	logf(LevelError, "error: ", format, args...)
+}
+
+// logf Writes the message when its level isn't discarded.
+func logf(l Level, prefix, format string, args ...interface{}) {
+	if l < GetLevel() {
+		return
+	}
+	// log.Logger adds the missing newline
+	_ = output.Output(3, prefix+fmt.Sprintf(format, args...)) //nolint:gomnd // the caller of the exported function
+}
@@ -0,0 +1,43 @@
+package logger_test
+
+import (
+	"bytes"
+	"os"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/redhat-et/copilot-ops/pkg/logger"
+)
+
+var _ = Describe("Logger", func() {
+	var out *bytes.Buffer
+
+	BeforeEach(func() {
+		out = &bytes.Buffer{}
+		logger.SetOutput(out)
+		DeferCleanup(logger.SetOutput, os.Stderr)
+		DeferCleanup(logger.SetLevel, logger.GetLevel())
+	})
+
+	It("parses the name of each level", func() {
+		for _, level := range []logger.Level{logger.LevelDebug, logger.LevelInfo, logger.LevelWarn, logger.LevelError} {
+			Expect(logger.ParseLevel(level.String())).To(Equal(level))
+		}
+		Expect(logger.ParseLevel("WARN")).To(Equal(logger.LevelWarn))
+		_, err := logger.ParseLevel("verbose")
+		Expect(err).To(MatchError(ContainSubstring("debug, info, warn, error")))
+	})
+
+	It("discards messages below the level", func() {
+		logger.SetLevel(logger.LevelWarn)
+		logger.Debugf("debug")
+		logger.Infof("info")
+		Expect(out.String()).To(BeEmpty())
+
+		logger.Warnf("the cache is full")
+		logger.Errorf("the backend is down")
+		Expect(out.String()).To(ContainSubstring("warning: the cache is full\n"))
+		Expect(out.String()).To(ContainSubstring("error: the backend is down\n"))
+	})
+})
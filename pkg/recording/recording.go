@@ -0,0 +1,198 @@
+// recording Captures the HTTP interactions with AI backends so that they can
+// be replayed later without network access.
+package recording
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+
+	"github.com/redhat-et/copilot-ops/pkg/logger"
+)
+
+// Request Is the recorded part of an HTTP request. Headers are not recorded,
+// so that API keys never end up in a recording.
+type Request struct {
+	Method string `json:"method"`
+	URL    string `json:"url"`
+	Body   string `json:"body"`
+}
+
+// Response Is the recorded part of an HTTP response.
+type Response struct {
+	StatusCode int         `json:"statusCode"`
+	Header     http.Header `json:"header,omitempty"`
+	Body       string      `json:"body"`
+}
+
+// Interaction Is a single request made to a backend, and the response it received.
+type Interaction struct {
+	Request  Request  `json:"request"`
+	Response Response `json:"response"`
+}
+
+// Recording Is the file format of a recording.
+type Recording struct {
+	Interactions []Interaction `json:"interactions"`
+}
+
+// Recorder Is an http.RoundTripper which records every interaction to a file.
+type Recorder struct {
+	// Transport Is used to make the requests, defaulting to http.DefaultTransport.
+	Transport http.RoundTripper
+	path      string
+	mu        sync.Mutex
+	recording Recording
+}
+
+// NewRecorder Returns a Recorder which writes its recording to the given path.
+func NewRecorder(path string, transport http.RoundTripper) *Recorder {
+	return &Recorder{
+		Transport: transport,
+		path:      path,
+	}
+}
+
+// RoundTrip Makes the request and records it along with its response.
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	transport := r.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	reqBody, err := readBody(&req.Body)
+	if err != nil {
+		return nil, err
+	}
+	res, err := transport.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	resBody, err := readBody(&res.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.recording.Interactions = append(r.recording.Interactions, Interaction{
+		Request: Request{
+			Method: req.Method,
+			URL:    req.URL.RequestURI(),
+			Body:   reqBody,
+		},
+		Response: Response{
+			StatusCode: res.StatusCode,
+			Header:     res.Header,
+			Body:       resBody,
+		},
+	})
+	// write after every interaction, so that failed runs are still recorded
+	if err = writeRecording(r.path, r.recording); err != nil {
+		return nil, fmt.Errorf("could not write recording: %w", err)
+	}
+	logger.Debugf("recorded %s %s to %q\n", req.Method, req.URL.RequestURI(), r.path)
+	return res, nil
+}
+
+// Replayer Is an http.RoundTripper which serves responses from a recording
+// instead of making requests.
+type Replayer struct {
+	mu        sync.Mutex
+	recording Recording
+	used      []bool
+}
+
+// NewReplayer Loads the recording at the given path.
+func NewReplayer(path string) (*Replayer, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not read recording: %w", err)
+	}
+	var rec Recording
+	if err = json.Unmarshal(data, &rec); err != nil {
+		return nil, fmt.Errorf("could not parse recording %q: %w", path, err)
+	}
+	return &Replayer{
+		recording: rec,
+		used:      make([]bool, len(rec.Interactions)),
+	}, nil
+}
+
+// RoundTrip Returns the recorded response for the request. Interactions are matched
+// by their method, URL, and body, falling back to the next unused interaction with
+// the same method and URL.
+func (r *Replayer) RoundTrip(req *http.Request) (*http.Response, error) {
+	reqBody, err := readBody(&req.Body)
+	if err != nil {
+		return nil, err
+	}
+	uri := req.URL.RequestURI()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	match := -1
+	for i, interaction := range r.recording.Interactions {
+		if r.used[i] || interaction.Request.Method != req.Method || interaction.Request.URL != uri {
+			continue
+		}
+		if interaction.Request.Body == reqBody {
+			match = i
+			break
+		}
+		if match < 0 {
+			match = i
+		}
+	}
+	if match < 0 {
+		return nil, fmt.Errorf("no recorded response for %s %s", req.Method, uri)
+	}
+	interaction := r.recording.Interactions[match]
+	if interaction.Request.Body != reqBody {
+		logger.Warnf("replaying a response for %s %s which was recorded with a different request body\n",
+			req.Method, uri)
+	}
+	r.used[match] = true
+	header := interaction.Response.Header.Clone()
+	if header == nil {
+		header = make(http.Header)
+	}
+
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", interaction.Response.StatusCode, http.StatusText(interaction.Response.StatusCode)),
+		StatusCode:    interaction.Response.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewBufferString(interaction.Response.Body)),
+		ContentLength: int64(len(interaction.Response.Body)),
+		Request:       req,
+	}, nil
+}
+
+// readBody Reads the body fully and replaces it so that it can be read again.
+func readBody(body *io.ReadCloser) (string, error) {
+	if *body == nil || *body == http.NoBody {
+		return "", nil
+	}
+	data, err := io.ReadAll(*body)
+	if err != nil {
+		return "", err
+	}
+	(*body).Close()
+	*body = io.NopCloser(bytes.NewReader(data))
+	return string(data), nil
+}
+
+// writeRecording Writes the recording to the given path as JSON.
+func writeRecording(path string, rec Recording) error {
+	data, err := json.MarshalIndent(rec, "", "    ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0600)
+}
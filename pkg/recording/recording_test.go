@@ -0,0 +1,87 @@
+package recording_test
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/redhat-et/copilot-ops/pkg/recording"
+)
+
+// post Sends a POST request with the given body and returns the response body.
+func post(client *http.Client, url, body string) (string, error) {
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodPost, url, strings.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Authorization", "Bearer secret-token")
+	res, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+	data, err := io.ReadAll(res.Body)
+	return string(data), err
+}
+
+var _ = Describe("Recording", func() {
+	var ts *httptest.Server
+	var recordingPath string
+
+	BeforeEach(func() {
+		ts = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, _ := io.ReadAll(r.Body)
+			fmt.Fprintf(w, "echo: %s", body)
+		}))
+		DeferCleanup(ts.Close)
+
+		dir, err := os.MkdirTemp("", "copilot-ops-recording")
+		Expect(err).NotTo(HaveOccurred())
+		DeferCleanup(os.RemoveAll, dir)
+		recordingPath = filepath.Join(dir, "recording.json")
+	})
+
+	It("replays what was recorded", func() {
+		recorder := &http.Client{Transport: recording.NewRecorder(recordingPath, nil)}
+		body, err := post(recorder, ts.URL+"/v1/completions", "first")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(body).To(Equal("echo: first"))
+		_, err = post(recorder, ts.URL+"/v1/completions", "second")
+		Expect(err).NotTo(HaveOccurred())
+
+		// credentials are never recorded
+		data, err := os.ReadFile(recordingPath)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(data)).NotTo(ContainSubstring("secret-token"))
+
+		// the server is no longer needed
+		ts.Close()
+		replayer, err := recording.NewReplayer(recordingPath)
+		Expect(err).NotTo(HaveOccurred())
+		client := &http.Client{Transport: replayer}
+
+		body, err = post(client, "http://unreachable.invalid/v1/completions", "second")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(body).To(Equal("echo: second"))
+		// unmatched bodies fall back to the next interaction for the URL
+		body, err = post(client, "http://unreachable.invalid/v1/completions", "changed")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(body).To(Equal("echo: first"))
+		// every interaction has been used up
+		_, err = post(client, "http://unreachable.invalid/v1/completions", "first")
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("fails to replay a missing recording", func() {
+		_, err := recording.NewReplayer(recordingPath)
+		Expect(err).To(HaveOccurred())
+	})
+})
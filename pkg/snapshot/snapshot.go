@@ -0,0 +1,124 @@
+// snapshot Stores the results of previous generations so that they can be
+// reused as context for follow-up requests.
+package snapshot
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"time"
+
+	"github.com/redhat-et/copilot-ops/pkg/ai"
+	"github.com/redhat-et/copilot-ops/pkg/cmd/config"
+	"github.com/redhat-et/copilot-ops/pkg/filemap"
+)
+
+// DirName Is the name of the directory under the cache directory where snapshots are kept.
+const DirName = "snapshots"
+
+// Snapshot Records the output of a generation along with what produced it.
+type Snapshot struct {
+	// Name Identifies the snapshot.
+	Name string `json:"name"`
+	// Backend Is the AI backend which produced the files.
+	Backend ai.Backend `json:"backend"`
+	// Request Is the user's request which produced the files.
+	Request string `json:"request"`
+	// CreatedAt Is when the snapshot was saved.
+	CreatedAt time.Time `json:"createdAt"`
+	// Files Are the files which were output by the generation.
+	Files []filemap.File `json:"files"`
+}
+
+// New Creates a snapshot of the files in the given filemap.
+func New(name string, backend ai.Backend, request string, fm *filemap.Filemap) *Snapshot {
+	s := &Snapshot{
+		Name:      name,
+		Backend:   backend,
+		Request:   request,
+		CreatedAt: time.Now().UTC(),
+		Files:     make([]filemap.File, 0, len(fm.Files)),
+	}
+	tags := make([]string, 0, len(fm.Files))
+	for tag := range fm.Files {
+		tags = append(tags, tag)
+	}
+	sort.Strings(tags)
+	for _, tag := range tags {
+		file := fm.Files[tag]
+		if file.Path == "" {
+			file.Path = tag
+		}
+		s.Files = append(s.Files, file)
+	}
+	return s
+}
+
+// Dir Returns the directory where snapshots are stored.
+func Dir() (string, error) {
+	cacheDir, err := config.CacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(cacheDir, DirName), nil
+}
+
+// Save Writes the snapshot to the snapshot directory, replacing any snapshot with the same name.
+func (s *Snapshot) Save() error {
+	snapshotPath, err := pathFor(s.Name)
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(filepath.Dir(snapshotPath), 0755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(s, "", "    ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(snapshotPath, data, 0600)
+}
+
+// Load Reads the snapshot with the given name from the snapshot directory.
+func Load(name string) (*Snapshot, error) {
+	snapshotPath, err := pathFor(name)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(snapshotPath)
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("snapshot %q does not exist", name)
+	} else if err != nil {
+		return nil, err
+	}
+	s := &Snapshot{}
+	if err = json.Unmarshal(data, s); err != nil {
+		return nil, fmt.Errorf("could not read snapshot %q: %w", name, err)
+	}
+	return s, nil
+}
+
+// Filemap Returns a filemap containing the files from the snapshot, tagged by their paths.
+func (s *Snapshot) Filemap() *filemap.Filemap {
+	fm := filemap.NewFilemap()
+	for _, file := range s.Files {
+		fm.Files[file.Path] = file
+	}
+	return fm
+}
+
+// pathFor Returns the path of the file which stores the named snapshot.
+func pathFor(name string) (string, error) {
+	validName := regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+	if !validName.MatchString(name) {
+		return "", fmt.Errorf("invalid snapshot name %q, only letters, numbers, '.', '_' and '-' are allowed", name)
+	}
+	dir, err := Dir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, name+".json"), nil
+}
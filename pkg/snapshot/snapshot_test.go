@@ -0,0 +1,51 @@
+package snapshot_test
+
+import (
+	"os"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/redhat-et/copilot-ops/pkg/ai"
+	"github.com/redhat-et/copilot-ops/pkg/cmd/config"
+	"github.com/redhat-et/copilot-ops/pkg/filemap"
+	"github.com/redhat-et/copilot-ops/pkg/snapshot"
+)
+
+var _ = Describe("Snapshot", func() {
+	BeforeEach(func() {
+		dir, err := os.MkdirTemp("", "copilot-ops-snapshots")
+		Expect(err).NotTo(HaveOccurred())
+		DeferCleanup(os.RemoveAll, dir)
+		DeferCleanup(os.Setenv, config.CacheDirEnv, os.Getenv(config.CacheDirEnv))
+		Expect(os.Setenv(config.CacheDirEnv, dir)).To(Succeed())
+	})
+
+	It("saves and loads a generation", func() {
+		fm := filemap.NewFilemap()
+		fm.Files["pod.yaml"] = filemap.File{Path: "app/pod.yaml", Content: "kind: Pod\n"}
+		fm.Files["service.yaml"] = filemap.File{Content: "kind: Service\n"}
+
+		s := snapshot.New("my-app", ai.GPT3, "create a pod", fm)
+		Expect(s.Save()).To(Succeed())
+
+		loaded, err := snapshot.Load("my-app")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(loaded.Backend).To(Equal(ai.GPT3))
+		Expect(loaded.Request).To(Equal("create a pod"))
+		Expect(loaded.Files).To(HaveLen(2))
+		Expect(loaded.Filemap().Files).To(HaveKey("app/pod.yaml"))
+		// files without a path fall back to their tag
+		Expect(loaded.Filemap().Files).To(HaveKey("service.yaml"))
+	})
+
+	It("fails on missing snapshots", func() {
+		_, err := snapshot.Load("does-not-exist")
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("rejects names which could escape the snapshot directory", func() {
+		_, err := snapshot.Load("../config")
+		Expect(err).To(HaveOccurred())
+	})
+})
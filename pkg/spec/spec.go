@@ -0,0 +1,133 @@
+// spec Defines the structured specification files which describe several
+// resources that should be generated in a single pass.
+package spec
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// SectionHeadingPrefix Is the markdown heading which begins a new section
+// in a spec file. The text following the prefix is the output path.
+const SectionHeadingPrefix = "## "
+
+// Section Describes a single resource which should be generated from the spec.
+type Section struct {
+	// Path Is the path of the file which the resource should be written to.
+	Path string `json:"path" yaml:"path"`
+	// Description Is the natural-language description of the resource.
+	Description string `json:"description" yaml:"description"`
+}
+
+// Spec Represents a natural-language spec file, broken up into one
+// section per resource that should be generated.
+type Spec struct {
+	// Summary Is optional text which applies to all of the sections.
+	Summary string `json:"summary,omitempty" yaml:"summary,omitempty"`
+	// Sections Contains the resources to be generated, in the order they were written.
+	Sections []Section `json:"sections" yaml:"sections"`
+}
+
+// Load Reads the spec file at the given path. Files with a YAML extension are
+// parsed as YAML, anything else is treated as markdown.
+func Load(path string) (*Spec, error) {
+	bytes, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		return ParseYAML(bytes)
+	default:
+		return ParseMarkdown(string(bytes))
+	}
+}
+
+// ParseYAML Parses a spec from YAML in the following format:
+//
+//	summary: optional text describing the application
+//	sections:
+//	  - path: app/deployment.yaml
+//	    description: a deployment running nginx
+func ParseYAML(data []byte) (*Spec, error) {
+	s := &Spec{}
+	if err := yaml.UnmarshalStrict(data, s); err != nil {
+		return nil, fmt.Errorf("could not parse spec: %w", err)
+	}
+	s.Summary = strings.TrimSpace(s.Summary)
+	for i := range s.Sections {
+		s.Sections[i].Path = strings.TrimSpace(s.Sections[i].Path)
+		s.Sections[i].Description = strings.TrimSpace(s.Sections[i].Description)
+	}
+	if err := s.Validate(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// ParseMarkdown Parses a spec from markdown, where each '## <path>' heading
+// begins the section for the file at <path>. Any text before the first
+// section heading is used as the summary.
+func ParseMarkdown(content string) (*Spec, error) {
+	s := &Spec{}
+	var summary []string
+	var current *Section
+	var body []string
+
+	// closeSection stores the section currently being read
+	closeSection := func() {
+		if current != nil {
+			current.Description = strings.TrimSpace(strings.Join(body, "\n"))
+			s.Sections = append(s.Sections, *current)
+		}
+		body = nil
+	}
+
+	for _, line := range strings.Split(content, "\n") {
+		if strings.HasPrefix(line, SectionHeadingPrefix) {
+			closeSection()
+			current = &Section{
+				Path: strings.TrimSpace(strings.TrimPrefix(line, SectionHeadingPrefix)),
+			}
+			continue
+		}
+		if current == nil {
+			summary = append(summary, line)
+		} else {
+			body = append(body, line)
+		}
+	}
+	closeSection()
+
+	s.Summary = strings.TrimSpace(strings.Join(summary, "\n"))
+	if err := s.Validate(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Validate Ensures that the spec contains at least one section, and that
+// every section has a unique path and a description.
+func (s *Spec) Validate() error {
+	if len(s.Sections) == 0 {
+		return fmt.Errorf("spec does not contain any sections")
+	}
+	seen := make(map[string]bool, len(s.Sections))
+	for i, section := range s.Sections {
+		if section.Path == "" {
+			return fmt.Errorf("section %d of the spec has no path", i+1)
+		}
+		if section.Description == "" {
+			return fmt.Errorf("section %q of the spec has no description", section.Path)
+		}
+		if seen[section.Path] {
+			return fmt.Errorf("section %q is defined more than once in the spec", section.Path)
+		}
+		seen[section.Path] = true
+	}
+	return nil
+}
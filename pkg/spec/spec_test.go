@@ -0,0 +1,62 @@
+package spec_test
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/redhat-et/copilot-ops/pkg/spec"
+)
+
+var _ = Describe("Spec", func() {
+	When("a markdown spec is parsed", func() {
+		It("creates one section per heading", func() {
+			s, err := spec.ParseMarkdown(`# My application
+An nginx web server.
+
+## app/deployment.yaml
+A Deployment running nginx with 3 replicas.
+
+## app/service.yaml
+A Service exposing the deployment on port 80.
+`)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(s.Summary).To(Equal("# My application\nAn nginx web server."))
+			Expect(s.Sections).To(HaveLen(2))
+			Expect(s.Sections[0].Path).To(Equal("app/deployment.yaml"))
+			Expect(s.Sections[0].Description).To(Equal("A Deployment running nginx with 3 replicas."))
+			Expect(s.Sections[1].Path).To(Equal("app/service.yaml"))
+		})
+
+		It("fails without any sections", func() {
+			_, err := spec.ParseMarkdown("just some text")
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("fails on empty or duplicate sections", func() {
+			_, err := spec.ParseMarkdown("## a.yaml\n\n## b.yaml\nsomething")
+			Expect(err).To(HaveOccurred())
+			_, err = spec.ParseMarkdown("## a.yaml\nsomething\n## a.yaml\nsomething else")
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	When("a YAML spec is parsed", func() {
+		It("reads the sections", func() {
+			s, err := spec.ParseYAML([]byte(`
+summary: An nginx web server.
+sections:
+  - path: app/deployment.yaml
+    description: A Deployment running nginx.
+`))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(s.Summary).To(Equal("An nginx web server."))
+			Expect(s.Sections).To(HaveLen(1))
+			Expect(s.Sections[0].Path).To(Equal("app/deployment.yaml"))
+		})
+
+		It("rejects unknown fields", func() {
+			_, err := spec.ParseYAML([]byte("sections:\n  - file: a.yaml\n"))
+			Expect(err).To(HaveOccurred())
+		})
+	})
+})
@@ -0,0 +1,180 @@
+package tokenizer
+
+import (
+	"bufio"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// BPE Counts tokens precisely using the byte-pair encoding ranks of an OpenAI model,
+// as published in tiktoken's '.tiktoken' files.
+type BPE struct {
+	encoding string
+	ranks    map[string]int
+	pattern  *regexp.Regexp
+	// splitNewlineRuns Is set for encodings which keep runs of whitespace
+	// ending in a newline together.
+	splitNewlineRuns bool
+}
+
+// LoadBPE Reads the BPE ranks for the given encoding from a '.tiktoken' file.
+func LoadBPE(encoding, ranksFile string) (*BPE, error) {
+	f, err := os.Open(ranksFile)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return NewBPE(encoding, f)
+}
+
+// NewBPE Creates a BPE tokenizer for the given encoding, reading its ranks from r.
+// Each line of r holds a base64-encoded token followed by its rank.
+func NewBPE(encoding string, r io.Reader) (*BPE, error) {
+	pattern, splitNewlineRuns, err := pretokenizePattern(encoding)
+	if err != nil {
+		return nil, err
+	}
+
+	ranks := make(map[string]int)
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		fields := strings.Fields(line)
+		//nolint:gomnd // each line is a token and its rank
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("invalid line in %s ranks: %q", encoding, line)
+		}
+		token, err := base64.StdEncoding.DecodeString(fields[0])
+		if err != nil {
+			return nil, fmt.Errorf("invalid token in %s ranks: %w", encoding, err)
+		}
+		rank, err := strconv.Atoi(fields[1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid rank in %s ranks: %w", encoding, err)
+		}
+		ranks[string(token)] = rank
+	}
+	if err = scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(ranks) == 0 {
+		return nil, fmt.Errorf("no ranks found for %s", encoding)
+	}
+
+	return &BPE{
+		encoding:         encoding,
+		ranks:            ranks,
+		pattern:          pattern,
+		splitNewlineRuns: splitNewlineRuns,
+	}, nil
+}
+
+// Name Describes the encoding used by the tokenizer.
+func (t *BPE) Name() string {
+	return t.encoding + " tokenizer"
+}
+
+// CountTokens Returns the number of tokens the model would encode the text as.
+func (t *BPE) CountTokens(text string) int {
+	count := 0
+	for _, piece := range t.pretokenize(text) {
+		if _, ok := t.ranks[piece]; ok {
+			count++
+			continue
+		}
+		count += t.mergeCount(piece)
+	}
+	return count
+}
+
+// pretokenize Splits the text into the pieces which are encoded separately.
+// Go's regexp has no lookahead, so the '\s+(?!\S)' rule is emulated by leaving
+// the last whitespace character of a run for the following piece.
+func (t *BPE) pretokenize(text string) []string {
+	var pieces []string
+	for len(text) > 0 {
+		loc := t.pattern.FindStringIndex(text)
+		if loc == nil {
+			pieces = append(pieces, text)
+			break
+		}
+		end := loc[1]
+		match := text[:end]
+		if end < len(text) && strings.TrimSpace(match) == "" && !unicode.IsSpace(nextRune(text[end:])) {
+			endsInNewline := strings.HasSuffix(match, "\n") || strings.HasSuffix(match, "\r")
+			if utf8.RuneCountInString(match) > 1 && !(t.splitNewlineRuns && endsInNewline) {
+				// leave the last whitespace character for the next piece
+				_, size := utf8.DecodeLastRuneInString(match)
+				end -= size
+			}
+		}
+		pieces = append(pieces, text[:end])
+		text = text[end:]
+	}
+	return pieces
+}
+
+// mergeCount Applies the byte-pair merges to the piece and returns how many tokens remain.
+func (t *BPE) mergeCount(piece string) int {
+	parts := make([]string, len(piece))
+	for i := 0; i < len(piece); i++ {
+		parts[i] = piece[i : i+1]
+	}
+	for len(parts) > 1 {
+		best, bestRank := -1, 0
+		for i := 0; i < len(parts)-1; i++ {
+			rank, ok := t.ranks[parts[i]+parts[i+1]]
+			if ok && (best < 0 || rank < bestRank) {
+				best, bestRank = i, rank
+			}
+		}
+		if best < 0 {
+			break
+		}
+		parts[best] += parts[best+1]
+		parts = append(parts[:best+1], parts[best+2:]...)
+	}
+	return len(parts)
+}
+
+// pretokenizePattern Returns the pattern used to split text for the given encoding,
+// with the lookaheads of the original patterns removed.
+func pretokenizePattern(encoding string) (*regexp.Regexp, bool, error) {
+	var pattern string
+	var splitNewlineRuns bool
+	switch encoding {
+	case EncodingR50K, EncodingP50K:
+		pattern = `'s|'t|'re|'ve|'m|'ll|'d| ?\p{L}+| ?\p{N}+| ?[^\s\p{L}\p{N}]+|\s+`
+	case EncodingCL100K:
+		pattern = `(?i:'s|'t|'re|'ve|'m|'ll|'d)|[^\r\n\p{L}\p{N}]?\p{L}+|\p{N}{1,3}| ?[^\s\p{L}\p{N}]+[\r\n]*|` +
+			`\s*[\r\n]+|\s+`
+		splitNewlineRuns = true
+	case EncodingO200K:
+		pattern = `[^\r\n\p{L}\p{N}]?[\p{Lu}\p{Lt}\p{Lm}\p{Lo}\p{M}]*[\p{Ll}\p{Lm}\p{Lo}\p{M}]+` +
+			`(?i:'s|'t|'re|'ve|'m|'ll|'d)?|` +
+			`[^\r\n\p{L}\p{N}]?[\p{Lu}\p{Lt}\p{Lm}\p{Lo}\p{M}]+[\p{Ll}\p{Lm}\p{Lo}\p{M}]*` +
+			`(?i:'s|'t|'re|'ve|'m|'ll|'d)?|` +
+			`\p{N}{1,3}| ?[^\s\p{L}\p{N}]+[\r\n/]*|\s*[\r\n]+|\s+`
+		splitNewlineRuns = true
+	default:
+		return nil, false, fmt.Errorf("unknown encoding %q", encoding)
+	}
+	re, err := regexp.Compile(pattern)
+	return re, splitNewlineRuns, err
+}
+
+// nextRune Returns the first rune of s.
+func nextRune(s string) rune {
+	r, _ := utf8.DecodeRuneInString(s)
+	return r
+}
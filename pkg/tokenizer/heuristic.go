@@ -0,0 +1,20 @@
+package tokenizer
+
+import "fmt"
+
+// CharsPerToken Is a rough estimate of how many characters make up a single token.
+const CharsPerToken = 4
+
+// Heuristic Estimates tokens from the length of the text, for models
+// without a tokenizer of their own.
+type Heuristic struct{}
+
+// Name Describes the heuristic.
+func (Heuristic) Name() string {
+	return fmt.Sprintf("chars/%d heuristic", CharsPerToken)
+}
+
+// CountTokens Returns the estimated number of tokens, rounding up.
+func (Heuristic) CountTokens(text string) int {
+	return (len(text) + CharsPerToken - 1) / CharsPerToken
+}
@@ -0,0 +1,120 @@
+// tokenizer Estimates how many tokens a piece of text will use, so that
+// token budgets can be checked before a request is sent to a backend.
+package tokenizer
+
+import (
+	"embed"
+	"path/filepath"
+	"strings"
+
+	"github.com/redhat-et/copilot-ops/pkg/cmd/config"
+	"github.com/redhat-et/copilot-ops/pkg/logger"
+)
+
+// Tokenizer Counts the tokens in text the way a particular model would.
+type Tokenizer interface {
+	// Name Describes the tokenizer, for logging which estimator was used.
+	Name() string
+	// CountTokens Returns the number of tokens in the given text.
+	CountTokens(text string) int
+}
+
+// Names of the BPE encodings used by OpenAI models.
+const (
+	EncodingR50K   = "r50k_base"
+	EncodingP50K   = "p50k_base"
+	EncodingCL100K = "cl100k_base"
+	EncodingO200K  = "o200k_base"
+)
+
+// DirName Is the directory under the cache directory where BPE rank files
+// (<encoding>.tiktoken) are looked up before the embedded ones.
+const DirName = "tokenizers"
+
+// embeddedRanks Holds the BPE ranks of every encoding, as published with OpenAI's tiktoken.
+//
+//go:embed encodings/*.tiktoken
+var embeddedRanks embed.FS //nolint:gochecknoglobals // embedded files have to be package variables
+
+// ForModel Returns the most accurate tokenizer available for the given model.
+// OpenAI models are tokenized precisely with the BPE ranks of their encoding, read from
+// the tokenizer directory when present there and from the embedded ranks otherwise.
+// Other models use the character heuristic.
+func ForModel(model string) Tokenizer {
+	encoding := EncodingForModel(model)
+	if encoding == "" {
+		logger.Debugf("estimating tokens for model %q with the %s\n", model, Heuristic{}.Name())
+		return Heuristic{}
+	}
+
+	if ranksFile, err := RanksFile(encoding); err == nil {
+		if tok, err := LoadBPE(encoding, ranksFile); err == nil {
+			logger.Debugf("counting tokens for model %q with %s from %s\n", model, tok.Name(), ranksFile)
+			return tok
+		}
+	}
+	tok, err := EmbeddedBPE(encoding)
+	if err != nil {
+		logger.Debugf("could not load the %s tokenizer (%s), estimating tokens for model %q with the %s\n",
+			encoding, err, model, Heuristic{}.Name())
+		return Heuristic{}
+	}
+	logger.Debugf("counting tokens for model %q with %s\n", model, tok.Name())
+	return tok
+}
+
+// EmbeddedBPE Returns a BPE tokenizer for the given encoding, using the ranks embedded in the binary.
+func EmbeddedBPE(encoding string) (*BPE, error) {
+	f, err := embeddedRanks.Open("encodings/" + encoding + ".tiktoken")
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return NewBPE(encoding, f)
+}
+
+// EncodingForModel Returns the name of the BPE encoding used by the given OpenAI model,
+// or an empty string if the model is unknown.
+func EncodingForModel(model string) string {
+	prefixes := []struct {
+		prefix   string
+		encoding string
+	}{
+		{"gpt-4o", EncodingO200K},
+		{"gpt-4.1", EncodingO200K},
+		{"gpt-4.5", EncodingO200K},
+		{"gpt-5", EncodingO200K},
+		{"o1", EncodingO200K},
+		{"o3", EncodingO200K},
+		{"o4", EncodingO200K},
+		{"gpt-4", EncodingCL100K},
+		{"gpt-3.5", EncodingCL100K},
+		{"text-embedding-", EncodingCL100K},
+		{"code-davinci-", EncodingP50K},
+		{"code-cushman-", EncodingP50K},
+		{"text-davinci-002", EncodingP50K},
+		{"text-davinci-003", EncodingP50K},
+		{"text-davinci-edit-", EncodingP50K},
+		{"code-davinci-edit-", EncodingP50K},
+		{"davinci", EncodingR50K},
+		{"curie", EncodingR50K},
+		{"babbage", EncodingR50K},
+		{"ada", EncodingR50K},
+		{"text-", EncodingR50K},
+	}
+	for _, p := range prefixes {
+		if strings.HasPrefix(model, p.prefix) {
+			return p.encoding
+		}
+	}
+	return ""
+}
+
+// RanksFile Returns the path where BPE ranks which replace the embedded ones for the given encoding are expected.
+func RanksFile(encoding string) (string, error) {
+	cacheDir, err := config.CacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(cacheDir, DirName, encoding+".tiktoken"), nil
+}
@@ -0,0 +1,13 @@
+package tokenizer_test
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func TestTokenizer(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Tokenizer Suite")
+}
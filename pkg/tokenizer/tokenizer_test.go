@@ -0,0 +1,121 @@
+package tokenizer_test
+
+import (
+	"encoding/base64"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/redhat-et/copilot-ops/pkg/cmd/config"
+	"github.com/redhat-et/copilot-ops/pkg/tokenizer"
+)
+
+// ranksFile Builds the contents of a '.tiktoken' file from the given tokens, ranked in order.
+func ranksFile(tokens ...string) string {
+	lines := make([]string, len(tokens))
+	for i, token := range tokens {
+		lines[i] = fmt.Sprintf("%s %d", base64.StdEncoding.EncodeToString([]byte(token)), i)
+	}
+	return strings.Join(lines, "\n")
+}
+
+var _ = Describe("Tokenizer", func() {
+	var cacheDir string
+
+	BeforeEach(func() {
+		var err error
+		cacheDir, err = os.MkdirTemp("", "copilot-ops-tokenizers")
+		Expect(err).NotTo(HaveOccurred())
+		DeferCleanup(os.RemoveAll, cacheDir)
+		DeferCleanup(os.Setenv, config.CacheDirEnv, os.Getenv(config.CacheDirEnv))
+		Expect(os.Setenv(config.CacheDirEnv, cacheDir)).To(Succeed())
+	})
+
+	It("maps OpenAI models to their encodings", func() {
+		Expect(tokenizer.EncodingForModel("code-davinci-002")).To(Equal(tokenizer.EncodingP50K))
+		Expect(tokenizer.EncodingForModel("gpt-3.5-turbo")).To(Equal(tokenizer.EncodingCL100K))
+		Expect(tokenizer.EncodingForModel("gpt-4o-mini")).To(Equal(tokenizer.EncodingO200K))
+		Expect(tokenizer.EncodingForModel("bloom")).To(BeEmpty())
+	})
+
+	It("falls back to the heuristic for other models", func() {
+		tok := tokenizer.ForModel("bloom")
+		Expect(tok).To(Equal(tokenizer.Heuristic{}))
+		Expect(tok.CountTokens("12345678")).To(Equal(2))
+		Expect(tok.CountTokens("123456789")).To(Equal(3))
+	})
+
+	// counts from OpenAI's cookbook on counting tokens with tiktoken
+	DescribeTable("counts tokens with the embedded ranks",
+		func(model, encoding, text string, count int) {
+			tok := tokenizer.ForModel(model)
+			Expect(tok.Name()).To(ContainSubstring(encoding))
+			Expect(tok.CountTokens(text)).To(Equal(count))
+		},
+		Entry("r50k_base", "davinci", tokenizer.EncodingR50K, "antidisestablishmentarianism", 5),
+		Entry("p50k_base", "code-davinci-002", tokenizer.EncodingP50K, "antidisestablishmentarianism", 5),
+		Entry("cl100k_base", "gpt-4", tokenizer.EncodingCL100K, "antidisestablishmentarianism", 6),
+		Entry("o200k_base", "gpt-4o", tokenizer.EncodingO200K, "tiktoken is great!", 6),
+	)
+
+	It("prefers the ranks in the cache directory", func() {
+		ranks := ranksFile("k", "i", "n", "d", ":", " ", "P", "o", "ki", "kin", "kind", " P", " Po")
+		ranksPath, err := tokenizer.RanksFile(tokenizer.EncodingP50K)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(os.MkdirAll(filepath.Dir(ranksPath), 0755)).To(Succeed())
+		Expect(os.WriteFile(ranksPath, []byte(ranks), 0600)).To(Succeed())
+
+		tok := tokenizer.ForModel("code-davinci-002")
+		Expect(tok.Name()).To(ContainSubstring(tokenizer.EncodingP50K))
+		// "kind" ":" " Po" "d"
+		Expect(tok.CountTokens("kind: Pod")).To(Equal(4))
+		// "kind" ":" " " " Po" "d", the last space of a run joins the next word
+		Expect(tok.CountTokens("kind:  Pod")).To(Equal(5))
+	})
+
+	// Every piece of these strings is a single token of the real encoding, so the ranks only need to hold
+	// the pieces, and the count shows that the text was split into the same pieces as tiktoken splits it.
+	DescribeTable("splits the text into the same pieces as tiktoken",
+		func(encoding, text string, pieces []string) {
+			tok, err := tokenizer.NewBPE(encoding, strings.NewReader(ranksFile(pieces...)))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(strings.Join(pieces, "")).To(Equal(text))
+			Expect(tok.CountTokens(text)).To(Equal(len(pieces)))
+		},
+		Entry("words", tokenizer.EncodingCL100K, "hello world", []string{"hello", " world"}),
+		Entry("punctuation", tokenizer.EncodingCL100K, "Hello, world!", []string{"Hello", ",", " world", "!"}),
+		Entry("numbers in groups of three", tokenizer.EncodingCL100K, "1234567", []string{"123", "456", "7"}),
+		Entry("yaml", tokenizer.EncodingCL100K, "kind: Pod\n", []string{"kind", ":", " Pod", "\n"}),
+		Entry("blank lines and indentation", tokenizer.EncodingCL100K, "line1\n\n  line2",
+			[]string{"line", "1", "\n\n", " ", " line", "2"}),
+		Entry("runs of spaces", tokenizer.EncodingP50K, "a  b", []string{"a", " ", " b"}),
+		Entry("words with o200k", tokenizer.EncodingO200K, "hello world", []string{"hello", " world"}),
+	)
+
+	// The slice of the real cl100k_base ranks holds every token which can be formed from the pieces of these
+	// strings, so the merges run exactly as with the full ranks. The counts are those tiktoken encodes the
+	// strings to in OpenAI's cookbook, e.g. "tiktoken is great!" as [83 1609 5963 374 2294 0].
+	DescribeTable("counts as many tokens as tiktoken with the real ranks",
+		func(text string, count int) {
+			tok, err := tokenizer.LoadBPE(tokenizer.EncodingCL100K, filepath.Join("testdata", "cl100k_base_slice.tiktoken"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(tok.CountTokens(text)).To(Equal(count))
+		},
+		Entry("words", "hello world", 2),
+		Entry("words which are merged from pieces", "tiktoken is great!", 6),
+		Entry("a long word", "antidisestablishmentarianism", 6),
+		Entry("numbers and spaces", "2 + 2 = 4", 7),
+		Entry("multi-byte characters split across tokens", "お誕生日おめでとう", 9),
+	)
+
+	It("rejects malformed ranks", func() {
+		_, err := tokenizer.NewBPE(tokenizer.EncodingCL100K, strings.NewReader("not-a-rank-line"))
+		Expect(err).To(HaveOccurred())
+		_, err = tokenizer.NewBPE("unknown", strings.NewReader(ranksFile("a")))
+		Expect(err).To(HaveOccurred())
+	})
+})
@@ -0,0 +1,52 @@
+package utils
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"golang.org/x/net/http/httpproxy"
+)
+
+// ProxyFromEnvironment Returns the proxy which the request is sent through, according to
+// HTTP_PROXY, HTTPS_PROXY and NO_PROXY. Unlike http.ProxyFromEnvironment, which only reads
+// them once per process, the variables are read on every request.
+func ProxyFromEnvironment(req *http.Request) (*url.URL, error) {
+	return httpproxy.FromEnvironment().ProxyFunc()(req.URL)
+}
+
+// ParseProxy Parses the URL of a proxy, which must have an http, https or socks5 scheme and a host.
+func ParseProxy(proxy string) (*url.URL, error) {
+	proxyURL, err := url.Parse(proxy)
+	if err != nil {
+		return nil, fmt.Errorf("invalid proxy URL %q: %w", proxy, err)
+	}
+	switch proxyURL.Scheme {
+	case "http", "https", "socks5":
+	default:
+		return nil, fmt.Errorf("invalid proxy URL %q, expected a URL such as http://proxy.example.com:3128", proxy)
+	}
+	if proxyURL.Host == "" {
+		return nil, fmt.Errorf("invalid proxy URL %q, the host is missing", proxy)
+	}
+	return proxyURL, nil
+}
+
+// ProxyTransport Returns a copy of the transport which sends every request through the proxy,
+// or through the proxy from the environment when the proxy is nil.
+// A nil transport stands for http.DefaultTransport.
+func ProxyTransport(transport http.RoundTripper, proxy *url.URL) (http.RoundTripper, error) {
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	httpTransport, ok := transport.(*http.Transport)
+	if !ok {
+		return nil, fmt.Errorf("cannot send requests through a proxy with a %T", transport)
+	}
+	httpTransport = httpTransport.Clone()
+	httpTransport.Proxy = ProxyFromEnvironment
+	if proxy != nil {
+		httpTransport.Proxy = http.ProxyURL(proxy)
+	}
+	return httpTransport, nil
+}
@@ -4,6 +4,9 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 )
 
 // JSONRequest Sends an HTTP Request with some default headers, and writes the
@@ -22,7 +25,7 @@ func JSONRequest(req *http.Request, c *http.Client, v interface{}) error {
 	defer res.Body.Close()
 	// wrap the HTTP error
 	if res.StatusCode < http.StatusOK || res.StatusCode >= http.StatusBadRequest {
-		return fmt.Errorf("error, status code: %d", res.StatusCode)
+		return NewStatusError(res, "")
 	}
 
 	if v != nil {
@@ -33,3 +36,54 @@ func JSONRequest(req *http.Request, c *http.Client, v interface{}) error {
 
 	return nil
 }
+
+// StatusError Is returned for a response with an error status code. Its message reads
+// 'error, status code: <code>', which is how every backend reports failed requests.
+type StatusError struct {
+	// StatusCode Is the status code of the response.
+	StatusCode int
+	// Message Is the error message returned by the server, if any.
+	Message string
+	// RetryAfter Is how long the server asked to wait before retrying with a Retry-After header,
+	// and HasRetryAfter reports whether it did.
+	RetryAfter    time.Duration
+	HasRetryAfter bool
+}
+
+// NewStatusError Returns a StatusError for the response, with the given message from the server.
+func NewStatusError(res *http.Response, message string) *StatusError {
+	retryAfter, ok := ParseRetryAfter(res.Header.Get("Retry-After"), time.Now())
+	return &StatusError{StatusCode: res.StatusCode, Message: message, RetryAfter: retryAfter, HasRetryAfter: ok}
+}
+
+// Error Describes the status code and the message of the error.
+func (e *StatusError) Error() string {
+	if e.Message != "" {
+		return fmt.Sprintf("error, status code: %d, message: %s", e.StatusCode, e.Message)
+	}
+	return fmt.Sprintf("error, status code: %d", e.StatusCode)
+}
+
+// ParseRetryAfter Parses the value of a Retry-After header, which is either a number of seconds
+// or an HTTP date, into the delay from now. A date in the past means no delay.
+// It reports false when the value is empty or can't be parsed.
+func ParseRetryAfter(value string, now time.Time) (time.Duration, bool) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+	date, err := http.ParseTime(value)
+	if err != nil {
+		return 0, false
+	}
+	if delay := date.Sub(now); delay > 0 {
+		return delay, true
+	}
+	return 0, true
+}